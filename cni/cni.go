@@ -10,12 +10,28 @@ import (
 const (
 	// CNI commands.
 	Cmd = "CNI_COMMAND"
+	// StoreBackend is the environment variable which selects the store backend of the plugins, json or bolt.
+	// If it is unset, the backend of the existing store files is used.
+	StoreBackend = "AZURE_CNI_STORE_BACKEND"
+	// StoreChecksum is the environment variable which makes the plugins write their json store files with a checksum
+	// when set to true. Versions before the checksum can't read these files, so unset it and let each file be written
+	// once before rolling back to them.
+	StoreChecksum = "AZURE_CNI_STORE_CHECKSUM"
+	// LockStorePerChange is the environment variable which makes the network plugin lock the store only around each
+	// change of its state, and order the operations on the same container with named locks, when set to true.
+	LockStorePerChange = "AZURE_CNI_LOCK_STORE_PER_CHANGE"
 	// CmdAdd - CNI ADD command.
 	CmdAdd = "ADD"
 	// CmdGet - CNI GET command.
 	CmdGet = "GET"
 	// CmdDel - CNI DEL command.
 	CmdDel = "DEL"
+	// CmdCheck - CNI CHECK command.
+	CmdCheck = "CHECK"
+	// CmdStatus - CNI STATUS command.
+	CmdStatus = "STATUS"
+	// CmdGC - CNI GC command.
+	CmdGC = "GC"
 	// CmdUpdate - CNI UPDATE command.
 	CmdUpdate = "UPDATE"
 	// CmdVersion - CNI VERSION command.
@@ -26,13 +42,20 @@ const (
 
 	// CNI errors.
 	ErrRuntime = 100
+	// ErrEndpointDiverged is returned by CHECK when the endpoint datapath doesn't match the endpoint state.
+	ErrEndpointDiverged = 101
+	// ErrPluginNotAvailable is returned by STATUS when the plugin cannot service ADD requests.
+	ErrPluginNotAvailable = 50
 
 	// DefaultVersion is the CNI version used when no version is specified in a network config file.
 	defaultVersion = "0.2.0"
 )
 
 // Supported CNI versions.
-var supportedVersions = []string{"0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0"}
+var supportedVersions = []string{"0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0", "1.0.0", "1.1.0"}
+
+// statusGCVersion is the CNI version which added the STATUS and GC commands.
+const statusGCVersion = "1.1.0"
 
 // CNI contract.
 type PluginApi interface {
@@ -41,3 +64,11 @@ type PluginApi interface {
 	Delete(args *cniSkel.CmdArgs) error
 	Update(args *cniSkel.CmdArgs) error
 }
+
+// LifecycleApi is implemented by plugins which handle the CHECK, STATUS and GC commands.
+// CHECK is handled by Get for plugins which don't implement it.
+type LifecycleApi interface {
+	Check(args *cniSkel.CmdArgs) error
+	Status(args *cniSkel.CmdArgs) error
+	GC(args *cniSkel.CmdArgs) error
+}
@@ -243,7 +243,7 @@ func (plugin *ipamPlugin) Add(args *cniSkel.CmdArgs) error {
 	}
 
 	// Convert result to the requested CNI version.
-	res, err := result.GetAsVersion(nwCfg.CNIVersion)
+	res, err := cni.GetResultAsVersion(result, nwCfg.CNIVersion)
 	if err != nil {
 		err = plugin.Errorf("Failed to convert result: %v", err)
 		return err
@@ -5,16 +5,29 @@ package cni
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/Azure/azure-container-networking/network/policy"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
 )
 
 const (
-	PolicyStr string = "Policy"
+	PolicyStr             string = "Policy"
+	SecondaryInterfaceStr string = "SecondaryInterface"
+	// PodMTUAnnotation is the Pod annotation which overrides the MTU of the network config for the Pod.
+	PodMTUAnnotation string = "kubernetes.azure.com/mtu"
+	// PodIngressBandwidthAnnotation and PodEgressBandwidthAnnotation limit the traffic of the Pod when the
+	// runtime doesn't pass them with the bandwidth capability.
+	PodIngressBandwidthAnnotation string = "kubernetes.io/ingress-bandwidth"
+	PodEgressBandwidthAnnotation  string = "kubernetes.io/egress-bandwidth"
 )
 
+// ErrInvalidSecondaryInterface is returned for a secondary interface without an interface name, network or IPAM type.
+var ErrInvalidSecondaryInterface = errors.New("secondary interface requires ifName, network and ipam type")
+
 // KVPair represents a K-V pair of a json object.
 type KVPair struct {
 	Name  string          `json:"name"`
@@ -31,6 +44,20 @@ type PortMapping struct {
 type RuntimeConfig struct {
 	PortMappings []PortMapping    `json:"portMappings,omitempty"`
 	DNS          RuntimeDNSConfig `json:"dns,omitempty"`
+	Bandwidth    *BandwidthConfig `json:"bandwidth,omitempty"`
+	// PodAnnotations are the annotations of the Pod, which containerd passes with the
+	// io.kubernetes.cri.pod-annotations capability.
+	PodAnnotations map[string]string `json:"io.kubernetes.cri.pod-annotations,omitempty"`
+}
+
+// BandwidthConfig is the bandwidth capability, which runtimes fill from the kubernetes.io/ingress-bandwidth
+// and kubernetes.io/egress-bandwidth Pod annotations. Rates are in bits per second and bursts in bits.
+// https://github.com/containernetworking/cni/blob/master/CONVENTIONS.md
+type BandwidthConfig struct {
+	IngressRate  uint64 `json:"ingressRate,omitempty"`
+	IngressBurst uint64 `json:"ingressBurst,omitempty"`
+	EgressRate   uint64 `json:"egressRate,omitempty"`
+	EgressBurst  uint64 `json:"egressBurst,omitempty"`
 }
 
 // https://github.com/kubernetes/kubernetes/blob/master/pkg/kubelet/dockershim/network/cni/cni.go#L104
@@ -49,10 +76,12 @@ type NetworkConfig struct {
 	Master                        string   `json:"master,omitempty"`
 	AdapterName                   string   `json:"adapterName,omitempty"`
 	Bridge                        string   `json:"bridge,omitempty"`
+	MTU                           int      `json:"mtu,omitempty"`
 	LogLevel                      string   `json:"logLevel,omitempty"`
 	LogTarget                     string   `json:"logTarget,omitempty"`
 	InfraVnetAddressSpace         string   `json:"infraVnetAddressSpace,omitempty"`
 	IPV6Mode                      string   `json:"ipv6Mode,omitempty"`
+	IPVlanMode                    string   `json:"ipvlanMode,omitempty"`
 	ServiceCidrs                  string   `json:"serviceCidrs,omitempty"`
 	VnetCidrs                     string   `json:"vnetCidrs,omitempty"`
 	PodNamespaceForDualNetwork    []string `json:"podNamespaceForDualNetwork,omitempty"`
@@ -76,6 +105,34 @@ type NetworkConfig struct {
 	RuntimeConfig   RuntimeConfig   `json:"runtimeConfig,omitempty"`
 	WindowsSettings WindowsSettings `json:"windowsSettings,omitempty"`
 	AdditionalArgs  []KVPair        `json:"AdditionalArgs,omitempty"`
+	// PrevResult is the result of the ADD which CHECK verifies.
+	PrevResult *cniTypesCurr.Result `json:"prevResult,omitempty"`
+	// ValidAttachments are the attachments which GC must not remove.
+	ValidAttachments []Attachment `json:"cni.dev/valid-attachments,omitempty"`
+}
+
+// Attachment identifies the attachment of a container to a network by its interface.
+type Attachment struct {
+	ContainerID string `json:"containerID"`
+	IfName      string `json:"ifname"`
+}
+
+// SecondaryInterface is an additional interface of a Pod, such as one on a storage or management network. Each
+// secondary interface is attached to its own network and gets its addresses from the IPAM plugin of that network.
+// Secondary interfaces are passed as AdditionalArgs named SecondaryInterface.
+type SecondaryInterface struct {
+	IfName  string `json:"ifName"`
+	Network string `json:"network"`
+	Mode    string `json:"mode,omitempty"`
+	Master  string `json:"master,omitempty"`
+	Bridge  string `json:"bridge,omitempty"`
+	MTU     int    `json:"mtu,omitempty"`
+	Ipam    struct {
+		Type        string `json:"type"`
+		Environment string `json:"environment,omitempty"`
+		AddrSpace   string `json:"addressSpace,omitempty"`
+		Subnet      string `json:"subnet,omitempty"`
+	} `json:"ipam"`
 }
 
 type WindowsSettings struct {
@@ -132,6 +189,29 @@ func GetPoliciesFromNwCfg(kvp []KVPair) []policy.Policy {
 	return policies
 }
 
+// GetSecondaryInterfacesFromNwCfg returns the secondary interfaces of the Pod from network config.
+func GetSecondaryInterfacesFromNwCfg(kvp []KVPair) ([]SecondaryInterface, error) {
+	var ifaces []SecondaryInterface
+	for _, pair := range kvp {
+		if pair.Name != SecondaryInterfaceStr {
+			continue
+		}
+
+		var iface SecondaryInterface
+		if err := json.Unmarshal(pair.Value, &iface); err != nil {
+			return nil, fmt.Errorf("failed to parse secondary interface %s: %w", string(pair.Value), err)
+		}
+
+		if iface.IfName == "" || iface.Network == "" || iface.Ipam.Type == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSecondaryInterface, string(pair.Value))
+		}
+
+		ifaces = append(ifaces, iface)
+	}
+
+	return ifaces, nil
+}
+
 // Serialize marshals a network configuration to bytes.
 func (nwcfg *NetworkConfig) Serialize() []byte {
 	bytes, _ := json.Marshal(nwcfg)
@@ -48,7 +48,16 @@ func NewCNSInvoker(podName, namespace string, cnsClient cnsclient) *CNSIPAMInvok
 	}
 }
 
-// Add uses the requestipconfig API in cns, and returns ipv4 and a nil ipv6 as CNS doesn't support IPv6 yet
+// podAnnotations returns the annotations of the Pod which the runtime passed with the pod-annotations capability,
+// such as the subnet and sticky IP annotations which CNS assigns the IP by.
+func podAnnotations(nwCfg *cni.NetworkConfig) map[string]string {
+	if nwCfg == nil {
+		return nil
+	}
+	return nwCfg.RuntimeConfig.PodAnnotations
+}
+
+// Add uses the requestipconfig API in cns, and returns ipv4, and ipv6 as well if CNS assigned the Pod an IPv6 address
 func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, error) {
 	// Parse Pod arguments.
 	podInfo := cns.KubernetesPodInfo{
@@ -70,6 +79,7 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 		OrchestratorContext: orchestratorContext,
 		PodInterfaceID:      GetEndpointID(addConfig.args),
 		InfraContainerID:    addConfig.args.ContainerID,
+		PodAnnotations:      podAnnotations(addConfig.nwCfg),
 	}
 
 	log.Printf("Requesting IP for pod %+v using ipconfig %+v", podInfo, ipconfig)
@@ -128,6 +138,14 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 		},
 	}
 
+	// a dual-stack Pod is assigned an IPv6 address alongside its IPv4 address
+	if response.PodIpInfo.PodIPConfigV6.IPAddress != "" {
+		addResult.ipv6Result, err = getIPv6Result(&response.PodIpInfo)
+		if err != nil {
+			return IPAMAddResult{}, err
+		}
+	}
+
 	// set subnet prefix for host vm
 	err = setHostOptions(&addResult.hostSubnetPrefix, ncipnet, addConfig.options, &info)
 	if err != nil {
@@ -137,6 +155,36 @@ func (invoker *CNSIPAMInvoker) Add(addConfig IPAMAddConfig) (IPAMAddResult, erro
 	return addResult, nil
 }
 
+// getIPv6Result builds the IPv6 result of a dual-stack Pod from the IPv6 address assigned by CNS.
+func getIPv6Result(podIPInfo *cns.PodIpInfo) (*cniTypesCurr.Result, error) {
+	ncgw := net.ParseIP(podIPInfo.NetworkContainerPrimaryIPConfigV6.GatewayIPAddress)
+	if ncgw == nil {
+		return nil, errors.Wrapf(errInvalidArgs, "IPv6 gateway address %s from response is invalid", podIPInfo.NetworkContainerPrimaryIPConfigV6.GatewayIPAddress)
+	}
+
+	podIPAddress := podIPInfo.PodIPConfigV6.IPAddress
+	ip, ncipnet, err := net.ParseCIDR(podIPAddress + "/" + fmt.Sprint(podIPInfo.PodIPConfigV6.PrefixLength))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse IPv6 address %s from response", podIPAddress)
+	}
+
+	return &cniTypesCurr.Result{
+		IPs: []*cniTypesCurr.IPConfig{
+			{
+				Version: "6",
+				Address: net.IPNet{IP: ip, Mask: ncipnet.Mask},
+				Gateway: ncgw,
+			},
+		},
+		Routes: []*cniTypes.Route{
+			{
+				Dst: network.Ipv6DefaultRouteDstPrefix,
+				GW:  ncgw,
+			},
+		},
+	}, nil
+}
+
 func setHostOptions(hostSubnetPrefix, ncSubnetPrefix *net.IPNet, options map[string]interface{}, info *IPv4ResultInfo) error {
 	// get the name of the primary IP address
 	_, hostIPNet, err := net.ParseCIDR(info.hostSubnet)
@@ -209,6 +257,7 @@ func (invoker *CNSIPAMInvoker) Delete(address *net.IPNet, nwCfg *cni.NetworkConf
 		OrchestratorContext: orchestratorContext,
 		PodInterfaceID:      GetEndpointID(args),
 		InfraContainerID:    args.ContainerID,
+		PodAnnotations:      podAnnotations(nwCfg),
 	}
 
 	if address != nil {
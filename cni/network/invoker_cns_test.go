@@ -112,6 +112,97 @@ func TestCNSIPAMInvoker_Add(t *testing.T) {
 			want1:   nil,
 			wantErr: false,
 		},
+		{
+			name: "Test happy CNI add with dual-stack Pod",
+			fields: fields{
+				podName:      testPodInfo.PodName,
+				podNamespace: testPodInfo.PodNamespace,
+				cnsClient: &MockCNSClient{
+					require: require,
+					request: requestIPAddressHandler{
+						ipconfigArgument: getTestIPConfigRequest(),
+						result: &cns.IPConfigResponse{
+							PodIpInfo: cns.PodIpInfo{
+								PodIPConfig: cns.IPSubnet{
+									IPAddress:    "10.0.1.10",
+									PrefixLength: 24,
+								},
+								NetworkContainerPrimaryIPConfig: cns.IPConfiguration{
+									IPSubnet: cns.IPSubnet{
+										IPAddress:    "10.0.1.0",
+										PrefixLength: 24,
+									},
+									DNSServers:       nil,
+									GatewayIPAddress: "10.0.0.1",
+								},
+								HostPrimaryIPInfo: cns.HostIPInfo{
+									Gateway:   "10.0.0.1",
+									PrimaryIP: "10.0.0.1",
+									Subnet:    "10.0.0.0/24",
+								},
+								PodIPConfigV6: cns.IPSubnet{
+									IPAddress:    "fd00::10",
+									PrefixLength: 64,
+								},
+								NetworkContainerPrimaryIPConfigV6: cns.IPConfiguration{
+									IPSubnet: cns.IPSubnet{
+										IPAddress:    "fd00::",
+										PrefixLength: 64,
+									},
+									GatewayIPAddress: "fd00::1",
+								},
+							},
+							Response: cns.Response{
+								ReturnCode: 0,
+								Message:    "",
+							},
+						},
+						err: nil,
+					},
+				},
+			},
+			args: args{
+				nwCfg: &cni.NetworkConfig{},
+				args: &cniSkel.CmdArgs{
+					ContainerID: "testcontainerid",
+					Netns:       "testnetns",
+					IfName:      "testifname",
+				},
+				hostSubnetPrefix: getCIDRNotationForAddress("10.0.0.1/24"),
+				options:          map[string]interface{}{},
+			},
+			want: &cniTypesCurr.Result{
+				IPs: []*cniTypesCurr.IPConfig{
+					{
+						Version: "4",
+						Address: *getCIDRNotationForAddress("10.0.1.10/24"),
+						Gateway: net.ParseIP("10.0.0.1"),
+					},
+				},
+				Routes: []*cniTypes.Route{
+					{
+						Dst: network.Ipv4DefaultRouteDstPrefix,
+						GW:  net.ParseIP("10.0.0.1"),
+					},
+				},
+			},
+			want1: &cniTypesCurr.Result{
+				IPs: []*cniTypesCurr.IPConfig{
+					{
+						Version: "6",
+						Address: *getCIDRNotationForAddress("fd00::10/64"),
+						Gateway: net.ParseIP("fd00::1"),
+					},
+				},
+				Routes: []*cniTypes.Route{
+					{
+						Dst: network.Ipv6DefaultRouteDstPrefix,
+						GW:  net.ParseIP("fd00::1"),
+					},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "fail to request IP address from cns",
 			fields: fields{
@@ -150,6 +241,50 @@ func TestCNSIPAMInvoker_Add(t *testing.T) {
 	}
 }
 
+func TestCNSIPAMInvokerForwardsPodAnnotations(t *testing.T) {
+	require := require.New(t) //nolint further usage of require without passing t
+	annotations := map[string]string{
+		cns.PodSubnetAnnotation: "podsubnet2",
+		cns.StickyIPAnnotation:  "true",
+	}
+	nwCfg := &cni.NetworkConfig{RuntimeConfig: cni.RuntimeConfig{PodAnnotations: annotations}}
+	args := &cniSkel.CmdArgs{
+		ContainerID: "testcontainerid",
+		Netns:       "testnetns",
+		IfName:      "testifname",
+	}
+	ipconfigArgument := getTestIPConfigRequest()
+	ipconfigArgument.PodAnnotations = annotations
+
+	invoker := &CNSIPAMInvoker{
+		podName:      testPodInfo.PodName,
+		podNamespace: testPodInfo.PodNamespace,
+		cnsClient: &MockCNSClient{
+			require: require,
+			request: requestIPAddressHandler{
+				ipconfigArgument: ipconfigArgument,
+				result: &cns.IPConfigResponse{
+					PodIpInfo: cns.PodIpInfo{
+						PodIPConfig: cns.IPSubnet{IPAddress: "10.0.1.10", PrefixLength: 24},
+						NetworkContainerPrimaryIPConfig: cns.IPConfiguration{
+							IPSubnet:         cns.IPSubnet{IPAddress: "10.0.1.0", PrefixLength: 24},
+							GatewayIPAddress: "10.0.0.1",
+						},
+						HostPrimaryIPInfo: cns.HostIPInfo{Gateway: "10.0.0.1", PrimaryIP: "10.0.0.1", Subnet: "10.0.0.0/24"},
+					},
+				},
+			},
+			release: releaseIPAddressHandler{
+				ipconfigArgument: ipconfigArgument,
+			},
+		},
+	}
+
+	_, err := invoker.Add(IPAMAddConfig{nwCfg: nwCfg, args: args, options: map[string]interface{}{}})
+	require.NoError(err)
+	require.NoError(invoker.Delete(nil, nwCfg, args, map[string]interface{}{}))
+}
+
 func TestCNSIPAMInvoker_Delete(t *testing.T) {
 	require := require.New(t) //nolint further usage of require without passing t
 	type fields struct {
@@ -0,0 +1,254 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/cni/util"
+	cnscli "github.com/Azure/azure-container-networking/cns/client"
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/telemetry"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// Check handles CNI check commands. It verifies that the endpoint created by ADD still exists,
+// still holds the addresses of the previous result, and that its datapath matches the endpoint state.
+func (plugin *NetPlugin) Check(args *cniSkel.CmdArgs) error {
+	var (
+		err       error
+		nwCfg     *cni.NetworkConfig
+		epInfo    *network.EndpointInfo
+		networkID string
+	)
+
+	log.Printf("[cni-net] Processing CHECK command with args {ContainerID:%v Netns:%v IfName:%v Args:%v Path:%v}.",
+		args.ContainerID, args.Netns, args.IfName, args.Args, args.Path)
+
+	defer func() {
+		log.Printf("[cni-net] CHECK command completed with err:%v.", err)
+	}()
+
+	// Parse network configuration from stdin.
+	if nwCfg, err = cni.ParseNetworkConfig(args.StdinData); err != nil {
+		err = plugin.Errorf("Failed to parse network configuration: %v.", err)
+		return err
+	}
+
+	iptables.DisableIPTableLock = nwCfg.DisableIPTableLock
+
+	if networkID, err = plugin.getNetworkName(args.Netns, nil, nwCfg); err != nil {
+		err = plugin.Errorf("Failed to extract network name from network config. error: %v", err)
+		return err
+	}
+
+	// Query the network.
+	if _, err = plugin.nm.GetNetworkInfo(networkID); err != nil {
+		err = plugin.Errorf("Failed to query network: %v", err)
+		return err
+	}
+
+	endpointID := GetEndpointID(args)
+
+	// Query the endpoint.
+	if epInfo, err = plugin.nm.GetEndpointInfo(networkID, endpointID); err != nil {
+		err = plugin.Errorf("Failed to query endpoint: %v", err)
+		return err
+	}
+
+	var divergences []string
+
+	// Every address handed out by ADD must still be assigned to the endpoint.
+	if nwCfg.PrevResult != nil {
+		for _, ipConfig := range nwCfg.PrevResult.IPs {
+			found := false
+			for _, address := range epInfo.IPAddresses {
+				if address.IP.Equal(ipConfig.Address.IP) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				divergences = append(divergences, fmt.Sprintf("address %v of the previous result is not assigned to endpoint %v", ipConfig.Address.String(), endpointID))
+			}
+		}
+	}
+
+	// Validate the interface, routes and rules of the endpoint.
+	var divergedErr *network.EndpointDivergedError
+	if err = plugin.nm.CheckEndpoint(networkID, endpointID, args.IfName); err != nil {
+		if !errors.As(err, &divergedErr) {
+			err = plugin.Errorf("Failed to check endpoint: %v", err)
+			return err
+		}
+		divergences = append(divergences, divergedErr.Divergences...)
+	}
+
+	if len(divergences) > 0 {
+		err = plugin.Error(&cniTypes.Error{
+			Code:    cni.ErrEndpointDiverged,
+			Msg:     fmt.Sprintf("endpoint %v diverged from its state", endpointID),
+			Details: strings.Join(divergences, "; "),
+		})
+		return err
+	}
+
+	return nil
+}
+
+// Status handles CNI status commands. The plugin is ready when its IPAM source is reachable.
+func (plugin *NetPlugin) Status(args *cniSkel.CmdArgs) error {
+	nwCfg, err := cni.ParseNetworkConfig(args.StdinData)
+	if err != nil {
+		return plugin.Errorf("Failed to parse network configuration: %v.", err)
+	}
+
+	if nwCfg.Ipam.Type != network.AzureCNS {
+		return nil
+	}
+
+	cnsClient, err := cnscli.New(nwCfg.CNSUrl, defaultRequestTimeout)
+	if err != nil {
+		return &cniTypes.Error{Code: cni.ErrPluginNotAvailable, Msg: "failed to create cns client", Details: err.Error()}
+	}
+
+	if err := cnsClient.GetHealthReport(context.TODO()); err != nil {
+		log.Printf("[cni-net] CNS is not available: %v", err)
+		return &cniTypes.Error{Code: cni.ErrPluginNotAvailable, Msg: "cns is not available", Details: err.Error()}
+	}
+
+	return nil
+}
+
+// GC handles CNI garbage collection commands. Every endpoint of the network that is not one of
+// the valid attachments passed by the runtime is deleted with its secondary interfaces, and its
+// addresses are released.
+func (plugin *NetPlugin) GC(args *cniSkel.CmdArgs) error {
+	nwCfg, err := cni.ParseNetworkConfig(args.StdinData)
+	if err != nil {
+		return plugin.Errorf("Failed to parse network configuration: %v.", err)
+	}
+
+	log.Printf("[cni-net] Processing GC command with %d valid attachments.", len(nwCfg.ValidAttachments))
+
+	// Multitenant endpoints are owned by the orchestrator and are not collected.
+	if nwCfg.MultiTenancy || nwCfg.ExecutionMode == string(util.Baremetal) {
+		return nil
+	}
+
+	iptables.DisableIPTableLock = nwCfg.DisableIPTableLock
+
+	networkID, err := plugin.getNetworkName("", nil, nwCfg)
+	if err != nil {
+		return plugin.Errorf("Failed to extract network name from network config. error: %v", err)
+	}
+
+	nwInfo, err := plugin.nm.GetNetworkInfo(networkID)
+	if err != nil {
+		// Without the network there are no endpoints to collect.
+		log.Printf("[cni-net] Failed to query network: %v", err)
+		return nil
+	}
+
+	endpoints, err := plugin.nm.GetAllEndpoints(networkID)
+	if err != nil {
+		log.Printf("[cni-net] Failed to get endpoints of network %s: %v", networkID, err)
+		return nil
+	}
+
+	// The endpoint state records the host side name of the container interface, so
+	// attachments are matched by the endpoint ID that ADD constructed from them.
+	valid := make(map[string]struct{}, len(nwCfg.ValidAttachments))
+	for _, attachment := range nwCfg.ValidAttachments {
+		valid[GetEndpointID(&cniSkel.CmdArgs{ContainerID: attachment.ContainerID, IfName: attachment.IfName})] = struct{}{}
+	}
+
+	var gcErr error
+	for endpointID, epInfo := range endpoints {
+		if _, ok := valid[endpointID]; ok {
+			continue
+		}
+
+		if err := plugin.collectEndpoint(networkID, endpointID, epInfo, nwCfg, &nwInfo, args); err != nil {
+			log.Errorf("[cni-net] Failed to collect endpoint %s: %v", endpointID, err)
+			gcErr = err
+		}
+	}
+
+	if gcErr != nil {
+		return plugin.RetriableError(fmt.Errorf("failed to collect stale endpoints: %w", gcErr))
+	}
+
+	return nil
+}
+
+// collectEndpoint deletes a stale endpoint and releases its addresses.
+func (plugin *NetPlugin) collectEndpoint(
+	networkID, endpointID string,
+	epInfo *network.EndpointInfo,
+	nwCfg *cni.NetworkConfig,
+	nwInfo *network.NetworkInfo,
+	args *cniSkel.CmdArgs,
+) error {
+	ipamInvoker := plugin.ipamInvoker
+	if ipamInvoker == nil {
+		switch nwCfg.Ipam.Type {
+		case network.AzureCNS:
+			cnsClient, err := cnscli.New(nwCfg.CNSUrl, defaultRequestTimeout)
+			if err != nil {
+				return errors.Wrap(err, "failed to create cns client")
+			}
+			ipamInvoker = NewCNSInvoker(epInfo.PODName, epInfo.PODNameSpace, cnsClient)
+
+		default:
+			ipamInvoker = NewAzureIpamInvoker(plugin, nwInfo)
+		}
+	}
+
+	// The endpoint is collected under the lock of its container, like the other operations on the container.
+	if err := plugin.AcquireNamedLock(epInfo.ContainerID); err != nil {
+		return err
+	}
+	defer plugin.ReleaseNamedLock(epInfo.ContainerID)
+
+	epArgs := &cniSkel.CmdArgs{
+		ContainerID: epInfo.ContainerID,
+		Netns:       epInfo.NetNsPath,
+		IfName:      getIfNameFromEndpointID(endpointID),
+		Path:        args.Path,
+		StdinData:   args.StdinData,
+	}
+
+	// The secondary interfaces are collected first, so that the endpoint still links them if collecting one fails.
+	if err := plugin.deleteLinkedEndpoints(epInfo.LinkedEndpoints, nwCfg, epArgs); err != nil {
+		return errors.Wrap(err, "failed to delete secondary interfaces")
+	}
+
+	telemetry.LogAndSendEvent(plugin.tb, fmt.Sprintf("Collecting stale endpoint:%v of container:%v", endpointID, epInfo.ContainerID))
+	if err := plugin.nm.DeleteEndpoint(networkID, endpointID); err != nil {
+		return errors.Wrap(err, "failed to delete endpoint")
+	}
+
+	for i := range epInfo.IPAddresses {
+		if err := ipamInvoker.Delete(&epInfo.IPAddresses[i], nwCfg, epArgs, nwInfo.Options); err != nil {
+			return errors.Wrapf(err, "failed to release address %v", epInfo.IPAddresses[i].String())
+		}
+	}
+
+	return nil
+}
+
+// getIfNameFromEndpointID returns the container interface name in the endpoint ID, which is the truncated
+// container ID joined to the container interface name.
+func getIfNameFromEndpointID(endpointID string) string {
+	if i := strings.Index(endpointID, "-"); i >= 0 {
+		return endpointID[i+1:]
+	}
+	return endpointID
+}
@@ -0,0 +1,138 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	acnnetwork "github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/telemetry"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLifecycleTestPlugin(t *testing.T) (*NetPlugin, *acnnetwork.MockNetworkManager, *MockIpamInvoker) {
+	t.Helper()
+	plugin, err := cni.NewPlugin("name", "1.1.0")
+	require.NoError(t, err)
+
+	nm := acnnetwork.NewMockNetworkmanager()
+	invoker := NewMockIpamInvoker(false, false, false)
+	return &NetPlugin{
+		Plugin:      plugin,
+		nm:          nm,
+		ipamInvoker: invoker,
+		report:      &telemetry.CNIReport{},
+		tb:          &telemetry.TelemetryBuffer{},
+	}, nm, invoker
+}
+
+func TestPluginCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		add        bool
+		prevIP     string
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:   "CNI Check happy path",
+			add:    true,
+			prevIP: "10.240.0.5",
+		},
+		{
+			name:       "CNI Check fail with address not assigned",
+			add:        true,
+			prevIP:     "10.240.0.99",
+			wantErr:    true,
+			wantErrMsg: "is not assigned to endpoint",
+		},
+		{
+			name:       "CNI Check fail with network not found",
+			prevIP:     "10.240.0.5",
+			wantErr:    true,
+			wantErrMsg: "Network not found",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			plugin, _, _ := newLifecycleTestPlugin(t)
+			if tt.add {
+				require.NoError(t, plugin.Add(args))
+			}
+
+			checkCfg := nwCfg
+			checkCfg.CNIVersion = "1.0.0"
+			checkCfg.PrevResult = &cniTypesCurr.Result{
+				IPs: []*cniTypesCurr.IPConfig{
+					{Address: net.IPNet{IP: net.ParseIP(tt.prevIP), Mask: net.CIDRMask(subnetBits, ipv4Bits)}},
+				},
+			}
+			checkArgs := *args
+			checkArgs.StdinData = checkCfg.Serialize()
+
+			err := plugin.Check(&checkArgs)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPluginGC(t *testing.T) {
+	tests := []struct {
+		name             string
+		validAttachments []cni.Attachment
+		wantEndpoints    int
+	}{
+		{
+			name: "CNI GC keeps valid attachments",
+			validAttachments: []cni.Attachment{
+				{ContainerID: args.ContainerID, IfName: args.IfName},
+			},
+			wantEndpoints: 1,
+		},
+		{
+			name: "CNI GC collects stale endpoints",
+			validAttachments: []cni.Attachment{
+				{ContainerID: "other-container", IfName: args.IfName},
+			},
+			wantEndpoints: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			plugin, nm, invoker := newLifecycleTestPlugin(t)
+			require.NoError(t, plugin.Add(args))
+			require.Len(t, invoker.ipMap, 1)
+
+			gcCfg := nwCfg
+			gcCfg.CNIVersion = "1.1.0"
+			gcCfg.ValidAttachments = tt.validAttachments
+
+			err := plugin.GC(&cniSkel.CmdArgs{StdinData: gcCfg.Serialize()})
+			require.NoError(t, err)
+
+			assert.Len(t, nm.TestEndpointInfoMap, tt.wantEndpoints)
+			// the addresses of collected endpoints are released.
+			assert.Len(t, invoker.ipMap, tt.wantEndpoints)
+		})
+	}
+}
+
+func TestPluginGCWithoutNetwork(t *testing.T) {
+	plugin, _, _ := newLifecycleTestPlugin(t)
+
+	gcCfg := nwCfg
+	gcCfg.CNIVersion = "1.1.0"
+	require.NoError(t, plugin.GC(&cniSkel.CmdArgs{StdinData: gcCfg.Serialize()}))
+}
@@ -36,6 +36,8 @@ import (
 const (
 	dockerNetworkOption = "com.docker.network.generic"
 	opModeTransparent   = "transparent"
+	opModeBridge        = "bridge"
+	opModeIPVlan        = "ipvlan"
 	// Supported IP version. Currently support only IPv4
 	ipVersion             = "4"
 	ipamV6                = "azure-vnet-ipamv6"
@@ -74,6 +76,8 @@ type NetPlugin struct {
 	nnsClient          NnsClient
 	hnsEndpointClient  network.AzureHNSEndpointClient
 	multitenancyClient MultitenancyClient
+	// secondaryIpamInvoker overrides the IPAM invoker of the networks of secondary interfaces.
+	secondaryIpamInvoker IPAMInvoker
 }
 
 type PolicyArgs struct {
@@ -314,12 +318,15 @@ func addNatIPV6SubnetInfo(nwCfg *cni.NetworkConfig,
 // Add handles CNI add commands.
 func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 	var (
-		ipamAddResult    IPAMAddResult
-		azIpamResult     *cniTypesCurr.Result
-		enableInfraVnet  bool
-		enableSnatForDNS bool
-		k8sPodName       string
-		cniMetric        telemetry.AIMetric
+		ipamAddResult       IPAMAddResult
+		azIpamResult        *cniTypesCurr.Result
+		replayedResult      *cniTypesCurr.Result
+		secondaryInterfaces []secondaryInterface
+		mtu                 int
+		enableInfraVnet     bool
+		enableSnatForDNS    bool
+		k8sPodName          string
+		cniMetric           telemetry.AIMetric
 	)
 
 	startTime := time.Now()
@@ -350,33 +357,28 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		telemetry.SendCNIMetric(&cniMetric, plugin.tb)
 
 		// Add Interfaces to result.
-		if ipamAddResult.ipv4Result == nil {
-			ipamAddResult.ipv4Result = &cniTypesCurr.Result{}
-		}
-
-		iface := &cniTypesCurr.Interface{
-			Name: args.IfName,
-		}
-		ipamAddResult.ipv4Result.Interfaces = append(ipamAddResult.ipv4Result.Interfaces, iface)
-
-		if ipamAddResult.ipv6Result != nil {
-			ipamAddResult.ipv4Result.IPs = append(ipamAddResult.ipv4Result.IPs, ipamAddResult.ipv6Result.IPs...)
+		result := replayedResult
+		if result == nil {
+			result = getAddResult(args.IfName, nwCfg, ipamAddResult.ipv4Result, ipamAddResult.ipv6Result, secondaryInterfaces)
 		}
 
-		addSnatInterface(nwCfg, ipamAddResult.ipv4Result)
 		// Convert result to the requested CNI version.
-		res, vererr := ipamAddResult.ipv4Result.GetAsVersion(nwCfg.CNIVersion)
+		res, vererr := cni.GetResultAsVersion(result, nwCfg.CNIVersion)
 		if vererr != nil {
 			log.Printf("GetAsVersion failed with error %v", vererr)
 			plugin.Error(vererr)
 		}
 
+		if res != nil {
+			setResultMTUs(res, args.IfName, mtu, secondaryInterfaces)
+		}
+
 		if err == nil && res != nil {
 			// Output the result to stdout.
 			res.Print()
 		}
 
-		log.Printf("[cni-net] ADD command completed for pod %v with result:%+v err:%v.", k8sPodName, ipamAddResult.ipv4Result, err)
+		log.Printf("[cni-net] ADD command completed for pod %v with result:%+v err:%v.", k8sPodName, result, err)
 	}()
 
 	// Parse Pod arguments.
@@ -414,6 +416,29 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		return err
 	}
 
+	bandwidth, err := getBandwidthInfo(nwCfg)
+	if err != nil {
+		err = plugin.Errorf("Invalid bandwidth configuration: %v", err)
+		return err
+	}
+
+	hostPortMappings, err := getHostPortMappings(nwCfg)
+	if err != nil {
+		err = plugin.Errorf("Invalid port mappings: %v", err)
+		return err
+	}
+
+	if mtu, err = getEndpointMTU(nwCfg); err != nil {
+		err = plugin.Errorf("Invalid mtu: %v", err)
+		return err
+	}
+
+	secondaryIfaces, err := getSecondaryInterfaces(nwCfg, args)
+	if err != nil {
+		err = plugin.Errorf("Invalid secondary interfaces: %v", err)
+		return err
+	}
+
 	for _, ns := range nwCfg.PodNamespaceForDualNetwork {
 		if k8sNamespace == ns {
 			log.Printf("Enable infravnet for this pod %v in namespace %v", k8sPodName, k8sNamespace)
@@ -497,6 +522,29 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 	}
 
+	// A repeated ADD of the container interface returns the result of the ADD which created its endpoint.
+	if nwInfoErr == nil {
+		var replayedEpInfo *network.EndpointInfo
+		if replayedResult, replayedEpInfo, err = plugin.replayAdd(args, nwCfg, &nwInfo, endpointID); err != nil {
+			err = plugin.Errorf("Failed to replay ADD: %v", err)
+			return err
+		}
+
+		if replayedEpInfo != nil {
+			mtu = replayedEpInfo.MTU
+			secondaryInterfaces = plugin.getReplayedSecondaryInterfaces(replayedEpInfo)
+			return nil
+		}
+	}
+
+	// The mtu is validated against the master interface before the IPAM if the network exists.
+	if nwInfoErr == nil {
+		if err = validateMasterMTU(mtu, nwInfo.MasterIfName); err != nil {
+			err = plugin.Errorf("Invalid mtu: %v", err)
+			return err
+		}
+	}
+
 	ipamAddConfig := IPAMAddConfig{nwCfg: nwCfg, args: args, options: options}
 	// No need to call Add if we already got IPAMAddResult in multitenancy section via GetContainerNetworkConfiguration
 	if !nwCfg.MultiTenancy {
@@ -525,8 +573,27 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		}
 
 		telemetry.LogAndSendEvent(plugin.tb, fmt.Sprintf("[cni-net] Created network %v with subnet %v.", networkID, ipamAddResult.hostSubnetPrefix.String()))
+
+		if err = validateMasterMTU(mtu, nwInfo.MasterIfName); err != nil {
+			err = plugin.Errorf("Invalid mtu: %v", err)
+			return err
+		}
+	}
+
+	// The secondary interfaces are created first so that the endpoint of the primary interface links them.
+	if secondaryInterfaces, err = plugin.addSecondaryInterfaces(nwCfg, args, secondaryIfaces, k8sPodName, k8sNamespace); err != nil {
+		err = plugin.Errorf("Failed to add secondary interfaces: %v", err)
+		return err
 	}
 
+	defer func() {
+		if err != nil {
+			if er := plugin.deleteLinkedEndpoints(getLinkedEndpoints(secondaryInterfaces), nwCfg, args); er != nil {
+				log.Errorf("Failed to cleanup secondary interfaces on failure: %v", er)
+			}
+		}
+	}()
+
 	natInfo := getNATInfo(nwCfg.ExecutionMode, options[network.SNATIPKey], nwCfg.MultiTenancy, enableSnatForDNS)
 
 	createEndpointInternalOpt := createEndpointInternalOpt{
@@ -544,6 +611,10 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 		enableInfraVnet:  enableInfraVnet,
 		enableSnatForDNS: enableSnatForDNS,
 		natInfo:          natInfo,
+		bandwidth:        bandwidth,
+		hostPortMappings: hostPortMappings,
+		secondaryIfaces:  secondaryInterfaces,
+		mtu:              mtu,
 	}
 	epInfo, err := plugin.createEndpointInternal(&createEndpointInternalOpt)
 	if err != nil {
@@ -557,6 +628,49 @@ func (plugin *NetPlugin) Add(args *cniSkel.CmdArgs) error {
 	return nil
 }
 
+// setResultMTUs sets the MTUs of the container interface and the secondary interfaces which have one in the result.
+// The MTUs are set on the interfaces either way, a result of a CNI version before 1.1.0 just can't report them.
+func setResultMTUs(res cniTypes.Result, ifName string, mtu int, secondaryInterfaces []secondaryInterface) {
+	mtus := map[string]int{ifName: mtu}
+	for i := range secondaryInterfaces {
+		mtus[secondaryInterfaces[i].ifName] = secondaryInterfaces[i].mtu
+	}
+	for name, mtu := range mtus {
+		if mtu == 0 {
+			continue
+		}
+		if err := cni.SetResultInterfaceMTU(res, name, mtu); err != nil {
+			log.Printf("[cni-net] MTU %d of interface %s is not in the result: %v", mtu, name, err)
+		}
+	}
+}
+
+// getAddResult returns the result of an ADD: the IPAM result of the container interface with the interface, its
+// IPv6 addresses and the secondary interfaces added. The IPAM results are not changed.
+func getAddResult(
+	ifName string,
+	nwCfg *cni.NetworkConfig,
+	result, resultV6 *cniTypesCurr.Result,
+	secondaryInterfaces []secondaryInterface,
+) *cniTypesCurr.Result {
+	addResult := &cniTypesCurr.Result{}
+	if result != nil {
+		*addResult = *result
+		addResult.Interfaces = append([]*cniTypesCurr.Interface(nil), result.Interfaces...)
+		addResult.IPs = append([]*cniTypesCurr.IPConfig(nil), result.IPs...)
+		addResult.Routes = append([]*cniTypes.Route(nil), result.Routes...)
+	}
+
+	addResult.Interfaces = append(addResult.Interfaces, &cniTypesCurr.Interface{Name: ifName})
+	if resultV6 != nil {
+		addResult.IPs = append(addResult.IPs, resultV6.IPs...)
+	}
+
+	addSecondaryInterfacesToResult(addResult, secondaryInterfaces)
+	addSnatInterface(nwCfg, addResult)
+	return addResult
+}
+
 func (plugin *NetPlugin) cleanupAllocationOnError(
 	result, resultV6 *cniTypesCurr.Result,
 	nwCfg *cni.NetworkConfig,
@@ -634,6 +748,7 @@ func (plugin *NetPlugin) createNetworkInternal(
 		Options:                       ipamAddConfig.options,
 		DisableHairpinOnHostInterface: ipamAddConfig.nwCfg.DisableHairpinOnHostInterface,
 		IPV6Mode:                      ipamAddConfig.nwCfg.IPV6Mode,
+		IPVlanMode:                    ipamAddConfig.nwCfg.IPVlanMode,
 		IPAMType:                      ipamAddConfig.nwCfg.Ipam.Type,
 		ServiceCidrs:                  ipamAddConfig.nwCfg.ServiceCidrs,
 	}
@@ -665,6 +780,10 @@ type createEndpointInternalOpt struct {
 	enableInfraVnet  bool
 	enableSnatForDNS bool
 	natInfo          []policy.NATInfo
+	bandwidth        *network.BandwidthInfo
+	hostPortMappings []network.PortMappingInfo
+	secondaryIfaces  []secondaryInterface
+	mtu              int
 }
 
 func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt) (network.EndpointInfo, error) {
@@ -716,6 +835,10 @@ func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt)
 		VnetCidrs:          opt.nwCfg.VnetCidrs,
 		ServiceCidrs:       opt.nwCfg.ServiceCidrs,
 		NATInfo:            opt.natInfo,
+		Bandwidth:          opt.bandwidth,
+		HostPortMappings:   opt.hostPortMappings,
+		LinkedEndpoints:    getLinkedEndpoints(opt.secondaryIfaces),
+		MTU:                opt.mtu,
 	}
 
 	epPolicies := getPoliciesFromRuntimeCfg(opt.nwCfg)
@@ -753,6 +876,10 @@ func (plugin *NetPlugin) createEndpointInternal(opt *createEndpointInternalOpt)
 		return epInfo, plugin.Errorf(err.Error())
 	}
 
+	// The result is cached in the endpoint for a repeated ADD.
+	epInfo.Result = getAddResult(opt.args.IfName, opt.nwCfg, opt.result, opt.resultV6, opt.secondaryIfaces)
+	epInfo.ConfigHash = getAddConfigHash(opt.args)
+
 	// Create the endpoint.
 	telemetry.SendCNIEvent(plugin.tb, fmt.Sprintf("[cni-net] Creating endpoint %+v.", epInfo))
 	log.Printf("[cni-net] Creating endpoint %v.", epInfo.Id)
@@ -786,7 +913,7 @@ func (plugin *NetPlugin) Get(args *cniSkel.CmdArgs) error {
 		result.Interfaces = append(result.Interfaces, iface)
 
 		// Convert result to the requested CNI version.
-		res, vererr := result.GetAsVersion(nwCfg.CNIVersion)
+		res, vererr := cni.GetResultAsVersion(&result, nwCfg.CNIVersion)
 		if vererr != nil {
 			log.Printf("GetAsVersion failed with error %v", vererr)
 			plugin.Error(vererr)
@@ -976,6 +1103,11 @@ func (plugin *NetPlugin) Delete(args *cniSkel.CmdArgs) error {
 
 	// schedule send metric before attempting delete
 	defer sendMetricFunc()
+	// The secondary interfaces are deleted first, so that a retried DEL finds them linked to the endpoint.
+	if err = plugin.deleteLinkedEndpoints(epInfo.LinkedEndpoints, nwCfg, args); err != nil {
+		return plugin.RetriableError(fmt.Errorf("failed to delete secondary interfaces: %w", err))
+	}
+
 	telemetry.LogAndSendEvent(plugin.tb, fmt.Sprintf("Deleting endpoint:%v", endpointID))
 	// Delete the endpoint.
 	if err = plugin.nm.DeleteEndpoint(networkID, endpointID); err != nil {
@@ -1055,7 +1187,7 @@ func (plugin *NetPlugin) Update(args *cniSkel.CmdArgs) error {
 		}
 
 		// Convert result to the requested CNI version.
-		res, vererr := result.GetAsVersion(nwCfg.CNIVersion)
+		res, vererr := cni.GetResultAsVersion(result, nwCfg.CNIVersion)
 		if vererr != nil {
 			log.Printf("GetAsVersion failed with error %v", vererr)
 			plugin.Error(vererr)
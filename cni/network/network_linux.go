@@ -1,17 +1,22 @@
 package network
 
 import (
+	"math"
 	"net"
 	"strconv"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/iptables"
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/network"
 	"github.com/Azure/azure-container-networking/network/policy"
 	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
 	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
@@ -21,6 +26,21 @@ const (
 
 const snatConfigFileName = "/tmp/snatConfig"
 
+const bitsPerByte = 8
+
+const maxPort = 65535
+
+// minMTU is the minimum IPv6 MTU, so that the MTU of a Pod also works for dual-stack Pods.
+const minMTU = 1280
+
+var (
+	errBandwidthBurstNotSet   = errors.New("burst must be set when the rate is set")
+	errBandwidthBurstTooLarge = errors.New("burst must be less than 4GB")
+	errBandwidthNotSupported  = errors.New("bandwidth shaping is not supported in ipvlan mode")
+	errInvalidPortMapping     = errors.New("invalid port mapping")
+	errInvalidMTU             = errors.New("invalid mtu")
+)
+
 // handleConsecutiveAdd is a dummy function for Linux platform.
 func (plugin *NetPlugin) handleConsecutiveAdd(args *cniSkel.CmdArgs, endpointID string, networkID string,
 	nwInfo *network.NetworkInfo, nwCfg *cni.NetworkConfig) (*cniTypesCurr.Result, error) {
@@ -138,3 +158,176 @@ func (plugin *NetPlugin) getNetworkName(_ string, _ *IPAMAddResult, nwCfg *cni.N
 func getNATInfo(_ string, _ interface{}, _, _ bool) (natInfo []policy.NATInfo) {
 	return natInfo
 }
+
+// getBandwidthInfo returns the bandwidth limits of the endpoint from the bandwidth capability of the runtime config,
+// or else from the bandwidth annotations of the Pod.
+func getBandwidthInfo(nwCfg *cni.NetworkConfig) (*network.BandwidthInfo, error) {
+	bw := nwCfg.RuntimeConfig.Bandwidth
+	if bw == nil {
+		var err error
+		if bw, err = getBandwidthFromAnnotations(nwCfg.RuntimeConfig.PodAnnotations); err != nil {
+			return nil, err
+		}
+	}
+
+	if bw == nil || (bw.IngressRate == 0 && bw.EgressRate == 0) {
+		return nil, nil
+	}
+
+	// ipvlan endpoints have no host veth to shape the traffic on.
+	if nwCfg.Mode == opModeIPVlan {
+		return nil, errBandwidthNotSupported
+	}
+
+	if err := validateRateAndBurst(bw.IngressRate, bw.IngressBurst); err != nil {
+		return nil, errors.Wrap(err, "invalid ingress bandwidth")
+	}
+
+	if err := validateRateAndBurst(bw.EgressRate, bw.EgressBurst); err != nil {
+		return nil, errors.Wrap(err, "invalid egress bandwidth")
+	}
+
+	return &network.BandwidthInfo{
+		IngressRate:  bw.IngressRate,
+		IngressBurst: bw.IngressBurst,
+		EgressRate:   bw.EgressRate,
+		EgressBurst:  bw.EgressBurst,
+	}, nil
+}
+
+// getBandwidthFromAnnotations returns the bandwidth limits of the kubernetes.io/ingress-bandwidth and
+// kubernetes.io/egress-bandwidth Pod annotations, which are quantities in bits per second. Like the kubelet,
+// it doesn't limit the burst.
+func getBandwidthFromAnnotations(annotations map[string]string) (*cni.BandwidthConfig, error) {
+	ingressRate, err := getBandwidthAnnotation(annotations, cni.PodIngressBandwidthAnnotation)
+	if err != nil {
+		return nil, err
+	}
+
+	egressRate, err := getBandwidthAnnotation(annotations, cni.PodEgressBandwidthAnnotation)
+	if err != nil {
+		return nil, err
+	}
+
+	if ingressRate == 0 && egressRate == 0 {
+		return nil, nil
+	}
+
+	bw := &cni.BandwidthConfig{IngressRate: ingressRate, EgressRate: egressRate}
+	if ingressRate != 0 {
+		bw.IngressBurst = math.MaxInt32
+	}
+
+	if egressRate != 0 {
+		bw.EgressBurst = math.MaxInt32
+	}
+
+	return bw, nil
+}
+
+func getBandwidthAnnotation(annotations map[string]string, name string) (uint64, error) {
+	value, ok := annotations[name]
+	if !ok {
+		return 0, nil
+	}
+
+	quantity, err := resource.ParseQuantity(strings.TrimSpace(value))
+	if err != nil {
+		return 0, errors.Wrapf(err, "annotation %s=%s is not a quantity", name, value)
+	}
+
+	if quantity.Sign() <= 0 {
+		return 0, errors.Errorf("annotation %s=%s must be positive", name, value)
+	}
+
+	return uint64(quantity.Value()), nil
+}
+
+func validateRateAndBurst(rate, burst uint64) error {
+	switch {
+	case rate != 0 && burst == 0:
+		return errBandwidthBurstNotSet
+	case burst/bitsPerByte >= math.MaxUint32:
+		return errBandwidthBurstTooLarge
+	}
+
+	return nil
+}
+
+// getHostPortMappings returns the host ports of the endpoint from the portMappings capability of the runtime config.
+func getHostPortMappings(nwCfg *cni.NetworkConfig) ([]network.PortMappingInfo, error) {
+	var mappings []network.PortMappingInfo
+
+	for _, mapping := range nwCfg.RuntimeConfig.PortMappings {
+		if mapping.HostPort <= 0 || mapping.HostPort > maxPort || mapping.ContainerPort <= 0 || mapping.ContainerPort > maxPort {
+			return nil, errors.Wrapf(errInvalidPortMapping, "ports %d:%d out of range", mapping.HostPort, mapping.ContainerPort)
+		}
+
+		protocol := strings.ToLower(strings.TrimSpace(mapping.Protocol))
+		switch protocol {
+		case "":
+			protocol = iptables.TCP
+		case iptables.TCP, iptables.UDP, iptables.SCTP:
+		default:
+			return nil, errors.Wrapf(errInvalidPortMapping, "protocol %s not supported", mapping.Protocol)
+		}
+
+		if mapping.HostIp != "" && net.ParseIP(mapping.HostIp) == nil {
+			return nil, errors.Wrapf(errInvalidPortMapping, "host IP %s is not an IP address", mapping.HostIp)
+		}
+
+		mappings = append(mappings, network.PortMappingInfo{
+			HostPort:      mapping.HostPort,
+			ContainerPort: mapping.ContainerPort,
+			Protocol:      protocol,
+			HostIP:        mapping.HostIp,
+		})
+	}
+
+	return mappings, nil
+}
+
+// getEndpointMTU returns the MTU of the container interface, from the Pod MTU annotation or else the mtu of the network
+// config. Zero keeps the default MTU. It is called before the IPAM, so only the minimum is checked: the master interface
+// may not be known until the network is created, so the MTU is validated against it by validateMasterMTU.
+func getEndpointMTU(nwCfg *cni.NetworkConfig) (int, error) {
+	mtu := nwCfg.MTU
+	if value, ok := nwCfg.RuntimeConfig.PodAnnotations[cni.PodMTUAnnotation]; ok {
+		annotationMTU, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0, errors.Wrapf(errInvalidMTU, "annotation %s=%s is not a number", cni.PodMTUAnnotation, value)
+		}
+		mtu = annotationMTU
+	}
+
+	if mtu != 0 && mtu < minMTU {
+		return 0, errors.Wrapf(errInvalidMTU, "%d is less than the minimum %d", mtu, minMTU)
+	}
+
+	return mtu, nil
+}
+
+// validateMasterMTU validates the MTU of the container interface against the MTU of the master interface.
+func validateMasterMTU(mtu int, masterIfName string) error {
+	if mtu == 0 {
+		return nil
+	}
+
+	masterIf, err := net.InterfaceByName(masterIfName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get master interface %s", masterIfName)
+	}
+
+	return validateMTU(mtu, masterIf.MTU)
+}
+
+func validateMTU(mtu, masterMTU int) error {
+	switch {
+	case mtu < minMTU:
+		return errors.Wrapf(errInvalidMTU, "%d is less than the minimum %d", mtu, minMTU)
+	case mtu > masterMTU:
+		return errors.Wrapf(errInvalidMTU, "%d is larger than the mtu %d of the master interface", mtu, masterMTU)
+	}
+
+	return nil
+}
@@ -4,13 +4,18 @@
 package network
 
 import (
+	"fmt"
+	"math"
 	"testing"
 
+	"github.com/Azure/azure-container-networking/cni"
 	"github.com/Azure/azure-container-networking/cns"
 	"github.com/Azure/azure-container-networking/network"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types/current"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func TestSetNetworkOptions(t *testing.T) {
@@ -161,3 +166,228 @@ func TestAddSnatForDns(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBandwidthInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		bandwidth   *cni.BandwidthConfig
+		annotations map[string]string
+		want        *network.BandwidthInfo
+		wantErr     error
+	}{
+		{
+			name: "no bandwidth capability",
+		},
+		{
+			name:      "no rates",
+			bandwidth: &cni.BandwidthConfig{},
+		},
+		{
+			name:      "ingress and egress rates",
+			bandwidth: &cni.BandwidthConfig{IngressRate: 1000000, IngressBurst: 200000, EgressRate: 2000000, EgressBurst: 400000},
+			want:      &network.BandwidthInfo{IngressRate: 1000000, IngressBurst: 200000, EgressRate: 2000000, EgressBurst: 400000},
+		},
+		{
+			name:      "rate without burst",
+			bandwidth: &cni.BandwidthConfig{EgressRate: 2000000},
+			wantErr:   errBandwidthBurstNotSet,
+		},
+		{
+			name:      "burst too large",
+			bandwidth: &cni.BandwidthConfig{IngressRate: 1000000, IngressBurst: math.MaxUint64},
+			wantErr:   errBandwidthBurstTooLarge,
+		},
+		{
+			name:        "bandwidth annotations",
+			annotations: map[string]string{cni.PodIngressBandwidthAnnotation: "10M", cni.PodEgressBandwidthAnnotation: "1Gi"},
+			want:        &network.BandwidthInfo{IngressRate: 10000000, IngressBurst: math.MaxInt32, EgressRate: 1 << 30, EgressBurst: math.MaxInt32},
+		},
+		{
+			name:        "ingress bandwidth annotation",
+			annotations: map[string]string{cni.PodIngressBandwidthAnnotation: "500k"},
+			want:        &network.BandwidthInfo{IngressRate: 500000, IngressBurst: math.MaxInt32},
+		},
+		{
+			name:        "bandwidth capability overrides annotations",
+			bandwidth:   &cni.BandwidthConfig{IngressRate: 1000000, IngressBurst: 200000},
+			annotations: map[string]string{cni.PodIngressBandwidthAnnotation: "10M"},
+			want:        &network.BandwidthInfo{IngressRate: 1000000, IngressBurst: 200000},
+		},
+		{
+			name:        "invalid bandwidth annotation",
+			annotations: map[string]string{cni.PodEgressBandwidthAnnotation: "fast"},
+			wantErr:     resource.ErrFormatWrong,
+		},
+		{
+			name:      "ipvlan mode",
+			mode:      opModeIPVlan,
+			bandwidth: &cni.BandwidthConfig{IngressRate: 1000000, IngressBurst: 200000},
+			wantErr:   errBandwidthNotSupported,
+		},
+		{
+			name: "ipvlan mode without bandwidth",
+			mode: opModeIPVlan,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			nwCfg := &cni.NetworkConfig{
+				Mode:          tt.mode,
+				RuntimeConfig: cni.RuntimeConfig{Bandwidth: tt.bandwidth, PodAnnotations: tt.annotations},
+			}
+			got, err := getBandwidthInfo(nwCfg)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetHostPortMappings(t *testing.T) {
+	tests := []struct {
+		name         string
+		portMappings []cni.PortMapping
+		want         []network.PortMappingInfo
+		wantErr      bool
+	}{
+		{
+			name: "no port mappings",
+		},
+		{
+			name: "port mappings",
+			portMappings: []cni.PortMapping{
+				{HostPort: 8080, ContainerPort: 80, Protocol: "TCP"},
+				{HostPort: 5353, ContainerPort: 53, Protocol: "udp", HostIp: "10.240.0.4"},
+				{HostPort: 9090, ContainerPort: 90},
+			},
+			want: []network.PortMappingInfo{
+				{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+				{HostPort: 5353, ContainerPort: 53, Protocol: "udp", HostIP: "10.240.0.4"},
+				{HostPort: 9090, ContainerPort: 90, Protocol: "tcp"},
+			},
+		},
+		{
+			name:         "host port out of range",
+			portMappings: []cni.PortMapping{{HostPort: 70000, ContainerPort: 80, Protocol: "tcp"}},
+			wantErr:      true,
+		},
+		{
+			name:         "unsupported protocol",
+			portMappings: []cni.PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "icmp"}},
+			wantErr:      true,
+		},
+		{
+			name:         "invalid host IP",
+			portMappings: []cni.PortMapping{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp", HostIp: "host"}},
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			nwCfg := &cni.NetworkConfig{RuntimeConfig: cni.RuntimeConfig{PortMappings: tt.portMappings}}
+			got, err := getHostPortMappings(nwCfg)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errInvalidPortMapping)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetEndpointMTU(t *testing.T) {
+	tests := []struct {
+		name        string
+		mtu         int
+		annotations map[string]string
+		want        int
+		wantErr     error
+	}{
+		{
+			name: "no mtu",
+		},
+		{
+			name: "mtu of the network config",
+			mtu:  1500,
+			want: 1500,
+		},
+		{
+			name:        "annotation overrides the network config",
+			mtu:         1500,
+			annotations: map[string]string{cni.PodMTUAnnotation: "9000"},
+			want:        9000,
+		},
+		{
+			name:        "annotation is not a number",
+			annotations: map[string]string{cni.PodMTUAnnotation: "jumbo"},
+			wantErr:     errInvalidMTU,
+		},
+		{
+			name:    "mtu below the minimum",
+			mtu:     576,
+			wantErr: errInvalidMTU,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			nwCfg := &cni.NetworkConfig{MTU: tt.mtu, RuntimeConfig: cni.RuntimeConfig{PodAnnotations: tt.annotations}}
+			got, err := getEndpointMTU(nwCfg)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPluginAddInvalidMTUFailsBeforeIPAM(t *testing.T) {
+	plugin := GetTestResources()
+	invoker := NewMockIpamInvoker(false, false, false)
+	plugin.ipamInvoker = invoker
+
+	mtuCfg := nwCfg
+	mtuCfg.MTU = minMTU - 1
+	args := &cniSkel.CmdArgs{
+		StdinData:   mtuCfg.Serialize(),
+		ContainerID: "test-container",
+		Netns:       "test-container",
+		Args:        fmt.Sprintf("K8S_POD_NAME=%v;K8S_POD_NAMESPACE=%v", "test-pod", "test-pod-ns"),
+		IfName:      eth0IfName,
+	}
+
+	err := plugin.Add(args)
+	require.Error(t, err)
+	assert.Empty(t, invoker.ipMap, "no ip should be allocated for an invalid mtu")
+}
+
+func TestValidateMasterMTU(t *testing.T) {
+	// The loopback interface has an MTU of 65536.
+	require.NoError(t, validateMasterMTU(0, "lo"))
+	require.NoError(t, validateMasterMTU(9000, "lo"))
+	require.ErrorIs(t, validateMasterMTU(65537, "lo"), errInvalidMTU)
+	require.NoError(t, validateMasterMTU(0, "nonexistent0"))
+	require.Error(t, validateMasterMTU(9000, "nonexistent0"))
+}
+
+func TestValidateMTU(t *testing.T) {
+	require.NoError(t, validateMTU(1500, 1500))
+	require.NoError(t, validateMTU(minMTU, 9000))
+	require.ErrorIs(t, validateMTU(minMTU-1, 1500), errInvalidMTU)
+	require.ErrorIs(t, validateMTU(9000, 1500), errInvalidMTU)
+}
@@ -233,6 +233,34 @@ func getEndpointDNSSettings(nwCfg *cni.NetworkConfig, result *cniTypesCurr.Resul
 	return epDNS, nil
 }
 
+// getHostPortMappings returns no host ports, since they are added to the endpoint as HNS policies by getPoliciesFromRuntimeCfg.
+func getHostPortMappings(*cni.NetworkConfig) ([]network.PortMappingInfo, error) {
+	return nil, nil
+}
+
+// getEndpointMTU rejects an MTU, since setting the MTU of the container interface is only supported on Linux.
+func getEndpointMTU(nwCfg *cni.NetworkConfig) (int, error) {
+	if _, ok := nwCfg.RuntimeConfig.PodAnnotations[cni.PodMTUAnnotation]; ok || nwCfg.MTU != 0 {
+		return 0, errors.New("setting the mtu is not supported on Windows")
+	}
+
+	return 0, nil
+}
+
+// validateMasterMTU does nothing, since getEndpointMTU rejects any mtu on Windows.
+func validateMasterMTU(_ int, _ string) error {
+	return nil
+}
+
+// getBandwidthInfo rejects the bandwidth capability, since bandwidth shaping is only supported on Linux.
+func getBandwidthInfo(nwCfg *cni.NetworkConfig) (*network.BandwidthInfo, error) {
+	if nwCfg.RuntimeConfig.Bandwidth != nil {
+		return nil, errors.New("bandwidth shaping is not supported on Windows")
+	}
+
+	return nil, nil
+}
+
 // getPoliciesFromRuntimeCfg returns network policies from network config.
 func getPoliciesFromRuntimeCfg(nwCfg *cni.NetworkConfig) []policy.Policy {
 	log.Printf("[net] RuntimeConfigs: %+v", nwCfg.RuntimeConfig)
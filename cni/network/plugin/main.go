@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"strconv"
 	"time"
 
 	"github.com/Azure/azure-container-networking/aitelemetry"
@@ -34,6 +35,7 @@ const (
 	telemetryNumRetries             = 5
 	telemetryWaitTimeInMilliseconds = 200
 	name                            = "azure-vnet"
+	telemetryLockName               = "telemetry"
 )
 
 // Version is populated by make during build.
@@ -65,6 +67,24 @@ func reportPluginError(reportManager *telemetry.ReportManager, tb *telemetry.Tel
 	}
 }
 
+// send the lock timeout metric if CNI timed out locking the store.
+func sendLockTimeoutMetric(tb *telemetry.TelemetryBuffer, err error) {
+	if !errors.Is(err, store.ErrTimeoutLockingStore) {
+		return
+	}
+
+	var cniMetric telemetry.AIMetric
+	cniMetric.Metric = aitelemetry.Metric{
+		Name:             telemetry.CNILockTimeoutStr,
+		Value:            1.0,
+		CustomDimensions: make(map[string]string),
+	}
+	sendErr := telemetry.SendCNIMetric(&cniMetric, tb)
+	if sendErr != nil {
+		log.Errorf("Couldn't send cnilocktimeout metric: %v", sendErr)
+	}
+}
+
 func validateConfig(jsonBytes []byte) error {
 	var conf struct {
 		Name string `json:"name"`
@@ -145,6 +165,15 @@ func rootExecute() error {
 	)
 
 	config.Version = version
+	// When enabled, the store is only locked around each change of the state, the operations on a container are
+	// ordered by its lock.
+	if value := os.Getenv(cni.LockStorePerChange); value != "" {
+		lockStorePerChange, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Printf("Invalid value %s of %s, the store is locked per operation.", value, cni.LockStorePerChange)
+		}
+		config.LockStorePerChange = lockStorePerChange
+	}
 	reportManager := &telemetry.ReportManager{
 		HostNetAgentURL: hostNetAgentURL,
 		ContentType:     telemetry.ContentType,
@@ -184,7 +213,6 @@ func rootExecute() error {
 			cniReport.VMUptime = upTime.Format("2006-01-02 15:04:05")
 		}
 
-		// CNI Acquires lock
 		if err = netPlugin.Plugin.InitializeKeyValueStore(&config); err != nil {
 			printCNIError(fmt.Sprintf("Failed to initialize key-value store of network plugin: %v", err))
 
@@ -195,19 +223,7 @@ func rootExecute() error {
 			}
 
 			reportPluginError(reportManager, tb, err)
-
-			if errors.Is(err, store.ErrTimeoutLockingStore) {
-				var cniMetric telemetry.AIMetric
-				cniMetric.Metric = aitelemetry.Metric{
-					Name:             telemetry.CNILockTimeoutStr,
-					Value:            1.0,
-					CustomDimensions: make(map[string]string),
-				}
-				sendErr := telemetry.SendCNIMetric(&cniMetric, tb)
-				if sendErr != nil {
-					log.Errorf("Couldn't send cnilocktimeout metric: %v", sendErr)
-				}
-			}
+			sendLockTimeoutMetric(tb, err)
 
 			tb.Close()
 			return errors.Wrap(err, "lock acquire error")
@@ -226,9 +242,41 @@ func rootExecute() error {
 		// Start telemetry process if not already started. This should be done inside lock, otherwise multiple process
 		// end up creating/killing telemetry process results in undesired state.
 		tb = telemetry.NewTelemetryBuffer()
+		lockErr := netPlugin.Plugin.AcquireNamedLock(telemetryLockName)
+		if lockErr != nil {
+			log.Errorf("Failed to acquire telemetry lock: %v", lockErr)
+		}
 		tb.ConnectToTelemetryService(telemetryNumRetries, telemetryWaitTimeInMilliseconds)
+		if lockErr == nil {
+			netPlugin.Plugin.ReleaseNamedLock(telemetryLockName)
+		}
 		defer tb.Close()
 
+		// The state is restored under the lock of the container, so that ADD, DEL, CHECK and UPDATE of a container
+		// see the changes of each other, while the operations on other containers run concurrently.
+		if containerID := os.Getenv("CNI_CONTAINERID"); containerID != "" {
+			if err = netPlugin.Plugin.AcquireNamedLock(containerID); err != nil {
+				printCNIError(fmt.Sprintf("Failed to acquire lock of container %s: %v", containerID, err))
+				reportPluginError(reportManager, tb, err)
+				return errors.Wrap(err, "lock acquire error")
+			}
+			defer netPlugin.Plugin.ReleaseNamedLock(containerID)
+		}
+
+		store.SetRecoveryHandler(func(fileName, snapshot string, err error) {
+			log.Errorf("Recovered corrupt store %s from snapshot %s: %v", fileName, snapshot, err)
+			cniMetric := telemetry.AIMetric{
+				Metric: aitelemetry.Metric{
+					Name:             telemetry.CNIStoreRecoveredStr,
+					Value:            1.0,
+					CustomDimensions: map[string]string{telemetry.StoreFileStr: fileName},
+				},
+			}
+			if sendErr := telemetry.SendCNIMetric(&cniMetric, tb); sendErr != nil {
+				log.Errorf("Couldn't send cnistorerecovered metric: %v", sendErr)
+			}
+		})
+
 		netPlugin.SetCNIReport(cniReport, tb)
 
 		t := time.Now()
@@ -237,6 +285,7 @@ func rootExecute() error {
 		if err = netPlugin.Start(&config); err != nil {
 			printCNIError(fmt.Sprintf("Failed to start network plugin, err:%v.\n", err))
 			reportPluginError(reportManager, tb, err)
+			sendLockTimeoutMetric(tb, err)
 			panic("network plugin start fatal error")
 		}
 
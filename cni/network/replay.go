@@ -0,0 +1,184 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/telemetry"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+	"github.com/pkg/errors"
+)
+
+// getAddConfigHash returns a hash of the inputs of an ADD, which tells a repeated ADD of a container interface,
+// such as a retry of the runtime after a timeout, from an ADD with another configuration.
+func getAddConfigHash(args *cniSkel.CmdArgs) string {
+	h := sha256.New()
+	for _, input := range [][]byte{[]byte(args.Netns), []byte(args.Args), bytes.TrimSpace(args.StdinData)} {
+		h.Write(input)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayAdd returns the result cached in the endpoint of the container interface when the ADD repeats the ADD
+// which created it, without calling the IPAM again. An endpoint created with another configuration is deleted
+// with its addresses and secondary interfaces, so that the ADD creates it again from scratch. A nil result is
+// returned when there is no endpoint to replay.
+func (plugin *NetPlugin) replayAdd(
+	args *cniSkel.CmdArgs,
+	nwCfg *cni.NetworkConfig,
+	nwInfo *network.NetworkInfo,
+	endpointID string,
+) (*cniTypesCurr.Result, *network.EndpointInfo, error) {
+	epInfo, err := plugin.nm.GetEndpointInfo(nwInfo.Id, endpointID)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	// An endpoint created before its configuration was hashed, such as by an earlier version of the plugin, is
+	// adopted with the configuration of this ADD if what it recorded of its ADD matches this ADD.
+	if epInfo.ConfigHash == "" && endpointMatchesAdd(args, nwCfg, epInfo) {
+		result, err := plugin.adoptEndpoint(args, nwCfg, nwInfo, epInfo)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to adopt endpoint %v", endpointID)
+		}
+		return result, epInfo, nil
+	}
+
+	if epInfo.Result != nil && epInfo.ConfigHash == getAddConfigHash(args) {
+		log.Printf("[cni-net] Endpoint %v already exists with the same configuration, returning its result.", endpointID)
+		return epInfo.Result, epInfo, nil
+	}
+
+	telemetry.LogAndSendEvent(plugin.tb, fmt.Sprintf("[cni-net] Endpoint %v exists with another configuration, deleting it.", endpointID))
+	if err := plugin.deleteStaleEndpoint(epInfo, nwCfg, nwInfo, args); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to delete endpoint %v", endpointID)
+	}
+
+	return nil, nil, nil
+}
+
+// endpointMatchesAdd returns whether an endpoint without a configuration hash was created by an ADD like this
+// one, as far as the endpoint records it: the interface and network namespace of the container, and the address
+// requested from the IPAM if the ADD requests one.
+func endpointMatchesAdd(args *cniSkel.CmdArgs, nwCfg *cni.NetworkConfig, epInfo *network.EndpointInfo) bool {
+	if epInfo.IfName != args.IfName || epInfo.NetNsPath != args.Netns {
+		return false
+	}
+
+	if nwCfg.Ipam.Address == "" {
+		return true
+	}
+
+	ip := net.ParseIP(nwCfg.Ipam.Address)
+	for _, ipAddress := range epInfo.IPAddresses {
+		if ipAddress.IP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// adoptEndpoint records the configuration hash of the ADD in an endpoint which has none, and the result of the
+// endpoint built from its addresses and routes if it has no cached result either, and returns the result.
+func (plugin *NetPlugin) adoptEndpoint(
+	args *cniSkel.CmdArgs,
+	nwCfg *cni.NetworkConfig,
+	nwInfo *network.NetworkInfo,
+	epInfo *network.EndpointInfo,
+) (*cniTypesCurr.Result, error) {
+	result := epInfo.Result
+	if result == nil {
+		result = getAddResult(args.IfName, nwCfg, getEndpointResult(epInfo), nil, plugin.getReplayedSecondaryInterfaces(epInfo))
+	}
+	configHash := getAddConfigHash(args)
+	if err := plugin.nm.SetEndpointResult(nwInfo.Id, epInfo.Id, result, configHash); err != nil {
+		return nil, errors.Wrap(err, "failed to record endpoint result")
+	}
+
+	log.Printf("[cni-net] Endpoint %v has no configuration hash, adopted it with the configuration of this ADD.", epInfo.Id)
+	epInfo.Result = result
+	epInfo.ConfigHash = configHash
+	return result, nil
+}
+
+// getEndpointResult returns the IPAM result of an endpoint, from its addresses, routes and DNS.
+func getEndpointResult(epInfo *network.EndpointInfo) *cniTypesCurr.Result {
+	result := &cniTypesCurr.Result{}
+	for _, ipAddress := range epInfo.IPAddresses {
+		ipConfig := &cniTypesCurr.IPConfig{Version: ipVersion, Address: ipAddress}
+		isIPv4 := ipAddress.IP.To4() != nil
+		if !isIPv4 {
+			ipConfig.Version = "6"
+		}
+		for _, gw := range epInfo.Gateways {
+			if (gw.To4() != nil) == isIPv4 {
+				ipConfig.Gateway = gw
+				break
+			}
+		}
+		result.IPs = append(result.IPs, ipConfig)
+	}
+
+	for _, route := range epInfo.Routes {
+		result.Routes = append(result.Routes, &cniTypes.Route{Dst: route.Dst, GW: route.Gw})
+	}
+
+	result.DNS.Nameservers = epInfo.DNS.Servers
+	result.DNS.Domain = epInfo.DNS.Suffix
+	return result
+}
+
+// deleteStaleEndpoint deletes an endpoint of the container interface with its secondary interfaces and releases
+// its addresses, like DEL.
+func (plugin *NetPlugin) deleteStaleEndpoint(
+	epInfo *network.EndpointInfo,
+	nwCfg *cni.NetworkConfig,
+	nwInfo *network.NetworkInfo,
+	args *cniSkel.CmdArgs,
+) error {
+	// The secondary interfaces are deleted first, so that the endpoint still links them if deleting one fails.
+	if err := plugin.deleteLinkedEndpoints(epInfo.LinkedEndpoints, nwCfg, args); err != nil {
+		return errors.Wrap(err, "failed to delete secondary interfaces")
+	}
+
+	if err := plugin.nm.DeleteEndpoint(nwInfo.Id, epInfo.Id); err != nil {
+		return errors.Wrap(err, "failed to delete endpoint")
+	}
+
+	// The addresses of multitenant endpoints are owned by the orchestrator.
+	if nwCfg.MultiTenancy {
+		return nil
+	}
+
+	for i := range epInfo.IPAddresses {
+		if err := plugin.ipamInvoker.Delete(&epInfo.IPAddresses[i], nwCfg, args, nwInfo.Options); err != nil {
+			return errors.Wrapf(err, "failed to release address %v", epInfo.IPAddresses[i].String())
+		}
+	}
+
+	return nil
+}
+
+// getReplayedSecondaryInterfaces returns the secondary interfaces linked by a replayed endpoint, with the MTU of
+// each, so that the replayed result reports the MTUs like the original one.
+func (plugin *NetPlugin) getReplayedSecondaryInterfaces(epInfo *network.EndpointInfo) []secondaryInterface {
+	secondaries := make([]secondaryInterface, 0, len(epInfo.LinkedEndpoints))
+	for _, linkedEp := range epInfo.LinkedEndpoints {
+		linkedEpInfo, err := plugin.nm.GetEndpointInfo(linkedEp.NetworkID, linkedEp.EndpointID)
+		if err != nil {
+			continue
+		}
+		secondaries = append(secondaries, secondaryInterface{ifName: getLinkedEndpointIfName(linkedEp), mtu: linkedEpInfo.MTU, linked: linkedEp})
+	}
+	return secondaries
+}
@@ -0,0 +1,266 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/network"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getReplayArgs(cfg *cni.NetworkConfig) *cniSkel.CmdArgs {
+	return &cniSkel.CmdArgs{
+		StdinData:   cfg.Serialize(),
+		ContainerID: "test-container",
+		Netns:       "test-container",
+		Args:        fmt.Sprintf("K8S_POD_NAME=%v;K8S_POD_NAMESPACE=%v", "test-pod", "test-pod-ns"),
+		IfName:      eth0IfName,
+	}
+}
+
+func TestPluginAddReplay(t *testing.T) {
+	plugin := GetTestResources()
+	ipamInvoker := NewMockIpamInvoker(false, false, false)
+	plugin.ipamInvoker = ipamInvoker
+	args := getReplayArgs(&nwCfg)
+
+	require.NoError(t, plugin.Add(args))
+	epInfo, err := plugin.nm.GetEndpointInfo(nwCfg.Name, GetEndpointID(args))
+	require.NoError(t, err)
+	require.NotNil(t, epInfo.Result)
+	assert.Equal(t, getAddConfigHash(args), epInfo.ConfigHash)
+	require.Len(t, epInfo.Result.Interfaces, 1)
+	assert.Equal(t, eth0IfName, epInfo.Result.Interfaces[0].Name)
+
+	// the retried ADD returns the cached result without allocating another address.
+	require.NoError(t, plugin.Add(args))
+	assert.Len(t, ipamInvoker.ipMap, 1)
+	endpoints, _ := plugin.nm.GetAllEndpoints(nwCfg.Name)
+	require.Len(t, endpoints, 1)
+	replayedEpInfo, err := plugin.nm.GetEndpointInfo(nwCfg.Name, GetEndpointID(args))
+	require.NoError(t, err)
+	assert.Same(t, epInfo, replayedEpInfo)
+}
+
+func TestPluginAddReplayConfigChanged(t *testing.T) {
+	plugin := GetTestResources()
+	ipamInvoker := NewMockIpamInvoker(false, false, false)
+	plugin.ipamInvoker = ipamInvoker
+	args := getReplayArgs(&nwCfg)
+
+	require.NoError(t, plugin.Add(args))
+	epInfo, err := plugin.nm.GetEndpointInfo(nwCfg.Name, GetEndpointID(args))
+	require.NoError(t, err)
+
+	// the endpoint created with another configuration is deleted with its address and created again.
+	changedNwCfg := nwCfg
+	changedNwCfg.IPsToRouteViaHost = []string{"169.254.20.11"}
+	changedArgs := getReplayArgs(&changedNwCfg)
+	require.NoError(t, plugin.Add(changedArgs))
+
+	assert.Len(t, ipamInvoker.ipMap, 1)
+	changedEpInfo, err := plugin.nm.GetEndpointInfo(nwCfg.Name, GetEndpointID(changedArgs))
+	require.NoError(t, err)
+	assert.NotSame(t, epInfo, changedEpInfo)
+	assert.Equal(t, getAddConfigHash(changedArgs), changedEpInfo.ConfigHash)
+	assert.Equal(t, []string{"169.254.20.11"}, changedEpInfo.IPsToRouteViaHost)
+}
+
+func TestPluginAddReplayDeleteFail(t *testing.T) {
+	plugin := GetTestResources()
+	args := getReplayArgs(&nwCfg)
+	require.NoError(t, plugin.Add(args))
+
+	// the address of the stale endpoint can't be released, so the ADD fails without creating the endpoint again.
+	plugin.ipamInvoker = NewMockIpamInvoker(false, false, true)
+	changedNwCfg := nwCfg
+	changedNwCfg.IPsToRouteViaHost = []string{"169.254.20.11"}
+	require.Error(t, plugin.Add(getReplayArgs(&changedNwCfg)))
+
+	endpoints, _ := plugin.nm.GetAllEndpoints(nwCfg.Name)
+	assert.Empty(t, endpoints)
+}
+
+func TestPluginAddFailReleasesAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		ipv6 bool
+	}{
+		{
+			name: "Endpoint failure releases IPv4 address",
+		},
+		{
+			name: "Endpoint failure releases dual-stack addresses",
+			ipv6: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := GetTestResources()
+			ipamInvoker := NewMockIpamInvoker(tt.ipv6, false, false)
+			plugin.ipamInvoker = ipamInvoker
+			// the MTU is invalid, so the ADD fails after the addresses are allocated.
+			testNwCfg := nwCfg
+			testNwCfg.MTU = 100
+
+			require.Error(t, plugin.Add(getReplayArgs(&testNwCfg)))
+			endpoints, _ := plugin.nm.GetAllEndpoints(nwCfg.Name)
+			assert.Empty(t, endpoints)
+			assert.Empty(t, ipamInvoker.ipMap)
+		})
+	}
+}
+
+func TestCleanupAllocationOnError(t *testing.T) {
+	v4 := &cniTypesCurr.Result{IPs: []*cniTypesCurr.IPConfig{{Address: net.IPNet{IP: net.ParseIP("10.240.0.5"), Mask: net.CIDRMask(24, 32)}}}}
+	v6 := &cniTypesCurr.Result{IPs: []*cniTypesCurr.IPConfig{{Address: net.IPNet{IP: net.ParseIP("fc00::2"), Mask: net.CIDRMask(64, 128)}}}}
+
+	tests := []struct {
+		name      string
+		result    *cniTypesCurr.Result
+		resultV6  *cniTypesCurr.Result
+		allocated []string
+		wantLeft  []string
+	}{
+		{
+			name:      "Release IPv4 address",
+			result:    v4,
+			allocated: []string{"10.240.0.5/24"},
+			wantLeft:  []string{},
+		},
+		{
+			name:      "Release dual-stack addresses",
+			result:    v4,
+			resultV6:  v6,
+			allocated: []string{"10.240.0.5/24", "fc00::2/64"},
+			wantLeft:  []string{},
+		},
+		{
+			name:      "Nothing allocated",
+			allocated: []string{"10.240.0.6/24"},
+			wantLeft:  []string{"10.240.0.6/24"},
+		},
+		{
+			name:      "Empty result",
+			result:    &cniTypesCurr.Result{},
+			allocated: []string{"10.240.0.6/24"},
+			wantLeft:  []string{"10.240.0.6/24"},
+		},
+		{
+			name:      "IPv4 release failure still releases IPv6 address",
+			result:    v4,
+			resultV6:  v6,
+			allocated: []string{"fc00::2/64"},
+			wantLeft:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := GetTestResources()
+			ipamInvoker := NewMockIpamInvoker(false, false, false)
+			for _, ip := range tt.allocated {
+				ipamInvoker.ipMap[ip] = true
+			}
+			plugin.ipamInvoker = ipamInvoker
+
+			plugin.cleanupAllocationOnError(tt.result, tt.resultV6, &nwCfg, getReplayArgs(&nwCfg), nil)
+
+			left := []string{}
+			for ip := range ipamInvoker.ipMap {
+				left = append(left, ip)
+			}
+			assert.ElementsMatch(t, tt.wantLeft, left)
+		})
+	}
+}
+
+func TestPluginAddReplayAdoptsEndpointWithoutConfigHash(t *testing.T) {
+	plugin := GetTestResources()
+	ipamInvoker := NewMockIpamInvoker(false, false, false)
+	plugin.ipamInvoker = ipamInvoker
+	args := getReplayArgs(&nwCfg)
+
+	require.NoError(t, plugin.Add(args))
+	epInfo, err := plugin.nm.GetEndpointInfo(nwCfg.Name, GetEndpointID(args))
+	require.NoError(t, err)
+
+	// the endpoint was created by an earlier version of the plugin, which neither hashed its configuration nor
+	// cached its result, so it is adopted and its result built from its addresses.
+	epInfo.ConfigHash = ""
+	epInfo.Result = nil
+	require.NoError(t, plugin.Add(args))
+
+	assert.Len(t, ipamInvoker.ipMap, 1)
+	adoptedEpInfo, err := plugin.nm.GetEndpointInfo(nwCfg.Name, GetEndpointID(args))
+	require.NoError(t, err)
+	assert.Same(t, epInfo, adoptedEpInfo)
+	assert.Equal(t, getAddConfigHash(args), adoptedEpInfo.ConfigHash)
+	require.NotNil(t, adoptedEpInfo.Result)
+	require.Len(t, adoptedEpInfo.Result.IPs, len(epInfo.IPAddresses))
+	assert.Equal(t, epInfo.IPAddresses[0], adoptedEpInfo.Result.IPs[0].Address)
+	require.Len(t, adoptedEpInfo.Result.Interfaces, 1)
+	assert.Equal(t, eth0IfName, adoptedEpInfo.Result.Interfaces[0].Name)
+}
+
+func TestPluginAddReplayRecreatesMismatchedEndpointWithoutConfigHash(t *testing.T) {
+	tests := []struct {
+		name         string
+		ipAddress    string
+		changeEpInfo func(*network.EndpointInfo)
+	}{
+		{
+			name:         "network namespace differs",
+			changeEpInfo: func(epInfo *network.EndpointInfo) { epInfo.NetNsPath = "old-container" },
+		},
+		{
+			name:         "interface name differs",
+			changeEpInfo: func(epInfo *network.EndpointInfo) { epInfo.IfName = "eth1" },
+		},
+		{
+			name:         "requested address differs",
+			ipAddress:    "10.240.0.99",
+			changeEpInfo: func(*network.EndpointInfo) {},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := GetTestResources()
+			ipamInvoker := NewMockIpamInvoker(false, false, false)
+			plugin.ipamInvoker = ipamInvoker
+			args := getReplayArgs(&nwCfg)
+
+			require.NoError(t, plugin.Add(args))
+			epInfo, err := plugin.nm.GetEndpointInfo(nwCfg.Name, GetEndpointID(args))
+			require.NoError(t, err)
+
+			// the endpoint has no configuration hash, but what it recorded of its ADD differs from this ADD, so it
+			// is deleted with its address and created again instead of being adopted.
+			epInfo.ConfigHash = ""
+			epInfo.Result = nil
+			tt.changeEpInfo(epInfo)
+			addNwCfg := nwCfg
+			addNwCfg.Ipam.Address = tt.ipAddress
+			addArgs := getReplayArgs(&addNwCfg)
+			require.NoError(t, plugin.Add(addArgs))
+
+			assert.Len(t, ipamInvoker.ipMap, 1)
+			recreatedEpInfo, err := plugin.nm.GetEndpointInfo(nwCfg.Name, GetEndpointID(addArgs))
+			require.NoError(t, err)
+			assert.NotSame(t, epInfo, recreatedEpInfo)
+			assert.Equal(t, getAddConfigHash(addArgs), recreatedEpInfo.ConfigHash)
+			assert.Equal(t, addArgs.Netns, recreatedEpInfo.NetNsPath)
+			assert.Equal(t, addArgs.IfName, recreatedEpInfo.IfName)
+		})
+	}
+}
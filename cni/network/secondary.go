@@ -0,0 +1,339 @@
+package network
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/cni"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network"
+	"github.com/Azure/azure-container-networking/telemetry"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+	"github.com/pkg/errors"
+)
+
+var (
+	errSecondaryInterfaceConflict = errors.New("secondary interface conflicts with another interface of the pod")
+	errSecondaryInterfaceIpam     = errors.New("secondary interfaces do not support cns ipam")
+	errSecondaryInterfaceMode     = errors.New("secondary interfaces only support bridge mode")
+	errSecondaryInterfaceTenancy  = errors.New("secondary interfaces are not supported with multitenancy")
+)
+
+// secondaryInterface is an interface of a Pod created in addition to the one requested by the runtime.
+type secondaryInterface struct {
+	ifName string
+	mtu    int
+	linked network.LinkedEndpointInfo
+	result *cniTypesCurr.Result
+}
+
+// getSecondaryInterfaces returns the secondary interfaces of the Pod after validating them against the primary interface.
+func getSecondaryInterfaces(nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs) ([]cni.SecondaryInterface, error) {
+	ifaces, err := cni.GetSecondaryInterfacesFromNwCfg(nwCfg.AdditionalArgs)
+	if err != nil || len(ifaces) == 0 {
+		return ifaces, err
+	}
+
+	if nwCfg.MultiTenancy {
+		return nil, errSecondaryInterfaceTenancy
+	}
+
+	ifNames := map[string]struct{}{args.IfName: {}}
+	networks := map[string]struct{}{nwCfg.Name: {}}
+	for i := range ifaces {
+		if _, ok := ifNames[ifaces[i].IfName]; ok {
+			return nil, errors.Wrapf(errSecondaryInterfaceConflict, "interface %s", ifaces[i].IfName)
+		}
+		if _, ok := networks[ifaces[i].Network]; ok {
+			return nil, errors.Wrapf(errSecondaryInterfaceConflict, "network %s", ifaces[i].Network)
+		}
+		if ifaces[i].Mode != "" && ifaces[i].Mode != opModeBridge {
+			return nil, errors.Wrapf(errSecondaryInterfaceMode, "interface %s mode %s", ifaces[i].IfName, ifaces[i].Mode)
+		}
+		if ifaces[i].Ipam.Type == network.AzureCNS {
+			return nil, errors.Wrapf(errSecondaryInterfaceIpam, "interface %s", ifaces[i].IfName)
+		}
+
+		ifNames[ifaces[i].IfName] = struct{}{}
+		networks[ifaces[i].Network] = struct{}{}
+	}
+
+	return ifaces, nil
+}
+
+// getSecondaryNetworkConfig returns the network config of the network of a secondary interface, derived from the
+// network config of the primary interface.
+func getSecondaryNetworkConfig(nwCfg *cni.NetworkConfig, iface *cni.SecondaryInterface) *cni.NetworkConfig {
+	secNwCfg := *nwCfg
+	secNwCfg.Name = iface.Network
+	secNwCfg.Mode = opModeBridge
+	secNwCfg.Master = iface.Master
+	secNwCfg.Bridge = iface.Bridge
+	secNwCfg.MTU = iface.MTU
+	secNwCfg.IPV6Mode = ""
+	secNwCfg.IPsToRouteViaHost = nil
+	secNwCfg.RuntimeConfig = cni.RuntimeConfig{}
+	secNwCfg.AdditionalArgs = nil
+	secNwCfg.PrevResult = nil
+	secNwCfg.Ipam.Type = iface.Ipam.Type
+	secNwCfg.Ipam.Environment = iface.Ipam.Environment
+	secNwCfg.Ipam.AddrSpace = iface.Ipam.AddrSpace
+	secNwCfg.Ipam.Subnet = iface.Ipam.Subnet
+	secNwCfg.Ipam.Address = ""
+	return &secNwCfg
+}
+
+// getSecondaryIpamInvoker returns the IPAM invoker of the network of a secondary interface.
+func (plugin *NetPlugin) getSecondaryIpamInvoker(nwInfo *network.NetworkInfo) IPAMInvoker {
+	if plugin.secondaryIpamInvoker != nil {
+		return plugin.secondaryIpamInvoker
+	}
+
+	return NewAzureIpamInvoker(plugin, nwInfo)
+}
+
+// addSecondaryInterfaces creates the endpoints of the secondary interfaces of the Pod. The secondary interfaces
+// created so far are deleted if one of them fails.
+func (plugin *NetPlugin) addSecondaryInterfaces(
+	nwCfg *cni.NetworkConfig,
+	args *cniSkel.CmdArgs,
+	ifaces []cni.SecondaryInterface,
+	k8sPodName, k8sNamespace string,
+) ([]secondaryInterface, error) {
+	var secondaries []secondaryInterface
+	for i := range ifaces {
+		secondary, err := plugin.addSecondaryInterface(nwCfg, args, &ifaces[i], k8sPodName, k8sNamespace)
+		if err != nil {
+			if er := plugin.deleteLinkedEndpoints(getLinkedEndpoints(secondaries), nwCfg, args); er != nil {
+				log.Errorf("Failed to cleanup secondary interfaces on failure: %v", er)
+			}
+			return nil, errors.Wrapf(err, "failed to add secondary interface %s", ifaces[i].IfName)
+		}
+
+		secondaries = append(secondaries, secondary)
+	}
+
+	return secondaries, nil
+}
+
+// addSecondaryInterface allocates the addresses of a secondary interface from the IPAM of its network, creating the
+// network if it does not exist yet, and creates its endpoint.
+func (plugin *NetPlugin) addSecondaryInterface(
+	nwCfg *cni.NetworkConfig,
+	args *cniSkel.CmdArgs,
+	iface *cni.SecondaryInterface,
+	k8sPodName, k8sNamespace string,
+) (secondaryInterface, error) {
+	secNwCfg := getSecondaryNetworkConfig(nwCfg, iface)
+	secArgs := *args
+	secArgs.IfName = iface.IfName
+	secArgs.StdinData = secNwCfg.Serialize()
+	networkID := secNwCfg.Name
+
+	mtu, err := getEndpointMTU(secNwCfg)
+	if err != nil {
+		return secondaryInterface{}, errors.Wrap(err, "invalid mtu")
+	}
+
+	options := make(map[string]interface{})
+	nwInfo, nwInfoErr := plugin.nm.GetNetworkInfo(networkID)
+	if nwInfoErr == nil {
+		options = nwInfo.Options
+		if err = validateMasterMTU(mtu, nwInfo.MasterIfName); err != nil {
+			return secondaryInterface{}, errors.Wrap(err, "invalid mtu")
+		}
+	}
+
+	ipamInvoker := plugin.getSecondaryIpamInvoker(&nwInfo)
+	ipamAddConfig := IPAMAddConfig{nwCfg: secNwCfg, args: &secArgs, options: options}
+	ipamAddResult, err := ipamInvoker.Add(ipamAddConfig)
+	if err != nil {
+		return secondaryInterface{}, errors.Wrap(err, "IPAM Invoker Add failed")
+	}
+
+	defer func() {
+		if err != nil {
+			for _, ipConfig := range ipamAddResult.ipv4Result.IPs {
+				if er := ipamInvoker.Delete(&ipConfig.Address, secNwCfg, &secArgs, options); er != nil {
+					log.Errorf("Failed to cleanup secondary ip allocation on failure: %v", er)
+				}
+			}
+		}
+	}()
+
+	if nwInfoErr != nil {
+		telemetry.LogAndSendEvent(plugin.tb, fmt.Sprintf("[cni-net] Creating secondary network %v.", networkID))
+		if nwInfo, err = plugin.createNetworkInternal(networkID, nil, ipamAddConfig, ipamAddResult); err != nil {
+			return secondaryInterface{}, err
+		}
+
+		if err = validateMasterMTU(mtu, nwInfo.MasterIfName); err != nil {
+			return secondaryInterface{}, errors.Wrap(err, "invalid mtu")
+		}
+	}
+
+	// The default route of the Pod is on its primary interface.
+	ipamAddResult.ipv4Result.Routes = removeDefaultRoutes(ipamAddResult.ipv4Result.Routes)
+
+	endpointID := GetEndpointID(&secArgs)
+	_, err = plugin.createEndpointInternal(&createEndpointInternalOpt{
+		nwCfg:        secNwCfg,
+		result:       ipamAddResult.ipv4Result,
+		args:         &secArgs,
+		nwInfo:       &nwInfo,
+		endpointID:   endpointID,
+		k8sPodName:   k8sPodName,
+		k8sNamespace: k8sNamespace,
+		mtu:          mtu,
+	})
+	if err != nil {
+		return secondaryInterface{}, err
+	}
+
+	log.Printf("[cni-net] Created secondary interface %v with endpoint %v in network %v.", iface.IfName, endpointID, networkID)
+
+	return secondaryInterface{
+		ifName: iface.IfName,
+		mtu:    mtu,
+		linked: network.LinkedEndpointInfo{NetworkID: networkID, EndpointID: endpointID, IfName: iface.IfName},
+		result: ipamAddResult.ipv4Result,
+	}, nil
+}
+
+// getLinkedEndpointIfName returns the name of the interface of the linked endpoint in the container. Endpoints
+// linked before the name was recorded have it in their endpoint ID instead.
+func getLinkedEndpointIfName(linkedEp network.LinkedEndpointInfo) string {
+	if linkedEp.IfName != "" {
+		return linkedEp.IfName
+	}
+	return getIfNameFromEndpointID(linkedEp.EndpointID)
+}
+
+// removeDefaultRoutes returns the routes without the default routes.
+func removeDefaultRoutes(routes []*cniTypes.Route) []*cniTypes.Route {
+	var filtered []*cniTypes.Route
+	for _, route := range routes {
+		if ones, _ := route.Dst.Mask.Size(); ones == 0 {
+			continue
+		}
+		filtered = append(filtered, route)
+	}
+
+	return filtered
+}
+
+// getLinkedEndpoints returns the endpoints of the secondary interfaces.
+func getLinkedEndpoints(secondaries []secondaryInterface) []network.LinkedEndpointInfo {
+	var linked []network.LinkedEndpointInfo
+	for i := range secondaries {
+		linked = append(linked, secondaries[i].linked)
+	}
+
+	return linked
+}
+
+// getLinkedEndpointsFromNwCfg returns the endpoints of the secondary interfaces of the network config, which are
+// the linked endpoints when the endpoint of the primary interface is gone.
+func getLinkedEndpointsFromNwCfg(nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs) []network.LinkedEndpointInfo {
+	ifaces, err := cni.GetSecondaryInterfacesFromNwCfg(nwCfg.AdditionalArgs)
+	if err != nil {
+		log.Printf("[cni-net] Failed to get secondary interfaces: %v", err)
+		return nil
+	}
+
+	var linked []network.LinkedEndpointInfo
+	for i := range ifaces {
+		linked = append(linked, network.LinkedEndpointInfo{
+			NetworkID:  ifaces[i].Network,
+			EndpointID: GetEndpointID(&cniSkel.CmdArgs{ContainerID: args.ContainerID, IfName: ifaces[i].IfName}),
+			IfName:     ifaces[i].IfName,
+		})
+	}
+
+	return linked
+}
+
+// addSecondaryInterfacesToResult adds the secondary interfaces and their addresses and routes to the result.
+func addSecondaryInterfacesToResult(result *cniTypesCurr.Result, secondaries []secondaryInterface) {
+	for i := range secondaries {
+		ifIndex := len(result.Interfaces)
+		result.Interfaces = append(result.Interfaces, &cniTypesCurr.Interface{Name: secondaries[i].ifName})
+
+		for _, ipConfig := range secondaries[i].result.IPs {
+			secIPConfig := *ipConfig
+			secIPConfig.Interface = &ifIndex
+			result.IPs = append(result.IPs, &secIPConfig)
+		}
+
+		result.Routes = append(result.Routes, secondaries[i].result.Routes...)
+	}
+}
+
+// deleteLinkedEndpoints deletes the linked endpoints and releases their addresses. Linked endpoints which no longer
+// exist are skipped, and the first error is returned after trying all of them.
+func (plugin *NetPlugin) deleteLinkedEndpoints(linked []network.LinkedEndpointInfo, nwCfg *cni.NetworkConfig, args *cniSkel.CmdArgs) error {
+	ifaces, _ := cni.GetSecondaryInterfacesFromNwCfg(nwCfg.AdditionalArgs)
+
+	var deleteErr error
+	for _, linkedEp := range linked {
+		if err := plugin.deleteLinkedEndpoint(linkedEp, nwCfg, ifaces, args); err != nil {
+			log.Errorf("[cni-net] Failed to delete linked endpoint %v: %v", linkedEp.EndpointID, err)
+			if deleteErr == nil {
+				deleteErr = err
+			}
+		}
+	}
+
+	return deleteErr
+}
+
+func (plugin *NetPlugin) deleteLinkedEndpoint(
+	linkedEp network.LinkedEndpointInfo,
+	nwCfg *cni.NetworkConfig,
+	ifaces []cni.SecondaryInterface,
+	args *cniSkel.CmdArgs,
+) error {
+	nwInfo, err := plugin.nm.GetNetworkInfo(linkedEp.NetworkID)
+	if err != nil {
+		log.Printf("[cni-net] Network %v of linked endpoint %v not found: %v", linkedEp.NetworkID, linkedEp.EndpointID, err)
+		return nil
+	}
+
+	epInfo, err := plugin.nm.GetEndpointInfo(linkedEp.NetworkID, linkedEp.EndpointID)
+	if err != nil {
+		log.Printf("[cni-net] Linked endpoint %v not found: %v", linkedEp.EndpointID, err)
+		return nil
+	}
+
+	// The IPAM of the secondary network comes from its secondary interface, or from the network if the network
+	// config no longer has it.
+	secNwCfg := *nwCfg
+	secNwCfg.Name = linkedEp.NetworkID
+	secNwCfg.Ipam.Type = nwInfo.IPAMType
+	for i := range ifaces {
+		if ifaces[i].Network == linkedEp.NetworkID {
+			secNwCfg = *getSecondaryNetworkConfig(nwCfg, &ifaces[i])
+			break
+		}
+	}
+
+	secArgs := *args
+	secArgs.IfName = getLinkedEndpointIfName(linkedEp)
+	secArgs.StdinData = secNwCfg.Serialize()
+
+	// The addresses are released first, so that a retried delete still finds the endpoint if releasing one fails.
+	ipamInvoker := plugin.getSecondaryIpamInvoker(&nwInfo)
+	for i := range epInfo.IPAddresses {
+		if err = ipamInvoker.Delete(&epInfo.IPAddresses[i], &secNwCfg, &secArgs, nwInfo.Options); err != nil {
+			return errors.Wrapf(err, "failed to release address %v", epInfo.IPAddresses[i].String())
+		}
+	}
+
+	telemetry.LogAndSendEvent(plugin.tb, fmt.Sprintf("Deleting linked endpoint:%v in network:%v", linkedEp.EndpointID, linkedEp.NetworkID))
+	if err = plugin.nm.DeleteEndpoint(linkedEp.NetworkID, linkedEp.EndpointID); err != nil {
+		return errors.Wrap(err, "failed to delete endpoint")
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cni"
+	acnnetwork "github.com/Azure/azure-container-networking/network"
+	cniSkel "github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getSecondaryInterfaceArg(t *testing.T, ifName, network, mode, ipamType string) cni.KVPair {
+	iface := cni.SecondaryInterface{IfName: ifName, Network: network, Mode: mode, Master: "eth1"}
+	iface.Ipam.Type = ipamType
+	value, err := json.Marshal(iface)
+	require.NoError(t, err)
+	return cni.KVPair{Name: cni.SecondaryInterfaceStr, Value: value}
+}
+
+func getSecondaryInterfaceArgs(t *testing.T, kvps ...cni.KVPair) *cniSkel.CmdArgs {
+	secNwCfg := nwCfg
+	secNwCfg.Ipam.Type = "azure-vnet-ipam"
+	secNwCfg.AdditionalArgs = kvps
+	return &cniSkel.CmdArgs{
+		StdinData:   secNwCfg.Serialize(),
+		ContainerID: "test-container",
+		Netns:       "test-container",
+		Args:        fmt.Sprintf("K8S_POD_NAME=%v;K8S_POD_NAMESPACE=%v", "test-pod", "test-pod-ns"),
+		IfName:      eth0IfName,
+	}
+}
+
+func TestPluginAddDeleteSecondaryInterface(t *testing.T) {
+	plugin := GetTestResources()
+	secondaryIpamInvoker := NewMockIpamInvoker(false, false, false)
+	plugin.secondaryIpamInvoker = secondaryIpamInvoker
+	args := getSecondaryInterfaceArgs(t, getSecondaryInterfaceArg(t, "net1", "storage", "", "azure-vnet-ipam"))
+
+	require.NoError(t, plugin.Add(args))
+	endpoints, _ := plugin.nm.GetAllEndpoints(nwCfg.Name)
+	require.Len(t, endpoints, 2)
+
+	primary, err := plugin.nm.GetEndpointInfo(nwCfg.Name, GetEndpointID(args))
+	require.NoError(t, err)
+	secondaryEndpointID := GetEndpointID(&cniSkel.CmdArgs{ContainerID: args.ContainerID, IfName: "net1"})
+	assert.Equal(t, []acnnetwork.LinkedEndpointInfo{{NetworkID: "storage", EndpointID: secondaryEndpointID, IfName: "net1"}}, primary.LinkedEndpoints)
+
+	secondary, err := plugin.nm.GetEndpointInfo("storage", secondaryEndpointID)
+	require.NoError(t, err)
+	assert.Equal(t, "net1", secondary.IfName)
+	assert.Len(t, secondaryIpamInvoker.ipMap, 1)
+
+	nwInfo, err := plugin.nm.GetNetworkInfo("storage")
+	require.NoError(t, err)
+	assert.Equal(t, opModeBridge, nwInfo.Mode)
+	assert.Equal(t, "eth1", nwInfo.MasterIfName)
+
+	require.NoError(t, plugin.Delete(args))
+	endpoints, _ = plugin.nm.GetAllEndpoints(nwCfg.Name)
+	assert.Empty(t, endpoints)
+	assert.Empty(t, secondaryIpamInvoker.ipMap)
+}
+
+func TestPluginAddSecondaryInterfaceFail(t *testing.T) {
+	plugin := GetTestResources()
+	primaryIpamInvoker := NewMockIpamInvoker(false, false, false)
+	plugin.ipamInvoker = primaryIpamInvoker
+	plugin.secondaryIpamInvoker = NewMockIpamInvoker(false, true, false)
+	args := getSecondaryInterfaceArgs(t, getSecondaryInterfaceArg(t, "net1", "storage", "", "azure-vnet-ipam"))
+
+	require.Error(t, plugin.Add(args))
+	endpoints, _ := plugin.nm.GetAllEndpoints(nwCfg.Name)
+	assert.Empty(t, endpoints)
+	assert.Empty(t, primaryIpamInvoker.ipMap)
+}
+
+func TestGetSecondaryInterfaces(t *testing.T) {
+	tests := []struct {
+		name    string
+		kvps    []cni.KVPair
+		wantLen int
+		wantErr error
+	}{
+		{
+			name:    "No secondary interfaces",
+			kvps:    []cni.KVPair{{Name: "EndpointPolicy", Value: []byte(`{}`)}},
+			wantLen: 0,
+		},
+		{
+			name: "Two secondary interfaces",
+			kvps: []cni.KVPair{
+				getSecondaryInterfaceArg(t, "net1", "storage", "bridge", "azure-vnet-ipam"),
+				getSecondaryInterfaceArg(t, "net2", "management", "", "azure-vnet-ipam"),
+			},
+			wantLen: 2,
+		},
+		{
+			name:    "Interface name of the primary interface",
+			kvps:    []cni.KVPair{getSecondaryInterfaceArg(t, eth0IfName, "storage", "", "azure-vnet-ipam")},
+			wantErr: errSecondaryInterfaceConflict,
+		},
+		{
+			name: "Network of another secondary interface",
+			kvps: []cni.KVPair{
+				getSecondaryInterfaceArg(t, "net1", "storage", "", "azure-vnet-ipam"),
+				getSecondaryInterfaceArg(t, "net2", "storage", "", "azure-vnet-ipam"),
+			},
+			wantErr: errSecondaryInterfaceConflict,
+		},
+		{
+			name:    "Transparent mode",
+			kvps:    []cni.KVPair{getSecondaryInterfaceArg(t, "net1", "storage", opModeTransparent, "azure-vnet-ipam")},
+			wantErr: errSecondaryInterfaceMode,
+		},
+		{
+			name:    "CNS IPAM",
+			kvps:    []cni.KVPair{getSecondaryInterfaceArg(t, "net1", "storage", "", acnnetwork.AzureCNS)},
+			wantErr: errSecondaryInterfaceIpam,
+		},
+		{
+			name:    "Missing network",
+			kvps:    []cni.KVPair{getSecondaryInterfaceArg(t, "net1", "", "", "azure-vnet-ipam")},
+			wantErr: cni.ErrInvalidSecondaryInterface,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			testNwCfg := nwCfg
+			testNwCfg.AdditionalArgs = tt.kvps
+			ifaces, err := getSecondaryInterfaces(&testNwCfg, args)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, ifaces, tt.wantLen)
+		})
+	}
+}
+
+func TestAddSecondaryInterfacesToResult(t *testing.T) {
+	_, defaultDst, _ := net.ParseCIDR("0.0.0.0/0")
+	_, storageDst, _ := net.ParseCIDR("10.10.0.0/16")
+	gw := net.ParseIP("10.20.0.1")
+	routes := removeDefaultRoutes([]*cniTypes.Route{{Dst: *defaultDst, GW: gw}, {Dst: *storageDst, GW: gw}})
+	require.Len(t, routes, 1)
+
+	result := &cniTypesCurr.Result{
+		Interfaces: []*cniTypesCurr.Interface{{Name: eth0IfName}},
+		IPs:        []*cniTypesCurr.IPConfig{{Address: net.IPNet{IP: net.ParseIP("10.240.0.5"), Mask: net.CIDRMask(24, 32)}}},
+	}
+	addSecondaryInterfacesToResult(result, []secondaryInterface{
+		{
+			ifName: "net1",
+			result: &cniTypesCurr.Result{
+				IPs:    []*cniTypesCurr.IPConfig{{Address: net.IPNet{IP: net.ParseIP("10.20.0.5"), Mask: net.CIDRMask(24, 32)}}},
+				Routes: routes,
+			},
+		},
+	})
+
+	require.Len(t, result.Interfaces, 2)
+	assert.Equal(t, "net1", result.Interfaces[1].Name)
+	require.Len(t, result.IPs, 2)
+	assert.Nil(t, result.IPs[0].Interface)
+	require.NotNil(t, result.IPs[1].Interface)
+	assert.Equal(t, 1, *result.IPs[1].Interface)
+	assert.Equal(t, routes, result.Routes)
+}
+
+func TestPluginDeleteSecondaryInterfaceFailIsRetried(t *testing.T) {
+	plugin := GetTestResources()
+	secondaryIpamInvoker := NewMockIpamInvoker(false, false, false)
+	plugin.secondaryIpamInvoker = secondaryIpamInvoker
+	args := getSecondaryInterfaceArgs(t, getSecondaryInterfaceArg(t, "net1", "storage", "", "azure-vnet-ipam"))
+	require.NoError(t, plugin.Add(args))
+
+	// the address of the secondary interface can't be released, so both endpoints are kept for the retry.
+	secondaryIpamInvoker.v4Fail = true
+	require.Error(t, plugin.Delete(args))
+	endpoints, _ := plugin.nm.GetAllEndpoints(nwCfg.Name)
+	assert.Len(t, endpoints, 2)
+
+	secondaryIpamInvoker.v4Fail = false
+	require.NoError(t, plugin.Delete(args))
+	endpoints, _ = plugin.nm.GetAllEndpoints(nwCfg.Name)
+	assert.Empty(t, endpoints)
+	assert.Empty(t, secondaryIpamInvoker.ipMap)
+}
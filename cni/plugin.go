@@ -6,8 +6,11 @@ package cni
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
 
 	"github.com/Azure/azure-container-networking/common"
 	"github.com/Azure/azure-container-networking/log"
@@ -24,10 +27,15 @@ import (
 
 var errEmptyContent = errors.New("read content is zero bytes")
 
+// namedLockDirSuffix is appended to the plugin name for the directory of the named lock files.
+const namedLockDirSuffix = "-locks"
+
 // Plugin is the parent class for CNI plugins.
 type Plugin struct {
 	*common.Plugin
-	version string
+	version     string
+	storeLocked bool
+	namedLock   *common.NamedFileLock
 }
 
 // NewPlugin creates a new CNI plugin.
@@ -80,8 +88,20 @@ func (plugin *Plugin) Execute(api PluginApi) (err error) {
 	// Set supported CNI versions.
 	pluginInfo := cniVers.PluginSupports(supportedVersions...)
 
-	// Parse args and call the appropriate cmd handler.
-	cniErr := cniSkel.PluginMainWithError(api.Add, api.Get, api.Delete, pluginInfo, plugin.version)
+	check := api.Get
+	lifecycle, ok := api.(LifecycleApi)
+	if ok {
+		check = lifecycle.Check
+	}
+
+	var cniErr *cniTypes.Error
+	if cmd := os.Getenv(Cmd); ok && (cmd == CmdStatus || cmd == CmdGC) {
+		// The vendored skel predates STATUS and GC, so they are dispatched here.
+		cniErr = executeStatusGC(cmd, lifecycle, pluginInfo)
+	} else {
+		// Parse args and call the appropriate cmd handler.
+		cniErr = cniSkel.PluginMainWithError(api.Add, check, api.Delete, pluginInfo, plugin.version)
+	}
 	if cniErr != nil {
 		cniErr.Print()
 		return cniErr
@@ -90,6 +110,50 @@ func (plugin *Plugin) Execute(api PluginApi) (err error) {
 	return nil
 }
 
+// executeStatusGC calls the STATUS or GC handler with the network configuration from stdin.
+// Neither command has container arguments.
+func executeStatusGC(cmd string, api LifecycleApi, pluginInfo cniVers.PluginInfo) *cniTypes.Error {
+	stdinData, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return cniTypes.NewError(cniTypes.ErrIOFailure, fmt.Sprintf("error reading from stdin: %v", err), "")
+	}
+
+	decoder := &cniVers.ConfigDecoder{}
+	configVersion, err := decoder.Decode(stdinData)
+	if err != nil {
+		return cniTypes.NewError(cniTypes.ErrDecodingFailure, err.Error(), "")
+	}
+	if gtet, err := cniVers.GreaterThanOrEqualTo(configVersion, statusGCVersion); err != nil {
+		return cniTypes.NewError(cniTypes.ErrDecodingFailure, err.Error(), "")
+	} else if !gtet {
+		return cniTypes.NewError(cniTypes.ErrIncompatibleCNIVersion, fmt.Sprintf("config version does not allow %s", cmd), "")
+	}
+	supported := false
+	for _, v := range pluginInfo.SupportedVersions() {
+		supported = supported || v == configVersion
+	}
+	if !supported {
+		return cniTypes.NewError(cniTypes.ErrIncompatibleCNIVersion, fmt.Sprintf("plugin does not support config version %s", configVersion), "")
+	}
+
+	args := &cniSkel.CmdArgs{
+		Path:      os.Getenv("CNI_PATH"),
+		StdinData: stdinData,
+	}
+	if cmd == CmdStatus {
+		err = api.Status(args)
+	} else {
+		err = api.GC(args)
+	}
+	if err == nil {
+		return nil
+	}
+	if cniErr, ok := err.(*cniTypes.Error); ok {
+		return cniErr
+	}
+	return cniTypes.NewError(ErrRuntime, err.Error(), "")
+}
+
 // DelegateAdd calls the given plugin's ADD command and returns the result.
 func (plugin *Plugin) DelegateAdd(pluginName string, nwCfg *NetworkConfig) (*cniTypesCurr.Result, error) {
 	var result *cniTypesCurr.Result
@@ -100,7 +164,13 @@ func (plugin *Plugin) DelegateAdd(pluginName string, nwCfg *NetworkConfig) (*cni
 
 	os.Setenv(Cmd, CmdAdd)
 
-	res, err := cniInvoke.DelegateAdd(context.TODO(), pluginName, nwCfg.Serialize(), nil)
+	// the vendored CNI library can't parse 1.x results, so the delegate is asked for the latest result it implements.
+	delegateCfg := *nwCfg
+	if isVersion1(delegateCfg.CNIVersion) {
+		delegateCfg.CNIVersion = cniTypesCurr.ImplementedSpecVersion
+	}
+
+	res, err := cniInvoke.DelegateAdd(context.TODO(), pluginName, delegateCfg.Serialize(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to delegate: %v", err)
 	}
@@ -161,23 +231,41 @@ func (plugin *Plugin) RetriableError(err error) *cniTypes.Error {
 func (plugin *Plugin) InitializeKeyValueStore(config *common.PluginConfig) error {
 	// Create the key value store.
 	if plugin.Store == nil {
+		if value := os.Getenv(StoreChecksum); value != "" {
+			checksum, err := strconv.ParseBool(value)
+			if err != nil {
+				log.Printf("[cni] Invalid value %s of %s, the store is written without a checksum.", value, StoreChecksum)
+			}
+			store.SetChecksumEnabled(checksum)
+		}
+
 		lockclient, err := processlock.NewFileLock(platform.CNILockPath + plugin.Name + store.LockExtension)
 		if err != nil {
 			log.Printf("[cni] Error initializing file lock:%v", err)
 			return errors.Wrap(err, "error creating new filelock")
 		}
 
-		plugin.Store, err = store.NewJsonFileStore(platform.CNIRuntimePath+plugin.Name+".json", lockclient)
+		plugin.Store, err = store.New(store.Backend(os.Getenv(StoreBackend)), platform.CNIRuntimePath+plugin.Name+".json", lockclient)
 		if err != nil {
 			log.Printf("[cni] Failed to create store: %v.", err)
 			return err
 		}
 	}
 
-	// Acquire store lock.
-	if err := plugin.Store.Lock(store.DefaultLockTimeout); err != nil {
-		log.Printf("[cni] Failed to lock store: %v.", err)
-		return err
+	// When the store is locked per change of the state, the processes of the plugin instead order the operations
+	// on the same container with named locks.
+	if config.LockStorePerChange {
+		if plugin.namedLock == nil {
+			plugin.namedLock = common.InitNamedFileLock(
+				filepath.Join(platform.CNILockPath, plugin.Name+namedLockDirSuffix), common.DefaultNamedFileLockStripes)
+		}
+	} else {
+		// Acquire store lock.
+		if err := plugin.Store.Lock(store.DefaultLockTimeout); err != nil {
+			log.Printf("[cni] Failed to lock store: %v.", err)
+			return err
+		}
+		plugin.storeLocked = true
 	}
 
 	config.Store = plugin.Store
@@ -187,7 +275,7 @@ func (plugin *Plugin) InitializeKeyValueStore(config *common.PluginConfig) error
 
 // Uninitialize key-value store
 func (plugin *Plugin) UninitializeKeyValueStore() error {
-	if plugin.Store != nil {
+	if plugin.Store != nil && plugin.storeLocked {
 		err := plugin.Store.Unlock()
 		if err != nil {
 			log.Printf("[cni] Failed to unlock store: %v.", err)
@@ -195,6 +283,33 @@ func (plugin *Plugin) UninitializeKeyValueStore() error {
 		}
 	}
 	plugin.Store = nil
+	plugin.storeLocked = false
+
+	return nil
+}
+
+// AcquireNamedLock acquires a lock held across the processes of the plugin, such as the lock of the operations on a
+// container. It does nothing unless the key-value store is locked per change of the state.
+func (plugin *Plugin) AcquireNamedLock(name string) error {
+	if plugin.namedLock == nil {
+		return nil
+	}
+
+	if err := plugin.namedLock.LockAcquire(name, store.DefaultLockTimeout); err != nil {
+		log.Printf("[cni] Failed to acquire lock %s: %v.", name, err)
+		return errors.Wrap(err, "failed to acquire named lock")
+	}
 
 	return nil
 }
+
+// ReleaseNamedLock releases a lock acquired by AcquireNamedLock.
+func (plugin *Plugin) ReleaseNamedLock(name string) {
+	if plugin.namedLock == nil {
+		return
+	}
+
+	if err := plugin.namedLock.LockRelease(name); err != nil {
+		log.Printf("[cni] Failed to release lock %s: %v.", name, err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package cni
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+	cniVers "github.com/containernetworking/cni/pkg/version"
+)
+
+// result100 is a CNI 1.0.0 result. It is the 0.4.0 result without the version of each IP,
+// which the vendored CNI library does not implement.
+type result100 struct {
+	CNIVersion string            `json:"cniVersion,omitempty"`
+	Interfaces []*interface100   `json:"interfaces,omitempty"`
+	IPs        []*ipConfig100    `json:"ips,omitempty"`
+	Routes     []*cniTypes.Route `json:"routes,omitempty"`
+	DNS        cniTypes.DNS      `json:"dns,omitempty"`
+}
+
+// interface100 is a CNI 1.x interface, which also has the MTU of the interface since CNI 1.1.0.
+type interface100 struct {
+	Name    string `json:"name"`
+	Mac     string `json:"mac,omitempty"`
+	Mtu     int    `json:"mtu,omitempty"`
+	Sandbox string `json:"sandbox,omitempty"`
+}
+
+type ipConfig100 struct {
+	Interface *int           `json:"interface,omitempty"`
+	Address   cniTypes.IPNet `json:"address"`
+	Gateway   net.IP         `json:"gateway,omitempty"`
+}
+
+// isVersion1 returns whether the CNI version is 1.0.0 or later.
+func isVersion1(version string) bool {
+	gtet, err := cniVers.GreaterThanOrEqualTo(version, "1.0.0")
+	return err == nil && gtet
+}
+
+// GetResultAsVersion converts the result to the requested CNI version, including 1.x versions.
+func GetResultAsVersion(result *cniTypesCurr.Result, version string) (cniTypes.Result, error) {
+	if !isVersion1(version) {
+		return result.GetAsVersion(version)
+	}
+
+	res := &result100{
+		CNIVersion: version,
+		Routes:     result.Routes,
+		DNS:        result.DNS,
+	}
+	for _, iface := range result.Interfaces {
+		res.Interfaces = append(res.Interfaces, &interface100{
+			Name:    iface.Name,
+			Mac:     iface.Mac,
+			Sandbox: iface.Sandbox,
+		})
+	}
+	for _, ip := range result.IPs {
+		res.IPs = append(res.IPs, &ipConfig100{
+			Interface: ip.Interface,
+			Address:   cniTypes.IPNet(ip.Address),
+			Gateway:   ip.Gateway,
+		})
+	}
+	return res, nil
+}
+
+// ErrResultMTUUnsupported is returned when the MTU of an interface can't be reported in a result of
+// the requested CNI version.
+var ErrResultMTUUnsupported = errors.New("interface MTU is only reported in CNI 1.1.0 or later results")
+
+// SetResultInterfaceMTU sets the MTU of an interface of the result. The interface MTU was added to the result
+// in CNI 1.1.0, so ErrResultMTUUnsupported is returned for results of earlier versions, whose interfaces still
+// have the MTU but can't report it.
+func SetResultInterfaceMTU(result cniTypes.Result, ifName string, mtu int) error {
+	res, ok := result.(*result100)
+	if !ok {
+		return fmt.Errorf("%w: result is version %s", ErrResultMTUUnsupported, result.Version())
+	}
+	if gtet, err := cniVers.GreaterThanOrEqualTo(res.CNIVersion, "1.1.0"); err != nil || !gtet {
+		return fmt.Errorf("%w: result is version %s", ErrResultMTUUnsupported, res.CNIVersion)
+	}
+
+	for _, iface := range res.Interfaces {
+		if iface.Name == ifName {
+			iface.Mtu = mtu
+		}
+	}
+	return nil
+}
+
+func (r *result100) Version() string {
+	return r.CNIVersion
+}
+
+func (r *result100) GetAsVersion(version string) (cniTypes.Result, error) {
+	if !isVersion1(version) {
+		return nil, fmt.Errorf("cannot convert version %s to %q", r.CNIVersion, version)
+	}
+	r.CNIVersion = version
+	return r, nil
+}
+
+func (r *result100) Print() error {
+	return r.PrintTo(os.Stdout)
+}
+
+func (r *result100) PrintTo(writer io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
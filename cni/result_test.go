@@ -0,0 +1,37 @@
+package cni
+
+import (
+	"testing"
+
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetResultInterfaceMTU(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		wantErr bool
+	}{
+		{name: "0.3.0 result", version: "0.3.0", wantErr: true},
+		{name: "1.0.0 result", version: "1.0.0", wantErr: true},
+		{name: "1.1.0 result", version: "1.1.0"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			result := &cniTypesCurr.Result{Interfaces: []*cniTypesCurr.Interface{{Name: "eth0"}, {Name: "eth1"}}}
+			res, err := GetResultAsVersion(result, tt.version)
+			require.NoError(t, err)
+
+			err = SetResultInterfaceMTU(res, "eth1", 1400)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrResultMTUUnsupported)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 0, res.(*result100).Interfaces[0].Mtu)
+			require.Equal(t, 1400, res.(*result100).Interfaces[1].Mtu)
+		})
+	}
+}
@@ -105,6 +105,13 @@ var args = common.ArgumentList{
 		Type:         "string",
 		DefaultValue: platform.CNMRuntimePath,
 	},
+	{
+		Name:         common.OptStoreBackend,
+		Shorthand:    common.OptStoreBackendAlias,
+		Description:  "Set the store backend (json or bolt), detected from the existing store files by default",
+		Type:         "string",
+		DefaultValue: "",
+	},
 }
 
 // Prints description and version information.
@@ -126,6 +133,7 @@ func main() {
 	ipamQueryInterval, _ := common.GetArg(common.OptIpamQueryInterval).(int)
 	vers := common.GetArg(common.OptVersion).(bool)
 	storeFileLocation := common.GetArg(common.OptStoreFileLocation).(string)
+	storeBackend := common.GetArg(common.OptStoreBackend).(string)
 
 	if vers {
 		printVersion()
@@ -167,7 +175,7 @@ func main() {
 
 	// Create the key value store.
 	storeFileName := storeFileLocation + name + ".json"
-	config.Store, err = store.NewJsonFileStore(storeFileName, lockclient)
+	config.Store, err = store.New(store.Backend(storeBackend), storeFileName, lockclient)
 	if err != nil {
 		log.Errorf("Failed to create store file: %s, due to error %v\n", storeFileName, err)
 		return
@@ -6,6 +6,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-container-networking/cns/types"
 	"github.com/pkg/errors"
@@ -26,6 +27,9 @@ const (
 	DetachContainerFromNetwork               = "/network/detachcontainerfromnetwork"
 	RequestIPConfig                          = "/network/requestipconfig"
 	ReleaseIPConfig                          = "/network/releaseipconfig"
+	RequestIPConfigs                         = "/network/requestipconfigs"
+	ReleaseIPConfigs                         = "/network/releaseipconfigs"
+	WatchIPConfigs                           = "/network/watchipconfigs"
 	PathDebugIPAddresses                     = "/debug/ipaddresses"
 	PathDebugPodContext                      = "/debug/podcontext"
 	PathDebugRestData                        = "/debug/restdata"
@@ -255,6 +259,20 @@ func NewPodInfoFromIPConfigRequest(req IPConfigRequest) (PodInfo, error) {
 	return p, nil
 }
 
+// KubePodsToPodInfos returns a PodInfo for each of the Pods which is not host network, whether or not it has been
+// assigned an IP yet.
+func KubePodsToPodInfos(pods []corev1.Pod) []PodInfo {
+	podInfos := make([]PodInfo, 0, len(pods))
+	for i := range pods {
+		if pods[i].Spec.HostNetwork {
+			// ignore host network pods.
+			continue
+		}
+		podInfos = append(podInfos, NewPodInfo("", "", pods[i].Name, pods[i].Namespace))
+	}
+	return podInfos
+}
+
 func KubePodsToPodInfoByIP(pods []corev1.Pod) (map[string]PodInfo, error) {
 	podInfoByIP := map[string]PodInfo{}
 	for i := range pods {
@@ -266,12 +284,22 @@ func KubePodsToPodInfoByIP(pods []corev1.Pod) (map[string]PodInfo, error) {
 			// ignore pods without an assigned IP.
 			continue
 		}
-		// error if we have already recorded that this IP is assigned to a Pod.
-		if _, ok := podInfoByIP[pods[i].Status.PodIP]; ok {
-			return nil, errors.Wrap(ErrDuplicateIP, pods[i].Status.PodIP)
+		// a dual-stack Pod lists both of its IPs in PodIPs, the first of which is the PodIP.
+		podIPs := []string{pods[i].Status.PodIP}
+		for _, podIP := range pods[i].Status.PodIPs {
+			if podIP.IP != pods[i].Status.PodIP {
+				podIPs = append(podIPs, podIP.IP)
+			}
+		}
+		podInfo := NewPodInfo("", "", pods[i].Name, pods[i].Namespace)
+		for _, podIP := range podIPs {
+			// error if we have already recorded that this IP is assigned to a Pod.
+			if _, ok := podInfoByIP[podIP]; ok {
+				return nil, errors.Wrap(ErrDuplicateIP, podIP)
+			}
+			// record the PodInfo by assigned IP.
+			podInfoByIP[podIP] = podInfo
 		}
-		// record the PodInfo by assigned IP.
-		podInfoByIP[pods[i].Status.PodIP] = NewPodInfo("", "", pods[i].Name, pods[i].Namespace)
 	}
 	return podInfoByIP, nil
 }
@@ -365,6 +393,10 @@ type PodIpInfo struct {
 	PodIPConfig                     IPSubnet
 	NetworkContainerPrimaryIPConfig IPConfiguration
 	HostPrimaryIPInfo               HostIPInfo
+	// PodIPConfigV6 and NetworkContainerPrimaryIPConfigV6 are set for a dual-stack Pod, which is assigned
+	// an IPv6 address from an IPv6 NC alongside its IPv4 address.
+	PodIPConfigV6                     IPSubnet
+	NetworkContainerPrimaryIPConfigV6 IPConfiguration
 }
 
 // DeleteNetworkContainerRequest specifies the details about the request to delete a specifc network container.
@@ -374,16 +406,35 @@ type HostIPInfo struct {
 	Subnet    string
 }
 
+// PodSubnetAnnotation is the Pod annotation which selects the subnet that the Pod IP is
+// assigned from when the Node has more than one NetworkContainer.
+const PodSubnetAnnotation = "kubernetes.azure.com/pod-subnet"
+
+// StickyIPAnnotation is the Pod annotation which, when "true", opts the Pod in to getting the same IP back
+// when it is recreated with the same namespace and name on the Node, such as a StatefulSet Pod.
+const StickyIPAnnotation = "kubernetes.azure.com/sticky-ip"
+
+// NamespaceIPQuota limits the IPs which CNS will assign to the Pods in a namespace.
+type NamespaceIPQuota struct {
+	// MaxIPs is the most IPs that may be assigned to the namespace. Zero is unlimited.
+	MaxIPs int
+	// ReservedIPs is the number of IPs in the pool that are held for the namespace,
+	// and which will not be assigned to other namespaces.
+	ReservedIPs int
+}
+
 type IPConfigRequest struct {
 	DesiredIPAddress    string
+	DesiredIPv6Address  string `json:",omitempty"`
 	PodInterfaceID      string
 	InfraContainerID    string
 	OrchestratorContext json.RawMessage
+	PodAnnotations      map[string]string `json:",omitempty"`
 }
 
 func (i IPConfigRequest) String() string {
-	return fmt.Sprintf("[IPConfigRequest: DesiredIPAddress %s, PodInterfaceID %s, InfraContainerID %s, OrchestratorContext %s]",
-		i.DesiredIPAddress, i.PodInterfaceID, i.InfraContainerID, string(i.OrchestratorContext))
+	return fmt.Sprintf("[IPConfigRequest: DesiredIPAddress %s, DesiredIPv6Address %s, PodInterfaceID %s, InfraContainerID %s, OrchestratorContext %s]",
+		i.DesiredIPAddress, i.DesiredIPv6Address, i.PodInterfaceID, i.InfraContainerID, string(i.OrchestratorContext))
 }
 
 // IPConfigResponse is used in CNS IPAM mode as a response to CNI ADD
@@ -392,6 +443,40 @@ type IPConfigResponse struct {
 	Response  Response
 }
 
+// IPConfigsRequest is used in CNS IPAM mode to request or release the IPs of several Pod interfaces
+// atomically in one call.
+type IPConfigsRequest struct {
+	IPConfigRequests []IPConfigRequest
+}
+
+// IPConfigResult is the result of a single IPConfigRequest in an IPConfigsRequest.
+type IPConfigResult struct {
+	PodIpInfo PodIpInfo
+	Response  Response
+}
+
+// IPConfigsResponse is used in CNS IPAM mode as a response to an IPConfigsRequest. It has a result for each
+// of the requests, in the same order. If any of the requests failed, the whole batch was rolled back.
+type IPConfigsResponse struct {
+	Results  []IPConfigResult
+	Response Response
+}
+
+// IPConfigStateEvent is sent on the WatchIPConfigs stream whenever an IPConfigurationStatus changes state.
+// Revisions increase by one with each event, and a watch can be resumed after the last Revision it received
+// until CNS restarts, after which the watch has to start over.
+type IPConfigStateEvent struct {
+	Revision      uint64
+	ID            string
+	IPAddress     string
+	NCID          string
+	PreviousState types.IPState `json:",omitempty"`
+	State         types.IPState
+	PodName       string `json:",omitempty"`
+	PodNamespace  string `json:",omitempty"`
+	Time          time.Time
+}
+
 // GetIPAddressesRequest is used in CNS IPAM mode to get the states of IPConfigs
 // The IPConfigStateFilter is a slice of IPs to fetch from CNS that match those states
 type GetIPAddressesRequest struct {
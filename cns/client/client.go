@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -8,6 +9,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Azure/azure-container-networking/cns"
@@ -30,6 +33,10 @@ var clientPaths = []string{
 	cns.DeleteHostNCApipaEndpointPath,
 	cns.RequestIPConfig,
 	cns.ReleaseIPConfig,
+	cns.RequestIPConfigs,
+	cns.ReleaseIPConfigs,
+	cns.WatchIPConfigs,
+	cns.GetHealthReportPath,
 	cns.PathDebugIPAddresses,
 	cns.PathDebugPodContext,
 	cns.PathDebugRestData,
@@ -304,6 +311,54 @@ func (c *Client) ReleaseIPAddress(ctx context.Context, ipconfig cns.IPConfigRequ
 	return nil
 }
 
+// RequestIPAddresses calls requestIPConfigs on CNS to assign an IP for each of the passed requests atomically.
+// If any of the requests fail, none of the IPs are assigned, and the per request results are returned with the error.
+func (c *Client) RequestIPAddresses(ctx context.Context, ipconfigs []cns.IPConfigRequest) (*cns.IPConfigsResponse, error) {
+	return c.batchIPConfigs(ctx, cns.RequestIPConfigs, ipconfigs)
+}
+
+// ReleaseIPAddresses calls releaseIPConfigs on CNS to release the IPs of each of the passed requests atomically.
+// If any of the releases fail, none of the IPs are released, and the per request results are returned with the error.
+func (c *Client) ReleaseIPAddresses(ctx context.Context, ipconfigs []cns.IPConfigRequest) (*cns.IPConfigsResponse, error) {
+	return c.batchIPConfigs(ctx, cns.ReleaseIPConfigs, ipconfigs)
+}
+
+func (c *Client) batchIPConfigs(ctx context.Context, path string, ipconfigs []cns.IPConfigRequest) (*cns.IPConfigsResponse, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(cns.IPConfigsRequest{IPConfigRequests: ipconfigs})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode IPConfigsRequest")
+	}
+
+	u := c.routes[path]
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var response cns.IPConfigsResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode IPConfigsResponse")
+	}
+
+	if response.Response.ReturnCode != 0 {
+		return &response, errors.New(response.Response.Message)
+	}
+
+	return &response, nil
+}
+
 // GetIPAddressesMatchingStates takes a variadic number of string parameters, to get all IP Addresses matching a number of states
 // usage GetIPAddressesWithStates(ctx, types.Available...)
 func (c *Client) GetIPAddressesMatchingStates(ctx context.Context, stateFilter ...types.IPState) ([]cns.IPConfigurationStatus, error) {
@@ -349,6 +404,70 @@ func (c *Client) GetIPAddressesMatchingStates(ctx context.Context, stateFilter .
 	return resp.IPConfigurationStatus, nil
 }
 
+// Watch streams the IP state changes in CNS after the passed revision, or from now if it is 0, and calls fn
+// with each of them until the context is cancelled, the stream ends, or fn returns an error. It returns the
+// revision of the last event passed to fn, which a new Watch can be resumed from. If the revision is no longer
+// in the CNS history, restserver.ErrRevisionCompacted is returned and the watch has to start over.
+func (c *Client) Watch(ctx context.Context, revision uint64, fn func(cns.IPConfigStateEvent) error) (uint64, error) {
+	u := c.routes[cns.WatchIPConfigs]
+	if revision > 0 {
+		q := u.Query()
+		q.Set("revision", strconv.FormatUint(revision, 10))
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return revision, errors.Wrap(err, "failed to build request")
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// the request timeout would cut the stream off, so the watch is only bounded by the context.
+	client := c.client
+	if hc, ok := client.(*http.Client); ok {
+		streaming := *hc
+		streaming.Timeout = 0
+		client = &streaming
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return revision, errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusGone {
+		return revision, errors.Wrapf(restserver.ErrRevisionCompacted, "failed to resume watch from revision %d", revision)
+	}
+	if res.StatusCode != http.StatusOK {
+		return revision, errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	var data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			continue
+		}
+		if line != "" || data == "" {
+			continue
+		}
+		var event cns.IPConfigStateEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return revision, errors.Wrap(err, "failed to decode IPConfigStateEvent")
+		}
+		data = ""
+		if err := fn(event); err != nil {
+			return revision, err
+		}
+		revision = event.Revision
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return revision, errors.Wrap(err, "failed to read watch stream")
+	}
+	return revision, ctx.Err()
+}
+
 // GetPodOrchestratorContext calls GetPodIpOrchestratorContext API on CNS
 func (c *Client) GetPodOrchestratorContext(ctx context.Context) (map[string]string, error) {
 	u := c.routes[cns.PathDebugPodContext]
@@ -378,6 +497,35 @@ func (c *Client) GetPodOrchestratorContext(ctx context.Context) (map[string]stri
 	return resp.PodContext, nil
 }
 
+// GetHealthReport returns an error if CNS is unreachable or reports that it is unhealthy.
+func (c *Client) GetHealthReport(ctx context.Context) error {
+	u := c.routes[cns.GetHealthReportPath]
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to build request")
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "http request failed")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("http response %d", res.StatusCode)
+	}
+
+	var resp cns.Response
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return errors.Wrap(err, "failed to decode Response")
+	}
+
+	if resp.ReturnCode != 0 {
+		return errors.New(resp.Message)
+	}
+
+	return nil
+}
+
 // GetHTTPServiceData gets all public in-memory struct details for debugging purpose
 func (c *Client) GetHTTPServiceData(ctx context.Context) (*restserver.GetHTTPServiceDataResponse, error) {
 	u := c.routes[cns.PathDebugRestData]
@@ -298,6 +298,82 @@ func TestCNSClientRequestAndRelease(t *testing.T) {
 	assert.NoError(t, err, "Expected to not fail when releasing IP reservation found with context")
 }
 
+func TestCNSClientBatchRequestAndRelease(t *testing.T) {
+	cnsClient, _ := New("", 2*time.Hour)
+	addTestStateToRestServer(t, []string{"10.0.0.6", "10.0.0.7"})
+
+	reqs := []cns.IPConfigRequest{}
+	for _, name := range []string{"batchpod1", "batchpod2", "batchpod3"} {
+		orchestratorContext, err := json.Marshal(cns.KubernetesPodInfo{PodName: name, PodNamespace: "batch"})
+		require.NoError(t, err)
+		reqs = append(reqs, cns.IPConfigRequest{OrchestratorContext: orchestratorContext})
+	}
+
+	// there are only two IPs, so the third request fails and the whole batch is rolled back.
+	resp, err := cnsClient.RequestIPAddresses(context.TODO(), reqs)
+	require.Error(t, err)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, types.FailedToAllocateIPConfig, resp.Results[2].Response.ReturnCode)
+	ipaddresses, err := cnsClient.GetIPAddressesMatchingStates(context.TODO(), types.Assigned)
+	require.NoError(t, err)
+	assert.Empty(t, ipaddresses)
+
+	resp, err = cnsClient.RequestIPAddresses(context.TODO(), reqs[:2])
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 2)
+	assert.ElementsMatch(t, []string{"10.0.0.6", "10.0.0.7"},
+		[]string{resp.Results[0].PodIpInfo.PodIPConfig.IPAddress, resp.Results[1].PodIpInfo.PodIPConfig.IPAddress})
+	ipaddresses, err = cnsClient.GetIPAddressesMatchingStates(context.TODO(), types.Assigned)
+	require.NoError(t, err)
+	assert.Len(t, ipaddresses, 2)
+
+	_, err = cnsClient.ReleaseIPAddresses(context.TODO(), reqs[:2])
+	require.NoError(t, err)
+	ipaddresses, err = cnsClient.GetIPAddressesMatchingStates(context.TODO(), types.Assigned)
+	require.NoError(t, err)
+	assert.Empty(t, ipaddresses)
+}
+
+func TestCNSClientWatch(t *testing.T) {
+	cnsClient, _ := New("", 2*time.Second)
+	addTestStateToRestServer(t, []string{"10.0.0.8"})
+
+	orchestratorContext, err := json.Marshal(cns.KubernetesPodInfo{PodName: "watchpod", PodNamespace: "watch"})
+	require.NoError(t, err)
+	req := cns.IPConfigRequest{OrchestratorContext: orchestratorContext}
+	_, err = cnsClient.RequestIPAddress(context.TODO(), req)
+	require.NoError(t, err)
+	require.NoError(t, cnsClient.ReleaseIPAddress(context.TODO(), req))
+
+	// replay the history until the assignment to the Pod, which is not accepted.
+	errStop := errors.New("stop")
+	last, err := cnsClient.Watch(context.TODO(), 1, func(event cns.IPConfigStateEvent) error {
+		if event.PodName == "watchpod" && event.State == types.Assigned {
+			return errStop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, errStop)
+
+	// resuming from the last accepted revision streams the assignment again, then the release.
+	received := []cns.IPConfigStateEvent{}
+	_, err = cnsClient.Watch(context.TODO(), last, func(event cns.IPConfigStateEvent) error {
+		received = append(received, event)
+		if len(received) == 2 {
+			return errStop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, errStop)
+	require.Len(t, received, 2)
+	assert.Equal(t, last+1, received[0].Revision)
+	assert.Equal(t, "10.0.0.8", received[0].IPAddress)
+	assert.Equal(t, types.Assigned, received[0].State)
+	assert.Equal(t, types.Assigned, received[1].PreviousState)
+	assert.Equal(t, types.Available, received[1].State)
+	assert.Equal(t, "watchpod", received[1].PodName)
+}
+
 func TestCNSClientPodContextApi(t *testing.T) {
 	podName := "testpodname"
 	podNamespace := "testpodnamespace"
@@ -1323,6 +1399,79 @@ func TestGetPodOrchestratorContext(t *testing.T) {
 	}
 }
 
+func TestGetHealthReport(t *testing.T) {
+	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		mockdo  *mockdo
+		routes  map[string]url.URL
+		wantErr bool
+	}{
+		{
+			name: "happy case",
+			ctx:  context.TODO(),
+			mockdo: &mockdo{
+				errToReturn:            nil,
+				objToReturn:            &cns.Response{},
+				httpStatusCodeToReturn: http.StatusOK,
+			},
+			routes:  emptyRoutes,
+			wantErr: false,
+		},
+		{
+			name: "bad request",
+			ctx:  context.TODO(),
+			mockdo: &mockdo{
+				errToReturn:            errBadRequest,
+				objToReturn:            nil,
+				httpStatusCodeToReturn: http.StatusBadRequest,
+			},
+			routes:  emptyRoutes,
+			wantErr: true,
+		},
+		{
+			name: "http status not ok",
+			ctx:  context.TODO(),
+			mockdo: &mockdo{
+				errToReturn:            nil,
+				objToReturn:            nil,
+				httpStatusCodeToReturn: http.StatusInternalServerError,
+			},
+			routes:  emptyRoutes,
+			wantErr: true,
+		},
+		{
+			name: "cns return code not zero",
+			ctx:  context.TODO(),
+			mockdo: &mockdo{
+				errToReturn: nil,
+				objToReturn: &cns.Response{
+					ReturnCode: types.UnexpectedError,
+				},
+				httpStatusCodeToReturn: http.StatusOK,
+			},
+			routes:  emptyRoutes,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{
+				client: tt.mockdo,
+				routes: tt.routes,
+			}
+			err := client.GetHealthReport(tt.ctx)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestGetHTTPServiceData(t *testing.T) {
 	emptyRoutes, _ := buildRoutes(defaultBaseURL, clientPaths)
 	tests := []struct {
@@ -28,19 +28,23 @@ func newCNIPodInfoProvider(exec exec.Interface) (cns.PodInfoByIPProvider, error)
 }
 
 // cniStateToPodInfoByIP converts an AzureCNIState dumped from a CNI exec
-// into a PodInfo map, using the first endpoint IP as the key in the map.
+// into a PodInfo map, using each endpoint IP as a key in the map.
 func cniStateToPodInfoByIP(state *api.AzureCNIState) (map[string]cns.PodInfo, error) {
 	podInfoByIP := map[string]cns.PodInfo{}
 	for _, endpoint := range state.ContainerInterfaces {
-		if _, ok := podInfoByIP[endpoint.IPAddresses[0].IP.String()]; ok {
-			return nil, errors.Wrap(cns.ErrDuplicateIP, endpoint.IPAddresses[0].IP.String())
-		}
-		podInfoByIP[endpoint.IPAddresses[0].IP.String()] = cns.NewPodInfo(
+		podInfo := cns.NewPodInfo(
 			endpoint.ContainerID,
 			endpoint.PodEndpointId,
 			endpoint.PodName,
 			endpoint.PodNamespace,
 		)
+		// a dual-stack endpoint has an IPv6 address after its IPv4 address.
+		for _, ipAddr := range endpoint.IPAddresses {
+			if _, ok := podInfoByIP[ipAddr.IP.String()]; ok {
+				return nil, errors.Wrap(cns.ErrDuplicateIP, ipAddr.IP.String())
+			}
+			podInfoByIP[ipAddr.IP.String()] = podInfo
+		}
 	}
 	return podInfoByIP, nil
 }
@@ -19,19 +19,29 @@ const (
 )
 
 type CNSConfig struct {
-	ChannelMode                 string
-	InitializeFromCNI           bool
-	ManagedSettings             ManagedSettings
-	Debug                       bool
-	SyncHostNCTimeoutMs         int
-	SyncHostNCVersionIntervalMs int
-	TLSCertificatePath          string
-	TLSEndpoint                 string
-	TLSPort                     string
-	TLSSubjectName              string
-	TelemetrySettings           TelemetrySettings
-	UseHTTPS                    bool
-	WireserverIP                string
+	ChannelMode                   string
+	EnableIPAMJournal             bool
+	EnableOrphanedIPReconciler    bool
+	EnableStoreChecksum           bool
+	InitializeFromCNI             bool
+	ManagedSettings               ManagedSettings
+	NCSelectionPolicy             string
+	NamespaceIPQuotas             map[string]cns.NamespaceIPQuota
+	OrphanedIPGracePeriodMs       int
+	OrphanedIPReconcileIntervalMs int
+	PoolScalingStrategy           string
+	StickyIPReservationTTLMs      int
+	StoreBackend                  string
+	Debug                         bool
+	SyncHostNCTimeoutMs           int
+	SyncHostNCVersionIntervalMs   int
+	TLSCertificatePath            string
+	TLSEndpoint                   string
+	TLSPort                       string
+	TLSSubjectName                string
+	TelemetrySettings             TelemetrySettings
+	UseHTTPS                      bool
+	WireserverIP                  string
 }
 
 type TelemetrySettings struct {
@@ -115,6 +115,28 @@ func (ipm *IPStateManager) ReleaseIPConfig(ipconfigID string) (cns.IPConfigurati
 	return ipm.AvailableIPConfigState[ipconfigID], nil
 }
 
+// MarkNCIPsAsPendingRelease marks up to the passed number of Available IPs belonging to the NC as PendingRelease.
+func (ipm *IPStateManager) MarkNCIPsAsPendingRelease(ncID string, numberOfIPsToMark int) (map[string]cns.IPConfigurationStatus, error) {
+	ipm.Lock()
+	defer ipm.Unlock()
+
+	pendingReleaseIPs := make(map[string]cns.IPConfigurationStatus)
+	remaining := []string{}
+	for _, id := range ipm.AvailableIPIDStack.items {
+		ipConfig := ipm.AvailableIPConfigState[id]
+		if ipConfig.NCID != ncID || len(pendingReleaseIPs) == numberOfIPsToMark {
+			remaining = append(remaining, id)
+			continue
+		}
+		ipConfig.SetState(types.PendingRelease)
+		pendingReleaseIPs[id] = ipConfig
+		ipm.PendingReleaseIPConfigState[id] = ipConfig
+		delete(ipm.AvailableIPConfigState, id)
+	}
+	ipm.AvailableIPIDStack.items = remaining
+	return pendingReleaseIPs, nil
+}
+
 func (ipm *IPStateManager) MarkIPAsPendingRelease(numberOfIPsToMark int) (map[string]cns.IPConfigurationStatus, error) {
 	ipm.Lock()
 	defer ipm.Unlock()
@@ -261,6 +283,10 @@ func (fake *HTTPServiceFake) MarkIPAsPendingRelease(numberToMark int) (map[strin
 	return fake.IPStateManager.MarkIPAsPendingRelease(numberToMark)
 }
 
+func (fake *HTTPServiceFake) MarkNCIPsAsPendingRelease(ncID string, numberToMark int) (map[string]cns.IPConfigurationStatus, error) {
+	return fake.IPStateManager.MarkNCIPsAsPendingRelease(ncID, numberToMark)
+}
+
 func (fake *HTTPServiceFake) GetOption(string) interface{} {
 	return nil
 }
@@ -0,0 +1,175 @@
+// Package ipamjournal durably records the Pod IP assignments made by CNS, so that
+// CNS can rebuild its IPAM state on restart without depending on the CNI.
+package ipamjournal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/pkg/errors"
+)
+
+const (
+	// assignmentsKey is the store key the journal snapshot is written under.
+	assignmentsKey = "Assignments"
+	// stickyIPsKey is the store key the sticky IP snapshot is written under.
+	stickyIPsKey = "StickyIPs"
+)
+
+// Entry is the journaled assignment of a single IP to a Pod.
+type Entry struct {
+	ID               string
+	NCID             string
+	IPAddress        string
+	InfraContainerID string
+	InterfaceID      string
+	PodName          string
+	PodNamespace     string
+}
+
+// PodInfo rebuilds the PodInfo that the IP was assigned to.
+func (e *Entry) PodInfo() cns.PodInfo {
+	return cns.NewPodInfo(e.InfraContainerID, e.InterfaceID, e.PodName, e.PodNamespace)
+}
+
+// StickyIPs is the journaled state of the IPs of Pods which opted in to sticky IPs.
+type StickyIPs struct {
+	// Assigned are the IDs of the IPConfigs assigned to sticky Pods.
+	Assigned []string
+	// Reservations are the IPConfigs held for sticky Pods after they were released, by Pod namespace/name.
+	Reservations map[string]StickyIPReservation
+}
+
+// StickyIPReservation is an IPConfig held for a sticky Pod since it was released.
+type StickyIPReservation struct {
+	ID         string
+	ReservedAt time.Time
+}
+
+// Journal records every IP assignment and unassignment to a KeyValueStore.
+// The full set of assignments is written on each transition, so the store always
+// holds a compacted snapshot which can be replayed directly on restart.
+type Journal struct {
+	sync.Mutex
+	store     store.KeyValueStore
+	entries   map[string]Entry
+	stickyIPs StickyIPs
+}
+
+// New creates a Journal backed by the passed store, loading any assignments that
+// were previously recorded in it.
+func New(s store.KeyValueStore) (*Journal, error) {
+	j := &Journal{
+		store:   s,
+		entries: map[string]Entry{},
+	}
+	if err := s.Read(assignmentsKey, &j.entries); err != nil {
+		if !errors.Is(err, store.ErrKeyNotFound) && !errors.Is(err, store.ErrStoreEmpty) {
+			return nil, errors.Wrap(err, "failed to read IPAM journal")
+		}
+		j.entries = map[string]Entry{}
+	}
+	if err := s.Read(stickyIPsKey, &j.stickyIPs); err != nil {
+		if !errors.Is(err, store.ErrKeyNotFound) && !errors.Is(err, store.ErrStoreEmpty) {
+			return nil, errors.Wrap(err, "failed to read sticky IPs from IPAM journal")
+		}
+		j.stickyIPs = StickyIPs{}
+	}
+	logger.Printf("[ipamjournal] loaded %d IP assignments and %d sticky IP reservations", len(j.entries), len(j.stickyIPs.Reservations))
+	return j, nil
+}
+
+// Assign records that the IP has been assigned to the Pod.
+func (j *Journal) Assign(ipconfig cns.IPConfigurationStatus, podInfo cns.PodInfo) error { //nolint:gocritic // ignore hugeparam
+	j.Lock()
+	defer j.Unlock()
+	prev, existed := j.entries[ipconfig.IPAddress]
+	j.entries[ipconfig.IPAddress] = Entry{
+		ID:               ipconfig.ID,
+		NCID:             ipconfig.NCID,
+		IPAddress:        ipconfig.IPAddress,
+		InfraContainerID: podInfo.InfraContainerID(),
+		InterfaceID:      podInfo.InterfaceID(),
+		PodName:          podInfo.Name(),
+		PodNamespace:     podInfo.Namespace(),
+	}
+	if err := j.flush(); err != nil {
+		if existed {
+			j.entries[ipconfig.IPAddress] = prev
+		} else {
+			delete(j.entries, ipconfig.IPAddress)
+		}
+		return err
+	}
+	return nil
+}
+
+// Unassign records that the IP is no longer assigned to a Pod.
+func (j *Journal) Unassign(ipconfig cns.IPConfigurationStatus) error { //nolint:gocritic // ignore hugeparam
+	j.Lock()
+	defer j.Unlock()
+	prev, existed := j.entries[ipconfig.IPAddress]
+	if !existed {
+		return nil
+	}
+	delete(j.entries, ipconfig.IPAddress)
+	if err := j.flush(); err != nil {
+		j.entries[ipconfig.IPAddress] = prev
+		return err
+	}
+	return nil
+}
+
+func (j *Journal) flush() error {
+	return errors.Wrap(j.store.Write(assignmentsKey, j.entries), "failed to write IPAM journal")
+}
+
+// SetStickyIPs records the state of the sticky IPs, replacing the previously recorded state.
+func (j *Journal) SetStickyIPs(stickyIPs StickyIPs) error {
+	j.Lock()
+	defer j.Unlock()
+	if err := j.store.Write(stickyIPsKey, &stickyIPs); err != nil {
+		return errors.Wrap(err, "failed to write sticky IPs to IPAM journal")
+	}
+	j.stickyIPs = stickyIPs
+	return nil
+}
+
+// StickyIPs returns a copy of the journaled state of the sticky IPs.
+func (j *Journal) StickyIPs() StickyIPs {
+	j.Lock()
+	defer j.Unlock()
+	stickyIPs := StickyIPs{
+		Assigned:     append([]string(nil), j.stickyIPs.Assigned...),
+		Reservations: make(map[string]StickyIPReservation, len(j.stickyIPs.Reservations)),
+	}
+	for k, v := range j.stickyIPs.Reservations {
+		stickyIPs.Reservations[k] = v
+	}
+	return stickyIPs
+}
+
+// Entries returns a copy of the journaled assignments by IP.
+func (j *Journal) Entries() map[string]Entry {
+	j.Lock()
+	defer j.Unlock()
+	entries := make(map[string]Entry, len(j.entries))
+	for k, v := range j.entries {
+		entries[k] = v
+	}
+	return entries
+}
+
+// PodInfoByIP implements cns.PodInfoByIPProvider from the journaled assignments.
+func (j *Journal) PodInfoByIP() (map[string]cns.PodInfo, error) {
+	entries := j.Entries()
+	podInfoByIP := make(map[string]cns.PodInfo, len(entries))
+	for ip := range entries {
+		e := entries[ip]
+		podInfoByIP[ip] = e.PodInfo()
+	}
+	return podInfoByIP, nil
+}
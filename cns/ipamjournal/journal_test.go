@@ -0,0 +1,125 @@
+package ipamjournal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	logger.InitLogger("testlogs", 0, 0, "./")
+}
+
+var (
+	testPod1 = cns.NewPodInfo("infra1", "iface1", "pod1", "default")
+	testPod2 = cns.NewPodInfo("infra2", "iface2", "pod2", "default")
+	testPod3 = cns.NewPodInfo("infra3", "iface3", "pod3", "default")
+	testIP1  = cns.IPConfigurationStatus{ID: "id1", NCID: "nc", IPAddress: "10.0.0.1"}
+	testIP2  = cns.IPConfigurationStatus{ID: "id2", NCID: "nc", IPAddress: "10.0.0.2"}
+)
+
+func newTestJournal(t *testing.T, path string) *Journal {
+	s, err := store.NewJsonFileStore(path, nil)
+	require.NoError(t, err)
+	j, err := New(s)
+	require.NoError(t, err)
+	return j
+}
+
+func TestJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+	j := newTestJournal(t, path)
+	require.NoError(t, j.Assign(testIP1, testPod1))
+	require.NoError(t, j.Assign(testIP2, testPod2))
+	require.NoError(t, j.Unassign(testIP1))
+	// unassigning an IP that is not journaled is a noop.
+	require.NoError(t, j.Unassign(testIP1))
+
+	// a new journal on the same store replays the assignments.
+	replayed := newTestJournal(t, path)
+	got, err := replayed.PodInfoByIP()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]cns.PodInfo{testIP2.IPAddress: testPod2}, got)
+	assert.Equal(t, "nc", replayed.Entries()[testIP2.IPAddress].NCID)
+}
+
+func TestJournalReplaysStickyIPs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+	j := newTestJournal(t, path)
+	assert.Empty(t, j.StickyIPs().Reservations)
+
+	stickyIPs := StickyIPs{
+		Assigned:     []string{testIP1.ID},
+		Reservations: map[string]StickyIPReservation{"default/pod2": {ID: testIP2.ID, ReservedAt: time.Unix(1000, 0).UTC()}},
+	}
+	require.NoError(t, j.SetStickyIPs(stickyIPs))
+	require.NoError(t, j.Assign(testIP1, testPod1))
+
+	replayed := newTestJournal(t, path)
+	assert.Equal(t, stickyIPs, replayed.StickyIPs())
+	assert.Len(t, replayed.Entries(), 1)
+}
+
+func TestCrossCheck(t *testing.T) {
+	journal := map[string]cns.PodInfo{
+		"10.0.0.1": testPod1,
+		"10.0.0.2": testPod2,
+	}
+	check := map[string]cns.PodInfo{
+		"10.0.0.2": testPod3,
+		"10.0.0.3": testPod3,
+	}
+	drift := CrossCheck(journal, check)
+	assert.ElementsMatch(t, []Drift{
+		{Kind: MissingFromCheck, IP: "10.0.0.1", Journal: testPod1},
+		{Kind: PodMismatch, IP: "10.0.0.2", Journal: testPod2, Check: testPod3},
+		{Kind: MissingFromJournal, IP: "10.0.0.3", Check: testPod3},
+	}, drift)
+	assert.Empty(t, CrossCheck(journal, journal))
+}
+
+func TestRecoveryPodInfoProvider(t *testing.T) {
+	j := newTestJournal(t, filepath.Join(t.TempDir(), "journal.json"))
+	check := map[string]cns.PodInfo{
+		"10.0.0.2": testPod3,
+		"10.0.0.3": testPod3,
+	}
+	okProvider := cns.PodInfoByIPProviderFunc(func() (map[string]cns.PodInfo, error) { return check, nil })
+	errProvider := cns.PodInfoByIPProviderFunc(func() (map[string]cns.PodInfo, error) {
+		return nil, errors.Wrap(cns.ErrDuplicateIP, "10.0.0.2")
+	})
+
+	// an empty journal falls back to the provider.
+	got, err := NewRecoveryPodInfoProvider(j, okProvider).PodInfoByIP()
+	require.NoError(t, err)
+	assert.Equal(t, check, got)
+	_, err = NewRecoveryPodInfoProvider(j, errProvider).PodInfoByIP()
+	require.ErrorIs(t, err, cns.ErrDuplicateIP)
+
+	require.NoError(t, j.Assign(testIP1, testPod1))
+	require.NoError(t, j.Assign(testIP2, testPod2))
+
+	// the journal wins on mismatches, and IPs only known to the provider are kept.
+	got, err = NewRecoveryPodInfoProvider(j, okProvider).PodInfoByIP()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]cns.PodInfo{
+		"10.0.0.1": testPod1,
+		"10.0.0.2": testPod2,
+		"10.0.0.3": testPod3,
+	}, got)
+
+	// a failed cross-check does not fail recovery.
+	got, err = NewRecoveryPodInfoProvider(j, errProvider).PodInfoByIP()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]cns.PodInfo{
+		"10.0.0.1": testPod1,
+		"10.0.0.2": testPod2,
+	}, got)
+}
@@ -0,0 +1,30 @@
+package ipamjournal
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var recoveryDrift = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "ipam_journal_recovery_drift",
+		Help: "IPs on which the IPAM journal disagreed with the cross-checked state at recovery.",
+	},
+	[]string{"kind"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		recoveryDrift,
+	)
+}
+
+func observeDrift(drift []Drift) {
+	counts := map[DriftKind]float64{MissingFromJournal: 0, MissingFromCheck: 0, PodMismatch: 0}
+	for i := range drift {
+		counts[drift[i].Kind]++
+	}
+	for k, v := range counts {
+		recoveryDrift.WithLabelValues(string(k)).Set(v)
+	}
+}
@@ -0,0 +1,83 @@
+package ipamjournal
+
+import (
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/pkg/errors"
+)
+
+// DriftKind describes how the journal and the cross-checked state disagree about an IP.
+type DriftKind string
+
+const (
+	// MissingFromJournal is an IP which is assigned in the cross-checked state but not in the journal.
+	MissingFromJournal DriftKind = "MissingFromJournal"
+	// MissingFromCheck is an IP which is assigned in the journal but not in the cross-checked state.
+	MissingFromCheck DriftKind = "MissingFromCheck"
+	// PodMismatch is an IP which is assigned to different Pods in the journal and the cross-checked state.
+	PodMismatch DriftKind = "PodMismatch"
+)
+
+// Drift is a single disagreement between the journal and the cross-checked state.
+type Drift struct {
+	Kind    DriftKind
+	IP      string
+	Journal cns.PodInfo
+	Check   cns.PodInfo
+}
+
+// CrossCheck compares the journaled assignments to the assignments from another source and
+// returns every IP they disagree on.
+func CrossCheck(journal, check map[string]cns.PodInfo) []Drift {
+	drift := []Drift{}
+	for ip, j := range journal {
+		c, ok := check[ip]
+		if !ok {
+			drift = append(drift, Drift{Kind: MissingFromCheck, IP: ip, Journal: j})
+			continue
+		}
+		if j.Name() != c.Name() || j.Namespace() != c.Namespace() {
+			drift = append(drift, Drift{Kind: PodMismatch, IP: ip, Journal: j, Check: c})
+		}
+	}
+	for ip, c := range check {
+		if _, ok := journal[ip]; !ok {
+			drift = append(drift, Drift{Kind: MissingFromJournal, IP: ip, Check: c})
+		}
+	}
+	return drift
+}
+
+// NewRecoveryPodInfoProvider returns a cns.PodInfoByIPProvider which replays the Journal and
+// cross-checks it against the passed provider, such as the CNI state.
+// The journal is authoritative: drift is reported instead of failing, and IPs which are only
+// known to the cross-checked provider are added so that they are not handed out twice.
+// If the cross-check fails, including on duplicate IPs, the journal alone is used.
+// If the journal is empty, as it is when it is first enabled, the provider is used as is.
+func NewRecoveryPodInfoProvider(j *Journal, check cns.PodInfoByIPProvider) cns.PodInfoByIPProvider {
+	return cns.PodInfoByIPProviderFunc(func() (map[string]cns.PodInfo, error) {
+		podInfoByIP, _ := j.PodInfoByIP()
+		checked, err := check.PodInfoByIP()
+		if err != nil {
+			if len(podInfoByIP) == 0 {
+				return nil, errors.Wrap(err, "IPAM journal is empty and cross-check provider failed")
+			}
+			logger.Errorf("[ipamjournal] drift: failed to cross-check %d journaled IPs, using journal: %v", len(podInfoByIP), err)
+			return podInfoByIP, nil
+		}
+		if len(podInfoByIP) == 0 {
+			logger.Printf("[ipamjournal] journal is empty, recovering %d IPs from cross-check provider", len(checked))
+			return checked, nil
+		}
+		drift := CrossCheck(podInfoByIP, checked)
+		for i := range drift {
+			logger.Errorf("[ipamjournal] drift: %s IP %s journal %+v check %+v", drift[i].Kind, drift[i].IP, drift[i].Journal, drift[i].Check)
+			if drift[i].Kind == MissingFromJournal {
+				podInfoByIP[drift[i].IP] = drift[i].Check
+			}
+		}
+		observeDrift(drift)
+		logger.Printf("[ipamjournal] recovered %d IPs with %d drifted", len(podInfoByIP), len(drift))
+		return podInfoByIP, nil
+	})
+}
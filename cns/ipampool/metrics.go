@@ -9,6 +9,8 @@ const (
 	subnetLabel              = "subnet"
 	subnetCIDRLabel          = "subnet_cidr"
 	podnetARMIDLabel         = "podnet_arm_id"
+	strategyLabel            = "strategy"
+	decisionLabel            = "decision"
 	customerMetricLabel      = "customer_metric"
 	customerMetricLabelValue = "customer metric"
 )
@@ -94,6 +96,33 @@ var (
 		},
 		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel},
 	)
+	ipamStrategyMinFreeIPCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ipam_strategy_min_free_ips",
+			Help: "Minimum free IP count chosen by the pool scaling strategy.",
+		},
+		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel, strategyLabel},
+	)
+	ipamStrategyMaxFreeIPCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ipam_strategy_max_free_ips",
+			Help: "Maximum free IP count chosen by the pool scaling strategy.",
+		},
+		[]string{subnetLabel, subnetCIDRLabel, podnetARMIDLabel, strategyLabel},
+	)
+	ipamScalingDecisions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ipam_scaling_decisions_total",
+			Help: "Count of pool scaling decisions by strategy and decision.",
+		},
+		[]string{strategyLabel, decisionLabel},
+	)
+	ipamAssignmentRate = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ipam_ip_assignment_rate",
+			Help: "Recent Pod IP assignment rate per second observed by the rate scaling strategy.",
+		},
+	)
 )
 
 func init() {
@@ -108,6 +137,10 @@ func init() {
 		ipamPendingReleaseIPCount,
 		ipamRequestedIPConfigCount,
 		ipamTotalIPCount,
+		ipamStrategyMinFreeIPCount,
+		ipamStrategyMaxFreeIPCount,
+		ipamScalingDecisions,
+		ipamAssignmentRate,
 	)
 }
 
@@ -123,3 +156,17 @@ func observeIPPoolState(state ipPoolState, meta metaState, labels []string) {
 	ipamRequestedIPConfigCount.WithLabelValues(labels...).Set(float64(state.requestedIPs))
 	ipamTotalIPCount.WithLabelValues(labels...).Set(float64(state.totalIPs))
 }
+
+func observeWatermarks(strategy string, w Watermarks, labels []string) {
+	labels = append(labels[:len(labels):len(labels)], strategy)
+	ipamStrategyMinFreeIPCount.WithLabelValues(labels...).Set(float64(w.MinFree))
+	ipamStrategyMaxFreeIPCount.WithLabelValues(labels...).Set(float64(w.MaxFree))
+}
+
+func observeScalingDecision(strategy, decision string) {
+	ipamScalingDecisions.WithLabelValues(strategy, decision).Inc()
+}
+
+func observeAssignmentRate(rate float64) {
+	ipamAssignmentRate.Set(rate)
+}
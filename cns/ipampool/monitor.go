@@ -29,16 +29,33 @@ type nodeNetworkConfigSpecUpdater interface {
 
 // metaState is the Monitor's configuration state for the IP pool.
 type metaState struct {
-	batch         int64
-	max           int64
-	maxFreeCount  int64
-	minFreeCount  int64
+	batch        int64
+	max          int64
+	maxFreeCount int64
+	minFreeCount int64
+}
+
+// ncPool is the Monitor's state for the IP pool of a single NetworkContainer.
+// When the Node has only one NetworkContainer, the pool has an empty id and
+// covers all of the IPs in CNS and the top level NodeNetworkConfigSpec counts.
+type ncPool struct {
+	id          string
+	subnet      string
+	subnetCIDR  string
+	subnetARMID string
+	// notInUseCount caches the count of IPs marked as PendingRelease in this pool until the Spec is updated.
 	notInUseCount int64
 }
 
+func (p *ncPool) labels() []string {
+	return []string{p.subnet, p.subnetCIDR, p.subnetARMID}
+}
+
 type Options struct {
 	RefreshDelay time.Duration
 	MaxIPs       int64
+	// Strategy decides the pool scaling Watermarks. Defaults to the ThresholdStrategy.
+	Strategy Strategy
 }
 
 type Monitor struct {
@@ -50,11 +67,9 @@ type Monitor struct {
 	started     chan interface{}
 	nncSource   chan v1alpha.NodeNetworkConfig
 	once        sync.Once
+	pools       []*ncPool
 }
 
-// Global Variables for Subnet, Subnet Address Space and Subnet ARM ID
-var subnet, subnetCIDR, subnetARMID string
-
 func NewMonitor(httpService cns.HTTPService, nnccli nodeNetworkConfigSpecUpdater, opts *Options) *Monitor {
 	if opts.RefreshDelay < 1 {
 		opts.RefreshDelay = DefaultRefreshDelay
@@ -62,12 +77,16 @@ func NewMonitor(httpService cns.HTTPService, nnccli nodeNetworkConfigSpecUpdater
 	if opts.MaxIPs < 1 {
 		opts.MaxIPs = DefaultMaxIPs
 	}
+	if opts.Strategy == nil {
+		opts.Strategy = ThresholdStrategy{}
+	}
 	return &Monitor{
 		opts:        opts,
 		httpService: httpService,
 		nnccli:      nnccli,
 		started:     make(chan interface{}),
 		nncSource:   make(chan v1alpha.NodeNetworkConfig),
+		pools:       []*ncPool{{}},
 	}
 }
 
@@ -95,11 +114,7 @@ func (pm *Monitor) Start(ctx context.Context) error {
 			}
 		case nnc := <-pm.nncSource: // received a new NodeNetworkConfig, extract the data from it and re-reconcile.
 			scaler := nnc.Status.Scaler
-
-			// Set SubnetName, SubnetAddressSpace and Pod Network ARM ID values to the global subnet, subnetCIDR and subnetARM variables.
-			subnet = nnc.Status.NetworkContainers[0].SubnetName
-			subnetCIDR = nnc.Status.NetworkContainers[0].SubnetAddressSpace
-			subnetARMID = GenerateARMID(&nnc.Status.NetworkContainers[0])
+			pm.pools = buildPools(nnc.Status.NetworkContainers, pm.pools)
 
 			pm.metastate.batch = scaler.BatchSize
 			pm.metastate.max = scaler.MaxIPCount
@@ -109,6 +124,7 @@ func (pm *Monitor) Start(ctx context.Context) error {
 				logger.Printf("[ipam-pool-monitor] set initial pool spec %+v", pm.spec)
 				close(pm.started) // close the init channel the first time we fully receive a NodeNetworkConfig.
 			})
+			pm.seedNetworkContainerSpecs(nnc.Status.NetworkContainers)
 		}
 		// if control has flowed through the select(s) to this point, we can now reconcile.
 		err := pm.reconcile(ctx)
@@ -118,6 +134,122 @@ func (pm *Monitor) Start(ctx context.Context) error {
 	}
 }
 
+// buildPools creates an ncPool for each of the passed NetworkContainers, carrying over the cached
+// state of any pools that already existed. If there is only a single NetworkContainer, a single
+// pool without an id is returned which tracks the top level NodeNetworkConfigSpec.
+func buildPools(ncs []v1alpha.NetworkContainer, existing []*ncPool) []*ncPool {
+	cached := make(map[string]*ncPool, len(existing))
+	for _, p := range existing {
+		cached[p.id] = p
+	}
+	pools := make([]*ncPool, 0, len(ncs))
+	for i := range ncs {
+		id := ncs[i].ID
+		if len(ncs) == 1 {
+			id = ""
+		}
+		p := &ncPool{id: id}
+		if c, ok := cached[id]; ok {
+			p.notInUseCount = c.notInUseCount
+		}
+		p.subnet = ncs[i].SubnetName
+		p.subnetCIDR = ncs[i].SubnetAddressSpace
+		p.subnetARMID = GenerateARMID(&ncs[i])
+		pools = append(pools, p)
+	}
+	if len(pools) == 0 {
+		pools = append(pools, &ncPool{})
+	}
+	return pools
+}
+
+// seedNetworkContainerSpecs makes sure that the cached spec has exactly one entry for every NetworkContainer
+// when there are multiple. An NC which does not have a spec yet is seeded with its current IP count.
+func (pm *Monitor) seedNetworkContainerSpecs(ncs []v1alpha.NetworkContainer) {
+	if len(ncs) < 2 { //nolint:gomnd // only used for multiple NCs
+		pm.spec.NetworkContainers = nil
+		return
+	}
+	ncSpecs := make([]v1alpha.NetworkContainerSpec, 0, len(ncs))
+	for i := range ncs {
+		if j := ncSpecIndex(&pm.spec, ncs[i].ID); j >= 0 {
+			ncSpecs = append(ncSpecs, pm.spec.NetworkContainers[j])
+			continue
+		}
+		ncSpecs = append(ncSpecs, v1alpha.NetworkContainerSpec{
+			ID:               ncs[i].ID,
+			RequestedIPCount: int64(len(ncs[i].IPAssignments)),
+		})
+	}
+	pm.spec.NetworkContainers = ncSpecs
+	pm.spec.RequestedIPCount = 0
+	for i := range pm.spec.NetworkContainers {
+		pm.spec.RequestedIPCount += pm.spec.NetworkContainers[i].RequestedIPCount
+	}
+}
+
+// ncSpecIndex returns the index of the NetworkContainerSpec for the passed NC ID, or -1.
+func ncSpecIndex(spec *v1alpha.NodeNetworkConfigSpec, id string) int {
+	for i := range spec.NetworkContainers {
+		if spec.NetworkContainers[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// requestedIPCount returns the requested IP count of the pool in the passed spec.
+func requestedIPCount(spec *v1alpha.NodeNetworkConfigSpec, id string) int64 {
+	if id == "" {
+		return spec.RequestedIPCount
+	}
+	if i := ncSpecIndex(spec, id); i >= 0 {
+		return spec.NetworkContainers[i].RequestedIPCount
+	}
+	return 0
+}
+
+// setRequestedIPCount sets the requested IP count of the pool in the passed spec and
+// keeps the total requested IP count in sync.
+func setRequestedIPCount(spec *v1alpha.NodeNetworkConfigSpec, id string, count int64) {
+	if id == "" {
+		spec.RequestedIPCount = count
+		return
+	}
+	i := ncSpecIndex(spec, id)
+	if i < 0 {
+		spec.NetworkContainers = append(spec.NetworkContainers, v1alpha.NetworkContainerSpec{ID: id})
+		i = len(spec.NetworkContainers) - 1
+	}
+	spec.RequestedIPCount += count - spec.NetworkContainers[i].RequestedIPCount
+	spec.NetworkContainers[i].RequestedIPCount = count
+}
+
+// ipsNotInUse returns the IPs not in use of the pool in the passed spec.
+func ipsNotInUse(spec *v1alpha.NodeNetworkConfigSpec, id string) []string {
+	if id == "" {
+		return spec.IPsNotInUse
+	}
+	if i := ncSpecIndex(spec, id); i >= 0 {
+		return spec.NetworkContainers[i].IPsNotInUse
+	}
+	return nil
+}
+
+// ipsForPool returns the subset of the passed IPs which belong to the pool.
+func ipsForPool(ips map[string]cns.IPConfigurationStatus, id string) map[string]cns.IPConfigurationStatus {
+	if id == "" {
+		return ips
+	}
+	filtered := map[string]cns.IPConfigurationStatus{}
+	for k := range ips {
+		if ips[k].NCID == id {
+			filtered[k] = ips[k]
+		}
+	}
+	return filtered
+}
+
 // ipPoolState is the current actual state of the CNS IP pool.
 type ipPoolState struct {
 	// allocatedToPods are the IPs CNS gives to Pods.
@@ -138,10 +270,11 @@ type ipPoolState struct {
 	totalIPs int64
 }
 
-func buildIPPoolState(ips map[string]cns.IPConfigurationStatus, spec v1alpha.NodeNetworkConfigSpec) ipPoolState {
+func buildIPPoolState(ips map[string]cns.IPConfigurationStatus, spec v1alpha.NodeNetworkConfigSpec, id string) ipPoolState {
+	ips = ipsForPool(ips, id)
 	state := ipPoolState{
 		totalIPs:     int64(len(ips)),
-		requestedIPs: spec.RequestedIPCount,
+		requestedIPs: requestedIPCount(&spec, id),
 	}
 	for _, v := range ips {
 		switch v.GetState() {
@@ -160,31 +293,56 @@ func buildIPPoolState(ips map[string]cns.IPConfigurationStatus, spec v1alpha.Nod
 	return state
 }
 
+// reconcile reconciles each of the NetworkContainer IP pools independently.
 func (pm *Monitor) reconcile(ctx context.Context) error {
 	allocatedIPs := pm.httpService.GetPodIPConfigState()
-	state := buildIPPoolState(allocatedIPs, pm.spec)
-	logger.Printf("ipam-pool-monitor state %+v", state)
-	observeIPPoolState(state, pm.metastate, []string{subnet, subnetCIDR, subnetARMID})
+	var errs []error
+	for _, p := range pm.pools {
+		if err := pm.reconcilePool(ctx, p, allocatedIPs); err != nil {
+			logger.Errorf("[ipam-pool-monitor] failed to reconcile pool for NC %q: %v", p.id, err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Wrapf(errs[0], "failed to reconcile %d of %d pools", len(errs), len(pm.pools))
+	}
+	return nil
+}
+
+func (pm *Monitor) reconcilePool(ctx context.Context, p *ncPool, allocatedIPs map[string]cns.IPConfigurationStatus) error {
+	state := buildIPPoolState(allocatedIPs, pm.spec, p.id)
+	logger.Printf("ipam-pool-monitor NC %q state %+v", p.id, state)
+	labels := p.labels()
+	observeIPPoolState(state, pm.metastate, labels)
+
+	strategy := pm.opts.Strategy.Name()
+	watermarks := pm.opts.Strategy.Watermarks(pm.metastate.batch, pm.metastate.max, Watermarks{
+		MinFree: pm.metastate.minFreeCount,
+		MaxFree: pm.metastate.maxFreeCount,
+	})
+	observeWatermarks(strategy, watermarks, labels)
 
 	switch {
 	// pod count is increasing
-	case state.expectedAvailableIPs < pm.metastate.minFreeCount:
-		if state.requestedIPs == pm.metastate.max {
+	case state.expectedAvailableIPs < watermarks.MinFree:
+		if pm.spec.RequestedIPCount == pm.metastate.max {
 			// If we're already at the maxIPCount, don't try to increase
 			return nil
 		}
 
-		logger.Printf("[ipam-pool-monitor] Increasing pool size...")
-		return pm.increasePoolSize(ctx, state)
+		logger.Printf("[ipam-pool-monitor] Increasing pool size, %s strategy watermarks %+v...", strategy, watermarks)
+		observeScalingDecision(strategy, "increase")
+		return pm.increasePoolSize(ctx, p, state)
 
 	// pod count is decreasing
-	case state.currentAvailableIPs >= pm.metastate.maxFreeCount:
-		logger.Printf("[ipam-pool-monitor] Decreasing pool size...")
-		return pm.decreasePoolSize(ctx, state)
+	case state.currentAvailableIPs >= watermarks.MaxFree:
+		logger.Printf("[ipam-pool-monitor] Decreasing pool size, %s strategy watermarks %+v...", strategy, watermarks)
+		observeScalingDecision(strategy, "decrease")
+		return pm.decreasePoolSize(ctx, p, state)
 
 	// CRD has reconciled CNS state, and target spec is now the same size as the state
 	// free to remove the IPs from the CRD
-	case int64(len(pm.spec.IPsNotInUse)) != state.pendingRelease:
+	case int64(len(ipsNotInUse(&pm.spec, p.id))) != state.pendingRelease:
 		logger.Printf("[ipam-pool-monitor] Removing Pending Release IPs from CRD...")
 		return pm.cleanPendingRelease(ctx)
 
@@ -197,25 +355,28 @@ func (pm *Monitor) reconcile(ctx context.Context) error {
 	return nil
 }
 
-func (pm *Monitor) increasePoolSize(ctx context.Context, state ipPoolState) error {
+func (pm *Monitor) increasePoolSize(ctx context.Context, p *ncPool, state ipPoolState) error {
 	tempNNCSpec := pm.createNNCSpecForCRD()
 
 	// Query the max IP count
-	previouslyRequestedIPCount := tempNNCSpec.RequestedIPCount
+	previouslyRequestedIPCount := requestedIPCount(&tempNNCSpec, p.id)
 	batchSize := pm.metastate.batch
 
-	tempNNCSpec.RequestedIPCount += batchSize
-	if tempNNCSpec.RequestedIPCount > pm.metastate.max {
+	// the max IP count is for the whole Node, so the other pools' IPs count against it
+	maxIPCount := pm.metastate.max - (tempNNCSpec.RequestedIPCount - previouslyRequestedIPCount)
+	updatedRequestedIPCount := previouslyRequestedIPCount + batchSize
+	if updatedRequestedIPCount > maxIPCount {
 		// We don't want to ask for more ips than the max
-		logger.Printf("[ipam-pool-monitor] Requested IP count (%d) is over max limit (%d), requesting max limit instead.", tempNNCSpec.RequestedIPCount, pm.metastate.max)
-		tempNNCSpec.RequestedIPCount = pm.metastate.max
+		logger.Printf("[ipam-pool-monitor] Requested IP count (%d) is over max limit (%d), requesting max limit instead.", updatedRequestedIPCount, maxIPCount)
+		updatedRequestedIPCount = maxIPCount
 	}
 
 	// If the requested IP count is same as before, then don't do anything
-	if tempNNCSpec.RequestedIPCount == previouslyRequestedIPCount {
-		logger.Printf("[ipam-pool-monitor] Previously requested IP count %d is same as updated IP count %d, doing nothing", previouslyRequestedIPCount, tempNNCSpec.RequestedIPCount)
+	if updatedRequestedIPCount <= previouslyRequestedIPCount {
+		logger.Printf("[ipam-pool-monitor] Previously requested IP count %d is same as updated IP count %d, doing nothing", previouslyRequestedIPCount, updatedRequestedIPCount)
 		return nil
 	}
+	setRequestedIPCount(&tempNNCSpec, p.id, updatedRequestedIPCount)
 
 	logger.Printf("[ipam-pool-monitor] Increasing pool size, pool %+v, spec %+v", state, tempNNCSpec)
 
@@ -232,14 +393,14 @@ func (pm *Monitor) increasePoolSize(ctx context.Context, state ipPoolState) erro
 	return nil
 }
 
-func (pm *Monitor) decreasePoolSize(ctx context.Context, state ipPoolState) error {
+func (pm *Monitor) decreasePoolSize(ctx context.Context, p *ncPool, state ipPoolState) error {
 	// mark n number of IPs as pending
 	var newIpsMarkedAsPending bool
 	var pendingIPAddresses map[string]cns.IPConfigurationStatus
 	var updatedRequestedIPCount int64
 
 	// Ensure the updated requested IP count is a multiple of the batch size
-	previouslyRequestedIPCount := pm.spec.RequestedIPCount
+	previouslyRequestedIPCount := requestedIPCount(&pm.spec, p.id)
 	batchSize := pm.metastate.batch
 	modResult := previouslyRequestedIPCount % batchSize
 
@@ -260,10 +421,15 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, state ipPoolState) erro
 
 	logger.Printf("[ipam-pool-monitor] updatedRequestedIPCount %d", updatedRequestedIPCount)
 
-	if pm.metastate.notInUseCount == 0 || pm.metastate.notInUseCount < state.pendingRelease {
+	if p.notInUseCount == 0 || p.notInUseCount < state.pendingRelease {
 		logger.Printf("[ipam-pool-monitor] Marking IPs as PendingRelease, ipsToBeReleasedCount %d", decreaseIPCountBy)
 		var err error
-		if pendingIPAddresses, err = pm.httpService.MarkIPAsPendingRelease(int(decreaseIPCountBy)); err != nil {
+		if p.id == "" {
+			pendingIPAddresses, err = pm.httpService.MarkIPAsPendingRelease(int(decreaseIPCountBy))
+		} else {
+			pendingIPAddresses, err = pm.httpService.MarkNCIPsAsPendingRelease(p.id, int(decreaseIPCountBy))
+		}
+		if err != nil {
 			return err
 		}
 
@@ -274,13 +440,13 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, state ipPoolState) erro
 
 	if newIpsMarkedAsPending {
 		// cache the updatingPendingRelease so that we dont re-set new IPs to PendingRelease in case UpdateCRD call fails
-		pm.metastate.notInUseCount = int64(len(tempNNCSpec.IPsNotInUse))
+		p.notInUseCount = int64(len(ipsNotInUse(&tempNNCSpec, p.id)))
 	}
 
 	logger.Printf("[ipam-pool-monitor] Releasing IPCount in this batch %d, updatingPendingIpsNotInUse count %d",
-		len(pendingIPAddresses), pm.metastate.notInUseCount)
+		len(pendingIPAddresses), p.notInUseCount)
 
-	tempNNCSpec.RequestedIPCount -= int64(len(pendingIPAddresses))
+	setRequestedIPCount(&tempNNCSpec, p.id, requestedIPCount(&tempNNCSpec, p.id)-int64(len(pendingIPAddresses)))
 	logger.Printf("[ipam-pool-monitor] Decreasing pool size, pool %+v, spec %+v", state, tempNNCSpec)
 
 	_, err := pm.nnccli.UpdateSpec(ctx, &tempNNCSpec)
@@ -297,8 +463,8 @@ func (pm *Monitor) decreasePoolSize(ctx context.Context, state ipPoolState) erro
 	pm.spec = tempNNCSpec
 
 	// clear the updatingPendingIpsNotInUse, as we have Updated the CRD
-	logger.Printf("[ipam-pool-monitor] cleaning the updatingPendingIpsNotInUse, existing length %d", pm.metastate.notInUseCount)
-	pm.metastate.notInUseCount = 0
+	logger.Printf("[ipam-pool-monitor] cleaning the updatingPendingIpsNotInUse, existing length %d", p.notInUseCount)
+	p.notInUseCount = 0
 
 	return nil
 }
@@ -334,16 +500,34 @@ func (pm *Monitor) createNNCSpecForCRD() v1alpha.NodeNetworkConfigSpec {
 		spec.IPsNotInUse = append(spec.IPsNotInUse, pendingIP.ID)
 	}
 
+	// If there are multiple NCs, populate the per NC counts and Pending IPs as well.
+	for i := range pm.spec.NetworkContainers {
+		ncSpec := v1alpha.NetworkContainerSpec{
+			ID:               pm.spec.NetworkContainers[i].ID,
+			RequestedIPCount: pm.spec.NetworkContainers[i].RequestedIPCount,
+		}
+		for _, pendingIP := range pendingIPs {
+			if pendingIP.NCID == ncSpec.ID {
+				ncSpec.IPsNotInUse = append(ncSpec.IPsNotInUse, pendingIP.ID)
+			}
+		}
+		spec.NetworkContainers = append(spec.NetworkContainers, ncSpec)
+	}
+
 	return spec
 }
 
 // GetStateSnapshot gets a snapshot of the IPAMPoolMonitor struct.
 func (pm *Monitor) GetStateSnapshot() cns.IpamPoolMonitorStateSnapshot {
 	spec, state := pm.spec, pm.metastate
+	var notInUseCount int64
+	for _, p := range pm.pools {
+		notInUseCount += p.notInUseCount
+	}
 	return cns.IpamPoolMonitorStateSnapshot{
 		MinimumFreeIps:           state.minFreeCount,
 		MaximumFreeIps:           state.maxFreeCount,
-		UpdatingIpsNotInUseCount: state.notInUseCount,
+		UpdatingIpsNotInUseCount: notInUseCount,
 		CachedNNC: v1alpha.NodeNetworkConfig{
 			Spec: spec,
 		},
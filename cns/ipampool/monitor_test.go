@@ -492,3 +492,31 @@ func TestCalculateIPs(t *testing.T) {
 		})
 	}
 }
+
+func TestSeedNetworkContainerSpecs(t *testing.T) {
+	ncs := []v1alpha.NetworkContainer{
+		{ID: "nc1", IPAssignments: make([]v1alpha.IPAssignment, 10)},
+		{ID: "nc2", IPAssignments: make([]v1alpha.IPAssignment, 16)},
+	}
+	pm := NewMonitor(nil, nil, &Options{})
+	pm.spec.NetworkContainers = []v1alpha.NetworkContainerSpec{{ID: "nc2", RequestedIPCount: 32}}
+
+	pm.pools = buildPools(ncs, pm.pools)
+	pm.seedNetworkContainerSpecs(ncs)
+	assert.Len(t, pm.pools, 2)
+	assert.Equal(t, []v1alpha.NetworkContainerSpec{
+		{ID: "nc1", RequestedIPCount: 10},
+		{ID: "nc2", RequestedIPCount: 32},
+	}, pm.spec.NetworkContainers)
+	assert.Equal(t, int64(42), pm.spec.RequestedIPCount)
+
+	setRequestedIPCount(&pm.spec, "nc1", 20)
+	assert.Equal(t, int64(20), requestedIPCount(&pm.spec, "nc1"))
+	assert.Equal(t, int64(52), pm.spec.RequestedIPCount)
+
+	// a single NC uses the top level spec.
+	pm.pools = buildPools(ncs[:1], pm.pools)
+	pm.seedNetworkContainerSpecs(ncs[:1])
+	assert.Equal(t, []*ncPool{{subnetARMID: GenerateARMID(&ncs[0])}}, pm.pools)
+	assert.Nil(t, pm.spec.NetworkContainers)
+}
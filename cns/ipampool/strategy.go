@@ -0,0 +1,155 @@
+package ipampool
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+)
+
+const (
+	// ThresholdStrategyName is the name of the default watermark based Strategy.
+	ThresholdStrategyName = "threshold"
+	// RateStrategyName is the name of the assignment rate predicting Strategy.
+	RateStrategyName = "rate"
+	// DefaultRateWindow is the default window over which the RateStrategy measures the IP assignment rate.
+	DefaultRateWindow = 1 * time.Minute
+	// DefaultRateLookahead is the default duration of IP demand the RateStrategy will try to keep free.
+	// This should approximate the time it takes DNC to honor a pool scale up.
+	DefaultRateLookahead = 30 * time.Second
+)
+
+// Watermarks are the free IP counts that the Monitor scales the pool between.
+// When the expected free IPs fall below MinFree the pool is scaled up, and when
+// the current free IPs reach MaxFree the pool is scaled down.
+type Watermarks struct {
+	MinFree int64
+	MaxFree int64
+}
+
+// Strategy decides the Watermarks that the Monitor reconciles the pool against.
+type Strategy interface {
+	// Name is used to identify the Strategy in logs and metrics.
+	Name() string
+	// Watermarks returns the Watermarks to scale the pool against, given the batch size,
+	// the max pool size, and the base Watermarks calculated from the NNC Scaler thresholds.
+	Watermarks(batch, max int64, base Watermarks) Watermarks
+}
+
+// ThresholdStrategy scales the pool using only the Scaler thresholds.
+type ThresholdStrategy struct{}
+
+func (ThresholdStrategy) Name() string {
+	return ThresholdStrategyName
+}
+
+// Watermarks returns the base Watermarks unchanged.
+func (ThresholdStrategy) Watermarks(_, _ int64, base Watermarks) Watermarks {
+	return base
+}
+
+// RateStrategy tracks the recent rate of Pod IP assignments and raises the
+// minimum free IP watermark so that the pool is scaled up before a burst of
+// Pods exhausts it, instead of waiting for the threshold to be crossed.
+// It never lowers the Watermarks below the base Scaler thresholds.
+type RateStrategy struct {
+	sync.Mutex
+	window      time.Duration
+	lookahead   time.Duration
+	assignments []time.Time
+	now         func() time.Time
+}
+
+// NewRateStrategy creates a RateStrategy which measures the assignment rate over the
+// passed window and keeps enough IPs free to satisfy the predicted demand for the lookahead.
+func NewRateStrategy(window, lookahead time.Duration) *RateStrategy {
+	if window <= 0 {
+		window = DefaultRateWindow
+	}
+	if lookahead <= 0 {
+		lookahead = DefaultRateLookahead
+	}
+	return &RateStrategy{
+		window:    window,
+		lookahead: lookahead,
+		now:       time.Now,
+	}
+}
+
+func (r *RateStrategy) Name() string {
+	return RateStrategyName
+}
+
+// ObserveStateTransition records IP assignments. It is intended to be
+// registered as an IPConfigurationStatus state middleware on the HTTPRestService.
+func (r *RateStrategy) ObserveStateTransition(i *cns.IPConfigurationStatus, s types.IPState) {
+	if s != types.Assigned || i.GetState() == types.Assigned {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	now := r.now()
+	r.trim(now)
+	r.assignments = append(r.assignments, now)
+}
+
+// Seed starts measuring the assignment rate from now, dropping the assignments recorded so far. It is intended to
+// be called once the initial CNS state has been reconciled, so that the assignments of the existing Pods replayed
+// by the reconcile are not taken for a burst of new Pods.
+func (r *RateStrategy) Seed() {
+	r.Lock()
+	defer r.Unlock()
+	r.assignments = nil
+}
+
+// trim drops the recorded assignments which have fallen out of the window. Caller must hold the lock.
+func (r *RateStrategy) trim(now time.Time) {
+	cutoff := now.Add(-r.window)
+	i := 0
+	for i < len(r.assignments) && !r.assignments[i].After(cutoff) {
+		i++
+	}
+	r.assignments = r.assignments[i:]
+}
+
+// Rate returns the IP assignment rate per second over the window.
+func (r *RateStrategy) Rate() float64 {
+	r.Lock()
+	defer r.Unlock()
+	r.trim(r.now())
+	return float64(len(r.assignments)) / r.window.Seconds()
+}
+
+// Watermarks raises the minimum free IPs to the demand predicted for the lookahead,
+// capped to the max pool size, and keeps the maximum free IPs at least a batch above it
+// so that the pool does not flap between scaling up and down. The raised maximum free IPs
+// are capped to the max pool size too, as no more IPs than that can be free.
+func (r *RateStrategy) Watermarks(batch, max int64, base Watermarks) Watermarks {
+	rate := r.Rate()
+	observeAssignmentRate(rate)
+	predicted := int64(math.Ceil(rate * r.lookahead.Seconds()))
+	w := base
+	if predicted > w.MinFree {
+		w.MinFree = predicted
+	}
+	if w.MinFree > max {
+		w.MinFree = max
+	}
+	if w.MaxFree < w.MinFree+batch {
+		w.MaxFree = w.MinFree + batch
+		if w.MaxFree > max {
+			w.MaxFree = max
+		}
+	}
+	return w
+}
+
+// NewStrategy returns the Strategy for the passed name, defaulting to the ThresholdStrategy.
+func NewStrategy(name string) Strategy {
+	if name == RateStrategyName {
+		return NewRateStrategy(DefaultRateWindow, DefaultRateLookahead)
+	}
+	return ThresholdStrategy{}
+}
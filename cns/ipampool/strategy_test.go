@@ -0,0 +1,160 @@
+package ipampool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThresholdStrategyWatermarks(t *testing.T) {
+	base := Watermarks{MinFree: 5, MaxFree: 15}
+	assert.Equal(t, base, ThresholdStrategy{}.Watermarks(10, 250, base))
+}
+
+func TestRateStrategyObserveStateTransition(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := NewRateStrategy(10*time.Second, 10*time.Second)
+	r.now = func() time.Time { return now }
+
+	available := &cns.IPConfigurationStatus{}
+	available.SetState(types.Available)
+	r.ObserveStateTransition(available, types.Assigned)
+	r.ObserveStateTransition(available, types.PendingRelease)
+	assert.InDelta(t, 0.1, r.Rate(), 0.0001)
+
+	// re-assigning an already Assigned IP is not a new assignment
+	assigned := &cns.IPConfigurationStatus{}
+	assigned.SetState(types.Assigned)
+	r.ObserveStateTransition(assigned, types.Assigned)
+	assert.InDelta(t, 0.1, r.Rate(), 0.0001)
+
+	// assignments fall out of the window
+	now = now.Add(11 * time.Second)
+	assert.InDelta(t, 0, r.Rate(), 0.0001)
+}
+
+func TestRateStrategySeed(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := NewRateStrategy(10*time.Second, 10*time.Second)
+	r.now = func() time.Time { return now }
+
+	// the assignments replayed by the reconcile of the initial state are dropped when the strategy is seeded.
+	for i := 0; i < 30; i++ {
+		ip := &cns.IPConfigurationStatus{}
+		ip.SetState(types.Available)
+		r.ObserveStateTransition(ip, types.Assigned)
+	}
+	r.Seed()
+	assert.Equal(t, float64(0), r.Rate())
+	base := Watermarks{MinFree: 5, MaxFree: 15}
+	assert.Equal(t, base, r.Watermarks(10, 250, base))
+
+	// the assignments after the seed are measured.
+	ip := &cns.IPConfigurationStatus{}
+	ip.SetState(types.Available)
+	r.ObserveStateTransition(ip, types.Assigned)
+	assert.Equal(t, 0.1, r.Rate())
+}
+
+func TestRateStrategyWatermarks(t *testing.T) {
+	tests := []struct {
+		name        string
+		assignments int
+		batch       int64
+		max         int64
+		base        Watermarks
+		want        Watermarks
+	}{
+		{
+			name:        "no assignments uses base",
+			assignments: 0,
+			batch:       10,
+			max:         250,
+			base:        Watermarks{MinFree: 5, MaxFree: 15},
+			want:        Watermarks{MinFree: 5, MaxFree: 15},
+		},
+		{
+			name:        "slow rate uses base",
+			assignments: 2,
+			batch:       10,
+			max:         250,
+			base:        Watermarks{MinFree: 5, MaxFree: 15},
+			want:        Watermarks{MinFree: 5, MaxFree: 15},
+		},
+		{
+			name:        "burst raises watermarks",
+			assignments: 30,
+			batch:       10,
+			max:         250,
+			base:        Watermarks{MinFree: 5, MaxFree: 15},
+			want:        Watermarks{MinFree: 30, MaxFree: 40},
+		},
+		{
+			name:        "burst capped to max",
+			assignments: 30,
+			batch:       10,
+			max:         20,
+			base:        Watermarks{MinFree: 5, MaxFree: 15},
+			want:        Watermarks{MinFree: 20, MaxFree: 20},
+		},
+		{
+			name:        "burst max free capped to max",
+			assignments: 15,
+			batch:       10,
+			max:         20,
+			base:        Watermarks{MinFree: 5, MaxFree: 15},
+			want:        Watermarks{MinFree: 15, MaxFree: 20},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			r := NewRateStrategy(10*time.Second, 10*time.Second)
+			r.now = func() time.Time { return now }
+			for i := 0; i < tt.assignments; i++ {
+				ip := &cns.IPConfigurationStatus{}
+				ip.SetState(types.Available)
+				r.ObserveStateTransition(ip, types.Assigned)
+			}
+			assert.Equal(t, tt.want, r.Watermarks(tt.batch, tt.max, tt.base))
+		})
+	}
+}
+
+func TestRateStrategyIncreasesPoolEarly(t *testing.T) {
+	initState := testState{
+		batch:                   10,
+		assigned:                4,
+		allocated:               10,
+		requestThresholdPercent: 50,
+		releaseThresholdPercent: 150,
+		max:                     30,
+	}
+
+	fakecns, fakerc, poolmonitor := initFakes(initState)
+	assert.NoError(t, fakerc.Reconcile(true))
+
+	// with the threshold strategy, 6 free IPs is above the minimum of 5 so the pool is not scaled.
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.Equal(t, initState.allocated, poolmonitor.spec.RequestedIPCount)
+
+	now := time.Unix(0, 0)
+	r := NewRateStrategy(10*time.Second, 10*time.Second)
+	r.now = func() time.Time { return now }
+	for i := 0; i < 8; i++ {
+		ip := &cns.IPConfigurationStatus{}
+		ip.SetState(types.Available)
+		r.ObserveStateTransition(ip, types.Assigned)
+	}
+	poolmonitor.opts.Strategy = r
+
+	// the predicted demand of 8 IPs is more than the 6 free, so the pool is scaled up early.
+	assert.NoError(t, poolmonitor.reconcile(context.Background()))
+	assert.Equal(t, initState.allocated+initState.batch, poolmonitor.spec.RequestedIPCount)
+	assert.Len(t, fakecns.GetAssignedIPConfigs(), initState.assigned)
+}
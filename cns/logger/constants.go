@@ -3,11 +3,13 @@ package logger
 
 const (
 	// Metrics
-	HeartBeatMetricStr = "HeartBeat"
+	HeartBeatMetricStr      = "HeartBeat"
+	StoreRecoveredMetricStr = "StoreRecovered"
 
 	// Dimensions
 	OrchestratorTypeStr = "OrchestratorType"
 	NodeIDStr           = "NodeID"
+	StoreFileStr        = "StoreFile"
 	// CNS Snspshot properties
 	CnsNCSnapshotEventStr         = "CNSNCSnapshot"
 	IpConfigurationStr            = "IPConfiguration"
@@ -166,6 +166,9 @@ func TestMain(m *testing.M) {
 	// Cleanup.
 	service.Stop()
 	nmAgentServer.Stop()
+	for i := 1; i <= store.DefaultSnapshots; i++ {
+		os.Remove(fmt.Sprintf("%s.%d", cnsJsonFileName, i))
+	}
 
 	os.Exit(exitCode)
 }
@@ -0,0 +1,111 @@
+package restserver
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+)
+
+// isIPv6Address returns whether the address is an IPv6 address.
+func isIPv6Address(ipAddress string) bool {
+	ip := net.ParseIP(ipAddress)
+	return ip != nil && ip.To4() == nil
+}
+
+// podIPIDsUntransacted returns the IDs of the IPConfigs assigned to the Pod, the IPv4 IPConfig first, does not take a lock.
+func (service *HTTPRestService) podIPIDsUntransacted(podInfo cns.PodInfo) []string {
+	ipIDs := []string{}
+	if ipID := service.PodIPIDByPodInterfaceKey[podInfo.Key()]; ipID != "" {
+		ipIDs = append(ipIDs, ipID)
+	}
+	if ipID := service.PodIPv6IDByPodInterfaceKey[podInfo.Key()]; ipID != "" {
+		ipIDs = append(ipIDs, ipID)
+	}
+	return ipIDs
+}
+
+// setIPv6Config copies the IPv6 IPConfig of a PodIpInfo to another.
+func setIPv6Config(dst *cns.PodIpInfo, src *cns.PodIpInfo) {
+	dst.PodIPConfigV6 = src.PodIPConfigV6
+	dst.NetworkContainerPrimaryIPConfigV6 = src.NetworkContainerPrimaryIPConfigV6
+}
+
+// populateExistingIPv6ConfigUntransacted adds the IPv6 IPConfig already assigned to the Pod, if any, to the
+// PodIpInfo, does not take a lock.
+func (service *HTTPRestService) populateExistingIPv6ConfigUntransacted(podInfo cns.PodInfo, podIPInfo *cns.PodIpInfo) (bool, error) {
+	ipID := service.PodIPv6IDByPodInterfaceKey[podInfo.Key()]
+	if ipID == "" {
+		return false, nil
+	}
+
+	ipState, isExist := service.PodIPConfigState[ipID]
+	if !isExist {
+		//nolint:goerr113
+		return false, fmt.Errorf("Failed to get existing IPv6 ipconfig. Pod to IPID exists, but IPID to IPConfig doesn't exist, CNS State potentially corrupt")
+	}
+
+	return true, service.populateIPConfigInfoUntransacted(ipState, podIPInfo)
+}
+
+// requestIPv6ConfigUntransacted adds the IPv6 IPConfig of a dual-stack Pod to its PodIpInfo, assigning one if the
+// Pod does not have one yet and the Node has IPv6 NCs. The returned ipAssignment is nil if no IPConfig was newly
+// assigned. Does not take a lock.
+func (service *HTTPRestService) requestIPv6ConfigUntransacted(req *cns.IPConfigRequest, podInfo cns.PodInfo, podIPInfo *cns.PodIpInfo) (*ipAssignment, error) {
+	if isExist, err := service.populateExistingIPv6ConfigUntransacted(podInfo, podIPInfo); err != nil || isExist {
+		return nil, err
+	}
+
+	if req.DesiredIPv6Address != "" {
+		// the quotas are of Pods, which are counted by their IPv4 IPs.
+		desiredIPInfo, prior, err := service.assignDesiredIPConfigUntransacted(podInfo, req.DesiredIPv6Address, false)
+		if err != nil {
+			return nil, err
+		}
+		setIPv6Config(podIPInfo, &desiredIPInfo)
+		if prior.ID == "" {
+			return nil, nil
+		}
+		return &ipAssignment{prior: prior, podInfo: podInfo}, nil
+	}
+
+	// when the IPv4 address of a Pod is reconciled, its IPv6 address is reconciled on its own from the IPv6 NC.
+	if req.DesiredIPAddress != "" {
+		return nil, nil
+	}
+
+	availableIPInfo, prior, err := service.assignAvailableIPv6ConfigUntransacted(podInfo)
+	if err != nil || prior.ID == "" {
+		return nil, err
+	}
+	setIPv6Config(podIPInfo, &availableIPInfo)
+	return &ipAssignment{prior: prior, podInfo: podInfo}, nil
+}
+
+// assignAvailableIPv6ConfigUntransacted assigns an Available IPv6 IP to the Pod and returns the assigned IPConfig as
+// it was before it was assigned, or an empty IPConfig if the Node has no IPv6 IPs. Does not take a lock.
+func (service *HTTPRestService) assignAvailableIPv6ConfigUntransacted(podInfo cns.PodInfo) (cns.PodIpInfo, cns.IPConfigurationStatus, error) {
+	hasIPv6 := false
+	for _, ipState := range service.PodIPConfigState {
+		if !isIPv6Address(ipState.IPAddress) {
+			continue
+		}
+		hasIPv6 = true
+		if ipState.GetState() == types.Available {
+			podIPInfo, err := service.assignAndPopulateUntransacted(ipState, podInfo)
+			if err != nil {
+				return cns.PodIpInfo{}, cns.IPConfigurationStatus{}, err
+			}
+			logger.Printf("[assignAvailableIPv6Config] assigned IPv6 %s to dual-stack Pod %+v", ipState.IPAddress, podInfo)
+			return podIPInfo, ipState, nil
+		}
+	}
+
+	if hasIPv6 {
+		//nolint:goerr113
+		return cns.PodIpInfo{}, cns.IPConfigurationStatus{}, fmt.Errorf("no IPv6 IPs available, waiting on Azure CNS to allocate more")
+	}
+	return cns.PodIpInfo{}, cns.IPConfigurationStatus{}, nil
+}
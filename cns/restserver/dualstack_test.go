@@ -0,0 +1,115 @@
+package restserver
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIPv6NCID = "d0a4a6c5-4d15-4bc6-9b5a-5bd1d8d1b8f2"
+	testIPv6IP1  = "fd00::1"
+	testIPv6ID1  = "5b4c8c52-3e53-4cf7-a5b8-5ba8e4c5a0d1"
+
+	testIPv6Gateway = "fd00::fffe"
+)
+
+// addTestIPv6NC adds an IPv6 NC with the passed secondary IPs, by ID, to the test service.
+func addTestIPv6NC(t *testing.T, ipv6ByID map[string]string) {
+	secondaryIPConfigs := map[string]cns.SecondaryIPConfig{}
+	for id, ip := range ipv6ByID {
+		secondaryIPConfigs[id] = newSecondaryIPConfig(ip, -1)
+	}
+	req := generateNetworkContainerRequest(secondaryIPConfigs, testIPv6NCID, "-1")
+	req.IPConfiguration.IPSubnet = cns.IPSubnet{IPAddress: "fd00::ff", PrefixLength: 64}
+	req.IPConfiguration.GatewayIPAddress = testIPv6Gateway
+	require.Equal(t, types.Success, svc.CreateOrUpdateNetworkContainerInternal(req))
+}
+
+func TestIPAMRequestDualStackIPConfig(t *testing.T) {
+	svc := getTestService()
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state1.ID: state1}))
+	addTestIPv6NC(t, map[string]string{testIPv6ID1: testIPv6IP1})
+
+	req := stickyIPConfigRequest(t, testPod1Info, false)
+	podIPInfo, err := requestIPConfigHelper(svc, req)
+	require.NoError(t, err)
+	assert.Equal(t, testIP1, podIPInfo.PodIPConfig.IPAddress)
+	assert.Equal(t, testIPv6IP1, podIPInfo.PodIPConfigV6.IPAddress)
+	assert.Equal(t, testIPv6Gateway, podIPInfo.NetworkContainerPrimaryIPConfigV6.GatewayIPAddress)
+	assert.Equal(t, 64, int(podIPInfo.PodIPConfigV6.PrefixLength))
+	assert.Equal(t, gatewayIp, podIPInfo.NetworkContainerPrimaryIPConfig.GatewayIPAddress)
+	assert.Equal(t, testIPv6ID1, svc.PodIPv6IDByPodInterfaceKey[testPod1Info.Key()])
+	assert.Len(t, svc.GetAssignedIPConfigs(), 2)
+
+	// the Pod gets the same IPs again.
+	podIPInfo, err = requestIPConfigHelper(svc, req)
+	require.NoError(t, err)
+	assert.Equal(t, testIP1, podIPInfo.PodIPConfig.IPAddress)
+	assert.Equal(t, testIPv6IP1, podIPInfo.PodIPConfigV6.IPAddress)
+	assert.Len(t, svc.GetAssignedIPConfigs(), 2)
+
+	require.NoError(t, svc.releaseIPConfig(testPod1Info))
+	assert.Empty(t, svc.GetAssignedIPConfigs())
+	assert.Empty(t, svc.PodIPIDByPodInterfaceKey)
+	assert.Empty(t, svc.PodIPv6IDByPodInterfaceKey)
+}
+
+func TestIPAMRequestIPConfigWithoutIPv6NC(t *testing.T) {
+	svc := getTestService()
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state1.ID: state1}))
+
+	podIPInfo, err := requestIPConfigHelper(svc, stickyIPConfigRequest(t, testPod1Info, false))
+	require.NoError(t, err)
+	assert.Equal(t, testIP1, podIPInfo.PodIPConfig.IPAddress)
+	assert.Empty(t, podIPInfo.PodIPConfigV6.IPAddress)
+	assert.Empty(t, svc.PodIPv6IDByPodInterfaceKey)
+}
+
+func TestIPAMRequestDualStackIPConfigRollback(t *testing.T) {
+	svc := getTestService()
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	state2 := NewPodState(testIP2, 24, testPod2GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+		state2.ID: state2,
+	}))
+	addTestIPv6NC(t, map[string]string{testIPv6ID1: testIPv6IP1})
+
+	_, err := requestIPConfigHelper(svc, stickyIPConfigRequest(t, testPod1Info, false))
+	require.NoError(t, err)
+
+	// the IPv6 IPs are exhausted, so the IPv4 IP assigned to the second Pod is rolled back.
+	_, err = requestIPConfigHelper(svc, stickyIPConfigRequest(t, testPod2Info, false))
+	require.Error(t, err)
+	assert.Len(t, svc.GetAssignedIPConfigs(), 2)
+	assert.Empty(t, svc.PodIPIDByPodInterfaceKey[testPod2Info.Key()])
+}
+
+func TestIPAMReconcileDualStackIPConfig(t *testing.T) {
+	svc := getTestService()
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	state2 := NewPodState(testIP2, 24, testPod2GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+		state2.ID: state2,
+	}))
+	addTestIPv6NC(t, map[string]string{testIPv6ID1: testIPv6IP1, testPod3GUID: "fd00::2"})
+
+	// the IPv6 NC is reconciled before the IPv4 NC, and each desired IP is assigned on its own.
+	req := stickyIPConfigRequest(t, testPod1Info, false)
+	req.DesiredIPAddress = "fd00::2"
+	_, err := requestIPConfigHelper(svc, req)
+	require.NoError(t, err)
+	req.DesiredIPAddress = testIP2
+	podIPInfo, err := requestIPConfigHelper(svc, req)
+	require.NoError(t, err)
+	assert.Equal(t, testIP2, podIPInfo.PodIPConfig.IPAddress)
+	assert.Equal(t, "fd00::2", podIPInfo.PodIPConfigV6.IPAddress)
+	assert.Len(t, svc.GetAssignedIPConfigs(), 2)
+}
@@ -256,7 +256,7 @@ func (service *HTTPRestService) ReconcileNCState(
 				PodInterfaceID:      podInfo.InterfaceID(),
 			}
 
-			if _, err := requestIPConfigHelper(service, ipconfigRequest); err != nil {
+			if _, err := reconcileIPConfigHelper(service, ipconfigRequest); err != nil {
 				logger.Errorf("AllocateIPConfig failed for SecondaryIP %+v, podInfo %+v, ncId %s, error: %v", secIpConfig, podInfo, ncRequest.NetworkContainerid, err)
 				return types.FailedToAllocateIPConfig
 			}
@@ -265,9 +265,16 @@ func (service *HTTPRestService) ReconcileNCState(
 		}
 	}
 
-	err := service.MarkExistingIPsAsPendingRelease(nnc.Spec.IPsNotInUse)
+	// only mark the IPs which belong to this NC, the Node may have others.
+	pendingIPIDs := []string{}
+	for _, id := range nnc.Spec.IPsNotInUse {
+		if _, ok := ncRequest.SecondaryIPConfigs[id]; ok {
+			pendingIPIDs = append(pendingIPIDs, id)
+		}
+	}
+	err := service.MarkExistingIPsAsPendingRelease(pendingIPIDs)
 	if err != nil {
-		logger.Errorf("[Azure CNS] Error. Failed to mark IPs as pending %v", nnc.Spec.IPsNotInUse)
+		logger.Errorf("[Azure CNS] Error. Failed to mark IPs as pending %v", pendingIPIDs)
 		return types.UnexpectedError
 	}
 
@@ -45,9 +45,13 @@ func (service *HTTPRestService) requestIPConfigHandler(w http.ResponseWriter, r
 
 	podIPInfo, err := requestIPConfigHelper(service, ipconfigRequest)
 	if err != nil {
+		returnCode := types.FailedToAllocateIPConfig
+		if errors.Is(err, ErrNamespaceIPQuotaExceeded) {
+			returnCode = types.NamespaceIPQuotaExceeded
+		}
 		reserveResp := &cns.IPConfigResponse{
 			Response: cns.Response{
-				ReturnCode: types.FailedToAllocateIPConfig,
+				ReturnCode: returnCode,
 				Message:    fmt.Sprintf("AllocateIPConfig failed: %v, IP config request is %s", err, ipconfigRequest),
 			},
 			PodIpInfo: podIPInfo,
@@ -111,11 +115,29 @@ func (service *HTTPRestService) releaseIPConfigHandler(w http.ResponseWriter, r
 // MarkIPAsPendingRelease will set the IPs which are in PendingProgramming or Available to PendingRelease state
 // It will try to update [totalIpsToRelease]  number of ips.
 func (service *HTTPRestService) MarkIPAsPendingRelease(totalIpsToRelease int) (map[string]cns.IPConfigurationStatus, error) {
-	pendingReleasedIps := make(map[string]cns.IPConfigurationStatus)
 	service.Lock()
 	defer service.Unlock()
+	return service.markIPsAsPendingReleaseUntransacted("", totalIpsToRelease)
+}
+
+// MarkNCIPsAsPendingRelease is the same as MarkIPAsPendingRelease, but only considers the IPs of the passed NC.
+func (service *HTTPRestService) MarkNCIPsAsPendingRelease(ncID string, totalIpsToRelease int) (map[string]cns.IPConfigurationStatus, error) {
+	service.Lock()
+	defer service.Unlock()
+	return service.markIPsAsPendingReleaseUntransacted(ncID, totalIpsToRelease)
+}
+
+// markIPsAsPendingReleaseUntransacted marks up to [totalIpsToRelease] IPs of the passed NC, or of any NC if
+// the ncID is empty, as PendingRelease, preferring PendingProgramming IPs over Available IPs.
+// Caller will acquire/release the service lock.
+func (service *HTTPRestService) markIPsAsPendingReleaseUntransacted(ncID string, totalIpsToRelease int) (map[string]cns.IPConfigurationStatus, error) {
+	pendingReleasedIps := make(map[string]cns.IPConfigurationStatus)
+	service.stickyIPs.expire()
 
 	for uuid, existingIpConfig := range service.PodIPConfigState {
+		if ncID != "" && existingIpConfig.NCID != ncID {
+			continue
+		}
 		if existingIpConfig.GetState() == types.PendingProgramming {
 			updatedIPConfig, err := service.updateIPConfigState(uuid, types.PendingRelease, existingIpConfig.PodInfo)
 			if err != nil {
@@ -131,7 +153,11 @@ func (service *HTTPRestService) MarkIPAsPendingRelease(totalIpsToRelease int) (m
 
 	// if not all expected IPs are set to PendingRelease, then check the Available IPs
 	for uuid, existingIpConfig := range service.PodIPConfigState {
-		if existingIpConfig.GetState() == types.Available {
+		if ncID != "" && existingIpConfig.NCID != ncID {
+			continue
+		}
+		// IPs reserved for sticky Pods are kept until their reservation expires.
+		if existingIpConfig.GetState() == types.Available && !service.stickyIPs.isReserved(uuid) {
 			updatedIPConfig, err := service.updateIPConfigState(uuid, types.PendingRelease, existingIpConfig.PodInfo)
 			if err != nil {
 				return nil, err
@@ -152,6 +178,10 @@ func (service *HTTPRestService) MarkIPAsPendingRelease(totalIpsToRelease int) (m
 func (service *HTTPRestService) updateIPConfigState(ipID string, updatedState types.IPState, podInfo cns.PodInfo) (cns.IPConfigurationStatus, error) {
 	if ipConfig, found := service.PodIPConfigState[ipID]; found {
 		logger.Printf("[updateIPConfigState] Changing IpId [%s] state to [%s], podInfo [%+v]. Current config [%+v]", ipID, updatedState, podInfo, ipConfig)
+		// the state middleware sees the Pod the IP is assigned to, or the Pod releasing it.
+		if podInfo != nil {
+			ipConfig.PodInfo = podInfo
+		}
 		ipConfig.SetState(updatedState)
 		ipConfig.PodInfo = podInfo
 		service.PodIPConfigState[ipID] = ipConfig
@@ -221,9 +251,10 @@ func (service *HTTPRestService) handleDebugRestData(w http.ResponseWriter, r *ht
 	defer service.RUnlock()
 	resp := GetHTTPServiceDataResponse{
 		HTTPRestServiceData: HTTPRestServiceData{
-			PodIPIDByPodInterfaceKey: service.PodIPIDByPodInterfaceKey,
-			PodIPConfigState:         service.PodIPConfigState,
-			IPAMPoolMonitor:          service.IPAMPoolMonitor.GetStateSnapshot(),
+			PodIPIDByPodInterfaceKey:   service.PodIPIDByPodInterfaceKey,
+			PodIPv6IDByPodInterfaceKey: service.PodIPv6IDByPodInterfaceKey,
+			PodIPConfigState:           service.PodIPConfigState,
+			IPAMPoolMonitor:            service.IPAMPoolMonitor.GetStateSnapshot(),
 		},
 	}
 	err := service.Listener.Encode(w, &resp)
@@ -285,11 +316,32 @@ func (service *HTTPRestService) GetPendingReleaseIPConfigs() []cns.IPConfigurati
 
 // assignIPConfig assigns the the ipconfig to the passed Pod, sets the state as Assigned, does not take a lock.
 func (service *HTTPRestService) assignIPConfig(ipconfig cns.IPConfigurationStatus, podInfo cns.PodInfo) error { //nolint:gocritic // ignore hugeparam
-	ipconfig, err := service.updateIPConfigState(ipconfig.ID, types.Assigned, podInfo)
+	// the assignment is journaled first so that an IP is never handed out without being recorded.
+	if service.IPAssignmentJournal != nil {
+		if err := service.IPAssignmentJournal.Assign(ipconfig, podInfo); err != nil {
+			return errors.Wrapf(err, "failed to journal assignment of IP %s", ipconfig.IPAddress)
+		}
+	}
+	assigned, err := service.updateIPConfigState(ipconfig.ID, types.Assigned, podInfo)
 	if err != nil {
+		// the IP was not assigned, so its journal entry is rolled back.
+		if service.IPAssignmentJournal != nil {
+			if jerr := service.IPAssignmentJournal.Unassign(ipconfig); jerr != nil {
+				logger.Errorf("[assignIPConfig] failed to roll back journaled assignment of IP %s: %v", ipconfig.IPAddress, jerr)
+			}
+		}
 		return err
 	}
+	ipconfig = assigned
+	service.stickyIPs.assign(ipconfig.ID)
 
+	if isIPv6Address(ipconfig.IPAddress) {
+		if service.PodIPv6IDByPodInterfaceKey == nil {
+			service.PodIPv6IDByPodInterfaceKey = map[string]string{}
+		}
+		service.PodIPv6IDByPodInterfaceKey[podInfo.Key()] = ipconfig.ID
+		return nil
+	}
 	service.PodIPIDByPodInterfaceKey[podInfo.Key()] = ipconfig.ID
 	return nil
 }
@@ -301,7 +353,18 @@ func (service *HTTPRestService) unassignIPConfig(ipconfig cns.IPConfigurationSta
 		return cns.IPConfigurationStatus{}, err
 	}
 
-	delete(service.PodIPIDByPodInterfaceKey, podInfo.Key())
+	if isIPv6Address(ipconfig.IPAddress) {
+		delete(service.PodIPv6IDByPodInterfaceKey, podInfo.Key())
+	} else {
+		delete(service.PodIPIDByPodInterfaceKey, podInfo.Key())
+	}
+	service.stickyIPs.release(ipconfig.ID, podInfo)
+	if service.IPAssignmentJournal != nil {
+		// a stale journal entry is reported as drift on recovery, so the release is not failed.
+		if err := service.IPAssignmentJournal.Unassign(ipconfig); err != nil {
+			logger.Errorf("[unassignIPConfig] failed to journal release of IP %s: %v", ipconfig.IPAddress, err)
+		}
+	}
 	logger.Printf("[setIPConfigAsAvailable] Deleted outdated pod info %s from PodIPIDByOrchestratorContext since IP %s with ID %s will be released and set as Available",
 		podInfo.Key(), ipconfig.IPAddress, ipconfig.ID)
 	return ipconfig, nil
@@ -313,8 +376,13 @@ func (service *HTTPRestService) releaseIPConfig(podInfo cns.PodInfo) error {
 	service.Lock()
 	defer service.Unlock()
 
-	ipID := service.PodIPIDByPodInterfaceKey[podInfo.Key()]
-	if ipID != "" {
+	// a dual-stack Pod has an IPv6 IPConfig as well as its IPv4 IPConfig.
+	ipIDs := service.podIPIDsUntransacted(podInfo)
+	if len(ipIDs) == 0 {
+		logger.Errorf("[releaseIPConfig] SetIPConfigAsAvailable ignoring request to release, no allocation found for pod [%+v]", podInfo)
+		return nil
+	}
+	for _, ipID := range ipIDs {
 		if ipconfig, isExist := service.PodIPConfigState[ipID]; isExist {
 			logger.Printf("[releaseIPConfig] Releasing IP %+v for pod %+v", ipconfig.IPAddress, podInfo)
 			_, err := service.unassignIPConfig(ipconfig, podInfo)
@@ -328,9 +396,6 @@ func (service *HTTPRestService) releaseIPConfig(podInfo cns.PodInfo) error {
 			return fmt.Errorf("[releaseIPConfig] releaseIPConfig failed. IPconfig %+v and pod info is %+v. Pod to IPID exists, but IPID to IPConfig doesn't exist, CNS State potentially corrupt",
 				ipconfig.IPAddress, podInfo)
 		}
-	} else {
-		logger.Errorf("[releaseIPConfig] SetIPConfigAsAvailable ignoring request to release, no allocation found for pod [%+v]", podInfo)
-		return nil
 	}
 	return nil
 }
@@ -357,18 +422,25 @@ func (service *HTTPRestService) MarkExistingIPsAsPendingRelease(pendingIPIDs []s
 }
 
 func (service *HTTPRestService) GetExistingIPConfig(podInfo cns.PodInfo) (cns.PodIpInfo, bool, error) {
+	service.RLock()
+	defer service.RUnlock()
+	return service.getExistingIPConfigUntransacted(podInfo)
+}
+
+// getExistingIPConfigUntransacted returns the IPConfig already assigned to the Pod, if any, does not take a lock.
+func (service *HTTPRestService) getExistingIPConfigUntransacted(podInfo cns.PodInfo) (cns.PodIpInfo, bool, error) {
 	var (
 		podIpInfo cns.PodIpInfo
 		isExist   bool
 	)
 
-	service.RLock()
-	defer service.RUnlock()
-
 	ipID := service.PodIPIDByPodInterfaceKey[podInfo.Key()]
 	if ipID != "" {
 		if ipState, isExist := service.PodIPConfigState[ipID]; isExist {
-			err := service.populateIPConfigInfoUntransacted(ipState, &podIpInfo)
+			if err := service.populateIPConfigInfoUntransacted(ipState, &podIpInfo); err != nil {
+				return podIpInfo, isExist, err
+			}
+			_, err := service.populateExistingIPv6ConfigUntransacted(podInfo, &podIpInfo)
 			return podIpInfo, isExist, err
 		}
 
@@ -380,10 +452,17 @@ func (service *HTTPRestService) GetExistingIPConfig(podInfo cns.PodInfo) (cns.Po
 }
 
 func (service *HTTPRestService) AssignDesiredIPConfig(podInfo cns.PodInfo, desiredIPAddress string) (cns.PodIpInfo, error) {
-	var podIpInfo cns.PodIpInfo
 	service.Lock()
 	defer service.Unlock()
+	podIPInfo, _, err := service.assignDesiredIPConfigUntransacted(podInfo, desiredIPAddress, true)
+	return podIPInfo, err
+}
 
+// assignDesiredIPConfigUntransacted assigns the desired IP to the Pod, and returns the IPConfig as it was before it
+// was assigned if it was newly assigned, or an empty IPConfig otherwise. Does not take a lock. The namespace IP quotas are not enforced when reconciling the IPs which
+// Pods already have.
+func (service *HTTPRestService) assignDesiredIPConfigUntransacted(podInfo cns.PodInfo, desiredIPAddress string, enforceQuota bool) (cns.PodIpInfo, cns.IPConfigurationStatus, error) {
+	var podIpInfo cns.PodIpInfo
 	for _, ipConfig := range service.PodIPConfigState {
 		if ipConfig.IPAddress == desiredIPAddress {
 			switch ipConfig.GetState() { //nolint:exhaustive // ignoring PendingRelease case intentionally
@@ -393,64 +472,102 @@ func (service *HTTPRestService) AssignDesiredIPConfig(podInfo cns.PodInfo, desir
 				if ipConfig.PodInfo.Key() == podInfo.Key() {
 					logger.Printf("[AssignDesiredIPConfig]: IP Config [%+v] is already assigned to this Pod [%+v]", ipConfig, podInfo)
 				} else {
-					return podIpInfo, errors.Errorf("[AssignDesiredIPConfig] Desired IP is already assigned %+v, requested for pod %+v", ipConfig, podInfo)
+					return podIpInfo, cns.IPConfigurationStatus{}, errors.Errorf("[AssignDesiredIPConfig] Desired IP is already assigned %+v, requested for pod %+v", ipConfig, podInfo)
 				}
 			case types.Available, types.PendingProgramming:
 				// This race can happen during restart, where CNS state is lost and thus we have lost the NC programmed version
 				// As part of reconcile, we mark IPs as Assigned which are already assigned to Pods (listed from APIServer)
+				if enforceQuota {
+					if err := service.checkNamespaceIPQuotaUntransacted(podInfo.Namespace(), ipConfig); err != nil {
+						return podIpInfo, cns.IPConfigurationStatus{}, err
+					}
+				}
 				if err := service.assignIPConfig(ipConfig, podInfo); err != nil {
-					return podIpInfo, err
+					return podIpInfo, cns.IPConfigurationStatus{}, err
 				}
+				err := service.populateIPConfigInfoUntransacted(ipConfig, &podIpInfo)
+				return podIpInfo, ipConfig, err
 			default:
-				return podIpInfo, errors.Errorf("[AllocateDesiredIPConfig] Desired IP is not available %+v", ipConfig)
+				return podIpInfo, cns.IPConfigurationStatus{}, errors.Errorf("[AllocateDesiredIPConfig] Desired IP is not available %+v", ipConfig)
 			}
 			err := service.populateIPConfigInfoUntransacted(ipConfig, &podIpInfo)
-			return podIpInfo, err
+			return podIpInfo, cns.IPConfigurationStatus{}, err
 		}
 	}
-	return podIpInfo, fmt.Errorf("Requested IP not found in pool")
+	return podIpInfo, cns.IPConfigurationStatus{}, fmt.Errorf("Requested IP not found in pool")
 }
 
 func (service *HTTPRestService) AssignAnyAvailableIPConfig(podInfo cns.PodInfo) (cns.PodIpInfo, error) {
 	service.Lock()
 	defer service.Unlock()
+	podIPInfo, _, err := service.assignAvailableIPConfigFromNCsUntransacted(podInfo, nil)
+	return podIPInfo, err
+}
 
-	for _, ipState := range service.PodIPConfigState {
-		if ipState.GetState() == types.Available {
-			if err := service.assignIPConfig(ipState, podInfo); err != nil {
-				return cns.PodIpInfo{}, err
-			}
-
-			podIPInfo := cns.PodIpInfo{}
-			if err := service.populateIPConfigInfoUntransacted(ipState, &podIPInfo); err != nil {
-				return cns.PodIpInfo{}, err
+// assignAvailableIPConfigFromNCsUntransacted assigns an Available IPv4 IP from the first of the passed NCs which has
+// one, and returns the assigned IPConfig as it was before it was assigned. If no NCs are passed, an Available IPv4 IP
+// from any NC is assigned. Does not take a lock.
+func (service *HTTPRestService) assignAvailableIPConfigFromNCsUntransacted(podInfo cns.PodInfo, ncIDs []string) (cns.PodIpInfo, cns.IPConfigurationStatus, error) {
+	service.stickyIPs.expire()
+	usage := service.namespaceIPUsageUntransacted()
+	if len(ncIDs) == 0 {
+		ncIDs = []string{""}
+	}
+	// an NC whose Available IPs are reserved for other namespaces is skipped for the next one.
+	var quotaErr error
+	for _, ncID := range ncIDs {
+		for _, ipState := range service.PodIPConfigState {
+			if ipState.GetState() == types.Available && (ncID == "" || ipState.NCID == ncID) && !service.stickyIPs.isReserved(ipState.ID) &&
+				!isIPv6Address(ipState.IPAddress) {
+				if err := usage.check(service.namespaceIPQuotas, podInfo.Namespace(), ipState); err != nil {
+					quotaErr = err
+					continue
+				}
+				podIPInfo, err := service.assignAndPopulateUntransacted(ipState, podInfo)
+				return podIPInfo, ipState, err
 			}
-
-			return podIPInfo, nil
 		}
 	}
+	if quotaErr != nil {
+		return cns.PodIpInfo{}, cns.IPConfigurationStatus{}, quotaErr
+	}
 	//nolint:goerr113
-	return cns.PodIpInfo{}, fmt.Errorf("no IPs available, waiting on Azure CNS to allocate more")
+	return cns.PodIpInfo{}, cns.IPConfigurationStatus{}, fmt.Errorf("no IPs available, waiting on Azure CNS to allocate more")
 }
 
-// If IPConfig is already assigned to pod, it returns that else it returns one of the available ipconfigs.
-func requestIPConfigHelper(service *HTTPRestService, req cns.IPConfigRequest) (cns.PodIpInfo, error) {
-	// check if ipconfig already assigned tothis pod and return if exists or error
-	// if error, ipstate is nil, if exists, ipstate is not nil and error is nil
-	podInfo, err := cns.NewPodInfoFromIPConfigRequest(req)
-	if err != nil {
-		return cns.PodIpInfo{}, errors.Wrapf(err, "failed to parse IPConfigRequest %v", req)
+// assignAndPopulateUntransacted assigns the ipconfig to the Pod and returns the PodIpInfo for it, does not take a lock.
+func (service *HTTPRestService) assignAndPopulateUntransacted(ipState cns.IPConfigurationStatus, podInfo cns.PodInfo) (cns.PodIpInfo, error) { //nolint:gocritic // ignore hugeparam
+	if err := service.assignIPConfig(ipState, podInfo); err != nil {
+		return cns.PodIpInfo{}, err
 	}
 
-	if podIPInfo, isExist, err := service.GetExistingIPConfig(podInfo); err != nil || isExist {
-		return podIPInfo, err
+	podIPInfo := cns.PodIpInfo{}
+	if err := service.populateIPConfigInfoUntransacted(ipState, &podIPInfo); err != nil {
+		return cns.PodIpInfo{}, err
 	}
 
-	// return desired IPConfig
-	if req.DesiredIPAddress != "" {
-		return service.AssignDesiredIPConfig(podInfo, req.DesiredIPAddress)
-	}
+	return podIPInfo, nil
+}
+
+// If IPConfig is already assigned to pod, it returns that else it returns one of the available ipconfigs.
+func requestIPConfigHelper(service *HTTPRestService, req cns.IPConfigRequest) (cns.PodIpInfo, error) {
+	return service.requestIPConfig(req, true)
+}
+
+// reconcileIPConfigHelper assigns the desired IP of the request to a Pod which already has it, such as on restart,
+// so the namespace IP quotas are not enforced for it.
+func reconcileIPConfigHelper(service *HTTPRestService, req cns.IPConfigRequest) (cns.PodIpInfo, error) {
+	return service.requestIPConfig(req, false)
+}
 
-	// return any free IPConfig
-	return service.AssignAnyAvailableIPConfig(podInfo)
+func (service *HTTPRestService) requestIPConfig(req cns.IPConfigRequest, enforceQuota bool) (cns.PodIpInfo, error) {
+	podIPInfos, err := service.requestIPConfigs([]cns.IPConfigRequest{req}, enforceQuota)
+	if err != nil {
+		var itemErr *BatchItemError
+		if errors.As(err, &itemErr) {
+			return cns.PodIpInfo{}, itemErr.Err
+		}
+		return cns.PodIpInfo{}, err
+	}
+	return podIPInfos[0], nil
 }
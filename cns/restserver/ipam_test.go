@@ -14,6 +14,7 @@ import (
 	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -715,3 +716,61 @@ func TestIPAMMarkExistingIPConfigAsPending(t *testing.T) {
 		t.Fatalf("Expected to see ID %v in pending release ipconfigs, actual %+v", testPod1GUID, assignedIPConfigs)
 	}
 }
+
+type fakeIPAssignmentJournal struct {
+	assigned map[string]string
+	err      error
+}
+
+func (j *fakeIPAssignmentJournal) Assign(ipconfig cns.IPConfigurationStatus, podInfo cns.PodInfo) error { //nolint:gocritic // ignore hugeparam
+	if j.err != nil {
+		return j.err
+	}
+	j.assigned[ipconfig.IPAddress] = podInfo.Name()
+	return nil
+}
+
+func (j *fakeIPAssignmentJournal) Unassign(ipconfig cns.IPConfigurationStatus) error { //nolint:gocritic // ignore hugeparam
+	delete(j.assigned, ipconfig.IPAddress)
+	return j.err
+}
+
+func TestIPAMJournalsAssignments(t *testing.T) {
+	svc := getTestService()
+	journal := &fakeIPAssignmentJournal{assigned: map[string]string{}}
+	svc.IPAssignmentJournal = journal
+
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	err := UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state1.ID: state1})
+	require.NoError(t, err)
+
+	b, _ := testPod1Info.OrchestratorContext()
+	req := cns.IPConfigRequest{
+		PodInterfaceID:      testPod1Info.InterfaceID(),
+		InfraContainerID:    testPod1Info.InfraContainerID(),
+		OrchestratorContext: b,
+	}
+	_, err = requestIPConfigHelper(svc, req)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{testIP1: testPod1Info.Name()}, journal.assigned)
+
+	require.NoError(t, svc.releaseIPConfig(testPod1Info))
+	assert.Empty(t, journal.assigned)
+
+	// the IP is not assigned if it can't be journaled.
+	journal.err = errors.New("journal failure")
+	_, err = requestIPConfigHelper(svc, req)
+	require.Error(t, err)
+	assert.Empty(t, svc.GetAssignedIPConfigs())
+}
+
+func TestIPAMJournalRollsBackFailedAssignment(t *testing.T) {
+	svc := getTestService()
+	journal := &fakeIPAssignmentJournal{assigned: map[string]string{}}
+	svc.IPAssignmentJournal = journal
+
+	// the IPConfig is not in the state, so it can't be assigned after it was journaled.
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	require.Error(t, svc.assignIPConfig(state1, testPod1Info))
+	assert.Empty(t, journal.assigned)
+}
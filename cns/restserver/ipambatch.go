@@ -0,0 +1,329 @@
+package restserver
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/pkg/errors"
+)
+
+// BatchItemError is returned by the batch IPAM helpers when one of the items in a batch fails.
+// The whole batch has been rolled back when it is returned.
+type BatchItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("batch item %d failed: %v", e.Index, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
+// ipAssignment is an IPConfig newly assigned to a Pod by a request, which is undone if the batch is rolled back.
+type ipAssignment struct {
+	// prior is the IPConfig as it was before it was assigned, which it is returned to on rollback.
+	prior   cns.IPConfigurationStatus
+	podInfo cns.PodInfo
+	// sticky is set if the IPConfig should be reserved for the Pod when it is released.
+	sticky bool
+	// reservedAt is the time of the reservation the IPConfig was assigned from, if it was reserved for the Pod,
+	// which the reservation keeps when it is restored on rollback.
+	reservedAt time.Time
+}
+
+// requestIPConfigUntransacted returns the IPConfigs already assigned to the Pod, or assigns them, does not take a lock.
+// A Pod is assigned an IPv4 IPConfig, and an IPv6 IPConfig as well if the Node has IPv6 NCs. The returned
+// ipAssignments are the IPConfigs which were newly assigned. The namespace IP quotas are not enforced for
+// the desired IPs of requests which reconcile the IPs that Pods already have.
+func (service *HTTPRestService) requestIPConfigUntransacted(req *cns.IPConfigRequest, podInfo cns.PodInfo, ncIDs []string, enforceQuota bool) (cns.PodIpInfo, []*ipAssignment, error) {
+	// the IPv6 address of a dual-stack Pod is reconciled on its own.
+	if isIPv6Address(req.DesiredIPAddress) {
+		podIPInfo, prior, err := service.assignDesiredIPConfigUntransacted(podInfo, req.DesiredIPAddress, false)
+		if err != nil || prior.ID == "" {
+			return podIPInfo, nil, err
+		}
+		return podIPInfo, []*ipAssignment{{prior: prior, podInfo: podInfo}}, nil
+	}
+
+	podIPInfo, assignment, err := service.requestIPv4ConfigUntransacted(req, podInfo, ncIDs, enforceQuota)
+	if err != nil {
+		return podIPInfo, nil, err
+	}
+	assignments := []*ipAssignment{}
+	if assignment != nil {
+		assignments = append(assignments, assignment)
+	}
+
+	v6Assignment, err := service.requestIPv6ConfigUntransacted(req, podInfo, &podIPInfo)
+	if err != nil {
+		service.rollbackIPAssignmentsUntransacted(assignments)
+		return cns.PodIpInfo{}, nil, err
+	}
+	if v6Assignment != nil {
+		assignments = append(assignments, v6Assignment)
+	}
+	return podIPInfo, assignments, nil
+}
+
+// requestIPv4ConfigUntransacted returns the IPv4 IPConfig already assigned to the Pod, or assigns one, does not take
+// a lock. The returned ipAssignment is nil if no IPConfig was newly assigned.
+func (service *HTTPRestService) requestIPv4ConfigUntransacted(req *cns.IPConfigRequest, podInfo cns.PodInfo, ncIDs []string, enforceQuota bool) (cns.PodIpInfo, *ipAssignment, error) {
+	if podIPInfo, isExist, err := service.getExistingIPConfigUntransacted(podInfo); err != nil || isExist {
+		return podIPInfo, nil, err
+	}
+
+	// return desired IPConfig
+	if req.DesiredIPAddress != "" {
+		podIPInfo, prior, err := service.assignDesiredIPConfigUntransacted(podInfo, req.DesiredIPAddress, enforceQuota)
+		if err != nil || prior.ID == "" {
+			return podIPInfo, nil, err
+		}
+		return podIPInfo, &ipAssignment{prior: prior, podInfo: podInfo}, nil
+	}
+
+	// return the IPConfig held for a sticky Pod which has been recreated
+	sticky := isStickyIPConfigRequest(req)
+	if sticky {
+		reservedAt, _ := service.stickyIPs.reservedAt(podInfo)
+		podIPInfo, prior, err := service.assignReservedIPConfigUntransacted(podInfo)
+		if err != nil {
+			return podIPInfo, nil, err
+		}
+		if prior.ID != "" {
+			return podIPInfo, &ipAssignment{prior: prior, podInfo: podInfo, reservedAt: reservedAt}, nil
+		}
+	}
+
+	// return a free IPConfig from the preferred NCs, or any NC if there is no preference
+	podIPInfo, prior, err := service.assignAvailableIPConfigFromNCsUntransacted(podInfo, ncIDs)
+	if err != nil {
+		return podIPInfo, nil, err
+	}
+	return podIPInfo, &ipAssignment{prior: prior, podInfo: podInfo, sticky: sticky}, nil
+}
+
+// requestIPConfigsHelper assigns an IPConfig to each of the requests atomically under a single service lock.
+// If any of the requests fails, every IPConfig newly assigned by the batch is returned to its prior state, and a
+// *BatchItemError is returned for the failed request.
+func requestIPConfigsHelper(service *HTTPRestService, reqs []cns.IPConfigRequest) ([]cns.PodIpInfo, error) {
+	return service.requestIPConfigs(reqs, true)
+}
+
+// requestIPConfigs is requestIPConfigsHelper, which only enforces the namespace IP quotas for the desired IPs of the
+// requests if enforceQuota is set.
+func (service *HTTPRestService) requestIPConfigs(reqs []cns.IPConfigRequest, enforceQuota bool) ([]cns.PodIpInfo, error) {
+	podInfos := make([]cns.PodInfo, len(reqs))
+	ncIDs := make([][]string, len(reqs))
+	for i := range reqs {
+		podInfo, err := cns.NewPodInfoFromIPConfigRequest(reqs[i])
+		if err != nil {
+			return nil, &BatchItemError{Index: i, Err: errors.Wrapf(err, "failed to parse IPConfigRequest %v", reqs[i])}
+		}
+		podInfos[i] = podInfo
+		if service.NCSelector != nil {
+			if ncIDs[i], err = service.NCSelector.Select(&reqs[i]); err != nil {
+				return nil, &BatchItemError{Index: i, Err: errors.Wrapf(err, "failed to select NC for IPConfigRequest %v", reqs[i])}
+			}
+		}
+	}
+
+	service.Lock()
+	defer service.Unlock()
+	podIPInfos := make([]cns.PodIpInfo, len(reqs))
+	assignments := []*ipAssignment{}
+	for i := range reqs {
+		podIPInfo, itemAssignments, err := service.requestIPConfigUntransacted(&reqs[i], podInfos[i], ncIDs[i], enforceQuota)
+		if err != nil {
+			service.rollbackIPAssignmentsUntransacted(assignments)
+			return nil, &BatchItemError{Index: i, Err: err}
+		}
+		podIPInfos[i] = podIPInfo
+		assignments = append(assignments, itemAssignments...)
+	}
+	for _, a := range assignments {
+		if a.sticky {
+			service.stickyIPs.track(a.prior.ID)
+		}
+	}
+	return podIPInfos, nil
+}
+
+// rollbackIPAssignmentsUntransacted returns the passed IPConfigs to the state they had before they were assigned,
+// in reverse order, returning any IPConfig which was reserved for a sticky Pod to its reservation. Does not take a lock.
+func (service *HTTPRestService) rollbackIPAssignmentsUntransacted(assignments []*ipAssignment) {
+	for i := len(assignments) - 1; i >= 0; i-- {
+		a := assignments[i]
+		if _, err := service.updateIPConfigState(a.prior.ID, a.prior.GetState(), a.prior.PodInfo); err != nil {
+			logger.Errorf("[rollbackIPAssignments] failed to roll back assignment of IPConfig %s to Pod %+v: %v", a.prior.ID, a.podInfo, err)
+			continue
+		}
+		if isIPv6Address(a.prior.IPAddress) {
+			delete(service.PodIPv6IDByPodInterfaceKey, a.podInfo.Key())
+		} else {
+			delete(service.PodIPIDByPodInterfaceKey, a.podInfo.Key())
+		}
+		if a.reservedAt.IsZero() {
+			service.stickyIPs.release(a.prior.ID, a.podInfo)
+		} else {
+			service.stickyIPs.releaseAt(a.prior.ID, a.podInfo, a.reservedAt)
+		}
+		if service.IPAssignmentJournal != nil {
+			if err := service.IPAssignmentJournal.Unassign(a.prior); err != nil {
+				logger.Errorf("[rollbackIPAssignments] failed to journal rollback of IP %s: %v", a.prior.IPAddress, err)
+			}
+		}
+	}
+}
+
+// releaseIPConfigs releases the IPConfigs assigned to each of the Pods atomically under a single service lock.
+// Pods which do not have an IPConfig are ignored. If any release fails, the IPConfigs released by the batch
+// are assigned to their Pods again and a *BatchItemError is returned for the failed Pod.
+func (service *HTTPRestService) releaseIPConfigs(podInfos []cns.PodInfo) error {
+	service.Lock()
+	defer service.Unlock()
+
+	type release struct {
+		ipconfig cns.IPConfigurationStatus
+		podInfo  cns.PodInfo
+		sticky   bool
+	}
+	released := []release{}
+	for i, podInfo := range podInfos {
+		ipIDs := service.podIPIDsUntransacted(podInfo)
+		if len(ipIDs) == 0 {
+			logger.Errorf("[releaseIPConfigs] ignoring request to release, no allocation found for pod [%+v]", podInfo)
+			continue
+		}
+		var err error
+		for _, ipID := range ipIDs {
+			ipconfig, ok := service.PodIPConfigState[ipID]
+			if !ok {
+				//nolint:goerr113
+				err = fmt.Errorf("pod to IPID %s exists, but IPID to IPConfig doesn't exist, CNS State potentially corrupt", ipID)
+				break
+			}
+			_, sticky := service.stickyIPs.assigned[ipID]
+			if _, err = service.unassignIPConfig(ipconfig, podInfo); err != nil {
+				break
+			}
+			released = append(released, release{ipconfig: ipconfig, podInfo: podInfo, sticky: sticky})
+		}
+		if err == nil {
+			continue
+		}
+		for j := len(released) - 1; j >= 0; j-- {
+			r := released[j]
+			if e := service.assignIPConfig(r.ipconfig, r.podInfo); e != nil {
+				logger.Errorf("[releaseIPConfigs] failed to roll back release of IP %s from Pod %+v: %v", r.ipconfig.IPAddress, r.podInfo, e)
+				continue
+			}
+			if r.sticky {
+				service.stickyIPs.track(r.ipconfig.ID)
+			}
+		}
+		return &BatchItemError{Index: i, Err: err}
+	}
+	return nil
+}
+
+// batchItemResponses builds the per item Responses for a batch of n items which failed with the passed error
+// and return code, or succeeded if the error is nil.
+func batchItemResponses(n int, err error, returnCode types.ResponseCode) (cns.Response, []cns.Response) {
+	responses := make([]cns.Response, n)
+	if err == nil {
+		return cns.Response{ReturnCode: types.Success}, responses
+	}
+	failed := -1
+	var itemErr *BatchItemError
+	if errors.As(err, &itemErr) {
+		failed = itemErr.Index
+	}
+	for i := range responses {
+		if i == failed {
+			responses[i] = cns.Response{ReturnCode: returnCode, Message: itemErr.Err.Error()}
+			continue
+		}
+		responses[i] = cns.Response{ReturnCode: types.UnexpectedError, Message: "batch rolled back"}
+	}
+	return cns.Response{ReturnCode: returnCode, Message: err.Error()}, responses
+}
+
+// validateIPConfigRequests validates each of the requests in a batch and returns their PodInfos, or a
+// *BatchItemError and the return code for the first request which is not valid.
+func (service *HTTPRestService) validateIPConfigRequests(reqs []cns.IPConfigRequest) ([]cns.PodInfo, types.ResponseCode, error) {
+	podInfos := make([]cns.PodInfo, len(reqs))
+	for i := range reqs {
+		podInfo, returnCode, returnMessage := service.validateIPConfigRequest(reqs[i])
+		if returnCode != types.Success {
+			return nil, returnCode, &BatchItemError{Index: i, Err: errors.New(returnMessage)}
+		}
+		podInfos[i] = podInfo
+	}
+	return podInfos, types.Success, nil
+}
+
+func (service *HTTPRestService) requestIPConfigsHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.IPConfigsRequest
+	err := service.Listener.Decode(w, r, &req)
+	operationName := "requestIPConfigsHandler"
+	logger.Request(service.Name+operationName, req, err)
+	if err != nil {
+		return
+	}
+
+	resp := &cns.IPConfigsResponse{}
+	_, returnCode, err := service.validateIPConfigRequests(req.IPConfigRequests)
+	var podIPInfos []cns.PodIpInfo
+	if err == nil {
+		podIPInfos, err = requestIPConfigsHelper(service, req.IPConfigRequests)
+		returnCode = types.FailedToAllocateIPConfig
+		if errors.Is(err, ErrNamespaceIPQuotaExceeded) {
+			returnCode = types.NamespaceIPQuotaExceeded
+		}
+	}
+	response, responses := batchItemResponses(len(req.IPConfigRequests), err, returnCode)
+	resp.Response = response
+	resp.Results = make([]cns.IPConfigResult, len(req.IPConfigRequests))
+	for i := range resp.Results {
+		resp.Results[i].Response = responses[i]
+		if err == nil {
+			resp.Results[i].PodIpInfo = podIPInfos[i]
+		}
+	}
+	w.Header().Set(cnsReturnCode, resp.Response.ReturnCode.String())
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name+operationName, req, resp, resp.Response.ReturnCode, err)
+}
+
+func (service *HTTPRestService) releaseIPConfigsHandler(w http.ResponseWriter, r *http.Request) {
+	var req cns.IPConfigsRequest
+	err := service.Listener.Decode(w, r, &req)
+	operationName := "releaseIPConfigsHandler"
+	logger.Request(service.Name+operationName, req, err)
+	if err != nil {
+		return
+	}
+
+	resp := &cns.IPConfigsResponse{}
+	podInfos, returnCode, err := service.validateIPConfigRequests(req.IPConfigRequests)
+	if err == nil {
+		err = service.releaseIPConfigs(podInfos)
+		returnCode = types.UnexpectedError
+	}
+	response, responses := batchItemResponses(len(req.IPConfigRequests), err, returnCode)
+	resp.Response = response
+	resp.Results = make([]cns.IPConfigResult, len(req.IPConfigRequests))
+	for i := range resp.Results {
+		resp.Results[i].Response = responses[i]
+	}
+	w.Header().Set(cnsReturnCode, resp.Response.ReturnCode.String())
+	err = service.Listener.Encode(w, &resp)
+	logger.ResponseEx(service.Name+operationName, req, resp, resp.Response.ReturnCode, err)
+}
@@ -0,0 +1,109 @@
+package restserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIPConfigsRollback(t *testing.T) {
+	svc := getTestService()
+	journal := &fakeIPAssignmentJournal{assigned: map[string]string{}}
+	svc.IPAssignmentJournal = journal
+	svc.SetNamespaceIPQuotas(map[string]cns.NamespaceIPQuota{
+		"reserved": {ReservedIPs: 1},
+	})
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	state2 := NewPodState(testIP2, 24, testPod2GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+		state2.ID: state2,
+	}))
+
+	// the second Pod would take the reserved IP, so the IP assigned to the first is rolled back.
+	_, err := requestIPConfigsHelper(svc, []cns.IPConfigRequest{
+		stickyIPConfigRequest(t, testPod1Info, false),
+		stickyIPConfigRequest(t, testPod2Info, false),
+	})
+	var itemErr *BatchItemError
+	require.ErrorAs(t, err, &itemErr)
+	assert.Equal(t, 1, itemErr.Index)
+	assert.ErrorIs(t, err, ErrNamespaceIPQuotaExceeded)
+	assert.Empty(t, svc.GetAssignedIPConfigs())
+	assert.Empty(t, svc.PodIPIDByPodInterfaceKey)
+	assert.Empty(t, journal.assigned)
+
+	svc.SetNamespaceIPQuotas(nil)
+	podIPInfos, err := requestIPConfigsHelper(svc, []cns.IPConfigRequest{
+		stickyIPConfigRequest(t, testPod1Info, false),
+		stickyIPConfigRequest(t, testPod3Info, false),
+	})
+	require.NoError(t, err)
+	assert.Len(t, podIPInfos, 2)
+	assert.Len(t, svc.GetAssignedIPConfigs(), 2)
+
+	require.NoError(t, svc.releaseIPConfigs([]cns.PodInfo{testPod1Info, testPod3Info, testPod2Info}))
+	assert.Empty(t, svc.GetAssignedIPConfigs())
+	assert.Empty(t, journal.assigned)
+}
+
+func TestRequestIPConfigsRollbackRestoresPriorState(t *testing.T) {
+	svc := getTestService()
+	journal := &fakeIPAssignmentJournal{assigned: map[string]string{}}
+	svc.IPAssignmentJournal = journal
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+	}))
+	_, err := svc.updateIPConfigState(state1.ID, types.PendingProgramming, nil)
+	require.NoError(t, err)
+
+	// the desired IP is PendingProgramming, and the second Pod has no IP left, so the desired IP is rolled back.
+	req1 := stickyIPConfigRequest(t, testPod1Info, false)
+	req1.DesiredIPAddress = testIP1
+	_, err = requestIPConfigsHelper(svc, []cns.IPConfigRequest{
+		req1,
+		stickyIPConfigRequest(t, testPod2Info, false),
+	})
+	var itemErr *BatchItemError
+	require.ErrorAs(t, err, &itemErr)
+	assert.Equal(t, 1, itemErr.Index)
+	rolledBack := svc.PodIPConfigState[state1.ID]
+	assert.Equal(t, types.PendingProgramming, rolledBack.GetState())
+	assert.Nil(t, rolledBack.PodInfo)
+	assert.Empty(t, svc.PodIPIDByPodInterfaceKey)
+	assert.Empty(t, journal.assigned)
+}
+
+func TestRequestIPConfigsHandlerReturnsResultsOnValidationFailure(t *testing.T) {
+	svc := getTestService()
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+	}))
+
+	body, err := json.Marshal(cns.IPConfigsRequest{IPConfigRequests: []cns.IPConfigRequest{
+		stickyIPConfigRequest(t, testPod1Info, false),
+		{PodInterfaceID: "invalid", OrchestratorContext: json.RawMessage("1")},
+	}})
+	require.NoError(t, err)
+	for _, handler := range []http.HandlerFunc{svc.requestIPConfigsHandler, svc.releaseIPConfigsHandler} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, cns.RequestIPConfigs, bytes.NewReader(body)))
+
+		var resp cns.IPConfigsResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.NotEqual(t, types.Success, resp.Response.ReturnCode)
+		require.Len(t, resp.Results, 2)
+		assert.Equal(t, types.UnexpectedError, resp.Results[0].Response.ReturnCode)
+		assert.Equal(t, resp.Response.ReturnCode, resp.Results[1].Response.ReturnCode)
+	}
+	assert.Empty(t, svc.GetAssignedIPConfigs())
+}
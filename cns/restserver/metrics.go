@@ -49,12 +49,20 @@ var syncHostNcVersion = prometheus.NewHistogramVec(
 	[]string{"success"},
 )
 
+var orphanedIPsReclaimed = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "orphaned_ips_reclaimed_total",
+		Help: "Assigned IPs reclaimed because their Pod no longer exists",
+	},
+)
+
 func init() {
 	metrics.Registry.MustRegister(
 		httpRequestLatency,
 		ipAssignmentLatency,
 		ipConfigStatusStateTransitionTime,
 		syncHostNcVersion,
+		orphanedIPsReclaimed,
 	)
 }
 
@@ -0,0 +1,82 @@
+package restserver
+
+import (
+	"sync"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/pkg/errors"
+)
+
+// NCSelectionPolicy is the policy used to choose the NetworkContainer that a Pod IP
+// is assigned from when the Node has more than one.
+type NCSelectionPolicy string
+
+const (
+	// FirstAvailableNCSelection assigns from the first NetworkContainer, in NodeNetworkConfig order, with a free IP.
+	FirstAvailableNCSelection NCSelectionPolicy = "FirstAvailable"
+	// PodSubnetAnnotationNCSelection assigns from the NetworkContainers in the subnet named by the
+	// cns.PodSubnetAnnotation on the Pod, falling back to FirstAvailableNCSelection without it.
+	PodSubnetAnnotationNCSelection NCSelectionPolicy = "PodSubnetAnnotation"
+)
+
+// ErrNoNCForSubnet indicates that a Pod requested a subnet that none of the Node's NetworkContainers are in.
+var ErrNoNCForSubnet = errors.New("no network container for requested subnet")
+
+type selectableNC struct {
+	id     string
+	subnet string
+}
+
+// NCSelector orders the NetworkContainers that an IP may be assigned from for a request.
+// It is a NodeNetworkConfig listener, and learns the NetworkContainers and their order from
+// the NodeNetworkConfig Status.
+type NCSelector struct {
+	sync.RWMutex
+	policy NCSelectionPolicy
+	ncs    []selectableNC
+}
+
+func NewNCSelector(policy NCSelectionPolicy) *NCSelector {
+	if policy != PodSubnetAnnotationNCSelection {
+		policy = FirstAvailableNCSelection
+	}
+	return &NCSelector{policy: policy}
+}
+
+// Update records the NetworkContainers in the passed NodeNetworkConfig.
+func (s *NCSelector) Update(nnc *v1alpha.NodeNetworkConfig) error {
+	ncs := make([]selectableNC, len(nnc.Status.NetworkContainers))
+	for i := range nnc.Status.NetworkContainers {
+		ncs[i] = selectableNC{
+			id:     nnc.Status.NetworkContainers[i].ID,
+			subnet: nnc.Status.NetworkContainers[i].SubnetName,
+		}
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.ncs = ncs
+	return nil
+}
+
+// Select returns the IDs of the NetworkContainers that an IP may be assigned from for the request,
+// in order of preference. A nil result means that there is no preference.
+func (s *NCSelector) Select(req *cns.IPConfigRequest) ([]string, error) {
+	s.RLock()
+	defer s.RUnlock()
+	if len(s.ncs) < 2 { //nolint:gomnd // no choice to make with a single NC
+		return nil, nil
+	}
+	subnet := req.PodAnnotations[cns.PodSubnetAnnotation]
+	ids := []string{}
+	for i := range s.ncs {
+		if s.policy == PodSubnetAnnotationNCSelection && subnet != "" && s.ncs[i].subnet != subnet {
+			continue
+		}
+		ids = append(ids, s.ncs[i].id)
+	}
+	if len(ids) == 0 {
+		return nil, errors.Wrapf(ErrNoNCForSubnet, "subnet: %s", subnet)
+	}
+	return ids, nil
+}
@@ -0,0 +1,142 @@
+package restserver
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/crd/nodenetworkconfig/api/v1alpha"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testOverflowNCID   = "9b8a1fe1-16b8-4ed9-a3e0-2b0c9b6f1a5e"
+	testPrimarySubnet  = "primary"
+	testOverflowSubnet = "overflow"
+)
+
+var testMultiNCNNC = &v1alpha.NodeNetworkConfig{
+	Status: v1alpha.NodeNetworkConfigStatus{
+		NetworkContainers: []v1alpha.NetworkContainer{
+			{ID: testNCID, SubnetName: testPrimarySubnet},
+			{ID: testOverflowNCID, SubnetName: testOverflowSubnet},
+		},
+	},
+}
+
+func TestNCSelectorSelect(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      NCSelectionPolicy
+		nnc         *v1alpha.NodeNetworkConfig
+		annotations map[string]string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:   "single NC has no preference",
+			policy: PodSubnetAnnotationNCSelection,
+			nnc: &v1alpha.NodeNetworkConfig{
+				Status: v1alpha.NodeNetworkConfigStatus{
+					NetworkContainers: []v1alpha.NetworkContainer{{ID: testNCID, SubnetName: testPrimarySubnet}},
+				},
+			},
+			want: nil,
+		},
+		{
+			name:   "first available keeps NNC order",
+			policy: FirstAvailableNCSelection,
+			nnc:    testMultiNCNNC,
+			want:   []string{testNCID, testOverflowNCID},
+		},
+		{
+			name:        "first available ignores annotation",
+			policy:      FirstAvailableNCSelection,
+			nnc:         testMultiNCNNC,
+			annotations: map[string]string{cns.PodSubnetAnnotation: testOverflowSubnet},
+			want:        []string{testNCID, testOverflowNCID},
+		},
+		{
+			name:   "annotation policy without annotation keeps NNC order",
+			policy: PodSubnetAnnotationNCSelection,
+			nnc:    testMultiNCNNC,
+			want:   []string{testNCID, testOverflowNCID},
+		},
+		{
+			name:        "annotation policy selects subnet",
+			policy:      PodSubnetAnnotationNCSelection,
+			nnc:         testMultiNCNNC,
+			annotations: map[string]string{cns.PodSubnetAnnotation: testOverflowSubnet},
+			want:        []string{testOverflowNCID},
+		},
+		{
+			name:        "annotation policy unknown subnet",
+			policy:      PodSubnetAnnotationNCSelection,
+			nnc:         testMultiNCNNC,
+			annotations: map[string]string{cns.PodSubnetAnnotation: "unknown"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewNCSelector(tt.policy)
+			require.NoError(t, s.Update(tt.nnc))
+			got, err := s.Select(&cns.IPConfigRequest{PodAnnotations: tt.annotations})
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrNoNCForSubnet)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIPAMAssignFromSelectedNC(t *testing.T) {
+	svc := getTestService()
+	svc.NCSelector = NewNCSelector(PodSubnetAnnotationNCSelection)
+	require.NoError(t, svc.NCSelector.Update(testMultiNCNNC))
+
+	req := generateNetworkContainerRequest(map[string]cns.SecondaryIPConfig{
+		testPod1GUID: newSecondaryIPConfig(testIP1, -1),
+	}, testNCID, "-1")
+	require.Equal(t, types.Success, svc.CreateOrUpdateNetworkContainerInternal(req))
+	req = generateNetworkContainerRequest(map[string]cns.SecondaryIPConfig{
+		testPod2GUID: newSecondaryIPConfig(testIP2, -1),
+	}, testOverflowNCID, "-1")
+	require.Equal(t, types.Success, svc.CreateOrUpdateNetworkContainerInternal(req))
+
+	// the annotated Pod gets an IP from the overflow subnet even though the primary has one free.
+	b, _ := testPod1Info.OrchestratorContext()
+	podIPInfo, err := requestIPConfigHelper(svc, cns.IPConfigRequest{
+		PodInterfaceID:      testPod1Info.InterfaceID(),
+		InfraContainerID:    testPod1Info.InfraContainerID(),
+		OrchestratorContext: b,
+		PodAnnotations:      map[string]string{cns.PodSubnetAnnotation: testOverflowSubnet},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testIP2, podIPInfo.PodIPConfig.IPAddress)
+
+	// the overflow subnet is now exhausted.
+	b, _ = testPod2Info.OrchestratorContext()
+	_, err = requestIPConfigHelper(svc, cns.IPConfigRequest{
+		PodInterfaceID:      testPod2Info.InterfaceID(),
+		InfraContainerID:    testPod2Info.InfraContainerID(),
+		OrchestratorContext: b,
+		PodAnnotations:      map[string]string{cns.PodSubnetAnnotation: testOverflowSubnet},
+	})
+	require.Error(t, err)
+
+	// without the annotation, the Pod gets an IP from the primary subnet.
+	podIPInfo, err = requestIPConfigHelper(svc, cns.IPConfigRequest{
+		PodInterfaceID:      testPod2Info.InterfaceID(),
+		InfraContainerID:    testPod2Info.InfraContainerID(),
+		OrchestratorContext: b,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, testIP1, podIPInfo.PodIPConfig.IPAddress)
+	ipConfig := svc.PodIPConfigState[testPod1GUID]
+	assert.Equal(t, types.Assigned, ipConfig.GetState())
+}
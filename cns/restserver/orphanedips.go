@@ -0,0 +1,167 @@
+package restserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultOrphanedIPReconcileInterval is how often the OrphanedIPReconciler checks for orphaned IPs by default.
+	DefaultOrphanedIPReconcileInterval = time.Minute
+	// DefaultOrphanedIPGracePeriod is how long an IP must be orphaned before it is reclaimed by default.
+	DefaultOrphanedIPGracePeriod = 5 * time.Minute
+)
+
+// PodInfoLister lists the Pods on the Node, including those which have not been assigned an IP yet.
+type PodInfoLister interface {
+	ListPodInfos() ([]cns.PodInfo, error)
+}
+
+// PodInfoListerFunc allows one-off functional implementations of the PodInfoLister interface.
+type PodInfoListerFunc func() ([]cns.PodInfo, error)
+
+// ListPodInfos implements PodInfoLister on PodInfoListerFunc.
+func (f PodInfoListerFunc) ListPodInfos() ([]cns.PodInfo, error) {
+	return f()
+}
+
+// OrphanedIPReconciler periodically reclaims Assigned IPs whose Pod no longer exists on the Node,
+// which happens when a CNI DEL never reaches CNS.
+// An IP is only reclaimed after its Pod has been missing for the grace period, so that a Pod which
+// has been assigned an IP but is not yet visible to the PodInfoLister is not mistaken for an orphan.
+type OrphanedIPReconciler struct {
+	service     *HTTPRestService
+	lister      PodInfoLister
+	interval    time.Duration
+	gracePeriod time.Duration
+	// orphanedSince is when each IPConfig ID was first seen without a Pod.
+	orphanedSince map[string]time.Time
+	now           func() time.Time
+}
+
+// NewOrphanedIPReconciler creates an OrphanedIPReconciler which checks the Assigned IPs of the service
+// against the Pods from the lister. Zero durations are replaced with the defaults.
+func NewOrphanedIPReconciler(service *HTTPRestService, lister PodInfoLister, interval, gracePeriod time.Duration) *OrphanedIPReconciler {
+	if interval <= 0 {
+		interval = DefaultOrphanedIPReconcileInterval
+	}
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultOrphanedIPGracePeriod
+	}
+	return &OrphanedIPReconciler{
+		service:       service,
+		lister:        lister,
+		interval:      interval,
+		gracePeriod:   gracePeriod,
+		orphanedSince: map[string]time.Time{},
+		now:           time.Now,
+	}
+}
+
+// Start runs the reconcile loop until the context is canceled.
+func (r *OrphanedIPReconciler) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "orphaned IP reconciler exiting")
+		case <-ticker.C:
+			if _, err := r.reconcile(); err != nil {
+				logger.Errorf("[OrphanedIPReconciler] failed to reconcile: %v", err)
+			}
+		}
+	}
+}
+
+// podName returns the namespace and name of the Pod of the PodInfo.
+func podName(podInfo cns.PodInfo) string {
+	return podInfo.Namespace() + "/" + podInfo.Name()
+}
+
+// podInstance identifies an instance of a Pod by its name and PodInfo key, which has the infra container and
+// interface of the Pod, since a recreated StatefulSet Pod has the same name as the Pod it replaces.
+func podInstance(podInfo cns.PodInfo) string {
+	return podName(podInfo) + "/" + podInfo.Key()
+}
+
+// currentPodInstances returns the current instances of each Pod with Assigned IPs, which is the instance assigned an
+// IP last. Instances assigned at the same time are all current, as it can't be told which one replaced the other.
+// The service must be locked.
+func (r *OrphanedIPReconciler) currentPodInstances() map[string]map[string]struct{} {
+	current := map[string]map[string]struct{}{}
+	assignedAt := map[string]time.Time{}
+	for _, ipconfig := range r.service.PodIPConfigState {
+		if ipconfig.GetState() != types.Assigned || ipconfig.PodInfo == nil {
+			continue
+		}
+		name, instance := podName(ipconfig.PodInfo), podInstance(ipconfig.PodInfo)
+		last, ok := assignedAt[name]
+		switch {
+		case !ok || ipconfig.LastStateTransition.After(last):
+			current[name] = map[string]struct{}{instance: {}}
+			assignedAt[name] = ipconfig.LastStateTransition
+		case ipconfig.LastStateTransition.Equal(last):
+			current[name][instance] = struct{}{}
+		}
+	}
+	return current
+}
+
+// reconcile reclaims the IPs which have been orphaned for longer than the grace period
+// and returns how many were reclaimed. An IP is orphaned if its Pod no longer exists,
+// or if it was assigned to an earlier instance of a Pod which has since been recreated with the same name.
+func (r *OrphanedIPReconciler) reconcile() (int, error) {
+	podInfos, err := r.lister.ListPodInfos()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list Pods")
+	}
+	// Pods are matched by namespace and name, since a Pod's IP is not reported until after it is assigned.
+	pods := make(map[string]struct{}, len(podInfos))
+	for _, podInfo := range podInfos {
+		pods[podName(podInfo)] = struct{}{}
+	}
+
+	now := r.now()
+	r.service.Lock()
+	defer r.service.Unlock()
+
+	current := r.currentPodInstances()
+	orphanedSince := map[string]time.Time{}
+	reclaimed := 0
+	for id, ipconfig := range r.service.PodIPConfigState {
+		if ipconfig.GetState() != types.Assigned || ipconfig.PodInfo == nil {
+			continue
+		}
+		if _, ok := pods[podName(ipconfig.PodInfo)]; ok {
+			if _, ok := current[podName(ipconfig.PodInfo)][podInstance(ipconfig.PodInfo)]; ok {
+				continue
+			}
+		}
+		since, ok := r.orphanedSince[id]
+		if !ok {
+			since = now
+		}
+		if now.Sub(since) < r.gracePeriod {
+			orphanedSince[id] = since
+			continue
+		}
+		podInfo := ipconfig.PodInfo
+		if _, err := r.service.unassignIPConfig(ipconfig, podInfo); err != nil {
+			logger.Errorf("[OrphanedIPReconciler] failed to reclaim IP %s from Pod %+v: %v", ipconfig.IPAddress, podInfo, err)
+			orphanedSince[id] = since
+			continue
+		}
+		logger.Printf("[OrphanedIPReconciler] reclaimed IP %s orphaned since %s from Pod %+v", ipconfig.IPAddress, since, podInfo)
+		orphanedIPsReclaimed.Inc()
+		reclaimed++
+	}
+	// IPs which are no longer orphaned are forgotten, so they restart the grace period if they are orphaned again.
+	r.orphanedSince = orphanedSince
+	return reclaimed, nil
+}
@@ -0,0 +1,142 @@
+package restserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOrphanedIPReconciler(t *testing.T) {
+	svc := getTestService()
+	state1, _ := NewPodStateWithOrchestratorContext(testIP1, testPod1GUID, testNCID, types.Assigned, 24, 0, testPod1Info)
+	state2, _ := NewPodStateWithOrchestratorContext(testIP2, testPod2GUID, testNCID, types.Assigned, 24, 0, testPod2Info)
+	state3 := NewPodState(testIP3, 24, testPod3GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+		state2.ID: state2,
+		state3.ID: state3,
+	}))
+
+	// only Pod 1 still exists.
+	pods := []cns.PodInfo{testPod1Info}
+	lister := PodInfoListerFunc(func() ([]cns.PodInfo, error) { return pods, nil })
+	now := time.Unix(0, 0)
+	r := NewOrphanedIPReconciler(svc, lister, time.Minute, 5*time.Minute)
+	r.now = func() time.Time { return now }
+
+	// Pod 2 is missing but still within the grace period.
+	reclaimed, err := r.reconcile()
+	require.NoError(t, err)
+	assert.Equal(t, 0, reclaimed)
+	assert.Len(t, svc.GetAssignedIPConfigs(), 2)
+
+	now = now.Add(5 * time.Minute)
+	reclaimed, err = r.reconcile()
+	require.NoError(t, err)
+	assert.Equal(t, 1, reclaimed)
+	assigned := svc.GetAssignedIPConfigs()
+	require.Len(t, assigned, 1)
+	assert.Equal(t, testPod1GUID, assigned[0].ID)
+	ipconfig := svc.PodIPConfigState[testPod2GUID]
+	assert.Equal(t, types.Available, ipconfig.GetState())
+	assert.NotContains(t, svc.PodIPIDByPodInterfaceKey, testPod2Info.Key())
+}
+
+func TestOrphanedIPReconcilerGracePeriodResets(t *testing.T) {
+	svc := getTestService()
+	state1, _ := NewPodStateWithOrchestratorContext(testIP1, testPod1GUID, testNCID, types.Assigned, 24, 0, testPod1Info)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state1.ID: state1}))
+
+	pods := []cns.PodInfo{}
+	lister := PodInfoListerFunc(func() ([]cns.PodInfo, error) { return pods, nil })
+	now := time.Unix(0, 0)
+	r := NewOrphanedIPReconciler(svc, lister, time.Minute, 5*time.Minute)
+	r.now = func() time.Time { return now }
+
+	_, err := r.reconcile()
+	require.NoError(t, err)
+
+	// the Pod shows up, so the IP is no longer orphaned.
+	pods = []cns.PodInfo{testPod1Info}
+	now = now.Add(3 * time.Minute)
+	_, err = r.reconcile()
+	require.NoError(t, err)
+
+	// it goes missing again, and the grace period starts over.
+	pods = []cns.PodInfo{}
+	now = now.Add(3 * time.Minute)
+	reclaimed, err := r.reconcile()
+	require.NoError(t, err)
+	assert.Equal(t, 0, reclaimed)
+	assert.Len(t, svc.GetAssignedIPConfigs(), 1)
+}
+
+func TestOrphanedIPReconcilerKeepsIPsOfStartingPods(t *testing.T) {
+	svc := getTestService()
+	state1, _ := NewPodStateWithOrchestratorContext(testIP1, testPod1GUID, testNCID, types.Assigned, 24, 0, testPod1Info)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state1.ID: state1}))
+
+	// the Pod has been assigned its IP by CNS, but its status does not report it yet.
+	pods := []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: testPod1Info.Name(), Namespace: testPod1Info.Namespace()}},
+	}
+	lister := PodInfoListerFunc(func() ([]cns.PodInfo, error) { return cns.KubePodsToPodInfos(pods), nil })
+	now := time.Unix(0, 0)
+	r := NewOrphanedIPReconciler(svc, lister, time.Minute, 5*time.Minute)
+	r.now = func() time.Time { return now }
+
+	_, err := r.reconcile()
+	require.NoError(t, err)
+	now = now.Add(5 * time.Minute)
+	reclaimed, err := r.reconcile()
+	require.NoError(t, err)
+	assert.Equal(t, 0, reclaimed)
+	assert.Len(t, svc.GetAssignedIPConfigs(), 1)
+}
+
+func TestOrphanedIPReconcilerReclaimsIPsOfRecreatedPods(t *testing.T) {
+	cns.GlobalPodInfoScheme = cns.InterfaceIDPodInfoScheme
+	defer func() { cns.GlobalPodInfoScheme = cns.KubernetesPodInfoScheme }()
+
+	svc := getTestService()
+	// the StatefulSet Pod was recreated with the same name in a new infra container, and the DEL of the old one was lost.
+	oldPodInfo := cns.NewPodInfo("898fb8-eth0", testPod1GUID, "web-0", "default")
+	newPodInfo := cns.NewPodInfo("b21e1e-eth0", testPod2GUID, "web-0", "default")
+	state1, _ := NewPodStateWithOrchestratorContext(testIP1, testPod1GUID, testNCID, types.Assigned, 24, 0, oldPodInfo)
+	state2, _ := NewPodStateWithOrchestratorContext(testIP2, testPod2GUID, testNCID, types.Assigned, 24, 0, newPodInfo)
+	state1.LastStateTransition = time.Unix(100, 0)
+	state2.LastStateTransition = time.Unix(200, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+		state2.ID: state2,
+	}))
+	svc.PodIPIDByPodInterfaceKey[oldPodInfo.Key()] = state1.ID
+	svc.PodIPIDByPodInterfaceKey[newPodInfo.Key()] = state2.ID
+
+	pods := []cns.PodInfo{cns.NewPodInfo("", "", "web-0", "default")}
+	lister := PodInfoListerFunc(func() ([]cns.PodInfo, error) { return pods, nil })
+	now := time.Unix(0, 0)
+	r := NewOrphanedIPReconciler(svc, lister, time.Minute, 5*time.Minute)
+	r.now = func() time.Time { return now }
+
+	reclaimed, err := r.reconcile()
+	require.NoError(t, err)
+	assert.Equal(t, 0, reclaimed)
+
+	// only the IP of the old instance is reclaimed once the grace period has passed.
+	now = now.Add(5 * time.Minute)
+	reclaimed, err = r.reconcile()
+	require.NoError(t, err)
+	assert.Equal(t, 1, reclaimed)
+	assigned := svc.GetAssignedIPConfigs()
+	require.Len(t, assigned, 1)
+	assert.Equal(t, testPod2GUID, assigned[0].ID)
+	assert.NotContains(t, svc.PodIPIDByPodInterfaceKey, oldPodInfo.Key())
+	assert.Equal(t, state2.ID, svc.PodIPIDByPodInterfaceKey[newPodInfo.Key()])
+}
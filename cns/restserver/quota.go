@@ -0,0 +1,98 @@
+package restserver
+
+import (
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/pkg/errors"
+)
+
+// ErrNamespaceIPQuotaExceeded indicates that assigning an IP to a Pod would exceed the IP quota of its
+// namespace, or would use an IP which is reserved for another namespace.
+var ErrNamespaceIPQuotaExceeded = errors.New("namespace IP quota exceeded")
+
+// SetNamespaceIPQuotas sets the IP quotas by namespace which are enforced when assigning IPs to Pods.
+func (service *HTTPRestService) SetNamespaceIPQuotas(quotas map[string]cns.NamespaceIPQuota) {
+	service.Lock()
+	defer service.Unlock()
+	service.namespaceIPQuotas = quotas
+}
+
+// namespaceIPUsage is the IP usage that the namespace IP quotas are enforced against.
+type namespaceIPUsage struct {
+	// assigned is the number of IPs assigned by namespace.
+	assigned map[string]int
+	// available is the number of IPs which can be assigned by NC.
+	available map[string]int
+}
+
+// namespaceIPUsageUntransacted counts the IPs assigned to each namespace and the IPs available in each NC.
+// Does not take a lock.
+func (service *HTTPRestService) namespaceIPUsageUntransacted() namespaceIPUsage {
+	usage := namespaceIPUsage{assigned: map[string]int{}, available: map[string]int{}}
+	for _, ipconfig := range service.PodIPConfigState {
+		// the quota is of Pods, so the IPv6 IP of a dual-stack Pod is not counted.
+		if isIPv6Address(ipconfig.IPAddress) {
+			continue
+		}
+		switch ipconfig.GetState() {
+		case types.Assigned:
+			if ipconfig.PodInfo != nil {
+				usage.assigned[ipconfig.PodInfo.Namespace()]++
+			}
+		case types.Available:
+			// IPs held for sticky Pods can't be assigned to another Pod.
+			if !service.stickyIPs.isReserved(ipconfig.ID) {
+				usage.available[ipconfig.NCID]++
+			}
+		}
+	}
+	return usage
+}
+
+// checkNamespaceIPQuotaUntransacted returns an error if the IPConfig can not be assigned to a Pod in the
+// namespace, either because the namespace is at its max or because the remaining Available IPs of the
+// IPConfig's NC are reserved for other namespaces. Does not take a lock.
+func (service *HTTPRestService) checkNamespaceIPQuotaUntransacted(namespace string, ipconfig cns.IPConfigurationStatus) error { //nolint:gocritic // ignore hugeparam
+	if len(service.namespaceIPQuotas) == 0 {
+		return nil
+	}
+	return service.namespaceIPUsageUntransacted().check(service.namespaceIPQuotas, namespace, ipconfig)
+}
+
+// check returns an error if the IPConfig can not be assigned to a Pod in the namespace with this usage.
+func (usage namespaceIPUsage) check(quotas map[string]cns.NamespaceIPQuota, namespace string, ipconfig cns.IPConfigurationStatus) error { //nolint:gocritic // ignore hugeparam
+	if len(quotas) == 0 || isIPv6Address(ipconfig.IPAddress) {
+		return nil
+	}
+	if err := usage.checkMaxIPs(quotas, namespace); err != nil {
+		return err
+	}
+	assigned := usage.assigned
+	quota := quotas[namespace]
+	// the namespace can always use its own reservation, and an IP which is not Available, such as one
+	// PendingProgramming, does not take from the IPs held for the reservations.
+	if assigned[namespace] < quota.ReservedIPs || ipconfig.GetState() != types.Available {
+		return nil
+	}
+	reservedForOthers := 0
+	for ns, q := range quotas {
+		if ns == namespace || assigned[ns] >= q.ReservedIPs {
+			continue
+		}
+		reservedForOthers += q.ReservedIPs - assigned[ns]
+	}
+	available := usage.available[ipconfig.NCID]
+	if available <= reservedForOthers {
+		return errors.Wrapf(ErrNamespaceIPQuotaExceeded, "the %d available IPs of NC %s are reserved for other namespaces", available, ipconfig.NCID)
+	}
+	return nil
+}
+
+// checkMaxIPs returns an error if the namespace is at the max IPs of its quota with this usage.
+func (usage namespaceIPUsage) checkMaxIPs(quotas map[string]cns.NamespaceIPQuota, namespace string) error {
+	quota := quotas[namespace]
+	if quota.MaxIPs > 0 && usage.assigned[namespace] >= quota.MaxIPs {
+		return errors.Wrapf(ErrNamespaceIPQuotaExceeded, "namespace %s has %d of max %d IPs", namespace, usage.assigned[namespace], quota.MaxIPs)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package restserver
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckNamespaceIPQuota(t *testing.T) {
+	tests := []struct {
+		name      string
+		quotas    map[string]cns.NamespaceIPQuota
+		assigned  map[string]int
+		available int
+		namespace string
+		wantErr   bool
+	}{
+		{
+			name:      "no quotas",
+			assigned:  map[string]int{"a": 10},
+			available: 1,
+			namespace: "a",
+		},
+		{
+			name:      "under max",
+			quotas:    map[string]cns.NamespaceIPQuota{"a": {MaxIPs: 2}},
+			assigned:  map[string]int{"a": 1},
+			available: 1,
+			namespace: "a",
+		},
+		{
+			name:      "at max",
+			quotas:    map[string]cns.NamespaceIPQuota{"a": {MaxIPs: 2}},
+			assigned:  map[string]int{"a": 2},
+			available: 1,
+			namespace: "a",
+			wantErr:   true,
+		},
+		{
+			name:      "max of other namespace",
+			quotas:    map[string]cns.NamespaceIPQuota{"a": {MaxIPs: 2}},
+			assigned:  map[string]int{"a": 2},
+			available: 1,
+			namespace: "b",
+		},
+		{
+			name:      "available IPs reserved for other namespace",
+			quotas:    map[string]cns.NamespaceIPQuota{"a": {ReservedIPs: 3}},
+			assigned:  map[string]int{"a": 1},
+			available: 2,
+			namespace: "b",
+			wantErr:   true,
+		},
+		{
+			name:      "available IPs beyond reservation",
+			quotas:    map[string]cns.NamespaceIPQuota{"a": {ReservedIPs: 3}},
+			assigned:  map[string]int{"a": 1},
+			available: 3,
+			namespace: "b",
+		},
+		{
+			name:      "fulfilled reservation",
+			quotas:    map[string]cns.NamespaceIPQuota{"a": {ReservedIPs: 3}},
+			assigned:  map[string]int{"a": 3},
+			available: 1,
+			namespace: "b",
+		},
+		{
+			name:      "namespace uses own reservation",
+			quotas:    map[string]cns.NamespaceIPQuota{"a": {ReservedIPs: 3}, "b": {ReservedIPs: 2}},
+			assigned:  map[string]int{"a": 1},
+			available: 3,
+			namespace: "a",
+		},
+		{
+			name:      "namespace beyond own reservation",
+			quotas:    map[string]cns.NamespaceIPQuota{"a": {ReservedIPs: 1}, "b": {ReservedIPs: 2}},
+			assigned:  map[string]int{"a": 1},
+			available: 2,
+			namespace: "a",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			svc := getTestService()
+			svc.SetNamespaceIPQuotas(tt.quotas)
+			ipconfigs := map[string]cns.IPConfigurationStatus{}
+			i := 0
+			for ns, n := range tt.assigned {
+				for j := 0; j < n; j++ {
+					i++
+					id := strconv.Itoa(i)
+					podInfo := cns.NewPodInfo(id, id, "pod"+id, ns)
+					ipconfig, _ := NewPodStateWithOrchestratorContext("10.0.0."+id, id, testNCID, types.Assigned, 24, 0, podInfo)
+					ipconfigs[id] = ipconfig
+				}
+			}
+			for j := 0; j < tt.available; j++ {
+				i++
+				id := strconv.Itoa(i)
+				ipconfigs[id] = NewPodState("10.0.0."+id, 24, id, testNCID, types.Available, 0)
+			}
+			require.NoError(t, UpdatePodIpConfigState(t, svc, ipconfigs))
+
+			// the IP being assigned is the last of the Available IPs.
+			err := svc.checkNamespaceIPQuotaUntransacted(tt.namespace, svc.PodIPConfigState[strconv.Itoa(i)])
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrNamespaceIPQuotaExceeded)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestIPAMAssignEnforcesNamespaceIPQuota(t *testing.T) {
+	svc := getTestService()
+	svc.SetNamespaceIPQuotas(map[string]cns.NamespaceIPQuota{
+		testPod1Info.Namespace(): {MaxIPs: 1},
+	})
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	state2 := NewPodState(testIP2, 24, testPod2GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+		state2.ID: state2,
+	}))
+
+	b, _ := testPod1Info.OrchestratorContext()
+	_, err := requestIPConfigHelper(svc, cns.IPConfigRequest{
+		PodInterfaceID:      testPod1Info.InterfaceID(),
+		InfraContainerID:    testPod1Info.InfraContainerID(),
+		OrchestratorContext: b,
+	})
+	require.NoError(t, err)
+
+	// a second Pod in the same namespace is over quota.
+	podInfo := cns.NewPodInfo("abc-eth0", "abc", "other", testPod1Info.Namespace())
+	b, _ = podInfo.OrchestratorContext()
+	_, err = requestIPConfigHelper(svc, cns.IPConfigRequest{
+		PodInterfaceID:      podInfo.InterfaceID(),
+		InfraContainerID:    podInfo.InfraContainerID(),
+		OrchestratorContext: b,
+	})
+	require.ErrorIs(t, err, ErrNamespaceIPQuotaExceeded)
+	assert.Len(t, svc.GetAssignedIPConfigs(), 1)
+}
+
+func TestIPAMDesiredIPEnforcesNamespaceIPQuota(t *testing.T) {
+	svc := getTestService()
+	svc.SetNamespaceIPQuotas(map[string]cns.NamespaceIPQuota{
+		testPod1Info.Namespace(): {MaxIPs: 1},
+	})
+	assigned, _ := NewPodStateWithOrchestratorContext(testIP1, testPod1GUID, testNCID, types.Assigned, 24, 0, testPod1Info)
+	state2 := NewPodState(testIP2, 24, testPod2GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		assigned.ID: assigned,
+		state2.ID:   state2,
+	}))
+
+	podInfo := cns.NewPodInfo("abc-eth0", "abc", "other", testPod1Info.Namespace())
+	b, _ := podInfo.OrchestratorContext()
+	req := cns.IPConfigRequest{
+		DesiredIPAddress:    testIP2,
+		PodInterfaceID:      podInfo.InterfaceID(),
+		InfraContainerID:    podInfo.InfraContainerID(),
+		OrchestratorContext: b,
+	}
+	_, err := requestIPConfigHelper(svc, req)
+	require.ErrorIs(t, err, ErrNamespaceIPQuotaExceeded)
+
+	// a Pod which already has the IP is reconciled regardless of the quota.
+	_, err = reconcileIPConfigHelper(svc, req)
+	require.NoError(t, err)
+	assert.Len(t, svc.GetAssignedIPConfigs(), 2)
+}
+
+func TestIPAMNamespaceIPReservationsArePerNC(t *testing.T) {
+	svc := getTestService()
+	svc.SetNamespaceIPQuotas(map[string]cns.NamespaceIPQuota{
+		"reserved": {ReservedIPs: 1},
+	})
+	req := generateNetworkContainerRequest(map[string]cns.SecondaryIPConfig{
+		testPod1GUID: newSecondaryIPConfig(testIP1, -1),
+	}, testNCID, "-1")
+	require.Equal(t, types.Success, svc.CreateOrUpdateNetworkContainerInternal(req))
+	req = generateNetworkContainerRequest(map[string]cns.SecondaryIPConfig{
+		testPod2GUID: newSecondaryIPConfig(testIP2, -1),
+		testPod3GUID: newSecondaryIPConfig(testIP3, -1),
+	}, testOverflowNCID, "-1")
+	require.Equal(t, types.Success, svc.CreateOrUpdateNetworkContainerInternal(req))
+
+	// the only Available IP of the first NC is held for the reservation, so the Pod gets an IP from the second NC.
+	podIPInfo, _, err := svc.assignAvailableIPConfigFromNCsUntransacted(testPod1Info, []string{testNCID, testOverflowNCID})
+	require.NoError(t, err)
+	assert.NotEqual(t, testIP1, podIPInfo.PodIPConfig.IPAddress)
+
+	// the last IP of the second NC is also held for the reservation.
+	_, _, err = svc.assignAvailableIPConfigFromNCsUntransacted(testPod2Info, []string{testOverflowNCID})
+	require.ErrorIs(t, err, ErrNamespaceIPQuotaExceeded)
+}
@@ -42,21 +42,34 @@ type nmagentClient interface {
 	GetNCVersionList(ctx context.Context) (*nmagent.NetworkContainerListResponse, error)
 }
 
+// IPAssignmentJournal durably records the IPs that are assigned to Pods.
+type IPAssignmentJournal interface {
+	Assign(ipconfig cns.IPConfigurationStatus, podInfo cns.PodInfo) error
+	Unassign(ipconfig cns.IPConfigurationStatus) error
+}
+
 // HTTPRestService represents http listener for CNS - Container Networking Service.
 type HTTPRestService struct {
 	*cns.Service
-	dockerClient             *dockerclient.Client
-	wscli                    interfaceGetter
-	ipamClient               *ipamclient.IpamClient
-	nmagentClient            nmagentClient
-	networkContainer         *networkcontainers.NetworkContainers
-	PodIPIDByPodInterfaceKey map[string]string                    // PodInterfaceId is key and value is Pod IP (SecondaryIP) uuid.
-	PodIPConfigState         map[string]cns.IPConfigurationStatus // Secondary IP ID(uuid) is key
-	IPAMPoolMonitor          cns.IPAMPoolMonitor
-	routingTable             *routes.RoutingTable
-	store                    store.KeyValueStore
-	state                    *httpRestServiceState
-	podsPendingIPAssignment  *bounded.TimedSet
+	dockerClient               *dockerclient.Client
+	wscli                      interfaceGetter
+	ipamClient                 *ipamclient.IpamClient
+	nmagentClient              nmagentClient
+	networkContainer           *networkcontainers.NetworkContainers
+	PodIPIDByPodInterfaceKey   map[string]string                    // PodInterfaceId is key and value is Pod IP (SecondaryIP) uuid.
+	PodIPv6IDByPodInterfaceKey map[string]string                    // PodInterfaceId is key and value is Pod IPv6 (SecondaryIP) uuid of dual-stack Pods.
+	PodIPConfigState           map[string]cns.IPConfigurationStatus // Secondary IP ID(uuid) is key
+	IPAMPoolMonitor            cns.IPAMPoolMonitor
+	NCSelector                 *NCSelector
+	IPAssignmentJournal        IPAssignmentJournal
+	routingTable               *routes.RoutingTable
+	store                      store.KeyValueStore
+	state                      *httpRestServiceState
+	podsPendingIPAssignment    *bounded.TimedSet
+	ipStateMiddlewares         []func(*cns.IPConfigurationStatus, types.IPState)
+	namespaceIPQuotas          map[string]cns.NamespaceIPQuota
+	stickyIPs                  *stickyIPs
+	ipStateWatcher             *ipStateWatcher
 	sync.RWMutex
 	dncPartitionKey string
 }
@@ -68,9 +81,10 @@ type GetHTTPServiceDataResponse struct {
 
 // HTTPRestServiceData represents in-memory CNS data in the debug API paths.
 type HTTPRestServiceData struct {
-	PodIPIDByPodInterfaceKey map[string]string                    // PodInterfaceId is key and value is Pod IP uuid.
-	PodIPConfigState         map[string]cns.IPConfigurationStatus // secondaryipid(uuid) is key
-	IPAMPoolMonitor          cns.IpamPoolMonitorStateSnapshot
+	PodIPIDByPodInterfaceKey   map[string]string                    // PodInterfaceId is key and value is Pod IP uuid.
+	PodIPv6IDByPodInterfaceKey map[string]string                    `json:",omitempty"` // PodInterfaceId is key and value is Pod IPv6 uuid.
+	PodIPConfigState           map[string]cns.IPConfigurationStatus // secondaryipid(uuid) is key
+	IPAMPoolMonitor            cns.IpamPoolMonitorStateSnapshot
 }
 
 type Response struct {
@@ -143,21 +157,25 @@ func NewHTTPRestService(config *common.ServiceConfig, wscli interfaceGetter, nma
 	}
 
 	podIPIDByPodInterfaceKey := make(map[string]string)
+	podIPv6IDByPodInterfaceKey := make(map[string]string)
 	podIPConfigState := make(map[string]cns.IPConfigurationStatus)
 
 	return &HTTPRestService{
-		Service:                  service,
-		store:                    service.Service.Store,
-		dockerClient:             dc,
-		wscli:                    wscli,
-		ipamClient:               ic,
-		nmagentClient:            nmagentClient,
-		networkContainer:         nc,
-		PodIPIDByPodInterfaceKey: podIPIDByPodInterfaceKey,
-		PodIPConfigState:         podIPConfigState,
-		routingTable:             routingTable,
-		state:                    serviceState,
-		podsPendingIPAssignment:  bounded.NewTimedSet(250), // nolint:gomnd // maxpods
+		Service:                    service,
+		store:                      service.Service.Store,
+		dockerClient:               dc,
+		wscli:                      wscli,
+		ipamClient:                 ic,
+		nmagentClient:              nmagentClient,
+		networkContainer:           nc,
+		PodIPIDByPodInterfaceKey:   podIPIDByPodInterfaceKey,
+		PodIPv6IDByPodInterfaceKey: podIPv6IDByPodInterfaceKey,
+		PodIPConfigState:           podIPConfigState,
+		routingTable:               routingTable,
+		state:                      serviceState,
+		podsPendingIPAssignment:    bounded.NewTimedSet(250), // nolint:gomnd // maxpods
+		stickyIPs:                  newStickyIPs(DefaultStickyIPReservationTTL),
+		ipStateWatcher:             newIPStateWatcher(),
 	}, nil
 }
 
@@ -187,6 +205,7 @@ func (service *HTTPRestService) Init(config *common.ServiceConfig) error {
 	listener.AddHandler(cns.GetHostLocalIPPath, service.getHostLocalIP)
 	listener.AddHandler(cns.GetIPAddressUtilizationPath, service.getIPAddressUtilization)
 	listener.AddHandler(cns.GetUnhealthyIPAddressesPath, service.getUnhealthyIPAddresses)
+	listener.AddHandler(cns.GetHealthReportPath, service.getHealthReport)
 	listener.AddHandler(cns.CreateOrUpdateNetworkContainer, service.createOrUpdateNetworkContainer)
 	listener.AddHandler(cns.DeleteNetworkContainer, service.deleteNetworkContainer)
 	listener.AddHandler(cns.GetInterfaceForContainer, service.getInterfaceForContainer)
@@ -203,6 +222,9 @@ func (service *HTTPRestService) Init(config *common.ServiceConfig) error {
 	listener.AddHandler(cns.UnpublishNetworkContainer, service.unpublishNetworkContainer)
 	listener.AddHandler(cns.RequestIPConfig, newHandlerFuncWithHistogram(service.requestIPConfigHandler, httpRequestLatency))
 	listener.AddHandler(cns.ReleaseIPConfig, newHandlerFuncWithHistogram(service.releaseIPConfigHandler, httpRequestLatency))
+	listener.AddHandler(cns.RequestIPConfigs, newHandlerFuncWithHistogram(service.requestIPConfigsHandler, httpRequestLatency))
+	listener.AddHandler(cns.ReleaseIPConfigs, newHandlerFuncWithHistogram(service.releaseIPConfigsHandler, httpRequestLatency))
+	listener.AddHandler(cns.WatchIPConfigs, service.watchIPConfigsHandler)
 	listener.AddHandler(cns.NmAgentSupportedApisPath, service.nmAgentSupportedApisHandler)
 	listener.AddHandler(cns.PathDebugIPAddresses, service.handleDebugIPAddresses)
 	listener.AddHandler(cns.PathDebugPodContext, service.handleDebugPodContext)
@@ -217,6 +239,7 @@ func (service *HTTPRestService) Init(config *common.ServiceConfig) error {
 	listener.AddHandler(cns.V2Prefix+cns.GetHostLocalIPPath, service.getHostLocalIP)
 	listener.AddHandler(cns.V2Prefix+cns.GetIPAddressUtilizationPath, service.getIPAddressUtilization)
 	listener.AddHandler(cns.V2Prefix+cns.GetUnhealthyIPAddressesPath, service.getUnhealthyIPAddresses)
+	listener.AddHandler(cns.V2Prefix+cns.GetHealthReportPath, service.getHealthReport)
 	listener.AddHandler(cns.V2Prefix+cns.CreateOrUpdateNetworkContainer, service.createOrUpdateNetworkContainer)
 	listener.AddHandler(cns.V2Prefix+cns.DeleteNetworkContainer, service.deleteNetworkContainer)
 	listener.AddHandler(cns.V2Prefix+cns.GetInterfaceForContainer, service.getInterfaceForContainer)
@@ -259,3 +282,12 @@ func (service *HTTPRestService) Stop() {
 	service.Uninitialize()
 	logger.Printf("[Azure CNS]  Service stopped.")
 }
+
+// WithIPStateMiddleware registers funcs which are called on every state transition of the
+// IPConfigurationStatuses in the PodIPConfigState. It must be called before any IPConfigs
+// are added to the state, since the middleware is attached when the IPConfigs are created.
+func (service *HTTPRestService) WithIPStateMiddleware(fs ...func(*cns.IPConfigurationStatus, types.IPState)) {
+	service.Lock()
+	defer service.Unlock()
+	service.ipStateMiddlewares = append(service.ipStateMiddlewares, fs...)
+}
@@ -0,0 +1,231 @@
+package restserver
+
+import (
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/ipamjournal"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/cns/types/bounded"
+)
+
+// DefaultStickyIPReservationTTL is how long the IP released by a sticky Pod is held for it by default.
+const DefaultStickyIPReservationTTL = 5 * time.Minute
+
+// StickyIPJournal durably records the state of the sticky IPs, so that their reservations survive a restart.
+type StickyIPJournal interface {
+	SetStickyIPs(stickyIPs ipamjournal.StickyIPs) error
+	StickyIPs() ipamjournal.StickyIPs
+}
+
+// stickyIPs holds the IPs released by sticky Pods in reservation for a Pod with the same
+// namespace and name, such as a rescheduled StatefulSet Pod, until the TTL expires.
+// It is not safe for concurrent use and is guarded by the service lock.
+type stickyIPs struct {
+	ttl     time.Duration
+	journal StickyIPJournal
+	// reservations is the time each reservation was made, by Pod namespace/name.
+	reservations *bounded.TimedSet
+	// ipIDByPod is the reserved IPConfig ID by Pod namespace/name.
+	ipIDByPod map[string]string
+	// podByIPID is the Pod namespace/name by reserved IPConfig ID.
+	podByIPID map[string]string
+	// assigned are the IPConfig IDs currently assigned to sticky Pods.
+	assigned map[string]struct{}
+}
+
+func newStickyIPs(ttl time.Duration) *stickyIPs {
+	return &stickyIPs{
+		ttl:          ttl,
+		reservations: bounded.NewTimedSet(250), // nolint:gomnd // maxpods
+		ipIDByPod:    map[string]string{},
+		podByIPID:    map[string]string{},
+		assigned:     map[string]struct{}{},
+	}
+}
+
+func stickyPodKey(podInfo cns.PodInfo) string {
+	return podInfo.Namespace() + "/" + podInfo.Name()
+}
+
+// isStickyIPConfigRequest returns whether the Pod in the request has opted in to sticky IPs.
+func isStickyIPConfigRequest(req *cns.IPConfigRequest) bool {
+	return req.PodAnnotations[cns.StickyIPAnnotation] == "true"
+}
+
+// expire drops the reservations which are older than the TTL, or which were evicted from the TimedSet.
+func (s *stickyIPs) expire() {
+	changed := false
+	for _, pod := range s.reservations.Expire(s.ttl) {
+		logger.Printf("[stickyIPs] reservation of IP %s for Pod %s expired", s.ipIDByPod[pod], pod)
+		delete(s.podByIPID, s.ipIDByPod[pod])
+		delete(s.ipIDByPod, pod)
+		changed = true
+	}
+	for pod, id := range s.ipIDByPod {
+		if !s.reservations.Contains(pod) {
+			delete(s.podByIPID, id)
+			delete(s.ipIDByPod, pod)
+			changed = true
+		}
+	}
+	if changed {
+		s.persist()
+	}
+}
+
+// track records that the IPConfig is assigned to a sticky Pod.
+func (s *stickyIPs) track(id string) {
+	if _, ok := s.assigned[id]; ok {
+		return
+	}
+	s.assigned[id] = struct{}{}
+	s.persist()
+}
+
+// assign drops any reservation of the IPConfig, since it has been assigned.
+func (s *stickyIPs) assign(id string) {
+	pod, ok := s.podByIPID[id]
+	if !ok {
+		return
+	}
+	s.reservations.Pop(pod)
+	delete(s.ipIDByPod, pod)
+	delete(s.podByIPID, id)
+	s.persist()
+}
+
+// release reserves the IPConfig for the Pod if it was assigned to a sticky Pod.
+func (s *stickyIPs) release(id string, podInfo cns.PodInfo) {
+	s.releaseAt(id, podInfo, time.Now())
+}
+
+// releaseAt is release, with the reservation made at the passed time, such as the time of the reservation which
+// the IPConfig was assigned from, when that assignment is rolled back.
+func (s *stickyIPs) releaseAt(id string, podInfo cns.PodInfo, reservedAt time.Time) {
+	if _, ok := s.assigned[id]; !ok {
+		return
+	}
+	delete(s.assigned, id)
+	if podInfo == nil {
+		s.persist()
+		return
+	}
+	pod := stickyPodKey(podInfo)
+	if prev, ok := s.ipIDByPod[pod]; ok {
+		s.reservations.Pop(pod)
+		delete(s.podByIPID, prev)
+	}
+	s.reservations.PushAt(pod, reservedAt)
+	s.ipIDByPod[pod] = id
+	s.podByIPID[id] = pod
+	s.persist()
+	logger.Printf("[stickyIPs] reserved IP %s for Pod %s for %s from %s", id, pod, s.ttl, reservedAt)
+}
+
+// persist records the sticky IPs in the journal, if there is one. The reservations are held on a best effort basis,
+// so failing to record them is logged instead of failing the IP assignment which changed them.
+func (s *stickyIPs) persist() {
+	if s.journal == nil {
+		return
+	}
+	state := ipamjournal.StickyIPs{
+		Assigned:     make([]string, 0, len(s.assigned)),
+		Reservations: make(map[string]ipamjournal.StickyIPReservation, len(s.ipIDByPod)),
+	}
+	for id := range s.assigned {
+		state.Assigned = append(state.Assigned, id)
+	}
+	for pod, id := range s.ipIDByPod {
+		reservedAt, _ := s.reservations.Time(pod)
+		state.Reservations[pod] = ipamjournal.StickyIPReservation{ID: id, ReservedAt: reservedAt}
+	}
+	if err := s.journal.SetStickyIPs(state); err != nil {
+		logger.Errorf("[stickyIPs] failed to journal sticky IPs: %v", err)
+	}
+}
+
+// restore replaces the sticky IPs with the state recorded in the journal, keeping the time each reservation
+// was made so that it expires as it would have without the restart.
+func (s *stickyIPs) restore(journal StickyIPJournal) {
+	s.journal = journal
+	state := journal.StickyIPs()
+	s.reservations = bounded.NewTimedSet(250) // nolint:gomnd // maxpods
+	s.ipIDByPod = map[string]string{}
+	s.podByIPID = map[string]string{}
+	s.assigned = map[string]struct{}{}
+	for _, id := range state.Assigned {
+		s.assigned[id] = struct{}{}
+	}
+	for pod, r := range state.Reservations {
+		s.reservations.PushAt(pod, r.ReservedAt)
+		s.ipIDByPod[pod] = r.ID
+		s.podByIPID[r.ID] = pod
+	}
+	// the reservations which expired during the restart are dropped on next use, once the TTL is configured.
+	logger.Printf("[stickyIPs] restored %d sticky IPs and %d reservations", len(s.assigned), len(s.ipIDByPod))
+}
+
+// reservedFor returns the IPConfig ID reserved for the Pod, if any.
+func (s *stickyIPs) reservedFor(podInfo cns.PodInfo) (string, bool) {
+	id, ok := s.ipIDByPod[stickyPodKey(podInfo)]
+	return id, ok
+}
+
+// reservedAt returns the time the reservation for the Pod was made, if there is one.
+func (s *stickyIPs) reservedAt(podInfo cns.PodInfo) (time.Time, bool) {
+	return s.reservations.Time(stickyPodKey(podInfo))
+}
+
+// isReserved returns whether the IPConfig is reserved for a Pod.
+func (s *stickyIPs) isReserved(id string) bool {
+	_, ok := s.podByIPID[id]
+	return ok
+}
+
+// SetStickyIPReservationTTL sets how long the IP released by a sticky Pod is held for it.
+func (service *HTTPRestService) SetStickyIPReservationTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultStickyIPReservationTTL
+	}
+	service.Lock()
+	defer service.Unlock()
+	service.stickyIPs.ttl = ttl
+}
+
+// SetStickyIPJournal restores the sticky IPs recorded in the journal and records every later change to them in it.
+func (service *HTTPRestService) SetStickyIPJournal(journal StickyIPJournal) {
+	service.Lock()
+	defer service.Unlock()
+	service.stickyIPs.restore(journal)
+}
+
+// assignReservedIPConfigUntransacted assigns the IPConfig reserved for the Pod, if there is one and it is still
+// Available, and returns it as it was before it was assigned. Does not take a lock.
+func (service *HTTPRestService) assignReservedIPConfigUntransacted(podInfo cns.PodInfo) (cns.PodIpInfo, cns.IPConfigurationStatus, error) {
+	service.stickyIPs.expire()
+	id, ok := service.stickyIPs.reservedFor(podInfo)
+	if !ok {
+		return cns.PodIpInfo{}, cns.IPConfigurationStatus{}, nil
+	}
+	ipState, ok := service.PodIPConfigState[id]
+	if !ok || ipState.GetState() != types.Available {
+		service.stickyIPs.assign(id)
+		return cns.PodIpInfo{}, cns.IPConfigurationStatus{}, nil
+	}
+	// the IP is held for the Pod, so it does not take from the IPs reserved for other namespaces, but the Pod is
+	// still limited by the max IPs of its namespace.
+	if len(service.namespaceIPQuotas) > 0 {
+		if err := service.namespaceIPUsageUntransacted().checkMaxIPs(service.namespaceIPQuotas, podInfo.Namespace()); err != nil {
+			return cns.PodIpInfo{}, cns.IPConfigurationStatus{}, err
+		}
+	}
+	podIPInfo, err := service.assignAndPopulateUntransacted(ipState, podInfo)
+	if err != nil {
+		return cns.PodIpInfo{}, cns.IPConfigurationStatus{}, err
+	}
+	service.stickyIPs.track(id)
+	logger.Printf("[assignReservedIPConfig] reassigned reserved IP %s to Pod %+v", ipState.IPAddress, podInfo)
+	return podIPInfo, ipState, nil
+}
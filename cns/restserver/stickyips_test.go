@@ -0,0 +1,163 @@
+package restserver
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/ipamjournal"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/Azure/azure-container-networking/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stickyIPConfigRequest(t *testing.T, podInfo cns.PodInfo, sticky bool) cns.IPConfigRequest {
+	b, err := podInfo.OrchestratorContext()
+	require.NoError(t, err)
+	req := cns.IPConfigRequest{
+		PodInterfaceID:      podInfo.InterfaceID(),
+		InfraContainerID:    podInfo.InfraContainerID(),
+		OrchestratorContext: b,
+	}
+	if sticky {
+		req.PodAnnotations = map[string]string{cns.StickyIPAnnotation: "true"}
+	}
+	return req
+}
+
+func TestIPAMStickyIPReassignedToRecreatedPod(t *testing.T) {
+	svc := getTestService()
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	state2 := NewPodState(testIP2, 24, testPod2GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{
+		state1.ID: state1,
+		state2.ID: state2,
+	}))
+
+	stateful := cns.NewPodInfo("abc-eth0", "abc", "db-0", "default")
+	podIPInfo, err := requestIPConfigHelper(svc, stickyIPConfigRequest(t, stateful, true))
+	require.NoError(t, err)
+	ip := podIPInfo.PodIPConfig.IPAddress
+	require.NoError(t, svc.releaseIPConfig(stateful))
+
+	// the released IP is held for the Pod, so another Pod gets the other IP.
+	podIPInfo, err = requestIPConfigHelper(svc, stickyIPConfigRequest(t, testPod3Info, false))
+	require.NoError(t, err)
+	assert.NotEqual(t, ip, podIPInfo.PodIPConfig.IPAddress)
+	_, err = requestIPConfigHelper(svc, stickyIPConfigRequest(t, testPod2Info, false))
+	require.Error(t, err)
+
+	// and the reserved IP is not released by the pool monitor.
+	pending, err := svc.MarkIPAsPendingRelease(1)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// the recreated Pod has a new infra container but the same name, and gets its IP back.
+	recreated := cns.NewPodInfo("def-eth0", "def", "db-0", "default")
+	podIPInfo, err = requestIPConfigHelper(svc, stickyIPConfigRequest(t, recreated, true))
+	require.NoError(t, err)
+	assert.Equal(t, ip, podIPInfo.PodIPConfig.IPAddress)
+}
+
+func TestIPAMStickyIPReservationExpires(t *testing.T) {
+	svc := getTestService()
+	svc.SetStickyIPReservationTTL(time.Nanosecond)
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state1.ID: state1}))
+
+	stateful := cns.NewPodInfo("abc-eth0", "abc", "db-0", "default")
+	_, err := requestIPConfigHelper(svc, stickyIPConfigRequest(t, stateful, true))
+	require.NoError(t, err)
+	require.NoError(t, svc.releaseIPConfig(stateful))
+	time.Sleep(time.Millisecond)
+
+	// the reservation has expired, so the IP can be assigned to any Pod.
+	podIPInfo, err := requestIPConfigHelper(svc, stickyIPConfigRequest(t, testPod2Info, false))
+	require.NoError(t, err)
+	assert.Equal(t, testIP1, podIPInfo.PodIPConfig.IPAddress)
+}
+
+func TestIPAMNonStickyIPNotReserved(t *testing.T) {
+	svc := getTestService()
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state1.ID: state1}))
+
+	_, err := requestIPConfigHelper(svc, stickyIPConfigRequest(t, testPod1Info, false))
+	require.NoError(t, err)
+	require.NoError(t, svc.releaseIPConfig(testPod1Info))
+
+	podIPInfo, err := requestIPConfigHelper(svc, stickyIPConfigRequest(t, testPod2Info, false))
+	require.NoError(t, err)
+	assert.Equal(t, testIP1, podIPInfo.PodIPConfig.IPAddress)
+}
+
+func TestIPAMStickyIPReservationSurvivesRestart(t *testing.T) {
+	newJournal := func(path string) *ipamjournal.Journal {
+		s, err := store.NewJsonFileStore(path, nil)
+		require.NoError(t, err)
+		j, err := ipamjournal.New(s)
+		require.NoError(t, err)
+		return j
+	}
+	path := filepath.Join(t.TempDir(), "journal.json")
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	state2 := NewPodState(testIP2, 24, testPod2GUID, testNCID, types.Available, 0)
+
+	svc := getTestService()
+	svc.SetStickyIPJournal(newJournal(path))
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state1.ID: state1, state2.ID: state2}))
+	stateful := cns.NewPodInfo("abc-eth0", "abc", "db-0", "default")
+	podIPInfo, err := requestIPConfigHelper(svc, stickyIPConfigRequest(t, stateful, true))
+	require.NoError(t, err)
+	ip := podIPInfo.PodIPConfig.IPAddress
+	require.NoError(t, svc.releaseIPConfig(stateful))
+
+	// a restarted CNS restores the reservation from the journal.
+	restarted := getTestService()
+	restarted.SetStickyIPJournal(newJournal(path))
+	require.NoError(t, UpdatePodIpConfigState(t, restarted, map[string]cns.IPConfigurationStatus{state1.ID: state1, state2.ID: state2}))
+	podIPInfo, err = requestIPConfigHelper(restarted, stickyIPConfigRequest(t, testPod3Info, false))
+	require.NoError(t, err)
+	assert.NotEqual(t, ip, podIPInfo.PodIPConfig.IPAddress)
+
+	recreated := cns.NewPodInfo("def-eth0", "def", "db-0", "default")
+	podIPInfo, err = requestIPConfigHelper(restarted, stickyIPConfigRequest(t, recreated, true))
+	require.NoError(t, err)
+	assert.Equal(t, ip, podIPInfo.PodIPConfig.IPAddress)
+
+	// the reassigned IP is still sticky, so it is held again when the recreated Pod is released after another restart.
+	require.NoError(t, restarted.releaseIPConfig(recreated))
+	assert.Contains(t, newJournal(path).StickyIPs().Reservations, "default/db-0")
+}
+
+func TestIPAMStickyIPRollbackKeepsReservationTime(t *testing.T) {
+	svc := getTestService()
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state1.ID: state1}))
+
+	stateful := cns.NewPodInfo("abc-eth0", "abc", "db-0", "default")
+	_, err := requestIPConfigHelper(svc, stickyIPConfigRequest(t, stateful, true))
+	require.NoError(t, err)
+	require.NoError(t, svc.releaseIPConfig(stateful))
+	reservedAt, ok := svc.stickyIPs.reservedAt(stateful)
+	require.True(t, ok)
+	time.Sleep(time.Millisecond)
+
+	// the recreated Pod gets its reserved IP, but there is no IP for the other Pod in the batch, so the batch is
+	// rolled back and the IP is reserved again as of the original reservation, instead of the time of the rollback.
+	recreated := cns.NewPodInfo("def-eth0", "def", "db-0", "default")
+	_, err = requestIPConfigsHelper(svc, []cns.IPConfigRequest{
+		stickyIPConfigRequest(t, recreated, true),
+		stickyIPConfigRequest(t, testPod2Info, false),
+	})
+	require.Error(t, err)
+	assert.Empty(t, svc.GetAssignedIPConfigs())
+	restoredAt, ok := svc.stickyIPs.reservedAt(recreated)
+	require.True(t, ok)
+	assert.Equal(t, reservedAt, restoredAt)
+	id, ok := svc.stickyIPs.reservedFor(recreated)
+	require.True(t, ok)
+	assert.Equal(t, state1.ID, id)
+}
@@ -290,6 +290,12 @@ func (service *HTTPRestService) addIPConfigStateUntransacted(ncID string, hostVe
 			PodInfo:   nil,
 		}
 		ipconfigStatus.WithStateMiddleware(stateTransitionMiddleware)
+		if service.ipStateWatcher != nil {
+			ipconfigStatus.WithStateMiddleware(service.ipStateWatcher.observe)
+		}
+		for _, f := range service.ipStateMiddlewares {
+			ipconfigStatus.WithStateMiddleware(f)
+		}
 		ipconfigStatus.SetState(newIPCNSStatus)
 		logger.Printf("[Azure-Cns] Add IP %s as %s", ipconfig.IPAddress, newIPCNSStatus)
 
@@ -738,13 +744,19 @@ func (service *HTTPRestService) populateIPConfigInfoUntransacted(ipConfigStatus
 	}
 
 	primaryIPCfg := ncStatus.CreateNetworkContainerRequest.IPConfiguration
-
-	podIPInfo.PodIPConfig = cns.IPSubnet{
+	podIPConfig := cns.IPSubnet{
 		IPAddress:    ipConfigStatus.IPAddress,
 		PrefixLength: primaryIPCfg.IPSubnet.PrefixLength,
 	}
 
-	podIPInfo.NetworkContainerPrimaryIPConfig = primaryIPCfg
+	// the IPv6 IPConfig of a dual-stack Pod is populated alongside its IPv4 IPConfig.
+	if isIPv6Address(ipConfigStatus.IPAddress) {
+		podIPInfo.PodIPConfigV6 = podIPConfig
+		podIPInfo.NetworkContainerPrimaryIPConfigV6 = primaryIPCfg
+	} else {
+		podIPInfo.PodIPConfig = podIPConfig
+		podIPInfo.NetworkContainerPrimaryIPConfig = primaryIPCfg
+	}
 	primaryHostInterface, err := service.getPrimaryHostInterface(context.TODO())
 	if err != nil {
 		return err
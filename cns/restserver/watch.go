@@ -0,0 +1,180 @@
+package restserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/logger"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ipStateWatchHistory is the number of recent events kept to resume watches from.
+	ipStateWatchHistory = 1024
+	// ipStateWatchBuffer is the number of events a watcher can fall behind by before it is disconnected.
+	ipStateWatchBuffer = 64
+)
+
+// ErrRevisionCompacted is returned when a watch is resumed from a revision which is no longer in the history.
+var ErrRevisionCompacted = errors.New("revision has been compacted")
+
+// ipStateWatcher records every IPConfigurationStatus state transition as a revisioned IPConfigStateEvent,
+// keeps a bounded history of them, and fans them out to the subscribed watches.
+type ipStateWatcher struct {
+	sync.Mutex
+	revision    uint64
+	history     []cns.IPConfigStateEvent
+	subscribers map[chan cns.IPConfigStateEvent]struct{}
+}
+
+func newIPStateWatcher() *ipStateWatcher {
+	return &ipStateWatcher{
+		subscribers: map[chan cns.IPConfigStateEvent]struct{}{},
+	}
+}
+
+// observe is an IPConfigurationStatus state middleware which publishes the transition to the next state.
+func (w *ipStateWatcher) observe(ipconfig *cns.IPConfigurationStatus, next types.IPState) {
+	event := cns.IPConfigStateEvent{
+		ID:            ipconfig.ID,
+		IPAddress:     ipconfig.IPAddress,
+		NCID:          ipconfig.NCID,
+		PreviousState: ipconfig.GetState(),
+		State:         next,
+		Time:          time.Now(),
+	}
+	if ipconfig.PodInfo != nil {
+		event.PodName = ipconfig.PodInfo.Name()
+		event.PodNamespace = ipconfig.PodInfo.Namespace()
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	w.revision++
+	event.Revision = w.revision
+	w.history = append(w.history, event)
+	if len(w.history) > ipStateWatchHistory {
+		w.history = w.history[len(w.history)-ipStateWatchHistory:]
+	}
+	for ch := range w.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// the watch has fallen too far behind, so it is closed and has to resume from its last revision.
+			logger.Errorf("[ipStateWatcher] closing watch which fell behind at revision %d", event.Revision)
+			delete(w.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns the events after the passed revision which are still in the history, and a channel
+// which receives every later event. A revision of 0 only watches for new events. A revision which is not
+// in the history fails with ErrRevisionCompacted, as does one newer than the latest revision, which was
+// received from CNS before it restarted and reset the revisions.
+// The channel is closed if the watch falls behind, and must be passed to unsubscribe when done.
+func (w *ipStateWatcher) subscribe(revision uint64) ([]cns.IPConfigStateEvent, chan cns.IPConfigStateEvent, error) {
+	w.Lock()
+	defer w.Unlock()
+	var backlog []cns.IPConfigStateEvent
+	if revision > w.revision {
+		return nil, nil, errors.Wrapf(ErrRevisionCompacted, "revision %d is newer than the latest revision %d", revision, w.revision)
+	}
+	if revision > 0 && revision < w.revision {
+		if len(w.history) == 0 || w.history[0].Revision > revision+1 {
+			return nil, nil, errors.Wrapf(ErrRevisionCompacted, "oldest revision is %d", w.revision-uint64(len(w.history))+1)
+		}
+		backlog = append(backlog, w.history[revision+1-w.history[0].Revision:]...)
+	}
+	ch := make(chan cns.IPConfigStateEvent, ipStateWatchBuffer)
+	w.subscribers[ch] = struct{}{}
+	return backlog, ch, nil
+}
+
+func (w *ipStateWatcher) unsubscribe(ch chan cns.IPConfigStateEvent) {
+	w.Lock()
+	defer w.Unlock()
+	if _, ok := w.subscribers[ch]; ok {
+		delete(w.subscribers, ch)
+		close(ch)
+	}
+}
+
+// writeIPConfigStateEvent writes the event as a server-sent event with its revision as the event ID.
+func writeIPConfigStateEvent(w http.ResponseWriter, event *cns.IPConfigStateEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event")
+	}
+	if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.Revision, b); err != nil {
+		return errors.Wrap(err, "failed to write event")
+	}
+	return nil
+}
+
+// watchIPConfigsHandler streams an IPConfigStateEvent for every IP state change as server-sent events.
+// The watch is resumed after the revision in the "revision" query parameter or the Last-Event-ID header,
+// and fails with 410 Gone if that revision is no longer in the history.
+func (service *HTTPRestService) watchIPConfigsHandler(w http.ResponseWriter, r *http.Request) {
+	operationName := "watchIPConfigsHandler"
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	from := r.URL.Query().Get("revision")
+	if from == "" {
+		from = r.Header.Get("Last-Event-ID")
+	}
+	var revision uint64
+	if from != "" {
+		var err error
+		if revision, err = strconv.ParseUint(from, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid revision %q", from), http.StatusBadRequest)
+			return
+		}
+	}
+
+	backlog, ch, err := service.ipStateWatcher.subscribe(revision)
+	if err != nil {
+		logger.Errorf("[%s] failed to resume watch from revision %d: %v", operationName, revision, err)
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	defer service.ipStateWatcher.unsubscribe(ch)
+	logger.Printf("[%s] watching IP state changes from revision %d", operationName, revision)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for i := range backlog {
+		if err := writeIPConfigStateEvent(w, &backlog[i]); err != nil {
+			logger.Errorf("[%s] %v", operationName, err)
+			return
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := writeIPConfigStateEvent(w, &event); err != nil {
+				logger.Errorf("[%s] %v", operationName, err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
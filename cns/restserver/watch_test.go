@@ -0,0 +1,100 @@
+package restserver
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/cns"
+	"github.com/Azure/azure-container-networking/cns/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPStateWatcherStreamsTransitions(t *testing.T) {
+	svc := getTestService()
+	state1 := NewPodState(testIP1, 24, testPod1GUID, testNCID, types.Available, 0)
+	require.NoError(t, UpdatePodIpConfigState(t, svc, map[string]cns.IPConfigurationStatus{state1.ID: state1}))
+
+	_, ch, err := svc.ipStateWatcher.subscribe(0)
+	require.NoError(t, err)
+	defer svc.ipStateWatcher.unsubscribe(ch)
+
+	_, err = requestIPConfigHelper(svc, stickyIPConfigRequest(t, testPod1Info, false))
+	require.NoError(t, err)
+	require.NoError(t, svc.releaseIPConfig(testPod1Info))
+
+	assigned := <-ch
+	assert.Equal(t, state1.ID, assigned.ID)
+	assert.Equal(t, types.Available, assigned.PreviousState)
+	assert.Equal(t, types.Assigned, assigned.State)
+	assert.Equal(t, testPod1Info.Name(), assigned.PodName)
+	assert.Equal(t, testPod1Info.Namespace(), assigned.PodNamespace)
+
+	released := <-ch
+	assert.Equal(t, assigned.Revision+1, released.Revision)
+	assert.Equal(t, types.Assigned, released.PreviousState)
+	assert.Equal(t, types.Available, released.State)
+	assert.Equal(t, testPod1Info.Name(), released.PodName)
+}
+
+func TestIPStateWatcherResume(t *testing.T) {
+	w := newIPStateWatcher()
+	ipconfig := &cns.IPConfigurationStatus{ID: "id", IPAddress: testIP1, NCID: testNCID}
+	ipconfig.WithStateMiddleware(w.observe)
+	for i := 0; i < ipStateWatchHistory+10; i++ {
+		if i%2 == 0 {
+			ipconfig.SetState(types.Assigned)
+		} else {
+			ipconfig.SetState(types.Available)
+		}
+	}
+	latest := uint64(ipStateWatchHistory + 10)
+
+	tests := []struct {
+		name     string
+		revision uint64
+		backlog  int
+		wantErr  error
+	}{
+		{name: "from now", revision: 0, backlog: 0},
+		{name: "up to date", revision: latest, backlog: 0},
+		{name: "behind", revision: latest - 5, backlog: 5},
+		{name: "oldest in history", revision: latest - ipStateWatchHistory, backlog: ipStateWatchHistory},
+		{name: "compacted", revision: 5, wantErr: ErrRevisionCompacted},
+		{name: "from before restart", revision: latest + 5, wantErr: ErrRevisionCompacted},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			backlog, ch, err := w.subscribe(tt.revision)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			defer w.unsubscribe(ch)
+			require.Len(t, backlog, tt.backlog)
+			for i := range backlog {
+				assert.Equal(t, tt.revision+uint64(i)+1, backlog[i].Revision)
+			}
+		})
+	}
+}
+
+func TestIPStateWatcherClosesSlowWatch(t *testing.T) {
+	w := newIPStateWatcher()
+	ipconfig := &cns.IPConfigurationStatus{ID: "id"}
+	ipconfig.WithStateMiddleware(w.observe)
+	_, ch, err := w.subscribe(0)
+	require.NoError(t, err)
+	for i := 0; i <= ipStateWatchBuffer; i++ {
+		ipconfig.SetState(types.Available)
+	}
+	// the watch received the events which fit in its buffer, then was closed.
+	received := 0
+	for range ch {
+		received++
+	}
+	assert.Equal(t, ipStateWatchBuffer, received)
+	assert.Empty(t, w.subscribers)
+	w.unsubscribe(ch)
+}
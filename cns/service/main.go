@@ -27,6 +27,7 @@ import (
 	"github.com/Azure/azure-container-networking/cns/common"
 	"github.com/Azure/azure-container-networking/cns/configuration"
 	"github.com/Azure/azure-container-networking/cns/hnsclient"
+	"github.com/Azure/azure-container-networking/cns/ipamjournal"
 	"github.com/Azure/azure-container-networking/cns/ipampool"
 	"github.com/Azure/azure-container-networking/cns/logger"
 	"github.com/Azure/azure-container-networking/cns/multitenantcontroller"
@@ -48,6 +49,7 @@ import (
 	"github.com/avast/retry-go/v3"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
@@ -479,6 +481,17 @@ func main() {
 	// Log platform information.
 	logger.Printf("Running on %v", platform.GetOSInfo())
 
+	store.SetRecoveryHandler(func(fileName, snapshot string, err error) {
+		logger.Errorf("Recovered corrupt store %s from snapshot %s: %v", fileName, snapshot, err)
+		logger.SendMetric(aitelemetry.Metric{
+			Name:             logger.StoreRecoveredMetricStr,
+			Value:            1.0,
+			CustomDimensions: map[string]string{logger.StoreFileStr: fileName},
+		})
+	})
+	// versions before the store checksum read files written with it as empty, so it is disabled before rolling back.
+	store.SetChecksumEnabled(cnsconfig.EnableStoreChecksum)
+
 	err = platform.CreateDirectory(storeFileLocation)
 	if err != nil {
 		logger.Errorf("Failed to create File Store directory %s, due to Error:%v", storeFileLocation, err.Error())
@@ -493,7 +506,7 @@ func main() {
 
 	// Create the key value store.
 	storeFileName := storeFileLocation + name + ".json"
-	config.Store, err = store.NewJsonFileStore(storeFileName, lockclient)
+	config.Store, err = store.New(store.Backend(cnsconfig.StoreBackend), storeFileName, lockclient)
 	if err != nil {
 		logger.Errorf("Failed to create store file: %s, due to error %v\n", storeFileName, err)
 		return
@@ -578,7 +591,21 @@ func main() {
 		}
 		logger.Printf("Set GlobalPodInfoScheme %v (InitializeFromCNI=%t)", cns.GlobalPodInfoScheme, cnsconfig.InitializeFromCNI)
 
-		err = InitializeCRDState(rootCtx, httpRestService, cnsconfig)
+		var journal *ipamjournal.Journal
+		if cnsconfig.EnableIPAMJournal {
+			journalFileName := storeFileLocation + name + "-ipam.json"
+			journalStore, err := store.New(store.Backend(cnsconfig.StoreBackend), journalFileName, nil) //nolint:govet // ignore err shadow
+			if err != nil {
+				logger.Errorf("Failed to create IPAM journal store file: %s, due to error %v\n", journalFileName, err)
+				return
+			}
+			if journal, err = ipamjournal.New(journalStore); err != nil {
+				logger.Errorf("Failed to load IPAM journal, err:%v.\n", err)
+				return
+			}
+		}
+
+		err = InitializeCRDState(rootCtx, httpRestService, cnsconfig, journal)
 		if err != nil {
 			logger.Errorf("Failed to start CRD Controller, err:%v.\n", err)
 			return
@@ -691,7 +718,7 @@ func main() {
 
 		// Create the key value store.
 		pluginStoreFile := storeFileLocation + pluginName + ".json"
-		pluginConfig.Store, err = store.NewJsonFileStore(pluginStoreFile, lockclientCnm)
+		pluginConfig.Store, err = store.New(store.Backend(cnsconfig.StoreBackend), pluginStoreFile, lockclientCnm)
 		if err != nil {
 			logger.Errorf("Failed to create plugin store file %s, due to error : %v\n", pluginStoreFile, err)
 			return
@@ -864,10 +891,10 @@ func reconcileInitialCNSState(ctx context.Context, cli nodeNetworkConfigGetter,
 		return errors.Wrap(err, "failed to reconcile NC state")
 	}
 
-	// Convert to CreateNetworkContainerRequest
-	ncRequest, err := kubecontroller.CRDStatusToNCRequest(&nnc.Status)
+	// Convert to CreateNetworkContainerRequests
+	ncRequests, err := kubecontroller.CRDStatusToNCRequests(&nnc.Status)
 	if err != nil {
-		return errors.Wrap(err, "failed to convert NNC status to network container request")
+		return errors.Wrap(err, "failed to convert NNC status to network container requests")
 	}
 	// rebuild CNS state
 	podInfoByIP, err := podInfoByIPProvider.PodInfoByIP()
@@ -875,13 +902,19 @@ func reconcileInitialCNSState(ctx context.Context, cli nodeNetworkConfigGetter,
 		return errors.Wrap(err, "provider failed to provide PodInfoByIP")
 	}
 
-	// Call cnsclient init cns passing those two things.
-	err = restserver.ResponseCodeToError(ncReconciler.ReconcileNCState(&ncRequest, podInfoByIP, nnc))
-	return errors.Wrap(err, "failed to reconcile NC state")
+	// Call cnsclient init cns passing those two things, for each NC.
+	for i := range ncRequests {
+		err = restserver.ResponseCodeToError(ncReconciler.ReconcileNCState(&ncRequests[i], podInfoByIP, nnc))
+		if err != nil {
+			return errors.Wrapf(err, "failed to reconcile NC %s state", ncRequests[i].NetworkContainerid)
+		}
+	}
+	return nil
 }
 
 // InitializeCRDState builds and starts the CRD controllers.
-func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cnsconfig *configuration.CNSConfig) error {
+// If the journal is not nil, it records every IP assignment and is replayed to initialize CNS state.
+func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cnsconfig *configuration.CNSConfig, journal *ipamjournal.Journal) error {
 	// convert interface type to implementation type
 	httpRestServiceImplementation, ok := httpRestService.(*restserver.HTTPRestService)
 	if !ok {
@@ -914,28 +947,52 @@ func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cn
 		return errors.Wrap(err, "failed to get NodeName")
 	}
 
+	// the kube provider lists the Pods scheduled to this Node from the apiserver.
+	listNodePods := func() ([]corev1.Pod, error) {
+		pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{ //nolint:govet // ignore err shadow
+			FieldSelector: "spec.nodeName=" + nodeName,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list Pods")
+		}
+		return pods.Items, nil
+	}
+	kubePodInfoByIPProvider := cns.PodInfoByIPProviderFunc(func() (map[string]cns.PodInfo, error) {
+		pods, err := listNodePods()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list Pods for PodInfoProvider")
+		}
+		podInfo, err := cns.KubePodsToPodInfoByIP(pods)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to convert Pods to PodInfoByIP")
+		}
+		return podInfo, nil
+	})
+
 	var podInfoByIPProvider cns.PodInfoByIPProvider
 	if cnsconfig.InitializeFromCNI {
 		logger.Printf("Initializing from CNI")
 		podInfoByIPProvider, err = cnireconciler.NewCNIPodInfoProvider()
 		if err != nil {
-			return errors.Wrap(err, "failed to create CNI PodInfoProvider")
+			if journal == nil {
+				return errors.Wrap(err, "failed to create CNI PodInfoProvider")
+			}
+			// the journal can still be replayed without the CNI, so the failure is deferred to the cross-check.
+			cniErr := errors.Wrap(err, "failed to create CNI PodInfoProvider")
+			podInfoByIPProvider = cns.PodInfoByIPProviderFunc(func() (map[string]cns.PodInfo, error) {
+				return nil, cniErr
+			})
 		}
 	} else {
 		logger.Printf("Initializing from Kubernetes")
-		podInfoByIPProvider = cns.PodInfoByIPProviderFunc(func() (map[string]cns.PodInfo, error) {
-			pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{ //nolint:govet // ignore err shadow
-				FieldSelector: "spec.nodeName=" + nodeName,
-			})
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to list Pods for PodInfoProvider")
-			}
-			podInfo, err := cns.KubePodsToPodInfoByIP(pods.Items)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to convert Pods to PodInfoByIP")
-			}
-			return podInfo, nil
-		})
+		podInfoByIPProvider = kubePodInfoByIPProvider
+	}
+
+	if journal != nil {
+		logger.Printf("Initializing from IPAM journal")
+		podInfoByIPProvider = ipamjournal.NewRecoveryPodInfoProvider(journal, podInfoByIPProvider)
+		httpRestServiceImplementation.IPAssignmentJournal = journal
+		httpRestServiceImplementation.SetStickyIPJournal(journal)
 	}
 
 	// create scoped kube clients.
@@ -947,12 +1004,24 @@ func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cn
 	scopedcli := kubecontroller.NewScopedClient(nnccli, types.NamespacedName{Namespace: "kube-system", Name: nodeName})
 
 	// initialize the ipam pool monitor
+	poolStrategy := ipampool.NewStrategy(cnsconfig.PoolScalingStrategy)
+	if rate, ok := poolStrategy.(*ipampool.RateStrategy); ok {
+		httpRestServiceImplementation.WithIPStateMiddleware(rate.ObserveStateTransition)
+	}
+	logger.Printf("Using %s IPAM pool scaling strategy", poolStrategy.Name())
 	poolOpts := ipampool.Options{
 		RefreshDelay: poolIPAMRefreshRateInMilliseconds * time.Millisecond,
+		Strategy:     poolStrategy,
 	}
 	poolMonitor := ipampool.NewMonitor(httpRestServiceImplementation, scopedcli, &poolOpts)
 	httpRestServiceImplementation.IPAMPoolMonitor = poolMonitor
 
+	// the NC selector chooses the NC to assign IPs from when there are multiple NCs on the Node.
+	ncSelector := restserver.NewNCSelector(restserver.NCSelectionPolicy(cnsconfig.NCSelectionPolicy))
+	httpRestServiceImplementation.NCSelector = ncSelector
+	httpRestServiceImplementation.SetNamespaceIPQuotas(cnsconfig.NamespaceIPQuotas)
+	httpRestServiceImplementation.SetStickyIPReservationTTL(time.Duration(cnsconfig.StickyIPReservationTTLMs) * time.Millisecond)
+
 	// reconcile initial CNS state from CNI or apiserver.
 	// apiserver nnc might not be registered or api server might be down and crashloop backof puts us outside of 5-10 minutes we have for
 	// aks addons to come up so retry a bit more aggresively here.
@@ -972,6 +1041,11 @@ func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cn
 	}
 	logger.Printf("reconciled initial CNS state after %d attempts", attempt)
 
+	// the assignments replayed by the reconcile are not new Pods, so the assignment rate is measured from here.
+	if rate, ok := poolStrategy.(*ipampool.RateStrategy); ok {
+		rate.Seed()
+	}
+
 	// start the pool Monitor before the Reconciler, since it needs to be ready to receive an
 	// NodeNetworkConfig update by the time the Reconciler tries to send it.
 	go func() {
@@ -1010,7 +1084,7 @@ func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cn
 		return errors.Wrapf(err, "failed to get node %s", nodeName)
 	}
 
-	reconciler := kubecontroller.NewReconciler(nnccli, kubecontroller.SwiftNodeNetworkConfigListener(httpRestServiceImplementation), poolMonitor)
+	reconciler := kubecontroller.NewReconciler(nnccli, kubecontroller.SwiftNodeNetworkConfigListener(httpRestServiceImplementation), ncSelector, poolMonitor)
 	// pass Node to the Reconciler for Controller xref
 	if err := reconciler.SetupWithManager(manager, node); err != nil {
 		return errors.Wrapf(err, "failed to setup reconciler with manager")
@@ -1084,5 +1158,26 @@ func InitializeCRDState(ctx context.Context, httpRestService cns.HTTPService, cn
 
 	logger.Printf("initialized and started SyncHostNCVersion loop")
 
+	if cnsconfig.EnableOrphanedIPReconciler {
+		// the Pods are listed whether or not they have an IP yet, so that the IPs of starting Pods are not reclaimed.
+		kubePodInfoLister := restserver.PodInfoListerFunc(func() ([]cns.PodInfo, error) {
+			pods, err := listNodePods()
+			if err != nil {
+				return nil, err
+			}
+			return cns.KubePodsToPodInfos(pods), nil
+		})
+		orphanedIPReconciler := restserver.NewOrphanedIPReconciler(httpRestServiceImplementation, kubePodInfoLister,
+			time.Duration(cnsconfig.OrphanedIPReconcileIntervalMs)*time.Millisecond,
+			time.Duration(cnsconfig.OrphanedIPGracePeriodMs)*time.Millisecond)
+		go func() {
+			logger.Printf("starting orphaned IP reconciler")
+			if err := orphanedIPReconciler.Start(ctx); err != nil {
+				logger.Printf("exiting orphaned IP reconciler: %v", err)
+			}
+		}()
+		logger.Printf("initialized and started orphaned IP reconciler")
+	}
+
 	return nil
 }
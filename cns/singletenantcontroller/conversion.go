@@ -19,8 +19,6 @@ var (
 	ErrInvalidPrimaryIP = errors.New("invalid primary IP")
 	// ErrInvalidSecondaryIP indicates that a secondary IP on the NC is invalid.
 	ErrInvalidSecondaryIP = errors.New("invalid secondary IP")
-	// ErrUnsupportedNCQuantity indicates that the node has an unsupported nummber of Network Containers attached.
-	ErrUnsupportedNCQuantity = errors.New("unsupported number of network containers")
 )
 
 type cnsClient interface {
@@ -37,46 +35,56 @@ func (f NodeNetworkConfigListenerFunc) Update(nnc *v1alpha.NodeNetworkConfig) er
 
 // SwiftNodeNetworkConfigListener return a function which satisfies the NodeNetworkConfigListener
 // interface. It accepts a CreateOrUpdateNetworkContainerInternal implementation, and when Update
-// is called, transforms the NNC in to an NC Request and calls the CNS Service implementation with
-// that request.
+// is called, transforms the NNC in to NC Requests and calls the CNS Service implementation with
+// each request.
 func SwiftNodeNetworkConfigListener(cnscli cnsClient) NodeNetworkConfigListenerFunc {
 	return func(nnc *v1alpha.NodeNetworkConfig) error {
-		// Create NC request and hand it off to CNS
-		ncRequest, err := CRDStatusToNCRequest(&nnc.Status)
+		// Create NC requests and hand them off to CNS
+		ncRequests, err := CRDStatusToNCRequests(&nnc.Status)
 		if err != nil {
-			return errors.Wrap(err, "failed to convert NNC status to network container request")
+			return errors.Wrap(err, "failed to convert NNC status to network container requests")
 		}
-		responseCode := cnscli.CreateOrUpdateNetworkContainerInternal(&ncRequest)
-		err = restserver.ResponseCodeToError(responseCode)
-		if err != nil {
-			logger.Errorf("[cns-rc] Error creating or updating NC in reconcile: %v", err)
-			return errors.Wrap(err, "failed to create or update network container")
+		assignedIPs := 0
+		for i := range ncRequests {
+			responseCode := cnscli.CreateOrUpdateNetworkContainerInternal(&ncRequests[i])
+			err = restserver.ResponseCodeToError(responseCode)
+			if err != nil {
+				logger.Errorf("[cns-rc] Error creating or updating NC %s in reconcile: %v", ncRequests[i].NetworkContainerid, err)
+				return errors.Wrapf(err, "failed to create or update network container %s", ncRequests[i].NetworkContainerid)
+			}
+			assignedIPs += len(ncRequests[i].SecondaryIPConfigs)
 		}
 
 		// record assigned IPs metric
-		allocatedIPs.Set(float64(len(nnc.Status.NetworkContainers[0].IPAssignments)))
+		allocatedIPs.Set(float64(assignedIPs))
 		return nil
 	}
 }
 
-// CRDStatusToNCRequest translates a crd status to createnetworkcontainer request
-func CRDStatusToNCRequest(status *v1alpha.NodeNetworkConfigStatus) (cns.CreateNetworkContainerRequest, error) {
-	// if NNC has no NC, return an empty request
-	if len(status.NetworkContainers) == 0 {
-		return cns.CreateNetworkContainerRequest{}, nil
-	}
-
-	// only support a single NC per node, error on more
-	if len(status.NetworkContainers) > 1 {
-		return cns.CreateNetworkContainerRequest{}, errors.Wrapf(ErrUnsupportedNCQuantity, "count: %d", len(status.NetworkContainers))
+// CRDStatusToNCRequests translates a crd status to a createnetworkcontainer request for each NC, in the same order.
+func CRDStatusToNCRequests(status *v1alpha.NodeNetworkConfigStatus) ([]cns.CreateNetworkContainerRequest, error) {
+	ncRequests := make([]cns.CreateNetworkContainerRequest, 0, len(status.NetworkContainers))
+	for i := range status.NetworkContainers {
+		ncRequest, err := ncToCreateNetworkContainerRequest(&status.NetworkContainers[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to convert NC %s", status.NetworkContainers[i].ID)
+		}
+		ncRequests = append(ncRequests, ncRequest)
 	}
+	return ncRequests, nil
+}
 
-	nc := status.NetworkContainers[0]
+// ncToCreateNetworkContainerRequest translates a single NC from the crd status to a createnetworkcontainer request.
+func ncToCreateNetworkContainerRequest(nc *v1alpha.NetworkContainer) (cns.CreateNetworkContainerRequest, error) {
 
 	primaryIP := nc.PrimaryIP
-	// if the PrimaryIP is not a CIDR, append a /32
+	// if the PrimaryIP is not a CIDR, append a /32, or a /128 for the primary IP of an IPv6 NC
 	if !strings.Contains(primaryIP, "/") {
-		primaryIP += "/32"
+		if strings.Contains(primaryIP, ":") {
+			primaryIP += "/128"
+		} else {
+			primaryIP += "/32"
+		}
 	}
 
 	primaryPrefix, err := netip.ParsePrefix(primaryIP)
@@ -21,6 +21,14 @@ const (
 	subnetPrefixLen    = 24
 	testSecIP          = "10.0.0.2"
 	version            = 1
+
+	uuid2               = "6a07155a-32d7-49af-872f-1e70ee366dc0"
+	defaultGateway2     = "10.1.0.2"
+	ncID2               = "f2e8c5a2-6bd3-4c6e-9a13-a6bb4b1e5c6d"
+	primaryIP2          = "10.1.0.1"
+	subnetAddressSpace2 = "10.1.0.0/24"
+	subnetName2         = "subnet2"
+	testSecIP2          = "10.1.0.2"
 )
 
 var invalidStatusMultiNC = v1alpha.NodeNetworkConfigStatus{
@@ -71,30 +79,78 @@ var validRequest = cns.CreateNetworkContainerRequest{
 	},
 }
 
-func TestConvertNNCStatusToNCRequest(t *testing.T) {
+var validStatusMultiNC = v1alpha.NodeNetworkConfigStatus{
+	NetworkContainers: []v1alpha.NetworkContainer{
+		validStatus.NetworkContainers[0],
+		{
+			PrimaryIP: primaryIP2,
+			ID:        ncID2,
+			IPAssignments: []v1alpha.IPAssignment{
+				{
+					Name: uuid2,
+					IP:   testSecIP2,
+				},
+			},
+			SubnetName:         subnetName2,
+			DefaultGateway:     defaultGateway2,
+			SubnetAddressSpace: subnetAddressSpace2,
+			Version:            version,
+		},
+	},
+	Scaler: v1alpha.Scaler{
+		BatchSize: 1,
+	},
+}
+
+var validRequest2 = cns.CreateNetworkContainerRequest{
+	Version: strconv.FormatInt(version, 10),
+	IPConfiguration: cns.IPConfiguration{
+		GatewayIPAddress: defaultGateway2,
+		IPSubnet: cns.IPSubnet{
+			PrefixLength: uint8(subnetPrefixLen),
+			IPAddress:    primaryIP2,
+		},
+	},
+	NetworkContainerid:   ncID2,
+	NetworkContainerType: cns.Docker,
+	SecondaryIPConfigs: map[string]cns.SecondaryIPConfig{
+		uuid2: {
+			IPAddress: testSecIP2,
+			NCVersion: version,
+		},
+	},
+}
+
+func TestConvertNNCStatusToNCRequests(t *testing.T) {
 	tests := []struct {
 		name    string
 		input   v1alpha.NodeNetworkConfigStatus
-		want    cns.CreateNetworkContainerRequest
+		want    []cns.CreateNetworkContainerRequest
 		wantErr bool
 	}{
 		{
 			name:    "valid",
 			input:   validStatus,
 			wantErr: false,
-			want:    validRequest,
+			want:    []cns.CreateNetworkContainerRequest{validRequest},
 		},
 		{
 			name:    "no nc",
 			input:   v1alpha.NodeNetworkConfigStatus{},
 			wantErr: false,
-			want:    cns.CreateNetworkContainerRequest{},
+			want:    []cns.CreateNetworkContainerRequest{},
 		},
 		{
-			name:    ">1 nc",
+			name:    ">1 invalid nc",
 			input:   invalidStatusMultiNC,
 			wantErr: true,
 		},
+		{
+			name:    ">1 nc",
+			input:   validStatusMultiNC,
+			wantErr: false,
+			want:    []cns.CreateNetworkContainerRequest{validRequest, validRequest2},
+		},
 		{
 			name: "malformed primary IP",
 			input: v1alpha.NodeNetworkConfigStatus{
@@ -157,7 +213,48 @@ func TestConvertNNCStatusToNCRequest(t *testing.T) {
 				},
 			},
 			wantErr: false,
-			want:    validRequest,
+			want:    []cns.CreateNetworkContainerRequest{validRequest},
+		},
+		{
+			name: "IPv6 NC",
+			input: v1alpha.NodeNetworkConfigStatus{
+				NetworkContainers: []v1alpha.NetworkContainer{
+					{
+						PrimaryIP: "fd00::1",
+						ID:        ncID,
+						IPAssignments: []v1alpha.IPAssignment{
+							{
+								Name: uuid,
+								IP:   "fd00::2",
+							},
+						},
+						DefaultGateway:     "fd00::ffff",
+						SubnetAddressSpace: "fd00::/64",
+						Version:            version,
+					},
+				},
+			},
+			wantErr: false,
+			want: []cns.CreateNetworkContainerRequest{
+				{
+					Version: strconv.FormatInt(version, 10),
+					IPConfiguration: cns.IPConfiguration{
+						GatewayIPAddress: "fd00::ffff",
+						IPSubnet: cns.IPSubnet{
+							PrefixLength: 64,
+							IPAddress:    "fd00::1",
+						},
+					},
+					NetworkContainerid:   ncID,
+					NetworkContainerType: cns.Docker,
+					SecondaryIPConfigs: map[string]cns.SecondaryIPConfig{
+						uuid: {
+							IPAddress: "fd00::2",
+							NCVersion: version,
+						},
+					},
+				},
+			},
 		},
 		{
 			name: "IP assignment is CIDR",
@@ -201,7 +298,7 @@ func TestConvertNNCStatusToNCRequest(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := CRDStatusToNCRequest(&tt.input)
+			got, err := CRDStatusToNCRequests(&tt.input)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
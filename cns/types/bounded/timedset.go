@@ -48,6 +48,12 @@ func NewTimedSet(c int) *TimedSet {
 // Push registers the passed key and saves the timestamp it is first registered.
 // If the key is already registered, does not overwrite the saved timestamp.
 func (ts *TimedSet) Push(key string) {
+	ts.PushAt(key, time.Now())
+}
+
+// PushAt registers the passed key as first registered at the passed time, such as to restore a
+// key registered before a restart. If the key is already registered, does not overwrite the saved timestamp.
+func (ts *TimedSet) PushAt(key string, t time.Time) {
 	ts.Lock()
 	defer ts.Unlock()
 	if _, ok := ts.items.Contains(key); ok {
@@ -57,10 +63,21 @@ func (ts *TimedSet) Push(key string) {
 		_ = heap.Pop(ts.items)
 	}
 	item := &TimedItem{Name: key}
-	item.Time = time.Now()
+	item.Time = t
 	heap.Push(ts.items, item)
 }
 
+// Time returns the timestamp the passed key was first registered, and whether it is registered.
+func (ts *TimedSet) Time(key string) (time.Time, bool) {
+	ts.Lock()
+	defer ts.Unlock()
+	idx, ok := ts.items.Contains(key)
+	if !ok {
+		return time.Time{}, false
+	}
+	return ts.items.items[idx].(*TimedItem).Time, true
+}
+
 // Pop returns the elapsed duration since the passed key was first registered,
 // or -1 if it is not found.
 func (ts *TimedSet) Pop(key string) time.Duration {
@@ -73,3 +90,22 @@ func (ts *TimedSet) Pop(key string) time.Duration {
 	item := heap.Remove(ts.items, idx)
 	return time.Since(item.(*TimedItem).Time)
 }
+
+// Contains returns whether the passed key is registered.
+func (ts *TimedSet) Contains(key string) bool {
+	ts.Lock()
+	defer ts.Unlock()
+	_, ok := ts.items.Contains(key)
+	return ok
+}
+
+// Expire removes and returns the keys which were first registered longer ago than the passed TTL.
+func (ts *TimedSet) Expire(ttl time.Duration) []string {
+	ts.Lock()
+	defer ts.Unlock()
+	expired := []string{}
+	for ts.items.Len() > 0 && time.Since(ts.items.items[0].(*TimedItem).Time) > ttl {
+		expired = append(expired, heap.Pop(ts.items).(*TimedItem).Name)
+	}
+	return expired
+}
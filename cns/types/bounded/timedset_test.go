@@ -84,3 +84,20 @@ func TestNewTimedSet(t *testing.T) {
 		})
 	}
 }
+
+func TestTimedSetExpire(t *testing.T) {
+	ts := NewTimedSet(3)
+	ts.Push("a")
+	ts.Push("b")
+	// backdate the first items instead of sleeping past the TTL.
+	ts.items.m["a"].(*TimedItem).Time = time.Now().Add(-time.Hour)
+	ts.items.m["b"].(*TimedItem).Time = time.Now().Add(-time.Minute)
+	ts.Push("c")
+
+	assert.Equal(t, []string{"a"}, ts.Expire(30*time.Minute))
+	assert.False(t, ts.Contains("a"))
+	assert.True(t, ts.Contains("b"))
+	assert.Equal(t, []string{"b"}, ts.Expire(30*time.Second))
+	assert.Empty(t, ts.Expire(30*time.Second))
+	assert.True(t, ts.Contains("c"))
+}
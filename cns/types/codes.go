@@ -38,6 +38,7 @@ const (
 	NetworkContainerVfpProgramCheckSkipped ResponseCode = 36
 	NmAgentSupportedApisError              ResponseCode = 37
 	UnsupportedNCVersion                   ResponseCode = 38
+	NamespaceIPQuotaExceeded               ResponseCode = 39
 	UnexpectedError                        ResponseCode = 99
 )
 
@@ -66,6 +67,8 @@ func (c ResponseCode) String() string {
 		return "InvalidSecondaryIPConfig"
 	case MalformedSubnet:
 		return "MalformedSubnet"
+	case NamespaceIPQuotaExceeded:
+		return "NamespaceIPQuotaExceeded"
 	case NetworkContainerNotSpecified:
 		return "NetworkContainerNotSpecified"
 	case NetworkContainerPublishFailed:
@@ -99,6 +99,10 @@ const (
 	OptStoreFileLocation      = "store-file-path"
 	OptStoreFileLocationAlias = "storefilepath"
 
+	// Store backend
+	OptStoreBackend      = "store-backend"
+	OptStoreBackendAlias = "storebackend"
+
 	// Private Endpoint
 	OptPrivateEndpoint      = "private-endpoint"
 	OptPrivateEndpointAlias = "pe"
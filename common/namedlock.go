@@ -1,9 +1,15 @@
 package common
 
 import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/pkg/errors"
 )
 
 // NamedLock holds a mutex and a map of locks. Mutex is used to
@@ -77,3 +83,140 @@ func (refCountedLock *refCountedLock) Lock() {
 func (refCountedLock *refCountedLock) Unlock() {
 	refCountedLock.mutex.Unlock()
 }
+
+// NamedFileLock is a named lock held across processes. It builds on NamedLock, which orders the lockers of a name
+// within the process, and locks a file in its directory for the lockers of the name in other processes. The names
+// are spread over a bounded number of lock files, so that the files don't pile up with every name ever locked. The
+// names of a process which share a lock file share its lock, so a process may hold several names at once.
+type NamedFileLock struct {
+	dir        string
+	numStripes uint32
+	namedLock  *NamedLock
+	mutex      sync.Mutex
+	stripes    map[string]*refCountedFileLock
+}
+
+// refCountedFileLock holds the lock of a lock file and the number of names of the process which share it.
+type refCountedFileLock struct {
+	mutex    sync.Mutex
+	fileLock processlock.Interface
+	refCount int
+}
+
+// DefaultNamedFileLockStripes is the number of lock files a NamedFileLock spreads its names over.
+const DefaultNamedFileLockStripes = 256
+
+// ErrTimeoutLockingNamedFileLock is returned when the lock file of a name is held by another process for longer
+// than the timeout of LockAcquire.
+var ErrTimeoutLockingNamedFileLock = errors.New("timed out locking named file lock")
+
+// InitNamedFileLock initializes a named file lock with its lock files in the given directory.
+func InitNamedFileLock(dir string, numStripes uint32) *NamedFileLock {
+	return &NamedFileLock{
+		dir:        dir,
+		numStripes: numStripes,
+		namedLock:  InitNamedLock(),
+		stripes:    make(map[string]*refCountedFileLock),
+	}
+}
+
+// lockFilePath returns the path of the lock file of the given name.
+func (namedFileLock *NamedFileLock) lockFilePath(lockName string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(lockName))
+	return filepath.Join(namedFileLock.dir, fmt.Sprintf("named-%03d.lock", h.Sum32()%namedFileLock.numStripes))
+}
+
+// LockAcquire acquires the lock with specified name, waiting at most timeout for the lock file of the name.
+func (namedFileLock *NamedFileLock) LockAcquire(lockName string, timeout time.Duration) error {
+	namedFileLock.namedLock.LockAcquire(lockName)
+
+	path := namedFileLock.lockFilePath(lockName)
+	namedFileLock.mutex.Lock()
+	stripe, ok := namedFileLock.stripes[path]
+	if !ok {
+		stripe = &refCountedFileLock{}
+		namedFileLock.stripes[path] = stripe
+	}
+	stripe.refCount++
+	namedFileLock.mutex.Unlock()
+
+	// The first name of the process to use the lock file acquires its lock, the others wait for it.
+	stripe.mutex.Lock()
+	var err error
+	if stripe.fileLock == nil {
+		var fileLock processlock.Interface
+		if fileLock, err = processlock.NewFileLock(path); err == nil {
+			if err = lockWithTimeout(fileLock, timeout); err == nil {
+				stripe.fileLock = fileLock
+			}
+		}
+	}
+	stripe.mutex.Unlock()
+
+	if err != nil {
+		namedFileLock.releaseStripe(path)
+		namedFileLock.namedLock.LockRelease(lockName)
+		return errors.Wrapf(err, "failed to acquire file lock of %s", lockName)
+	}
+
+	return nil
+}
+
+// lockWithTimeout locks a lock file, waiting at most timeout for another process to release it. When the wait times
+// out, the lock is released as soon as it is acquired.
+func lockWithTimeout(fileLock processlock.Interface, timeout time.Duration) error {
+	status := make(chan error)
+	abandoned := make(chan struct{})
+	go func() {
+		err := fileLock.Lock()
+		select {
+		case status <- err:
+		case <-abandoned:
+			if err == nil {
+				_ = fileLock.Unlock()
+			}
+		}
+	}()
+
+	select {
+	case err := <-status:
+		return err //nolint:wrapcheck // wrapped by the caller
+	case <-time.After(timeout):
+		close(abandoned)
+		return ErrTimeoutLockingNamedFileLock
+	}
+}
+
+// LockRelease releases the lock with specified name
+func (namedFileLock *NamedFileLock) LockRelease(lockName string) error {
+	err := namedFileLock.releaseStripe(namedFileLock.lockFilePath(lockName))
+	namedFileLock.namedLock.LockRelease(lockName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to release file lock of %s", lockName)
+	}
+	return nil
+}
+
+// releaseStripe drops a reference to the lock of the lock file, releasing it when no name of the process uses it.
+func (namedFileLock *NamedFileLock) releaseStripe(path string) error {
+	namedFileLock.mutex.Lock()
+	defer namedFileLock.mutex.Unlock()
+
+	stripe, ok := namedFileLock.stripes[path]
+	if !ok {
+		log.Printf("Attempt to unlock: %s without acquiring the lock", path)
+		return nil
+	}
+
+	stripe.refCount--
+	if stripe.refCount > 0 {
+		return nil
+	}
+
+	delete(namedFileLock.stripes, path)
+	if stripe.fileLock == nil {
+		return nil
+	}
+	return stripe.fileLock.Unlock() //nolint:wrapcheck // wrapped by the caller
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedFileLockExcludesOtherProcesses(t *testing.T) {
+	dir := t.TempDir()
+	// Each NamedFileLock opens its own lock files, like the NamedFileLock of another process.
+	lock1 := InitNamedFileLock(dir, DefaultNamedFileLockStripes)
+	lock2 := InitNamedFileLock(dir, DefaultNamedFileLockStripes)
+
+	require.NoError(t, lock1.LockAcquire("container1", time.Second))
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- lock2.LockAcquire("container1", 5*time.Second)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("lock of container1 was acquired while it is held by another process")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, lock1.LockRelease("container1"))
+	select {
+	case err := <-acquired:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock of container1 was not acquired after it was released")
+	}
+	require.NoError(t, lock2.LockRelease("container1"))
+}
+
+func TestNamedFileLockSharesLockFiles(t *testing.T) {
+	// With a single lock file every name shares it.
+	lock := InitNamedFileLock(t.TempDir(), 1)
+
+	require.NoError(t, lock.LockAcquire("container1", time.Second))
+	require.NoError(t, lock.LockAcquire("container2", time.Second))
+	require.NoError(t, lock.LockRelease("container1"))
+	require.NoError(t, lock.LockRelease("container2"))
+	require.Empty(t, lock.stripes)
+
+	require.NoError(t, lock.LockAcquire("container1", time.Second))
+	require.NoError(t, lock.LockRelease("container1"))
+}
+
+func TestNamedFileLockTimeout(t *testing.T) {
+	dir := t.TempDir()
+	lock1 := InitNamedFileLock(dir, DefaultNamedFileLockStripes)
+	lock2 := InitNamedFileLock(dir, DefaultNamedFileLockStripes)
+
+	require.NoError(t, lock1.LockAcquire("container1", time.Second))
+	require.ErrorIs(t, lock2.LockAcquire("container1", 100*time.Millisecond), ErrTimeoutLockingNamedFileLock)
+	require.Empty(t, lock2.stripes)
+
+	// The lock file abandoned by the timed out wait is released once it is acquired.
+	require.NoError(t, lock1.LockRelease("container1"))
+	require.NoError(t, lock2.LockAcquire("container1", 5*time.Second))
+	require.NoError(t, lock2.LockRelease("container1"))
+}
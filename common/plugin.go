@@ -40,6 +40,10 @@ type PluginConfig struct {
 	Listener *Listener
 	ErrChan  chan error
 	Store    store.KeyValueStore
+	// LockStorePerChange makes the network manager lock the store only around each change of its state, rather
+	// than the owner of the store holding its lock throughout, so that the processes sharing the store run
+	// concurrently.
+	LockStorePerChange bool
 }
 
 // NewPlugin creates a new Plugin object.
@@ -43,6 +43,16 @@ type NodeNetworkConfigList struct {
 type NodeNetworkConfigSpec struct {
 	RequestedIPCount int64    `json:"requestedIPCount,omitempty"`
 	IPsNotInUse      []string `json:"ipsNotInUse,omitempty"`
+	// NetworkContainers is the desired state of each NetworkContainer's IP pool when the Node has
+	// more than one. RequestedIPCount and IPsNotInUse are the totals across all of them.
+	NetworkContainers []NetworkContainerSpec `json:"networkContainers,omitempty"`
+}
+
+// NetworkContainerSpec defines the desired state of a single NetworkContainer's IP pool
+type NetworkContainerSpec struct {
+	ID               string   `json:"id,omitempty"`
+	RequestedIPCount int64    `json:"requestedIPCount,omitempty"`
+	IPsNotInUse      []string `json:"ipsNotInUse,omitempty"`
 }
 
 // Status indicates the NNC reconcile status
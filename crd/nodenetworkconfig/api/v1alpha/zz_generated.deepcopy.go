@@ -44,6 +44,26 @@ func (in *NetworkContainer) DeepCopy() *NetworkContainer {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkContainerSpec) DeepCopyInto(out *NetworkContainerSpec) {
+	*out = *in
+	if in.IPsNotInUse != nil {
+		in, out := &in.IPsNotInUse, &out.IPsNotInUse
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkContainerSpec.
+func (in *NetworkContainerSpec) DeepCopy() *NetworkContainerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkContainerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeNetworkConfig) DeepCopyInto(out *NodeNetworkConfig) {
 	*out = *in
@@ -111,6 +131,13 @@ func (in *NodeNetworkConfigSpec) DeepCopyInto(out *NodeNetworkConfigSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.NetworkContainers != nil {
+		in, out := &in.NetworkContainers, &out.NetworkContainers
+		*out = make([]NetworkContainerSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeNetworkConfigSpec.
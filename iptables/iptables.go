@@ -13,6 +13,9 @@ import (
 const (
 	CNIInputChain  = "AZURECNIINPUT"
 	CNIOutputChain = "AZURECNIOUTPUT"
+	// CNIHostPortChain DNATs host ports to endpoints and CNIHostPortMasqChain masquerades hairpin traffic to them.
+	CNIHostPortChain     = "AZURECNIHOSTPORT"
+	CNIHostPortMasqChain = "AZURECNIHPMASQ"
 )
 
 // standard iptable chains
@@ -39,6 +42,7 @@ const (
 	Accept     = "ACCEPT"
 	Drop       = "DROP"
 	Masquerade = "MASQUERADE"
+	Dnat       = "DNAT"
 )
 
 // actions
@@ -73,8 +77,9 @@ const (
 
 // known protocols
 const (
-	UDP = "udp"
-	TCP = "tcp"
+	UDP  = "udp"
+	TCP  = "tcp"
+	SCTP = "sctp"
 )
 
 var DisableIPTableLock bool
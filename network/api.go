@@ -6,6 +6,7 @@ package network
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -20,6 +21,9 @@ var (
 	errMultipleEndpointsFound = fmt.Errorf("Multiple endpoints found")
 	errEndpointInUse          = fmt.Errorf("Endpoint is already joined to a sandbox")
 	errEndpointNotInUse       = fmt.Errorf("Endpoint is not joined to a sandbox")
+
+	// ErrEndpointDiverged is returned by CheckEndpoint when the datapath doesn't match the endpoint state.
+	ErrEndpointDiverged = errors.New("Endpoint datapath diverged from state")
 )
 
 type networkNotFoundError struct{}
@@ -31,3 +35,16 @@ func (n *networkNotFoundError) Error() string {
 func IsNetworkNotFoundError(err error) bool {
 	return errors.Is(err, errNetworkNotFound)
 }
+
+// EndpointDivergedError lists every way in which the datapath of an endpoint differs from its state.
+type EndpointDivergedError struct {
+	Divergences []string
+}
+
+func (e *EndpointDivergedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrEndpointDiverged.Error(), strings.Join(e.Divergences, "; "))
+}
+
+func (e *EndpointDivergedError) Unwrap() error {
+	return ErrEndpointDiverged
+}
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+)
+
+const (
+	// Prefix for ifb interface names, which shape the traffic sent by endpoints.
+	ifbInterfacePrefix = commonInterfacePrefix + "b"
+	// maxInterfaceNameLength is the longest interface name accepted by the kernel.
+	maxInterfaceNameLength = 15
+)
+
+var errBandwidthNotSupported = errors.New("Bandwidth shaping requires a host veth and is not supported in ipvlan mode")
+
+// getIFBName returns the name of the ifb interface of a host veth.
+func getIFBName(hostIfName string) string {
+	ifbName := ifbInterfacePrefix + strings.TrimPrefix(hostIfName, hostVEthInterfacePrefix)
+	if len(ifbName) > maxInterfaceNameLength {
+		ifbName = ifbName[:maxInterfaceNameLength]
+	}
+
+	return ifbName
+}
+
+// addBandwidthShaping limits the traffic of an endpoint on its host veth. The traffic to the endpoint
+// is shaped when it leaves the host veth, and the traffic from the endpoint is redirected to an ifb
+// interface and shaped when it leaves it.
+func addBandwidthShaping(nu networkutils.NetworkUtils, hostIfName string, bw *BandwidthInfo) error {
+	if bw.IngressRate > 0 {
+		log.Printf("[net] Limiting ingress of %v to %d bit/s.", hostIfName, bw.IngressRate)
+		if err := nu.AddTokenBucketFilter(hostIfName, bw.IngressRate, bw.IngressBurst); err != nil {
+			return err
+		}
+	}
+
+	if bw.EgressRate > 0 {
+		ifbName := getIFBName(hostIfName)
+		log.Printf("[net] Limiting egress of %v to %d bit/s on %v.", hostIfName, bw.EgressRate, ifbName)
+		if err := nu.CreateIFBRedirect(hostIfName, ifbName); err != nil {
+			return err
+		}
+
+		if err := nu.AddTokenBucketFilter(ifbName, bw.EgressRate, bw.EgressBurst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteBandwidthShaping deletes the ifb interface of an endpoint. The qdiscs on the host veth are
+// deleted with it.
+func deleteBandwidthShaping(nu networkutils.NetworkUtils, hostIfName string, bw *BandwidthInfo) {
+	if bw.EgressRate == 0 {
+		return
+	}
+
+	if err := nu.DeleteIFB(getIFBName(hostIfName)); err != nil {
+		log.Printf("[net] Failed to delete ifb interface of %v: %v.", hostIfName, err)
+	}
+}
@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetIFBName(t *testing.T) {
+	require.Equal(t, "azb1234567", getIFBName("azv1234567"))
+	require.Equal(t, "azb1234567890ab", getIFBName("azv1234567890ab"))
+	require.Equal(t, "azbhostinterfac", getIFBName("hostinterface01"))
+}
+
+func TestAddBandwidthShaping(t *testing.T) {
+	tests := []struct {
+		name    string
+		nl      netlink.NetlinkInterface
+		plc     platform.ExecClient
+		bw      *BandwidthInfo
+		wantErr bool
+	}{
+		{
+			name: "Shape ingress and egress",
+			nl:   netlink.NewMockNetlink(false, ""),
+			plc:  platform.NewMockExecClient(false),
+			bw:   &BandwidthInfo{IngressRate: 1000000, IngressBurst: 200000, EgressRate: 1000000, EgressBurst: 200000},
+		},
+		{
+			name:    "Shape ingress tc fail",
+			nl:      netlink.NewMockNetlink(false, ""),
+			plc:     platform.NewMockExecClient(true),
+			bw:      &BandwidthInfo{IngressRate: 1000000, IngressBurst: 200000},
+			wantErr: true,
+		},
+		{
+			name:    "Shape egress ifb fail",
+			nl:      netlink.NewMockNetlink(true, "netlink fail"),
+			plc:     platform.NewMockExecClient(false),
+			bw:      &BandwidthInfo{EgressRate: 1000000, EgressBurst: 200000},
+			wantErr: true,
+		},
+		{
+			name: "No rates",
+			nl:   netlink.NewMockNetlink(true, "netlink fail"),
+			plc:  platform.NewMockExecClient(true),
+			bw:   &BandwidthInfo{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := addBandwidthShaping(networkutils.NewNetworkUtils(tt.nl, tt.plc), "azv1234567", tt.bw)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
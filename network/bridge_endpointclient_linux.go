@@ -3,6 +3,7 @@ package network
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/ebtables"
 	"github.com/Azure/azure-container-networking/log"
@@ -65,6 +66,12 @@ func (client *LinuxBridgeEndpointClient) AddEndpoints(epInfo *EndpointInfo) erro
 		return err
 	}
 
+	if epInfo.MTU > 0 {
+		if err := client.nuc.SetVethMTU(client.hostVethName, client.containerVethName, epInfo.MTU); err != nil {
+			return err
+		}
+	}
+
 	containerIf, err := net.InterfaceByName(client.containerVethName)
 	if err != nil {
 		return err
@@ -211,6 +218,71 @@ func (client *LinuxBridgeEndpointClient) DeleteEndpoints(ep *endpoint) error {
 	return nil
 }
 
+func (client *LinuxBridgeEndpointClient) CheckEndpointRules(ep *endpoint) []string {
+	if divergences := checkInterface(client.netioshim, client.hostVethName); divergences != nil {
+		return divergences
+	}
+
+	rules, err := ebtables.GetEbtableRules(ebtables.Nat, ebtables.PreRouting)
+	if err != nil {
+		return []string{fmt.Sprintf("ebtables rules can't be listed: %v", err)}
+	}
+
+	var divergences []string
+	for _, ipAddr := range ep.IPAddresses {
+		if ipAddr.IP.To4() != nil {
+			arpReplyMac := client.getArpReplyAddress(ep.MacAddress)
+			if !ebRuleExists(rules, "--arp-ip-dst "+ipAddr.IP.String()+" ", "-j arpreply", "--arpreply-mac "+arpReplyMac.String()) {
+				divergences = append(divergences, fmt.Sprintf("ebtables ARP reply rule for %v missing", ipAddr.IP.String()))
+			}
+		}
+
+		dst := "--ip-dst "
+		if ipAddr.IP.To4() == nil {
+			dst = "--ip6-dst "
+		}
+		if !ebRuleExists(rules, "-i "+client.hostPrimaryIfName+" ", dst+ipAddr.IP.String()+" ", "--to-dst "+ep.MacAddress.String()) {
+			divergences = append(divergences, fmt.Sprintf("ebtables MAC DNAT rule for %v missing", ipAddr.IP.String()))
+		}
+
+		if client.mode != opModeTunnel && ipAddr.IP.To4() != nil {
+			divergences = append(divergences, checkStaticArp(client.plClient, client.bridgeName, ipAddr.IP, ep.MacAddress)...)
+		}
+	}
+
+	return divergences
+}
+
+func (client *LinuxBridgeEndpointClient) CheckContainerInterfacesAndRoutes(ep *endpoint) []string {
+	if divergences := checkInterface(client.netioshim, client.containerVethName); divergences != nil {
+		return divergences
+	}
+
+	divergences := checkIPAddresses(client.netioshim, client.containerVethName, ep.IPAddresses)
+	return append(divergences, checkRoutes(client.netlink, client.netioshim, client.containerVethName, ep.Routes)...)
+}
+
+// ebRuleExists returns whether one of the listed ebtables rules contains all the given matches.
+func ebRuleExists(rules []string, matches ...string) bool {
+	for _, rule := range rules {
+		// Pad the rule so that matches ending in a separator also match its last field.
+		rule += " "
+		found := true
+		for _, match := range matches {
+			if !strings.Contains(rule, match) {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
 func addRuleToRouteViaHost(epInfo *EndpointInfo) error {
 	for _, ipAddr := range epInfo.IPsToRouteViaHost {
 		tableName := "broute"
@@ -12,6 +12,7 @@ import (
 	"github.com/Azure/azure-container-networking/netlink"
 	"github.com/Azure/azure-container-networking/network/policy"
 	"github.com/Azure/azure-container-networking/platform"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
 )
 
 const (
@@ -43,10 +44,16 @@ type endpoint struct {
 	NetworkContainerID       string
 	NetworkNameSpace         string `json:",omitempty"`
 	ContainerID              string
-	PODName                  string `json:",omitempty"`
-	PODNameSpace             string `json:",omitempty"`
-	InfraVnetAddressSpace    string `json:",omitempty"`
-	NetNs                    string `json:",omitempty"`
+	PODName                  string               `json:",omitempty"`
+	PODNameSpace             string               `json:",omitempty"`
+	InfraVnetAddressSpace    string               `json:",omitempty"`
+	NetNs                    string               `json:",omitempty"`
+	Bandwidth                *BandwidthInfo       `json:",omitempty"`
+	HostPortMappings         []PortMappingInfo    `json:",omitempty"`
+	LinkedEndpoints          []LinkedEndpointInfo `json:",omitempty"`
+	MTU                      int                  `json:",omitempty"`
+	Result                   *cniTypesCurr.Result `json:",omitempty"`
+	ConfigHash               string               `json:",omitempty"`
 }
 
 // EndpointInfo contains read-only information about an endpoint.
@@ -81,6 +88,42 @@ type EndpointInfo struct {
 	VnetCidrs                string
 	ServiceCidrs             string
 	NATInfo                  []policy.NATInfo
+	Bandwidth                *BandwidthInfo
+	HostPortMappings         []PortMappingInfo
+	LinkedEndpoints          []LinkedEndpointInfo
+	// MTU of the container interface. Zero keeps the default MTU of the endpoint client.
+	MTU int
+	// Result is the CNI result of the ADD which created the endpoint, returned again when the ADD is repeated
+	// with the configuration hashed in ConfigHash.
+	Result     *cniTypesCurr.Result
+	ConfigHash string
+}
+
+// LinkedEndpointInfo identifies an endpoint of the same container in another network, such as the endpoint of
+// a secondary interface, which is deleted together with the endpoint linking it.
+type LinkedEndpointInfo struct {
+	NetworkID  string
+	EndpointID string
+	// IfName is the name of the interface of the endpoint in the container.
+	IfName string `json:",omitempty"`
+}
+
+// BandwidthInfo limits the traffic of an endpoint. Rates are in bits per second and bursts in bits.
+// A zero rate leaves that direction unlimited.
+type BandwidthInfo struct {
+	IngressRate  uint64
+	IngressBurst uint64
+	EgressRate   uint64
+	EgressBurst  uint64
+}
+
+// PortMappingInfo maps a port on the host to a port of an endpoint. An empty HostIP maps the port on
+// every address of the host.
+type PortMappingInfo struct {
+	HostPort      int
+	ContainerPort int
+	Protocol      string
+	HostIP        string `json:",omitempty"`
 }
 
 // RouteInfo contains information about an IP route.
@@ -117,6 +160,9 @@ func (nw *network) newEndpoint(cli apipaClient, nl netlink.NetlinkInterface, plc
 		return nil, err
 	}
 
+	ep.LinkedEndpoints = epInfo.LinkedEndpoints
+	ep.Result = epInfo.Result
+	ep.ConfigHash = epInfo.ConfigHash
 	nw.Endpoints[epInfo.Id] = ep
 	log.Printf("[net] Created endpoint %+v.", ep)
 
@@ -227,6 +273,12 @@ func (ep *endpoint) getInfo() *EndpointInfo {
 		PODName:                  ep.PODName,
 		PODNameSpace:             ep.PODNameSpace,
 		NetworkContainerID:       ep.NetworkContainerID,
+		Bandwidth:                ep.Bandwidth,
+		HostPortMappings:         ep.HostPortMappings,
+		LinkedEndpoints:          ep.LinkedEndpoints,
+		MTU:                      ep.MTU,
+		Result:                   ep.Result,
+		ConfigHash:               ep.ConfigHash,
 	}
 
 	info.Routes = append(info.Routes, ep.Routes...)
@@ -65,6 +65,12 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 		return nil, err
 	}
 
+	// ipvlan endpoints have no host veth to shape the traffic on.
+	if epInfo.Bandwidth != nil && nw.Mode == opModeIPVlan {
+		err = errBandwidthNotSupported
+		return nil, err
+	}
+
 	if epInfo.Data != nil {
 		if _, ok := epInfo.Data[VlanIDKey]; ok {
 			vlanid = epInfo.Data[VlanIDKey].(int)
@@ -104,6 +110,11 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 			nl,
 			ovsctl.NewOvsctl(),
 			plc)
+	} else if nw.Mode == opModeIPVlan {
+		log.Printf("IPVlan client")
+		// The host side of an ipvlan endpoint is the ipvlan interface of the network.
+		hostIfName = nw.extIf.BridgeName
+		epClient = NewIPVlanEndpointClient(nw.extIf, contIfName, nw.IPVlanMode, nl, plc)
 	} else if nw.Mode != opModeTransparent {
 		log.Printf("Bridge client")
 		epClient = NewLinuxBridgeEndpointClient(nw.extIf, hostIfName, contIfName, nw.Mode, nl, plc)
@@ -137,6 +148,12 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 			}
 
 			epClient.DeleteEndpoints(endpt)
+
+			if epInfo.Bandwidth != nil {
+				deleteBandwidthShaping(networkutils.NewNetworkUtils(nl, plc), hostIfName, epInfo.Bandwidth)
+			}
+
+			deleteHostPortRules(epInfo.IPAddresses, epInfo.HostPortMappings)
 		}
 	}()
 
@@ -154,6 +171,20 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 		return nil, err
 	}
 
+	// Shape the traffic of the endpoint on its host veth.
+	if epInfo.Bandwidth != nil {
+		if err = addBandwidthShaping(networkutils.NewNetworkUtils(nl, plc), hostIfName, epInfo.Bandwidth); err != nil {
+			return nil, err
+		}
+	}
+
+	// DNAT the host ports of the endpoint to it.
+	if len(epInfo.HostPortMappings) > 0 {
+		if err = addHostPortRules(epInfo.IPAddresses, epInfo.HostPortMappings); err != nil {
+			return nil, err
+		}
+	}
+
 	// If a network namespace for the container interface is specified...
 	if epInfo.NetNsPath != "" {
 		// Open the network namespace.
@@ -224,6 +255,14 @@ func (nw *network) newEndpointImpl(_ apipaClient, nl netlink.NetlinkInterface, p
 		ContainerID:              epInfo.ContainerID,
 		PODName:                  epInfo.PODName,
 		PODNameSpace:             epInfo.PODNameSpace,
+		Bandwidth:                epInfo.Bandwidth,
+		HostPortMappings:         epInfo.HostPortMappings,
+		MTU:                      epInfo.MTU,
+	}
+
+	// An ipvlan endpoint has no veth pair, so the container interface is only known by its name in the container.
+	if nw.Mode == opModeIPVlan && epInfo.IfName != "" {
+		ep.IfName = epInfo.IfName
 	}
 
 	ep.Routes = append(ep.Routes, epInfo.Routes...)
@@ -240,6 +279,8 @@ func (nw *network) deleteEndpointImpl(nl netlink.NetlinkInterface, plc platform.
 	if ep.VlanID != 0 {
 		epInfo := ep.getInfo()
 		epClient = NewOVSEndpointClient(nw, epInfo, ep.HostIfName, "", ep.VlanID, ep.LocalIP, nl, ovsctl.NewOvsctl(), plc)
+	} else if nw.Mode == opModeIPVlan {
+		epClient = NewIPVlanEndpointClient(nw.extIf, ep.IfName, nw.IPVlanMode, nl, plc)
 	} else if nw.Mode != opModeTransparent {
 		epClient = NewLinuxBridgeEndpointClient(nw.extIf, ep.HostIfName, "", nw.Mode, nl, plc)
 	} else {
@@ -249,9 +290,57 @@ func (nw *network) deleteEndpointImpl(nl netlink.NetlinkInterface, plc platform.
 	epClient.DeleteEndpointRules(ep)
 	epClient.DeleteEndpoints(ep)
 
+	if ep.Bandwidth != nil {
+		deleteBandwidthShaping(networkutils.NewNetworkUtils(nl, plc), ep.HostIfName, ep.Bandwidth)
+	}
+
+	deleteHostPortRules(ep.IPAddresses, ep.HostPortMappings)
+
 	return nil
 }
 
+// checkEndpointImpl returns every divergence of the host and container datapath of an endpoint from its state.
+func (nw *network) checkEndpointImpl(nl netlink.NetlinkInterface, plc platform.ExecClient, ep *endpoint, ifName string) ([]string, error) {
+	var epClient EndpointClient
+
+	if ep.VlanID != 0 {
+		epInfo := ep.getInfo()
+		epClient = NewOVSEndpointClient(nw, epInfo, ep.HostIfName, ifName, ep.VlanID, ep.LocalIP, nl, ovsctl.NewOvsctl(), plc)
+	} else if nw.Mode == opModeIPVlan {
+		epClient = NewIPVlanEndpointClient(nw.extIf, ifName, nw.IPVlanMode, nl, plc)
+	} else if nw.Mode != opModeTransparent {
+		epClient = NewLinuxBridgeEndpointClient(nw.extIf, ep.HostIfName, ifName, nw.Mode, nl, plc)
+	} else {
+		epClient = NewTransparentEndpointClient(nw.extIf, ep.HostIfName, ifName, nw.Mode, nl, plc)
+	}
+
+	divergences := epClient.CheckEndpointRules(ep)
+
+	if ep.NetworkNameSpace == "" {
+		return divergences, nil
+	}
+
+	ns, err := OpenNamespace(ep.NetworkNameSpace)
+	if err != nil {
+		return append(divergences, fmt.Sprintf("netns %v can't be opened: %v", ep.NetworkNameSpace, err)), nil
+	}
+	defer ns.Close()
+
+	log.Printf("[net] Entering netns %v.", ep.NetworkNameSpace)
+	if err = ns.Enter(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		log.Printf("[net] Exiting netns %v.", ep.NetworkNameSpace)
+		if err := ns.Exit(); err != nil {
+			log.Printf("[net] Failed to exit netns, err:%v.", err)
+		}
+	}()
+
+	return append(divergences, epClient.CheckContainerInterfacesAndRoutes(ep)...), nil
+}
+
 // getInfoImpl returns information about the endpoint.
 func (ep *endpoint) getInfoImpl(epInfo *EndpointInfo) {
 }
@@ -346,6 +435,109 @@ func deleteRoutes(nl netlink.NetlinkInterface, netioshim netio.NetIOInterface, i
 	return nil
 }
 
+// checkInterface returns a divergence if the interface doesn't exist.
+func checkInterface(netioshim netio.NetIOInterface, interfaceName string) []string {
+	if _, err := netioshim.GetNetworkInterfaceByName(interfaceName); err != nil {
+		return []string{fmt.Sprintf("interface %v not found: %v", interfaceName, err)}
+	}
+
+	return nil
+}
+
+// checkIPAddresses returns a divergence for every address which is not assigned to the interface.
+func checkIPAddresses(netioshim netio.NetIOInterface, interfaceName string, ipAddresses []net.IPNet) []string {
+	interfaceIf, err := netioshim.GetNetworkInterfaceByName(interfaceName)
+	if err != nil {
+		return []string{fmt.Sprintf("interface %v not found: %v", interfaceName, err)}
+	}
+
+	addrs, err := netioshim.GetNetworkInterfaceAddrs(interfaceIf)
+	if err != nil {
+		return []string{fmt.Sprintf("addresses of interface %v can't be listed: %v", interfaceName, err)}
+	}
+
+	var divergences []string
+	for _, ipAddr := range ipAddresses {
+		found := false
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ipAddr.IP) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			divergences = append(divergences, fmt.Sprintf("address %v not assigned to interface %v", ipAddr.String(), interfaceName))
+		}
+	}
+
+	return divergences
+}
+
+// checkRoutes returns a divergence for every route which is missing from the interface,
+// or which has a different gateway.
+func checkRoutes(nl netlink.NetlinkInterface, netioshim netio.NetIOInterface, interfaceName string, routes []RouteInfo) []string {
+	var divergences []string
+
+	for _, route := range routes {
+		devName := interfaceName
+		if route.DevName != "" {
+			devName = route.DevName
+		}
+
+		devIf, err := netioshim.GetNetworkInterfaceByName(devName)
+		if err != nil {
+			divergences = append(divergences, fmt.Sprintf("route %v not checked, interface %v not found", route.Dst.String(), devName))
+			continue
+		}
+
+		family := netlink.GetIPAddressFamily(route.Gw)
+		if route.Gw == nil {
+			family = netlink.GetIPAddressFamily(route.Dst.IP)
+		}
+
+		dst := route.Dst
+		nlRoutes, err := nl.GetIPRoute(&netlink.Route{Family: family, Dst: &dst, LinkIndex: devIf.Index})
+		if err != nil {
+			divergences = append(divergences, fmt.Sprintf("routes of interface %v can't be listed: %v", devName, err))
+			continue
+		}
+
+		found := false
+		for _, nlRoute := range nlRoutes {
+			if route.Gw == nil || route.Gw.Equal(nlRoute.Gw) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			if route.Gw != nil {
+				divergences = append(divergences, fmt.Sprintf("route %v via %v missing on interface %v", route.Dst.String(), route.Gw, devName))
+			} else {
+				divergences = append(divergences, fmt.Sprintf("route %v missing on interface %v", route.Dst.String(), devName))
+			}
+		}
+	}
+
+	return divergences
+}
+
+// checkStaticArp returns a divergence if the interface has no neighbor entry resolving the IP address to the MAC address.
+func checkStaticArp(plc platform.ExecClient, interfaceName string, ipAddr net.IP, macAddress net.HardwareAddr) []string {
+	cmd := fmt.Sprintf("ip neigh show dev %s to %s", interfaceName, ipAddr.String())
+	out, err := plc.ExecuteCommand(cmd)
+	if err != nil {
+		return []string{fmt.Sprintf("neighbors of interface %v can't be listed: %v", interfaceName, err)}
+	}
+
+	if !strings.Contains(out, "lladdr "+macAddress.String()) {
+		return []string{fmt.Sprintf("arp entry %v -> %v missing on interface %v", ipAddr.String(), macAddress.String(), interfaceName)}
+	}
+
+	return nil
+}
+
 // updateEndpointImpl updates an existing endpoint in the network.
 func (nm *networkManager) updateEndpointImpl(nw *network, existingEpInfo *EndpointInfo, targetEpInfo *EndpointInfo) (*endpoint, error) {
 	var ns *Namespace
@@ -515,6 +515,54 @@ func (nw *network) deleteEndpointImplHnsV2(ep *endpoint) error {
 	return nil
 }
 
+// checkEndpointImpl returns every divergence of the HNS endpoint from the endpoint state.
+// The interface inside the container is managed by HNS, so only the HNS endpoint is validated.
+func (nw *network) checkEndpointImpl(_ netlink.NetlinkInterface, _ platform.ExecClient, ep *endpoint, _ string) ([]string, error) {
+	var assigned []net.IP
+
+	if useHnsV2, err := UseHnsV2(ep.NetNs); useHnsV2 {
+		if err != nil {
+			return nil, err
+		}
+
+		hcnEndpoint, err := hnsv2.GetEndpointByID(ep.HnsId)
+		if err != nil {
+			if _, endpointNotFound := err.(hcn.EndpointNotFoundError); !endpointNotFound {
+				return nil, fmt.Errorf("Failed to get hcn endpoint with id: %s due to err: %w", ep.HnsId, err)
+			}
+			return []string{fmt.Sprintf("hcn endpoint %s not found", ep.HnsId)}, nil
+		}
+
+		for _, ipConfig := range hcnEndpoint.IpConfigurations {
+			assigned = append(assigned, net.ParseIP(ipConfig.IpAddress))
+		}
+	} else {
+		hnsEndpoint, err := hcsshim.GetHNSEndpointByID(ep.HnsId)
+		if err != nil {
+			return []string{fmt.Sprintf("hns endpoint %s not found: %v", ep.HnsId, err)}, nil
+		}
+
+		assigned = append(assigned, hnsEndpoint.IPAddress)
+	}
+
+	var divergences []string
+	for _, ipAddr := range ep.IPAddresses {
+		found := false
+		for _, ip := range assigned {
+			if ipAddr.IP.Equal(ip) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			divergences = append(divergences, fmt.Sprintf("address %v not assigned to hns endpoint %s", ipAddr.String(), ep.HnsId))
+		}
+	}
+
+	return divergences, nil
+}
+
 // getInfoImpl returns information about the endpoint.
 func (ep *endpoint) getInfoImpl(epInfo *EndpointInfo) {
 	epInfo.Data["hnsid"] = ep.HnsId
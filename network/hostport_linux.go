@@ -0,0 +1,217 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-container-networking/iptables"
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+// hostPortJumpMatch selects the traffic to local addresses, which is checked against the host ports.
+const hostPortJumpMatch = "-m addrtype --dst-type LOCAL"
+
+// getIPTablesVersion returns the iptables version of an IP address.
+func getIPTablesVersion(ip net.IP) string {
+	if ip.To4() != nil {
+		return iptables.V4
+	}
+
+	return iptables.V6
+}
+
+// getHostIP returns the host IP address of a mapping, or an empty string when the port is mapped on every
+// address of the host. An unspecified address such as 0.0.0.0 maps the port on every address too.
+func getHostIP(mapping PortMappingInfo) string {
+	if ip := net.ParseIP(mapping.HostIP); ip != nil && ip.IsUnspecified() {
+		return ""
+	}
+
+	return mapping.HostIP
+}
+
+// getHostPortDNATRule returns the rule which DNATs the host port of a mapping to the endpoint IP address.
+func getHostPortDNATRule(mapping PortMappingInfo, ip net.IP) (match, target string) {
+	match = fmt.Sprintf("-p %s --dport %d", mapping.Protocol, mapping.HostPort)
+	if hostIP := getHostIP(mapping); hostIP != "" {
+		match = fmt.Sprintf("-d %s %s", hostIP, match)
+	}
+
+	target = fmt.Sprintf("%s --to-destination %s", iptables.Dnat, net.JoinHostPort(ip.String(), strconv.Itoa(mapping.ContainerPort)))
+	return match, target
+}
+
+// getHostPortMasqRule returns the rule which masquerades the traffic of an endpoint to its own host port.
+// Without it the endpoint would reply to itself directly and drop the reply.
+func getHostPortMasqRule(mapping PortMappingInfo, ip net.IP) (match, target string) {
+	match = fmt.Sprintf("-p %s -s %s -d %s --dport %d", mapping.Protocol, ip.String(), ip.String(), mapping.ContainerPort)
+	return match, iptables.Masquerade
+}
+
+// isMappedAddress returns whether a mapping applies to an endpoint IP address. A mapping with a
+// host IP only applies to the endpoint addresses of the same family.
+func isMappedAddress(mapping PortMappingInfo, ip net.IP) bool {
+	if getHostIP(mapping) == "" {
+		return true
+	}
+
+	hostIP := net.ParseIP(mapping.HostIP)
+	return hostIP != nil && getIPTablesVersion(hostIP) == getIPTablesVersion(ip)
+}
+
+// addHostPortChains creates the host port chains and jumps to them from the nat table.
+func addHostPortChains(version string) error {
+	for _, chain := range []string{iptables.CNIHostPortChain, iptables.CNIHostPortMasqChain} {
+		if err := iptables.CreateChain(version, iptables.Nat, chain); err != nil {
+			return fmt.Errorf("failed to create chain %s: %w", chain, err)
+		}
+	}
+
+	// iptables -t nat -I PREROUTING/OUTPUT -m addrtype --dst-type LOCAL -j AZURECNIHOSTPORT
+	for _, chain := range []string{iptables.Prerouting, iptables.Output} {
+		if err := iptables.InsertIptableRule(version, iptables.Nat, chain, hostPortJumpMatch, iptables.CNIHostPortChain); err != nil {
+			return fmt.Errorf("failed to jump from chain %s to %s: %w", chain, iptables.CNIHostPortChain, err)
+		}
+	}
+
+	// iptables -t nat -I POSTROUTING -j AZURECNIHPMASQ
+	if err := iptables.InsertIptableRule(version, iptables.Nat, iptables.Postrouting, "", iptables.CNIHostPortMasqChain); err != nil {
+		return fmt.Errorf("failed to jump from chain %s to %s: %w", iptables.Postrouting, iptables.CNIHostPortMasqChain, err)
+	}
+
+	return nil
+}
+
+// addHostPortRules DNATs the host ports of an endpoint to its IP addresses.
+func addHostPortRules(ipAddresses []net.IPNet, mappings []PortMappingInfo) error {
+	chainsAdded := make(map[string]bool)
+
+	for _, ipAddr := range ipAddresses {
+		version := getIPTablesVersion(ipAddr.IP)
+		for _, mapping := range mappings {
+			if !isMappedAddress(mapping, ipAddr.IP) {
+				continue
+			}
+
+			if !chainsAdded[version] {
+				if err := addHostPortChains(version); err != nil {
+					return err
+				}
+				chainsAdded[version] = true
+			}
+
+			log.Printf("[net] Mapping host port %s/%d to %v:%d.", mapping.Protocol, mapping.HostPort, ipAddr.IP, mapping.ContainerPort)
+			match, target := getHostPortDNATRule(mapping, ipAddr.IP)
+			if err := iptables.AppendIptableRule(version, iptables.Nat, iptables.CNIHostPortChain, match, target); err != nil {
+				return fmt.Errorf("failed to map host port %d: %w", mapping.HostPort, err)
+			}
+
+			match, target = getHostPortMasqRule(mapping, ipAddr.IP)
+			if err := iptables.AppendIptableRule(version, iptables.Nat, iptables.CNIHostPortMasqChain, match, target); err != nil {
+				return fmt.Errorf("failed to masquerade host port %d: %w", mapping.HostPort, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteHostPortRules deletes the host port rules of an endpoint.
+func deleteHostPortRules(ipAddresses []net.IPNet, mappings []PortMappingInfo) {
+	for _, ipAddr := range ipAddresses {
+		version := getIPTablesVersion(ipAddr.IP)
+		for _, mapping := range mappings {
+			if !isMappedAddress(mapping, ipAddr.IP) {
+				continue
+			}
+
+			log.Printf("[net] Unmapping host port %s/%d from %v:%d.", mapping.Protocol, mapping.HostPort, ipAddr.IP, mapping.ContainerPort)
+			match, target := getHostPortDNATRule(mapping, ipAddr.IP)
+			if err := iptables.DeleteIptableRule(version, iptables.Nat, iptables.CNIHostPortChain, match, target); err != nil {
+				log.Printf("[net] Failed to delete DNAT rule of host port %d: %v", mapping.HostPort, err)
+			}
+
+			match, target = getHostPortMasqRule(mapping, ipAddr.IP)
+			if err := iptables.DeleteIptableRule(version, iptables.Nat, iptables.CNIHostPortMasqChain, match, target); err != nil {
+				log.Printf("[net] Failed to delete masquerade rule of host port %d: %v", mapping.HostPort, err)
+			}
+		}
+	}
+}
+
+// hostPortRulesExist returns whether every host port rule of an endpoint is programmed.
+func hostPortRulesExist(ipAddresses []net.IPNet, mappings []PortMappingInfo) bool {
+	for _, ipAddr := range ipAddresses {
+		version := getIPTablesVersion(ipAddr.IP)
+		for _, mapping := range mappings {
+			if !isMappedAddress(mapping, ipAddr.IP) {
+				continue
+			}
+
+			match, target := getHostPortDNATRule(mapping, ipAddr.IP)
+			if !iptables.RuleExists(version, iptables.Nat, iptables.CNIHostPortChain, match, target) {
+				return false
+			}
+
+			match, target = getHostPortMasqRule(mapping, ipAddr.IP)
+			if !iptables.RuleExists(version, iptables.Nat, iptables.CNIHostPortMasqChain, match, target) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// hostPortRulesChecked returns whether the host port rules were already checked since the last reboot.
+func (nm *networkManager) hostPortRulesChecked() bool {
+	if nm.HostPortRulesCheckTime.IsZero() {
+		return false
+	}
+
+	rebootTime, err := platform.GetLastRebootTime()
+	if err != nil {
+		log.Printf("[net] Failed to get last reboot time, err:%v.", err)
+		return false
+	}
+
+	return nm.HostPortRulesCheckTime.After(rebootTime)
+}
+
+// restoreHostPortRules reprograms the host port rules of the endpoints which lost them, such as after a
+// reboot. The rules are checked once per boot, the time of the check is saved in the state.
+func (nm *networkManager) restoreHostPortRules() {
+	var endpoints []*endpoint
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			for _, ep := range nw.Endpoints {
+				if len(ep.HostPortMappings) > 0 {
+					endpoints = append(endpoints, ep)
+				}
+			}
+		}
+	}
+
+	if len(endpoints) == 0 || nm.hostPortRulesChecked() {
+		return
+	}
+
+	for _, ep := range endpoints {
+		if hostPortRulesExist(ep.IPAddresses, ep.HostPortMappings) {
+			continue
+		}
+
+		log.Printf("[net] Restoring host port rules of endpoint %v.", ep.Id)
+		if err := addHostPortRules(ep.IPAddresses, ep.HostPortMappings); err != nil {
+			log.Printf("[net] Failed to restore host port rules of endpoint %v: %v.", ep.Id, err)
+		}
+	}
+
+	nm.HostPortRulesCheckTime = time.Now()
+	if err := nm.save(); err != nil {
+		log.Printf("[net] Failed to save the host port rules check time, err:%v.", err)
+	}
+}
@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostPortRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		mapping     PortMappingInfo
+		ip          string
+		wantDNAT    string
+		wantDNATDst string
+		wantMasq    string
+	}{
+		{
+			name:        "tcp host port",
+			mapping:     PortMappingInfo{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+			ip:          "10.240.0.5",
+			wantDNAT:    "-p tcp --dport 8080",
+			wantDNATDst: "DNAT --to-destination 10.240.0.5:80",
+			wantMasq:    "-p tcp -s 10.240.0.5 -d 10.240.0.5 --dport 80",
+		},
+		{
+			name:        "udp host port on a host IP",
+			mapping:     PortMappingInfo{HostPort: 5353, ContainerPort: 53, Protocol: "udp", HostIP: "10.240.0.4"},
+			ip:          "10.240.0.5",
+			wantDNAT:    "-d 10.240.0.4 -p udp --dport 5353",
+			wantDNATDst: "DNAT --to-destination 10.240.0.5:53",
+			wantMasq:    "-p udp -s 10.240.0.5 -d 10.240.0.5 --dport 53",
+		},
+		{
+			name:        "host port on the unspecified host IP",
+			mapping:     PortMappingInfo{HostPort: 8080, ContainerPort: 80, Protocol: "tcp", HostIP: "0.0.0.0"},
+			ip:          "10.240.0.5",
+			wantDNAT:    "-p tcp --dport 8080",
+			wantDNATDst: "DNAT --to-destination 10.240.0.5:80",
+			wantMasq:    "-p tcp -s 10.240.0.5 -d 10.240.0.5 --dport 80",
+		},
+		{
+			name:        "ipv6 host port",
+			mapping:     PortMappingInfo{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+			ip:          "fc00::5",
+			wantDNAT:    "-p tcp --dport 8080",
+			wantDNATDst: "DNAT --to-destination [fc00::5]:80",
+			wantMasq:    "-p tcp -s fc00::5 -d fc00::5 --dport 80",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			match, target := getHostPortDNATRule(tt.mapping, net.ParseIP(tt.ip))
+			assert.Equal(t, tt.wantDNAT, match)
+			assert.Equal(t, tt.wantDNATDst, target)
+
+			match, target = getHostPortMasqRule(tt.mapping, net.ParseIP(tt.ip))
+			assert.Equal(t, tt.wantMasq, match)
+			assert.Equal(t, "MASQUERADE", target)
+		})
+	}
+}
+
+func TestIsMappedAddress(t *testing.T) {
+	v4 := net.ParseIP("10.240.0.5")
+	v6 := net.ParseIP("fc00::5")
+
+	assert.True(t, isMappedAddress(PortMappingInfo{}, v4))
+	assert.True(t, isMappedAddress(PortMappingInfo{}, v6))
+	assert.True(t, isMappedAddress(PortMappingInfo{HostIP: "10.240.0.4"}, v4))
+	assert.False(t, isMappedAddress(PortMappingInfo{HostIP: "10.240.0.4"}, v6))
+	assert.True(t, isMappedAddress(PortMappingInfo{HostIP: "fc00::4"}, v6))
+	assert.True(t, isMappedAddress(PortMappingInfo{HostIP: "0.0.0.0"}, v6))
+	assert.True(t, isMappedAddress(PortMappingInfo{HostIP: "::"}, v4))
+}
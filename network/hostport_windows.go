@@ -0,0 +1,4 @@
+package network
+
+// restoreHostPortRules is a no-op on Windows, where host ports are HNS endpoint policies.
+func (nm *networkManager) restoreHostPortRules() {}
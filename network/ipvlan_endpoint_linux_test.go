@@ -0,0 +1,246 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+	"github.com/Azure/azure-container-networking/platform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPVlanAddEndpoints(t *testing.T) {
+	nl := netlink.NewMockNetlink(false, "")
+	plc := platform.NewMockExecClient(false)
+
+	tests := []struct {
+		name       string
+		client     *IPVlanEndpointClient
+		epInfo     *EndpointInfo
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "Add endpoints",
+			client: &IPVlanEndpointClient{
+				ipvlanName:        "azipvlan2",
+				hostPrimaryIfName: "eth0",
+				containerIfName:   "azvcontainer",
+				mode:              IPVlanL2,
+				netlink:           netlink.NewMockNetlink(false, ""),
+				plClient:          platform.NewMockExecClient(false),
+				netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+				netioshim:         netio.NewMockNetIO(false, 0),
+			},
+			epInfo:  &EndpointInfo{},
+			wantErr: false,
+		},
+		{
+			name: "Add endpoints netlink fail",
+			client: &IPVlanEndpointClient{
+				ipvlanName:        "azipvlan2",
+				hostPrimaryIfName: "eth0",
+				containerIfName:   "azvcontainer",
+				mode:              IPVlanL2,
+				netlink:           netlink.NewMockNetlink(true, "netlink fail"),
+				plClient:          platform.NewMockExecClient(false),
+				netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+				netioshim:         netio.NewMockNetIO(false, 0),
+			},
+			epInfo:     &EndpointInfo{},
+			wantErr:    true,
+			wantErrMsg: "IPVlanEndpointClient Error : " + netlink.ErrorMockNetlink.Error() + " : netlink fail",
+		},
+		{
+			name: "Add endpoints get interface fail for primary interface",
+			client: &IPVlanEndpointClient{
+				ipvlanName:        "azipvlan2",
+				hostPrimaryIfName: "eth0",
+				containerIfName:   "azvcontainer",
+				mode:              IPVlanL3,
+				netlink:           netlink.NewMockNetlink(false, ""),
+				plClient:          platform.NewMockExecClient(false),
+				netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+				netioshim:         netio.NewMockNetIO(true, 2),
+			},
+			epInfo:     &EndpointInfo{},
+			wantErr:    true,
+			wantErrMsg: "IPVlanEndpointClient Error : " + netio.ErrMockNetIOFail.Error() + ":eth0",
+		},
+		{
+			name: "Add endpoints invalid ipvlan mode",
+			client: &IPVlanEndpointClient{
+				ipvlanName:        "azipvlan2",
+				hostPrimaryIfName: "eth0",
+				containerIfName:   "azvcontainer",
+				mode:              "l4",
+				netlink:           netlink.NewMockNetlink(false, ""),
+				plClient:          platform.NewMockExecClient(false),
+				netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+				netioshim:         netio.NewMockNetIO(true, 1),
+			},
+			epInfo:     &EndpointInfo{},
+			wantErr:    true,
+			wantErrMsg: "invalid ipvlan mode l4",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.client.AddEndpoints(tt.epInfo)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErrMsg, "Expected:%v actual:%v", tt.wantErrMsg, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestIPVlanAddEndpointRules(t *testing.T) {
+	nl := netlink.NewMockNetlink(false, "")
+	plc := platform.NewMockExecClient(false)
+
+	tests := []struct {
+		name       string
+		client     *IPVlanEndpointClient
+		epInfo     *EndpointInfo
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "Add host routes",
+			client: &IPVlanEndpointClient{
+				ipvlanName:        "azipvlan2",
+				hostPrimaryIfName: "eth0",
+				containerIfName:   "azvcontainer",
+				netlink:           netlink.NewMockNetlink(false, ""),
+				plClient:          platform.NewMockExecClient(false),
+				netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+				netioshim:         netio.NewMockNetIO(false, 0),
+			},
+			epInfo: &EndpointInfo{
+				IPAddresses: []net.IPNet{
+					{
+						IP:   net.ParseIP("192.168.0.4"),
+						Mask: net.CIDRMask(subnetv4Mask, ipv4Bits),
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Add host routes netio fail",
+			client: &IPVlanEndpointClient{
+				ipvlanName:        "azipvlan2",
+				hostPrimaryIfName: "eth0",
+				containerIfName:   "azvcontainer",
+				netlink:           netlink.NewMockNetlink(false, ""),
+				plClient:          platform.NewMockExecClient(false),
+				netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+				netioshim:         netio.NewMockNetIO(true, 1),
+			},
+			epInfo: &EndpointInfo{
+				IPAddresses: []net.IPNet{
+					{
+						IP:   net.ParseIP("192.168.0.4"),
+						Mask: net.CIDRMask(subnetv4Mask, ipv4Bits),
+					},
+				},
+			},
+			wantErr:    true,
+			wantErrMsg: "IPVlanEndpointClient Error : addRoutes failed: " + netio.ErrMockNetIOFail.Error() + ":azipvlan2",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.client.AddEndpointRules(tt.epInfo)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErrMsg, "Expected:%v actual:%v", tt.wantErrMsg, err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestIPVlanContainerRoutes(t *testing.T) {
+	ipAddresses := []net.IPNet{
+		{IP: net.ParseIP("192.168.0.4"), Mask: net.CIDRMask(subnetv4Mask, ipv4Bits)},
+		{IP: net.ParseIP("fc00::4"), Mask: net.CIDRMask(subnetv6Mask, ipv6Bits)},
+	}
+	_, defaultIPNet, _ := net.ParseCIDR(defaultGwCidr)
+	routes := []RouteInfo{{Dst: *defaultIPNet, Gw: net.ParseIP("192.168.0.1")}}
+
+	tests := []struct {
+		name       string
+		mode       string
+		wantRoutes int
+		wantGw     bool
+	}{
+		{
+			name:       "L2 mode uses the routes of the endpoint",
+			mode:       IPVlanL2,
+			wantRoutes: 1,
+			wantGw:     true,
+		},
+		{
+			name:       "L3 mode uses device routes",
+			mode:       IPVlanL3,
+			wantRoutes: 2,
+		},
+		{
+			name:       "L3S mode uses device routes",
+			mode:       IPVlanL3S,
+			wantRoutes: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := &IPVlanEndpointClient{mode: tt.mode}
+			containerRoutes := client.getContainerRoutes(ipAddresses, routes)
+			require.Len(t, containerRoutes, tt.wantRoutes)
+			for _, route := range containerRoutes {
+				require.Equal(t, tt.wantGw, route.Gw != nil)
+			}
+		})
+	}
+}
+
+func TestIPVlanConfigureContainerInterfacesAndRoutes(t *testing.T) {
+	nl := netlink.NewMockNetlink(false, "")
+	plc := platform.NewMockExecClient(false)
+
+	client := &IPVlanEndpointClient{
+		ipvlanName:        "azipvlan2",
+		hostPrimaryIfName: "eth0",
+		containerIfName:   "eth0",
+		mode:              IPVlanL3,
+		netlink:           netlink.NewMockNetlink(false, ""),
+		plClient:          platform.NewMockExecClient(false),
+		netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+		netioshim:         netio.NewMockNetIO(false, 0),
+	}
+	epInfo := &EndpointInfo{
+		IPAddresses: []net.IPNet{
+			{
+				IP:   net.ParseIP("192.168.0.4"),
+				Mask: net.CIDRMask(subnetv4Mask, ipv4Bits),
+			},
+		},
+	}
+
+	require.NoError(t, client.ConfigureContainerInterfacesAndRoutes(epInfo))
+}
@@ -0,0 +1,257 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netio"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+var errorIPVlanEndpointClient = errors.New("IPVlanEndpointClient Error")
+
+func newErrorIPVlanEndpointClient(errStr string) error {
+	return fmt.Errorf("%w : %s", errorIPVlanEndpointClient, errStr)
+}
+
+// IPVlanEndpointClient creates the container interface as an ipvlan slave of the external interface.
+// Pods share the MAC address of the external interface and there is no veth pair or bridge.
+type IPVlanEndpointClient struct {
+	ipvlanName        string
+	hostPrimaryIfName string
+	containerIfName   string
+	mode              string
+	netlink           netlink.NetlinkInterface
+	netioshim         netio.NetIOInterface
+	plClient          platform.ExecClient
+	netUtilsClient    networkutils.NetworkUtils
+}
+
+func NewIPVlanEndpointClient(
+	extIf *externalInterface,
+	containerIfName string,
+	mode string,
+	nl netlink.NetlinkInterface,
+	plc platform.ExecClient,
+) *IPVlanEndpointClient {
+	client := &IPVlanEndpointClient{
+		ipvlanName:        extIf.BridgeName,
+		hostPrimaryIfName: extIf.Name,
+		containerIfName:   containerIfName,
+		mode:              mode,
+		netlink:           nl,
+		netioshim:         &netio.NetIO{},
+		plClient:          plc,
+		netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+	}
+
+	return client
+}
+
+// getHostRoutes returns the routes of the endpoint IP addresses on the host ipvlan interface.
+func getHostRoutes(ipAddresses []net.IPNet) []RouteInfo {
+	var routeInfoList []RouteInfo
+
+	for _, ipAddr := range ipAddresses {
+		var ipNet net.IPNet
+		if ipAddr.IP.To4() != nil {
+			ipNet = net.IPNet{IP: ipAddr.IP, Mask: net.CIDRMask(ipv4FullMask, ipv4Bits)}
+		} else {
+			ipNet = net.IPNet{IP: ipAddr.IP, Mask: net.CIDRMask(ipv6FullMask, ipv6Bits)}
+		}
+		routeInfoList = append(routeInfoList, RouteInfo{Dst: ipNet})
+	}
+
+	return routeInfoList
+}
+
+// getContainerRoutes returns the routes of the container interface. In L3 modes the external interface
+// routes all traffic of the slaves, so the container only needs device routes.
+func (client *IPVlanEndpointClient) getContainerRoutes(ipAddresses []net.IPNet, routes []RouteInfo) []RouteInfo {
+	if client.mode != IPVlanL3 && client.mode != IPVlanL3S {
+		return routes
+	}
+
+	var routeInfoList []RouteInfo
+	hasV4, hasV6 := false, false
+	for _, ipAddr := range ipAddresses {
+		if ipAddr.IP.To4() != nil {
+			hasV4 = true
+		} else {
+			hasV6 = true
+		}
+	}
+
+	if hasV4 {
+		_, defaultIPNet, _ := net.ParseCIDR(defaultGwCidr)
+		routeInfoList = append(routeInfoList, RouteInfo{Dst: *defaultIPNet, Scope: netlink.RT_SCOPE_LINK})
+	}
+
+	if hasV6 {
+		_, defaultIPNet, _ := net.ParseCIDR(defaultv6Cidr)
+		routeInfoList = append(routeInfoList, RouteInfo{Dst: *defaultIPNet, Scope: netlink.RT_SCOPE_LINK})
+	}
+
+	return routeInfoList
+}
+
+func (client *IPVlanEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
+	if _, err := client.netioshim.GetNetworkInterfaceByName(client.containerIfName); err == nil {
+		log.Printf("Deleting old ipvlan interface %v", client.containerIfName)
+		if err = client.netlink.DeleteLink(client.containerIfName); err != nil {
+			log.Printf("[net] Failed to delete old ipvlan interface %v: %v.", client.containerIfName, err)
+			return newErrorIPVlanEndpointClient(err.Error())
+		}
+	}
+
+	mode, err := getIPVlanMode(client.mode)
+	if err != nil {
+		return newErrorIPVlanEndpointClient(err.Error())
+	}
+
+	primaryIf, err := client.netioshim.GetNetworkInterfaceByName(client.hostPrimaryIfName)
+	if err != nil {
+		return newErrorIPVlanEndpointClient(err.Error())
+	}
+
+	// The slave is created in the host namespace and moved to the container namespace by MoveEndpointsToContainerNS.
+	link := netlink.IPVlanLink{
+		LinkInfo: netlink.LinkInfo{
+			Type:        netlink.LINK_TYPE_IPVLAN,
+			Name:        client.containerIfName,
+			MTU:         uint(epInfo.MTU),
+			ParentIndex: primaryIf.Index,
+		},
+		Mode: mode,
+	}
+
+	log.Printf("[net] Creating ipvlan interface %v on %v.", client.containerIfName, client.hostPrimaryIfName)
+	if err = client.netlink.AddLink(&link); err != nil {
+		return newErrorIPVlanEndpointClient(err.Error())
+	}
+
+	return nil
+}
+
+func (client *IPVlanEndpointClient) AddEndpointRules(epInfo *EndpointInfo) error {
+	// ip route add <podip> dev <hostipvlan>
+	// This route is needed for the host to reach the pod, since the master can't talk to its own slaves.
+	for _, routeInfo := range getHostRoutes(epInfo.IPAddresses) {
+		log.Printf("[net] Adding route for the ip %v", routeInfo.Dst.String())
+		if err := addRoutes(client.netlink, client.netioshim, client.ipvlanName, []RouteInfo{routeInfo}); err != nil {
+			return newErrorIPVlanEndpointClient(err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (client *IPVlanEndpointClient) DeleteEndpointRules(ep *endpoint) {
+	// ip route del <podip> dev <hostipvlan>
+	for _, routeInfo := range getHostRoutes(ep.IPAddresses) {
+		log.Printf("[net] Deleting route for the ip %v", routeInfo.Dst.String())
+		if err := deleteRoutes(client.netlink, client.netioshim, client.ipvlanName, []RouteInfo{routeInfo}); err != nil {
+			log.Printf("[net] Failed to delete route on VM for the ip %v: %v", routeInfo.Dst.String(), err)
+		}
+	}
+}
+
+func (client *IPVlanEndpointClient) MoveEndpointsToContainerNS(epInfo *EndpointInfo, nsID uintptr) error {
+	// Move the container interface to container's network namespace.
+	log.Printf("[net] Setting link %v netns %v.", client.containerIfName, epInfo.NetNsPath)
+	if err := client.netlink.SetLinkNetNs(client.containerIfName, nsID); err != nil {
+		return newErrorIPVlanEndpointClient(err.Error())
+	}
+
+	return nil
+}
+
+func (client *IPVlanEndpointClient) SetupContainerInterfaces(epInfo *EndpointInfo) error {
+	if err := client.netUtilsClient.SetupContainerInterface(client.containerIfName, epInfo.IfName); err != nil {
+		return err
+	}
+
+	client.containerIfName = epInfo.IfName
+
+	return nil
+}
+
+func (client *IPVlanEndpointClient) ConfigureContainerInterfacesAndRoutes(epInfo *EndpointInfo) error {
+	if err := client.netUtilsClient.AssignIPToInterface(client.containerIfName, epInfo.IPAddresses); err != nil {
+		return newErrorIPVlanEndpointClient(err.Error())
+	}
+
+	routes := client.getContainerRoutes(epInfo.IPAddresses, epInfo.Routes)
+	if err := addRoutes(client.netlink, client.netioshim, client.containerIfName, routes); err != nil {
+		return newErrorIPVlanEndpointClient(err.Error())
+	}
+
+	return nil
+}
+
+// DeleteEndpoints deletes the ipvlan slave of the endpoint. Unlike a veth pair it has no host peer,
+// so it is deleted from the container namespace. The slave is gone with the namespace if it no longer exists.
+func (client *IPVlanEndpointClient) DeleteEndpoints(ep *endpoint) error {
+	if ep.NetworkNameSpace == "" {
+		if _, err := client.netioshim.GetNetworkInterfaceByName(client.containerIfName); err != nil {
+			return nil
+		}
+
+		log.Printf("[net] Deleting ipvlan interface %v.", client.containerIfName)
+		if err := client.netlink.DeleteLink(client.containerIfName); err != nil {
+			return newErrorIPVlanEndpointClient(err.Error())
+		}
+
+		return nil
+	}
+
+	ns, err := OpenNamespace(ep.NetworkNameSpace)
+	if err != nil {
+		log.Printf("[net] Skipping deletion of ipvlan interface %v, netns %v can't be opened: %v.", ep.IfName, ep.NetworkNameSpace, err)
+		return nil
+	}
+	defer ns.Close()
+
+	log.Printf("[net] Entering netns %v.", ep.NetworkNameSpace)
+	if err = ns.Enter(); err != nil {
+		return newErrorIPVlanEndpointClient(err.Error())
+	}
+
+	defer func() {
+		log.Printf("[net] Exiting netns %v.", ep.NetworkNameSpace)
+		if err := ns.Exit(); err != nil {
+			log.Printf("[net] Failed to exit netns, err:%v.", err)
+		}
+	}()
+
+	log.Printf("[net] Deleting ipvlan interface %v.", ep.IfName)
+	if err = client.netlink.DeleteLink(ep.IfName); err != nil {
+		return newErrorIPVlanEndpointClient(err.Error())
+	}
+
+	return nil
+}
+
+func (client *IPVlanEndpointClient) CheckEndpointRules(ep *endpoint) []string {
+	if divergences := checkInterface(client.netioshim, client.ipvlanName); divergences != nil {
+		return divergences
+	}
+
+	// ip route <podip> dev <hostipvlan>
+	return checkRoutes(client.netlink, client.netioshim, client.ipvlanName, getHostRoutes(ep.IPAddresses))
+}
+
+func (client *IPVlanEndpointClient) CheckContainerInterfacesAndRoutes(ep *endpoint) []string {
+	if divergences := checkInterface(client.netioshim, client.containerIfName); divergences != nil {
+		return divergences
+	}
+
+	divergences := checkIPAddresses(client.netioshim, client.containerIfName, ep.IPAddresses)
+	routes := client.getContainerRoutes(ep.IPAddresses, ep.Routes)
+
+	return append(divergences, checkRoutes(client.netlink, client.netioshim, client.containerIfName, routes)...)
+}
@@ -0,0 +1,131 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+	"github.com/Azure/azure-container-networking/network/networkutils"
+	"github.com/Azure/azure-container-networking/platform"
+)
+
+const (
+	// Prefix for host ipvlan interface names.
+	ipvlanPrefix = commonInterfacePrefix + "ipvlan"
+)
+
+var errorIPVlanClient = errors.New("IPVlanClient Error")
+
+func newErrorIPVlanClient(errStr string) error {
+	return fmt.Errorf("%w : %s", errorIPVlanClient, errStr)
+}
+
+// getIPVlanMode returns the netlink ipvlan mode of an ipvlan network.
+func getIPVlanMode(mode string) (netlink.IPVlanMode, error) {
+	switch mode {
+	case IPVlanL2, "":
+		return netlink.IPVLAN_MODE_L2, nil
+	case IPVlanL3:
+		return netlink.IPVLAN_MODE_L3, nil
+	case IPVlanL3S:
+		return netlink.IPVLAN_MODE_L3S, nil
+	default:
+		return netlink.IPVLAN_MODE_MAX, newErrorIPVlanClient(fmt.Sprintf("invalid ipvlan mode %s", mode))
+	}
+}
+
+// IPVlanClient manages the host ipvlan interface of an ipvlan network. The containers' ipvlan
+// interfaces are slaves of the same external interface, so no bridge is needed.
+type IPVlanClient struct {
+	ipvlanName        string
+	hostInterfaceName string
+	mode              string
+	netlink           netlink.NetlinkInterface
+	nuClient          networkutils.NetworkUtils
+}
+
+func NewIPVlanClient(
+	ipvlanName string,
+	hostInterfaceName string,
+	mode string,
+	nl netlink.NetlinkInterface,
+	plc platform.ExecClient,
+) *IPVlanClient {
+	client := &IPVlanClient{
+		ipvlanName:        ipvlanName,
+		hostInterfaceName: hostInterfaceName,
+		mode:              mode,
+		netlink:           nl,
+		nuClient:          networkutils.NewNetworkUtils(nl, plc),
+	}
+
+	return client
+}
+
+// CreateBridge creates the host ipvlan interface, which routes host traffic to the containers.
+func (client *IPVlanClient) CreateBridge() error {
+	log.Printf("[net] Creating ipvlan interface %v on %v.", client.ipvlanName, client.hostInterfaceName)
+
+	mode, err := getIPVlanMode(client.mode)
+	if err != nil {
+		return err
+	}
+
+	hostIf, err := net.InterfaceByName(client.hostInterfaceName)
+	if err != nil {
+		return newErrorIPVlanClient(err.Error())
+	}
+
+	link := netlink.IPVlanLink{
+		LinkInfo: netlink.LinkInfo{
+			Type:        netlink.LINK_TYPE_IPVLAN,
+			Name:        client.ipvlanName,
+			ParentIndex: hostIf.Index,
+		},
+		Mode: mode,
+	}
+
+	if err := client.netlink.AddLink(&link); err != nil {
+		return newErrorIPVlanClient(err.Error())
+	}
+
+	if err := client.nuClient.DisableRAForInterface(client.ipvlanName); err != nil {
+		return newErrorIPVlanClient(err.Error())
+	}
+
+	log.Printf("[net] Setting link %v state up.", client.ipvlanName)
+	if err := client.netlink.SetLinkState(client.ipvlanName, true); err != nil {
+		return newErrorIPVlanClient(err.Error())
+	}
+
+	return nil
+}
+
+func (client *IPVlanClient) DeleteBridge() error {
+	log.Printf("[net] Deleting ipvlan interface %v.", client.ipvlanName)
+	if err := client.netlink.DeleteLink(client.ipvlanName); err != nil {
+		log.Printf("[net] Failed to delete ipvlan interface %v, err:%v.", client.ipvlanName, err)
+		return newErrorIPVlanClient(err.Error())
+	}
+
+	return nil
+}
+
+// AddL2Rules is a no-op, since ipvlan interfaces share the MAC address of the external interface.
+func (client *IPVlanClient) AddL2Rules(extIf *externalInterface) error {
+	return nil
+}
+
+func (client *IPVlanClient) DeleteL2Rules(extIf *externalInterface) {
+}
+
+// SetBridgeMasterToHostInterface is a no-op, since the external interface stays the ipvlan master.
+func (client *IPVlanClient) SetBridgeMasterToHostInterface() error {
+	return nil
+}
+
+func (client *IPVlanClient) SetHairpinOnHostInterface(enable bool) error {
+	return nil
+}
@@ -4,6 +4,8 @@
 package network
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"sync"
 	"time"
@@ -15,6 +17,7 @@ import (
 	"github.com/Azure/azure-container-networking/netlink"
 	"github.com/Azure/azure-container-networking/platform"
 	"github.com/Azure/azure-container-networking/store"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
 )
 
 const (
@@ -33,6 +36,11 @@ var Ipv4DefaultRouteDstPrefix = net.IPNet{
 	Mask: net.IPv4Mask(0, 0, 0, 0),
 }
 
+var Ipv6DefaultRouteDstPrefix = net.IPNet{
+	IP:   net.IPv6zero,
+	Mask: net.CIDRMask(0, 128), //nolint:gomnd // ipv6 bits
+}
+
 type NetworkClient interface {
 	CreateBridge() error
 	DeleteBridge() error
@@ -50,6 +58,10 @@ type EndpointClient interface {
 	SetupContainerInterfaces(epInfo *EndpointInfo) error
 	ConfigureContainerInterfacesAndRoutes(epInfo *EndpointInfo) error
 	DeleteEndpoints(ep *endpoint) error
+	// CheckEndpointRules and CheckContainerInterfacesAndRoutes return a description of every host
+	// and container network namespace setting of the endpoint that is missing or differs from its state.
+	CheckEndpointRules(ep *endpoint) []string
+	CheckContainerInterfacesAndRoutes(ep *endpoint) []string
 }
 
 // NetworkManager manages the set of container networking resources.
@@ -57,10 +69,15 @@ type networkManager struct {
 	Version            string
 	TimeStamp          time.Time
 	ExternalInterfaces map[string]*externalInterface
+
+	// HostPortRulesCheckTime is the last time the host port rules of the endpoints were checked.
+	HostPortRulesCheckTime time.Time `json:",omitempty"`
+
 	store              store.KeyValueStore
 	netlink            netlink.NetlinkInterface
 	netio              netio.NetIOInterface
 	plClient           platform.ExecClient
+	lockStorePerChange bool
 	sync.Mutex
 }
 
@@ -80,11 +97,15 @@ type NetworkManager interface {
 	CreateEndpoint(client apipaClient, networkID string, epInfo *EndpointInfo) error
 	DeleteEndpoint(networkID string, endpointID string) error
 	GetEndpointInfo(networkID string, endpointID string) (*EndpointInfo, error)
+	// CheckEndpoint returns an EndpointDivergedError if the datapath of the endpoint doesn't match its state.
+	// ifName is the name of the interface in the container network namespace.
+	CheckEndpoint(networkID string, endpointID string, ifName string) error
 	GetAllEndpoints(networkID string) (map[string]*EndpointInfo, error)
 	GetEndpointInfoBasedOnPODDetails(networkID string, podName string, podNameSpace string, doExactMatchForPodName bool) (*EndpointInfo, error)
 	AttachEndpoint(networkID string, endpointID string, sandboxKey string) (*endpoint, error)
 	DetachEndpoint(networkID string, endpointID string) error
 	UpdateEndpoint(networkID string, existingEpInfo *EndpointInfo, targetEpInfo *EndpointInfo) error
+	SetEndpointResult(networkID, endpointID string, result *cniTypesCurr.Result, configHash string) error
 	GetNumberOfEndpoints(ifName string, networkID string) int
 	SetupNetworkUsingState(networkMonitor *cnms.NetworkMonitor) error
 }
@@ -105,6 +126,12 @@ func NewNetworkManager(nl netlink.NetlinkInterface, plc platform.ExecClient, net
 func (nm *networkManager) Initialize(config *common.PluginConfig, isRehydrationRequired bool) error {
 	nm.Version = config.Version
 	nm.store = config.Store
+	nm.lockStorePerChange = config.LockStorePerChange
+
+	if err := nm.lockStore(); err != nil {
+		return err
+	}
+	defer nm.unlockStore()
 
 	// Restore persisted state.
 	err := nm.restore(isRehydrationRequired)
@@ -205,6 +232,8 @@ func (nm *networkManager) restore(isRehydrationRequired bool) error {
 		}
 	}
 
+	nm.restoreHostPortRules()
+
 	log.Printf("[net] Restored state, %+v\n", nm)
 	for _, extIf := range nm.ExternalInterfaces {
 		log.Printf("External Interface %+v", extIf)
@@ -235,6 +264,79 @@ func (nm *networkManager) save() error {
 	return err
 }
 
+// lockStore locks the store for a change of the state when the store is locked per change. Otherwise the owner of
+// the store holds its lock and lockStore does nothing.
+func (nm *networkManager) lockStore() error {
+	if !nm.lockStorePerChange || nm.store == nil {
+		return nil
+	}
+
+	if err := nm.store.Lock(store.DefaultLockTimeout); err != nil {
+		log.Printf("[net] Failed to lock store, err:%v\n", err)
+		return fmt.Errorf("failed to lock store: %w", err)
+	}
+
+	return nil
+}
+
+// unlockStore unlocks the store locked by lockStore.
+func (nm *networkManager) unlockStore() {
+	if !nm.lockStorePerChange || nm.store == nil {
+		return
+	}
+
+	if err := nm.store.Unlock(); err != nil {
+		log.Printf("[net] Failed to unlock store, err:%v\n", err)
+	}
+}
+
+// reload reads the state saved by other processes since the state was restored. The store must be locked.
+func (nm *networkManager) reload() error {
+	if !nm.lockStorePerChange || nm.store == nil {
+		return nil
+	}
+
+	version := nm.Version
+	externalInterfaces := nm.ExternalInterfaces
+	nm.ExternalInterfaces = make(map[string]*externalInterface)
+
+	err := nm.store.Read(storeKey, nm)
+	nm.Version = version
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) && !errors.Is(err, store.ErrStoreEmpty) {
+		log.Printf("[net] Failed to reload state, err:%v\n", err)
+		nm.ExternalInterfaces = externalInterfaces
+		return err
+	}
+
+	// Populate pointers.
+	for _, extIf := range nm.ExternalInterfaces {
+		for _, nw := range extIf.Networks {
+			nw.extIf = extIf
+		}
+	}
+
+	return nil
+}
+
+// update applies a change to the state and saves it. When the store is locked per change, the change is applied to
+// the state reloaded under the store lock, so that it keeps the changes saved by other processes.
+func (nm *networkManager) update(change func() error) error {
+	if err := nm.lockStore(); err != nil {
+		return err
+	}
+	defer nm.unlockStore()
+
+	if err := nm.reload(); err != nil {
+		return err
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	return nm.save()
+}
+
 //
 // NetworkManager API
 //
@@ -246,17 +348,9 @@ func (nm *networkManager) AddExternalInterface(ifName string, subnet string) err
 	nm.Lock()
 	defer nm.Unlock()
 
-	err := nm.newExternalInterface(ifName, subnet)
-	if err != nil {
-		return err
-	}
-
-	err = nm.save()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return nm.update(func() error {
+		return nm.newExternalInterface(ifName, subnet)
+	})
 }
 
 // CreateNetwork creates a new container network.
@@ -264,17 +358,16 @@ func (nm *networkManager) CreateNetwork(nwInfo *NetworkInfo) error {
 	nm.Lock()
 	defer nm.Unlock()
 
-	_, err := nm.newNetwork(nwInfo)
-	if err != nil {
-		return err
-	}
+	return nm.update(func() error {
+		// Another process may have created the network since the state was restored.
+		if _, err := nm.getNetwork(nwInfo.Id); err == nil && nm.lockStorePerChange {
+			log.Printf("[net] Network %v was created by another process.", nwInfo.Id)
+			return nil
+		}
 
-	err = nm.save()
-	if err != nil {
+		_, err := nm.newNetwork(nwInfo)
 		return err
-	}
-
-	return nil
+	})
 }
 
 // DeleteNetwork deletes an existing container network.
@@ -282,17 +375,9 @@ func (nm *networkManager) DeleteNetwork(networkId string) error {
 	nm.Lock()
 	defer nm.Unlock()
 
-	err := nm.deleteNetwork(networkId)
-	if err != nil {
-		return err
-	}
-
-	err = nm.save()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return nm.update(func() error {
+		return nm.deleteNetwork(networkId)
+	})
 }
 
 // GetNetworkInfo returns information about the given network.
@@ -340,13 +425,27 @@ func (nm *networkManager) CreateEndpoint(cli apipaClient, networkID string, epIn
 		}
 	}
 
-	_, err = nw.newEndpoint(cli, nm.netlink, nm.plClient, epInfo)
+	// The datapath of the endpoint is programmed without the store lock, only its state is changed under it.
+	ep, err := nw.newEndpoint(cli, nm.netlink, nm.plClient, epInfo)
 	if err != nil {
 		return err
 	}
 
-	err = nm.save()
+	err = nm.update(func() error {
+		// The network is looked up again as the state may have been reloaded.
+		nw, err := nm.getNetwork(networkID)
+		if err != nil {
+			return err
+		}
+
+		nw.Endpoints[ep.Id] = ep
+		return nil
+	})
 	if err != nil {
+		log.Printf("[net] Failed to save endpoint %v, deleting it, err:%v.", ep.Id, err)
+		if delErr := nw.deleteEndpoint(nm.netlink, nm.plClient, ep.Id); delErr != nil {
+			log.Printf("[net] Failed to delete endpoint %v, err:%v.", ep.Id, delErr)
+		}
 		return err
 	}
 
@@ -363,16 +462,46 @@ func (nm *networkManager) DeleteEndpoint(networkID, endpointID string) error {
 		return err
 	}
 
+	// The datapath of the endpoint is removed without the store lock, only its state is changed under it.
 	err = nw.deleteEndpoint(nm.netlink, nm.plClient, endpointID)
 	if err != nil {
 		return err
 	}
 
-	err = nm.save()
+	return nm.update(func() error {
+		// The network is looked up again as the state may have been reloaded.
+		if nw, err := nm.getNetwork(networkID); err == nil {
+			delete(nw.Endpoints, endpointID)
+		}
+		return nil
+	})
+}
+
+// CheckEndpoint verifies that the datapath of the given endpoint matches its state.
+func (nm *networkManager) CheckEndpoint(networkID, endpointID, ifName string) error {
+	nm.Lock()
+	defer nm.Unlock()
+
+	nw, err := nm.getNetwork(networkID)
+	if err != nil {
+		return err
+	}
+
+	ep, err := nw.getEndpoint(endpointID)
 	if err != nil {
 		return err
 	}
 
+	var divergences []string
+	if divergences, err = nw.checkEndpointImpl(nm.netlink, nm.plClient, ep, ifName); err != nil {
+		return err
+	}
+
+	if len(divergences) > 0 {
+		log.Printf("[net] Endpoint %v diverged from state: %v", endpointID, divergences)
+		return &EndpointDivergedError{Divergences: divergences}
+	}
+
 	return nil
 }
 
@@ -498,22 +627,37 @@ func (nm *networkManager) UpdateEndpoint(networkID string, existingEpInfo *Endpo
 	nm.Lock()
 	defer nm.Unlock()
 
-	nw, err := nm.getNetwork(networkID)
-	if err != nil {
-		return err
-	}
+	return nm.update(func() error {
+		nw, err := nm.getNetwork(networkID)
+		if err != nil {
+			return err
+		}
 
-	err = nm.updateEndpoint(nw, existingEpInfo, targetEpInfo)
-	if err != nil {
-		return err
-	}
+		return nm.updateEndpoint(nw, existingEpInfo, targetEpInfo)
+	})
+}
 
-	err = nm.save()
-	if err != nil {
-		return err
-	}
+// SetEndpointResult records the CNI result of an existing endpoint and the hash of the configuration it was
+// created with, which are returned again when the ADD is repeated.
+func (nm *networkManager) SetEndpointResult(networkID, endpointID string, result *cniTypesCurr.Result, configHash string) error {
+	nm.Lock()
+	defer nm.Unlock()
 
-	return nil
+	return nm.update(func() error {
+		nw, err := nm.getNetwork(networkID)
+		if err != nil {
+			return err
+		}
+
+		ep, err := nw.getEndpoint(endpointID)
+		if err != nil {
+			return err
+		}
+
+		ep.Result = result
+		ep.ConfigHash = configHash
+		return nil
+	})
 }
 
 func (nm *networkManager) GetNumberOfEndpoints(ifName string, networkId string) int {
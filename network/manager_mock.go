@@ -1,14 +1,18 @@
 package network
 
 import (
+	"sync"
+
 	cnms "github.com/Azure/azure-container-networking/cnms/cnmspackage"
 	"github.com/Azure/azure-container-networking/common"
+	cniTypesCurr "github.com/containernetworking/cni/pkg/types/current"
 )
 
 // MockNetworkManager is a mock structure for Network Manager
 type MockNetworkManager struct {
 	TestNetworkInfoMap  map[string]*NetworkInfo
 	TestEndpointInfoMap map[string]*EndpointInfo
+	mu                  sync.Mutex
 }
 
 // NewMockNetworkmanager returns a new mock
@@ -34,6 +38,8 @@ func (nm *MockNetworkManager) AddExternalInterface(ifName string, subnet string)
 
 // CreateNetwork mock
 func (nm *MockNetworkManager) CreateNetwork(nwInfo *NetworkInfo) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
 	nm.TestNetworkInfoMap[nwInfo.Id] = nwInfo
 	return nil
 }
@@ -45,6 +51,8 @@ func (nm *MockNetworkManager) DeleteNetwork(networkID string) error {
 
 // GetNetworkInfo mock
 func (nm *MockNetworkManager) GetNetworkInfo(networkID string) (NetworkInfo, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
 	if info, exists := nm.TestNetworkInfoMap[networkID]; exists {
 		return *info, nil
 	}
@@ -53,28 +61,51 @@ func (nm *MockNetworkManager) GetNetworkInfo(networkID string) (NetworkInfo, err
 
 // CreateEndpoint mock
 func (nm *MockNetworkManager) CreateEndpoint(_ apipaClient, networkID string, epInfo *EndpointInfo) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
 	nm.TestEndpointInfoMap[epInfo.Id] = epInfo
 	return nil
 }
 
 // DeleteEndpoint mock
 func (nm *MockNetworkManager) DeleteEndpoint(networkID, endpointID string) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
 	delete(nm.TestEndpointInfoMap, endpointID)
 	return nil
 }
 
 func (nm *MockNetworkManager) GetAllEndpoints(networkID string) (map[string]*EndpointInfo, error) {
-	return nm.TestEndpointInfoMap, nil
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	eps := make(map[string]*EndpointInfo, len(nm.TestEndpointInfoMap))
+	for id, epInfo := range nm.TestEndpointInfoMap {
+		eps[id] = epInfo
+	}
+	return eps, nil
 }
 
 // GetEndpointInfo mock
 func (nm *MockNetworkManager) GetEndpointInfo(networkID string, endpointID string) (*EndpointInfo, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
 	if info, exists := nm.TestEndpointInfoMap[endpointID]; exists {
 		return info, nil
 	}
 	return nil, errEndpointNotFound
 }
 
+// CheckEndpoint mock
+func (nm *MockNetworkManager) CheckEndpoint(networkID, endpointID, ifName string) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	if _, exists := nm.TestEndpointInfoMap[endpointID]; !exists {
+		return errEndpointNotFound
+	}
+	return nil
+}
+
 // GetEndpointInfoBasedOnPODDetails mock
 func (nm *MockNetworkManager) GetEndpointInfoBasedOnPODDetails(networkID string, podName string, podNameSpace string, doExactMatchForPodName bool) (*EndpointInfo, error) {
 	return &EndpointInfo{}, nil
@@ -95,6 +126,19 @@ func (nm *MockNetworkManager) UpdateEndpoint(networkID string, existingEpInfo *E
 	return nil
 }
 
+// SetEndpointResult mock
+func (nm *MockNetworkManager) SetEndpointResult(networkID, endpointID string, result *cniTypesCurr.Result, configHash string) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	info, exists := nm.TestEndpointInfoMap[endpointID]
+	if !exists {
+		return errEndpointNotFound
+	}
+	info.Result = result
+	info.ConfigHash = configHash
+	return nil
+}
+
 // GetNumberOfEndpoints mock
 func (nm *MockNetworkManager) GetNumberOfEndpoints(ifName string, networkID string) int {
 	return 0
@@ -106,6 +150,8 @@ func (nm *MockNetworkManager) SetupNetworkUsingState(networkMonitor *cnms.Networ
 }
 
 func (nm *MockNetworkManager) FindNetworkIDFromNetNs(netNs string) (string, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
 	// based on the GetAllEndpoints func above, it seems that this mock is only intended to be used with
 	// one network, so just return the network here if it exists
 	for network := range nm.TestNetworkInfoMap {
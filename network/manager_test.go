@@ -2,12 +2,16 @@ package network
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/processlock"
 	"github.com/Azure/azure-container-networking/store"
 	"github.com/Azure/azure-container-networking/testutils"
 )
@@ -121,6 +125,57 @@ var _ = Describe("Test Manager", func() {
 		})
 	})
 
+	Describe("Test update", func() {
+		Context("When the store is locked per change", func() {
+			It("Should keep the changes saved by other processes", func() {
+				dir, err := os.MkdirTemp("", "manager")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.RemoveAll(dir)
+				fileName := filepath.Join(dir, "azure-vnet.json")
+				newManager := func() *networkManager {
+					st, err := store.NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+					Expect(err).NotTo(HaveOccurred())
+					nm := &networkManager{ExternalInterfaces: map[string]*externalInterface{}}
+					err = nm.Initialize(&common.PluginConfig{Store: st, LockStorePerChange: true}, false)
+					Expect(err).NotTo(HaveOccurred())
+					return nm
+				}
+				addNetwork := func(nm *networkManager, extIfName, nwID string) error {
+					return nm.update(func() error {
+						nm.ExternalInterfaces[extIfName] = &externalInterface{
+							Name:     extIfName,
+							Networks: map[string]*network{nwID: {Id: nwID, Endpoints: map[string]*endpoint{}}},
+						}
+						return nil
+					})
+				}
+
+				nm1 := newManager()
+				nm2 := newManager()
+				Expect(addNetwork(nm1, "eth0", "nw1")).To(Succeed())
+				Expect(addNetwork(nm2, "eth1", "nw2")).To(Succeed())
+
+				nm3 := newManager()
+				Expect(nm3.ExternalInterfaces).To(HaveLen(2))
+				Expect(nm3.ExternalInterfaces["eth0"].Networks["nw1"].extIf.Name).To(Equal("eth0"))
+				Expect(nm3.ExternalInterfaces["eth1"].Networks["nw2"].extIf.Name).To(Equal("eth1"))
+			})
+		})
+
+		Context("When the change fails", func() {
+			It("Should not save the state", func() {
+				nm := &networkManager{
+					store: &testutils.KeyValueStoreMock{
+						WriteError: errors.New("error for test"),
+					},
+				}
+				err := nm.update(func() error { return errNetworkNotFound })
+				Expect(err).To(MatchError(errNetworkNotFound))
+				Expect(nm.TimeStamp).To(Equal(time.Time{}))
+			})
+		})
+	})
+
 	Describe("Test GetNumberOfEndpoints", func() {
 		Context("When ExternalInterfaces is nil", func() {
 			It("Should return 0", func() {
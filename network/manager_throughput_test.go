@@ -0,0 +1,150 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/Azure/azure-container-networking/store"
+)
+
+const (
+	// ipamLatency is the latency of the call to the IPAM, such as a request to CNS, made before the endpoint is created.
+	ipamLatency = 10 * time.Millisecond
+	// datapathLatency is the latency of programming the interfaces, routes and rules of an endpoint.
+	datapathLatency = 20 * time.Millisecond
+	// podsPerNode is the number of endpoints kept in the state, like the state of a full node.
+	podsPerNode = 250
+
+	throughputExtIfName = "eth0"
+	throughputNetworkID = "azure"
+)
+
+// throughputEndpoint returns an endpoint with the fields which are saved for a pod.
+func throughputEndpoint(n int64) *endpoint {
+	id := fmt.Sprintf("container-%d-eth0", n)
+	return &endpoint{
+		Id:           id,
+		IfName:       "eth0",
+		HostIfName:   fmt.Sprintf("azv%d", n),
+		MacAddress:   net.HardwareAddr{0x12, 0x34, 0x56, 0x78, 0x9a, byte(n)},
+		IPAddresses:  []net.IPNet{{IP: net.IPv4(10, 240, byte(n>>8), byte(n)), Mask: net.CIDRMask(16, 32)}},
+		Gateways:     []net.IP{net.IPv4(10, 240, 0, 1)},
+		Routes:       []RouteInfo{{Dst: net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)}, Gw: net.IPv4(10, 240, 0, 1)}},
+		ContainerID:  fmt.Sprintf("container-%d", n),
+		PODName:      fmt.Sprintf("pod-%d", n),
+		PODNameSpace: "default",
+	}
+}
+
+// BenchmarkCreateEndpointThroughput compares the throughput of concurrent pod ADDs on a json store which is locked
+// throughout each ADD, as the plugin locks it by default, with ADDs on a store which the network manager only locks
+// around each change of the state. Each ADD opens the store and initializes a network manager like a CNI process,
+// waits for the IPAM and the datapath, then saves its endpoint through the update of the network manager like
+// CreateEndpoint does. The datapath is simulated as creating interfaces needs the privileges of the plugin.
+func BenchmarkCreateEndpointThroughput(b *testing.B) {
+	benchmarks := []struct {
+		name               string
+		lockStorePerChange bool
+	}{
+		{
+			name: "Store lock per ADD",
+		},
+		{
+			name:               "Store lock per change",
+			lockStorePerChange: true,
+		},
+	}
+
+	for _, bm := range benchmarks {
+		bm := bm
+		b.Run(bm.name, func(b *testing.B) {
+			dir := b.TempDir()
+			storePath := filepath.Join(dir, "azure-vnet.json")
+			storeLockPath := filepath.Join(dir, "azure-vnet.lock")
+
+			openStore := func() (store.KeyValueStore, error) {
+				lockclient, err := processlock.NewFileLock(storeLockPath)
+				if err != nil {
+					return nil, err //nolint:wrapcheck // test error
+				}
+				return store.NewJsonFileStore(storePath, lockclient) //nolint:wrapcheck // test error
+			}
+
+			// The state starts with the endpoints of a full node.
+			st, err := openStore()
+			if err != nil {
+				b.Fatal(err)
+			}
+			nw := &network{Id: throughputNetworkID, Endpoints: make(map[string]*endpoint)}
+			for n := int64(1); n <= podsPerNode; n++ {
+				ep := throughputEndpoint(-n)
+				nw.Endpoints[ep.Id] = ep
+			}
+			seed := &networkManager{
+				store: st,
+				ExternalInterfaces: map[string]*externalInterface{
+					throughputExtIfName: {Name: throughputExtIfName, Networks: map[string]*network{throughputNetworkID: nw}},
+				},
+			}
+			if err = seed.save(); err != nil {
+				b.Fatal(err)
+			}
+
+			var containers int64
+			add := func(n int64) error {
+				st, err := openStore()
+				if err != nil {
+					return err
+				}
+
+				// The plugin holds the store lock throughout the ADD unless the network manager locks it per change.
+				if !bm.lockStorePerChange {
+					if err = st.Lock(store.DefaultLockTimeout); err != nil {
+						return err //nolint:wrapcheck // test error
+					}
+					defer st.Unlock() //nolint:errcheck // test cleanup
+				}
+
+				nm := &networkManager{ExternalInterfaces: make(map[string]*externalInterface)}
+				if err = nm.Initialize(&common.PluginConfig{Store: st, LockStorePerChange: bm.lockStorePerChange}, false); err != nil {
+					return err
+				}
+
+				time.Sleep(ipamLatency)
+				time.Sleep(datapathLatency)
+
+				// The endpoint of the oldest pod is dropped, so that the state keeps the size of a full node.
+				ep := throughputEndpoint(n)
+				return nm.update(func() error {
+					nw, err := nm.getNetwork(throughputNetworkID)
+					if err != nil {
+						return err
+					}
+
+					nw.Endpoints[ep.Id] = ep
+					delete(nw.Endpoints, throughputEndpoint(n-podsPerNode).Id)
+					return nil
+				})
+			}
+
+			b.SetParallelism(16)
+			b.ResetTimer()
+			start := time.Now()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := add(atomic.AddInt64(&containers, 1)); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "pods/s")
+		})
+	}
+}
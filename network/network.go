@@ -17,6 +17,7 @@ const (
 	opModeBridge      = "bridge"
 	opModeTunnel      = "tunnel"
 	opModeTransparent = "transparent"
+	opModeIPVlan      = "ipvlan"
 	opModeDefault     = opModeTunnel
 )
 
@@ -25,6 +26,13 @@ const (
 	IPV6Nat = "ipv6nat"
 )
 
+const (
+	// ipvlan modes
+	IPVlanL2  = "l2"
+	IPVlanL3  = "l3"
+	IPVlanL3S = "l3s"
+)
+
 // externalInterface is a host network interface that bridges containers to external networks.
 type externalInterface struct {
 	Name        string
@@ -52,6 +60,7 @@ type network struct {
 	EnableSnatOnHost bool
 	NetNs            string
 	SnatBridgeIP     string
+	IPVlanMode       string `json:",omitempty"`
 }
 
 // NetworkInfo contains read-only information about a container network.
@@ -70,6 +79,7 @@ type NetworkInfo struct {
 	Options                       map[string]interface{}
 	DisableHairpinOnHostInterface bool
 	IPV6Mode                      string
+	IPVlanMode                    string
 	IPAMType                      string
 	ServiceCidrs                  string
 }
@@ -88,6 +88,15 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 				return nil, fmt.Errorf("Ipv6 forwarding failed: %w", err)
 			}
 		}
+	case opModeIPVlan:
+		log.Printf("IPVlan mode")
+		ifName = extIf.Name
+		if nwInfo.IPVlanMode == "" {
+			nwInfo.IPVlanMode = IPVlanL2
+		}
+		if err := nm.connectIPVlanInterface(extIf, nwInfo); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, errNetworkModeInvalid
 	}
@@ -107,6 +116,7 @@ func (nm *networkManager) newNetworkImpl(nwInfo *NetworkInfo, extIf *externalInt
 		VlanId:           vlanid,
 		DNS:              nwInfo.DNS,
 		EnableSnatOnHost: nwInfo.EnableSnatOnHost,
+		IPVlanMode:       nwInfo.IPVlanMode,
 	}
 
 	return nw, nil
@@ -137,6 +147,8 @@ func (nm *networkManager) deleteNetworkImpl(nw *network) error {
 
 	if nw.VlanId != 0 {
 		networkClient = NewOVSClient(nw.extIf.BridgeName, nw.extIf.Name, ovsctl.NewOvsctl(), nm.netlink, nm.plClient)
+	} else if nw.Mode == opModeIPVlan {
+		networkClient = NewIPVlanClient(nw.extIf.BridgeName, nw.extIf.Name, nw.IPVlanMode, nm.netlink, nm.plClient)
 	} else {
 		networkClient = NewLinuxBridgeClient(nw.extIf.BridgeName, nw.extIf.Name, NetworkInfo{}, nm.netlink, nm.plClient)
 	}
@@ -534,6 +546,41 @@ func (nm *networkManager) connectExternalInterface(extIf *externalInterface, nwI
 	return nil
 }
 
+// connectIPVlanInterface creates the host ipvlan interface of the external interface, through which
+// the host reaches the ipvlan interfaces of the containers. Unlike a bridge, it leaves the IP
+// configuration of the external interface in place.
+func (nm *networkManager) connectIPVlanInterface(extIf *externalInterface, nwInfo *NetworkInfo) error {
+	if extIf.BridgeName != "" {
+		log.Printf("[net] Interface is already connected to ipvlan interface %v.", extIf.BridgeName)
+		return nil
+	}
+
+	hostIf, err := net.InterfaceByName(extIf.Name)
+	if err != nil {
+		return err
+	}
+
+	ipvlanName := nwInfo.BridgeName
+	if ipvlanName == "" {
+		ipvlanName = fmt.Sprintf("%s%d", ipvlanPrefix, hostIf.Index)
+	}
+
+	networkClient := NewIPVlanClient(ipvlanName, extIf.Name, nwInfo.IPVlanMode, nm.netlink, nm.plClient)
+	if _, err = net.InterfaceByName(ipvlanName); err != nil {
+		if err = networkClient.CreateBridge(); err != nil {
+			log.Printf("Error while creating ipvlan interface %+v", err)
+			return err
+		}
+	} else {
+		log.Printf("[net] Found existing ipvlan interface %v.", ipvlanName)
+	}
+
+	extIf.BridgeName = ipvlanName
+	log.Printf("[net] Connected interface %v to ipvlan interface %v.", extIf.Name, ipvlanName)
+
+	return nil
+}
+
 // DisconnectExternalInterface disconnects a host interface from its bridge.
 func (nm *networkManager) disconnectExternalInterface(extIf *externalInterface, networkClient NetworkClient) {
 	log.Printf("[net] Disconnecting interface %v.", extIf.Name)
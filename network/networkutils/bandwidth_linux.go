@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package networkutils
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/netlink"
+)
+
+const (
+	addTBFCmd            = "tc qdisc add dev %s root tbf rate %dbit burst %d latency %dms"
+	addIngressQdiscCmd   = "tc qdisc add dev %s handle ffff: ingress"
+	addRedirectFilterCmd = "tc filter add dev %s parent ffff: protocol all u32 match u32 0 0 action mirred egress redirect dev %s"
+	// tbfLatencyMs is the longest time a packet may wait in the token bucket filter before it is dropped.
+	tbfLatencyMs = 25
+	bitsPerByte  = 8
+)
+
+// AddTokenBucketFilter limits the traffic sent out of an interface to rate bits per second, with bursts of up to burst bits.
+func (nu NetworkUtils) AddTokenBucketFilter(ifName string, rate, burst uint64) error {
+	// tc qdisc add dev <ifname> root tbf rate <rate>bit burst <burst bytes> latency 25ms
+	cmd := fmt.Sprintf(addTBFCmd, ifName, rate, burst/bitsPerByte, tbfLatencyMs)
+	if out, err := nu.plClient.ExecuteCommand(cmd); err != nil {
+		log.Printf("[net] Adding token bucket filter on %v failed with: %v out: %v", ifName, err, out)
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	return nil
+}
+
+// CreateIFBRedirect creates an ifb interface and redirects the traffic received on an interface to it.
+// Traffic can only be shaped when it is sent, so the received traffic is shaped on the ifb interface.
+func (nu NetworkUtils) CreateIFBRedirect(ifName, ifbName string) error {
+	log.Printf("[net] Creating ifb interface %v for %v.", ifbName, ifName)
+
+	link := netlink.IFBLink{
+		LinkInfo: netlink.LinkInfo{
+			Type: netlink.LINK_TYPE_IFB,
+			Name: ifbName,
+		},
+	}
+
+	if err := nu.netlink.AddLink(&link); err != nil {
+		log.Printf("[net] Failed to create ifb interface, err:%v.", err)
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	log.Printf("[net] Setting link %v state up.", ifbName)
+	if err := nu.netlink.SetLinkState(ifbName, true); err != nil {
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	// tc qdisc add dev <ifname> handle ffff: ingress
+	cmd := fmt.Sprintf(addIngressQdiscCmd, ifName)
+	if out, err := nu.plClient.ExecuteCommand(cmd); err != nil {
+		log.Printf("[net] Adding ingress qdisc on %v failed with: %v out: %v", ifName, err, out)
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	// tc filter add dev <ifname> parent ffff: ... action mirred egress redirect dev <ifbname>
+	cmd = fmt.Sprintf(addRedirectFilterCmd, ifName, ifbName)
+	if out, err := nu.plClient.ExecuteCommand(cmd); err != nil {
+		log.Printf("[net] Adding redirect from %v to %v failed with: %v out: %v", ifName, ifbName, err, out)
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	return nil
+}
+
+// DeleteIFB deletes an ifb interface created by CreateIFBRedirect.
+func (nu NetworkUtils) DeleteIFB(ifbName string) error {
+	log.Printf("[net] Deleting ifb interface %v.", ifbName)
+	if err := nu.netlink.DeleteLink(ifbName); err != nil {
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	return nil
+}
@@ -34,6 +34,9 @@ const (
 	toggleIPV6Cmd        = "sysctl -w net.ipv6.conf.all.disable_ipv6=%d"
 	enableIPV6ForwardCmd = "sysctl -w net.ipv6.conf.all.forwarding=1"
 	disableRACmd         = "sysctl -w net.ipv6.conf.%s.accept_ra=0"
+	enableProxyNDPCmd    = "sysctl -w net.ipv6.conf.%s.proxy_ndp=1"
+	addNeighProxyCmd     = "ip -6 neigh add proxy %s dev %s"
+	deleteNeighProxyCmd  = "ip -6 neigh del proxy %s dev %s"
 	acceptRAV6File       = "/proc/sys/net/ipv6/conf/%s/accept_ra"
 )
 
@@ -85,6 +88,20 @@ func (nu NetworkUtils) CreateEndpoint(hostVethName, containerVethName string) er
 	return nil
 }
 
+// SetVethMTU sets the MTU of both ends of a veth pair.
+func (nu NetworkUtils) SetVethMTU(hostVethName, containerVethName string, mtu int) error {
+	log.Printf("[net] Setting mtu %d on veth pair %v %v.", mtu, hostVethName, containerVethName)
+	if err := nu.netlink.SetLinkMTU(hostVethName, mtu); err != nil {
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	if err := nu.netlink.SetLinkMTU(containerVethName, mtu); err != nil {
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	return nil
+}
+
 func (nu NetworkUtils) SetupContainerInterface(containerVethName, targetIfName string) error {
 	// Interface needs to be down before renaming.
 	log.Printf("[net] Setting link %v state down.", containerVethName)
@@ -226,6 +243,43 @@ func (nu NetworkUtils) EnableIPV6Forwarding() error {
 	return nil
 }
 
+// EnableProxyNDP enables answering neighbor solicitations for the proxy neighbor entries of an interface,
+// the IPv6 equivalent of proxy arp.
+func (nu NetworkUtils) EnableProxyNDP(ifName string) error {
+	// sysctl -w net.ipv6.conf.<ifname>.proxy_ndp=1
+	cmd := fmt.Sprintf(enableProxyNDPCmd, ifName)
+	if out, err := nu.plClient.ExecuteCommand(cmd); err != nil {
+		log.Printf("[net] Enabling proxy ndp on %v failed with: %v out: %v", ifName, err, out)
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	return nil
+}
+
+// AddNeighborProxy answers neighbor solicitations for an IPv6 address on an interface.
+func (nu NetworkUtils) AddNeighborProxy(ip net.IP, ifName string) error {
+	// ip -6 neigh add proxy <ip> dev <ifname>
+	cmd := fmt.Sprintf(addNeighProxyCmd, ip.String(), ifName)
+	if out, err := nu.plClient.ExecuteCommand(cmd); err != nil {
+		log.Printf("[net] Adding neighbor proxy for %v on %v failed with: %v out: %v", ip, ifName, err, out)
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	return nil
+}
+
+// DeleteNeighborProxy deletes a neighbor proxy entry added by AddNeighborProxy.
+func (nu NetworkUtils) DeleteNeighborProxy(ip net.IP, ifName string) error {
+	// ip -6 neigh del proxy <ip> dev <ifname>
+	cmd := fmt.Sprintf(deleteNeighProxyCmd, ip.String(), ifName)
+	if out, err := nu.plClient.ExecuteCommand(cmd); err != nil {
+		log.Printf("[net] Deleting neighbor proxy for %v on %v failed with: %v out: %v", ip, ifName, err, out)
+		return newErrorNetworkUtils(err.Error())
+	}
+
+	return nil
+}
+
 // This functions enables/disables ipv6 setting based on enable parameter passed.
 func (nu NetworkUtils) UpdateIPV6Setting(disable int) error {
 	// sysctl -w net.ipv6.conf.all.disable_ipv6=0/1
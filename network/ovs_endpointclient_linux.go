@@ -83,6 +83,12 @@ func (client *OVSEndpointClient) AddEndpoints(epInfo *EndpointInfo) error {
 		return err
 	}
 
+	if epInfo.MTU > 0 {
+		if err := epc.SetVethMTU(client.hostVethName, client.containerVethName, epInfo.MTU); err != nil {
+			return err
+		}
+	}
+
 	containerIf, err := net.InterfaceByName(client.containerVethName)
 	if err != nil {
 		log.Printf("InterfaceByName returns error for ifname %v with error %v", client.containerVethName, err)
@@ -244,3 +250,17 @@ func (client *OVSEndpointClient) DeleteEndpoints(ep *endpoint) error {
 	DeleteSnatEndpoint(client)
 	return DeleteInfraVnetEndpoint(client, ep.Id[:7])
 }
+
+func (client *OVSEndpointClient) CheckEndpointRules(ep *endpoint) []string {
+	// The OVS flows of the endpoint are not validated.
+	return checkInterface(client.netioshim, client.hostVethName)
+}
+
+func (client *OVSEndpointClient) CheckContainerInterfacesAndRoutes(ep *endpoint) []string {
+	if divergences := checkInterface(client.netioshim, client.containerVethName); divergences != nil {
+		return divergences
+	}
+
+	divergences := checkIPAddresses(client.netioshim, client.containerVethName, ep.IPAddresses)
+	return append(divergences, checkRoutes(client.netlink, client.netioshim, client.containerVethName, ep.Routes)...)
+}
@@ -44,6 +44,20 @@ func TestTransAddEndpoints(t *testing.T) {
 			epInfo:  &EndpointInfo{},
 			wantErr: false,
 		},
+		{
+			name: "Add endpoints with mtu",
+			client: &TransparentEndpointClient{
+				hostPrimaryIfName: "eth0",
+				hostVethName:      "azvhost",
+				containerVethName: "azvcontainer",
+				netlink:           netlink.NewMockNetlink(false, ""),
+				plClient:          platform.NewMockExecClient(false),
+				netUtilsClient:    networkutils.NewNetworkUtils(nl, plc),
+				netioshim:         netio.NewMockNetIO(false, 0),
+			},
+			epInfo:  &EndpointInfo{MTU: 9000},
+			wantErr: false,
+		},
 		{
 			name: "Add endpoints netlink fail",
 			client: &TransparentEndpointClient{
@@ -192,6 +206,32 @@ func TestTransAddEndpointsRules(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Add endpoint rules Dualstack neighbor proxy fail",
+			client: &TransparentEndpointClient{
+				hostPrimaryIfName: "eth0",
+				hostVethName:      "azvhost",
+				containerVethName: "azvcontainer",
+				netlink:           netlink.NewMockNetlink(false, ""),
+				plClient:          platform.NewMockExecClient(false),
+				netUtilsClient:    networkutils.NewNetworkUtils(nl, platform.NewMockExecClient(true)),
+				netioshim:         netio.NewMockNetIO(false, 0),
+			},
+			epInfo: &EndpointInfo{
+				IPAddresses: []net.IPNet{
+					{
+						IP:   net.ParseIP("192.168.0.4"),
+						Mask: net.CIDRMask(subnetv4Mask, ipv4FullMask),
+					},
+					{
+						IP:   net.ParseIP("fc00::4"),
+						Mask: net.CIDRMask(subnetv6Mask, ipv6FullMask),
+					},
+				},
+			},
+			wantErr:    true,
+			wantErrMsg: "TransparentEndpointClient Error",
+		},
 		{
 			name: "Add endpoint rules fail",
 			client: &TransparentEndpointClient{
@@ -389,3 +429,96 @@ func TestTransConfigureContainerInterfacesAndRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestTransCheckEndpoint(t *testing.T) {
+	nl := netlink.NewMockNetlink(false, "")
+	plc := platform.NewMockExecClient(false)
+	ep := &endpoint{
+		IPAddresses: []net.IPNet{
+			{
+				IP:   net.ParseIP("192.168.0.4"),
+				Mask: net.CIDRMask(subnetv4Mask, ipv4Bits),
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		client          *TransparentEndpointClient
+		check           func(*TransparentEndpointClient, *endpoint) []string
+		wantDivergences []string
+	}{
+		{
+			name: "Check endpoint rules host veth missing",
+			client: &TransparentEndpointClient{
+				hostVethName:      "azvhost",
+				containerVethName: "eth0",
+				netlink:           nl,
+				plClient:          plc,
+				netioshim:         netio.NewMockNetIO(true, 1),
+			},
+			check: (*TransparentEndpointClient).CheckEndpointRules,
+			wantDivergences: []string{
+				"host veth azvhost not found",
+			},
+		},
+		{
+			name: "Check endpoint rules route and proxy arp missing",
+			client: &TransparentEndpointClient{
+				hostVethName:      "azvhost",
+				containerVethName: "eth0",
+				netlink:           nl,
+				plClient:          plc,
+				netioshim:         netio.NewMockNetIO(false, 0),
+			},
+			check: (*TransparentEndpointClient).CheckEndpointRules,
+			wantDivergences: []string{
+				"route 192.168.0.4/32 missing on interface azvhost",
+				"proxy arp not enabled on host veth azvhost",
+			},
+		},
+		{
+			name: "Check container interface missing",
+			client: &TransparentEndpointClient{
+				hostVethName:      "azvhost",
+				containerVethName: "eth0",
+				netlink:           nl,
+				plClient:          plc,
+				netioshim:         netio.NewMockNetIO(true, 1),
+			},
+			check: (*TransparentEndpointClient).CheckContainerInterfacesAndRoutes,
+			wantDivergences: []string{
+				"interface eth0 not found",
+			},
+		},
+		{
+			name: "Check container address, routes and arp missing",
+			client: &TransparentEndpointClient{
+				hostVethName:      "azvhost",
+				containerVethName: "eth0",
+				hostVethMac:       net.HardwareAddr{0xab, 0xcd, 0xef, 0x12, 0x34, 0x56},
+				netlink:           nl,
+				plClient:          plc,
+				netioshim:         netio.NewMockNetIO(false, 0),
+			},
+			check: (*TransparentEndpointClient).CheckContainerInterfacesAndRoutes,
+			wantDivergences: []string{
+				"address 192.168.0.4/24 not assigned to interface eth0",
+				"route 169.254.1.1/32 missing on interface eth0",
+				"route 0.0.0.0/0 via 169.254.1.1 missing on interface eth0",
+				"arp entry 169.254.1.1 -> ab:cd:ef:12:34:56 missing on interface eth0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			divergences := tt.check(tt.client, ep)
+			require.Len(t, divergences, len(tt.wantDivergences), "divergences: %v", divergences)
+			for i := range tt.wantDivergences {
+				require.Contains(t, divergences[i], tt.wantDivergences[i])
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/Azure/azure-container-networking/log"
 	"github.com/Azure/azure-container-networking/netio"
@@ -70,6 +71,18 @@ func NewTransparentEndpointClient(
 	return client
 }
 
+// hasIPv6Address returns whether an endpoint has an IPv6 address, such as a dual-stack endpoint
+// assigned an IPv6 address by CNS alongside its IPv4 address.
+func hasIPv6Address(ipAddresses []net.IPNet) bool {
+	for _, ipAddr := range ipAddresses {
+		if ipAddr.IP.To4() == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (client *TransparentEndpointClient) setArpProxy(ifName string) error {
 	cmd := fmt.Sprintf("echo 1 > /proc/sys/net/ipv4/conf/%v/proxy_arp", ifName)
 	_, err := client.plClient.ExecuteCommand(cmd)
@@ -116,6 +129,15 @@ func (client *TransparentEndpointClient) AddEndpoints(epInfo *EndpointInfo) erro
 
 	client.hostVethMac = hostVethIf.HardwareAddr
 
+	// An MTU requested for the endpoint must be set, while the MTU of the primary interface is best effort.
+	if epInfo.MTU > 0 {
+		if err = client.netUtilsClient.SetVethMTU(client.hostVethName, client.containerVethName, epInfo.MTU); err != nil {
+			return newErrorTransparentEndpointClient(err.Error())
+		}
+
+		return nil
+	}
+
 	log.Printf("Setting mtu %d on veth interface %s", primaryIf.MTU, client.hostVethName)
 	if err := client.netlink.SetLinkMTU(client.hostVethName, primaryIf.MTU); err != nil {
 		log.Errorf("Setting mtu failed for hostveth %s:%v", client.hostVethName, err)
@@ -158,6 +180,35 @@ func (client *TransparentEndpointClient) AddEndpointRules(epInfo *EndpointInfo)
 		return err
 	}
 
+	if hasIPv6Address(epInfo.IPAddresses) {
+		return client.addIPV6NeighProxy(epInfo.IPAddresses)
+	}
+
+	return nil
+}
+
+// addIPV6NeighProxy forwards IPv6 on the host and answers the neighbor solicitations for the IPv6
+// addresses of the endpoint on the primary interface, as proxy arp does for IPv4.
+func (client *TransparentEndpointClient) addIPV6NeighProxy(ipAddresses []net.IPNet) error {
+	if err := client.netUtilsClient.EnableIPV6Forwarding(); err != nil {
+		return newErrorTransparentEndpointClient(err.Error())
+	}
+
+	if err := client.netUtilsClient.EnableProxyNDP(client.hostPrimaryIfName); err != nil {
+		return newErrorTransparentEndpointClient(err.Error())
+	}
+
+	for _, ipAddr := range ipAddresses {
+		if ipAddr.IP.To4() != nil {
+			continue
+		}
+
+		log.Printf("[net] Adding neighbor proxy for the ip %v on %v", ipAddr.IP, client.hostPrimaryIfName)
+		if err := client.netUtilsClient.AddNeighborProxy(ipAddr.IP, client.hostPrimaryIfName); err != nil {
+			return newErrorTransparentEndpointClient(err.Error())
+		}
+	}
+
 	return nil
 }
 
@@ -181,6 +232,12 @@ func (client *TransparentEndpointClient) DeleteEndpointRules(ep *endpoint) {
 		if err := deleteRoutes(client.netlink, client.netioshim, client.hostVethName, []RouteInfo{routeInfo}); err != nil {
 			log.Printf("[net] Failed to delete route on VM for the ip %v: %v", ipNet.String(), err)
 		}
+
+		if ipAddr.IP.To4() == nil {
+			if err := client.netUtilsClient.DeleteNeighborProxy(ipAddr.IP, client.hostPrimaryIfName); err != nil {
+				log.Printf("[net] Failed to delete neighbor proxy for the ip %v: %v", ipAddr.IP, err)
+			}
+		}
 	}
 }
 
@@ -255,13 +312,12 @@ func (client *TransparentEndpointClient) ConfigureContainerInterfacesAndRoutes(e
 		return fmt.Errorf("Adding arp in container failed: %w", err)
 	}
 
-	if epInfo.IPV6Mode != "" {
+	// a dual-stack endpoint from CNS has an IPv6 address without an IPv6 mode.
+	if epInfo.IPV6Mode != "" || hasIPv6Address(epInfo.IPAddresses) {
 		if err := client.setupIPV6Routes(); err != nil {
 			return err
 		}
-	}
 
-	if epInfo.IPV6Mode != "" {
 		return client.setIPV6NeighEntry()
 	}
 
@@ -305,3 +361,63 @@ func (client *TransparentEndpointClient) setIPV6NeighEntry() error {
 func (client *TransparentEndpointClient) DeleteEndpoints(ep *endpoint) error {
 	return nil
 }
+
+func (client *TransparentEndpointClient) CheckEndpointRules(ep *endpoint) []string {
+	hostVethIf, err := client.netioshim.GetNetworkInterfaceByName(client.hostVethName)
+	if err != nil {
+		return []string{fmt.Sprintf("host veth %v not found: %v", client.hostVethName, err)}
+	}
+
+	// The static arp entry in the container resolves the virtual gateway to the host veth.
+	client.hostVethMac = hostVethIf.HardwareAddr
+
+	// ip route <podip> dev <hostveth>
+	var routeInfoList []RouteInfo
+	for _, ipAddr := range ep.IPAddresses {
+		var ipNet net.IPNet
+		if ipAddr.IP.To4() != nil {
+			ipNet = net.IPNet{IP: ipAddr.IP, Mask: net.CIDRMask(ipv4FullMask, ipv4Bits)}
+		} else {
+			ipNet = net.IPNet{IP: ipAddr.IP, Mask: net.CIDRMask(ipv6FullMask, ipv6Bits)}
+		}
+		routeInfoList = append(routeInfoList, RouteInfo{Dst: ipNet})
+	}
+	divergences := checkRoutes(client.netlink, client.netioshim, client.hostVethName, routeInfoList)
+
+	cmd := fmt.Sprintf("cat /proc/sys/net/ipv4/conf/%v/proxy_arp", client.hostVethName)
+	if out, err := client.plClient.ExecuteCommand(cmd); err != nil || strings.TrimSpace(out) != "1" {
+		divergences = append(divergences, fmt.Sprintf("proxy arp not enabled on host veth %v", client.hostVethName))
+	}
+
+	return divergences
+}
+
+func (client *TransparentEndpointClient) CheckContainerInterfacesAndRoutes(ep *endpoint) []string {
+	if divergences := checkInterface(client.netioshim, client.containerVethName); divergences != nil {
+		return divergences
+	}
+
+	divergences := checkIPAddresses(client.netioshim, client.containerVethName, ep.IPAddresses)
+
+	// ip route 169.254.1.1/32 dev eth0 and ip route default via 169.254.1.1 dev eth0
+	virtualGwIP, virtualGwNet, _ := net.ParseCIDR(virtualGwIPString)
+	_, defaultIPNet, _ := net.ParseCIDR(defaultGwCidr)
+	routes := []RouteInfo{
+		{Dst: *virtualGwNet},
+		{Dst: *defaultIPNet, Gw: virtualGwIP},
+	}
+	if hasIPv6Address(ep.IPAddresses) {
+		// ip -6 route fe80::1234:5678:9abc/128 dev eth0 and ip -6 route default via fe80::1234:5678:9abc dev eth0
+		virtualv6GwIP, virtualv6GwNet, _ := net.ParseCIDR(virtualv6GwString)
+		_, defaultv6IPNet, _ := net.ParseCIDR(defaultv6Cidr)
+		routes = append(routes, RouteInfo{Dst: *virtualv6GwNet}, RouteInfo{Dst: *defaultv6IPNet, Gw: virtualv6GwIP})
+	}
+	divergences = append(divergences, checkRoutes(client.netlink, client.netioshim, client.containerVethName, routes)...)
+
+	// arp 169.254.1.1 -> hostveth mac
+	if client.hostVethMac != nil {
+		divergences = append(divergences, checkStaticArp(client.plClient, client.containerVethName, virtualGwIP, client.hostVethMac)...)
+	}
+
+	return divergences
+}
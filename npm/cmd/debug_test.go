@@ -11,6 +11,7 @@ import (
 const (
 	iptableSaveFile = "../pkg/dataplane/testdata/iptablesave"
 	npmCacheFile    = "../pkg/dataplane/testdata/npmcache.json"
+	simulatorFile   = "../pkg/dataplane/testdata/simulator.yaml"
 	nonExistingFile = "non-existing-iptables-file"
 
 	npmCacheFlag         = "-c"
@@ -18,6 +19,8 @@ const (
 	dstFlag              = "-d"
 	srcFlag              = "-s"
 	unknownShorthandFlag = "-z"
+	fileFlag             = "-f"
+	portFlag             = "-p"
 
 	testIP1 = "10.240.0.17" // from npmCacheWithCustomFormat.json
 	testIP2 = "10.240.0.68" // ditto
@@ -26,6 +29,7 @@ const (
 	convertIPTableCmdString = "convertiptable"
 	getTuplesCmdString      = "gettuples"
 	parseIPTableCmdString   = "parseiptable"
+	simulateCmdString       = "simulate"
 )
 
 type testCases struct {
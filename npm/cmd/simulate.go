@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	dataplane "github.com/Azure/azure-container-networking/npm/pkg/dataplane/debug"
+	"github.com/Azure/azure-container-networking/npm/util/errors"
+	"github.com/spf13/cobra"
+)
+
+var errSpecifyObjectFiles = fmt.Errorf("must specify at least one file with Pods, Namespaces, and NetworkPolicies")
+
+func newSimulateCmd() *cobra.Command {
+	simulateCmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Simulate whether NetworkPolicies allow a flow between the specified source and destination, without a cluster",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, _ := cmd.Flags().GetString("src")
+			if src == "" {
+				return fmt.Errorf("%w", errors.ErrSrcNotSpecified)
+			}
+			dst, _ := cmd.Flags().GetString("dst")
+			if dst == "" {
+				return fmt.Errorf("%w", errors.ErrDstNotSpecified)
+			}
+			files, _ := cmd.Flags().GetStringSlice("file")
+			if len(files) == 0 {
+				return errSpecifyObjectFiles
+			}
+			protocol, _ := cmd.Flags().GetString("protocol")
+			port, _ := cmd.Flags().GetInt32("port")
+
+			simulator, err := dataplane.NewSimulatorFromFiles(files...)
+			if err != nil {
+				return fmt.Errorf("%w", err)
+			}
+			result, err := simulator.Simulate(&dataplane.Flow{Src: src, Dst: dst, Protocol: protocol, Port: port})
+			if err != nil {
+				return fmt.Errorf("%w", err)
+			}
+			resultJSON, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal simulation result: %w", err)
+			}
+			fmt.Printf("%s\n", resultJSON)
+			return nil
+		},
+	}
+
+	simulateCmd.Flags().StringP("src", "s", "", "set the source as <namespace>/<pod name> or an IP")
+	simulateCmd.Flags().StringP("dst", "d", "", "set the destination as <namespace>/<pod name> or an IP")
+	simulateCmd.Flags().StringSliceP("file", "f", nil, "Set the YAML or JSON files with Pods, Namespaces, and NetworkPolicies (can be repeated)")
+	simulateCmd.Flags().String("protocol", "TCP", "set the protocol: TCP, UDP, or SCTP")
+	simulateCmd.Flags().Int32P("port", "p", 0, "set the destination port (optional, but a flow without a port only matches rules without ports)")
+
+	return simulateCmd
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestSimulateCmd(t *testing.T) {
+	baseArgs := []string{debugCmdString, simulateCmdString}
+	srcPod := "frontend/web"
+	dstPod := "backend/api"
+
+	tests := []*testCases{
+		{
+			name:    "no src or dst",
+			args:    concatArgs(baseArgs, fileFlag, simulatorFile),
+			wantErr: true,
+		},
+		{
+			name:    "no file",
+			args:    concatArgs(baseArgs, srcFlag, srcPod, dstFlag, dstPod),
+			wantErr: true,
+		},
+		{
+			name:    "bad file",
+			args:    concatArgs(baseArgs, srcFlag, srcPod, dstFlag, dstPod, fileFlag, nonExistingFile),
+			wantErr: true,
+		},
+		{
+			name:    "unknown pod",
+			args:    concatArgs(baseArgs, srcFlag, "frontend/unknown", dstFlag, dstPod, fileFlag, simulatorFile),
+			wantErr: true,
+		},
+		{
+			name:    "pods",
+			args:    concatArgs(baseArgs, srcFlag, srcPod, dstFlag, dstPod, portFlag, "8080", fileFlag, simulatorFile),
+			wantErr: false,
+		},
+		{
+			name:    "external ip and protocol",
+			args:    concatArgs(baseArgs, srcFlag, "1.1.1.1", dstFlag, dstPod, "--protocol", "UDP", fileFlag, simulatorFile),
+			wantErr: false,
+		},
+		{
+			name:    "repeated files",
+			args:    concatArgs(baseArgs, srcFlag, srcPod, dstFlag, dstPod, fileFlag, simulatorFile, fileFlag, simulatorFile),
+			wantErr: false,
+		},
+	}
+
+	testCommand(t, tests)
+}
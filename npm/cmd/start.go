@@ -3,6 +3,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"time"
@@ -13,7 +14,9 @@ import (
 	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	restserver "github.com/Azure/azure-container-networking/npm/http/server"
 	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/fqdn"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/denylog"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
 	"github.com/Azure/azure-container-networking/npm/pkg/models"
@@ -22,6 +25,7 @@ import (
 	"github.com/spf13/viper"
 	"k8s.io/apimachinery/pkg/util/wait"
 	k8sversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -115,29 +119,56 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 	k8sServerVersion := k8sServerVersion(clientset)
 
 	var dp dataplane.GenericDataplane
+	var deniedFlowsEncoder json.Marshaler
 	stopChannel := wait.NeverStop
 	if config.Toggles.EnableV2NPM {
 		// update the dataplane config
 		npmV2DataplaneCfg.PlaceAzureChainFirst = config.Toggles.PlaceAzureChainFirst
+		npmV2DataplaneCfg.PolicyManagerCfg.UseNFTables = config.Toggles.EnableNFTables
+		npmV2DataplaneCfg.IPSetManagerCfg.UseNFTables = config.Toggles.EnableNFTables
+		npmV2DataplaneCfg.PolicyManagerCfg.EnableDenyLogging = config.Toggles.EnableDenyLogging
+		npmV2DataplaneCfg.PolicyManagerCfg.EnableAdminNetworkPolicies = config.Toggles.EnableAdminNetworkPolicies
 		if config.Toggles.ApplyIPSetsOnNeed {
 			npmV2DataplaneCfg.IPSetMode = ipsets.ApplyOnNeed
 		} else {
 			npmV2DataplaneCfg.IPSetMode = ipsets.ApplyAllIPSets
 		}
 
-		dp, err = dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, stopChannel)
+		var v2Dataplane *dataplane.DataPlane
+		v2Dataplane, err = dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, stopChannel)
 		if err != nil {
 			return fmt.Errorf("failed to create dataplane with error %w", err)
 		}
-		dp.RunPeriodicTasks()
+		v2Dataplane.RunPeriodicTasks()
+		dp = v2Dataplane
+		if config.Toggles.EnableDenyLogging {
+			deniedFlowsEncoder = startDenyLogging(v2Dataplane, stopChannel)
+		}
 	}
 	npMgr := npm.NewNetworkPolicyManager(config, factory, dp, exec.New(), version, k8sServerVersion)
+	enableFQDNNetworkPolicies := config.Toggles.EnableFQDNNetworkPolicies
+	if enableFQDNNetworkPolicies && util.IsWindowsDP() {
+		klog.Warning("FQDNNetworkPolicies are not supported on Windows, so they are not enforced")
+		enableFQDNNetworkPolicies = false
+	}
+	if config.Toggles.EnableV2NPM && (config.Toggles.EnableAdminNetworkPolicies || enableFQDNNetworkPolicies) {
+		dynamicClient, dynamicErr := dynamic.NewForConfig(k8sConfig)
+		if dynamicErr != nil {
+			return fmt.Errorf("failed to generate dynamic client with cluster config: %w", dynamicErr)
+		}
+		if config.Toggles.EnableAdminNetworkPolicies {
+			npMgr.AddAdminNetworkPolicyController(dynamicClient, resyncPeriod, dp)
+		}
+		if enableFQDNNetworkPolicies {
+			npMgr.AddFQDNNetworkPolicyController(dynamicClient, resyncPeriod, dp, fqdnResolverConfig(config))
+		}
+	}
 	err = metrics.CreateTelemetryHandle(config.NPMVersion(), version, npm.GetAIMetadata())
 	if err != nil {
 		klog.Infof("CreateTelemetryHandle failed with error %v. AITelemetry is not initialized.", err)
 	}
 
-	go restserver.NPMRestServerListenAndServe(config, npMgr)
+	go restserver.NPMRestServerListenAndServe(config, npMgr, deniedFlowsEncoder)
 
 	metrics.SendLog(util.NpmID, "starting NPM", metrics.PrintLog)
 	if err = npMgr.Start(config, stopChannel); err != nil {
@@ -148,6 +179,17 @@ func start(config npmconfig.Config, flags npmconfig.Flags) error {
 	select {}
 }
 
+// startDenyLogging records the packets logged by the dataplane when it drops them, and returns the recorder for the HTTP API.
+func startDenyLogging(dp *dataplane.DataPlane, stopChannel <-chan struct{}) *denylog.Recorder {
+	recorder := denylog.NewRecorder(dp)
+	go func() {
+		if err := denylog.NewListener(recorder).Run(stopChannel); err != nil {
+			metrics.SendErrorLogAndMetric(util.NpmID, "error: failed to listen for dropped packets: %v", err)
+		}
+	}()
+	return recorder
+}
+
 func initLogging() error {
 	log.SetName("azure-npm")
 	log.SetLevel(log.LevelInfo)
@@ -181,3 +223,11 @@ func k8sServerVersion(kubeclientset kubernetes.Interface) *k8sversion.Info {
 	}
 	return serverVersion
 }
+
+// fqdnResolverConfig converts the FQDN config of NPM into the config of the FQDN resolver
+func fqdnResolverConfig(config npmconfig.Config) fqdn.Config {
+	return fqdn.Config{
+		ResolveInterval: time.Duration(config.FQDN.ResolveIntervalInSeconds) * time.Second,
+		MemberTTL:       time.Duration(config.FQDN.MemberTTLInSeconds) * time.Second,
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
@@ -65,16 +66,23 @@ func startDaemon(config npmconfig.Config) error {
 	}
 
 	var dp dataplane.GenericDataplane
+	var deniedFlowsEncoder json.Marshaler
 
-	dp, err = dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, wait.NeverStop)
+	npmV2DataplaneCfg.PolicyManagerCfg.EnableDenyLogging = config.Toggles.EnableDenyLogging
+	npmV2DataplaneCfg.PolicyManagerCfg.EnableAdminNetworkPolicies = config.Toggles.EnableAdminNetworkPolicies
+	v2Dataplane, err := dataplane.NewDataPlane(models.GetNodeName(), common.NewIOShim(), npmV2DataplaneCfg, wait.NeverStop)
 	if err != nil {
 		klog.Errorf("failed to create dataplane: %v", err)
 		return fmt.Errorf("failed to create dataplane with error %w", err)
 	}
 
-	dp.RunPeriodicTasks()
+	v2Dataplane.RunPeriodicTasks()
+	dp = v2Dataplane
+	if config.Toggles.EnableDenyLogging {
+		deniedFlowsEncoder = startDenyLogging(v2Dataplane, wait.NeverStop)
+	}
 	// TODO Daemon should implement cache encoder
-	go restserver.NPMRestServerListenAndServe(config, nil)
+	go restserver.NPMRestServerListenAndServe(config, nil, deniedFlowsEncoder)
 
 	client, err := transport.NewEventsClient(ctx, pod, node, addr)
 	if err != nil {
@@ -17,6 +17,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -112,13 +113,25 @@ func startControlplane(config npmconfig.Config, flags npmconfig.Flags) error {
 		klog.Errorf("failed to create NPM controlplane manager with error: %v", err)
 		return fmt.Errorf("failed to create NPM controlplane manager: %w", err)
 	}
+	if config.Toggles.EnableAdminNetworkPolicies || config.Toggles.EnableFQDNNetworkPolicies {
+		dynamicClient, dynamicErr := dynamic.NewForConfig(k8sConfig)
+		if dynamicErr != nil {
+			return fmt.Errorf("failed to generate dynamic client with cluster config: %w", dynamicErr)
+		}
+		if config.Toggles.EnableAdminNetworkPolicies {
+			npMgr.AddAdminNetworkPolicyController(dynamicClient, resyncPeriod, dp)
+		}
+		if config.Toggles.EnableFQDNNetworkPolicies {
+			npMgr.AddFQDNNetworkPolicyController(dynamicClient, resyncPeriod, dp, fqdnResolverConfig(config))
+		}
+	}
 
 	err = metrics.CreateTelemetryHandle(config.NPMVersion(), version, npm.GetAIMetadata())
 	if err != nil {
 		klog.Infof("CreateTelemetryHandle failed with error %v. AITelemetry is not initialized.", err)
 	}
 
-	go restserver.NPMRestServerListenAndServe(config, npMgr)
+	go restserver.NPMRestServerListenAndServe(config, npMgr, nil)
 
 	metrics.SendLog(util.FanOutServerID, "starting fan-out server", metrics.PrintLog)
 
@@ -14,6 +14,11 @@ const (
 	v2 = 2
 )
 
+const (
+	defaultFQDNResolveIntervalInSeconds = 30
+	defaultFQDNMemberTTLInSeconds       = 300
+)
+
 // DefaultConfig is the guaranteed configuration NPM can run in out of the box
 var DefaultConfig = Config{
 	ResyncPeriodInMinutes: defaultResyncPeriod,
@@ -27,13 +32,22 @@ var DefaultConfig = Config{
 		ServicePort: defaultGrpcServicePort,
 	},
 
+	FQDN: FQDNConfig{
+		ResolveIntervalInSeconds: defaultFQDNResolveIntervalInSeconds,
+		MemberTTLInSeconds:       defaultFQDNMemberTTLInSeconds,
+	},
+
 	Toggles: Toggles{
-		EnablePrometheusMetrics: true,
-		EnablePprof:             true,
-		EnableHTTPDebugAPI:      true,
-		EnableV2NPM:             true,
-		PlaceAzureChainFirst:    util.PlaceAzureChainFirst,
-		ApplyIPSetsOnNeed:       false,
+		EnablePrometheusMetrics:    true,
+		EnablePprof:                true,
+		EnableHTTPDebugAPI:         true,
+		EnableV2NPM:                true,
+		PlaceAzureChainFirst:       util.PlaceAzureChainFirst,
+		ApplyIPSetsOnNeed:          false,
+		EnableNFTables:             false,
+		EnableDenyLogging:          false,
+		EnableAdminNetworkPolicies: false,
+		EnableFQDNNetworkPolicies:  false,
 	},
 }
 
@@ -46,6 +60,14 @@ type GrpcServerConfig struct {
 	ServicePort int `json:"ServicePort,omitempty"`
 }
 
+type FQDNConfig struct {
+	// ResolveIntervalInSeconds is how often the domain names of FQDNNetworkPolicies are resolved
+	ResolveIntervalInSeconds int `json:"ResolveIntervalInSeconds,omitempty"`
+	// MemberTTLInSeconds is how long an address is allowed after a domain name last resolved to it, when the TTL of
+	// its DNS record isn't known because resolv.conf has no nameservers
+	MemberTTLInSeconds int `json:"MemberTTLInSeconds,omitempty"`
+}
+
 type Config struct {
 	ResyncPeriodInMinutes int `json:"ResyncPeriodInMinutes,omitempty"`
 
@@ -54,6 +76,8 @@ type Config struct {
 
 	Transport GrpcServerConfig `json:"Transport,omitempty"`
 
+	FQDN FQDNConfig `json:"FQDN,omitempty"`
+
 	Toggles Toggles `json:"Toggles,omitempty"`
 }
 
@@ -64,6 +88,14 @@ type Toggles struct {
 	EnableV2NPM             bool
 	PlaceAzureChainFirst    bool
 	ApplyIPSetsOnNeed       bool
+	// EnableNFTables makes the v2 Linux dataplane use nftables instead of iptables and ipset
+	EnableNFTables bool
+	// EnableDenyLogging makes the v2 Linux dataplane log dropped packets via NFLOG and report them as denied flows
+	EnableDenyLogging bool
+	// EnableAdminNetworkPolicies makes v2 NPM enforce AdminNetworkPolicies and BaselineAdminNetworkPolicies on Linux
+	EnableAdminNetworkPolicies bool
+	// EnableFQDNNetworkPolicies makes v2 NPM enforce FQDNNetworkPolicies on Linux
+	EnableFQDNNetworkPolicies bool
 }
 
 type Flags struct {
@@ -5,14 +5,18 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	npmconfig "github.com/Azure/azure-container-networking/npm/config"
+	"github.com/Azure/azure-container-networking/npm/pkg/apis/adminnetworkpolicy/v1alpha1"
 	controllersv2 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v2"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/fqdn"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
 	"github.com/Azure/azure-container-networking/npm/pkg/models"
 	"github.com/Azure/azure-container-networking/npm/pkg/transport"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
@@ -133,6 +137,22 @@ func (n *NetworkPolicyServer) GetAppVersion() string {
 	return n.Version
 }
 
+// AddAdminNetworkPolicyController creates the informers and controller for AdminNetworkPolicies and BaselineAdminNetworkPolicies.
+// It must be called before Start.
+func (n *NetworkPolicyServer) AddAdminNetworkPolicyController(client dynamic.Interface, resyncPeriod time.Duration, dp dataplane.GenericDataplane) {
+	n.AnpInformer = controllersv2.NewAdminNetworkPolicyInformer(client, v1alpha1.AdminNetworkPoliciesResource, resyncPeriod)
+	n.BanpInformer = controllersv2.NewAdminNetworkPolicyInformer(client, v1alpha1.BaselineAdminNetworkPoliciesResource, resyncPeriod)
+	n.AdminNetPolControllerV2 = controllersv2.NewAdminNetworkPolicyController(n.AnpInformer, n.BanpInformer, dp)
+}
+
+// AddFQDNNetworkPolicyController creates the informer and controller for FQDNNetworkPolicies, and the resolver for their domain names.
+// It must be called before Start.
+func (n *NetworkPolicyServer) AddFQDNNetworkPolicyController(client dynamic.Interface, resyncPeriod time.Duration, dp dataplane.GenericDataplane, resolverCfg fqdn.Config) {
+	n.FqdnInformer = controllersv2.NewFQDNNetworkPolicyInformer(client, resyncPeriod)
+	n.FQDNResolver = fqdn.NewResolver(dp, resolverCfg)
+	n.FQDNNetPolControllerV2 = controllersv2.NewFQDNNetworkPolicyController(n.FqdnInformer, dp, n.FQDNResolver)
+}
+
 func (n *NetworkPolicyServer) Start(config npmconfig.Config, stopCh <-chan struct{}) error {
 	// Starts all informers manufactured by n's InformerFactory.
 	n.InformerFactory.Start(stopCh)
@@ -154,6 +174,7 @@ func (n *NetworkPolicyServer) Start(config npmconfig.Config, stopCh <-chan struc
 	go n.PodControllerV2.Run(stopCh)
 	go n.NamespaceControllerV2.Run(stopCh)
 	go n.NetPolControllerV2.Run(stopCh)
+	models.RunCRDControllers(&n.Informers, &n.K8SControllersV2, stopCh)
 
 	// start the transport layer (gRPC) server
 	// We block the main thread here until the server is stopped.
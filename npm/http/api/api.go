@@ -6,6 +6,7 @@ const (
 	NodeMetricsPath    = "/node-metrics"
 	ClusterMetricsPath = "/cluster-metrics"
 	NPMMgrPath         = "/npm/v1/debug/manager"
+	DeniedFlowsPath    = "/npm/v2/debug/denied-flows"
 )
 
 type DescribeIPSetRequest struct{}
@@ -21,7 +21,8 @@ type NPMRestServer struct {
 	router           *mux.Router
 }
 
-func NPMRestServerListenAndServe(config npmconfig.Config, npmEncoder json.Marshaler) {
+// NPMRestServerListenAndServe serves the HTTP API. The encoders are nil when their data isn't available.
+func NPMRestServerListenAndServe(config npmconfig.Config, npmEncoder, deniedFlowsEncoder json.Marshaler) {
 	rs := NPMRestServer{}
 
 	rs.router = mux.NewRouter()
@@ -39,6 +40,11 @@ func NPMRestServerListenAndServe(config npmconfig.Config, npmEncoder json.Marsha
 		rs.router.Handle(api.NPMMgrPath, rs.npmCacheHandler(npmEncoder)).Methods(http.MethodGet)
 	}
 
+	if config.Toggles.EnableHTTPDebugAPI && deniedFlowsEncoder != nil {
+		// denied flows of the v2 Linux dataplane, if deny logging is enabled
+		rs.router.Handle(api.DeniedFlowsPath, rs.npmCacheHandler(deniedFlowsEncoder)).Methods(http.MethodGet)
+	}
+
 	if config.Toggles.EnablePprof {
 		rs.router.PathPrefix("/debug/").Handler(http.DefaultServeMux)
 		rs.router.HandleFunc("/debug/pprof/", pprof.Index)
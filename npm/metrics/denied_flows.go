@@ -0,0 +1,24 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// IncDeniedFlowsBySelectingPolicy increments the number of packets dropped by NPM for a policy selecting their Pod,
+// and direction.
+func IncDeniedFlowsBySelectingPolicy(policyNamespace, policyName, direction string) {
+	deniedFlowsBySelectingPolicy.With(getDeniedFlowsBySelectingPolicyLabels(policyNamespace, policyName, direction)).Inc()
+}
+
+// GetDeniedFlowsBySelectingPolicy returns the number of packets dropped by NPM for a policy selecting their Pod,
+// and direction.
+// This function is slow.
+func GetDeniedFlowsBySelectingPolicy(policyNamespace, policyName, direction string) (int, error) {
+	return getCounterVecValue(deniedFlowsBySelectingPolicy, getDeniedFlowsBySelectingPolicyLabels(policyNamespace, policyName, direction))
+}
+
+func getDeniedFlowsBySelectingPolicyLabels(policyNamespace, policyName, direction string) prometheus.Labels {
+	return prometheus.Labels{
+		policyNamespaceLabel: policyNamespace,
+		policyNameLabel:      policyName,
+		directionLabel:       direction,
+	}
+}
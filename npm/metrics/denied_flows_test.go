@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncDeniedFlowsBySelectingPolicy(t *testing.T) {
+	IncDeniedFlowsBySelectingPolicy("x", "deny-all", "IN")
+	IncDeniedFlowsBySelectingPolicy("x", "deny-all", "IN")
+	IncDeniedFlowsBySelectingPolicy("x", "deny-all", "OUT")
+
+	val, err := GetDeniedFlowsBySelectingPolicy("x", "deny-all", "IN")
+	require.NoError(t, err)
+	require.Equal(t, 2, val)
+	val, err = GetDeniedFlowsBySelectingPolicy("x", "deny-all", "OUT")
+	require.NoError(t, err)
+	require.Equal(t, 1, val)
+	val, err = GetDeniedFlowsBySelectingPolicy("y", "deny-all", "IN")
+	require.NoError(t, err)
+	require.Equal(t, 0, val)
+}
@@ -54,6 +54,12 @@ const (
 	namespaceExecTimeName           = "namespace_exec_time"
 	controllerNamespaceExecTimeHelp = "Execution time in milliseconds for adding/updating/deleting a namespace"
 
+	deniedFlowsBySelectingPolicyName = "denied_flows_by_selecting_policy"
+	deniedFlowsBySelectingPolicyHelp = "The number of packets dropped by NPM, counted for each policy selecting the Pod which the packet was going to or coming from. A packet is dropped when none of these policies allows it, so the count doesn't tell which of them denied it"
+	policyNamespaceLabel             = "policy_namespace"
+	policyNameLabel                  = "policy_name"
+	directionLabel                   = "direction"
+
 	// TODO add health metrics
 
 	quantileMedian float64 = 0.5
@@ -67,7 +73,8 @@ const (
 // Gauge metrics have the methods Inc(), Dec(), and Set(float64)
 // Summary metrics have the method Observe(float64)
 // For any Vector metric, you can call With(prometheus.Labels) before the above methods
-//   e.g. SomeGaugeVec.With(prometheus.Labels{label1: val1, label2: val2, ...).Dec()
+//
+//	e.g. SomeGaugeVec.With(prometheus.Labels{label1: val1, label2: val2, ...).Dec()
 var (
 	nodeRegistry    = prometheus.NewRegistry()
 	clusterRegistry = prometheus.NewRegistry()
@@ -95,6 +102,10 @@ var (
 	controllerNamespaceExecTime *prometheus.SummaryVec
 	controllerExecTimeLabels    = []string{operationLabel, hadErrorLabel}
 
+	// deny logging metrics
+	deniedFlowsBySelectingPolicy       *prometheus.CounterVec
+	deniedFlowsBySelectingPolicyLabels = []string{policyNamespaceLabel, policyNameLabel, directionLabel}
+
 	// TODO add health metrics
 )
 
@@ -171,6 +182,7 @@ func initializeDaemonMetrics() {
 	// NODE METRICS
 	addACLRuleExecTime = createNodeSummary(addACLRuleExecTimeName, addACLRuleExecTimeHelp)
 	addIPSetExecTime = createNodeSummary(addIPSetExecTimeName, addIPSetExecTimeHelp)
+	deniedFlowsBySelectingPolicy = createNodeCounterVec(deniedFlowsBySelectingPolicyName, deniedFlowsBySelectingPolicyHelp, deniedFlowsBySelectingPolicyLabels)
 }
 
 // initializeControllerMetrics creates metrics modified by the controller
@@ -228,6 +240,19 @@ func createClusterGaugeVec(name, helpMessage string, labels []string) *prometheu
 	return gaugeVec
 }
 
+func createNodeCounterVec(name, helpMessage string, labels []string) *prometheus.CounterVec {
+	counterVec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      helpMessage,
+		},
+		labels,
+	)
+	register(counterVec, name, NodeMetrics)
+	return counterVec
+}
+
 func createNodeSummary(name, helpMessage string) prometheus.Summary {
 	// uses default observation TTL of 10 minutes
 	summary := prometheus.NewSummary(
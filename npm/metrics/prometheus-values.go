@@ -25,6 +25,16 @@ func getVecValue(gaugeVecMetric *prometheus.GaugeVec, labels prometheus.Labels)
 	return getValue(gaugeVecMetric.With(labels))
 }
 
+// getCounterVecValue returns a Counter Vec metric's value, or 0 if the label doesn't exist for the metric.
+// This function is slow.
+func getCounterVecValue(counterVecMetric *prometheus.CounterVec, labels prometheus.Labels) (int, error) {
+	dtoMetric, err := getDTOMetric(counterVecMetric.With(labels))
+	if err != nil {
+		return 0, err
+	}
+	return int(dtoMetric.Counter.GetValue()), nil
+}
+
 // getCountValue returns the number of times a Summary metric has recorded an observation.
 // This function is slow.
 func getCountValue(collector prometheus.Collector) (int, error) {
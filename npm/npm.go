@@ -5,15 +5,19 @@ package npm
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	npmconfig "github.com/Azure/azure-container-networking/npm/config"
 	"github.com/Azure/azure-container-networking/npm/ipsm"
+	"github.com/Azure/azure-container-networking/npm/pkg/apis/adminnetworkpolicy/v1alpha1"
 	controllersv1 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v1"
 	controllersv2 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v2"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/fqdn"
 	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
 	"github.com/Azure/azure-container-networking/npm/pkg/models"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
@@ -153,6 +157,22 @@ func (npMgr *NetworkPolicyManager) GetAppVersion() string {
 	return npMgr.Version
 }
 
+// AddAdminNetworkPolicyController creates the informers and controller for AdminNetworkPolicies and BaselineAdminNetworkPolicies.
+// It must be called before Start, and only for v2 NPM.
+func (npMgr *NetworkPolicyManager) AddAdminNetworkPolicyController(client dynamic.Interface, resyncPeriod time.Duration, dp dataplane.GenericDataplane) {
+	npMgr.AnpInformer = controllersv2.NewAdminNetworkPolicyInformer(client, v1alpha1.AdminNetworkPoliciesResource, resyncPeriod)
+	npMgr.BanpInformer = controllersv2.NewAdminNetworkPolicyInformer(client, v1alpha1.BaselineAdminNetworkPoliciesResource, resyncPeriod)
+	npMgr.AdminNetPolControllerV2 = controllersv2.NewAdminNetworkPolicyController(npMgr.AnpInformer, npMgr.BanpInformer, dp)
+}
+
+// AddFQDNNetworkPolicyController creates the informer and controller for FQDNNetworkPolicies, and the resolver for their domain names.
+// It must be called before Start, and only for v2 NPM.
+func (npMgr *NetworkPolicyManager) AddFQDNNetworkPolicyController(client dynamic.Interface, resyncPeriod time.Duration, dp dataplane.GenericDataplane, resolverCfg fqdn.Config) {
+	npMgr.FqdnInformer = controllersv2.NewFQDNNetworkPolicyInformer(client, resyncPeriod)
+	npMgr.FQDNResolver = fqdn.NewResolver(dp, resolverCfg)
+	npMgr.FQDNNetPolControllerV2 = controllersv2.NewFQDNNetworkPolicyController(npMgr.FqdnInformer, dp, npMgr.FQDNResolver)
+}
+
 // Start starts shared informers and waits for the shared informer cache to sync.
 func (npMgr *NetworkPolicyManager) Start(config npmconfig.Config, stopCh <-chan struct{}) error {
 	if !config.Toggles.EnableV2NPM {
@@ -183,6 +203,7 @@ func (npMgr *NetworkPolicyManager) Start(config npmconfig.Config, stopCh <-chan
 		go npMgr.PodControllerV2.Run(stopCh)
 		go npMgr.NamespaceControllerV2.Run(stopCh)
 		go npMgr.NetPolControllerV2.Run(stopCh)
+		models.RunCRDControllers(&npMgr.Informers, &npMgr.K8SControllersV2, stopCh)
 		return nil
 	}
 
@@ -0,0 +1,37 @@
+// Package v1alpha1 mirrors the subset of the SIG-network AdminNetworkPolicy API (policy.networking.k8s.io/v1alpha1) which NPM translates.
+// The objects are read with the dynamic client and converted from unstructured, so they aren't registered with a scheme.
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	// GroupVersion is the group version of the AdminNetworkPolicy CRDs
+	GroupVersion = schema.GroupVersion{Group: "policy.networking.k8s.io", Version: "v1alpha1"}
+
+	AdminNetworkPoliciesResource         = GroupVersion.WithResource("adminnetworkpolicies")
+	BaselineAdminNetworkPoliciesResource = GroupVersion.WithResource("baselineadminnetworkpolicies")
+)
+
+// AdminNetworkPolicyFromUnstructured converts an object listed or watched with the dynamic client
+func AdminNetworkPolicyFromUnstructured(obj *unstructured.Unstructured) (*AdminNetworkPolicy, error) {
+	anp := &AdminNetworkPolicy{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), anp); err != nil {
+		return nil, fmt.Errorf("failed to convert AdminNetworkPolicy %s: %w", obj.GetName(), err)
+	}
+	return anp, nil
+}
+
+// BaselineAdminNetworkPolicyFromUnstructured converts an object listed or watched with the dynamic client
+func BaselineAdminNetworkPolicyFromUnstructured(obj *unstructured.Unstructured) (*BaselineAdminNetworkPolicy, error) {
+	banp := &BaselineAdminNetworkPolicy{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), banp); err != nil {
+		return nil, fmt.Errorf("failed to convert BaselineAdminNetworkPolicy %s: %w", obj.GetName(), err)
+	}
+	return banp, nil
+}
@@ -0,0 +1,27 @@
+// Package v1alpha1 contains the FQDNNetworkPolicy API (acn.azure.com/v1alpha1), which allows egress to domain names.
+// The objects are read with the dynamic client and converted from unstructured, so they aren't registered with a scheme.
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	// GroupVersion is the group version of the FQDNNetworkPolicy CRD
+	GroupVersion = schema.GroupVersion{Group: "acn.azure.com", Version: "v1alpha1"}
+
+	FQDNNetworkPoliciesResource = GroupVersion.WithResource("fqdnnetworkpolicies")
+)
+
+// FQDNNetworkPolicyFromUnstructured converts an object listed or watched with the dynamic client
+func FQDNNetworkPolicyFromUnstructured(obj *unstructured.Unstructured) (*FQDNNetworkPolicy, error) {
+	policy := &FQDNNetworkPolicy{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), policy); err != nil {
+		return nil, fmt.Errorf("failed to convert FQDNNetworkPolicy %s/%s: %w", obj.GetNamespace(), obj.GetName(), err)
+	}
+	return policy, nil
+}
@@ -0,0 +1,38 @@
+package v1alpha1
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FQDNNetworkPolicy allows the selected Pods to send traffic to domain names.
+// Like a NetworkPolicy with only egress rules, the selected Pods can only send the traffic
+// which this policy or another policy allows, so DNS must be allowed by a NetworkPolicy.
+type FQDNNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec FQDNNetworkPolicySpec `json:"spec"`
+}
+
+type FQDNNetworkPolicySpec struct {
+	// PodSelector selects the Pods in the policy's namespace which the policy applies to
+	PodSelector metav1.LabelSelector          `json:"podSelector"`
+	Egress      []FQDNNetworkPolicyEgressRule `json:"egress,omitempty"`
+}
+
+// FQDNNetworkPolicyEgressRule allows traffic to any of the peers on any of the ports
+type FQDNNetworkPolicyEgressRule struct {
+	// Ports allows all ports if empty. Named ports aren't supported.
+	Ports []networkingv1.NetworkPolicyPort `json:"ports,omitempty"`
+	To    []FQDNNetworkPolicyPeer          `json:"to"`
+}
+
+// FQDNNetworkPolicyPeer matches the addresses which a domain name resolves to.
+// NPM resolves the name every FQDN.ResolveIntervalInSeconds (30 by default) and allows an address until
+// its DNS record expires after the name last resolved to it. When the TTL of the record isn't known, the
+// address is allowed for FQDN.MemberTTLInSeconds (300 by default) instead.
+type FQDNNetworkPolicyPeer struct {
+	// FQDN is a fully qualified domain name like "api.example.com". Wildcards aren't supported.
+	FQDN string `json:"fqdn"`
+}
@@ -0,0 +1,283 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/apis/adminnetworkpolicy/v1alpha1"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/translation"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+const (
+	adminPolicyKind         = "AdminNetworkPolicy"
+	baselineAdminPolicyKind = "BaselineAdminNetworkPolicy"
+)
+
+var (
+	errAdminPolicyKeyFormat          = errors.New("invalid admin network policy key format")
+	errAdminPolicyTranslationFailure = errors.New("failed to translate admin network policy")
+)
+
+// NewAdminNetworkPolicyInformer returns an informer for the cluster-scoped AdminNetworkPolicy or BaselineAdminNetworkPolicy resource.
+func NewAdminNetworkPolicyInformer(client dynamic.Interface, resource schema.GroupVersionResource, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return newUnstructuredInformer(client, resource, resyncPeriod)
+}
+
+// newUnstructuredInformer returns an informer for a resource in all namespaces.
+// CRDs aren't part of client-go, so the objects are listed and watched as unstructured with the dynamic client.
+func newUnstructuredInformer(client dynamic.Interface, resource schema.GroupVersionResource, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.Resource(resource).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.Resource(resource).Watch(context.TODO(), options)
+			},
+		},
+		&unstructured.Unstructured{},
+		resyncPeriod,
+		cache.Indexers{},
+	)
+}
+
+// AdminNetworkPolicyController translates AdminNetworkPolicies and BaselineAdminNetworkPolicies into the admin tiers of the dataplane.
+type AdminNetworkPolicyController struct {
+	anpInformer  cache.SharedIndexInformer
+	banpInformer cache.SharedIndexInformer
+	workqueue    workqueue.RateLimitingInterface
+	// rawSpecMap holds the spec of each applied policy. Key is AdminNetworkPolicy/<name> or BaselineAdminNetworkPolicy/<name>
+	rawSpecMap map[string]interface{}
+	dp         dataplane.GenericDataplane
+}
+
+func NewAdminNetworkPolicyController(anpInformer, banpInformer cache.SharedIndexInformer, dp dataplane.GenericDataplane) *AdminNetworkPolicyController {
+	c := &AdminNetworkPolicyController{
+		anpInformer:  anpInformer,
+		banpInformer: banpInformer,
+		workqueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "AdminNetworkPolicy"),
+		rawSpecMap:   make(map[string]interface{}),
+		dp:           dp,
+	}
+
+	anpInformer.AddEventHandler(c.eventHandler(translation.AdminPolicyKey))
+	banpInformer.AddEventHandler(c.eventHandler(translation.BaselineAdminPolicyKey))
+	return c
+}
+
+func (c *AdminNetworkPolicyController) LengthOfRawSpecMap() int {
+	return len(c.rawSpecMap)
+}
+
+// eventHandler enqueues the policy key of added, updated, and deleted objects
+func (c *AdminNetworkPolicyController) eventHandler(policyKey func(name string) string) cache.ResourceEventHandlerFuncs {
+	enqueue := func(obj interface{}) {
+		// DeletionHandlingMetaNamespaceKeyFunc handles DeletedFinalStateUnknown tombstones.
+		// The policies are cluster-scoped, so the key is only the name.
+		name, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			utilruntime.HandleError(err)
+			return
+		}
+		c.workqueue.Add(policyKey(name))
+	}
+
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(old, newObj interface{}) {
+			oldPolicy, oldOK := old.(*unstructured.Unstructured)
+			newPolicy, newOK := newObj.(*unstructured.Unstructured)
+			if oldOK && newOK && oldPolicy.GetResourceVersion() == newPolicy.GetResourceVersion() {
+				// Periodic resync will send update events for all known policies.
+				return
+			}
+			enqueue(newObj)
+		},
+		DeleteFunc: enqueue,
+	}
+}
+
+func (c *AdminNetworkPolicyController) Run(stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	klog.Infof("Starting Admin Network Policy worker")
+	go wait.Until(c.runWorker, time.Second, stopCh)
+
+	klog.Infof("Started Admin Network Policy worker")
+	<-stopCh
+	klog.Info("Shutting down Admin Network Policy workers")
+}
+
+func (c *AdminNetworkPolicyController) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *AdminNetworkPolicyController) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+
+	if shutdown {
+		return false
+	}
+
+	err := func(obj interface{}) error {
+		defer c.workqueue.Done(obj)
+		key, ok := obj.(string)
+		if !ok {
+			c.workqueue.Forget(obj)
+			utilruntime.HandleError(fmt.Errorf("expected string in workqueue but got %#v, err %w", obj, errWorkqueueFormatting))
+			return nil
+		}
+		if err := c.syncAdminPolicy(key); err != nil {
+			c.workqueue.AddRateLimited(key)
+			return fmt.Errorf("error syncing '%s': %w, requeuing", key, err)
+		}
+		c.workqueue.Forget(obj)
+		klog.Infof("Successfully synced '%s'", key)
+		return nil
+	}(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		metrics.SendErrorLogAndMetric(util.NetpolID, "syncAdminPolicy error due to %v", err)
+		return true
+	}
+
+	return true
+}
+
+// syncAdminPolicy compares the actual state with the desired, and attempts to converge the two.
+func (c *AdminNetworkPolicyController) syncAdminPolicy(key string) error {
+	timer := metrics.StartNewTimer()
+
+	// the kind takes the place of the namespace in the key
+	kind, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil || (kind != adminPolicyKind && kind != baselineAdminPolicyKind) {
+		utilruntime.HandleError(fmt.Errorf("invalid resource key: %s err: %w", key, errAdminPolicyKeyFormat))
+		return nil //nolint HandleError  is used instead of returning error to caller
+	}
+
+	operationKind := metrics.NoOp
+	defer func() {
+		metrics.RecordControllerPolicyExecTime(timer, operationKind, err != nil)
+	}()
+
+	informer := c.anpInformer
+	if kind == baselineAdminPolicyKind {
+		informer = c.banpInformer
+	}
+	obj, exists, err := informer.GetIndexer().GetByKey(name)
+	if err != nil {
+		return fmt.Errorf("[syncAdminPolicy] error getting %s: %w", key, err)
+	}
+
+	var policy *unstructured.Unstructured
+	if exists {
+		policy, _ = obj.(*unstructured.Unstructured)
+	}
+	if policy == nil || policy.GetDeletionTimestamp() != nil {
+		klog.Infof("%s is not found or is being deleted", key)
+		if _, ok := c.rawSpecMap[key]; ok {
+			operationKind = metrics.DeleteOp
+		}
+		err = c.cleanUpAdminPolicy(key)
+		return err
+	}
+
+	operationKind, err = c.syncAddAndUpdateAdminPolicy(key, kind, policy)
+	if err != nil {
+		return fmt.Errorf("[syncAdminPolicy] error due to %w", err)
+	}
+	return nil
+}
+
+// syncAddAndUpdateAdminPolicy translates a new or updated policy and installs it into the dataplane
+func (c *AdminNetworkPolicyController) syncAddAndUpdateAdminPolicy(key, kind string, policy *unstructured.Unstructured) (metrics.OperationKind, error) {
+	var spec interface{}
+	var npmNetPol *policies.NPMNetworkPolicy
+	var err error
+	if kind == adminPolicyKind {
+		var anp *v1alpha1.AdminNetworkPolicy
+		if anp, err = v1alpha1.AdminNetworkPolicyFromUnstructured(policy); err == nil {
+			spec = &anp.Spec
+			if c.isApplied(key, spec) {
+				return metrics.NoOp, nil
+			}
+			npmNetPol, err = translation.TranslateAdminPolicy(anp)
+		}
+	} else {
+		var banp *v1alpha1.BaselineAdminNetworkPolicy
+		if banp, err = v1alpha1.BaselineAdminNetworkPolicyFromUnstructured(policy); err == nil {
+			spec = &banp.Spec
+			if c.isApplied(key, spec) {
+				return metrics.NoOp, nil
+			}
+			npmNetPol, err = translation.TranslateBaselineAdminPolicy(banp)
+		}
+	}
+
+	if err != nil {
+		// Re-queuing would result in the same error, so remove any previously applied version
+		// rather than keep enforcing rules which no longer match the policy.
+		klog.Errorf("Failed to translate %s, so it is not enforced: %s", key, err.Error())
+		if cleanUpErr := c.cleanUpAdminPolicy(key); cleanUpErr != nil {
+			return metrics.DeleteOp, cleanUpErr
+		}
+		if errors.Is(err, translation.ErrUnsupportedAdminSubject) || errors.Is(err, translation.ErrUnsupportedAdminPeer) {
+			return metrics.NoOp, nil
+		}
+		return metrics.NoOp, fmt.Errorf("%w: %s", errAdminPolicyTranslationFailure, err.Error())
+	}
+
+	operationKind := metrics.CreateOp
+	if _, ok := c.rawSpecMap[key]; ok {
+		operationKind = metrics.UpdateOp
+	}
+
+	if err = c.dp.UpdatePolicy(npmNetPol); err != nil {
+		return operationKind, fmt.Errorf("[syncAddAndUpdateAdminPolicy] Error: failed to update translated NPMNetworkPolicy into Dataplane due to %w", err)
+	}
+
+	c.rawSpecMap[key] = spec
+	return operationKind, nil
+}
+
+// isApplied returns true if the spec is the same as the lastly applied spec for the key
+func (c *AdminNetworkPolicyController) isApplied(key string, spec interface{}) bool {
+	cachedSpec, ok := c.rawSpecMap[key]
+	return ok && reflect.DeepEqual(cachedSpec, spec)
+}
+
+// cleanUpAdminPolicy removes an applied policy from the dataplane
+func (c *AdminNetworkPolicyController) cleanUpAdminPolicy(key string) error {
+	if _, ok := c.rawSpecMap[key]; !ok {
+		return nil
+	}
+
+	if err := c.dp.RemovePolicy(key); err != nil {
+		return fmt.Errorf("[cleanUpAdminPolicy] Error: failed to remove policy due to %w", err)
+	}
+
+	delete(c.rawSpecMap, key)
+	return nil
+}
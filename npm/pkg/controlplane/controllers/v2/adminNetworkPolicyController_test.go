@@ -0,0 +1,103 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package controllers
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/apis/adminnetworkpolicy/v1alpha1"
+	dpmocks "github.com/Azure/azure-container-networking/npm/pkg/dataplane/mocks"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newAdminNetPolController(dp *dpmocks.MockGenericDataplane) *AdminNetworkPolicyController {
+	// the informers are never run, so they don't need a client
+	anpInformer := NewAdminNetworkPolicyInformer(nil, v1alpha1.AdminNetworkPoliciesResource, noResyncPeriodFunc())
+	banpInformer := NewAdminNetworkPolicyInformer(nil, v1alpha1.BaselineAdminNetworkPoliciesResource, noResyncPeriodFunc())
+	return NewAdminNetworkPolicyController(anpInformer, banpInformer, dp)
+}
+
+func adminPolicyObj(kind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": v1alpha1.GroupVersion.String(),
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "resourceVersion": "1"},
+		"spec":       spec,
+	}}
+}
+
+func adminPolicySpec(action string) map[string]interface{} {
+	return map[string]interface{}{
+		"priority": int64(5),
+		"subject":  map[string]interface{}{"namespaces": map[string]interface{}{}},
+		"ingress": []interface{}{
+			map[string]interface{}{
+				"action": action,
+				"from":   []interface{}{map[string]interface{}{"namespaces": map[string]interface{}{}}},
+			},
+		},
+	}
+}
+
+func TestSyncAdminPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	c := newAdminNetPolController(dp)
+
+	anp := adminPolicyObj(adminPolicyKind, "guardrail", adminPolicySpec("Deny"))
+	require.NoError(t, c.anpInformer.GetIndexer().Add(anp))
+	banp := adminPolicyObj(baselineAdminPolicyKind, "default", adminPolicySpec("Allow"))
+	require.NoError(t, c.banpInformer.GetIndexer().Add(banp))
+
+	dp.EXPECT().UpdatePolicy(gomock.Any()).DoAndReturn(func(npmNetPol *policies.NPMNetworkPolicy) error {
+		require.Equal(t, "AdminNetworkPolicy/guardrail", npmNetPol.PolicyKey)
+		require.Equal(t, policies.AdminTier, npmNetPol.Tier)
+		require.Equal(t, int32(5), npmNetPol.Priority)
+		return nil
+	}).Times(1)
+	require.NoError(t, c.syncAdminPolicy("AdminNetworkPolicy/guardrail"))
+
+	dp.EXPECT().UpdatePolicy(gomock.Any()).DoAndReturn(func(npmNetPol *policies.NPMNetworkPolicy) error {
+		require.Equal(t, "BaselineAdminNetworkPolicy/default", npmNetPol.PolicyKey)
+		require.Equal(t, policies.BaselineAdminTier, npmNetPol.Tier)
+		return nil
+	}).Times(1)
+	require.NoError(t, c.syncAdminPolicy("BaselineAdminNetworkPolicy/default"))
+	require.Equal(t, 2, c.LengthOfRawSpecMap())
+
+	// an unchanged spec isn't applied again
+	require.NoError(t, c.syncAdminPolicy("AdminNetworkPolicy/guardrail"))
+
+	// a deleted policy is removed from the dataplane
+	require.NoError(t, c.anpInformer.GetIndexer().Delete(anp))
+	dp.EXPECT().RemovePolicy("AdminNetworkPolicy/guardrail").Return(nil).Times(1)
+	require.NoError(t, c.syncAdminPolicy("AdminNetworkPolicy/guardrail"))
+	require.Equal(t, 1, c.LengthOfRawSpecMap())
+
+	// an invalid key is dropped
+	require.NoError(t, c.syncAdminPolicy("NetworkPolicy/guardrail"))
+}
+
+func TestSyncAdminPolicyTranslationFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	c := newAdminNetPolController(dp)
+
+	anp := adminPolicyObj(adminPolicyKind, "guardrail", adminPolicySpec("Deny"))
+	require.NoError(t, c.anpInformer.GetIndexer().Add(anp))
+	dp.EXPECT().UpdatePolicy(gomock.Any()).Return(nil).Times(1)
+	require.NoError(t, c.syncAdminPolicy("AdminNetworkPolicy/guardrail"))
+
+	// the previously applied version is removed when the update can't be translated
+	updated := adminPolicyObj(adminPolicyKind, "guardrail", adminPolicySpec("Log"))
+	updated.SetResourceVersion("2")
+	require.NoError(t, c.anpInformer.GetIndexer().Update(updated))
+	dp.EXPECT().RemovePolicy("AdminNetworkPolicy/guardrail").Return(nil).Times(1)
+	require.Error(t, c.syncAdminPolicy("AdminNetworkPolicy/guardrail"))
+	require.Equal(t, 0, c.LengthOfRawSpecMap())
+}
@@ -0,0 +1,241 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/apis/fqdnnetworkpolicy/v1alpha1"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/translation"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+var errFQDNPolicyTranslationFailure = errors.New("failed to translate FQDN network policy")
+
+// NewFQDNNetworkPolicyInformer returns an informer for FQDNNetworkPolicies in all namespaces.
+func NewFQDNNetworkPolicyInformer(client dynamic.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return newUnstructuredInformer(client, v1alpha1.FQDNNetworkPoliciesResource, resyncPeriod)
+}
+
+// FQDNResolver keeps the FQDN IPSets of the domain names referenced by each policy up to date.
+// It is implemented by fqdn.Resolver.
+type FQDNResolver interface {
+	SetPolicyFQDNs(policyKey string, fqdns []string) error
+}
+
+// FQDNNetworkPolicyController translates FQDNNetworkPolicies into the dataplane,
+// and tells the FQDN resolver which domain names each policy references.
+type FQDNNetworkPolicyController struct {
+	fqdnInformer cache.SharedIndexInformer
+	workqueue    workqueue.RateLimitingInterface
+	// rawSpecMap holds the spec of each applied policy. Key is <namespace>/<name>
+	rawSpecMap map[string]*v1alpha1.FQDNNetworkPolicySpec
+	dp         dataplane.GenericDataplane
+	resolver   FQDNResolver
+}
+
+func NewFQDNNetworkPolicyController(fqdnInformer cache.SharedIndexInformer, dp dataplane.GenericDataplane, resolver FQDNResolver) *FQDNNetworkPolicyController {
+	c := &FQDNNetworkPolicyController{
+		fqdnInformer: fqdnInformer,
+		workqueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "FQDNNetworkPolicy"),
+		rawSpecMap:   make(map[string]*v1alpha1.FQDNNetworkPolicySpec),
+		dp:           dp,
+		resolver:     resolver,
+	}
+
+	fqdnInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueue,
+		UpdateFunc: func(old, newObj interface{}) {
+			oldPolicy, oldOK := old.(*unstructured.Unstructured)
+			newPolicy, newOK := newObj.(*unstructured.Unstructured)
+			if oldOK && newOK && oldPolicy.GetResourceVersion() == newPolicy.GetResourceVersion() {
+				// Periodic resync will send update events for all known policies.
+				return
+			}
+			c.enqueue(newObj)
+		},
+		DeleteFunc: c.enqueue,
+	})
+	return c
+}
+
+func (c *FQDNNetworkPolicyController) LengthOfRawSpecMap() int {
+	return len(c.rawSpecMap)
+}
+
+// enqueue adds the <namespace>/<name> key of added, updated, and deleted objects
+func (c *FQDNNetworkPolicyController) enqueue(obj interface{}) {
+	// DeletionHandlingMetaNamespaceKeyFunc handles DeletedFinalStateUnknown tombstones
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.workqueue.Add(key)
+}
+
+func (c *FQDNNetworkPolicyController) Run(stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	klog.Infof("Starting FQDN Network Policy worker")
+	go wait.Until(c.runWorker, time.Second, stopCh)
+
+	klog.Infof("Started FQDN Network Policy worker")
+	<-stopCh
+	klog.Info("Shutting down FQDN Network Policy workers")
+}
+
+func (c *FQDNNetworkPolicyController) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *FQDNNetworkPolicyController) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+
+	if shutdown {
+		return false
+	}
+
+	err := func(obj interface{}) error {
+		defer c.workqueue.Done(obj)
+		key, ok := obj.(string)
+		if !ok {
+			c.workqueue.Forget(obj)
+			utilruntime.HandleError(fmt.Errorf("expected string in workqueue but got %#v, err %w", obj, errWorkqueueFormatting))
+			return nil
+		}
+		if err := c.syncFQDNPolicy(key); err != nil {
+			c.workqueue.AddRateLimited(key)
+			return fmt.Errorf("error syncing '%s': %w, requeuing", key, err)
+		}
+		c.workqueue.Forget(obj)
+		klog.Infof("Successfully synced '%s'", key)
+		return nil
+	}(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		metrics.SendErrorLogAndMetric(util.NetpolID, "syncFQDNPolicy error due to %v", err)
+		return true
+	}
+
+	return true
+}
+
+// syncFQDNPolicy compares the actual state with the desired, and attempts to converge the two.
+func (c *FQDNNetworkPolicyController) syncFQDNPolicy(key string) error {
+	timer := metrics.StartNewTimer()
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil //nolint HandleError  is used instead of returning error to caller
+	}
+
+	operationKind := metrics.NoOp
+	defer func() {
+		metrics.RecordControllerPolicyExecTime(timer, operationKind, err != nil)
+	}()
+
+	obj, exists, err := c.fqdnInformer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return fmt.Errorf("[syncFQDNPolicy] error getting %s: %w", key, err)
+	}
+
+	var policy *unstructured.Unstructured
+	if exists {
+		policy, _ = obj.(*unstructured.Unstructured)
+	}
+	if policy == nil || policy.GetDeletionTimestamp() != nil {
+		klog.Infof("FQDN network policy %s is not found or is being deleted", key)
+		if _, ok := c.rawSpecMap[key]; ok {
+			operationKind = metrics.DeleteOp
+		}
+		err = c.cleanUpFQDNPolicy(key, namespace, name)
+		return err
+	}
+
+	operationKind, err = c.syncAddAndUpdateFQDNPolicy(key, policy)
+	if err != nil {
+		return fmt.Errorf("[syncFQDNPolicy] error due to %w", err)
+	}
+	return nil
+}
+
+// syncAddAndUpdateFQDNPolicy translates a new or updated policy, registers its domain names with the resolver,
+// and installs it into the dataplane
+func (c *FQDNNetworkPolicyController) syncAddAndUpdateFQDNPolicy(key string, policy *unstructured.Unstructured) (metrics.OperationKind, error) {
+	fqdnPol, err := v1alpha1.FQDNNetworkPolicyFromUnstructured(policy)
+	if err == nil {
+		if cachedSpec, ok := c.rawSpecMap[key]; ok && reflect.DeepEqual(cachedSpec, &fqdnPol.Spec) {
+			return metrics.NoOp, nil
+		}
+	}
+
+	var npmNetPol *policies.NPMNetworkPolicy
+	if err == nil {
+		npmNetPol, err = translation.TranslateFQDNPolicy(fqdnPol)
+	}
+	if err != nil {
+		// Re-queuing would result in the same error, so remove any previously applied version
+		// rather than keep enforcing rules which no longer match the policy.
+		klog.Errorf("Failed to translate FQDN network policy %s, so it is not enforced: %s", key, err.Error())
+		if cleanUpErr := c.cleanUpFQDNPolicy(key, policy.GetNamespace(), policy.GetName()); cleanUpErr != nil {
+			return metrics.DeleteOp, cleanUpErr
+		}
+		if errors.Is(err, translation.ErrInvalidFQDN) || errors.Is(err, translation.ErrUnsupportedFQDNNamedPort) {
+			return metrics.NoOp, nil
+		}
+		return metrics.NoOp, fmt.Errorf("%w: %s", errFQDNPolicyTranslationFailure, err.Error())
+	}
+
+	operationKind := metrics.CreateOp
+	if _, ok := c.rawSpecMap[key]; ok {
+		operationKind = metrics.UpdateOp
+	}
+
+	// resolve new domain names first, so that traffic to them is allowed as soon as the policy is applied
+	if err = c.resolver.SetPolicyFQDNs(npmNetPol.PolicyKey, translation.FQDNsOfPolicy(npmNetPol)); err != nil {
+		return operationKind, fmt.Errorf("[syncAddAndUpdateFQDNPolicy] Error: failed to update FQDN IPSets due to %w", err)
+	}
+
+	if err = c.dp.UpdatePolicy(npmNetPol); err != nil {
+		return operationKind, fmt.Errorf("[syncAddAndUpdateFQDNPolicy] Error: failed to update translated NPMNetworkPolicy into Dataplane due to %w", err)
+	}
+
+	c.rawSpecMap[key] = &fqdnPol.Spec
+	return operationKind, nil
+}
+
+// cleanUpFQDNPolicy removes an applied policy from the dataplane, then releases its domain names in the resolver
+func (c *FQDNNetworkPolicyController) cleanUpFQDNPolicy(key, namespace, name string) error {
+	if _, ok := c.rawSpecMap[key]; !ok {
+		return nil
+	}
+
+	policyKey := translation.FQDNPolicyKey(namespace, name)
+	if err := c.dp.RemovePolicy(policyKey); err != nil {
+		return fmt.Errorf("[cleanUpFQDNPolicy] Error: failed to remove policy due to %w", err)
+	}
+
+	if err := c.resolver.SetPolicyFQDNs(policyKey, nil); err != nil {
+		return fmt.Errorf("[cleanUpFQDNPolicy] Error: failed to remove FQDN IPSets due to %w", err)
+	}
+
+	delete(c.rawSpecMap, key)
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 Microsoft. All rights reserved.
+// MIT License
+package controllers
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/apis/fqdnnetworkpolicy/v1alpha1"
+	dpmocks "github.com/Azure/azure-container-networking/npm/pkg/dataplane/mocks"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// fakeFQDNResolver records the domain names referenced by each policy key
+type fakeFQDNResolver struct {
+	policyFQDNs map[string][]string
+}
+
+func (r *fakeFQDNResolver) SetPolicyFQDNs(policyKey string, fqdns []string) error {
+	if len(fqdns) == 0 {
+		delete(r.policyFQDNs, policyKey)
+		return nil
+	}
+	r.policyFQDNs[policyKey] = fqdns
+	return nil
+}
+
+func newFQDNNetPolController(dp *dpmocks.MockGenericDataplane) (*FQDNNetworkPolicyController, *fakeFQDNResolver) {
+	resolver := &fakeFQDNResolver{policyFQDNs: make(map[string][]string)}
+	// the informer is never run, so it doesn't need a client
+	fqdnInformer := NewFQDNNetworkPolicyInformer(nil, noResyncPeriodFunc())
+	return NewFQDNNetworkPolicyController(fqdnInformer, dp, resolver), resolver
+}
+
+func fqdnPolicyObj(namespace, name, resourceVersion string, fqdns ...string) *unstructured.Unstructured {
+	to := make([]interface{}, 0, len(fqdns))
+	for _, fqdn := range fqdns {
+		to = append(to, map[string]interface{}{"fqdn": fqdn})
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": v1alpha1.GroupVersion.String(),
+		"kind":       "FQDNNetworkPolicy",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace, "resourceVersion": resourceVersion},
+		"spec": map[string]interface{}{
+			"podSelector": map[string]interface{}{},
+			"egress":      []interface{}{map[string]interface{}{"to": to}},
+		},
+	}}
+}
+
+func TestSyncFQDNPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	c, resolver := newFQDNNetPolController(dp)
+
+	fqdnPol := fqdnPolicyObj("x", "saas", "1", "api.example.com", "login.example.com")
+	require.NoError(t, c.fqdnInformer.GetIndexer().Add(fqdnPol))
+	dp.EXPECT().UpdatePolicy(gomock.Any()).DoAndReturn(func(npmNetPol *policies.NPMNetworkPolicy) error {
+		require.Equal(t, "FQDNNetworkPolicy/x/saas", npmNetPol.PolicyKey)
+		return nil
+	}).Times(1)
+	require.NoError(t, c.syncFQDNPolicy("x/saas"))
+	require.Equal(t, []string{"api.example.com", "login.example.com"}, resolver.policyFQDNs["FQDNNetworkPolicy/x/saas"])
+	require.Equal(t, 1, c.LengthOfRawSpecMap())
+
+	// an unchanged spec isn't applied again
+	require.NoError(t, c.syncFQDNPolicy("x/saas"))
+
+	updated := fqdnPolicyObj("x", "saas", "2", "api.example.com")
+	require.NoError(t, c.fqdnInformer.GetIndexer().Update(updated))
+	dp.EXPECT().UpdatePolicy(gomock.Any()).Return(nil).Times(1)
+	require.NoError(t, c.syncFQDNPolicy("x/saas"))
+	require.Equal(t, []string{"api.example.com"}, resolver.policyFQDNs["FQDNNetworkPolicy/x/saas"])
+
+	// a deleted policy is removed from the dataplane and its domain names are released
+	require.NoError(t, c.fqdnInformer.GetIndexer().Delete(updated))
+	dp.EXPECT().RemovePolicy("FQDNNetworkPolicy/x/saas").Return(nil).Times(1)
+	require.NoError(t, c.syncFQDNPolicy("x/saas"))
+	require.Empty(t, resolver.policyFQDNs)
+	require.Equal(t, 0, c.LengthOfRawSpecMap())
+}
+
+func TestSyncFQDNPolicyTranslationFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dp := dpmocks.NewMockGenericDataplane(ctrl)
+	c, resolver := newFQDNNetPolController(dp)
+
+	fqdnPol := fqdnPolicyObj("x", "saas", "1", "api.example.com")
+	require.NoError(t, c.fqdnInformer.GetIndexer().Add(fqdnPol))
+	dp.EXPECT().UpdatePolicy(gomock.Any()).Return(nil).Times(1)
+	require.NoError(t, c.syncFQDNPolicy("x/saas"))
+
+	// the previously applied version is removed when the update has a wildcard, and it isn't requeued
+	updated := fqdnPolicyObj("x", "saas", "2", "*.example.com")
+	require.NoError(t, c.fqdnInformer.GetIndexer().Update(updated))
+	dp.EXPECT().RemovePolicy("FQDNNetworkPolicy/x/saas").Return(nil).Times(1)
+	require.NoError(t, c.syncFQDNPolicy("x/saas"))
+	require.Empty(t, resolver.policyFQDNs)
+	require.Equal(t, 0, c.LengthOfRawSpecMap())
+}
@@ -0,0 +1,262 @@
+package fqdn
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	resolvConfPath = "/etc/resolv.conf"
+	dnsPort        = "53"
+	// maxUDPResponseSize is the size of a DNS response over UDP without EDNS. Larger responses are truncated,
+	// and the query is sent again over TCP.
+	maxUDPResponseSize = 512
+	// maxCNAMEs bounds the chain of CNAME records followed to the addresses of a name
+	maxCNAMEs = 8
+)
+
+var (
+	errNoSuchHost         = errors.New("no such host")
+	errUnexpectedResponse = errors.New("unexpected DNS response")
+)
+
+// dnsRecord is an address which a domain name resolves to, and how long it may be cached
+type dnsRecord struct {
+	ip  net.IP
+	ttl time.Duration
+}
+
+// dnsClient looks up the IPv4 addresses of domain names with the TTLs of their records, which the resolver of the
+// Go standard library doesn't return. It queries the nameservers of resolv.conf in order until one answers.
+// When resolv.conf has no nameservers, the names are resolved by the resolver of the Go standard library instead,
+// and every address gets the fallback TTL.
+type dnsClient struct {
+	resolvConf  string
+	fallbackTTL time.Duration
+}
+
+func newDNSClient(resolvConf string, fallbackTTL time.Duration) *dnsClient {
+	return &dnsClient{resolvConf: resolvConf, fallbackTTL: fallbackTTL}
+}
+
+// lookup returns the IPv4 addresses of the domain name. resolv.conf is read for each lookup, so that a change of
+// the nameservers is picked up by the next resolve.
+func (c *dnsClient) lookup(ctx context.Context, host string) ([]dnsRecord, error) {
+	servers, err := readNameservers(c.resolvConf)
+	if err != nil || len(servers) == 0 {
+		return c.lookupWithoutTTL(ctx, host)
+	}
+
+	var lastErr error
+	for _, server := range servers {
+		records, err := queryA(ctx, server, host)
+		if err == nil || errors.Is(err, errNoSuchHost) {
+			return records, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// lookupWithoutTTL resolves the domain name with the resolver of the Go standard library
+func (c *dnsClient) lookupWithoutTTL(ctx context.Context, host string) ([]dnsRecord, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by the caller
+	}
+	records := make([]dnsRecord, 0, len(addrs))
+	for _, addr := range addrs {
+		records = append(records, dnsRecord{ip: addr.IP, ttl: c.fallbackTTL})
+	}
+	return records, nil
+}
+
+// readNameservers returns the addresses of the nameservers in resolv.conf
+func readNameservers(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	servers := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		if ip := net.ParseIP(fields[1]); ip != nil {
+			servers = append(servers, net.JoinHostPort(ip.String(), dnsPort))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return servers, nil
+}
+
+// queryA queries the nameserver for the A records of the domain name, over UDP and then over TCP if the response
+// is truncated.
+func queryA(ctx context.Context, server, host string) ([]dnsRecord, error) {
+	if !strings.HasSuffix(host, ".") {
+		host += "."
+	}
+	name, err := dnsmessage.NewName(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid domain name %s: %w", host, err)
+	}
+	var idBytes [2]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate DNS query ID: %w", err)
+	}
+	id := binary.BigEndian.Uint16(idBytes[:])
+
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack DNS query for %s: %w", host, err)
+	}
+
+	response, err := exchange(ctx, "udp", server, packed)
+	if err != nil {
+		return nil, err
+	}
+	records, truncated, err := parseA(response, id, name)
+	if err != nil || !truncated {
+		return records, err
+	}
+
+	response, err = exchange(ctx, "tcp", server, packed)
+	if err != nil {
+		return nil, err
+	}
+	records, _, err = parseA(response, id, name)
+	return records, err
+}
+
+// exchange sends the query to the nameserver and returns its response. Over TCP, messages are prefixed with their
+// length.
+func exchange(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nameserver %s: %w", server, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, fmt.Errorf("failed to set deadline of the query to nameserver %s: %w", server, err)
+		}
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, fmt.Errorf("failed to query nameserver %s: %w", server, err)
+		}
+		response := make([]byte, maxUDPResponseSize)
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response of nameserver %s: %w", server, err)
+		}
+		return response[:n], nil
+	}
+
+	message := make([]byte, 2, 2+len(query))
+	binary.BigEndian.PutUint16(message, uint16(len(query)))
+	if _, err := conn.Write(append(message, query...)); err != nil {
+		return nil, fmt.Errorf("failed to query nameserver %s: %w", server, err)
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, fmt.Errorf("failed to read response of nameserver %s: %w", server, err)
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, fmt.Errorf("failed to read response of nameserver %s: %w", server, err)
+	}
+	return response, nil
+}
+
+// parseA returns the addresses of the domain name in the response, following its CNAME records. The TTL of an
+// address is the shortest TTL of the records leading to it, since the address may change once any of them expires.
+func parseA(response []byte, id uint16, name dnsmessage.Name) ([]dnsRecord, bool, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(response)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse DNS response: %w", err)
+	}
+	if header.ID != id || !header.Response {
+		return nil, false, errUnexpectedResponse
+	}
+	if header.Truncated {
+		return nil, true, nil
+	}
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, false, errNoSuchHost
+	default:
+		return nil, false, fmt.Errorf("%w: %s", errUnexpectedResponse, header.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, false, fmt.Errorf("failed to parse DNS response: %w", err)
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse DNS response: %w", err)
+	}
+
+	type cname struct {
+		target string
+		ttl    time.Duration
+	}
+	cnames := make(map[string]cname)
+	addresses := make(map[string][]dnsRecord)
+	for _, answer := range answers {
+		owner := strings.ToLower(answer.Header.Name.String())
+		ttl := time.Duration(answer.Header.TTL) * time.Second
+		switch body := answer.Body.(type) {
+		case *dnsmessage.CNAMEResource:
+			cnames[owner] = cname{target: strings.ToLower(body.CNAME.String()), ttl: ttl}
+		case *dnsmessage.AResource:
+			addresses[owner] = append(addresses[owner], dnsRecord{ip: net.IP(body.A[:]), ttl: ttl})
+		}
+	}
+
+	owner := strings.ToLower(name.String())
+	chainTTL := time.Duration(-1)
+	for i := 0; i < maxCNAMEs; i++ {
+		c, ok := cnames[owner]
+		if !ok {
+			break
+		}
+		owner = c.target
+		if chainTTL < 0 || c.ttl < chainTTL {
+			chainTTL = c.ttl
+		}
+	}
+
+	records := make([]dnsRecord, 0, len(addresses[owner]))
+	for _, record := range addresses[owner] {
+		if chainTTL >= 0 && chainTTL < record.ttl {
+			record.ttl = chainTTL
+		}
+		records = append(records, record)
+	}
+	return records, false, nil
+}
@@ -0,0 +1,230 @@
+package fqdn
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func mustName(t *testing.T, name string) dnsmessage.Name {
+	t.Helper()
+	n, err := dnsmessage.NewName(name)
+	require.NoError(t, err)
+	return n
+}
+
+// dnsResponse packs a response to the query with the answers
+func dnsResponse(t *testing.T, query *dnsmessage.Message, rcode dnsmessage.RCode, truncated bool, answers ...dnsmessage.Resource) []byte {
+	t.Helper()
+	response := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: rcode, Truncated: truncated},
+		Questions: query.Questions,
+		Answers:   answers,
+	}
+	packed, err := response.Pack()
+	require.NoError(t, err)
+	return packed
+}
+
+func aRecord(t *testing.T, name string, ip [4]byte, ttl uint32) dnsmessage.Resource {
+	t.Helper()
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: mustName(t, name), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.AResource{A: ip},
+	}
+}
+
+func cnameRecord(t *testing.T, name, target string, ttl uint32) dnsmessage.Resource {
+	t.Helper()
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: mustName(t, name), Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.CNAMEResource{CNAME: mustName(t, target)},
+	}
+}
+
+func TestParseA(t *testing.T) {
+	query := &dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 7},
+		Questions: []dnsmessage.Question{{Name: mustName(t, "api.example.com."), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	name := query.Questions[0].Name
+
+	tests := []struct {
+		name          string
+		response      []byte
+		want          []dnsRecord
+		wantTruncated bool
+		wantErr       error
+	}{
+		{
+			name: "addresses with their TTLs",
+			response: dnsResponse(t, query, dnsmessage.RCodeSuccess, false,
+				aRecord(t, "api.example.com.", [4]byte{20, 0, 0, 1}, 30),
+				aRecord(t, "api.example.com.", [4]byte{20, 0, 0, 2}, 300)),
+			want: []dnsRecord{
+				{ip: net.IPv4(20, 0, 0, 1).To4(), ttl: 30 * time.Second},
+				{ip: net.IPv4(20, 0, 0, 2).To4(), ttl: 300 * time.Second},
+			},
+		},
+		{
+			name: "CNAME with a shorter TTL than the address",
+			response: dnsResponse(t, query, dnsmessage.RCodeSuccess, false,
+				cnameRecord(t, "API.example.com.", "edge.example.net.", 20),
+				aRecord(t, "edge.example.net.", [4]byte{20, 0, 0, 3}, 60),
+				aRecord(t, "other.example.net.", [4]byte{20, 0, 0, 4}, 60)),
+			want: []dnsRecord{{ip: net.IPv4(20, 0, 0, 3).To4(), ttl: 20 * time.Second}},
+		},
+		{
+			name:     "no such host",
+			response: dnsResponse(t, query, dnsmessage.RCodeNameError, false),
+			wantErr:  errNoSuchHost,
+		},
+		{
+			name:     "server failure",
+			response: dnsResponse(t, query, dnsmessage.RCodeServerFailure, false),
+			wantErr:  errUnexpectedResponse,
+		},
+		{
+			name:          "truncated",
+			response:      dnsResponse(t, query, dnsmessage.RCodeSuccess, true),
+			wantTruncated: true,
+		},
+		{
+			name:     "response to another query",
+			response: dnsResponse(t, &dnsmessage.Message{Header: dnsmessage.Header{ID: 8}, Questions: query.Questions}, dnsmessage.RCodeSuccess, false),
+			wantErr:  errUnexpectedResponse,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			records, truncated, err := parseA(tt.response, query.ID, name)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantTruncated, truncated)
+			if tt.want != nil {
+				require.Equal(t, tt.want, records)
+			}
+		})
+	}
+}
+
+// startNameserver answers A queries for api.example.com over UDP and TCP on the same local port.
+// The UDP responses are truncated if truncateUDP is set.
+func startNameserver(t *testing.T, truncateUDP bool) string {
+	t.Helper()
+	var udpConn net.PacketConn
+	var tcpListener net.Listener
+	// the TCP listener takes the port of the UDP socket, which may already be taken for TCP
+	for i := 0; tcpListener == nil; i++ {
+		require.Less(t, i, 10, "no free port for UDP and TCP")
+		var err error
+		udpConn, err = net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		tcpListener, err = net.Listen("tcp", udpConn.LocalAddr().String())
+		if err != nil {
+			udpConn.Close()
+		}
+	}
+	t.Cleanup(func() {
+		udpConn.Close()
+		tcpListener.Close()
+	})
+
+	record := aRecord(t, "api.example.com.", [4]byte{20, 0, 0, 1}, 42)
+	// answer is called by the server goroutines, so it returns nil instead of failing the test
+	answer := func(packed []byte, truncated bool) []byte {
+		var query dnsmessage.Message
+		if err := query.Unpack(packed); err != nil {
+			return nil
+		}
+		response := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, Truncated: truncated},
+			Questions: query.Questions,
+		}
+		if !truncated {
+			response.Answers = []dnsmessage.Resource{record}
+		}
+		packedResponse, err := response.Pack()
+		if err != nil {
+			return nil
+		}
+		return packedResponse
+	}
+
+	go func() {
+		buf := make([]byte, maxUDPResponseSize)
+		for {
+			n, addr, err := udpConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = udpConn.WriteTo(answer(buf[:n], truncateUDP), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcpListener.Accept()
+			if err != nil {
+				return
+			}
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err == nil {
+				query := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, query); err == nil {
+					response := answer(query, false)
+					binary.BigEndian.PutUint16(length[:], uint16(len(response)))
+					_, _ = conn.Write(append(length[:], response...))
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return udpConn.LocalAddr().String()
+}
+
+func TestQueryA(t *testing.T) {
+	for _, truncateUDP := range []bool{false, true} {
+		server := startNameserver(t, truncateUDP)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		records, err := queryA(ctx, server, "api.example.com")
+		cancel()
+		require.NoError(t, err)
+		require.Equal(t, []dnsRecord{{ip: net.IPv4(20, 0, 0, 1).To4(), ttl: 42 * time.Second}}, records, "truncated over UDP: %t", truncateUDP)
+	}
+}
+
+func TestReadNameservers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	conf := "# generated\nsearch default.svc.cluster.local\nnameserver 10.0.0.10\nnameserver fd00::10\nnameserver\noptions ndots:5\n"
+	require.NoError(t, os.WriteFile(path, []byte(conf), 0o600))
+
+	servers, err := readNameservers(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.10:53", "[fd00::10]:53"}, servers)
+}
+
+func TestDNSClientLookupWithoutNameservers(t *testing.T) {
+	// without nameservers in resolv.conf, the resolver of the Go standard library is used and the TTLs aren't known
+	c := newDNSClient(filepath.Join(t.TempDir(), "resolv.conf"), time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	records, err := c.lookup(ctx, "localhost")
+	require.NoError(t, err)
+	require.NotEmpty(t, records)
+	for _, record := range records {
+		require.Equal(t, time.Hour, record.ttl)
+	}
+}
@@ -0,0 +1,278 @@
+// Package fqdn keeps the FQDN IPSets referenced by FQDNNetworkPolicies up to date with the addresses their domain names resolve to.
+package fqdn
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultResolveInterval is how often the domain names are resolved
+	DefaultResolveInterval = 30 * time.Second
+	// DefaultMemberTTL is how long an address stays in an FQDN IPSet after the domain name last resolved to it,
+	// when the TTL of its record isn't known
+	DefaultMemberTTL = 5 * time.Minute
+
+	lookupTimeout = 10 * time.Second
+)
+
+type Config struct {
+	ResolveInterval time.Duration
+	// MemberTTL is how long an address stays in an FQDN IPSet after the domain name last resolved to it, when the
+	// TTL of its record isn't known. An address otherwise stays until its record expires, so that clients which
+	// still have an address that was just rotated out cached can keep connecting to it. The TTLs are only known
+	// when the names are resolved with the nameservers of resolv.conf, and the resolver of the Go standard library,
+	// which doesn't return them, is used when resolv.conf has no nameservers.
+	MemberTTL time.Duration
+}
+
+type lookupFunc func(ctx context.Context, host string) ([]dnsRecord, error)
+
+// Resolver periodically resolves the domain names referenced by FQDNNetworkPolicies.
+// Each domain name has an FQDN IPSet. Resolved addresses are added to the set,
+// and removed once their records have expired without the name resolving to them again.
+type Resolver struct {
+	sync.Mutex
+	Config
+	dp     dataplane.GenericDataplane
+	lookup lookupFunc
+	now    func() time.Time
+	// names has the state of each referenced domain name
+	names map[string]*fqdnState
+	// policyFQDNs has the domain names referenced by each policy key
+	policyFQDNs map[string][]string
+}
+
+type fqdnState struct {
+	// policyKeys are the policies referencing the domain name
+	policyKeys map[string]struct{}
+	// expiry is the time when each address in the IPSet expires
+	expiry map[string]time.Time
+}
+
+func NewResolver(dp dataplane.GenericDataplane, cfg Config) *Resolver {
+	if cfg.ResolveInterval <= 0 {
+		cfg.ResolveInterval = DefaultResolveInterval
+	}
+	if cfg.MemberTTL <= 0 {
+		cfg.MemberTTL = DefaultMemberTTL
+	}
+	return &Resolver{
+		Config:      cfg,
+		dp:          dp,
+		lookup:      newDNSClient(resolvConfPath, cfg.MemberTTL).lookup,
+		now:         time.Now,
+		names:       make(map[string]*fqdnState),
+		policyFQDNs: make(map[string][]string),
+	}
+}
+
+// setMetadata returns the metadata of the FQDN IPSet of the domain name
+func setMetadata(fqdn string) []*ipsets.IPSetMetadata {
+	return []*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(fqdn, ipsets.FQDN)}
+}
+
+// podMetadata returns the metadata for adding or removing an address.
+// The pod key is the same for all addresses of the set, so that no other owner's address is removed,
+// and the node name is empty, so that the dataplane doesn't look for an endpoint with the address.
+func podMetadata(fqdn, ip string) *dataplane.PodMetadata {
+	return dataplane.NewPodMetadata(util.FQDNPrefix+fqdn, ip, "")
+}
+
+// SetPolicyFQDNs records the domain names referenced by the policy, replacing the names it referenced before.
+// Newly referenced names are resolved right away, so that traffic to them is allowed once the policy is applied.
+// Like in resolveAll, the lookups are done without the lock.
+// The IPSets of names which are no longer referenced by any policy are emptied and deleted.
+// Call with no names when the policy is deleted, after removing the policy from the dataplane.
+func (r *Resolver) SetPolicyFQDNs(policyKey string, fqdns []string) error {
+	newNames, err := r.setPolicyFQDNs(policyKey, fqdns)
+	if err != nil {
+		return err
+	}
+
+	resolved := make(map[string][]dnsRecord, len(newNames))
+	for _, fqdn := range newNames {
+		records, err := r.lookupIPv4(fqdn)
+		if err != nil {
+			klog.Warningf("[FQDN] failed to resolve %s for policy %s, will retry: %s", fqdn, policyKey, err.Error())
+		}
+		resolved[fqdn] = records
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	for fqdn, records := range resolved {
+		state, ok := r.names[fqdn]
+		if !ok {
+			// no longer referenced
+			continue
+		}
+		if err := r.updateMembers(fqdn, state, records); err != nil {
+			return err
+		}
+	}
+
+	if err := r.dp.ApplyDataPlane(); err != nil {
+		return fmt.Errorf("[FQDN] failed to apply FQDN IPSets: %w", err)
+	}
+	return nil
+}
+
+// setPolicyFQDNs updates the references of the policy and returns the names which weren't referenced before.
+// Their IPSets are created even though the names aren't resolved yet.
+func (r *Resolver) setPolicyFQDNs(policyKey string, fqdns []string) ([]string, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	newNames := make([]string, 0)
+	for _, fqdn := range fqdns {
+		state, ok := r.names[fqdn]
+		if !ok {
+			state = &fqdnState{policyKeys: make(map[string]struct{}), expiry: make(map[string]time.Time)}
+			r.names[fqdn] = state
+			r.dp.CreateIPSets(setMetadata(fqdn))
+			newNames = append(newNames, fqdn)
+		}
+		state.policyKeys[policyKey] = struct{}{}
+	}
+
+	referenced := make(map[string]struct{}, len(fqdns))
+	for _, fqdn := range fqdns {
+		referenced[fqdn] = struct{}{}
+	}
+	for _, fqdn := range r.policyFQDNs[policyKey] {
+		if _, ok := referenced[fqdn]; ok {
+			continue
+		}
+		state := r.names[fqdn]
+		delete(state.policyKeys, policyKey)
+		if len(state.policyKeys) == 0 {
+			if err := r.removeFQDN(fqdn, state); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(fqdns) == 0 {
+		delete(r.policyFQDNs, policyKey)
+	} else {
+		r.policyFQDNs[policyKey] = fqdns
+	}
+	return newNames, nil
+}
+
+// removeFQDN empties and deletes the IPSet of a domain name which is no longer referenced.
+// Policy references to the set are removed by the dataplane, so the set is only deleted once no policy uses it.
+func (r *Resolver) removeFQDN(fqdn string, state *fqdnState) error {
+	for ip := range state.expiry {
+		if err := r.dp.RemoveFromSets(setMetadata(fqdn), podMetadata(fqdn, ip)); err != nil {
+			return fmt.Errorf("[FQDN] failed to remove %s from the IPSet of %s: %w", ip, fqdn, err)
+		}
+		delete(state.expiry, ip)
+	}
+	r.dp.DeleteIPSet(setMetadata(fqdn)[0], util.SoftDelete)
+	delete(r.names, fqdn)
+	return nil
+}
+
+// lookupIPv4 returns the IPv4 addresses which the domain name resolves to, since NPM only programs IPv4,
+// with the TTLs of their records
+func (r *Resolver) lookupIPv4(fqdn string) ([]dnsRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	records, err := r.lookup(ctx, fqdn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up addresses of %s: %w", fqdn, err)
+	}
+
+	ipv4Records := make([]dnsRecord, 0, len(records))
+	for _, record := range records {
+		if ip := record.ip.To4(); ip != nil {
+			ipv4Records = append(ipv4Records, dnsRecord{ip: ip, ttl: record.ttl})
+		}
+	}
+	return ipv4Records, nil
+}
+
+// updateMembers adds the resolved addresses to the IPSet of the domain name and refreshes their expiry to when
+// their records expire, then removes the addresses which expired. An address is only removed by the first resolve
+// after it expired, so that it's kept while the name still resolves to it.
+// Call with no addresses when the name can't be resolved.
+func (r *Resolver) updateMembers(fqdn string, state *fqdnState, records []dnsRecord) error {
+	now := r.now()
+	for _, record := range records {
+		ip := record.ip.String()
+		if _, ok := state.expiry[ip]; !ok {
+			if err := r.dp.AddToSets(setMetadata(fqdn), podMetadata(fqdn, ip)); err != nil {
+				return fmt.Errorf("[FQDN] failed to add %s to the IPSet of %s: %w", ip, fqdn, err)
+			}
+		}
+		if expiry := now.Add(record.ttl); expiry.After(state.expiry[ip]) {
+			state.expiry[ip] = expiry
+		}
+	}
+
+	for ip, expiry := range state.expiry {
+		if now.Before(expiry) {
+			continue
+		}
+		if err := r.dp.RemoveFromSets(setMetadata(fqdn), podMetadata(fqdn, ip)); err != nil {
+			return fmt.Errorf("[FQDN] failed to remove expired %s from the IPSet of %s: %w", ip, fqdn, err)
+		}
+		delete(state.expiry, ip)
+	}
+	return nil
+}
+
+// resolveAll resolves every referenced domain name, then applies the IPSets.
+// The lookups are done without the lock, so that they don't block policy updates.
+func (r *Resolver) resolveAll() {
+	r.Lock()
+	fqdns := make([]string, 0, len(r.names))
+	for fqdn := range r.names {
+		fqdns = append(fqdns, fqdn)
+	}
+	r.Unlock()
+
+	resolved := make(map[string][]dnsRecord, len(fqdns))
+	for _, fqdn := range fqdns {
+		records, err := r.lookupIPv4(fqdn)
+		if err != nil {
+			klog.Warningf("[FQDN] %s", err.Error())
+		}
+		resolved[fqdn] = records
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	for fqdn, records := range resolved {
+		state, ok := r.names[fqdn]
+		if !ok {
+			// no longer referenced
+			continue
+		}
+		if err := r.updateMembers(fqdn, state, records); err != nil {
+			metrics.SendErrorLogAndMetric(util.NetpolID, "error: %s", err.Error())
+		}
+	}
+
+	if err := r.dp.ApplyDataPlane(); err != nil {
+		metrics.SendErrorLogAndMetric(util.NetpolID, "error: [FQDN] failed to apply FQDN IPSets: %s", err.Error())
+	}
+}
+
+// Run resolves the domain names every resolve interval until the stop channel is closed
+func (r *Resolver) Run(stopCh <-chan struct{}) {
+	klog.Infof("Starting FQDN resolver with resolve interval %s and member TTL %s for records without TTLs", r.ResolveInterval, r.MemberTTL)
+	wait.Until(r.resolveAll, r.ResolveInterval, stopCh)
+	klog.Info("Shutting down FQDN resolver")
+}
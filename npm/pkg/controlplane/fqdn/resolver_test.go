@@ -0,0 +1,205 @@
+package fqdn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/stretchr/testify/require"
+)
+
+var errLookup = errors.New("no such host")
+
+// fakeDataplane records the members of the FQDN IPSets. Calling any other method panics.
+type fakeDataplane struct {
+	dataplane.GenericDataplane
+	sets    map[string]map[string]struct{}
+	applies int
+}
+
+func newFakeDataplane() *fakeDataplane {
+	return &fakeDataplane{sets: make(map[string]map[string]struct{})}
+}
+
+func (dp *fakeDataplane) CreateIPSets(setMetadatas []*ipsets.IPSetMetadata) {
+	for _, setMetadata := range setMetadatas {
+		if _, ok := dp.sets[setMetadata.GetPrefixName()]; !ok {
+			dp.sets[setMetadata.GetPrefixName()] = make(map[string]struct{})
+		}
+	}
+}
+
+func (dp *fakeDataplane) DeleteIPSet(setMetadata *ipsets.IPSetMetadata, _ util.DeleteOption) {
+	delete(dp.sets, setMetadata.GetPrefixName())
+}
+
+func (dp *fakeDataplane) AddToSets(setMetadatas []*ipsets.IPSetMetadata, podMetadata *dataplane.PodMetadata) error {
+	dp.CreateIPSets(setMetadatas)
+	for _, setMetadata := range setMetadatas {
+		dp.sets[setMetadata.GetPrefixName()][podMetadata.PodIP] = struct{}{}
+	}
+	return nil
+}
+
+func (dp *fakeDataplane) RemoveFromSets(setMetadatas []*ipsets.IPSetMetadata, podMetadata *dataplane.PodMetadata) error {
+	for _, setMetadata := range setMetadatas {
+		delete(dp.sets[setMetadata.GetPrefixName()], podMetadata.PodIP)
+	}
+	return nil
+}
+
+func (dp *fakeDataplane) ApplyDataPlane() error {
+	dp.applies++
+	return nil
+}
+
+// members returns the sorted members of the IPSet of the domain name, or nil if the set doesn't exist
+func (dp *fakeDataplane) members(fqdn string) []string {
+	set, ok := dp.sets[util.FQDNPrefix+fqdn]
+	if !ok {
+		return nil
+	}
+	ips := make([]string, 0, len(set))
+	for ip := range set {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// fakeRecordTTL is the TTL of the records answered by fakeDNS
+const fakeRecordTTL = time.Minute
+
+// fakeDNS answers lookups from a map, and fails for names which aren't in it
+type fakeDNS map[string][]string
+
+func (d fakeDNS) lookup(_ context.Context, host string) ([]dnsRecord, error) {
+	ips, ok := d[host]
+	if !ok {
+		return nil, errLookup
+	}
+	records := make([]dnsRecord, 0, len(ips))
+	for _, ip := range ips {
+		records = append(records, dnsRecord{ip: net.ParseIP(ip), ttl: fakeRecordTTL})
+	}
+	return records, nil
+}
+
+func newTestResolver(dp *fakeDataplane, dns fakeDNS, now *time.Time) *Resolver {
+	r := NewResolver(dp, Config{ResolveInterval: time.Second, MemberTTL: time.Hour})
+	r.lookup = dns.lookup
+	r.now = func() time.Time { return *now }
+	return r
+}
+
+func TestSetPolicyFQDNs(t *testing.T) {
+	now := time.Unix(1000, 0)
+	dp := newFakeDataplane()
+	dns := fakeDNS{
+		"api.example.com":   {"20.0.0.1", "20.0.0.2", "2001:db8::1"},
+		"login.example.com": {"20.0.0.3"},
+	}
+	r := newTestResolver(dp, dns, &now)
+
+	// new names are resolved right away, and IPv6 addresses are ignored
+	require.NoError(t, r.SetPolicyFQDNs("FQDNNetworkPolicy/x/a", []string{"api.example.com", "login.example.com"}))
+	require.Equal(t, []string{"20.0.0.1", "20.0.0.2"}, dp.members("api.example.com"))
+	require.Equal(t, []string{"20.0.0.3"}, dp.members("login.example.com"))
+
+	// a name which can't be resolved yet still has a set
+	require.NoError(t, r.SetPolicyFQDNs("FQDNNetworkPolicy/y/b", []string{"api.example.com", "unknown.example.com"}))
+	require.Equal(t, []string{}, dp.members("unknown.example.com"))
+
+	// names no longer referenced by any policy are removed
+	require.NoError(t, r.SetPolicyFQDNs("FQDNNetworkPolicy/x/a", []string{"api.example.com"}))
+	require.Nil(t, dp.members("login.example.com"))
+	require.Equal(t, []string{"20.0.0.1", "20.0.0.2"}, dp.members("api.example.com"))
+
+	// names still referenced by another policy are kept
+	require.NoError(t, r.SetPolicyFQDNs("FQDNNetworkPolicy/x/a", nil))
+	require.Equal(t, []string{"20.0.0.1", "20.0.0.2"}, dp.members("api.example.com"))
+
+	require.NoError(t, r.SetPolicyFQDNs("FQDNNetworkPolicy/y/b", nil))
+	require.Empty(t, dp.sets)
+	require.Empty(t, r.names)
+	require.Empty(t, r.policyFQDNs)
+}
+
+func TestSetPolicyFQDNsResolvesWithoutLock(t *testing.T) {
+	now := time.Unix(1000, 0)
+	dp := newFakeDataplane()
+	dns := fakeDNS{"api.example.com": {"20.0.0.1"}}
+	r := newTestResolver(dp, dns, &now)
+
+	locked := false
+	r.lookup = func(ctx context.Context, host string) ([]dnsRecord, error) {
+		if r.TryLock() {
+			r.Unlock()
+		} else {
+			locked = true
+		}
+		return dns.lookup(ctx, host)
+	}
+
+	require.NoError(t, r.SetPolicyFQDNs("FQDNNetworkPolicy/x/a", []string{"api.example.com"}))
+	require.False(t, locked, "the lock was held while resolving")
+	require.Equal(t, []string{"20.0.0.1"}, dp.members("api.example.com"))
+}
+
+func TestResolveAllExpiresMembers(t *testing.T) {
+	now := time.Unix(1000, 0)
+	dp := newFakeDataplane()
+	dns := fakeDNS{"api.example.com": {"20.0.0.1"}}
+	r := newTestResolver(dp, dns, &now)
+	require.NoError(t, r.SetPolicyFQDNs("FQDNNetworkPolicy/x/a", []string{"api.example.com"}))
+
+	// the name rotates to a new address, and the old one is kept until it expires
+	dns["api.example.com"] = []string{"20.0.0.2"}
+	now = now.Add(30 * time.Second)
+	r.resolveAll()
+	require.Equal(t, []string{"20.0.0.1", "20.0.0.2"}, dp.members("api.example.com"))
+
+	now = now.Add(30 * time.Second)
+	r.resolveAll()
+	require.Equal(t, []string{"20.0.0.2"}, dp.members("api.example.com"))
+
+	// addresses expire even when the name can't be resolved
+	delete(dns, "api.example.com")
+	now = now.Add(time.Minute)
+	r.resolveAll()
+	require.Equal(t, []string{}, dp.members("api.example.com"))
+	require.Equal(t, 4, dp.applies)
+}
+
+func TestResolveAllExpiresMembersWithTheirRecords(t *testing.T) {
+	now := time.Unix(1000, 0)
+	dp := newFakeDataplane()
+	r := newTestResolver(dp, fakeDNS{}, &now)
+	ttls := map[string]time.Duration{"20.0.0.1": 10 * time.Second, "20.0.0.2": 5 * time.Minute}
+	r.lookup = func(_ context.Context, _ string) ([]dnsRecord, error) {
+		records := make([]dnsRecord, 0, len(ttls))
+		for ip, ttl := range ttls {
+			records = append(records, dnsRecord{ip: net.ParseIP(ip), ttl: ttl})
+		}
+		return records, nil
+	}
+	require.NoError(t, r.SetPolicyFQDNs("FQDNNetworkPolicy/x/a", []string{"api.example.com"}))
+	require.Equal(t, []string{"20.0.0.1", "20.0.0.2"}, dp.members("api.example.com"))
+
+	// the name no longer resolves to either address, and each is kept until its own record expires,
+	// instead of for the member TTL
+	ttls = map[string]time.Duration{}
+	now = now.Add(30 * time.Second)
+	r.resolveAll()
+	require.Equal(t, []string{"20.0.0.2"}, dp.members("api.example.com"))
+
+	now = now.Add(5 * time.Minute)
+	r.resolveAll()
+	require.Equal(t, []string{}, dp.members("api.example.com"))
+}
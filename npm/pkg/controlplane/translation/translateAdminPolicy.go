@@ -0,0 +1,283 @@
+package translation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/apis/adminnetworkpolicy/v1alpha1"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var (
+	// ErrUnsupportedAdminSubject is returned when the subject of an admin policy can't be matched by one jump to the policy chain,
+	// e.g. a namespace selector with a multi-value In or NotIn expression.
+	ErrUnsupportedAdminSubject = errors.New("unsupported subject in admin network policy")
+	// ErrUnsupportedAdminPeer is returned when a peer of an admin policy selects neither namespaces nor pods, e.g. nodes or networks.
+	ErrUnsupportedAdminPeer = errors.New("unsupported peer in admin network policy")
+	errUnknownAdminPort     = errors.New("admin network policy port has no port number, named port, or port range")
+	errUnknownAdminAction   = errors.New("unknown action in admin network policy rule")
+	errInvalidAdminPriority = errors.New("invalid priority for admin network policy")
+)
+
+const (
+	// the kinds used as the first part of the policy keys of admin policies.
+	// Namespace names can't have upper case letters, so these keys never collide with the keys of NetworkPolicies.
+	adminPolicyKeyPrefix         = "AdminNetworkPolicy"
+	baselineAdminPolicyKeyPrefix = "BaselineAdminNetworkPolicy"
+
+	maxAdminPolicyPriority = 1000
+)
+
+// AdminPolicyKey returns the policy key of the AdminNetworkPolicy with the name
+func AdminPolicyKey(name string) string {
+	return fmt.Sprintf("%s/%s", adminPolicyKeyPrefix, name)
+}
+
+// BaselineAdminPolicyKey returns the policy key of the BaselineAdminNetworkPolicy with the name
+func BaselineAdminPolicyKey(name string) string {
+	return fmt.Sprintf("%s/%s", baselineAdminPolicyKeyPrefix, name)
+}
+
+// adminPeer holds the fields shared by ingress and egress peers of admin policies
+type adminPeer struct {
+	namespaces *metav1.LabelSelector
+	pods       *v1alpha1.NamespacedPod
+}
+
+func ingressAdminPeers(from []v1alpha1.AdminNetworkPolicyIngressPeer) []adminPeer {
+	peers := make([]adminPeer, 0, len(from))
+	for _, peer := range from {
+		peers = append(peers, adminPeer{namespaces: peer.Namespaces, pods: peer.Pods})
+	}
+	return peers
+}
+
+func egressAdminPeers(to []v1alpha1.AdminNetworkPolicyEgressPeer) []adminPeer {
+	peers := make([]adminPeer, 0, len(to))
+	for _, peer := range to {
+		peers = append(peers, adminPeer{namespaces: peer.Namespaces, pods: peer.Pods})
+	}
+	return peers
+}
+
+// adminPorts converts the ports of an admin policy rule into NetworkPolicyPorts, so that they're translated like the ports of a NetworkPolicy.
+func adminPorts(ports *[]v1alpha1.AdminNetworkPolicyPort) ([]networkingv1.NetworkPolicyPort, error) {
+	if ports == nil {
+		return nil, nil
+	}
+
+	netpolPorts := make([]networkingv1.NetworkPolicyPort, 0, len(*ports))
+	for _, port := range *ports {
+		netpolPort := networkingv1.NetworkPolicyPort{}
+		switch {
+		case port.PortNumber != nil:
+			portNumber := intstr.FromInt(int(port.PortNumber.Port))
+			netpolPort.Port = &portNumber
+			if port.PortNumber.Protocol != "" {
+				protocol := port.PortNumber.Protocol
+				netpolPort.Protocol = &protocol
+			}
+		case port.NamedPort != nil:
+			namedPort := intstr.FromString(*port.NamedPort)
+			netpolPort.Port = &namedPort
+		case port.PortRange != nil:
+			start := intstr.FromInt(int(port.PortRange.Start))
+			end := port.PortRange.End
+			netpolPort.Port = &start
+			netpolPort.EndPort = &end
+			if port.PortRange.Protocol != "" {
+				protocol := port.PortRange.Protocol
+				netpolPort.Protocol = &protocol
+			}
+		default:
+			return nil, errUnknownAdminPort
+		}
+		netpolPorts = append(netpolPorts, netpolPort)
+	}
+	return netpolPorts, nil
+}
+
+// adminNameSpaceSelector translates a namespace selector which must be matched by one list of SetInfos.
+func adminNameSpaceSelector(matchType policies.MatchType, selector *metav1.LabelSelector) ([]*ipsets.TranslatedIPSet, []policies.SetInfo, error) {
+	flattenNSSelector := flattenNameSpaceSelector(selector)
+	if len(flattenNSSelector) != 1 {
+		return nil, nil, ErrUnsupportedAdminSubject
+	}
+	nsSelectorIPSets, nsSelectorList := nameSpaceSelector(matchType, &flattenNSSelector[0])
+	return nsSelectorIPSets, nsSelectorList, nil
+}
+
+// adminSubject translates the subject of an admin policy into the pod selector of npmNetPol.
+func adminSubject(npmNetPol *policies.NPMNetworkPolicy, subject *v1alpha1.AdminNetworkPolicySubject) error {
+	switch {
+	case subject.Namespaces != nil:
+		nsSelectorIPSets, nsSelectorList, err := adminNameSpaceSelector(policies.EitherMatch, subject.Namespaces)
+		if err != nil {
+			return err
+		}
+		npmNetPol.PodSelectorIPSets = nsSelectorIPSets
+		npmNetPol.PodSelectorList = nsSelectorList
+	case subject.Pods != nil:
+		podSelectorIPSets, podSelectorList, err := podSelector(policies.EitherMatch, &subject.Pods.PodSelector)
+		if err != nil {
+			return err
+		}
+		nsSelectorIPSets, nsSelectorList, err := adminNameSpaceSelector(policies.EitherMatch, &subject.Pods.NamespaceSelector)
+		if err != nil {
+			return err
+		}
+		npmNetPol.PodSelectorIPSets = append(podSelectorIPSets, nsSelectorIPSets...)
+		npmNetPol.PodSelectorList = append(podSelectorList, nsSelectorList...)
+	default:
+		return ErrUnsupportedAdminSubject
+	}
+	return nil
+}
+
+// translateAdminRule adds ACLs with the verdict for the peers and ports of an admin policy rule.
+// The ACLs are in the order of the rules, so that the first matching rule decides the verdict.
+func translateAdminRule(npmNetPol *policies.NPMNetworkPolicy, direction policies.Direction, matchType policies.MatchType, verdict policies.Verdict,
+	ports *[]v1alpha1.AdminNetworkPolicyPort, peers []adminPeer) error {
+	netpolPorts, err := adminPorts(ports)
+	if err != nil {
+		return err
+	}
+
+	firstACLIndex := len(npmNetPol.ACLs)
+	for _, peer := range peers {
+		switch {
+		case peer.namespaces != nil:
+			// namespaces with multiple values are matched by one ACL for each value
+			flattenNSSelector := flattenNameSpaceSelector(peer.namespaces)
+			for i := range flattenNSSelector {
+				nsSelectorIPSets, nsSelectorList := nameSpaceSelector(matchType, &flattenNSSelector[i])
+				npmNetPol.RuleIPSets = append(npmNetPol.RuleIPSets, nsSelectorIPSets...)
+				if err := peerAndPortRule(npmNetPol, direction, netpolPorts, nsSelectorList); err != nil {
+					return err
+				}
+			}
+		case peer.pods != nil:
+			podSelectorIPSets, podSelectorList, err := podSelector(matchType, &peer.pods.PodSelector)
+			if err != nil {
+				return err
+			}
+			npmNetPol.RuleIPSets = append(npmNetPol.RuleIPSets, podSelectorIPSets...)
+
+			flattenNSSelector := flattenNameSpaceSelector(&peer.pods.NamespaceSelector)
+			for i := range flattenNSSelector {
+				nsSelectorIPSets, nsSelectorList := nameSpaceSelector(matchType, &flattenNSSelector[i])
+				npmNetPol.RuleIPSets = append(npmNetPol.RuleIPSets, nsSelectorIPSets...)
+				nsSelectorList = append(nsSelectorList, podSelectorList...)
+				if err := peerAndPortRule(npmNetPol, direction, netpolPorts, nsSelectorList); err != nil {
+					return err
+				}
+			}
+		default:
+			return ErrUnsupportedAdminPeer
+		}
+	}
+
+	// peerAndPortRule only creates ACLs which allow traffic
+	for _, acl := range npmNetPol.ACLs[firstACLIndex:] {
+		acl.Target = verdict
+	}
+	return nil
+}
+
+func adminVerdict(action v1alpha1.AdminNetworkPolicyRuleAction) (policies.Verdict, error) {
+	switch action {
+	case v1alpha1.AdminNetworkPolicyRuleActionAllow:
+		return policies.Allowed, nil
+	case v1alpha1.AdminNetworkPolicyRuleActionDeny:
+		return policies.Dropped, nil
+	case v1alpha1.AdminNetworkPolicyRuleActionPass:
+		return policies.Passed, nil
+	default:
+		return "", fmt.Errorf("%w: %s", errUnknownAdminAction, action)
+	}
+}
+
+func baselineAdminVerdict(action v1alpha1.BaselineAdminNetworkPolicyRuleAction) (policies.Verdict, error) {
+	switch action {
+	case v1alpha1.BaselineAdminNetworkPolicyRuleActionAllow:
+		return policies.Allowed, nil
+	case v1alpha1.BaselineAdminNetworkPolicyRuleActionDeny:
+		return policies.Dropped, nil
+	default:
+		return "", fmt.Errorf("%w: %s", errUnknownAdminAction, action)
+	}
+}
+
+// TranslateAdminPolicy translates an AdminNetworkPolicy into an NPMNetworkPolicy in the admin tier.
+// Unlike NetworkPolicies, there are no default drop ACLs, since traffic which matches no rule is decided by the next policies.
+func TranslateAdminPolicy(anp *v1alpha1.AdminNetworkPolicy) (*policies.NPMNetworkPolicy, error) {
+	if anp.Spec.Priority < 0 || anp.Spec.Priority > maxAdminPolicyPriority {
+		return nil, fmt.Errorf("%w: %d", errInvalidAdminPriority, anp.Spec.Priority)
+	}
+
+	npmNetPol := &policies.NPMNetworkPolicy{
+		Name:      anp.Name,
+		PolicyKey: AdminPolicyKey(anp.Name),
+		Tier:      policies.AdminTier,
+		Priority:  anp.Spec.Priority,
+	}
+	if err := adminSubject(npmNetPol, &anp.Spec.Subject); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range anp.Spec.Ingress {
+		verdict, err := adminVerdict(rule.Action)
+		if err != nil {
+			return nil, err
+		}
+		if err := translateAdminRule(npmNetPol, policies.Ingress, policies.SrcMatch, verdict, rule.Ports, ingressAdminPeers(rule.From)); err != nil {
+			return nil, err
+		}
+	}
+	for _, rule := range anp.Spec.Egress {
+		verdict, err := adminVerdict(rule.Action)
+		if err != nil {
+			return nil, err
+		}
+		if err := translateAdminRule(npmNetPol, policies.Egress, policies.DstMatch, verdict, rule.Ports, egressAdminPeers(rule.To)); err != nil {
+			return nil, err
+		}
+	}
+	return npmNetPol, nil
+}
+
+// TranslateBaselineAdminPolicy translates a BaselineAdminNetworkPolicy into an NPMNetworkPolicy in the baseline admin tier.
+func TranslateBaselineAdminPolicy(banp *v1alpha1.BaselineAdminNetworkPolicy) (*policies.NPMNetworkPolicy, error) {
+	npmNetPol := &policies.NPMNetworkPolicy{
+		Name:      banp.Name,
+		PolicyKey: BaselineAdminPolicyKey(banp.Name),
+		Tier:      policies.BaselineAdminTier,
+	}
+	if err := adminSubject(npmNetPol, &banp.Spec.Subject); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range banp.Spec.Ingress {
+		verdict, err := baselineAdminVerdict(rule.Action)
+		if err != nil {
+			return nil, err
+		}
+		if err := translateAdminRule(npmNetPol, policies.Ingress, policies.SrcMatch, verdict, rule.Ports, ingressAdminPeers(rule.From)); err != nil {
+			return nil, err
+		}
+	}
+	for _, rule := range banp.Spec.Egress {
+		verdict, err := baselineAdminVerdict(rule.Action)
+		if err != nil {
+			return nil, err
+		}
+		if err := translateAdminRule(npmNetPol, policies.Egress, policies.DstMatch, verdict, rule.Ports, egressAdminPeers(rule.To)); err != nil {
+			return nil, err
+		}
+	}
+	return npmNetPol, nil
+}
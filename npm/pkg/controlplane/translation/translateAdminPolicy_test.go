@@ -0,0 +1,254 @@
+package translation
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/apis/adminnetworkpolicy/v1alpha1"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTranslateAdminPolicy(t *testing.T) {
+	namespaceSubject := v1alpha1.AdminNetworkPolicySubject{
+		Namespaces: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+	}
+	tests := []struct {
+		name      string
+		anp       *v1alpha1.AdminNetworkPolicy
+		npmNetPol *policies.NPMNetworkPolicy
+		wantErr   bool
+	}{
+		{
+			name: "deny ingress from namespaces and pass egress to pods",
+			anp: &v1alpha1.AdminNetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "guardrail"},
+				Spec: v1alpha1.AdminNetworkPolicySpec{
+					Priority: 10,
+					Subject:  namespaceSubject,
+					Ingress: []v1alpha1.AdminNetworkPolicyIngressRule{
+						{
+							Action: v1alpha1.AdminNetworkPolicyRuleActionDeny,
+							From: []v1alpha1.AdminNetworkPolicyIngressPeer{
+								{Namespaces: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "b"}}},
+							},
+							Ports: &[]v1alpha1.AdminNetworkPolicyPort{
+								{PortNumber: &v1alpha1.Port{Protocol: v1.ProtocolTCP, Port: 80}},
+							},
+						},
+					},
+					Egress: []v1alpha1.AdminNetworkPolicyEgressRule{
+						{
+							Action: v1alpha1.AdminNetworkPolicyRuleActionPass,
+							To: []v1alpha1.AdminNetworkPolicyEgressPeer{
+								{
+									Pods: &v1alpha1.NamespacedPod{
+										PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+									},
+								},
+							},
+							Ports: &[]v1alpha1.AdminNetworkPolicyPort{
+								{PortRange: &v1alpha1.PortRange{Protocol: v1.ProtocolUDP, Start: 53, End: 60}},
+							},
+						},
+						{
+							Action: v1alpha1.AdminNetworkPolicyRuleActionAllow,
+							To: []v1alpha1.AdminNetworkPolicyEgressPeer{
+								{Namespaces: &metav1.LabelSelector{}},
+							},
+						},
+					},
+				},
+			},
+			npmNetPol: &policies.NPMNetworkPolicy{
+				Name:      "guardrail",
+				PolicyKey: "AdminNetworkPolicy/guardrail",
+				Tier:      policies.AdminTier,
+				Priority:  10,
+				PodSelectorIPSets: []*ipsets.TranslatedIPSet{
+					ipsets.NewTranslatedIPSet("team:a", ipsets.KeyValueLabelOfNamespace),
+				},
+				PodSelectorList: []policies.SetInfo{
+					policies.NewSetInfo("team:a", ipsets.KeyValueLabelOfNamespace, included, policies.EitherMatch),
+				},
+				RuleIPSets: []*ipsets.TranslatedIPSet{
+					ipsets.NewTranslatedIPSet("team:b", ipsets.KeyValueLabelOfNamespace),
+					ipsets.NewTranslatedIPSet("app:db", ipsets.KeyValueLabelOfPod),
+					ipsets.NewTranslatedIPSet(util.KubeAllNamespacesFlag, ipsets.KeyLabelOfNamespace),
+					ipsets.NewTranslatedIPSet(util.KubeAllNamespacesFlag, ipsets.KeyLabelOfNamespace),
+				},
+				ACLs: []*policies.ACLPolicy{
+					{
+						PolicyID:  "azure-acl--guardrail",
+						Target:    policies.Dropped,
+						Direction: policies.Ingress,
+						SrcList: []policies.SetInfo{
+							policies.NewSetInfo("team:b", ipsets.KeyValueLabelOfNamespace, included, policies.SrcMatch),
+						},
+						DstPorts: policies.Ports{Port: 80},
+						Protocol: "TCP",
+					},
+					{
+						PolicyID:  "azure-acl--guardrail",
+						Target:    policies.Passed,
+						Direction: policies.Egress,
+						DstList: []policies.SetInfo{
+							policies.NewSetInfo(util.KubeAllNamespacesFlag, ipsets.KeyLabelOfNamespace, included, policies.DstMatch),
+							policies.NewSetInfo("app:db", ipsets.KeyValueLabelOfPod, included, policies.DstMatch),
+						},
+						DstPorts: policies.Ports{Port: 53, EndPort: 60},
+						Protocol: "UDP",
+					},
+					{
+						PolicyID:  "azure-acl--guardrail",
+						Target:    policies.Allowed,
+						Direction: policies.Egress,
+						DstList: []policies.SetInfo{
+							policies.NewSetInfo(util.KubeAllNamespacesFlag, ipsets.KeyLabelOfNamespace, included, policies.DstMatch),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "pods subject",
+			anp: &v1alpha1.AdminNetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "pods"},
+				Spec: v1alpha1.AdminNetworkPolicySpec{
+					Subject: v1alpha1.AdminNetworkPolicySubject{
+						Pods: &v1alpha1.NamespacedPod{
+							NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+							PodSelector:       metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+						},
+					},
+				},
+			},
+			npmNetPol: &policies.NPMNetworkPolicy{
+				Name:      "pods",
+				PolicyKey: "AdminNetworkPolicy/pods",
+				Tier:      policies.AdminTier,
+				PodSelectorIPSets: []*ipsets.TranslatedIPSet{
+					ipsets.NewTranslatedIPSet("app:web", ipsets.KeyValueLabelOfPod),
+					ipsets.NewTranslatedIPSet("team:a", ipsets.KeyValueLabelOfNamespace),
+				},
+				PodSelectorList: []policies.SetInfo{
+					policies.NewSetInfo("app:web", ipsets.KeyValueLabelOfPod, included, policies.EitherMatch),
+					policies.NewSetInfo("team:a", ipsets.KeyValueLabelOfNamespace, included, policies.EitherMatch),
+				},
+			},
+		},
+		{
+			name: "priority out of range",
+			anp: &v1alpha1.AdminNetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "bad-priority"},
+				Spec:       v1alpha1.AdminNetworkPolicySpec{Priority: 1001, Subject: namespaceSubject},
+			},
+			wantErr: true,
+		},
+		{
+			name: "subject with multiple values",
+			anp: &v1alpha1.AdminNetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "multi-value"},
+				Spec: v1alpha1.AdminNetworkPolicySpec{
+					Subject: v1alpha1.AdminNetworkPolicySubject{
+						Namespaces: &metav1.LabelSelector{
+							MatchExpressions: []metav1.LabelSelectorRequirement{
+								{Key: "team", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported peer",
+			anp: &v1alpha1.AdminNetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "networks"},
+				Spec: v1alpha1.AdminNetworkPolicySpec{
+					Subject: namespaceSubject,
+					Egress: []v1alpha1.AdminNetworkPolicyEgressRule{
+						{Action: v1alpha1.AdminNetworkPolicyRuleActionDeny, To: []v1alpha1.AdminNetworkPolicyEgressPeer{{}}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown action",
+			anp: &v1alpha1.AdminNetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "unknown-action"},
+				Spec: v1alpha1.AdminNetworkPolicySpec{
+					Subject: namespaceSubject,
+					Ingress: []v1alpha1.AdminNetworkPolicyIngressRule{
+						{Action: "Log", From: []v1alpha1.AdminNetworkPolicyIngressPeer{{Namespaces: &metav1.LabelSelector{}}}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			npmNetPol, err := TranslateAdminPolicy(tt.anp)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.npmNetPol, npmNetPol)
+		})
+	}
+}
+
+func TestTranslateBaselineAdminPolicy(t *testing.T) {
+	banp := &v1alpha1.BaselineAdminNetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec: v1alpha1.BaselineAdminNetworkPolicySpec{
+			Subject: v1alpha1.AdminNetworkPolicySubject{Namespaces: &metav1.LabelSelector{}},
+			Ingress: []v1alpha1.BaselineAdminNetworkPolicyIngressRule{
+				{
+					Action: v1alpha1.BaselineAdminNetworkPolicyRuleActionDeny,
+					From:   []v1alpha1.AdminNetworkPolicyIngressPeer{{Namespaces: &metav1.LabelSelector{}}},
+				},
+			},
+		},
+	}
+	expected := &policies.NPMNetworkPolicy{
+		Name:      "default",
+		PolicyKey: "BaselineAdminNetworkPolicy/default",
+		Tier:      policies.BaselineAdminTier,
+		PodSelectorIPSets: []*ipsets.TranslatedIPSet{
+			ipsets.NewTranslatedIPSet(util.KubeAllNamespacesFlag, ipsets.KeyLabelOfNamespace),
+		},
+		PodSelectorList: []policies.SetInfo{
+			policies.NewSetInfo(util.KubeAllNamespacesFlag, ipsets.KeyLabelOfNamespace, included, policies.EitherMatch),
+		},
+		RuleIPSets: []*ipsets.TranslatedIPSet{
+			ipsets.NewTranslatedIPSet(util.KubeAllNamespacesFlag, ipsets.KeyLabelOfNamespace),
+		},
+		ACLs: []*policies.ACLPolicy{
+			{
+				PolicyID:  "azure-acl--default",
+				Target:    policies.Dropped,
+				Direction: policies.Ingress,
+				SrcList: []policies.SetInfo{
+					policies.NewSetInfo(util.KubeAllNamespacesFlag, ipsets.KeyLabelOfNamespace, included, policies.SrcMatch),
+				},
+			},
+		},
+	}
+	npmNetPol, err := TranslateBaselineAdminPolicy(banp)
+	require.NoError(t, err)
+	require.Equal(t, expected, npmNetPol)
+
+	// baseline policies can't pass
+	banp.Spec.Ingress[0].Action = "Pass"
+	_, err = TranslateBaselineAdminPolicy(banp)
+	require.Error(t, err)
+}
@@ -0,0 +1,96 @@
+package translation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/apis/fqdnnetworkpolicy/v1alpha1"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var (
+	// ErrInvalidFQDN is returned when a peer of an FQDN policy isn't a valid domain name, e.g. a wildcard.
+	ErrInvalidFQDN = errors.New("invalid FQDN in FQDN network policy")
+	// ErrUnsupportedFQDNNamedPort is returned when a rule of an FQDN policy has a named port, which only Pods have.
+	ErrUnsupportedFQDNNamedPort = errors.New("unsupported named port in FQDN network policy")
+)
+
+// the kind used as the first part of the policy keys of FQDN policies.
+// Namespace names can't have upper case letters, so these keys never collide with the keys of NetworkPolicies.
+const fqdnPolicyKeyPrefix = "FQDNNetworkPolicy"
+
+// FQDNPolicyKey returns the policy key of the FQDNNetworkPolicy with the namespace and name
+func FQDNPolicyKey(namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", fqdnPolicyKeyPrefix, namespace, name)
+}
+
+// normalizeFQDN lower cases the domain name and removes the trailing dot of the root domain,
+// so that names which resolve the same way share one IPSet.
+func normalizeFQDN(fqdn string) (string, error) {
+	normalized := strings.TrimSuffix(strings.ToLower(fqdn), ".")
+	if errs := validation.IsDNS1123Subdomain(normalized); len(errs) > 0 {
+		return "", fmt.Errorf("%w: %s: %s", ErrInvalidFQDN, fqdn, strings.Join(errs, ", "))
+	}
+	return normalized, nil
+}
+
+// FQDNsOfPolicy returns the domain names whose FQDN IPSets are referenced by the translated policy
+func FQDNsOfPolicy(npmNetPol *policies.NPMNetworkPolicy) []string {
+	fqdns := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, set := range npmNetPol.RuleIPSets {
+		if set.Metadata.Type != ipsets.FQDN {
+			continue
+		}
+		if _, ok := seen[set.Metadata.Name]; ok {
+			continue
+		}
+		seen[set.Metadata.Name] = struct{}{}
+		fqdns = append(fqdns, set.Metadata.Name)
+	}
+	return fqdns
+}
+
+// TranslateFQDNPolicy translates an FQDNNetworkPolicy into an NPMNetworkPolicy with egress rules to FQDN IPSets.
+// The IPSets are created without members, since the FQDN resolver keeps their members up to date.
+func TranslateFQDNPolicy(fqdnPol *v1alpha1.FQDNNetworkPolicy) (*policies.NPMNetworkPolicy, error) {
+	npmNetPol := policies.NewNPMNetworkPolicy(fqdnPol.Name, fqdnPol.Namespace)
+	npmNetPol.PolicyKey = FQDNPolicyKey(fqdnPol.Namespace, fqdnPol.Name)
+
+	var err error
+	npmNetPol.PodSelectorIPSets, npmNetPol.PodSelectorList, err = podSelectorWithNS(npmNetPol.NameSpace, policies.EitherMatch, &fqdnPol.Spec.PodSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range fqdnPol.Spec.Egress {
+		for i := range rule.Ports {
+			portKind, err := portType(rule.Ports[i])
+			if err != nil {
+				return nil, err
+			}
+			if portKind == namedPortType {
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedFQDNNamedPort, rule.Ports[i].Port.String())
+			}
+		}
+
+		for _, peer := range rule.To {
+			fqdn, err := normalizeFQDN(peer.FQDN)
+			if err != nil {
+				return nil, err
+			}
+			npmNetPol.RuleIPSets = append(npmNetPol.RuleIPSets, ipsets.NewTranslatedIPSet(fqdn, ipsets.FQDN))
+			setInfo := policies.NewSetInfo(fqdn, ipsets.FQDN, included, policies.DstMatch)
+			if err := peerAndPortRule(npmNetPol, policies.Egress, rule.Ports, []policies.SetInfo{setInfo}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// like NetworkPolicies, drop the rest of the egress traffic of the selected Pods
+	npmNetPol.ACLs = append(npmNetPol.ACLs, defaultDropACL(npmNetPol.NameSpace, npmNetPol.Name, policies.Egress))
+	return npmNetPol, nil
+}
@@ -0,0 +1,161 @@
+package translation
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/apis/fqdnnetworkpolicy/v1alpha1"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestTranslateFQDNPolicy(t *testing.T) {
+	tcp := v1.ProtocolTCP
+	port443 := intstr.FromInt(443)
+	namedPort := intstr.FromString("https")
+	webSelector := metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+
+	tests := []struct {
+		name      string
+		spec      v1alpha1.FQDNNetworkPolicySpec
+		npmNetPol *policies.NPMNetworkPolicy
+		fqdns     []string
+		wantErr   bool
+	}{
+		{
+			name: "fqdns with and without ports",
+			spec: v1alpha1.FQDNNetworkPolicySpec{
+				PodSelector: webSelector,
+				Egress: []v1alpha1.FQDNNetworkPolicyEgressRule{
+					{
+						Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port443}},
+						To:    []v1alpha1.FQDNNetworkPolicyPeer{{FQDN: "API.example.com."}},
+					},
+					{
+						To: []v1alpha1.FQDNNetworkPolicyPeer{{FQDN: "login.example.com"}, {FQDN: "api.example.com"}},
+					},
+				},
+			},
+			npmNetPol: &policies.NPMNetworkPolicy{
+				Name:      "saas",
+				NameSpace: "x",
+				PolicyKey: "FQDNNetworkPolicy/x/saas",
+				PodSelectorIPSets: []*ipsets.TranslatedIPSet{
+					ipsets.NewTranslatedIPSet("app:web", ipsets.KeyValueLabelOfPod),
+					ipsets.NewTranslatedIPSet("x", ipsets.Namespace),
+				},
+				PodSelectorList: []policies.SetInfo{
+					policies.NewSetInfo("app:web", ipsets.KeyValueLabelOfPod, included, policies.EitherMatch),
+					policies.NewSetInfo("x", ipsets.Namespace, included, policies.EitherMatch),
+				},
+				RuleIPSets: []*ipsets.TranslatedIPSet{
+					ipsets.NewTranslatedIPSet("api.example.com", ipsets.FQDN),
+					ipsets.NewTranslatedIPSet("login.example.com", ipsets.FQDN),
+					ipsets.NewTranslatedIPSet("api.example.com", ipsets.FQDN),
+				},
+				ACLs: []*policies.ACLPolicy{
+					{
+						PolicyID:  "azure-acl-x-saas",
+						Target:    policies.Allowed,
+						Direction: policies.Egress,
+						DstList: []policies.SetInfo{
+							policies.NewSetInfo("api.example.com", ipsets.FQDN, included, policies.DstMatch),
+						},
+						DstPorts: policies.Ports{Port: 443},
+						Protocol: "TCP",
+					},
+					{
+						PolicyID:  "azure-acl-x-saas",
+						Target:    policies.Allowed,
+						Direction: policies.Egress,
+						DstList: []policies.SetInfo{
+							policies.NewSetInfo("login.example.com", ipsets.FQDN, included, policies.DstMatch),
+						},
+					},
+					{
+						PolicyID:  "azure-acl-x-saas",
+						Target:    policies.Allowed,
+						Direction: policies.Egress,
+						DstList: []policies.SetInfo{
+							policies.NewSetInfo("api.example.com", ipsets.FQDN, included, policies.DstMatch),
+						},
+					},
+					{
+						PolicyID:  "azure-acl-x-saas",
+						Target:    policies.Dropped,
+						Direction: policies.Egress,
+					},
+				},
+			},
+			fqdns: []string{"api.example.com", "login.example.com"},
+		},
+		{
+			name: "no rules drops all egress",
+			spec: v1alpha1.FQDNNetworkPolicySpec{PodSelector: webSelector},
+			npmNetPol: &policies.NPMNetworkPolicy{
+				Name:      "saas",
+				NameSpace: "x",
+				PolicyKey: "FQDNNetworkPolicy/x/saas",
+				PodSelectorIPSets: []*ipsets.TranslatedIPSet{
+					ipsets.NewTranslatedIPSet("app:web", ipsets.KeyValueLabelOfPod),
+					ipsets.NewTranslatedIPSet("x", ipsets.Namespace),
+				},
+				PodSelectorList: []policies.SetInfo{
+					policies.NewSetInfo("app:web", ipsets.KeyValueLabelOfPod, included, policies.EitherMatch),
+					policies.NewSetInfo("x", ipsets.Namespace, included, policies.EitherMatch),
+				},
+				ACLs: []*policies.ACLPolicy{
+					{
+						PolicyID:  "azure-acl-x-saas",
+						Target:    policies.Dropped,
+						Direction: policies.Egress,
+					},
+				},
+			},
+			fqdns: []string{},
+		},
+		{
+			name: "wildcard",
+			spec: v1alpha1.FQDNNetworkPolicySpec{
+				Egress: []v1alpha1.FQDNNetworkPolicyEgressRule{
+					{To: []v1alpha1.FQDNNetworkPolicyPeer{{FQDN: "*.example.com"}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "named port",
+			spec: v1alpha1.FQDNNetworkPolicySpec{
+				Egress: []v1alpha1.FQDNNetworkPolicyEgressRule{
+					{
+						Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &namedPort}},
+						To:    []v1alpha1.FQDNNetworkPolicyPeer{{FQDN: "api.example.com"}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fqdnPol := &v1alpha1.FQDNNetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "saas", Namespace: "x"},
+				Spec:       tt.spec,
+			}
+			npmNetPol, err := TranslateFQDNPolicy(fqdnPol)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.npmNetPol, npmNetPol)
+			require.Equal(t, tt.fqdns, FQDNsOfPolicy(npmNetPol))
+		})
+	}
+}
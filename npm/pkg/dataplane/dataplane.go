@@ -307,6 +307,14 @@ func (dp *DataPlane) GetAllPolicies() []string {
 	return dp.policyMgr.GetAllPolicies()
 }
 
+// GetPoliciesSelecting returns the policies with rules in the direction which select the Pod with the IP.
+// When a packet is dropped, these are the policies which didn't allow it.
+func (dp *DataPlane) GetPoliciesSelecting(direction policies.Direction, podIP string) []*policies.NPMNetworkPolicy {
+	return dp.policyMgr.GetPoliciesSelecting(direction, func(setName string) bool {
+		return dp.ipsetMgr.HasMember(setName, podIP)
+	})
+}
+
 func (dp *DataPlane) createIPSetsAndReferences(sets []*ipsets.TranslatedIPSet, netpolName string, referenceType ipsets.ReferenceType) error {
 	// Create IPSets first along with reference updates
 	npmErrorString := npmerrors.AddSelectorReference
@@ -0,0 +1,626 @@
+package dataplane
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	anpv1alpha1 "github.com/Azure/azure-container-networking/npm/pkg/apis/adminnetworkpolicy/v1alpha1"
+	fqdnv1alpha1 "github.com/Azure/azure-container-networking/npm/pkg/apis/fqdnnetworkpolicy/v1alpha1"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/translation"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+// the range of the IPs given to simulated Pods without a status.podIP, so that manifests can be simulated as is
+const simulatedPodCIDR = "198.18.0.0/15"
+
+// kinds of the policy CRDs which the simulator skips
+const (
+	adminPolicyKind         = "AdminNetworkPolicy"
+	baselineAdminPolicyKind = "BaselineAdminNetworkPolicy"
+	fqdnPolicyKind          = "FQDNNetworkPolicy"
+)
+
+var (
+	errUnsupportedObject = errors.New("unsupported object, expected a Pod, Namespace, NetworkPolicy, or List")
+	errPodNotFound       = errors.New("pod not found")
+	errNoSimulatedPodIPs = errors.New("no IPs left for simulated pods")
+)
+
+// Flow is a flow to simulate
+type Flow struct {
+	// Src and Dst are either a Pod as <namespace>/<name>, or an IP address. IPs of Pods are resolved to the Pods.
+	Src string
+	Dst string
+	// Protocol is TCP, UDP, or SCTP. It defaults to TCP.
+	Protocol string
+	// Port is the destination port. A flow without a port only matches rules without ports.
+	Port int32
+}
+
+// SimulationResult is whether the flow is allowed, and why
+type SimulationResult struct {
+	Allowed bool `json:"allowed"`
+	// Egress is the result for the source Pod, and is nil if the source isn't a Pod
+	Egress *DirectionResult `json:"egress,omitempty"`
+	// Ingress is the result for the destination Pod, and is nil if the destination isn't a Pod
+	Ingress *DirectionResult `json:"ingress,omitempty"`
+	// SkippedPolicies are the keys of the AdminNetworkPolicies, BaselineAdminNetworkPolicies, and FQDNNetworkPolicies
+	// which weren't simulated, so the result may differ from the cluster if they select the Pods
+	SkippedPolicies []string `json:"skippedPolicies,omitempty"`
+}
+
+// DirectionResult is the result of the policies selecting a Pod in one direction
+type DirectionResult struct {
+	Allowed bool `json:"allowed"`
+	// SelectingPolicies are the keys of the policies selecting the Pod. The flow is allowed if there are none.
+	SelectingPolicies []string `json:"selectingPolicies"`
+	// MatchingRules are the allow rules matching the flow if it's allowed, and otherwise the drop rules matching it
+	MatchingRules []*SimulatedRule `json:"matchingRules"`
+}
+
+// SimulatedRule is an ACL of a translated policy. Sets are prefixed names, with a "!" if the match is negated.
+type SimulatedRule struct {
+	PolicyKey string   `json:"policyKey"`
+	Target    string   `json:"target"`
+	Direction string   `json:"direction"`
+	Protocol  string   `json:"protocol"`
+	Ports     string   `json:"ports"`
+	SrcList   []string `json:"srcList"`
+	DstList   []string `json:"dstList"`
+}
+
+// Simulator answers whether flows would be allowed by NetworkPolicies without a cluster or a dataplane.
+// Policies are translated like the v2 controllers do, and programmed into an in-memory IPSetManager and PolicyManager
+// whose commands are never run. HostNetwork Pods are ignored like NPM does.
+// AdminNetworkPolicies, BaselineAdminNetworkPolicies, and FQDNNetworkPolicies aren't simulated. They're skipped with a warning.
+type Simulator struct {
+	ipsetMgr  *ipsets.IPSetManager
+	policyMgr *policies.PolicyManager
+	// podIPs has the IP of each simulated Pod by <namespace>/<name>
+	podIPs map[string]string
+	// podKeys has the <namespace>/<name> of each simulated Pod by IP
+	podKeys   map[string]string
+	nextPodIP net.IP
+	// skippedPolicies are the keys of the policies which aren't simulated
+	skippedPolicies []string
+}
+
+// NewSimulatorFromFiles creates a simulator with the Pods, Namespaces, and NetworkPolicies in the YAML or JSON files.
+// Files may have multiple documents and List objects like the output of kubectl get -o yaml.
+func NewSimulatorFromFiles(paths ...string) (*Simulator, error) {
+	objects := make([]runtime.Object, 0)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		fileObjects, err := decodeObjects(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		objects = append(objects, fileObjects...)
+	}
+	return NewSimulator(objects)
+}
+
+// decodeObjects decodes all documents of the reader
+func decodeObjects(r io.Reader) ([]runtime.Object, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	objects := make([]runtime.Object, 0)
+	for {
+		raw := runtime.RawExtension{}
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("failed to decode document: %w", err)
+		}
+		if len(raw.Raw) == 0 || string(raw.Raw) == "null" {
+			// empty document
+			continue
+		}
+		obj, err := decodeObject(raw.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+}
+
+// decodeObject decodes a JSON object. Kinds which aren't in the client-go scheme, like the policy CRDs, are decoded as unstructured.
+func decodeObject(data []byte) (runtime.Object, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if err == nil {
+		return obj, nil
+	}
+	if !runtime.IsNotRegisteredError(err) {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+	return u, nil
+}
+
+// NewSimulator creates a simulator with the Pods, Namespaces, and NetworkPolicies.
+// Pods without an IP are given an unused one from 198.18.0.0/15.
+// AdminNetworkPolicies, BaselineAdminNetworkPolicies, and FQDNNetworkPolicies are skipped with a warning naming them.
+func NewSimulator(objects []runtime.Object) (*Simulator, error) {
+	metrics.InitializeAll()
+
+	// the managers only keep the state in memory, and the commands to program the kernel always succeed without running
+	ioShim := common.NewMockIOShim(nil)
+	ioShim.Exec = &testingexec.FakeExec{DisableScripts: true}
+	_, podCIDR, _ := net.ParseCIDR(simulatedPodCIDR)
+	s := &Simulator{
+		ipsetMgr:  ipsets.NewIPSetManager(&ipsets.IPSetManagerCfg{IPSetMode: ipsets.ApplyAllIPSets}, ioShim),
+		policyMgr: policies.NewPolicyManager(ioShim, &policies.PolicyManagerCfg{PolicyMode: policies.IPSetPolicyMode}),
+		podIPs:    make(map[string]string),
+		podKeys:   make(map[string]string),
+		nextPodIP: nextIP(podCIDR.IP.To4()),
+	}
+
+	objects, err := flattenLists(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	// add namespaces and pods before policies, like the controllers do after their informers sync
+	podsWithoutIP := make([]*corev1.Pod, 0)
+	netPols := make([]*networkingv1.NetworkPolicy, 0)
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *corev1.Namespace:
+			if err := s.addNamespace(o); err != nil {
+				return nil, err
+			}
+		case *corev1.Pod:
+			if o.Status.PodIP == "" {
+				// IPs are allocated once the IPs of the other pods are known
+				podsWithoutIP = append(podsWithoutIP, o)
+				continue
+			}
+			if err := s.addPod(o); err != nil {
+				return nil, err
+			}
+		case *networkingv1.NetworkPolicy:
+			netPols = append(netPols, o)
+		case *unstructured.Unstructured:
+			// the policy CRDs aren't in the scheme, so they're decoded as unstructured
+			policyKey, ok := unstructuredPolicyKey(o)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", errUnsupportedObject, o.GroupVersionKind().String())
+			}
+			s.skippedPolicies = append(s.skippedPolicies, policyKey)
+		default:
+			return nil, fmt.Errorf("%w: %s", errUnsupportedObject, obj.GetObjectKind().GroupVersionKind().String())
+		}
+	}
+
+	if len(s.skippedPolicies) > 0 {
+		sort.Strings(s.skippedPolicies)
+		klog.Warningf("skipping policies which the simulator doesn't support: %s", strings.Join(s.skippedPolicies, ", "))
+	}
+
+	for _, pod := range podsWithoutIP {
+		if err := s.addPod(pod); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, netPol := range netPols {
+		if err := s.addNetworkPolicy(netPol); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// unstructuredPolicyKey returns the policy key of an AdminNetworkPolicy, BaselineAdminNetworkPolicy, or FQDNNetworkPolicy
+// decoded as unstructured, and false for other kinds
+func unstructuredPolicyKey(obj *unstructured.Unstructured) (string, bool) {
+	gvk := obj.GroupVersionKind()
+	switch {
+	case gvk.Group == anpv1alpha1.GroupVersion.Group && gvk.Kind == adminPolicyKind:
+		return translation.AdminPolicyKey(obj.GetName()), true
+	case gvk.Group == anpv1alpha1.GroupVersion.Group && gvk.Kind == baselineAdminPolicyKind:
+		return translation.BaselineAdminPolicyKey(obj.GetName()), true
+	case gvk.Group == fqdnv1alpha1.GroupVersion.Group && gvk.Kind == fqdnPolicyKind:
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = corev1.NamespaceDefault
+		}
+		return translation.FQDNPolicyKey(namespace, obj.GetName()), true
+	default:
+		return "", false
+	}
+}
+
+// flattenLists replaces List objects with their items
+func flattenLists(objects []runtime.Object) ([]runtime.Object, error) {
+	result := make([]runtime.Object, 0, len(objects))
+	for _, obj := range objects {
+		list, ok := obj.(*corev1.List)
+		if !ok {
+			result = append(result, obj)
+			continue
+		}
+		for i := range list.Items {
+			item := list.Items[i].Object
+			if item == nil {
+				var err error
+				item, err = decodeObject(list.Items[i].Raw)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode list item: %w", err)
+				}
+			}
+			items, err := flattenLists([]runtime.Object{item})
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, items...)
+		}
+	}
+	return result, nil
+}
+
+func (s *Simulator) addNamespace(nsObj *corev1.Namespace) error {
+	namespaceSets := []*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(nsObj.Name, ipsets.Namespace)}
+	setsToAddNamespaceTo := []*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(util.KubeAllNamespacesFlag, ipsets.KeyLabelOfNamespace)}
+	for nsLabelKey, nsLabelVal := range nsObj.Labels {
+		setsToAddNamespaceTo = append(setsToAddNamespaceTo,
+			ipsets.NewIPSetMetadata(nsLabelKey, ipsets.KeyLabelOfNamespace),
+			ipsets.NewIPSetMetadata(util.GetIpSetFromLabelKV(nsLabelKey, nsLabelVal), ipsets.KeyValueLabelOfNamespace),
+		)
+	}
+	if err := s.ipsetMgr.AddToLists(setsToAddNamespaceTo, namespaceSets); err != nil {
+		return fmt.Errorf("failed to add namespace %s: %w", nsObj.Name, err)
+	}
+	return nil
+}
+
+func (s *Simulator) addPod(podObj *corev1.Pod) error {
+	if podObj.Spec.HostNetwork {
+		return nil
+	}
+	namespace := podObj.Namespace
+	if namespace == "" {
+		namespace = corev1.NamespaceDefault
+	}
+	podKey := namespace + "/" + podObj.Name
+
+	podIP := podObj.Status.PodIP
+	if podIP == "" {
+		var err error
+		if podIP, err = s.allocatePodIP(); err != nil {
+			return fmt.Errorf("failed to add pod %s: %w", podKey, err)
+		}
+	}
+	s.podIPs[podKey] = podIP
+	s.podKeys[podIP] = podKey
+
+	// pods can be in namespaces which weren't given
+	allNamespaces := []*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(util.KubeAllNamespacesFlag, ipsets.KeyLabelOfNamespace)}
+	namespaceSet := []*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(namespace, ipsets.Namespace)}
+	if err := s.ipsetMgr.AddToLists(allNamespaces, namespaceSet); err != nil {
+		return fmt.Errorf("failed to add namespace of pod %s: %w", podKey, err)
+	}
+
+	podSets := namespaceSet
+	for labelKey, labelVal := range podObj.Labels {
+		podSets = append(podSets,
+			ipsets.NewIPSetMetadata(labelKey, ipsets.KeyLabelOfPod),
+			ipsets.NewIPSetMetadata(util.GetIpSetFromLabelKV(labelKey, labelVal), ipsets.KeyValueLabelOfPod),
+		)
+	}
+	if err := s.ipsetMgr.AddToSets(podSets, podIP, podKey); err != nil {
+		return fmt.Errorf("failed to add pod %s: %w", podKey, err)
+	}
+
+	for i := range podObj.Spec.Containers {
+		for _, port := range podObj.Spec.Containers[i].Ports {
+			if port.Name == "" {
+				continue
+			}
+			protocol := port.Protocol
+			if protocol == "" {
+				// the API server defaults the protocol
+				protocol = corev1.ProtocolTCP
+			}
+			namedPortSet := []*ipsets.IPSetMetadata{ipsets.NewIPSetMetadata(port.Name, ipsets.NamedPorts)}
+			if err := s.ipsetMgr.AddToSets(namedPortSet, namedPortMember(podIP, string(protocol), port.ContainerPort), podKey); err != nil {
+				return fmt.Errorf("failed to add named port %s of pod %s: %w", port.Name, podKey, err)
+			}
+		}
+	}
+	return nil
+}
+
+// allocatePodIP returns the next IP for Pods without one
+func (s *Simulator) allocatePodIP() (string, error) {
+	_, podCIDR, _ := net.ParseCIDR(simulatedPodCIDR)
+	for !podCIDR.Contains(s.nextPodIP) || s.podKeys[s.nextPodIP.String()] != "" {
+		if !podCIDR.Contains(s.nextPodIP) {
+			return "", errNoSimulatedPodIPs
+		}
+		s.nextPodIP = nextIP(s.nextPodIP)
+	}
+	ip := s.nextPodIP.String()
+	s.nextPodIP = nextIP(s.nextPodIP)
+	return ip, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// namedPortMember is the member of a named port IPSet, in the same format as the pod controller
+func namedPortMember(ip, protocol string, port int32) string {
+	return fmt.Sprintf("%s,%s:%d", ip, protocol, port)
+}
+
+// addNetworkPolicy translates the policy, then creates its IPSets and adds it like the dataplane does
+func (s *Simulator) addNetworkPolicy(netPol *networkingv1.NetworkPolicy) error {
+	if netPol.Namespace == "" {
+		netPol = netPol.DeepCopy()
+		netPol.Namespace = corev1.NamespaceDefault
+	}
+	npmNetPol, err := translation.TranslatePolicy(netPol)
+	if err != nil {
+		return fmt.Errorf("failed to translate network policy %s/%s: %w", netPol.Namespace, netPol.Name, err)
+	}
+
+	sets := make([]*ipsets.TranslatedIPSet, 0, len(npmNetPol.PodSelectorIPSets)+len(npmNetPol.RuleIPSets))
+	sets = append(sets, npmNetPol.PodSelectorIPSets...)
+	sets = append(sets, npmNetPol.RuleIPSets...)
+	for _, set := range sets {
+		s.ipsetMgr.CreateIPSets([]*ipsets.IPSetMetadata{set.Metadata})
+		switch {
+		case set.Metadata.Type == ipsets.CIDRBlocks:
+			for _, ipblock := range set.Members {
+				if err := s.ipsetMgr.AddToSets([]*ipsets.IPSetMetadata{set.Metadata}, ipblock, ""); err != nil {
+					return fmt.Errorf("failed to add %s for network policy %s: %w", ipblock, npmNetPol.PolicyKey, err)
+				}
+			}
+		case set.Metadata.Type == ipsets.NestedLabelOfPod && len(set.Members) > 0:
+			if err := s.ipsetMgr.AddToLists([]*ipsets.IPSetMetadata{set.Metadata}, ipsets.GetMembersOfTranslatedSets(set.Members)); err != nil {
+				return fmt.Errorf("failed to add nested label sets for network policy %s: %w", npmNetPol.PolicyKey, err)
+			}
+		}
+	}
+
+	if err := s.policyMgr.AddPolicy(npmNetPol, nil); err != nil {
+		return fmt.Errorf("failed to add network policy %s: %w", npmNetPol.PolicyKey, err)
+	}
+	return nil
+}
+
+// simulatedEndpoint is the source or destination of a flow
+type simulatedEndpoint struct {
+	ip string
+	// isPod is false for IPs outside the cluster
+	isPod bool
+}
+
+func (s *Simulator) resolveEndpoint(input string) (*simulatedEndpoint, error) {
+	if ip := net.ParseIP(input); ip != nil {
+		_, isPod := s.podKeys[ip.String()]
+		return &simulatedEndpoint{ip: ip.String(), isPod: isPod}, nil
+	}
+	ip, ok := s.podIPs[input]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errPodNotFound, input)
+	}
+	return &simulatedEndpoint{ip: ip, isPod: true}, nil
+}
+
+// Simulate returns whether the flow is allowed by the egress policies of the source Pod and the ingress policies of the destination Pod
+func (s *Simulator) Simulate(flow *Flow) (*SimulationResult, error) {
+	src, err := s.resolveEndpoint(flow.Src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source: %w", err)
+	}
+	dst, err := s.resolveEndpoint(flow.Dst)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination: %w", err)
+	}
+	protocol := strings.ToUpper(flow.Protocol)
+	if protocol == "" {
+		protocol = string(policies.TCP)
+	}
+
+	result := &SimulationResult{Allowed: true, SkippedPolicies: s.skippedPolicies}
+	if src.isPod {
+		result.Egress = s.simulateDirection(policies.Egress, src.ip, dst.ip, protocol, flow.Port)
+		result.Allowed = result.Allowed && result.Egress.Allowed
+	}
+	if dst.isPod {
+		result.Ingress = s.simulateDirection(policies.Ingress, src.ip, dst.ip, protocol, flow.Port)
+		result.Allowed = result.Allowed && result.Ingress.Allowed
+	}
+	return result, nil
+}
+
+// simulateDirection evaluates the policies selecting the destination Pod for ingress, or the source Pod for egress.
+// Like NetworkPolicies, the flow is allowed if any rule allows it, and dropped if a policy selects the Pod and none allows it.
+func (s *Simulator) simulateDirection(direction policies.Direction, srcIP, dstIP, protocol string, port int32) *DirectionResult {
+	podIP := dstIP
+	if direction == policies.Egress {
+		podIP = srcIP
+	}
+	selecting := s.policyMgr.GetPoliciesSelecting(direction, func(setName string) bool {
+		return s.hasMember(setName, podIP)
+	})
+	sort.Slice(selecting, func(i, j int) bool { return selecting[i].PolicyKey < selecting[j].PolicyKey })
+
+	result := &DirectionResult{
+		Allowed:           true,
+		SelectingPolicies: make([]string, 0, len(selecting)),
+		MatchingRules:     make([]*SimulatedRule, 0),
+	}
+	droppingRules := make([]*SimulatedRule, 0)
+	for _, policy := range selecting {
+		result.SelectingPolicies = append(result.SelectingPolicies, policy.PolicyKey)
+		for _, acl := range policy.ACLs {
+			if acl.Direction != direction && acl.Direction != policies.Both {
+				continue
+			}
+			if !s.aclMatches(acl, direction, srcIP, dstIP, protocol, port) {
+				continue
+			}
+			switch acl.Target {
+			case policies.Allowed:
+				result.MatchingRules = append(result.MatchingRules, newSimulatedRule(policy.PolicyKey, acl))
+			case policies.Dropped:
+				droppingRules = append(droppingRules, newSimulatedRule(policy.PolicyKey, acl))
+			}
+		}
+	}
+
+	if len(result.MatchingRules) == 0 && len(droppingRules) > 0 {
+		result.Allowed = false
+		result.MatchingRules = droppingRules
+	}
+	return result
+}
+
+// aclMatches returns true if the flow matches the protocol, ports, and sets of the ACL
+func (s *Simulator) aclMatches(acl *policies.ACLPolicy, direction policies.Direction, srcIP, dstIP, protocol string, port int32) bool {
+	if acl.Protocol != policies.UnspecifiedProtocol && string(acl.Protocol) != protocol {
+		return false
+	}
+	if acl.DstPorts.Port != 0 && (port < acl.DstPorts.Port || port > acl.DstPorts.EndPort) {
+		return false
+	}
+
+	setInfos := make([]policies.SetInfo, 0, len(acl.SrcList)+len(acl.DstList))
+	setInfos = append(setInfos, acl.SrcList...)
+	setInfos = append(setInfos, acl.DstList...)
+	for _, setInfo := range setInfos {
+		var matched bool
+		switch setInfo.MatchType {
+		case policies.SrcMatch:
+			matched = s.hasMember(setInfo.IPSet.GetPrefixName(), srcIP)
+		case policies.DstMatch:
+			matched = s.hasMember(setInfo.IPSet.GetPrefixName(), dstIP)
+		case policies.DstDstMatch:
+			matched = s.hasMember(setInfo.IPSet.GetPrefixName(), namedPortMember(dstIP, protocol, port))
+		case policies.EitherMatch:
+			// the Pod which the policy applies to
+			ip := dstIP
+			if direction == policies.Egress {
+				ip = srcIP
+			}
+			matched = s.hasMember(setInfo.IPSet.GetPrefixName(), ip)
+		}
+		if matched != setInfo.Included {
+			return false
+		}
+	}
+	return true
+}
+
+// hasMember returns true if the member is in the set, or in a set of the list.
+// Members of CIDR and FQDN sets are matched like hash:net sets, where the most specific CIDR decides and nomatch CIDRs exclude.
+func (s *Simulator) hasMember(setName, member string) bool {
+	set := s.ipsetMgr.GetIPSet(setName)
+	if set == nil {
+		return false
+	}
+	if set.Kind == ipsets.ListSet {
+		for memberSetName := range set.MemberIPSets {
+			if s.hasMember(memberSetName, member) {
+				return true
+			}
+		}
+		return false
+	}
+	if set.Type != ipsets.CIDRBlocks && set.Type != ipsets.FQDN {
+		_, ok := set.IPPodKey[member]
+		return ok
+	}
+
+	ip := net.ParseIP(member)
+	if ip == nil {
+		return false
+	}
+	matched := false
+	longestPrefix := -1
+	for entry := range set.IPPodKey {
+		cidr := strings.TrimSpace(strings.TrimSuffix(entry, util.IpsetNomatch))
+		if !strings.Contains(cidr, "/") {
+			cidr += "/32"
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil || !ipnet.Contains(ip) {
+			continue
+		}
+		if prefix, _ := ipnet.Mask.Size(); prefix > longestPrefix {
+			longestPrefix = prefix
+			matched = !strings.HasSuffix(entry, util.IpsetNomatch)
+		}
+	}
+	return matched
+}
+
+func newSimulatedRule(policyKey string, acl *policies.ACLPolicy) *SimulatedRule {
+	ports := ANY
+	if acl.DstPorts.Port != 0 {
+		ports = fmt.Sprintf("%d", acl.DstPorts.Port)
+		if acl.DstPorts.EndPort != acl.DstPorts.Port {
+			ports = fmt.Sprintf("%d-%d", acl.DstPorts.Port, acl.DstPorts.EndPort)
+		}
+	}
+	protocol := string(acl.Protocol)
+	if acl.Protocol == policies.UnspecifiedProtocol {
+		protocol = ANY
+	}
+	return &SimulatedRule{
+		PolicyKey: policyKey,
+		Target:    string(acl.Target),
+		Direction: string(acl.Direction),
+		Protocol:  protocol,
+		Ports:     ports,
+		SrcList:   setInfoNames(acl.SrcList),
+		DstList:   setInfoNames(acl.DstList),
+	}
+}
+
+func setInfoNames(setInfos []policies.SetInfo) []string {
+	names := make([]string, 0, len(setInfos))
+	for _, setInfo := range setInfos {
+		name := setInfo.IPSet.GetPrefixName()
+		if !setInfo.Included {
+			name = "!" + name
+		}
+		names = append(names, name)
+	}
+	return names
+}
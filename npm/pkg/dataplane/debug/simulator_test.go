@@ -0,0 +1,273 @@
+package dataplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const simulatorFile = "../testdata/simulator.yaml"
+
+func simulatedPod(namespace, name, ip string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Status:     corev1.PodStatus{PodIP: ip},
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	tcp := corev1.ProtocolTCP
+	port443 := intstr.FromInt(443)
+	objects := []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "x"}},
+		simulatedPod("x", "a", "10.0.0.1", map[string]string{"app": "a"}),
+		simulatedPod("x", "b", "10.0.0.2", map[string]string{"app": "b"}),
+		// not selected by any policy
+		simulatedPod("x", "c", "10.0.0.3", map[string]string{"app": "c"}),
+		&networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "b-ingress", Namespace: "x"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "b"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "a"}}}},
+						Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port443}},
+					},
+				},
+			},
+		},
+		&networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "a-egress", Namespace: "x"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "a"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress: []networkingv1.NetworkPolicyEgressRule{
+					{To: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}},
+					{To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "20.0.0.0/16", Except: []string{"20.0.1.0/24"}}}}},
+				},
+			},
+		},
+	}
+	s, err := NewSimulator(objects)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		flow          *Flow
+		allowed       bool
+		egressPols    []string
+		ingressPols   []string
+		matchingRules int
+	}{
+		{
+			name:          "allowed by egress and ingress rules",
+			flow:          &Flow{Src: "x/a", Dst: "x/b", Port: 443},
+			allowed:       true,
+			egressPols:    []string{"x/a-egress"},
+			ingressPols:   []string{"x/b-ingress"},
+			matchingRules: 1,
+		},
+		{
+			name:          "pod IPs are resolved to pods",
+			flow:          &Flow{Src: "10.0.0.1", Dst: "10.0.0.2", Protocol: "tcp", Port: 443},
+			allowed:       true,
+			egressPols:    []string{"x/a-egress"},
+			ingressPols:   []string{"x/b-ingress"},
+			matchingRules: 1,
+		},
+		{
+			name:          "wrong port is dropped by ingress",
+			flow:          &Flow{Src: "x/a", Dst: "x/b", Port: 80},
+			allowed:       false,
+			egressPols:    []string{"x/a-egress"},
+			ingressPols:   []string{"x/b-ingress"},
+			matchingRules: 1,
+		},
+		{
+			name:          "wrong protocol is dropped by ingress",
+			flow:          &Flow{Src: "x/a", Dst: "x/b", Protocol: "UDP", Port: 443},
+			allowed:       false,
+			egressPols:    []string{"x/a-egress"},
+			ingressPols:   []string{"x/b-ingress"},
+			matchingRules: 1,
+		},
+		{
+			name:          "wrong source is dropped by ingress",
+			flow:          &Flow{Src: "x/c", Dst: "x/b", Port: 443},
+			allowed:       false,
+			egressPols:    []string{},
+			ingressPols:   []string{"x/b-ingress"},
+			matchingRules: 1,
+		},
+		{
+			name:        "pods not selected by a policy allow everything",
+			flow:        &Flow{Src: "x/c", Dst: "x/c"},
+			allowed:     true,
+			egressPols:  []string{},
+			ingressPols: []string{},
+		},
+		{
+			name:          "egress to ip block",
+			flow:          &Flow{Src: "x/a", Dst: "20.0.2.1", Port: 80},
+			allowed:       true,
+			egressPols:    []string{"x/a-egress"},
+			matchingRules: 1,
+		},
+		{
+			name:          "egress to except of ip block",
+			flow:          &Flow{Src: "x/a", Dst: "20.0.1.1", Port: 80},
+			allowed:       false,
+			egressPols:    []string{"x/a-egress"},
+			matchingRules: 1,
+		},
+		{
+			name:    "external to external",
+			flow:    &Flow{Src: "1.1.1.1", Dst: "8.8.8.8"},
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.Simulate(tt.flow)
+			require.NoError(t, err)
+			require.Equal(t, tt.allowed, result.Allowed)
+
+			if tt.egressPols == nil {
+				require.Nil(t, result.Egress)
+			} else {
+				require.Equal(t, tt.egressPols, result.Egress.SelectingPolicies)
+			}
+			if tt.ingressPols == nil {
+				require.Nil(t, result.Ingress)
+			} else {
+				require.Equal(t, tt.ingressPols, result.Ingress.SelectingPolicies)
+			}
+
+			// the rules of the direction deciding the flow
+			decidingResult := result.Ingress
+			if decidingResult == nil || (result.Egress != nil && !result.Egress.Allowed) {
+				decidingResult = result.Egress
+			}
+			if decidingResult != nil {
+				require.Len(t, decidingResult.MatchingRules, tt.matchingRules)
+			}
+		})
+	}
+}
+
+func TestSimulateFromFile(t *testing.T) {
+	s, err := NewSimulatorFromFiles(simulatorFile)
+	require.NoError(t, err)
+
+	result, err := s.Simulate(&Flow{Src: "frontend/web", Dst: "backend/api", Port: 8080})
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, []*SimulatedRule{
+		{
+			PolicyKey: "backend/allow-web-to-api",
+			Target:    "ALLOW",
+			Direction: "IN",
+			Protocol:  "TCP",
+			Ports:     ANY,
+			SrcList:   []string{"nslabel-team:web"},
+			DstList:   []string{"namedport:http"},
+		},
+	}, result.Ingress.MatchingRules)
+
+	// the rule is only for TCP
+	result, err = s.Simulate(&Flow{Src: "frontend/web", Dst: "backend/api", Protocol: "UDP", Port: 8080})
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+
+	// the source namespace doesn't have the label
+	result, err = s.Simulate(&Flow{Src: "backend/api", Dst: "backend/api", Port: 8080})
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, "DROP", result.Ingress.MatchingRules[0].Target)
+}
+
+func TestSimulatorPodWithoutIP(t *testing.T) {
+	s, err := NewSimulator([]runtime.Object{
+		simulatedPod("x", "a", "", nil),
+		simulatedPod("x", "b", "198.18.0.1", nil),
+		simulatedPod("x", "c", "", nil),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "198.18.0.2", s.podIPs["x/a"])
+	require.Equal(t, "198.18.0.3", s.podIPs["x/c"])
+
+	_, err = s.Simulate(&Flow{Src: "x/a", Dst: "x/d"})
+	require.ErrorIs(t, err, errPodNotFound)
+}
+
+func TestNewSimulatorErrors(t *testing.T) {
+	_, err := NewSimulator([]runtime.Object{&corev1.Service{}})
+	require.ErrorIs(t, err, errUnsupportedObject)
+
+	_, err = NewSimulatorFromFiles(simulatorFile, "non-existing-file")
+	require.Error(t, err)
+}
+
+func TestSimulatorSkipsAdminAndFQDNPolicies(t *testing.T) {
+	policies := `apiVersion: policy.networking.k8s.io/v1alpha1
+kind: AdminNetworkPolicy
+metadata:
+  name: deny-all
+spec:
+  priority: 10
+  subject:
+    namespaces: {}
+  ingress:
+  - action: Deny
+    from:
+    - namespaces: {}
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: policy.networking.k8s.io/v1alpha1
+  kind: BaselineAdminNetworkPolicy
+  metadata:
+    name: default
+  spec:
+    subject:
+      namespaces: {}
+- apiVersion: acn.azure.com/v1alpha1
+  kind: FQDNNetworkPolicy
+  metadata:
+    name: allow-api
+  spec:
+    podSelector: {}
+    egress:
+    - to:
+      - fqdn: api.example.com
+`
+	policiesFile := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(policiesFile, []byte(policies), 0o600))
+
+	s, err := NewSimulatorFromFiles(simulatorFile, policiesFile)
+	require.NoError(t, err)
+	skipped := []string{"AdminNetworkPolicy/deny-all", "BaselineAdminNetworkPolicy/default", "FQDNNetworkPolicy/default/allow-api"}
+	require.Equal(t, skipped, s.skippedPolicies)
+
+	// the skipped policies are in the result, since they could change it
+	result, err := s.Simulate(&Flow{Src: "frontend/web", Dst: "10.0.0.1"})
+	require.NoError(t, err)
+	require.Equal(t, skipped, result.SkippedPolicies)
+
+	// other custom resources are still unsupported
+	unsupportedFile := filepath.Join(t.TempDir(), "unsupported.yaml")
+	require.NoError(t, os.WriteFile(unsupportedFile, []byte("apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\n"), 0o600))
+	_, err = NewSimulatorFromFiles(unsupportedFile)
+	require.ErrorIs(t, err, errUnsupportedObject)
+}
@@ -0,0 +1,179 @@
+// Package denylog reports the packets dropped by NPM, which the Linux dataplane logs via NFLOG when deny logging is enabled.
+package denylog
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/Azure/azure-container-networking/npm/util"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// MaxDeniedFlows is the number of most recent denied flows kept for the HTTP API
+	MaxDeniedFlows = 1000
+	// DeniedFlowsPerSecond and DeniedFlowsBurst limit how many dropped packets are kept as denied flows.
+	// Every dropped packet is still counted in the Prometheus metrics.
+	DeniedFlowsPerSecond = 10
+	DeniedFlowsBurst     = 100
+	// PolicyCacheTTL is how long the policies selecting a Pod are cached, so that they aren't looked up for every
+	// dropped packet. A policy change is reflected in the counts and denied flows after at most this long.
+	PolicyCacheTTL = time.Second
+	// MaxCachedPods bounds the cache of the policies selecting each Pod. The cache is emptied when it's full.
+	MaxCachedPods = 1000
+)
+
+// PolicyResolver finds the policies selecting the Pod of a dropped packet. It is implemented by the DataPlane.
+type PolicyResolver interface {
+	GetPoliciesSelecting(direction policies.Direction, podIP string) []*policies.NPMNetworkPolicy
+}
+
+// Packet is the decoded IPv4 header of a logged packet.
+// The ports are 0 for protocols without ports.
+type Packet struct {
+	Protocol string
+	SrcIP    string
+	SrcPort  int
+	DstIP    string
+	DstPort  int
+}
+
+// DeniedFlow is a packet dropped by NPM.
+type DeniedFlow struct {
+	Time      time.Time
+	Direction policies.Direction
+	*Packet
+	// SelectingPolicies are the keys of the policies which selected the Pod, none of which allowed the packet.
+	// The NFLOG rules log the drop verdict of the direction, which isn't made by a single policy.
+	SelectingPolicies []string
+}
+
+// Recorder counts dropped packets per policy selecting their Pod and keeps the most recent ones as denied flows.
+type Recorder struct {
+	sync.Mutex
+	resolver PolicyResolver
+	limiter  *rate.Limiter
+	// ring buffer of denied flows, where next is the index to write to
+	flows []*DeniedFlow
+	next  int
+	// policies selecting each Pod IP, for each direction
+	cache map[cacheKey]*cachedPolicies
+	now   func() time.Time
+}
+
+type cacheKey struct {
+	direction policies.Direction
+	podIP     string
+}
+
+type cachedPolicies struct {
+	policies []*policies.NPMNetworkPolicy
+	keys     []string
+	expiry   time.Time
+}
+
+func NewRecorder(resolver PolicyResolver) *Recorder {
+	return &Recorder{
+		resolver: resolver,
+		limiter:  rate.NewLimiter(DeniedFlowsPerSecond, DeniedFlowsBurst),
+		flows:    make([]*DeniedFlow, 0, MaxDeniedFlows),
+		cache:    make(map[cacheKey]*cachedPolicies),
+		now:      time.Now,
+	}
+}
+
+// DirectionForPrefix returns the direction of the drop for the NFLOG prefix of a logged packet.
+func DirectionForPrefix(prefix string) (policies.Direction, bool) {
+	switch prefix {
+	case util.IptablesAzureIngressDenyLogPrefix:
+		return policies.Ingress, true
+	case util.IptablesAzureEgressDenyLogPrefix:
+		return policies.Egress, true
+	default:
+		return "", false
+	}
+}
+
+// Record counts the dropped packet for each of the policies which selected the Pod,
+// and keeps it as a denied flow unless over the rate limit.
+func (r *Recorder) Record(direction policies.Direction, packet *Packet) {
+	podIP := packet.DstIP
+	if direction == policies.Egress {
+		podIP = packet.SrcIP
+	}
+
+	now := r.now()
+	selecting := r.policiesSelecting(direction, podIP, now)
+	for _, policy := range selecting.policies {
+		metrics.IncDeniedFlowsBySelectingPolicy(policy.NameSpace, policy.Name, string(direction))
+	}
+
+	if !r.limiter.AllowN(now, 1) {
+		return
+	}
+
+	flow := &DeniedFlow{
+		Time:              now,
+		Direction:         direction,
+		Packet:            packet,
+		SelectingPolicies: selecting.keys,
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if len(r.flows) < MaxDeniedFlows {
+		r.flows = append(r.flows, flow)
+	} else {
+		r.flows[r.next] = flow
+	}
+	r.next = (r.next + 1) % MaxDeniedFlows
+}
+
+// policiesSelecting returns the policies selecting the Pod IP from the cache, or looks them up when they aren't cached
+// or expired. They are looked up without the lock, so that a slow lookup doesn't block the other packets.
+func (r *Recorder) policiesSelecting(direction policies.Direction, podIP string, now time.Time) *cachedPolicies {
+	key := cacheKey{direction: direction, podIP: podIP}
+	r.Lock()
+	cached, ok := r.cache[key]
+	r.Unlock()
+	if ok && now.Before(cached.expiry) {
+		return cached
+	}
+
+	selectingPolicies := r.resolver.GetPoliciesSelecting(direction, podIP)
+	policyKeys := make([]string, 0, len(selectingPolicies))
+	for _, policy := range selectingPolicies {
+		policyKeys = append(policyKeys, policy.PolicyKey)
+	}
+	sort.Strings(policyKeys)
+	cached = &cachedPolicies{policies: selectingPolicies, keys: policyKeys, expiry: now.Add(PolicyCacheTTL)}
+
+	r.Lock()
+	defer r.Unlock()
+	if len(r.cache) >= MaxCachedPods {
+		r.cache = make(map[cacheKey]*cachedPolicies)
+	}
+	r.cache[key] = cached
+	return cached
+}
+
+// DeniedFlows returns the kept denied flows from oldest to newest.
+func (r *Recorder) DeniedFlows() []*DeniedFlow {
+	r.Lock()
+	defer r.Unlock()
+	result := make([]*DeniedFlow, 0, len(r.flows))
+	if len(r.flows) == MaxDeniedFlows {
+		result = append(result, r.flows[r.next:]...)
+		return append(result, r.flows[:r.next]...)
+	}
+	return append(result, r.flows...)
+}
+
+// MarshalJSON encodes the denied flows for the HTTP API.
+func (r *Recorder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.DeniedFlows()) //nolint:wrapcheck // unnecessary to wrap error
+}
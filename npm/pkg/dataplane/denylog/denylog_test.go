@@ -0,0 +1,170 @@
+package denylog
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-container-networking/npm/metrics"
+	"github.com/Azure/azure-container-networking/npm/metrics/promutil"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/policies"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	// policies selecting each pod IP for each direction
+	policies map[policies.Direction]map[string][]*policies.NPMNetworkPolicy
+	calls    int
+}
+
+func (r *fakeResolver) GetPoliciesSelecting(direction policies.Direction, podIP string) []*policies.NPMNetworkPolicy {
+	r.calls++
+	return r.policies[direction][podIP]
+}
+
+var (
+	ingressPolicyA = &policies.NPMNetworkPolicy{Name: "ingress-a", NameSpace: "x", PolicyKey: "x/ingress-a"}
+	ingressPolicyB = &policies.NPMNetworkPolicy{Name: "ingress-b", NameSpace: "x", PolicyKey: "x/ingress-b"}
+	egressPolicy   = &policies.NPMNetworkPolicy{Name: "egress", NameSpace: "y", PolicyKey: "y/egress"}
+
+	testResolver = &fakeResolver{
+		policies: map[policies.Direction]map[string][]*policies.NPMNetworkPolicy{
+			policies.Ingress: {"10.0.0.1": {ingressPolicyB, ingressPolicyA}},
+			policies.Egress:  {"10.0.0.2": {egressPolicy}},
+		},
+	}
+)
+
+func newTestRecorder(now time.Time) *Recorder {
+	recorder := NewRecorder(testResolver)
+	recorder.now = func() time.Time { return now }
+	return recorder
+}
+
+func TestRecord(t *testing.T) {
+	now := time.Unix(1000, 0)
+	recorder := newTestRecorder(now)
+
+	ingressPacket := &Packet{Protocol: "TCP", SrcIP: "10.0.0.2", SrcPort: 40000, DstIP: "10.0.0.1", DstPort: 80}
+	egressPacket := &Packet{Protocol: "UDP", SrcIP: "10.0.0.2", SrcPort: 40001, DstIP: "10.0.0.3", DstPort: 53}
+	unknownPacket := &Packet{Protocol: "ICMP", SrcIP: "10.0.0.3", DstIP: "10.0.0.4"}
+
+	ingressBefore := getDeniedFlows(t, "x", "ingress-a", policies.Ingress)
+	egressBefore := getDeniedFlows(t, "y", "egress", policies.Egress)
+
+	recorder.Record(policies.Ingress, ingressPacket)
+	recorder.Record(policies.Egress, egressPacket)
+	recorder.Record(policies.Ingress, unknownPacket)
+
+	expectedFlows := []*DeniedFlow{
+		{Time: now, Direction: policies.Ingress, Packet: ingressPacket, SelectingPolicies: []string{"x/ingress-a", "x/ingress-b"}},
+		{Time: now, Direction: policies.Egress, Packet: egressPacket, SelectingPolicies: []string{"y/egress"}},
+		{Time: now, Direction: policies.Ingress, Packet: unknownPacket, SelectingPolicies: []string{}},
+	}
+	require.Equal(t, expectedFlows, recorder.DeniedFlows())
+
+	require.Equal(t, ingressBefore+1, getDeniedFlows(t, "x", "ingress-a", policies.Ingress))
+	require.Equal(t, egressBefore+1, getDeniedFlows(t, "y", "egress", policies.Egress))
+}
+
+func TestRecordRateLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	recorder := newTestRecorder(now)
+	packet := &Packet{Protocol: "TCP", SrcIP: "10.0.0.2", SrcPort: 40000, DstIP: "10.0.0.1", DstPort: 80}
+
+	countBefore := getDeniedFlows(t, "x", "ingress-a", policies.Ingress)
+	for i := 0; i < DeniedFlowsBurst+5; i++ {
+		recorder.Record(policies.Ingress, packet)
+	}
+	require.Len(t, recorder.DeniedFlows(), DeniedFlowsBurst, "flows over the burst should not be kept")
+	require.Equal(t, countBefore+DeniedFlowsBurst+5, getDeniedFlows(t, "x", "ingress-a", policies.Ingress), "every flow should be counted")
+
+	// after a second, the limiter allows more flows
+	recorder.now = func() time.Time { return now.Add(time.Second) }
+	for i := 0; i < DeniedFlowsPerSecond+5; i++ {
+		recorder.Record(policies.Ingress, packet)
+	}
+	require.Len(t, recorder.DeniedFlows(), DeniedFlowsBurst+DeniedFlowsPerSecond)
+}
+
+func TestRecordCachesPolicies(t *testing.T) {
+	now := time.Unix(1000, 0)
+	resolver := &fakeResolver{policies: testResolver.policies}
+	recorder := NewRecorder(resolver)
+	recorder.now = func() time.Time { return now }
+	packet := &Packet{Protocol: "TCP", SrcIP: "10.0.0.2", SrcPort: 40000, DstIP: "10.0.0.1", DstPort: 80}
+
+	countBefore := getDeniedFlows(t, "x", "ingress-a", policies.Ingress)
+	for i := 0; i < DeniedFlowsBurst+5; i++ {
+		recorder.Record(policies.Ingress, packet)
+	}
+	require.Equal(t, 1, resolver.calls, "policies should be looked up once per pod")
+	require.Equal(t, countBefore+DeniedFlowsBurst+5, getDeniedFlows(t, "x", "ingress-a", policies.Ingress))
+
+	// the other direction of the pod is cached separately
+	recorder.Record(policies.Egress, packet)
+	require.Equal(t, 2, resolver.calls)
+
+	// the cached policies expire
+	recorder.now = func() time.Time { return now.Add(PolicyCacheTTL) }
+	recorder.Record(policies.Ingress, packet)
+	require.Equal(t, 3, resolver.calls)
+}
+
+func TestDeniedFlowsWrapAround(t *testing.T) {
+	start := time.Unix(1000, 0)
+	recorder := newTestRecorder(start)
+	numFlows := MaxDeniedFlows + 10
+	for i := 0; i < numFlows; i++ {
+		// a second apart so the rate limit is never reached
+		flowTime := start.Add(time.Duration(i) * time.Second)
+		recorder.now = func() time.Time { return flowTime }
+		recorder.Record(policies.Egress, &Packet{Protocol: "TCP", SrcIP: "10.0.0.2", DstIP: "10.0.0.1", DstPort: i})
+	}
+
+	flows := recorder.DeniedFlows()
+	require.Len(t, flows, MaxDeniedFlows)
+	// the oldest flows are overwritten, and the rest are ordered from oldest to newest
+	for i, flow := range flows {
+		require.Equal(t, numFlows-MaxDeniedFlows+i, flow.DstPort)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	recorder := newTestRecorder(time.Unix(1000, 0).UTC())
+	recorder.Record(policies.Egress, &Packet{Protocol: "UDP", SrcIP: "10.0.0.2", SrcPort: 40001, DstIP: "10.0.0.3", DstPort: 53})
+
+	b, err := json.Marshal(recorder)
+	require.NoError(t, err)
+	expected := `[{"Time":"1970-01-01T00:16:40Z","Direction":"OUT","Protocol":"UDP","SrcIP":"10.0.0.2","SrcPort":40001,` +
+		`"DstIP":"10.0.0.3","DstPort":53,"SelectingPolicies":["y/egress"]}]`
+	require.JSONEq(t, expected, string(b))
+}
+
+func TestDirectionForPrefix(t *testing.T) {
+	direction, ok := DirectionForPrefix("AZURE-NPM-INGRESS-DROP")
+	require.True(t, ok)
+	require.Equal(t, policies.Ingress, direction)
+
+	direction, ok = DirectionForPrefix("AZURE-NPM-EGRESS-DROP")
+	require.True(t, ok)
+	require.Equal(t, policies.Egress, direction)
+
+	_, ok = DirectionForPrefix("OTHER")
+	require.False(t, ok)
+}
+
+func getDeniedFlows(t *testing.T, policyNamespace, policyName string, direction policies.Direction) int {
+	val, err := metrics.GetDeniedFlowsBySelectingPolicy(policyNamespace, policyName, string(direction))
+	promutil.NotifyIfErrors(t, err)
+	return val
+}
+
+func TestMain(m *testing.M) {
+	metrics.InitializeAll()
+
+	exitCode := m.Run()
+
+	os.Exit(exitCode)
+}
@@ -0,0 +1,240 @@
+package denylog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/Azure/azure-container-networking/npm/util"
+	"golang.org/x/sys/unix"
+	"k8s.io/klog"
+)
+
+// nfnetlink_log constants which aren't defined in the unix package
+const (
+	nfulnlMsgPacket = 0
+	nfulnlMsgConfig = 1
+
+	nfulaCfgCmd  = 1
+	nfulaCfgMode = 2
+
+	nfulnlCfgCmdBind = 1
+	nfulnlCopyPacket = 2
+
+	nfulaPayload = 9
+	nfulaPrefix  = 10
+
+	// masks out the nested and byte order flags of an attribute type
+	nlaTypeMask = 0x3fff
+
+	sizeofNfgenmsg = 4
+	// enough for the IPv4 header with options and the ports of the transport header
+	copyRange = 128
+
+	receiveBufferSize     = 65536
+	receiveTimeoutSeconds = 1
+)
+
+var errInvalidAttribute = errors.New("invalid netlink attribute")
+
+// Byte encoder for netlink, which uses the host byte order
+var encoder binary.ByteOrder
+
+func init() {
+	var x uint32 = 0x01020304
+	if *(*byte)(unsafe.Pointer(&x)) == 0x01 {
+		encoder = binary.BigEndian
+	} else {
+		encoder = binary.LittleEndian
+	}
+}
+
+// Listener receives the packets logged to the NFLOG group of NPM and passes them to the Recorder.
+type Listener struct {
+	recorder *Recorder
+	group    uint16
+	seq      uint32
+}
+
+func NewListener(recorder *Recorder) *Listener {
+	return &Listener{
+		recorder: recorder,
+		group:    util.IptablesAzureDenyLogGroup,
+	}
+}
+
+// Run binds to the NFLOG group and records the logged packets until the stop channel is closed.
+func (l *Listener) Run(stopChannel <-chan struct{}) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_NETFILTER)
+	if err != nil {
+		return fmt.Errorf("failed to create netfilter netlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("failed to bind netfilter netlink socket: %w", err)
+	}
+	timeout := unix.Timeval{Sec: receiveTimeoutSeconds}
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		return fmt.Errorf("failed to set receive timeout on netfilter netlink socket: %w", err)
+	}
+
+	// bind to the group, then ask for the packets to be copied
+	if err := l.configure(fd, unix.AF_INET, nfulaCfgCmd, []byte{nfulnlCfgCmdBind}); err != nil {
+		return fmt.Errorf("failed to bind to NFLOG group %d: %w", l.group, err)
+	}
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode[0:4], copyRange)
+	mode[4] = nfulnlCopyPacket
+	if err := l.configure(fd, unix.AF_UNSPEC, nfulaCfgMode, mode); err != nil {
+		return fmt.Errorf("failed to set copy mode for NFLOG group %d: %w", l.group, err)
+	}
+
+	klog.Infof("[DenyLog] listening for dropped packets on NFLOG group %d", l.group)
+	buffer := make([]byte, receiveBufferSize)
+	for {
+		select {
+		case <-stopChannel:
+			return nil
+		default:
+		}
+
+		n, _, err := unix.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			switch {
+			case errors.Is(err, unix.EAGAIN), errors.Is(err, unix.EINTR):
+			case errors.Is(err, unix.ENOBUFS):
+				// the kernel dropped logged packets since we couldn't keep up
+				klog.Warningf("[DenyLog] dropped packets were lost since the socket buffer is full")
+			default:
+				return fmt.Errorf("failed to receive from netfilter netlink socket: %w", err)
+			}
+			continue
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			klog.Errorf("[DenyLog] failed to parse netlink messages: %v", err)
+			continue
+		}
+		for k := range msgs {
+			l.handleMessage(&msgs[k])
+		}
+	}
+}
+
+func (l *Listener) handleMessage(msg *syscall.NetlinkMessage) {
+	if msg.Header.Type != unix.NFNL_SUBSYS_ULOG<<8|nfulnlMsgPacket {
+		return
+	}
+	prefix, payload, err := parsePacketMessage(msg.Data)
+	if err != nil {
+		klog.Errorf("[DenyLog] failed to parse NFLOG message: %v", err)
+		return
+	}
+	direction, ok := DirectionForPrefix(prefix)
+	if !ok {
+		klog.Infof("[DenyLog] ignoring packet with unexpected prefix %s", prefix)
+		return
+	}
+	packet, err := ParsePacket(payload)
+	if err != nil {
+		klog.Infof("[DenyLog] ignoring packet which couldn't be decoded: %v", err)
+		return
+	}
+	l.recorder.Record(direction, packet)
+}
+
+// parsePacketMessage returns the prefix and payload of an NFLOG packet message, which has a nfgenmsg header followed by attributes
+func parsePacketMessage(data []byte) (prefix string, payload []byte, err error) {
+	if len(data) < sizeofNfgenmsg {
+		return "", nil, errInvalidAttribute
+	}
+	b := data[sizeofNfgenmsg:]
+	for len(b) >= unix.SizeofNlAttr {
+		length := int(encoder.Uint16(b[0:2]))
+		attrType := encoder.Uint16(b[2:4]) & nlaTypeMask
+		if length < unix.SizeofNlAttr || length > len(b) {
+			return "", nil, errInvalidAttribute
+		}
+		value := b[unix.SizeofNlAttr:length]
+		switch attrType {
+		case nfulaPrefix:
+			// null-terminated
+			for k, c := range value {
+				if c == 0 {
+					value = value[:k]
+					break
+				}
+			}
+			prefix = string(value)
+		case nfulaPayload:
+			payload = value
+		}
+
+		alignedLength := nlaAlign(length)
+		if alignedLength > len(b) {
+			break
+		}
+		b = b[alignedLength:]
+	}
+	return prefix, payload, nil
+}
+
+// configure sends an NFLOG config message with one attribute for the group, and waits for the ack
+func (l *Listener) configure(fd int, family uint8, attrType uint16, value []byte) error {
+	l.seq++
+	attrLength := unix.SizeofNlAttr + len(value)
+	msgLength := unix.NLMSG_HDRLEN + sizeofNfgenmsg + nlaAlign(attrLength)
+	b := make([]byte, msgLength)
+
+	encoder.PutUint32(b[0:4], uint32(msgLength))
+	encoder.PutUint16(b[4:6], unix.NFNL_SUBSYS_ULOG<<8|nfulnlMsgConfig)
+	encoder.PutUint16(b[6:8], unix.NLM_F_REQUEST|unix.NLM_F_ACK)
+	encoder.PutUint32(b[8:12], l.seq)
+	// pid of 0 lets the kernel fill in the port id
+
+	nfgenmsg := b[unix.NLMSG_HDRLEN:]
+	nfgenmsg[0] = family
+	nfgenmsg[1] = unix.NFNETLINK_V0
+	binary.BigEndian.PutUint16(nfgenmsg[2:4], l.group)
+
+	attr := nfgenmsg[sizeofNfgenmsg:]
+	encoder.PutUint16(attr[0:2], uint16(attrLength))
+	encoder.PutUint16(attr[2:4], attrType)
+	copy(attr[unix.SizeofNlAttr:], value)
+
+	if err := unix.Sendto(fd, b, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("failed to send config message: %w", err)
+	}
+	return l.waitForAck(fd)
+}
+
+func (l *Listener) waitForAck(fd int) error {
+	buffer := make([]byte, unix.Getpagesize())
+	for {
+		n, _, err := unix.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			return fmt.Errorf("failed to receive ack: %w", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			return fmt.Errorf("failed to parse ack: %w", err)
+		}
+		for _, msg := range msgs {
+			if msg.Header.Seq != l.seq || msg.Header.Type != unix.NLMSG_ERROR || len(msg.Data) < 4 {
+				continue
+			}
+			if errCode := int32(encoder.Uint32(msg.Data[0:4])); errCode != 0 {
+				return syscall.Errno(-errCode)
+			}
+			return nil
+		}
+	}
+}
+
+func nlaAlign(length int) int {
+	return (length + unix.NLA_ALIGNTO - 1) & ^(unix.NLA_ALIGNTO - 1)
+}
@@ -0,0 +1,84 @@
+package denylog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// nflogAttribute encodes a netlink attribute padded to the attribute alignment
+func nflogAttribute(attrType uint16, value []byte) []byte {
+	length := unix.SizeofNlAttr + len(value)
+	b := make([]byte, nlaAlign(length))
+	encoder.PutUint16(b[0:2], uint16(length))
+	encoder.PutUint16(b[2:4], attrType)
+	copy(b[unix.SizeofNlAttr:], value)
+	return b
+}
+
+func nflogMessage(attrs ...[]byte) []byte {
+	b := []byte{unix.AF_INET, unix.NFNETLINK_V0, 0x03, 0xf2}
+	for _, attr := range attrs {
+		b = append(b, attr...)
+	}
+	return b
+}
+
+func TestParsePacketMessage(t *testing.T) {
+	payload := ipv4Header(protocolTCP, 0x9c, 0x40, 0x00, 0x50)
+
+	tests := []struct {
+		name            string
+		data            []byte
+		expectedPrefix  string
+		expectedPayload []byte
+		wantErr         bool
+	}{
+		{
+			name: "prefix and payload",
+			data: nflogMessage(
+				nflogAttribute(1, []byte{0, 0, 0, 0}),
+				nflogAttribute(nfulaPrefix, []byte("AZURE-NPM-INGRESS-DROP\x00")),
+				nflogAttribute(nfulaPayload, payload),
+			),
+			expectedPrefix:  "AZURE-NPM-INGRESS-DROP",
+			expectedPayload: payload,
+		},
+		{
+			name: "nested flag is ignored",
+			data: nflogMessage(
+				nflogAttribute(unix.NLA_F_NESTED|nfulaPrefix, []byte("AZURE-NPM-EGRESS-DROP\x00")),
+			),
+			expectedPrefix: "AZURE-NPM-EGRESS-DROP",
+		},
+		{
+			name: "no attributes",
+			data: nflogMessage(),
+		},
+		{
+			name:    "too short for header",
+			data:    []byte{unix.AF_INET},
+			wantErr: true,
+		},
+		{
+			name:    "attribute longer than message",
+			data:    nflogMessage(nflogAttribute(nfulaPayload, payload))[:sizeofNfgenmsg+10],
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, payload, err := parsePacketMessage(tt.data)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedPrefix, prefix)
+			require.Equal(t, tt.expectedPayload, payload)
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package denylog
+
+import "errors"
+
+var errUnsupported = errors.New("deny logging is only supported on Linux")
+
+// Listener is a no-op on Windows, where NPM doesn't log dropped packets.
+type Listener struct{}
+
+func NewListener(_ *Recorder) *Listener {
+	return &Listener{}
+}
+
+// Run returns an error since deny logging is only supported on Linux.
+func (l *Listener) Run(_ <-chan struct{}) error {
+	return errUnsupported
+}
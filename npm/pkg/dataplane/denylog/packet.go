@@ -0,0 +1,70 @@
+package denylog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+const (
+	minIPv4HeaderLength = 20
+	ipv4Version         = 4
+
+	protocolICMP = 1
+	protocolTCP  = 6
+	protocolUDP  = 17
+	protocolSCTP = 132
+)
+
+var (
+	errPacketTooShort = errors.New("packet is too short for an IPv4 header")
+	errNotIPv4        = errors.New("packet is not IPv4")
+)
+
+// ParsePacket decodes the addresses, protocol, and ports of an IPv4 packet.
+// The packet may be truncated after the first 4 bytes of the transport header.
+func ParsePacket(b []byte) (*Packet, error) {
+	if len(b) < minIPv4HeaderLength {
+		return nil, errPacketTooShort
+	}
+	if b[0]>>4 != ipv4Version {
+		return nil, errNotIPv4
+	}
+	headerLength := int(b[0]&0x0f) * 4
+	if headerLength < minIPv4HeaderLength || len(b) < headerLength {
+		return nil, errPacketTooShort
+	}
+
+	packet := &Packet{
+		Protocol: protocolName(b[9]),
+		SrcIP:    net.IP(b[12:16]).String(),
+		DstIP:    net.IP(b[16:20]).String(),
+	}
+
+	switch b[9] {
+	case protocolTCP, protocolUDP, protocolSCTP:
+		ports := b[headerLength:]
+		if len(ports) >= 4 {
+			packet.SrcPort = int(binary.BigEndian.Uint16(ports[0:2]))
+			packet.DstPort = int(binary.BigEndian.Uint16(ports[2:4]))
+		}
+	}
+	return packet, nil
+}
+
+// protocol names are the same as in policies.Protocol
+func protocolName(protocol byte) string {
+	switch protocol {
+	case protocolICMP:
+		return "ICMP"
+	case protocolTCP:
+		return "TCP"
+	case protocolUDP:
+		return "UDP"
+	case protocolSCTP:
+		return "SCTP"
+	default:
+		return fmt.Sprint(protocol)
+	}
+}
@@ -0,0 +1,93 @@
+package denylog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ipv4Header returns a 20 byte IPv4 header for the protocol, followed by the extra bytes
+func ipv4Header(protocol byte, extra ...byte) []byte {
+	b := make([]byte, minIPv4HeaderLength)
+	b[0] = 0x45 // version 4 and header length of 5 words
+	b[9] = protocol
+	copy(b[12:16], []byte{10, 0, 0, 2})
+	copy(b[16:20], []byte{10, 0, 0, 1})
+	return append(b, extra...)
+}
+
+func TestParsePacket(t *testing.T) {
+	withOptions := ipv4Header(protocolTCP, 0, 0, 0, 0, 0x9c, 0x40, 0x00, 0x50)
+	withOptions[0] = 0x46 // header length of 6 words, so the first 4 extra bytes are options
+
+	tests := []struct {
+		name     string
+		packet   []byte
+		expected *Packet
+		wantErr  bool
+	}{
+		{
+			name:     "tcp",
+			packet:   ipv4Header(protocolTCP, 0x9c, 0x40, 0x00, 0x50),
+			expected: &Packet{Protocol: "TCP", SrcIP: "10.0.0.2", SrcPort: 40000, DstIP: "10.0.0.1", DstPort: 80},
+		},
+		{
+			name:     "udp",
+			packet:   ipv4Header(protocolUDP, 0x9c, 0x41, 0x00, 0x35),
+			expected: &Packet{Protocol: "UDP", SrcIP: "10.0.0.2", SrcPort: 40001, DstIP: "10.0.0.1", DstPort: 53},
+		},
+		{
+			name:     "sctp",
+			packet:   ipv4Header(protocolSCTP, 0x00, 0x01, 0x00, 0x02),
+			expected: &Packet{Protocol: "SCTP", SrcIP: "10.0.0.2", SrcPort: 1, DstIP: "10.0.0.1", DstPort: 2},
+		},
+		{
+			name:     "header with options",
+			packet:   withOptions,
+			expected: &Packet{Protocol: "TCP", SrcIP: "10.0.0.2", SrcPort: 40000, DstIP: "10.0.0.1", DstPort: 80},
+		},
+		{
+			name:     "icmp has no ports",
+			packet:   ipv4Header(protocolICMP, 8, 0, 0, 0),
+			expected: &Packet{Protocol: "ICMP", SrcIP: "10.0.0.2", DstIP: "10.0.0.1"},
+		},
+		{
+			name:     "unknown protocol",
+			packet:   ipv4Header(47),
+			expected: &Packet{Protocol: "47", SrcIP: "10.0.0.2", DstIP: "10.0.0.1"},
+		},
+		{
+			name:     "truncated transport header",
+			packet:   ipv4Header(protocolTCP, 0x9c),
+			expected: &Packet{Protocol: "TCP", SrcIP: "10.0.0.2", DstIP: "10.0.0.1"},
+		},
+		{
+			name:    "too short",
+			packet:  ipv4Header(protocolTCP)[:10],
+			wantErr: true,
+		},
+		{
+			name:    "header length longer than packet",
+			packet:  append([]byte{0x4f}, ipv4Header(protocolTCP)[1:]...),
+			wantErr: true,
+		},
+		{
+			name:    "ipv6",
+			packet:  append([]byte{0x60}, ipv4Header(protocolTCP)[1:]...),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			packet, err := ParsePacket(tt.packet)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, packet)
+		})
+	}
+}
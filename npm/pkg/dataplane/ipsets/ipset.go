@@ -49,6 +49,8 @@ func (setMetadata *IPSetMetadata) GetPrefixName() string {
 	switch setMetadata.Type {
 	case CIDRBlocks:
 		return fmt.Sprintf("%s%s", util.CIDRPrefix, setMetadata.Name)
+	case FQDN:
+		return fmt.Sprintf("%s%s", util.FQDNPrefix, setMetadata.Name)
 	case Namespace:
 		return fmt.Sprintf("%s%s", util.NamespacePrefix, setMetadata.Name)
 	case NamedPorts:
@@ -74,6 +76,8 @@ func (setMetadata *IPSetMetadata) GetSetKind() SetKind {
 	switch setMetadata.Type {
 	case CIDRBlocks:
 		return HashSet
+	case FQDN:
+		return HashSet
 	case Namespace:
 		return HashSet
 	case NamedPorts:
@@ -149,6 +153,9 @@ const (
 	NestedLabelOfPod SetType = 7
 	// CIDRBlocks holds CIDR blocks
 	CIDRBlocks SetType = 8
+	// FQDN holds the addresses which a domain name resolved to.
+	// Members are added and expired by the FQDN resolver instead of the translation engine.
+	FQDN SetType = 9
 	// Unknown const for unknown string
 	Unknown string = "unknown"
 )
@@ -164,6 +171,7 @@ var (
 		NamedPorts:               "NamedPorts",
 		NestedLabelOfPod:         "NestedLabelOfPod",
 		CIDRBlocks:               "CIDRBlocks",
+		FQDN:                     "FQDN",
 	}
 	// ErrIPSetInvalidKind is returned when IPSet kind is invalid
 	ErrIPSetInvalidKind = errors.New("invalid IPSet Kind")
@@ -48,6 +48,8 @@ type IPSetManager struct {
 type IPSetManagerCfg struct {
 	IPSetMode   IPSetMode
 	NetworkName string
+	// UseNFTables renders ipsets into nftables sets instead of ipset. Only affects Linux
+	UseNFTables bool
 }
 
 func NewIPSetManager(iMgrCfg *IPSetManagerCfg, ioShim *common.IOShim) *IPSetManager {
@@ -143,6 +145,26 @@ func (iMgr *IPSetManager) GetIPSet(name string) *IPSet {
 	return iMgr.setMap[name]
 }
 
+// HasMember needs the prefixed ipset name, and returns whether the ip is a member of the hash set or one of the list's member sets
+func (iMgr *IPSetManager) HasMember(name, ip string) bool {
+	iMgr.Lock()
+	defer iMgr.Unlock()
+	if !iMgr.exists(name) {
+		return false
+	}
+	set := iMgr.setMap[name]
+	if set.Kind == HashSet {
+		_, ok := set.IPPodKey[ip]
+		return ok
+	}
+	for _, member := range set.MemberIPSets {
+		if _, ok := member.IPPodKey[ip]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 // AddReference creates the set if necessary and adds relevant reference
 // it throws an error if the set and reference type are an invalid combination
 func (iMgr *IPSetManager) AddReference(setMetadata *IPSetMetadata, referenceName string, referenceType ReferenceType) error {
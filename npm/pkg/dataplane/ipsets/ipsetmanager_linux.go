@@ -85,6 +85,10 @@ var (
 		If a flush fails, we could update the num entries for that set, but that would be a lot of overhead.
 */
 func (iMgr *IPSetManager) resetIPSets() error {
+	if iMgr.iMgrCfg.UseNFTables {
+		return iMgr.resetIPSetsNFT()
+	}
+
 	listCommand := iMgr.ioShim.Exec.Command(ipsetCommand, ipsetListFlag, ipsetNameFlag)
 	grepCommand := iMgr.ioShim.Exec.Command(ioutil.Grep, azureNPMPrefix)
 	azureIPSets, haveAzureIPSets, commandError := ioutil.PipeCommandToGrep(listCommand, grepCommand)
@@ -247,6 +251,10 @@ example where every set in add/update cache should have ip 1.2.3.4 and 2.3.4.5:
 
 */
 func (iMgr *IPSetManager) applyIPSets() error {
+	if iMgr.iMgrCfg.UseNFTables {
+		return iMgr.applyIPSetsNFT()
+	}
+
 	var saveFile []byte
 	var saveError error
 	if len(iMgr.toAddOrUpdateCache) > 0 {
@@ -540,7 +548,7 @@ func (iMgr *IPSetManager) createSetForApply(creator *ioutil.FileCreator, set *IP
 	}
 
 	specs := []string{ipsetCreateFlag, set.HashedName, ipsetExistFlag, methodFlag}
-	if set.Type == CIDRBlocks {
+	if set.Type == CIDRBlocks || set.Type == FQDN {
 		specs = append(specs, ipsetMaxelemName, ipsetMaxelemNum)
 	}
 
@@ -579,6 +579,7 @@ func TestCreateForAllSetTypes(t *testing.T) {
 	iMgr.CreateIPSets([]*IPSetMetadata{TestKVPodSet.Metadata})
 	iMgr.CreateIPSets([]*IPSetMetadata{TestNamedportSet.Metadata})
 	iMgr.CreateIPSets([]*IPSetMetadata{TestCIDRSet.Metadata})
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestFQDNSet.Metadata}, "20.0.0.1", "d"))
 	require.NoError(t, iMgr.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata}, []*IPSetMetadata{TestNSSet.Metadata, TestKeyPodSet.Metadata}))
 	require.NoError(t, iMgr.AddToLists([]*IPSetMetadata{TestKVNSList.Metadata}, []*IPSetMetadata{TestKVPodSet.Metadata}))
 	iMgr.CreateIPSets([]*IPSetMetadata{TestNestedLabelList.Metadata})
@@ -592,12 +593,14 @@ func TestCreateForAllSetTypes(t *testing.T) {
 		fmt.Sprintf("-N %s --exist nethash", TestKVPodSet.HashedName),
 		fmt.Sprintf("-N %s --exist hash:ip,port", TestNamedportSet.HashedName),
 		fmt.Sprintf("-N %s --exist nethash maxelem 4294967295", TestCIDRSet.HashedName),
+		fmt.Sprintf("-N %s --exist nethash maxelem 4294967295", TestFQDNSet.HashedName),
 		fmt.Sprintf("-N %s --exist setlist", TestKeyNSList.HashedName),
 		fmt.Sprintf("-N %s --exist setlist", TestKVNSList.HashedName),
 		fmt.Sprintf("-N %s --exist setlist", TestNestedLabelList.HashedName),
 		fmt.Sprintf("-A %s 10.0.0.0", TestNSSet.HashedName),
 		fmt.Sprintf("-A %s 10.0.0.1", TestNSSet.HashedName),
 		fmt.Sprintf("-A %s 10.0.0.5", TestKeyPodSet.HashedName),
+		fmt.Sprintf("-A %s 20.0.0.1", TestFQDNSet.HashedName),
 		fmt.Sprintf("-A %s %s", TestKeyNSList.HashedName, TestNSSet.HashedName),
 		fmt.Sprintf("-A %s %s", TestKeyNSList.HashedName, TestKeyPodSet.HashedName),
 		fmt.Sprintf("-A %s %s", TestKVNSList.HashedName, TestKVPodSet.HashedName),
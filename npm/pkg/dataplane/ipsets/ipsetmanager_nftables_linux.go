@@ -0,0 +1,397 @@
+package ipsets
+
+// This file contains code for the nftables implementation of resetting and applying IPSets.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/parse"
+	"github.com/Azure/azure-container-networking/npm/util"
+	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+	"github.com/Azure/azure-container-networking/npm/util/ioutil"
+	"k8s.io/klog"
+)
+
+const (
+	nftListFlag = "list"
+	nftSetsFlag = "sets"
+	// constant for parsing nft list sets, which has lines like "	set azure-npm-123 {"
+	nftSetStringWithSpace = "set "
+
+	nftAddressType   = "ipv4_addr"
+	nftNamedPortType = "ipv4_addr . inet_proto . inet_service"
+	// ipset defaults to tcp for named port members without a protocol
+	nftDefaultProtocol = "tcp"
+
+	ipv4Bits = 32
+)
+
+var (
+	nftNoSuchFileDefinition = ioutil.NewErrorDefinition("No such file or directory")
+	nftSetInUseDefinition   = ioutil.NewErrorDefinition("Device or resource busy")
+)
+
+/*
+	IPSets are rendered into sets of the azure-npm table, which the PolicyManager creates on bootup:
+	- hash sets become interval sets of addresses (named port sets become sets of address . protocol . port)
+	- nft sets can't contain other sets, so list sets become interval sets of the addresses of their members
+
+	Since nft applies all lines of a file in one transaction, each dirty set is flushed and written again with all its members,
+	and the lists in the kernel which contain a dirty set are written again too.
+
+	overall error handling for nft file:
+	nothing is applied when a line fails, so when recovering from a line failure, we only skip the failed line or its set.
+	- skip the add/flush/add element lines of a set if any of them fails
+	  - checks if the set exists with a different type, but performs the same handling for any error
+	- skip the delete of a set if it fails, and mark it as a failure (TODO)
+	  - checks if the set is in use by a rule, but performs the same handling for any error
+
+	example:
+		add table ip azure-npm
+		add set ip azure-npm azure-npm-123 { type ipv4_addr ; flags interval ; }
+		flush set ip azure-npm azure-npm-123
+		add element ip azure-npm azure-npm-123 { 10.0.0.0/16, 10.1.0.4 }
+		add set ip azure-npm azure-npm-456 { type ipv4_addr . inet_proto . inet_service ; }
+		flush set ip azure-npm azure-npm-456
+		add element ip azure-npm azure-npm-456 { 10.1.0.4 . tcp . 8080 }
+		delete set ip azure-npm azure-npm-789
+*/
+
+// resetIPSetsNFT deletes all sets in the azure-npm table
+func (iMgr *IPSetManager) resetIPSetsNFT() error {
+	listCommand := iMgr.ioShim.Exec.Command(util.Nftables, nftListFlag, nftSetsFlag, util.NftablesFamily, util.NftablesAzureTable)
+	grepCommand := iMgr.ioShim.Exec.Command(ioutil.Grep, nftSetStringWithSpace+azureNPMPrefix)
+	azureSets, haveAzureSets, commandError := ioutil.PipeCommandToGrep(listCommand, grepCommand)
+	if commandError != nil {
+		return npmerrors.SimpleErrorWrapper("failed to run nft list sets for resetting IPSets (prometheus metrics may be off now)", commandError)
+	}
+	if !haveAzureSets {
+		return nil
+	}
+	creator := iMgr.fileCreatorForNFTReset(azureSets)
+	if err := creator.RunCommandWithFile(util.Nftables, util.NftablesFileFlag, util.NftablesStdinFile); err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to run nft for resetting IPSets", err)
+	}
+	return nil
+}
+
+// this needs to be a separate function because we need to check creator contents in UTs
+func (iMgr *IPSetManager) fileCreatorForNFTReset(nftListOutput []byte) *ioutil.FileCreator {
+	creator := ioutil.NewFileCreator(iMgr.ioShim, maxTryCount, util.NftablesLineErrorPattern)
+	creator.UseRunFileLineNumbers()
+	readIndex := 0
+	var line []byte
+	for readIndex < len(nftListOutput) {
+		line, readIndex = parse.Line(readIndex, nftListOutput)
+		fields := strings.Fields(string(line))
+		if len(fields) < 2 || fields[0] != strings.TrimSpace(nftSetStringWithSpace) {
+			klog.Errorf("[RESET-IPSETS] expected a set line in nft list sets output, but got the following line: %s", string(line))
+			continue
+		}
+		hashedSetName := fields[1]
+		errorHandlers := []*ioutil.LineErrorHandler{
+			{
+				Definition: ioutil.AlwaysMatchDefinition,
+				Method:     ioutil.Skip,
+				Callback: func() {
+					klog.Errorf("[RESET-IPSETS] marking delete for set %s as a failure", hashedSetName)
+					// TODO mark the set as a failure and reconcile what rule is referring to it
+				},
+			},
+		}
+		creator.AddLine(sectionID(destroySectionPrefix, hashedSetName), errorHandlers, nftSetSpecs("delete", hashedSetName)...)
+	}
+	return creator
+}
+
+func (iMgr *IPSetManager) applyIPSetsNFT() error {
+	creator := iMgr.fileCreatorForNFTApply(maxTryCount)
+	if err := creator.RunCommandWithFile(util.Nftables, util.NftablesFileFlag, util.NftablesStdinFile); err != nil {
+		return npmerrors.SimpleErrorWrapper("nft failed when applying ipsets", err)
+	}
+	return nil
+}
+
+func (iMgr *IPSetManager) fileCreatorForNFTApply(maxTryCount int) *ioutil.FileCreator {
+	creator := ioutil.NewFileCreator(iMgr.ioShim, maxTryCount, util.NftablesLineErrorPattern)
+	creator.UseRunFileLineNumbers()
+	creator.AddLine("", nil, "add", "table", util.NftablesFamily, util.NftablesAzureTable)
+
+	// 1. write the dirty sets and the lists containing them
+	for _, prefixedName := range iMgr.setsToWriteForNFT() {
+		iMgr.writeSetForNFT(creator, iMgr.setMap[prefixedName])
+	}
+
+	// 2. delete the sets in the delete cache
+	for _, prefixedName := range sortedNames(iMgr.toDeleteCache) {
+		prefixedName := prefixedName // to appease golint complaints about function literal
+		errorHandlers := []*ioutil.LineErrorHandler{
+			{
+				Definition: nftSetInUseDefinition,
+				Method:     ioutil.Skip,
+				Callback: func() {
+					klog.Errorf("skipping delete line for set %s since the set is in use by a rule", prefixedName)
+					// TODO mark the set as a failure and reconcile what rule is referring to it
+				},
+			},
+			{
+				Definition: nftNoSuchFileDefinition,
+				Method:     ioutil.Skip,
+				Callback: func() {
+					klog.Infof("skipping delete line for set %s since the set doesn't exist", prefixedName)
+				},
+			},
+			{
+				Definition: ioutil.AlwaysMatchDefinition,
+				Method:     ioutil.Skip,
+				Callback: func() {
+					klog.Errorf("skipping delete line for set %s due to unknown error", prefixedName)
+				},
+			},
+		}
+		creator.AddLine(sectionID(destroySectionPrefix, prefixedName), errorHandlers, nftSetSpecs("delete", util.GetHashedName(prefixedName))...)
+	}
+	return creator
+}
+
+// setsToWriteForNFT returns the sorted names of the sets in the toAddOrUpdateCache,
+// along with the lists in the kernel that contain one of them, since their elements are copied from their members.
+func (iMgr *IPSetManager) setsToWriteForNFT() []string {
+	setsToWrite := make(map[string]struct{}, len(iMgr.toAddOrUpdateCache))
+	for prefixedName := range iMgr.toAddOrUpdateCache {
+		setsToWrite[prefixedName] = struct{}{}
+	}
+	for _, set := range iMgr.setMap {
+		if set.Kind != ListSet || !iMgr.shouldBeInKernel(set) {
+			continue
+		}
+		for memberName := range set.MemberIPSets {
+			if _, ok := iMgr.toAddOrUpdateCache[memberName]; ok {
+				setsToWrite[set.Name] = struct{}{}
+				break
+			}
+		}
+	}
+	return sortedNames(setsToWrite)
+}
+
+func (iMgr *IPSetManager) writeSetForNFT(creator *ioutil.FileCreator, set *IPSet) {
+	prefixedName := set.Name // to appease golint complaints about function literal
+	errorHandlers := []*ioutil.LineErrorHandler{
+		{
+			Definition: nftNoSuchFileDefinition,
+			Method:     ioutil.SkipSection,
+			Callback: func() {
+				klog.Errorf("skipping set %s since the table doesn't exist", prefixedName)
+			},
+		},
+		{
+			Definition: ioutil.AlwaysMatchDefinition,
+			Method:     ioutil.SkipSection,
+			Callback: func() {
+				klog.Errorf("skipping set %s due to unknown error, possibly since the set already exists with a different type", prefixedName)
+				// TODO mark the set as a failure and handle this
+			},
+		},
+	}
+	sectionID := sectionID(addOrUpdateSectionPrefix, prefixedName)
+
+	var elements []string
+	createSpecs := nftSetSpecs("add", set.HashedName)
+	if set.Type == NamedPorts {
+		createSpecs = append(createSpecs, "{", "type", nftNamedPortType, ";", "}")
+		elements = nftNamedPortElements(set.IPPodKey)
+	} else {
+		createSpecs = append(createSpecs, "{", "type", nftAddressType, ";", "flags", "interval", ";", "}")
+		members := make([]string, 0, len(set.IPPodKey))
+		if set.Kind == HashSet {
+			for ip := range set.IPPodKey {
+				members = append(members, ip)
+			}
+		} else {
+			for _, member := range set.MemberIPSets {
+				for ip := range member.IPPodKey {
+					members = append(members, ip)
+				}
+			}
+		}
+		elements = nftAddressElements(members)
+	}
+
+	creator.AddLine(sectionID, errorHandlers, createSpecs...)
+	creator.AddLine(sectionID, errorHandlers, nftSetSpecs("flush", set.HashedName)...)
+	if len(elements) > 0 {
+		elementSpecs := []string{"add", "element", util.NftablesFamily, util.NftablesAzureTable, set.HashedName, "{", strings.Join(elements, ", "), "}"}
+		creator.AddLine(sectionID, errorHandlers, elementSpecs...)
+	}
+}
+
+func nftSetSpecs(verb, hashedName string) []string {
+	return []string{verb, "set", util.NftablesFamily, util.NftablesAzureTable, hashedName}
+}
+
+// nftNamedPortElements converts members like 10.0.0.1,TCP:8080 to elements like 10.0.0.1 . tcp . 8080
+func nftNamedPortElements(ipPodKey map[string]string) []string {
+	elementSet := make(map[string]struct{}, len(ipPodKey))
+	for member := range ipPodKey {
+		ipAndPort := strings.Split(member, ",")
+		if len(ipAndPort) != 2 {
+			klog.Errorf("skipping named port member %s without a port", member)
+			continue
+		}
+		protocol := nftDefaultProtocol
+		port := ipAndPort[1]
+		if protocolAndPort := strings.Split(port, util.IpsetLabelDelimter); len(protocolAndPort) == 2 {
+			protocol = strings.ToLower(protocolAndPort[0])
+			port = protocolAndPort[1]
+		}
+		elementSet[fmt.Sprintf("%s . %s . %s", ipAndPort[0], protocol, port)] = struct{}{}
+	}
+	return sortedNames(elementSet)
+}
+
+// addressInterval is a range of IPv4 addresses from a member like 10.0.0.0/16, 10.0.0.1, or 10.0.0.0/24 nomatch
+type addressInterval struct {
+	start     uint32
+	end       uint32
+	prefixLen int
+	nomatch   bool
+}
+
+// nftAddressElements converts members into elements of an interval set, which can't overlap.
+// Like in a hash:net ipset, the most specific member decides whether an address is in the set,
+// so nomatch members carve holes into less specific members.
+func nftAddressElements(members []string) []string {
+	intervals := make([]addressInterval, 0, len(members))
+	hasNomatch := false
+	for _, member := range members {
+		interval, ok := parseAddressInterval(member)
+		if !ok {
+			klog.Errorf("skipping invalid member %s of address set", member)
+			continue
+		}
+		hasNomatch = hasNomatch || interval.nomatch
+		intervals = append(intervals, interval)
+	}
+	if hasNomatch {
+		intervals = mostSpecificIntervals(intervals)
+	}
+
+	merged := mergeIntervals(intervals)
+	elements := make([]string, 0, len(merged))
+	for _, interval := range merged {
+		elements = append(elements, interval.nftString())
+	}
+	return elements
+}
+
+func parseAddressInterval(member string) (addressInterval, bool) {
+	fields := strings.Fields(member)
+	if len(fields) == 0 || len(fields) > 2 || (len(fields) == 2 && fields[1] != util.IpsetNomatch) {
+		return addressInterval{}, false
+	}
+	interval := addressInterval{nomatch: len(fields) == 2}
+
+	address := fields[0]
+	if !strings.Contains(address, "/") {
+		address += fmt.Sprintf("/%d", ipv4Bits)
+	}
+	_, ipNet, err := net.ParseCIDR(address)
+	if err != nil || ipNet.IP.To4() == nil {
+		return addressInterval{}, false
+	}
+	interval.prefixLen, _ = ipNet.Mask.Size()
+	interval.start = binary.BigEndian.Uint32(ipNet.IP.To4())
+	interval.end = interval.start | ^binary.BigEndian.Uint32(net.IP(ipNet.Mask).To4())
+	return interval, true
+}
+
+// mostSpecificIntervals splits the intervals at all of their boundaries,
+// and returns the pieces whose most specific interval isn't nomatch.
+func mostSpecificIntervals(intervals []addressInterval) []addressInterval {
+	boundarySet := make(map[uint64]struct{}, 2*len(intervals))
+	for _, interval := range intervals {
+		boundarySet[uint64(interval.start)] = struct{}{}
+		boundarySet[uint64(interval.end)+1] = struct{}{}
+	}
+	boundaries := make([]uint64, 0, len(boundarySet))
+	for boundary := range boundarySet {
+		boundaries = append(boundaries, boundary)
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i] < boundaries[j] })
+
+	pieces := make([]addressInterval, 0, len(boundaries))
+	for k := 0; k+1 < len(boundaries); k++ {
+		start := uint32(boundaries[k])
+		end := uint32(boundaries[k+1] - 1)
+		mostSpecificLen := -1
+		included := false
+		for _, interval := range intervals {
+			if interval.start > start || interval.end < end || interval.prefixLen < mostSpecificLen {
+				continue
+			}
+			if interval.prefixLen > mostSpecificLen {
+				included = !interval.nomatch
+			} else {
+				// ipset prefers nomatch for the same CIDR
+				included = included && !interval.nomatch
+			}
+			mostSpecificLen = interval.prefixLen
+		}
+		if included {
+			pieces = append(pieces, addressInterval{start: start, end: end})
+		}
+	}
+	return pieces
+}
+
+// mergeIntervals sorts the intervals and merges overlapping and adjacent ones
+func mergeIntervals(intervals []addressInterval) []addressInterval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].start < intervals[j].start })
+	merged := make([]addressInterval, 0, len(intervals))
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && uint64(interval.start) <= uint64(merged[last].end)+1 {
+			if interval.end > merged[last].end {
+				merged[last].end = interval.end
+			}
+			continue
+		}
+		merged = append(merged, addressInterval{start: interval.start, end: interval.end})
+	}
+	return merged
+}
+
+// nftString returns the interval like 10.0.0.1, 10.0.0.0/24, or 10.0.0.1-10.0.0.6
+func (interval addressInterval) nftString() string {
+	start := uint32ToIP(interval.start)
+	if interval.start == interval.end {
+		return start
+	}
+	size := uint64(interval.end) - uint64(interval.start) + 1
+	hostBits := bits.TrailingZeros64(size)
+	if size == 1<<hostBits && interval.start&uint32(size-1) == 0 {
+		return fmt.Sprintf("%s/%d", start, ipv4Bits-hostBits)
+	}
+	return fmt.Sprintf("%s-%s", start, uint32ToIP(interval.end))
+}
+
+func uint32ToIP(address uint32) string {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, address)
+	return ip.String()
+}
+
+func sortedNames(names map[string]struct{}) []string {
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,202 @@
+package ipsets
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+	dptestutils "github.com/Azure/azure-container-networking/npm/pkg/dataplane/testutils"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	nftCfg = &IPSetManagerCfg{
+		IPSetMode:   ApplyAllIPSets,
+		NetworkName: "azure",
+		UseNFTables: true,
+	}
+
+	fakeNFTCommand = testutils.TestCmd{Cmd: []string{"nft", "-f", "/dev/stdin"}}
+)
+
+func TestNFTFileCreatorForApply(t *testing.T) {
+	calls := []testutils.TestCmd{fakeNFTCommand}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	iMgr := NewIPSetManager(nftCfg, ioshim)
+
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.0", "a"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.1", "b"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestKeyPodSet.Metadata}, "10.0.0.5", "c"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNamedportSet.Metadata}, "10.0.0.5,TCP:8080", "c"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestCIDRSet.Metadata}, "10.1.0.0/16", ""))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestCIDRSet.Metadata}, "10.1.2.0/24 nomatch", ""))
+	require.NoError(t, iMgr.AddToLists([]*IPSetMetadata{TestKeyNSList.Metadata}, []*IPSetMetadata{TestNSSet.Metadata, TestKeyPodSet.Metadata}))
+	iMgr.CreateIPSets([]*IPSetMetadata{TestKVPodSet.Metadata})
+	iMgr.toDeleteCache[TestNestedLabelList.PrefixName] = struct{}{}
+
+	creator := iMgr.fileCreatorForNFTApply(len(calls))
+	actualLines := strings.Split(creator.ToString(), "\n")
+	expectedLines := []string{
+		"add table ip azure-npm",
+		fmt.Sprintf("add set ip azure-npm %s { type ipv4_addr ; flags interval ; }", TestCIDRSet.HashedName),
+		fmt.Sprintf("flush set ip azure-npm %s", TestCIDRSet.HashedName),
+		fmt.Sprintf("add element ip azure-npm %s { 10.1.0.0/23, 10.1.3.0-10.1.255.255 }", TestCIDRSet.HashedName),
+		fmt.Sprintf("add set ip azure-npm %s { type ipv4_addr . inet_proto . inet_service ; }", TestNamedportSet.HashedName),
+		fmt.Sprintf("flush set ip azure-npm %s", TestNamedportSet.HashedName),
+		fmt.Sprintf("add element ip azure-npm %s { 10.0.0.5 . tcp . 8080 }", TestNamedportSet.HashedName),
+		fmt.Sprintf("add set ip azure-npm %s { type ipv4_addr ; flags interval ; }", TestNSSet.HashedName),
+		fmt.Sprintf("flush set ip azure-npm %s", TestNSSet.HashedName),
+		fmt.Sprintf("add element ip azure-npm %s { 10.0.0.0/31 }", TestNSSet.HashedName),
+		fmt.Sprintf("add set ip azure-npm %s { type ipv4_addr ; flags interval ; }", TestKeyNSList.HashedName),
+		fmt.Sprintf("flush set ip azure-npm %s", TestKeyNSList.HashedName),
+		fmt.Sprintf("add element ip azure-npm %s { 10.0.0.0/31, 10.0.0.5 }", TestKeyNSList.HashedName),
+		fmt.Sprintf("add set ip azure-npm %s { type ipv4_addr ; flags interval ; }", TestKeyPodSet.HashedName),
+		fmt.Sprintf("flush set ip azure-npm %s", TestKeyPodSet.HashedName),
+		fmt.Sprintf("add element ip azure-npm %s { 10.0.0.5 }", TestKeyPodSet.HashedName),
+		fmt.Sprintf("add set ip azure-npm %s { type ipv4_addr ; flags interval ; }", TestKVPodSet.HashedName),
+		fmt.Sprintf("flush set ip azure-npm %s", TestKVPodSet.HashedName),
+		fmt.Sprintf("delete set ip azure-npm %s", TestNestedLabelList.HashedName),
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+
+	wasFileAltered, err := creator.RunCommandOnceWithFile("nft", "-f", "/dev/stdin")
+	require.NoError(t, err, "nft should be successful")
+	require.False(t, wasFileAltered, "file should not be altered")
+}
+
+func TestNFTApplyIPSetsSkipsFailedSet(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{
+			Cmd:      []string{"nft", "-f", "/dev/stdin"},
+			Stdout:   "/dev/stdin:3:1-40: Error: Could not process rule: File exists",
+			ExitCode: 1,
+		},
+		fakeNFTCommand,
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	iMgr := NewIPSetManager(nftCfg, ioshim)
+
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestNSSet.Metadata}, "10.0.0.0", "a"))
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{TestKeyPodSet.Metadata}, "10.0.0.5", "c"))
+
+	creator := iMgr.fileCreatorForNFTApply(len(calls))
+	wasFileAltered, err := creator.RunCommandOnceWithFile("nft", "-f", "/dev/stdin")
+	require.Error(t, err, "nft should fail")
+	require.True(t, wasFileAltered, "file should be altered")
+
+	// the set with the failed line is skipped, but the lines of the other set are kept
+	actualLines := strings.Split(creator.ToString(), "\n")
+	expectedLines := []string{
+		"add table ip azure-npm",
+		fmt.Sprintf("add set ip azure-npm %s { type ipv4_addr ; flags interval ; }", TestKeyPodSet.HashedName),
+		fmt.Sprintf("flush set ip azure-npm %s", TestKeyPodSet.HashedName),
+		fmt.Sprintf("add element ip azure-npm %s { 10.0.0.5 }", TestKeyPodSet.HashedName),
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+
+	wasFileAltered, err = creator.RunCommandOnceWithFile("nft", "-f", "/dev/stdin")
+	require.NoError(t, err, "nft should be successful")
+	require.False(t, wasFileAltered, "file should not be altered")
+}
+
+func TestNFTFileCreatorForReset(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	iMgr := NewIPSetManager(nftCfg, ioshim)
+
+	nftListOutput := []byte("\tset azure-npm-123456 {\n\tset azure-npm-987654 {\n")
+	creator := iMgr.fileCreatorForNFTReset(nftListOutput)
+	actualLines := strings.Split(creator.ToString(), "\n")
+	expectedLines := []string{
+		"delete set ip azure-npm azure-npm-123456",
+		"delete set ip azure-npm azure-npm-987654",
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+}
+
+func TestResetIPSetsNFT(t *testing.T) {
+	calls := []testutils.TestCmd{
+		{Cmd: []string{"nft", "list", "sets", "ip", "azure-npm"}, PipedToCommand: true},
+		{Cmd: []string{"grep", "set azure-npm-"}, Stdout: "\tset azure-npm-123456 {\n"},
+		fakeNFTCommand,
+	}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	iMgr := NewIPSetManager(nftCfg, ioshim)
+
+	require.NoError(t, iMgr.resetIPSets())
+}
+
+func TestNFTAddressElements(t *testing.T) {
+	tests := []struct {
+		name     string
+		members  []string
+		expected []string
+	}{
+		{
+			name:     "no members",
+			members:  nil,
+			expected: []string{},
+		},
+		{
+			name:     "ips are merged into cidrs and ranges",
+			members:  []string{"10.0.0.3", "10.0.0.1", "10.0.0.2", "10.0.0.4", "10.0.0.9"},
+			expected: []string{"10.0.0.1-10.0.0.4", "10.0.0.9"},
+		},
+		{
+			name:     "overlapping cidrs are merged",
+			members:  []string{"10.0.0.0/24", "10.0.0.128/25", "10.0.1.0/24"},
+			expected: []string{"10.0.0.0/23"},
+		},
+		{
+			name:     "nomatch carves a hole",
+			members:  []string{"10.0.0.0/24", "10.0.0.0/26 nomatch"},
+			expected: []string{"10.0.0.64-10.0.0.255"},
+		},
+		{
+			name:     "more specific cidr within nomatch is included",
+			members:  []string{"10.0.0.0/16", "10.0.1.0/24 nomatch", "10.0.1.5"},
+			expected: []string{"10.0.0.0/24", "10.0.1.5", "10.0.2.0-10.0.255.255"},
+		},
+		{
+			name:     "nomatch wins over the same cidr",
+			members:  []string{"10.0.0.0/24", "10.0.0.0/24 nomatch"},
+			expected: []string{},
+		},
+		{
+			name:     "whole address space",
+			members:  []string{"0.0.0.0/0", "255.255.255.255"},
+			expected: []string{"0.0.0.0/0"},
+		},
+		{
+			name:     "invalid members are skipped",
+			members:  []string{"10.0.0.1", "abc", "10.0.0.0/24 bad"},
+			expected: []string{"10.0.0.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, nftAddressElements(tt.members))
+		})
+	}
+}
+
+func TestNFTNamedPortElements(t *testing.T) {
+	ipPodKey := map[string]string{
+		"10.0.0.1,TCP:8080": "a",
+		"10.0.0.1,udp:53":   "a",
+		"10.0.0.2,80":       "b",
+		"10.0.0.3":          "c",
+	}
+	expected := []string{"10.0.0.1 . tcp . 8080", "10.0.0.1 . udp . 53", "10.0.0.2 . tcp . 80"}
+	require.Equal(t, expected, nftNamedPortElements(ipPodKey))
+}
@@ -944,6 +944,20 @@ func TestRemoveFromList(t *testing.T) {
 	require.Equal(t, 0, len(set.MemberIPSets))
 }
 
+func TestHasMember(t *testing.T) {
+	iMgr := NewIPSetManager(applyOnNeedCfg, common.NewMockIOShim([]testutils.TestCmd{}))
+	setMetadata := NewIPSetMetadata(testSetName, Namespace)
+	listMetadata := NewIPSetMetadata(testListName, KeyLabelOfNamespace)
+	require.NoError(t, iMgr.AddToSets([]*IPSetMetadata{setMetadata}, testPodIP, testPodKey))
+	require.NoError(t, iMgr.AddToLists([]*IPSetMetadata{listMetadata}, []*IPSetMetadata{setMetadata}))
+
+	require.True(t, iMgr.HasMember(setMetadata.GetPrefixName(), testPodIP))
+	require.True(t, iMgr.HasMember(listMetadata.GetPrefixName(), testPodIP))
+	require.False(t, iMgr.HasMember(setMetadata.GetPrefixName(), "10.0.0.100"))
+	require.False(t, iMgr.HasMember(listMetadata.GetPrefixName(), "10.0.0.100"))
+	require.False(t, iMgr.HasMember("missing-set", testPodIP))
+}
+
 func TestRemoveFromListMissing(t *testing.T) {
 	iMgr := NewIPSetManager(applyOnNeedCfg, common.NewMockIOShim([]testutils.TestCmd{}))
 
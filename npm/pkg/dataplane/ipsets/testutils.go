@@ -28,6 +28,7 @@ var (
 	TestKVPodSet        = CreateTestSet("test-kvPod-set", KeyValueLabelOfPod)
 	TestNamedportSet    = CreateTestSet("test-namedport-set", NamedPorts)
 	TestCIDRSet         = CreateTestSet("test-cidr-set", CIDRBlocks)
+	TestFQDNSet         = CreateTestSet("test.example.com", FQDN)
 	TestKeyNSList       = CreateTestSet("test-keyNS-list", KeyLabelOfNamespace)
 	TestKVNSList        = CreateTestSet("test-kvNS-list", KeyValueLabelOfNamespace)
 	TestNestedLabelList = CreateTestSet("test-nestedlabel-list", NestedLabelOfPod)
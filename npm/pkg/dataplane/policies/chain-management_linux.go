@@ -37,7 +37,14 @@ var (
 		util.IptablesAzureEgressChain,
 		util.IptablesAzureAcceptChain,
 	}
-	// Should not be used directly. Initialized from iptablesAzureChains on first use of isAzureChain().
+	// Base chains which are only created when AdminNetworkPolicies are enabled.
+	iptablesAdminChains = []string{
+		util.IptablesAzureAdminIngressChain,
+		util.IptablesAzureAdminEgressChain,
+		util.IptablesAzureBaselineIngressChain,
+		util.IptablesAzureBaselineEgressChain,
+	}
+	// Should not be used directly. Initialized from iptablesAzureChains and iptablesAdminChains on first use of isAzureChain().
 	iptablesAzureChainsMap map[string]struct{}
 
 	jumpToAzureChainArgs = []string{
@@ -123,11 +130,24 @@ func isBaseChain(chain string) bool {
 		for _, chain := range iptablesAzureChains {
 			iptablesAzureChainsMap[chain] = struct{}{}
 		}
+		for _, chain := range iptablesAdminChains {
+			iptablesAzureChainsMap[chain] = struct{}{}
+		}
 	}
 	_, exist := iptablesAzureChainsMap[chain]
 	return exist
 }
 
+// baseChains returns the base chains to create at bootup
+func (pMgr *PolicyManager) baseChains() []string {
+	if !pMgr.EnableAdminNetworkPolicies {
+		return iptablesAzureChains
+	}
+	chains := make([]string, 0, len(iptablesAzureChains)+len(iptablesAdminChains))
+	chains = append(chains, iptablesAzureChains...)
+	return append(chains, iptablesAdminChains...)
+}
+
 /*
 	Called once at startup.
 	Like the rest of PolicyManager, minimizes the number of OS calls by consolidating all possible actions into one iptables-restore call.
@@ -148,6 +168,10 @@ func isBaseChain(chain string) bool {
 		- would use a grep pattern like so: <line num...AZURE-NPM>|<Chain AZURE-NPM>
 */
 func (pMgr *PolicyManager) bootup(_ []string) error {
+	if pMgr.UseNFTables {
+		return pMgr.bootupNFT()
+	}
+
 	klog.Infof("booting up iptables Azure chains")
 
 	// Stop reconciling so we don't centend for iptables, and so we don't update the staleChains at the same time as reconcile()
@@ -196,6 +220,11 @@ func (pMgr *PolicyManager) bootup(_ []string) error {
 // - creates the jump rule from FORWARD chain to AZURE-NPM chain (if it does not exist) and makes sure it's after the jumps to KUBE-FORWARD & KUBE-SERVICES chains (if they exist).
 // - cleans up stale policy chains. It can be forced to stop this process if reconcileManager.forceLock() is called.
 func (pMgr *PolicyManager) reconcile() {
+	if pMgr.UseNFTables {
+		// the base chain is placed by its priority, and policy chains are deleted along with their policy
+		return
+	}
+
 	if err := pMgr.positionAzureChainJumpRule(); err != nil {
 		msg := fmt.Sprintf("failed to reconcile jump rule to Azure-NPM due to %s", err.Error())
 		metrics.SendErrorLogAndMetric(util.IptmID, "error: %s", msg)
@@ -278,8 +307,9 @@ func (pMgr *PolicyManager) runIPTablesCommand(operationFlag string, args ...stri
 // Writes the restore file for bootup, and marks the following as stale: deprecated chains and old v2 policy chains.
 // This is a separate function to help with UTs.
 func (pMgr *PolicyManager) creatorForBootup(currentChains map[string]struct{}) *ioutil.FileCreator {
-	chainsToCreate := make([]string, 0, len(iptablesAzureChains))
-	for _, chain := range iptablesAzureChains {
+	baseChains := pMgr.baseChains()
+	chainsToCreate := make([]string, 0, len(baseChains))
+	for _, chain := range baseChains {
 		_, exists := currentChains[chain]
 		if !exists {
 			chainsToCreate = append(chainsToCreate, chain)
@@ -297,10 +327,25 @@ func (pMgr *PolicyManager) creatorForBootup(currentChains map[string]struct{}) *
 	}
 
 	// add AZURE-NPM-INGRESS chain rules
+	// AdminNetworkPolicies come before the jumps to NetworkPolicy chains, which are inserted after the first rule
+	if pMgr.EnableAdminNetworkPolicies {
+		creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureIngressChain, util.IptablesJumpFlag, util.IptablesAzureAdminIngressChain)
+	}
+	if pMgr.EnableDenyLogging {
+		ingressLogSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureIngressChain}
+		ingressLogSpecs = append(ingressLogSpecs, denyLogSpecs(util.IptablesAzureIngressDenyLogPrefix)...)
+		ingressLogSpecs = append(ingressLogSpecs, onMarkSpecs(util.IptablesAzureIngressDropMarkHex)...)
+		ingressLogSpecs = append(ingressLogSpecs, commentSpecs(fmt.Sprintf("LOG-ON-INGRESS-DROP-MARK-%s", util.IptablesAzureIngressDropMarkHex))...)
+		creator.AddLine("", nil, ingressLogSpecs...)
+	}
 	ingressDropSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureIngressChain, util.IptablesJumpFlag, util.IptablesDrop}
 	ingressDropSpecs = append(ingressDropSpecs, onMarkSpecs(util.IptablesAzureIngressDropMarkHex)...)
 	ingressDropSpecs = append(ingressDropSpecs, commentSpecs(fmt.Sprintf("DROP-ON-INGRESS-DROP-MARK-%s", util.IptablesAzureIngressDropMarkHex))...)
 	creator.AddLine("", nil, ingressDropSpecs...)
+	// BaselineAdminNetworkPolicies are only reached if no NetworkPolicy decided on the packet
+	if pMgr.EnableAdminNetworkPolicies {
+		creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureIngressChain, util.IptablesJumpFlag, util.IptablesAzureBaselineIngressChain)
+	}
 
 	// add AZURE-NPM-INGRESS-ALLOW-MARK chain
 	markIngressAllowSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureIngressAllowMarkChain}
@@ -310,10 +355,23 @@ func (pMgr *PolicyManager) creatorForBootup(currentChains map[string]struct{}) *
 	creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureIngressAllowMarkChain, util.IptablesJumpFlag, util.IptablesAzureEgressChain)
 
 	// add AZURE-NPM-EGRESS chain rules
+	if pMgr.EnableAdminNetworkPolicies {
+		creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureEgressChain, util.IptablesJumpFlag, util.IptablesAzureAdminEgressChain)
+	}
+	if pMgr.EnableDenyLogging {
+		egressLogSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureEgressChain}
+		egressLogSpecs = append(egressLogSpecs, denyLogSpecs(util.IptablesAzureEgressDenyLogPrefix)...)
+		egressLogSpecs = append(egressLogSpecs, onMarkSpecs(util.IptablesAzureEgressDropMarkHex)...)
+		egressLogSpecs = append(egressLogSpecs, commentSpecs(fmt.Sprintf("LOG-ON-EGRESS-DROP-MARK-%s", util.IptablesAzureEgressDropMarkHex))...)
+		creator.AddLine("", nil, egressLogSpecs...)
+	}
 	egressDropSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureEgressChain, util.IptablesJumpFlag, util.IptablesDrop}
 	egressDropSpecs = append(egressDropSpecs, onMarkSpecs(util.IptablesAzureEgressDropMarkHex)...)
 	egressDropSpecs = append(egressDropSpecs, commentSpecs(fmt.Sprintf("DROP-ON-EGRESS-DROP-MARK-%s", util.IptablesAzureEgressDropMarkHex))...)
 	creator.AddLine("", nil, egressDropSpecs...)
+	if pMgr.EnableAdminNetworkPolicies {
+		creator.AddLine("", nil, util.IptablesAppendFlag, util.IptablesAzureEgressChain, util.IptablesJumpFlag, util.IptablesAzureBaselineEgressChain)
+	}
 
 	jumpOnIngressMatchSpecs := []string{util.IptablesAppendFlag, util.IptablesAzureEgressChain, util.IptablesJumpFlag, util.IptablesAzureAcceptChain}
 	jumpOnIngressMatchSpecs = append(jumpOnIngressMatchSpecs, onMarkSpecs(util.IptablesAzureIngressAllowMarkHex)...)
@@ -434,3 +492,15 @@ func onMarkSpecs(mark string) []string {
 		mark,
 	}
 }
+
+// the NFLOG target passes the packet to the deny log listener and continues to the next rule
+func denyLogSpecs(prefix string) []string {
+	return []string{
+		util.IptablesJumpFlag,
+		util.IptablesNFLOG,
+		util.IptablesNFLOGGroupFlag,
+		fmt.Sprint(util.IptablesAzureDenyLogGroup),
+		util.IptablesNFLOGPrefixFlag,
+		prefix,
+	}
+}
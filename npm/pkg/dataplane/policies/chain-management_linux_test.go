@@ -330,6 +330,80 @@ func TestCreatorForBootup(t *testing.T) {
 	}
 }
 
+func TestCreatorForBootupWithDenyLogging(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	cfg := &PolicyManagerCfg{
+		PolicyMode:           IPSetPolicyMode,
+		PlaceAzureChainFirst: util.PlaceAzureChainFirst,
+		EnableDenyLogging:    true,
+	}
+	pMgr := NewPolicyManager(ioshim, cfg)
+	creator := pMgr.creatorForBootup(stringsToMap(nil))
+	actualLines := strings.Split(creator.ToString(), "\n")
+	// same expected lines as "no NPM prior" in TestCreatorForBootup, except for the NFLOG rules before the DROP rules
+	expectedLines := []string{
+		"*filter",
+		":AZURE-NPM - -",
+		":AZURE-NPM-INGRESS - -",
+		":AZURE-NPM-INGRESS-ALLOW-MARK - -",
+		":AZURE-NPM-EGRESS - -",
+		":AZURE-NPM-ACCEPT - -",
+		"-A AZURE-NPM-INGRESS -j NFLOG --nflog-group 1010 --nflog-prefix AZURE-NPM-INGRESS-DROP -m mark --mark 0x400/0x400 -m comment --comment LOG-ON-INGRESS-DROP-MARK-0x400/0x400",
+		"-A AZURE-NPM-INGRESS -j DROP -m mark --mark 0x400/0x400 -m comment --comment DROP-ON-INGRESS-DROP-MARK-0x400/0x400",
+		"-A AZURE-NPM-INGRESS-ALLOW-MARK -j MARK --set-mark 0x200/0x200 -m comment --comment SET-INGRESS-ALLOW-MARK-0x200/0x200",
+		"-A AZURE-NPM-INGRESS-ALLOW-MARK -j AZURE-NPM-EGRESS",
+		"-A AZURE-NPM-EGRESS -j NFLOG --nflog-group 1010 --nflog-prefix AZURE-NPM-EGRESS-DROP -m mark --mark 0x800/0x800 -m comment --comment LOG-ON-EGRESS-DROP-MARK-0x800/0x800",
+		"-A AZURE-NPM-EGRESS -j DROP -m mark --mark 0x800/0x800 -m comment --comment DROP-ON-EGRESS-DROP-MARK-0x800/0x800",
+		"-A AZURE-NPM-EGRESS -j AZURE-NPM-ACCEPT -m mark --mark 0x200/0x200 -m comment --comment ACCEPT-ON-INGRESS-ALLOW-MARK-0x200/0x200",
+		"-A AZURE-NPM-ACCEPT -j ACCEPT",
+		"COMMIT",
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+}
+
+func TestCreatorForBootupWithAdminNetworkPolicies(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	cfg := &PolicyManagerCfg{
+		PolicyMode:                 IPSetPolicyMode,
+		PlaceAzureChainFirst:       util.PlaceAzureChainFirst,
+		EnableAdminNetworkPolicies: true,
+	}
+	pMgr := NewPolicyManager(ioshim, cfg)
+	creator := pMgr.creatorForBootup(stringsToMap([]string{"AZURE-NPM-ADMIN-INGRESS", "AZURE-NPM-BASELINE-EGRESS"}))
+	actualLines := strings.Split(creator.ToString(), "\n")
+	// same expected lines as "no NPM prior" in TestCreatorForBootup, except for the admin chains and the jumps to them
+	expectedLines := []string{
+		"*filter",
+		":AZURE-NPM - -",
+		":AZURE-NPM-INGRESS - -",
+		":AZURE-NPM-INGRESS-ALLOW-MARK - -",
+		":AZURE-NPM-EGRESS - -",
+		":AZURE-NPM-ACCEPT - -",
+		":AZURE-NPM-ADMIN-EGRESS - -",
+		":AZURE-NPM-BASELINE-INGRESS - -",
+		"-F AZURE-NPM-ADMIN-INGRESS",
+		"-F AZURE-NPM-BASELINE-EGRESS",
+		"-A AZURE-NPM-INGRESS -j AZURE-NPM-ADMIN-INGRESS",
+		"-A AZURE-NPM-INGRESS -j DROP -m mark --mark 0x400/0x400 -m comment --comment DROP-ON-INGRESS-DROP-MARK-0x400/0x400",
+		"-A AZURE-NPM-INGRESS -j AZURE-NPM-BASELINE-INGRESS",
+		"-A AZURE-NPM-INGRESS-ALLOW-MARK -j MARK --set-mark 0x200/0x200 -m comment --comment SET-INGRESS-ALLOW-MARK-0x200/0x200",
+		"-A AZURE-NPM-INGRESS-ALLOW-MARK -j AZURE-NPM-EGRESS",
+		"-A AZURE-NPM-EGRESS -j AZURE-NPM-ADMIN-EGRESS",
+		"-A AZURE-NPM-EGRESS -j DROP -m mark --mark 0x800/0x800 -m comment --comment DROP-ON-EGRESS-DROP-MARK-0x800/0x800",
+		"-A AZURE-NPM-EGRESS -j AZURE-NPM-BASELINE-EGRESS",
+		"-A AZURE-NPM-EGRESS -j AZURE-NPM-ACCEPT -m mark --mark 0x200/0x200 -m comment --comment ACCEPT-ON-INGRESS-ALLOW-MARK-0x200/0x200",
+		"-A AZURE-NPM-ACCEPT -j ACCEPT",
+		"COMMIT",
+		"",
+	}
+	dptestutils.AssertEqualLines(t, sortFlushes(expectedLines), sortFlushes(actualLines))
+	// the admin chains are base chains, so they aren't cleaned up
+	require.Empty(t, pMgr.staleChains.chainsToCleanup)
+}
+
 func sortFlushes(lines []string) []string {
 	result := make([]string, len(lines))
 	copy(result, lines)
@@ -28,6 +28,10 @@ type NPMNetworkPolicy struct {
 	// podIP is key and endpoint ID as value
 	// Will be populated by dataplane and policy manager
 	PodEndpoints map[string]string
+	// Tier is the kind of policy, which decides when the policy is evaluated relative to other policies
+	Tier PolicyTier
+	// Priority orders AdminNetworkPolicies, where a lower value is evaluated first
+	Priority int32
 }
 
 func NewNPMNetworkPolicy(netPolName, netPolNamespace string) *NPMNetworkPolicy {
@@ -38,6 +42,30 @@ func NewNPMNetworkPolicy(netPolName, netPolNamespace string) *NPMNetworkPolicy {
 	}
 }
 
+// IsAdminTier returns true for AdminNetworkPolicies and BaselineAdminNetworkPolicies
+func (netPol *NPMNetworkPolicy) IsAdminTier() bool {
+	return netPol.Tier == AdminTier || netPol.Tier == BaselineAdminTier
+}
+
+// HasFQDNSets returns true if the policy references FQDN IPSets, i.e. it was translated from an FQDNNetworkPolicy
+func (netPol *NPMNetworkPolicy) HasFQDNSets() bool {
+	for _, set := range netPol.RuleIPSets {
+		if set.Metadata.Type == ipsets.FQDN {
+			return true
+		}
+	}
+	return false
+}
+
+func (netPol *NPMNetworkPolicy) hasACLsIn(direction Direction) bool {
+	for _, aclPolicy := range netPol.ACLs {
+		if (direction == Ingress && aclPolicy.hasIngress()) || (direction == Egress && aclPolicy.hasEgress()) {
+			return true
+		}
+	}
+	return false
+}
+
 func (netPol *NPMNetworkPolicy) numACLRulesProducedInKernel() int {
 	numRules := 0
 	hasIngress := false
@@ -60,6 +88,21 @@ func (netPol *NPMNetworkPolicy) numACLRulesProducedInKernel() int {
 	if hasEgress {
 		numRules++
 	}
+
+	// AdminNetworkPolicy chains start with a rule returning passed traffic, and each pass ACL also returns after setting the mark
+	if netPol.Tier == AdminTier {
+		if hasIngress {
+			numRules++
+		}
+		if hasEgress {
+			numRules++
+		}
+		for _, aclPolicy := range netPol.ACLs {
+			if aclPolicy.Target == Passed {
+				numRules++
+			}
+		}
+	}
 	return numRules
 }
 
@@ -76,12 +119,12 @@ func (netPol *NPMNetworkPolicy) PrettyString() string {
 
 	podSelectorIPSetString := translatedIPSetsToString(netPol.PodSelectorIPSets)
 	podSelectorListString := infoArrayToString(netPol.PodSelectorList)
-	format := `Name:%s  Namespace:%s
+	format := `Name:%s  Namespace:%s  Tier:%s  Priority:%d
 PodSelectorIPSets: %s
 PodSelectorList: %s
 ACLs:
 %s`
-	return fmt.Sprintf(format, netPol.Name, netPol.NameSpace, podSelectorIPSetString, podSelectorListString, aclArrayString)
+	return fmt.Sprintf(format, netPol.Name, netPol.NameSpace, netPol.Tier, netPol.Priority, podSelectorIPSetString, podSelectorListString, aclArrayString)
 }
 
 // ACLPolicy equivalent to a single iptable rule in linux
@@ -139,10 +182,16 @@ func NormalizePolicy(networkPolicy *NPMNetworkPolicy) {
 
 // TODO do verification in controller?
 func ValidatePolicy(networkPolicy *NPMNetworkPolicy) error {
+	if !networkPolicy.hasKnownTier() {
+		return npmerrors.SimpleError(fmt.Sprintf("policy %s has unknown tier [%s]", networkPolicy.PolicyKey, networkPolicy.Tier))
+	}
 	for _, aclPolicy := range networkPolicy.ACLs {
 		if !aclPolicy.hasKnownTarget() {
 			return npmerrors.SimpleError(fmt.Sprintf("ACL policy %s has unknown target [%s]", aclPolicy.PolicyID, aclPolicy.Target))
 		}
+		if aclPolicy.Target == Passed && networkPolicy.Tier != AdminTier {
+			return npmerrors.SimpleError(fmt.Sprintf("ACL policy %s has target [%s], which is only allowed for AdminNetworkPolicies", aclPolicy.PolicyID, aclPolicy.Target))
+		}
 		if !aclPolicy.hasKnownDirection() {
 			return npmerrors.SimpleError(fmt.Sprintf("ACL policy %s has unknown direction [%s]", aclPolicy.PolicyID, aclPolicy.Direction))
 		}
@@ -228,7 +277,13 @@ func (aclPolicy *ACLPolicy) hasKnownProtocol() bool {
 }
 
 func (aclPolicy *ACLPolicy) hasKnownTarget() bool {
-	return aclPolicy.Target == Allowed || aclPolicy.Target == Dropped
+	return aclPolicy.Target == Allowed || aclPolicy.Target == Dropped || aclPolicy.Target == Passed
+}
+
+func (netPol *NPMNetworkPolicy) hasKnownTier() bool {
+	return netPol.Tier == NetworkPolicyTier ||
+		netPol.Tier == AdminTier ||
+		netPol.Tier == BaselineAdminTier
 }
 
 func (aclPolicy *ACLPolicy) satisifiesPortAndProtocolConstraints() bool {
@@ -334,6 +389,21 @@ const (
 	Allowed Verdict = "ALLOW"
 	// Dropped is denying a flow
 	Dropped Verdict = "DROP"
+	// Passed skips the rest of the AdminNetworkPolicies so that the flow is decided by NetworkPolicies
+	Passed Verdict = "PASS"
+)
+
+// PolicyTier is the kind of policy. Tiers are evaluated in this order:
+// AdminNetworkPolicies, NetworkPolicies, then BaselineAdminNetworkPolicies.
+type PolicyTier string
+
+const (
+	// NetworkPolicyTier is for networking.k8s.io/v1 NetworkPolicies
+	NetworkPolicyTier PolicyTier = ""
+	// AdminTier is for AdminNetworkPolicies, which are evaluated before NetworkPolicies in order of priority
+	AdminTier PolicyTier = "Admin"
+	// BaselineAdminTier is for BaselineAdminNetworkPolicies, which are only evaluated for Pods not selected by a NetworkPolicy
+	BaselineAdminTier PolicyTier = "BaselineAdmin"
 )
 
 // Protocol can be TCP, UDP, SCTP, or unspecified since they are currently supported in networkpolicy.
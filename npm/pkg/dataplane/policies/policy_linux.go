@@ -44,6 +44,27 @@ func (networkPolicy *NPMNetworkPolicy) chainName(prefix string) string {
 	return joinWithDash(prefix, policyHash)
 }
 
+// returns the chains with the jumps to the ingress and egress chains of the policy, which depend on the policy's tier
+func (networkPolicy *NPMNetworkPolicy) jumpChainNames() (ingressChain, egressChain string) {
+	switch networkPolicy.Tier {
+	case AdminTier:
+		return util.IptablesAzureAdminIngressChain, util.IptablesAzureAdminEgressChain
+	case BaselineAdminTier:
+		return util.IptablesAzureBaselineIngressChain, util.IptablesAzureBaselineEgressChain
+	default:
+		return util.IptablesAzureIngressChain, util.IptablesAzureEgressChain
+	}
+}
+
+// adminPolicyBefore returns true if the AdminNetworkPolicy a is evaluated before b.
+// Policies with the same priority are ordered by key so that the order is deterministic.
+func adminPolicyBefore(a, b *NPMNetworkPolicy) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	return a.PolicyKey < b.PolicyKey
+}
+
 func (networkPolicy *NPMNetworkPolicy) commentForJumpToIngress() string {
 	return networkPolicy.commentForJump(forIngress)
 }
@@ -67,6 +88,10 @@ func (networkPolicy *NPMNetworkPolicy) commentForJump(direction UniqueDirection)
 	if len(networkPolicy.PodSelectorList) > 0 {
 		podSelectorComment = commentForInfos(networkPolicy.PodSelectorList)
 	}
+	if networkPolicy.IsAdminTier() {
+		// admin policies are cluster-scoped
+		return fmt.Sprintf("%s-POLICY-%s-%s-%s", prefix, networkPolicy.PolicyKey, toFrom, podSelectorComment)
+	}
 	return fmt.Sprintf("%s-POLICY-%s-%s-%s-IN-ns-%s", prefix, networkPolicy.PolicyKey, toFrom, podSelectorComment, networkPolicy.NameSpace)
 }
 
@@ -123,9 +148,12 @@ func (aclPolicy *ACLPolicy) comment() string {
 	}
 
 	builder := strings.Builder{}
-	if aclPolicy.Target == Allowed {
+	switch aclPolicy.Target {
+	case Allowed:
 		builder.WriteString("ALLOW")
-	} else {
+	case Passed:
+		builder.WriteString("PASS")
+	default:
 		builder.WriteString("DROP")
 	}
 
@@ -209,6 +237,7 @@ func (portRange *Ports) toIPTablesString() string {
 					- ingress: "ALLOW-FROM"
 					- egress: "ALLOW-TO"
 			- denied: replace "ALLOW" with "DROP"
+			- passed (only in AdminNetworkPolicies): replace "ALLOW" with "PASS"
 		- similar idea (think there are at most two non-namedPort ipsets e.g. ns selector and pod selector):
 			prefix
 			[-ipset1Name]
@@ -229,7 +258,7 @@ func (portRange *Ports) toIPTablesString() string {
 			-policyKey
 			-TO         (or "-FROM" if egress)
 			[-podSelectorComment]   (or "all" if there are no pod selectors)
-			-IN-ns
+			-IN-ns      (omitted for AdminNetworkPolicies and BaselineAdminNetworkPolicies)
 			-namespaceName
 
 	strings for protocol, ports, selectors:
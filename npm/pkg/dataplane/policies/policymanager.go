@@ -25,6 +25,10 @@ const (
 	// it represents the number of rules unrelated to policies
 	// it's technically 3 off when there are no policies since we flush the AZURE-NPM chain then
 	numLinuxBaseACLRules = 11
+	// the NFLOG rules before the drops in AZURE-NPM-INGRESS and AZURE-NPM-EGRESS
+	numLinuxDenyLogACLRules = 2
+	// the jumps to the admin and baseline admin chains from AZURE-NPM-INGRESS and AZURE-NPM-EGRESS
+	numLinuxAdminACLRules = 4
 )
 
 type PolicyManagerCfg struct {
@@ -32,9 +36,17 @@ type PolicyManagerCfg struct {
 	PolicyMode PolicyManagerMode
 	// PlaceAzureChainFirst only affects Linux
 	PlaceAzureChainFirst bool
+	// UseNFTables renders policies into nftables instead of iptables. Only affects Linux
+	UseNFTables bool
+	// EnableDenyLogging logs packets before they are dropped by NPM, in the NFLOG group util.IptablesAzureDenyLogGroup. Only affects Linux
+	EnableDenyLogging bool
+	// EnableAdminNetworkPolicies adds the chains for AdminNetworkPolicies and BaselineAdminNetworkPolicies. Only affects Linux
+	EnableAdminNetworkPolicies bool
 }
 
 type PolicyMap struct {
+	// only writes to the cache and reads outside of the goroutine adding and removing policies need the lock
+	sync.RWMutex
 	cache map[string]*NPMNetworkPolicy
 }
 
@@ -75,7 +87,14 @@ func (pMgr *PolicyManager) Bootup(epIDs []string) error {
 
 	if !util.IsWindowsDP() {
 		// update Prometheus metrics on success
-		metrics.IncNumACLRulesBy(numLinuxBaseACLRules)
+		numBaseACLRules := numLinuxBaseACLRules
+		if pMgr.EnableDenyLogging {
+			numBaseACLRules += numLinuxDenyLogACLRules
+		}
+		if pMgr.EnableAdminNetworkPolicies {
+			numBaseACLRules += numLinuxAdminACLRules
+		}
+		metrics.IncNumACLRulesBy(numBaseACLRules)
 	}
 	return nil
 }
@@ -104,6 +123,32 @@ func (pMgr *PolicyManager) GetPolicy(policyKey string) (*NPMNetworkPolicy, bool)
 	return policy, ok
 }
 
+// GetPoliciesSelecting returns the policies with rules in the direction which select a Pod with the given membership.
+// isMember reports whether the Pod is in the IPSet with the given prefixed name.
+// It is safe to call while policies are being added or removed.
+func (pMgr *PolicyManager) GetPoliciesSelecting(direction Direction, isMember func(setName string) bool) []*NPMNetworkPolicy {
+	pMgr.policyMap.RLock()
+	defer pMgr.policyMap.RUnlock()
+
+	result := make([]*NPMNetworkPolicy, 0)
+	for _, policy := range pMgr.policyMap.cache {
+		if !policy.hasACLsIn(direction) {
+			continue
+		}
+		selected := true
+		for _, setInfo := range policy.PodSelectorList {
+			if isMember(setInfo.IPSet.GetPrefixName()) != setInfo.Included {
+				selected = false
+				break
+			}
+		}
+		if selected {
+			result = append(result, policy)
+		}
+	}
+	return result
+}
+
 func (pMgr *PolicyManager) AddPolicy(policy *NPMNetworkPolicy, endpointList map[string]string) error {
 	if len(policy.ACLs) == 0 {
 		klog.Infof("[DataPlane] No ACLs in policy %s to apply", policy.PolicyKey)
@@ -132,7 +177,9 @@ func (pMgr *PolicyManager) AddPolicy(policy *NPMNetworkPolicy, endpointList map[
 	// update Prometheus metrics on success
 	metrics.IncNumACLRulesBy(policy.numACLRulesProducedInKernel())
 
+	pMgr.policyMap.Lock()
 	pMgr.policyMap.cache[policy.PolicyKey] = policy
+	pMgr.policyMap.Unlock()
 	return nil
 }
 
@@ -164,7 +211,9 @@ func (pMgr *PolicyManager) RemovePolicy(policyKey string, endpointList map[strin
 	// update Prometheus metrics on success
 	metrics.DecNumACLRulesBy(policy.numACLRulesProducedInKernel())
 
+	pMgr.policyMap.Lock()
 	delete(pMgr.policyMap.cache, policyKey)
+	pMgr.policyMap.Unlock()
 	return nil
 }
 
@@ -42,6 +42,13 @@ Known errors that we should retry on:
 */
 
 func (pMgr *PolicyManager) addPolicy(networkPolicy *NPMNetworkPolicy, _ map[string]string) error {
+	if networkPolicy.IsAdminTier() && !pMgr.EnableAdminNetworkPolicies {
+		return npmerrors.SimpleError(fmt.Sprintf("cannot add policy %s since AdminNetworkPolicies are not enabled", networkPolicy.PolicyKey))
+	}
+	if pMgr.UseNFTables {
+		return pMgr.addPolicyNFT(networkPolicy)
+	}
+
 	// 1. Add rules for the network policies and activate NPM (if necessary).
 	chainsToCreate := chainNames([]*NPMNetworkPolicy{networkPolicy})
 	creator := pMgr.creatorForNewNetworkPolicies(chainsToCreate, []*NPMNetworkPolicy{networkPolicy})
@@ -63,6 +70,10 @@ func (pMgr *PolicyManager) addPolicy(networkPolicy *NPMNetworkPolicy, _ map[stri
 }
 
 func (pMgr *PolicyManager) removePolicy(networkPolicy *NPMNetworkPolicy, _ map[string]string) error {
+	if pMgr.UseNFTables {
+		return pMgr.removePolicyNFT(networkPolicy)
+	}
+
 	chainsToDelete := chainNames([]*NPMNetworkPolicy{networkPolicy})
 	creator := pMgr.creatorForRemovingPolicies(chainsToDelete)
 
@@ -163,13 +174,14 @@ func (pMgr *PolicyManager) deleteJumpRule(policy *NPMNetworkPolicy, direction Un
 	var specs []string
 	var baseChainName string
 	var chainName string
+	ingressJumpChain, egressJumpChain := policy.jumpChainNames()
 	if direction == forIngress {
 		specs = ingressJumpSpecs(policy)
-		baseChainName = util.IptablesAzureIngressChain
+		baseChainName = ingressJumpChain
 		chainName = policy.ingressChainName()
 	} else {
 		specs = egressJumpSpecs(policy)
-		baseChainName = util.IptablesAzureEgressChain
+		baseChainName = egressJumpChain
 		chainName = policy.egressChainName()
 	}
 
@@ -213,44 +225,115 @@ func (pMgr *PolicyManager) creatorForNewNetworkPolicies(policyChains []string, n
 	// 2. Add all rules for the network policies
 	ingressJumpLineNumber := 1
 	egressJumpLineNumber := 1
-	for _, networkPolicy := range networkPolicies {
+	if pMgr.EnableAdminNetworkPolicies {
+		// the first rules jump to the AdminNetworkPolicy chains
+		ingressJumpLineNumber = 2
+		egressJumpLineNumber = 2
+	}
+	for k, networkPolicy := range networkPolicies {
 		// 2.1 add all rules for the policy chain(s)
 		writeNetworkPolicyRules(creator, networkPolicy)
 
 		// 2.2 add jump rule(s) to the policy chain(s)
 		hasIngress, hasEgress := networkPolicy.hasIngressAndEgress()
+		ingressJumpChain, egressJumpChain := networkPolicy.jumpChainNames()
 		if hasIngress {
-			ingressJumpSpecs := insertSpecs(util.IptablesAzureIngressChain, ingressJumpLineNumber, ingressJumpSpecs(networkPolicy))
+			lineNumber := ingressJumpLineNumber
+			switch networkPolicy.Tier {
+			case AdminTier:
+				lineNumber = pMgr.adminJumpLineNumber(networkPolicy, Ingress, networkPolicies[:k])
+			case BaselineAdminTier:
+				lineNumber = 1
+			default:
+				ingressJumpLineNumber++
+			}
+			ingressJumpSpecs := insertSpecs(ingressJumpChain, lineNumber, ingressJumpSpecs(networkPolicy))
 			creator.AddLine("", nil, ingressJumpSpecs...) // TODO error handler
-			ingressJumpLineNumber++
 		}
 		if hasEgress {
-			egressJumpSpecs := insertSpecs(util.IptablesAzureEgressChain, egressJumpLineNumber, egressJumpSpecs(networkPolicy))
+			lineNumber := egressJumpLineNumber
+			switch networkPolicy.Tier {
+			case AdminTier:
+				lineNumber = pMgr.adminJumpLineNumber(networkPolicy, Egress, networkPolicies[:k])
+			case BaselineAdminTier:
+				lineNumber = 1
+			default:
+				egressJumpLineNumber++
+			}
+			egressJumpSpecs := insertSpecs(egressJumpChain, lineNumber, egressJumpSpecs(networkPolicy))
 			creator.AddLine("", nil, egressJumpSpecs...) // TODO error handler
-			egressJumpLineNumber++
 		}
 	}
 	creator.AddLine("", nil, util.IptablesRestoreCommit)
 	return creator
 }
 
+// adminJumpLineNumber returns the line in the AdminNetworkPolicy chain for the jump to the policy's chain in the direction,
+// so that the jumps are ordered by priority.
+// The jumps of placedPolicies are inserted in the same iptables-restore file before this policy's jump.
+func (pMgr *PolicyManager) adminJumpLineNumber(networkPolicy *NPMNetworkPolicy, direction Direction, placedPolicies []*NPMNetworkPolicy) int {
+	lineNumber := 1
+	isBefore := func(otherPolicy *NPMNetworkPolicy) bool {
+		return otherPolicy.Tier == AdminTier &&
+			otherPolicy.PolicyKey != networkPolicy.PolicyKey &&
+			otherPolicy.hasACLsIn(direction) &&
+			adminPolicyBefore(otherPolicy, networkPolicy)
+	}
+	for _, otherPolicy := range pMgr.policyMap.cache {
+		if isBefore(otherPolicy) {
+			lineNumber++
+		}
+	}
+	for _, otherPolicy := range placedPolicies {
+		if isBefore(otherPolicy) {
+			lineNumber++
+		}
+	}
+	return lineNumber
+}
+
 // write rules for the policy chain(s)
 func writeNetworkPolicyRules(creator *ioutil.FileCreator, networkPolicy *NPMNetworkPolicy) {
+	if networkPolicy.Tier == AdminTier {
+		// skip the rest of the AdminNetworkPolicies after an earlier one passed the packet
+		hasIngress, hasEgress := networkPolicy.hasIngressAndEgress()
+		if hasIngress {
+			creator.AddLine("", nil, returnOnPassMarkSpecs(networkPolicy.ingressChainName(), util.IptablesAzureIngressPassMarkHex)...)
+		}
+		if hasEgress {
+			creator.AddLine("", nil, returnOnPassMarkSpecs(networkPolicy.egressChainName(), util.IptablesAzureEgressPassMarkHex)...)
+		}
+	}
+
 	for _, aclPolicy := range networkPolicy.ACLs {
 		var chainName string
 		var actionSpecs []string
+		var passMark string
 		if aclPolicy.hasIngress() {
 			chainName = networkPolicy.ingressChainName()
-			if aclPolicy.Target == Allowed {
+			switch {
+			case aclPolicy.Target == Allowed:
 				actionSpecs = []string{util.IptablesJumpFlag, util.IptablesAzureIngressAllowMarkChain}
-			} else {
+			case aclPolicy.Target == Passed:
+				passMark = util.IptablesAzureIngressPassMarkHex
+				actionSpecs = setMarkSpecs(passMark)
+			case networkPolicy.IsAdminTier():
+				// NetworkPolicies can't allow what an admin denies, so drop right away
+				actionSpecs = []string{util.IptablesJumpFlag, util.IptablesDrop}
+			default:
 				actionSpecs = setMarkSpecs(util.IptablesAzureIngressDropMarkHex)
 			}
 		} else {
 			chainName = networkPolicy.egressChainName()
-			if aclPolicy.Target == Allowed {
+			switch {
+			case aclPolicy.Target == Allowed:
 				actionSpecs = []string{util.IptablesJumpFlag, util.IptablesAzureAcceptChain}
-			} else {
+			case aclPolicy.Target == Passed:
+				passMark = util.IptablesAzureEgressPassMarkHex
+				actionSpecs = setMarkSpecs(passMark)
+			case networkPolicy.IsAdminTier():
+				actionSpecs = []string{util.IptablesJumpFlag, util.IptablesDrop}
+			default:
 				actionSpecs = setMarkSpecs(util.IptablesAzureEgressDropMarkHex)
 			}
 		}
@@ -258,9 +341,19 @@ func writeNetworkPolicyRules(creator *ioutil.FileCreator, networkPolicy *NPMNetw
 		line = append(line, actionSpecs...)
 		line = append(line, iptablesRuleSpecs(aclPolicy)...)
 		creator.AddLine("", nil, line...) // TODO add error handler
+		if passMark != "" {
+			// setting the mark doesn't end the chain, so return right after
+			creator.AddLine("", nil, returnOnPassMarkSpecs(chainName, passMark)...)
+		}
 	}
 }
 
+func returnOnPassMarkSpecs(chainName, passMark string) []string {
+	specs := []string{util.IptablesAppendFlag, chainName, util.IptablesJumpFlag, util.IptablesReturn}
+	specs = append(specs, onMarkSpecs(passMark)...)
+	return append(specs, commentSpecs(fmt.Sprintf("RETURN-ON-PASS-MARK-%s", passMark))...)
+}
+
 func iptablesRuleSpecs(aclPolicy *ACLPolicy) []string {
 	specs := make([]string, 0)
 	if aclPolicy.Protocol != UnspecifiedProtocol {
@@ -284,6 +284,108 @@ func TestCreatorForAddPolicies(t *testing.T) {
 	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
 }
 
+func TestCreatorForAddAdminPolicies(t *testing.T) {
+	calls := []testutils.TestCmd{}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	cfg := &PolicyManagerCfg{
+		PolicyMode:                 IPSetPolicyMode,
+		PlaceAzureChainFirst:       util.PlaceAzureChainFirst,
+		EnableAdminNetworkPolicies: true,
+	}
+	pMgr := NewPolicyManager(ioshim, cfg)
+
+	ingressPassedACL := &ACLPolicy{
+		SrcList:   []SetInfo{{ipsets.TestCIDRSet.Metadata, true, SrcMatch}},
+		Target:    Passed,
+		Direction: Ingress,
+		Protocol:  UnspecifiedProtocol,
+	}
+	newAdminPolicy := func(name string, priority int32, acls ...*ACLPolicy) *NPMNetworkPolicy {
+		return &NPMNetworkPolicy{
+			Name:            name,
+			PolicyKey:       "AdminNetworkPolicy/" + name,
+			PodSelectorList: []SetInfo{{ipsets.TestNSSet.Metadata, true, EitherMatch}},
+			ACLs:            acls,
+			Tier:            AdminTier,
+			Priority:        priority,
+		}
+	}
+	// the cached policy is evaluated between the two new ones
+	cachedPolicy := newAdminPolicy("cached", 20, ingressDeniedACL, egressAllowedACL)
+	pMgr.policyMap.cache[cachedPolicy.PolicyKey] = cachedPolicy
+	lastPolicy := newAdminPolicy("last", 30, ingressDeniedACL)
+	firstPolicy := newAdminPolicy("first", 10, ingressPassedACL, ingressDeniedACL, egressAllowedACL)
+	baselinePolicy := &NPMNetworkPolicy{
+		Name:      "default",
+		PolicyKey: "BaselineAdminNetworkPolicy/default",
+		ACLs:      []*ACLPolicy{egressDeniedACL},
+		Tier:      BaselineAdminTier,
+	}
+
+	policies := []*NPMNetworkPolicy{lastPolicy, firstPolicy, baselinePolicy, ingressNetPol}
+	creator := pMgr.creatorForNewNetworkPolicies(chainNames(policies), policies)
+	actualLines := strings.Split(creator.ToString(), "\n")
+
+	adminIngressDropRule := strings.Replace(ingressDropRule, fmt.Sprintf("-j MARK --set-mark %s", util.IptablesAzureIngressDropMarkHex), "-j DROP", 1)
+	baselineEgressDropRule := strings.Replace(egressDropRule, fmt.Sprintf("-j MARK --set-mark %s", util.IptablesAzureEgressDropMarkHex), "-j DROP", 1)
+	ingressReturnRule := "-j RETURN -m mark --mark 0x1000/0x1000 -m comment --comment RETURN-ON-PASS-MARK-0x1000/0x1000"
+	egressReturnRule := "-j RETURN -m mark --mark 0x2000/0x2000 -m comment --comment RETURN-ON-PASS-MARK-0x2000/0x2000"
+	adminJump := func(chain, matchType, comment string) string {
+		return fmt.Sprintf("-j %s -m set --match-set %s %s -m comment --comment %s", chain, ipsets.TestNSSet.HashedName, matchType, comment)
+	}
+	expectedLines := []string{
+		"*filter",
+		fmt.Sprintf(":%s - -", lastPolicy.ingressChainName()),
+		fmt.Sprintf(":%s - -", firstPolicy.ingressChainName()),
+		fmt.Sprintf(":%s - -", firstPolicy.egressChainName()),
+		fmt.Sprintf(":%s - -", baselinePolicy.egressChainName()),
+		fmt.Sprintf(":%s - -", ingressNetPolChain),
+		// priority 30 goes after the cached policy
+		fmt.Sprintf("-A %s %s", lastPolicy.ingressChainName(), ingressReturnRule),
+		fmt.Sprintf("-A %s %s", lastPolicy.ingressChainName(), adminIngressDropRule),
+		fmt.Sprintf("-I AZURE-NPM-ADMIN-INGRESS 2 %s",
+			adminJump(lastPolicy.ingressChainName(), "dst", "INGRESS-POLICY-AdminNetworkPolicy/last-TO-ns-test-ns-set")),
+		// priority 10 goes first
+		fmt.Sprintf("-A %s %s", firstPolicy.ingressChainName(), ingressReturnRule),
+		fmt.Sprintf("-A %s %s", firstPolicy.egressChainName(), egressReturnRule),
+		fmt.Sprintf("-A %s -j MARK --set-mark 0x1000/0x1000 -m set --match-set %s src -m comment --comment PASS-FROM-cidr-test-cidr-set",
+			firstPolicy.ingressChainName(), ipsets.TestCIDRSet.HashedName),
+		fmt.Sprintf("-A %s %s", firstPolicy.ingressChainName(), ingressReturnRule),
+		fmt.Sprintf("-A %s %s", firstPolicy.ingressChainName(), adminIngressDropRule),
+		fmt.Sprintf("-A %s %s", firstPolicy.egressChainName(), egressAllowRule),
+		fmt.Sprintf("-I AZURE-NPM-ADMIN-INGRESS 1 %s",
+			adminJump(firstPolicy.ingressChainName(), "dst", "INGRESS-POLICY-AdminNetworkPolicy/first-TO-ns-test-ns-set")),
+		fmt.Sprintf("-I AZURE-NPM-ADMIN-EGRESS 1 %s",
+			adminJump(firstPolicy.egressChainName(), "src", "EGRESS-POLICY-AdminNetworkPolicy/first-FROM-ns-test-ns-set")),
+		// baseline policy
+		fmt.Sprintf("-A %s %s", baselinePolicy.egressChainName(), baselineEgressDropRule),
+		fmt.Sprintf("-I AZURE-NPM-BASELINE-EGRESS 1 -j %s -m comment --comment EGRESS-POLICY-BaselineAdminNetworkPolicy/default-FROM-all", baselinePolicy.egressChainName()),
+		// NetworkPolicy jumps go after the jump to AZURE-NPM-ADMIN-INGRESS
+		fmt.Sprintf("-A %s %s", ingressNetPolChain, ingressDropRule),
+		fmt.Sprintf("-I AZURE-NPM-INGRESS 2 %s", ingressNetPolJump),
+		"COMMIT",
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+}
+
+func TestAddAdminPolicyWhenDisabled(t *testing.T) {
+	calls := []testutils.TestCmd{}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+
+	policy := &NPMNetworkPolicy{
+		Name:      "default",
+		PolicyKey: "BaselineAdminNetworkPolicy/default",
+		ACLs:      []*ACLPolicy{egressDeniedACL},
+		Tier:      BaselineAdminTier,
+	}
+	require.Error(t, pMgr.AddPolicy(policy, nil))
+	require.False(t, pMgr.PolicyExists(policy.PolicyKey))
+}
+
 func TestCreatorForRemovePolicies(t *testing.T) {
 	calls := []testutils.TestCmd{fakeIPTablesRestoreCommand}
 	ioshim := common.NewMockIOShim(calls)
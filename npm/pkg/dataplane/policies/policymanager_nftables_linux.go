@@ -0,0 +1,426 @@
+package policies
+
+// This file contains code for the nftables implementation of booting up and adding/removing policies.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	"github.com/Azure/azure-container-networking/npm/util"
+	npmerrors "github.com/Azure/azure-container-networking/npm/util/errors"
+	"github.com/Azure/azure-container-networking/npm/util/ioutil"
+	"k8s.io/klog"
+)
+
+const (
+	nftMaxTryCount = 2
+	// nft rejects comments longer than this
+	nftMaxCommentLength = 128
+
+	// the base chain runs before the FORWARD chain of iptables (priority 0) if the Azure chain should be first, and after it otherwise
+	nftAzureChainFirstPriority     = "-1"
+	nftAzureChainAfterKubePriority = "1"
+)
+
+var (
+	nftIngressAllowMark = markValue(util.IptablesAzureIngressAllowMarkHex)
+	nftIngressDropMark  = markValue(util.IptablesAzureIngressDropMarkHex)
+	nftEgressDropMark   = markValue(util.IptablesAzureEgressDropMarkHex)
+	nftIngressPassMark  = markValue(util.IptablesAzureIngressPassMarkHex)
+	nftEgressPassMark   = markValue(util.IptablesAzureEgressPassMarkHex)
+)
+
+/*
+Error handling for nft:
+nft applies all lines of a file in one transaction, so nothing is applied if any line fails.
+Like for iptables-restore, we retry on any error and will make two tries max.
+
+Layout of the azure-npm table (the names of the chains are the same as in iptables):
+	AZURE-NPM-FORWARD: base chain hooked into forward, jumps to AZURE-NPM for new connections
+	AZURE-NPM: empty when NPM is deactivated, otherwise jumps to AZURE-NPM-INGRESS, AZURE-NPM-EGRESS, and AZURE-NPM-ACCEPT
+	AZURE-NPM-INGRESS and AZURE-NPM-EGRESS: jumps to the policy chains, followed by the rules acting on marks
+	AZURE-NPM-ADMIN-* and AZURE-NPM-BASELINE-*: jumps to the chains of admin policies, jumped to from AZURE-NPM-INGRESS and AZURE-NPM-EGRESS
+		before and after the NetworkPolicy jumps (only when AdminNetworkPolicies are enabled)
+	AZURE-NPM-INGRESS-ALLOW-MARK and AZURE-NPM-ACCEPT: same rules as in iptables
+	AZURE-NPM-INGRESS-<hash> and AZURE-NPM-EGRESS-<hash>: rules of a policy
+
+Rules can only be deleted from a chain by their handle, so AZURE-NPM and the chains with jumps to policy chains are
+flushed and written again from the policy cache whenever a policy is added or removed.
+This lets us delete policy chains in the same transaction instead of in the background.
+*/
+
+// bootupNFT recreates the azure-npm table, which deletes all NPM sets and policies, and leaves NPM deactivated.
+func (pMgr *PolicyManager) bootupNFT() error {
+	klog.Infof("booting up nftables table %s", util.NftablesAzureTable)
+	creator := pMgr.creatorForNFTBootup()
+	if err := runNFT(creator); err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to run nft for bootup", err)
+	}
+	return nil
+}
+
+func (pMgr *PolicyManager) addPolicyNFT(networkPolicy *NPMNetworkPolicy) error {
+	policies := pMgr.cachedPoliciesExcept(networkPolicy.PolicyKey)
+	policies = append(policies, networkPolicy)
+	creator := pMgr.creatorForNFTPolicies([]*NPMNetworkPolicy{networkPolicy}, nil, policies)
+	if err := runNFT(creator); err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to run nft with updated policies", err)
+	}
+	return nil
+}
+
+func (pMgr *PolicyManager) removePolicyNFT(networkPolicy *NPMNetworkPolicy) error {
+	policies := pMgr.cachedPoliciesExcept(networkPolicy.PolicyKey)
+	creator := pMgr.creatorForNFTPolicies(nil, []*NPMNetworkPolicy{networkPolicy}, policies)
+	if err := runNFT(creator); err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to run nft to remove policies", err)
+	}
+	return nil
+}
+
+func runNFT(creator *ioutil.FileCreator) error {
+	err := creator.RunCommandWithFile(util.Nftables, util.NftablesFileFlag, util.NftablesStdinFile)
+	if err != nil {
+		return npmerrors.SimpleErrorWrapper("failed to run nft file", err)
+	}
+	return nil
+}
+
+// returns the cached policies sorted by key, except for the policy with the given key
+func (pMgr *PolicyManager) cachedPoliciesExcept(policyKey string) []*NPMNetworkPolicy {
+	policies := make([]*NPMNetworkPolicy, 0, len(pMgr.policyMap.cache))
+	for key, policy := range pMgr.policyMap.cache {
+		if key != policyKey {
+			policies = append(policies, policy)
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].PolicyKey < policies[j].PolicyKey
+	})
+	return policies
+}
+
+func newNFTCreator(ioShim *common.IOShim) *ioutil.FileCreator {
+	creator := ioutil.NewFileCreator(ioShim, nftMaxTryCount, util.NftablesLineErrorPattern)
+	creator.UseRunFileLineNumbers()
+	return creator
+}
+
+func (pMgr *PolicyManager) creatorForNFTBootup() *ioutil.FileCreator {
+	creator := newNFTCreator(pMgr.ioShim)
+
+	// 1. recreate the table (adding it first so that the delete doesn't fail if it doesn't exist)
+	creator.AddLine("", nil, nftTableSpecs("add")...)
+	creator.AddLine("", nil, nftTableSpecs("delete")...)
+	creator.AddLine("", nil, nftTableSpecs("add")...)
+
+	// 2. add the base chain and the chains in iptablesAzureChains (and iptablesAdminChains if enabled)
+	priority := nftAzureChainFirstPriority
+	if pMgr.PlaceAzureChainFirst == util.PlaceAzureChainAfterKubeServices {
+		priority = nftAzureChainAfterKubePriority
+	}
+	forwardChainSpecs := nftChainSpecs("add", util.NftablesAzureForwardChain)
+	forwardChainSpecs = append(forwardChainSpecs, "{", "type", "filter", "hook", "forward", "priority", priority, ";", "policy", "accept", ";", "}")
+	creator.AddLine("", nil, forwardChainSpecs...)
+	for _, chain := range pMgr.baseChains() {
+		creator.AddLine("", nil, nftChainSpecs("add", chain)...)
+	}
+
+	// 3. add the rules of the base chains, leaving NPM deactivated
+	creator.AddLine("", nil, nftRuleSpecs(util.NftablesAzureForwardChain, "ct", "state", "new", "jump", util.IptablesAzureChain)...)
+	pMgr.writeNFTPolicyJumps(creator, nil)
+
+	markIngressAllowSpecs := nftRuleSpecs(util.IptablesAzureIngressAllowMarkChain, nftSetMarkSpecs(nftIngressAllowMark)...)
+	markIngressAllowSpecs = append(markIngressAllowSpecs, nftCommentSpecs(fmt.Sprintf("SET-INGRESS-ALLOW-MARK-%s", util.IptablesAzureIngressAllowMarkHex))...)
+	creator.AddLine("", nil, markIngressAllowSpecs...)
+	creator.AddLine("", nil, nftRuleSpecs(util.IptablesAzureIngressAllowMarkChain, "jump", util.IptablesAzureEgressChain)...)
+
+	creator.AddLine("", nil, nftRuleSpecs(util.IptablesAzureAcceptChain, "accept")...)
+	return creator
+}
+
+// creatorForNFTPolicies adds the chains of the policies to add, deletes the chains of the policies to remove,
+// and writes the jumps to the chains of all policies, which activates or deactivates NPM if necessary.
+func (pMgr *PolicyManager) creatorForNFTPolicies(policiesToAdd, policiesToRemove, allPolicies []*NPMNetworkPolicy) *ioutil.FileCreator {
+	creator := newNFTCreator(pMgr.ioShim)
+
+	// 1. add the policy chains, flushing them in case they exist
+	for _, chain := range chainNames(policiesToAdd) {
+		creator.AddLine("", nil, nftChainSpecs("add", chain)...)
+		creator.AddLine("", nil, nftChainSpecs("flush", chain)...)
+	}
+	for _, networkPolicy := range policiesToAdd {
+		writeNFTNetworkPolicyRules(creator, networkPolicy)
+	}
+
+	// 2. write the jumps to the policy chains
+	pMgr.writeNFTPolicyJumps(creator, allPolicies)
+
+	// 3. delete the policy chains, which have no more jumps to them
+	for _, chain := range chainNames(policiesToRemove) {
+		creator.AddLine("", nil, nftChainSpecs("delete", chain)...)
+	}
+	return creator
+}
+
+// writeNFTPolicyJumps flushes and writes AZURE-NPM, AZURE-NPM-INGRESS, and AZURE-NPM-EGRESS,
+// as well as the admin and baseline admin chains if AdminNetworkPolicies are enabled.
+// AZURE-NPM is left empty if there are no policies.
+func (pMgr *PolicyManager) writeNFTPolicyJumps(creator *ioutil.FileCreator, policies []*NPMNetworkPolicy) {
+	// 1. activate or deactivate NPM
+	creator.AddLine("", nil, nftChainSpecs("flush", util.IptablesAzureChain)...)
+	if len(policies) > 0 {
+		creator.AddLine("", nil, nftRuleSpecs(util.IptablesAzureChain, "jump", util.IptablesAzureIngressChain)...)
+		creator.AddLine("", nil, nftRuleSpecs(util.IptablesAzureChain, "jump", util.IptablesAzureEgressChain)...)
+		creator.AddLine("", nil, nftRuleSpecs(util.IptablesAzureChain, "jump", util.IptablesAzureAcceptChain)...)
+	}
+
+	// 2. add the jumps to the AdminNetworkPolicy chains in order of priority, and to the BaselineAdminNetworkPolicy chains
+	if pMgr.EnableAdminNetworkPolicies {
+		adminPolicies := policiesInTier(policies, AdminTier)
+		sort.SliceStable(adminPolicies, func(i, j int) bool {
+			return adminPolicyBefore(adminPolicies[i], adminPolicies[j])
+		})
+		baselinePolicies := policiesInTier(policies, BaselineAdminTier)
+		creator.AddLine("", nil, nftChainSpecs("flush", util.IptablesAzureAdminIngressChain)...)
+		writeNFTIngressJumps(creator, util.IptablesAzureAdminIngressChain, adminPolicies)
+		creator.AddLine("", nil, nftChainSpecs("flush", util.IptablesAzureAdminEgressChain)...)
+		writeNFTEgressJumps(creator, util.IptablesAzureAdminEgressChain, adminPolicies)
+		creator.AddLine("", nil, nftChainSpecs("flush", util.IptablesAzureBaselineIngressChain)...)
+		writeNFTIngressJumps(creator, util.IptablesAzureBaselineIngressChain, baselinePolicies)
+		creator.AddLine("", nil, nftChainSpecs("flush", util.IptablesAzureBaselineEgressChain)...)
+		writeNFTEgressJumps(creator, util.IptablesAzureBaselineEgressChain, baselinePolicies)
+	}
+	networkPolicies := policiesInTier(policies, NetworkPolicyTier)
+
+	// 3. add the ingress jumps followed by the rules of AZURE-NPM-INGRESS
+	creator.AddLine("", nil, nftChainSpecs("flush", util.IptablesAzureIngressChain)...)
+	if pMgr.EnableAdminNetworkPolicies {
+		creator.AddLine("", nil, nftRuleSpecs(util.IptablesAzureIngressChain, "jump", util.IptablesAzureAdminIngressChain)...)
+	}
+	writeNFTIngressJumps(creator, util.IptablesAzureIngressChain, networkPolicies)
+	if pMgr.EnableDenyLogging {
+		ingressLogSpecs := nftRuleSpecs(util.IptablesAzureIngressChain, nftOnMarkSpecs(nftIngressDropMark)...)
+		ingressLogSpecs = append(ingressLogSpecs, nftDenyLogSpecs(util.IptablesAzureIngressDenyLogPrefix)...)
+		creator.AddLine("", nil, ingressLogSpecs...)
+	}
+	ingressDropSpecs := nftRuleSpecs(util.IptablesAzureIngressChain, nftOnMarkSpecs(nftIngressDropMark)...)
+	ingressDropSpecs = append(ingressDropSpecs, "drop")
+	ingressDropSpecs = append(ingressDropSpecs, nftCommentSpecs(fmt.Sprintf("DROP-ON-INGRESS-DROP-MARK-%s", util.IptablesAzureIngressDropMarkHex))...)
+	creator.AddLine("", nil, ingressDropSpecs...)
+	if pMgr.EnableAdminNetworkPolicies {
+		creator.AddLine("", nil, nftRuleSpecs(util.IptablesAzureIngressChain, "jump", util.IptablesAzureBaselineIngressChain)...)
+	}
+
+	// 4. add the egress jumps followed by the rules of AZURE-NPM-EGRESS
+	creator.AddLine("", nil, nftChainSpecs("flush", util.IptablesAzureEgressChain)...)
+	if pMgr.EnableAdminNetworkPolicies {
+		creator.AddLine("", nil, nftRuleSpecs(util.IptablesAzureEgressChain, "jump", util.IptablesAzureAdminEgressChain)...)
+	}
+	writeNFTEgressJumps(creator, util.IptablesAzureEgressChain, networkPolicies)
+	if pMgr.EnableDenyLogging {
+		egressLogSpecs := nftRuleSpecs(util.IptablesAzureEgressChain, nftOnMarkSpecs(nftEgressDropMark)...)
+		egressLogSpecs = append(egressLogSpecs, nftDenyLogSpecs(util.IptablesAzureEgressDenyLogPrefix)...)
+		creator.AddLine("", nil, egressLogSpecs...)
+	}
+	egressDropSpecs := nftRuleSpecs(util.IptablesAzureEgressChain, nftOnMarkSpecs(nftEgressDropMark)...)
+	egressDropSpecs = append(egressDropSpecs, "drop")
+	egressDropSpecs = append(egressDropSpecs, nftCommentSpecs(fmt.Sprintf("DROP-ON-EGRESS-DROP-MARK-%s", util.IptablesAzureEgressDropMarkHex))...)
+	creator.AddLine("", nil, egressDropSpecs...)
+	if pMgr.EnableAdminNetworkPolicies {
+		creator.AddLine("", nil, nftRuleSpecs(util.IptablesAzureEgressChain, "jump", util.IptablesAzureBaselineEgressChain)...)
+	}
+
+	acceptOnIngressMatchSpecs := nftRuleSpecs(util.IptablesAzureEgressChain, nftOnMarkSpecs(nftIngressAllowMark)...)
+	acceptOnIngressMatchSpecs = append(acceptOnIngressMatchSpecs, "jump", util.IptablesAzureAcceptChain)
+	acceptOnIngressMatchSpecs = append(acceptOnIngressMatchSpecs, nftCommentSpecs(fmt.Sprintf("ACCEPT-ON-INGRESS-ALLOW-MARK-%s", util.IptablesAzureIngressAllowMarkHex))...)
+	creator.AddLine("", nil, acceptOnIngressMatchSpecs...)
+}
+
+func writeNFTIngressJumps(creator *ioutil.FileCreator, chain string, policies []*NPMNetworkPolicy) {
+	for _, networkPolicy := range policies {
+		if hasIngress, _ := networkPolicy.hasIngressAndEgress(); hasIngress {
+			creator.AddLine("", nil, nftRuleSpecs(chain, nftIngressJumpSpecs(networkPolicy)...)...)
+		}
+	}
+}
+
+func writeNFTEgressJumps(creator *ioutil.FileCreator, chain string, policies []*NPMNetworkPolicy) {
+	for _, networkPolicy := range policies {
+		if _, hasEgress := networkPolicy.hasIngressAndEgress(); hasEgress {
+			creator.AddLine("", nil, nftRuleSpecs(chain, nftEgressJumpSpecs(networkPolicy)...)...)
+		}
+	}
+}
+
+// returns the policies in the tier, keeping their order
+func policiesInTier(policies []*NPMNetworkPolicy, tier PolicyTier) []*NPMNetworkPolicy {
+	result := make([]*NPMNetworkPolicy, 0, len(policies))
+	for _, networkPolicy := range policies {
+		if networkPolicy.Tier == tier {
+			result = append(result, networkPolicy)
+		}
+	}
+	return result
+}
+
+// write rules for the policy chain(s)
+func writeNFTNetworkPolicyRules(creator *ioutil.FileCreator, networkPolicy *NPMNetworkPolicy) {
+	if networkPolicy.Tier == AdminTier {
+		// skip the rest of the AdminNetworkPolicies after an earlier one passed the packet
+		hasIngress, hasEgress := networkPolicy.hasIngressAndEgress()
+		if hasIngress {
+			creator.AddLine("", nil, nftReturnOnPassMarkSpecs(networkPolicy.ingressChainName(), nftIngressPassMark)...)
+		}
+		if hasEgress {
+			creator.AddLine("", nil, nftReturnOnPassMarkSpecs(networkPolicy.egressChainName(), nftEgressPassMark)...)
+		}
+	}
+
+	for _, aclPolicy := range networkPolicy.ACLs {
+		var chainName string
+		var actionSpecs []string
+		if aclPolicy.hasIngress() {
+			chainName = networkPolicy.ingressChainName()
+			switch {
+			case aclPolicy.Target == Allowed:
+				actionSpecs = []string{"jump", util.IptablesAzureIngressAllowMarkChain}
+			case aclPolicy.Target == Passed:
+				// unlike in iptables, the mark can be set and the chain returned from in one rule
+				actionSpecs = append(nftSetMarkSpecs(nftIngressPassMark), "return")
+			case networkPolicy.IsAdminTier():
+				actionSpecs = []string{"drop"}
+			default:
+				actionSpecs = nftSetMarkSpecs(nftIngressDropMark)
+			}
+		} else {
+			chainName = networkPolicy.egressChainName()
+			switch {
+			case aclPolicy.Target == Allowed:
+				actionSpecs = []string{"jump", util.IptablesAzureAcceptChain}
+			case aclPolicy.Target == Passed:
+				actionSpecs = append(nftSetMarkSpecs(nftEgressPassMark), "return")
+			case networkPolicy.IsAdminTier():
+				actionSpecs = []string{"drop"}
+			default:
+				actionSpecs = nftSetMarkSpecs(nftEgressDropMark)
+			}
+		}
+		specs := nftRuleSpecs(chainName, nftACLRuleSpecs(aclPolicy)...)
+		specs = append(specs, actionSpecs...)
+		specs = append(specs, nftCommentSpecs(aclPolicy.comment())...)
+		creator.AddLine("", nil, specs...)
+	}
+}
+
+func nftACLRuleSpecs(aclPolicy *ACLPolicy) []string {
+	specs := make([]string, 0)
+	if aclPolicy.Protocol != UnspecifiedProtocol {
+		protocol := strings.ToLower(string(aclPolicy.Protocol))
+		if aclPolicy.DstPorts.isUnspecified() {
+			specs = append(specs, "meta", "l4proto", protocol)
+		} else {
+			specs = append(specs, protocol, "dport", aclPolicy.DstPorts.toNFTString())
+		}
+	}
+	for _, setInfo := range aclPolicy.SrcList {
+		specs = append(specs, setInfo.nftMatchSetSpecs(setInfo.MatchType)...)
+	}
+	for _, setInfo := range aclPolicy.DstList {
+		specs = append(specs, setInfo.nftMatchSetSpecs(setInfo.MatchType)...)
+	}
+	return specs
+}
+
+func nftIngressJumpSpecs(networkPolicy *NPMNetworkPolicy) []string {
+	specs := nftMatchSetSpecsForNetworkPolicy(networkPolicy, DstMatch)
+	specs = append(specs, "jump", networkPolicy.ingressChainName())
+	return append(specs, nftCommentSpecs(networkPolicy.commentForJumpToIngress())...)
+}
+
+func nftEgressJumpSpecs(networkPolicy *NPMNetworkPolicy) []string {
+	specs := nftMatchSetSpecsForNetworkPolicy(networkPolicy, SrcMatch)
+	specs = append(specs, "jump", networkPolicy.egressChainName())
+	return append(specs, nftCommentSpecs(networkPolicy.commentForJumpToEgress())...)
+}
+
+func nftMatchSetSpecsForNetworkPolicy(networkPolicy *NPMNetworkPolicy, matchType MatchType) []string {
+	specs := make([]string, 0)
+	for _, setInfo := range networkPolicy.PodSelectorList {
+		specs = append(specs, setInfo.nftMatchSetSpecs(matchType)...)
+	}
+	return specs
+}
+
+// nftMatchSetSpecs matches the set like "ip saddr != @azure-npm-123".
+// Named port sets have elements like "10.0.0.1 . tcp . 80", so they are matched with the destination ip, protocol, and port.
+func (info SetInfo) nftMatchSetSpecs(matchType MatchType) []string {
+	var specs []string
+	switch {
+	case info.IPSet.Type == ipsets.NamedPorts:
+		specs = []string{"ip", "daddr", ".", "meta", "l4proto", ".", "th", "dport"}
+	case matchType == SrcMatch:
+		specs = []string{"ip", "saddr"}
+	default:
+		specs = []string{"ip", "daddr"}
+	}
+	if !info.Included {
+		specs = append(specs, "!=")
+	}
+	return append(specs, "@"+info.IPSet.GetHashedName())
+}
+
+func (portRange *Ports) toNFTString() string {
+	if portRange.Port == portRange.EndPort {
+		return fmt.Sprint(portRange.Port)
+	}
+	return fmt.Sprintf("%d-%d", portRange.Port, portRange.EndPort)
+}
+
+func nftTableSpecs(verb string) []string {
+	return []string{verb, "table", util.NftablesFamily, util.NftablesAzureTable}
+}
+
+func nftChainSpecs(verb, chain string) []string {
+	return []string{verb, "chain", util.NftablesFamily, util.NftablesAzureTable, chain}
+}
+
+func nftRuleSpecs(chain string, specs ...string) []string {
+	return append([]string{"add", "rule", util.NftablesFamily, util.NftablesAzureTable, chain}, specs...)
+}
+
+// iptables matches "--mark value/mask" while nft needs "meta mark & mask == value"
+func nftOnMarkSpecs(mark string) []string {
+	return []string{"meta", "mark", "&", mark, "==", mark}
+}
+
+func nftReturnOnPassMarkSpecs(chain, passMark string) []string {
+	specs := nftRuleSpecs(chain, nftOnMarkSpecs(passMark)...)
+	specs = append(specs, "return")
+	return append(specs, nftCommentSpecs(fmt.Sprintf("RETURN-ON-PASS-MARK-%s", passMark))...)
+}
+
+// like "--set-mark value/value" in iptables, only sets the bits of the mark
+func nftSetMarkSpecs(mark string) []string {
+	return []string{"meta", "mark", "set", "meta", "mark", "|", mark}
+}
+
+// like the NFLOG target in iptables
+func nftDenyLogSpecs(prefix string) []string {
+	return []string{"log", "prefix", fmt.Sprintf("\"%s\"", prefix), "group", fmt.Sprint(util.IptablesAzureDenyLogGroup)}
+}
+
+func nftCommentSpecs(comment string) []string {
+	if len(comment) > nftMaxCommentLength {
+		comment = comment[:nftMaxCommentLength]
+	}
+	return []string{"comment", fmt.Sprintf("\"%s\"", comment)}
+}
+
+// returns the value of a mark like 0x200/0x200
+func markValue(mark string) string {
+	return strings.Split(mark, "/")[0]
+}
@@ -0,0 +1,328 @@
+package policies
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/common"
+	"github.com/Azure/azure-container-networking/npm/pkg/dataplane/ipsets"
+	dptestutils "github.com/Azure/azure-container-networking/npm/pkg/dataplane/testutils"
+	"github.com/Azure/azure-container-networking/npm/util"
+	testutils "github.com/Azure/azure-container-networking/test/utils"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	nftConfig = &PolicyManagerCfg{
+		PolicyMode:           IPSetPolicyMode,
+		PlaceAzureChainFirst: util.PlaceAzureChainFirst,
+		UseNFTables:          true,
+	}
+
+	fakeNFTCommand        = testutils.TestCmd{Cmd: []string{"nft", "-f", "/dev/stdin"}}
+	fakeNFTFailureCommand = testutils.TestCmd{Cmd: []string{"nft", "-f", "/dev/stdin"}, ExitCode: 1}
+)
+
+// nft rule variables for ACLs
+var (
+	nftIngressDropRule = fmt.Sprintf(
+		"tcp dport 222-333 ip saddr @%s ip daddr != @%s meta mark set meta mark | 0x400 comment \"%s\"",
+		ipsets.TestCIDRSet.HashedName,
+		ipsets.TestKeyPodSet.HashedName,
+		ingressDropComment,
+	)
+	nftIngressAllowRule = fmt.Sprintf("ip saddr @%s jump AZURE-NPM-INGRESS-ALLOW-MARK comment \"%s\"", ipsets.TestCIDRSet.HashedName, ingressAllowComment)
+	nftEgressDropRule   = fmt.Sprintf("udp dport 144 ip daddr @%s meta mark set meta mark | 0x800 comment \"%s\"", ipsets.TestCIDRSet.HashedName, egressDropComment)
+	nftEgressAllowRule  = fmt.Sprintf(
+		"ip daddr . meta l4proto . th dport @%s jump AZURE-NPM-ACCEPT comment \"%s\"",
+		ipsets.TestNamedportSet.HashedName,
+		egressAllowComment,
+	)
+
+	nftBothDirectionsNetPolIngressJump = fmt.Sprintf(
+		"ip daddr @%s jump %s comment \"%s\"",
+		ipsets.TestKeyPodSet.HashedName,
+		bothDirectionsNetPolIngressChain,
+		bothDirectionsNetPolIngressJumpComment,
+	)
+	nftBothDirectionsNetPolEgressJump = fmt.Sprintf(
+		"ip saddr @%s jump %s comment \"%s\"",
+		ipsets.TestKeyPodSet.HashedName,
+		bothDirectionsNetPolEgressChain,
+		bothDirectionsNetPolEgressJumpComment,
+	)
+	nftEgressNetPolJump = fmt.Sprintf("jump %s comment \"%s\"", egressNetPolChain, egressNetPolJumpComment)
+)
+
+// lines for the rules of AZURE-NPM-INGRESS and AZURE-NPM-EGRESS after the jumps to policy chains
+var (
+	nftIngressDropOnMarkLine = "add rule ip azure-npm AZURE-NPM-INGRESS meta mark & 0x400 == 0x400 drop comment \"DROP-ON-INGRESS-DROP-MARK-0x400/0x400\""
+	nftEgressDropOnMarkLine  = "add rule ip azure-npm AZURE-NPM-EGRESS meta mark & 0x800 == 0x800 drop comment \"DROP-ON-EGRESS-DROP-MARK-0x800/0x800\""
+	nftEgressAcceptLine      = "add rule ip azure-npm AZURE-NPM-EGRESS meta mark & 0x200 == 0x200 jump AZURE-NPM-ACCEPT comment \"ACCEPT-ON-INGRESS-ALLOW-MARK-0x200/0x200\""
+)
+
+func TestCreatorForNFTBootup(t *testing.T) {
+	tests := []struct {
+		name                 string
+		placeAzureChainFirst bool
+		expectedPriority     string
+	}{
+		{
+			name:                 "place azure chain first",
+			placeAzureChainFirst: util.PlaceAzureChainFirst,
+			expectedPriority:     "-1",
+		},
+		{
+			name:                 "place azure chain after kube services",
+			placeAzureChainFirst: util.PlaceAzureChainAfterKubeServices,
+			expectedPriority:     "1",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ioshim := common.NewMockIOShim(nil)
+			defer ioshim.VerifyCalls(t, nil)
+			cfg := &PolicyManagerCfg{
+				PolicyMode:           IPSetPolicyMode,
+				PlaceAzureChainFirst: tt.placeAzureChainFirst,
+				UseNFTables:          true,
+			}
+			pMgr := NewPolicyManager(ioshim, cfg)
+
+			creator := pMgr.creatorForNFTBootup()
+			actualLines := strings.Split(creator.ToString(), "\n")
+			expectedLines := []string{
+				"add table ip azure-npm",
+				"delete table ip azure-npm",
+				"add table ip azure-npm",
+				fmt.Sprintf("add chain ip azure-npm AZURE-NPM-FORWARD { type filter hook forward priority %s ; policy accept ; }", tt.expectedPriority),
+				"add chain ip azure-npm AZURE-NPM",
+				"add chain ip azure-npm AZURE-NPM-INGRESS",
+				"add chain ip azure-npm AZURE-NPM-INGRESS-ALLOW-MARK",
+				"add chain ip azure-npm AZURE-NPM-EGRESS",
+				"add chain ip azure-npm AZURE-NPM-ACCEPT",
+				"add rule ip azure-npm AZURE-NPM-FORWARD ct state new jump AZURE-NPM",
+				"flush chain ip azure-npm AZURE-NPM",
+				"flush chain ip azure-npm AZURE-NPM-INGRESS",
+				nftIngressDropOnMarkLine,
+				"flush chain ip azure-npm AZURE-NPM-EGRESS",
+				nftEgressDropOnMarkLine,
+				nftEgressAcceptLine,
+				"add rule ip azure-npm AZURE-NPM-INGRESS-ALLOW-MARK meta mark set meta mark | 0x200 comment \"SET-INGRESS-ALLOW-MARK-0x200/0x200\"",
+				"add rule ip azure-npm AZURE-NPM-INGRESS-ALLOW-MARK jump AZURE-NPM-EGRESS",
+				"add rule ip azure-npm AZURE-NPM-ACCEPT accept",
+				"",
+			}
+			dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+		})
+	}
+}
+
+func TestCreatorForNFTBootupWithDenyLogging(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	cfg := &PolicyManagerCfg{
+		PolicyMode:           IPSetPolicyMode,
+		PlaceAzureChainFirst: util.PlaceAzureChainFirst,
+		UseNFTables:          true,
+		EnableDenyLogging:    true,
+	}
+	pMgr := NewPolicyManager(ioshim, cfg)
+
+	creator := pMgr.creatorForNFTBootup()
+	actualLines := strings.Split(creator.ToString(), "\n")
+	expectedLines := []string{
+		"add table ip azure-npm",
+		"delete table ip azure-npm",
+		"add table ip azure-npm",
+		"add chain ip azure-npm AZURE-NPM-FORWARD { type filter hook forward priority -1 ; policy accept ; }",
+		"add chain ip azure-npm AZURE-NPM",
+		"add chain ip azure-npm AZURE-NPM-INGRESS",
+		"add chain ip azure-npm AZURE-NPM-INGRESS-ALLOW-MARK",
+		"add chain ip azure-npm AZURE-NPM-EGRESS",
+		"add chain ip azure-npm AZURE-NPM-ACCEPT",
+		"add rule ip azure-npm AZURE-NPM-FORWARD ct state new jump AZURE-NPM",
+		"flush chain ip azure-npm AZURE-NPM",
+		"flush chain ip azure-npm AZURE-NPM-INGRESS",
+		"add rule ip azure-npm AZURE-NPM-INGRESS meta mark & 0x400 == 0x400 log prefix \"AZURE-NPM-INGRESS-DROP\" group 1010",
+		nftIngressDropOnMarkLine,
+		"flush chain ip azure-npm AZURE-NPM-EGRESS",
+		"add rule ip azure-npm AZURE-NPM-EGRESS meta mark & 0x800 == 0x800 log prefix \"AZURE-NPM-EGRESS-DROP\" group 1010",
+		nftEgressDropOnMarkLine,
+		nftEgressAcceptLine,
+		"add rule ip azure-npm AZURE-NPM-INGRESS-ALLOW-MARK meta mark set meta mark | 0x200 comment \"SET-INGRESS-ALLOW-MARK-0x200/0x200\"",
+		"add rule ip azure-npm AZURE-NPM-INGRESS-ALLOW-MARK jump AZURE-NPM-EGRESS",
+		"add rule ip azure-npm AZURE-NPM-ACCEPT accept",
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+}
+
+func TestCreatorForNFTAddPolicies(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	pMgr := NewPolicyManager(ioshim, nftConfig)
+
+	toAdd := []*NPMNetworkPolicy{bothDirectionsNetPol}
+	creator := pMgr.creatorForNFTPolicies(toAdd, nil, []*NPMNetworkPolicy{bothDirectionsNetPol, egressNetPol})
+	actualLines := strings.Split(creator.ToString(), "\n")
+	expectedLines := []string{
+		fmt.Sprintf("add chain ip azure-npm %s", bothDirectionsNetPolIngressChain),
+		fmt.Sprintf("flush chain ip azure-npm %s", bothDirectionsNetPolIngressChain),
+		fmt.Sprintf("add chain ip azure-npm %s", bothDirectionsNetPolEgressChain),
+		fmt.Sprintf("flush chain ip azure-npm %s", bothDirectionsNetPolEgressChain),
+		fmt.Sprintf("add rule ip azure-npm %s %s", bothDirectionsNetPolIngressChain, nftIngressDropRule),
+		fmt.Sprintf("add rule ip azure-npm %s %s", bothDirectionsNetPolIngressChain, nftIngressAllowRule),
+		fmt.Sprintf("add rule ip azure-npm %s %s", bothDirectionsNetPolEgressChain, nftEgressDropRule),
+		fmt.Sprintf("add rule ip azure-npm %s %s", bothDirectionsNetPolEgressChain, nftEgressAllowRule),
+		// activation
+		"flush chain ip azure-npm AZURE-NPM",
+		"add rule ip azure-npm AZURE-NPM jump AZURE-NPM-INGRESS",
+		"add rule ip azure-npm AZURE-NPM jump AZURE-NPM-EGRESS",
+		"add rule ip azure-npm AZURE-NPM jump AZURE-NPM-ACCEPT",
+		// jumps
+		"flush chain ip azure-npm AZURE-NPM-INGRESS",
+		fmt.Sprintf("add rule ip azure-npm AZURE-NPM-INGRESS %s", nftBothDirectionsNetPolIngressJump),
+		nftIngressDropOnMarkLine,
+		"flush chain ip azure-npm AZURE-NPM-EGRESS",
+		fmt.Sprintf("add rule ip azure-npm AZURE-NPM-EGRESS %s", nftBothDirectionsNetPolEgressJump),
+		fmt.Sprintf("add rule ip azure-npm AZURE-NPM-EGRESS %s", nftEgressNetPolJump),
+		nftEgressDropOnMarkLine,
+		nftEgressAcceptLine,
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+}
+
+func TestCreatorForNFTAddAdminPolicies(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	cfg := &PolicyManagerCfg{
+		PolicyMode:                 IPSetPolicyMode,
+		PlaceAzureChainFirst:       util.PlaceAzureChainFirst,
+		UseNFTables:                true,
+		EnableAdminNetworkPolicies: true,
+	}
+	pMgr := NewPolicyManager(ioshim, cfg)
+
+	passPolicy := &NPMNetworkPolicy{
+		Name:      "pass",
+		PolicyKey: "AdminNetworkPolicy/pass",
+		ACLs: []*ACLPolicy{
+			{
+				SrcList:   []SetInfo{{ipsets.TestCIDRSet.Metadata, true, SrcMatch}},
+				Target:    Passed,
+				Direction: Ingress,
+				Protocol:  UnspecifiedProtocol,
+			},
+		},
+		Tier:     AdminTier,
+		Priority: 20,
+	}
+	denyPolicy := &NPMNetworkPolicy{
+		Name:      "deny",
+		PolicyKey: "AdminNetworkPolicy/deny",
+		ACLs:      []*ACLPolicy{ingressDeniedACL},
+		Tier:      AdminTier,
+		Priority:  10,
+	}
+	baselinePolicy := &NPMNetworkPolicy{
+		Name:      "default",
+		PolicyKey: "BaselineAdminNetworkPolicy/default",
+		ACLs:      []*ACLPolicy{egressAllowedACL},
+		Tier:      BaselineAdminTier,
+	}
+
+	// the policies are sorted by key, so the higher priority policy comes second
+	toAdd := []*NPMNetworkPolicy{passPolicy}
+	creator := pMgr.creatorForNFTPolicies(toAdd, nil, []*NPMNetworkPolicy{passPolicy, baselinePolicy, denyPolicy, egressNetPol})
+	actualLines := strings.Split(creator.ToString(), "\n")
+	passChain := passPolicy.ingressChainName()
+	expectedLines := []string{
+		fmt.Sprintf("add chain ip azure-npm %s", passChain),
+		fmt.Sprintf("flush chain ip azure-npm %s", passChain),
+		fmt.Sprintf("add rule ip azure-npm %s meta mark & 0x1000 == 0x1000 return comment \"RETURN-ON-PASS-MARK-0x1000\"", passChain),
+		fmt.Sprintf("add rule ip azure-npm %s ip saddr @%s meta mark set meta mark | 0x1000 return comment \"PASS-FROM-cidr-test-cidr-set\"",
+			passChain, ipsets.TestCIDRSet.HashedName),
+		// activation
+		"flush chain ip azure-npm AZURE-NPM",
+		"add rule ip azure-npm AZURE-NPM jump AZURE-NPM-INGRESS",
+		"add rule ip azure-npm AZURE-NPM jump AZURE-NPM-EGRESS",
+		"add rule ip azure-npm AZURE-NPM jump AZURE-NPM-ACCEPT",
+		// admin jumps in order of priority
+		"flush chain ip azure-npm AZURE-NPM-ADMIN-INGRESS",
+		fmt.Sprintf("add rule ip azure-npm AZURE-NPM-ADMIN-INGRESS jump %s comment \"INGRESS-POLICY-AdminNetworkPolicy/deny-TO-all\"", denyPolicy.ingressChainName()),
+		fmt.Sprintf("add rule ip azure-npm AZURE-NPM-ADMIN-INGRESS jump %s comment \"INGRESS-POLICY-AdminNetworkPolicy/pass-TO-all\"", passChain),
+		"flush chain ip azure-npm AZURE-NPM-ADMIN-EGRESS",
+		"flush chain ip azure-npm AZURE-NPM-BASELINE-INGRESS",
+		"flush chain ip azure-npm AZURE-NPM-BASELINE-EGRESS",
+		fmt.Sprintf("add rule ip azure-npm AZURE-NPM-BASELINE-EGRESS jump %s comment \"EGRESS-POLICY-BaselineAdminNetworkPolicy/default-FROM-all\"",
+			baselinePolicy.egressChainName()),
+		// NetworkPolicy jumps between the admin and baseline admin jumps
+		"flush chain ip azure-npm AZURE-NPM-INGRESS",
+		"add rule ip azure-npm AZURE-NPM-INGRESS jump AZURE-NPM-ADMIN-INGRESS",
+		nftIngressDropOnMarkLine,
+		"add rule ip azure-npm AZURE-NPM-INGRESS jump AZURE-NPM-BASELINE-INGRESS",
+		"flush chain ip azure-npm AZURE-NPM-EGRESS",
+		"add rule ip azure-npm AZURE-NPM-EGRESS jump AZURE-NPM-ADMIN-EGRESS",
+		fmt.Sprintf("add rule ip azure-npm AZURE-NPM-EGRESS %s", nftEgressNetPolJump),
+		nftEgressDropOnMarkLine,
+		"add rule ip azure-npm AZURE-NPM-EGRESS jump AZURE-NPM-BASELINE-EGRESS",
+		nftEgressAcceptLine,
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+
+	// admin policies deny right away
+	creator = pMgr.creatorForNFTPolicies([]*NPMNetworkPolicy{denyPolicy}, nil, nil)
+	require.Contains(t, creator.ToString(), fmt.Sprintf("add rule ip azure-npm %s %s", denyPolicy.ingressChainName(),
+		strings.Replace(nftIngressDropRule, "meta mark set meta mark | 0x400", "drop", 1)))
+}
+
+func TestCreatorForNFTRemovePolicies(t *testing.T) {
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	pMgr := NewPolicyManager(ioshim, nftConfig)
+
+	// removing the last policy deactivates NPM
+	creator := pMgr.creatorForNFTPolicies(nil, []*NPMNetworkPolicy{bothDirectionsNetPol}, nil)
+	actualLines := strings.Split(creator.ToString(), "\n")
+	expectedLines := []string{
+		"flush chain ip azure-npm AZURE-NPM",
+		"flush chain ip azure-npm AZURE-NPM-INGRESS",
+		nftIngressDropOnMarkLine,
+		"flush chain ip azure-npm AZURE-NPM-EGRESS",
+		nftEgressDropOnMarkLine,
+		nftEgressAcceptLine,
+		fmt.Sprintf("delete chain ip azure-npm %s", bothDirectionsNetPolIngressChain),
+		fmt.Sprintf("delete chain ip azure-npm %s", bothDirectionsNetPolEgressChain),
+		"",
+	}
+	dptestutils.AssertEqualLines(t, expectedLines, actualLines)
+}
+
+func TestAddAndRemovePolicyNFT(t *testing.T) {
+	calls := []testutils.TestCmd{fakeNFTCommand, fakeNFTCommand, fakeNFTCommand}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, nftConfig)
+
+	require.NoError(t, pMgr.Bootup(nil))
+	require.NoError(t, pMgr.AddPolicy(bothDirectionsNetPol, nil))
+	require.True(t, pMgr.PolicyExists(bothDirectionsNetPol.PolicyKey))
+	require.NoError(t, pMgr.RemovePolicy(bothDirectionsNetPol.PolicyKey, nil))
+	require.False(t, pMgr.PolicyExists(bothDirectionsNetPol.PolicyKey))
+}
+
+func TestAddPolicyNFTFailure(t *testing.T) {
+	calls := []testutils.TestCmd{fakeNFTFailureCommand, fakeNFTFailureCommand}
+	ioshim := common.NewMockIOShim(calls)
+	defer ioshim.VerifyCalls(t, calls)
+	pMgr := NewPolicyManager(ioshim, nftConfig)
+
+	require.Error(t, pMgr.AddPolicy(bothDirectionsNetPol, nil))
+	require.False(t, pMgr.PolicyExists(bothDirectionsNetPol.PolicyKey))
+}
@@ -186,10 +186,100 @@ func TestRemoveNonexistentPolicy(t *testing.T) {
 	promVals{0, 0}.testPrometheusMetrics(t)
 }
 
+func TestGetPoliciesSelecting(t *testing.T) {
+	ingressPolicy := &NPMNetworkPolicy{
+		PolicyKey: "x/ingress",
+		PodSelectorList: []SetInfo{
+			{IPSet: testNSSet, Included: true, MatchType: DstMatch},
+		},
+		ACLs: []*ACLPolicy{{Target: Dropped, Direction: Ingress}},
+	}
+	egressPolicy := &NPMNetworkPolicy{
+		PolicyKey: "x/egress",
+		PodSelectorList: []SetInfo{
+			{IPSet: testNSSet, Included: true, MatchType: SrcMatch},
+			{IPSet: testKeyPodSet, Included: false, MatchType: SrcMatch},
+		},
+		ACLs: []*ACLPolicy{{Target: Dropped, Direction: Egress}},
+	}
+	bothPolicy := &NPMNetworkPolicy{
+		PolicyKey: "x/both",
+		PodSelectorList: []SetInfo{
+			{IPSet: testKeyPodSet, Included: true, MatchType: DstMatch},
+		},
+		ACLs: []*ACLPolicy{{Target: Dropped, Direction: Both}},
+	}
+
+	ioshim := common.NewMockIOShim(nil)
+	defer ioshim.VerifyCalls(t, nil)
+	pMgr := NewPolicyManager(ioshim, ipsetConfig)
+	for _, policy := range []*NPMNetworkPolicy{ingressPolicy, egressPolicy, bothPolicy} {
+		pMgr.policyMap.cache[policy.PolicyKey] = policy
+	}
+
+	tests := []struct {
+		name        string
+		direction   Direction
+		memberSets  []string
+		expectedIDs []string
+	}{
+		{
+			name:        "ingress in namespace set",
+			direction:   Ingress,
+			memberSets:  []string{testNSSet.GetPrefixName()},
+			expectedIDs: []string{"x/ingress"},
+		},
+		{
+			name:        "ingress in both sets",
+			direction:   Ingress,
+			memberSets:  []string{testNSSet.GetPrefixName(), testKeyPodSet.GetPrefixName()},
+			expectedIDs: []string{"x/both", "x/ingress"},
+		},
+		{
+			name:        "egress in namespace set",
+			direction:   Egress,
+			memberSets:  []string{testNSSet.GetPrefixName()},
+			expectedIDs: []string{"x/egress"},
+		},
+		{
+			name:        "egress excluded by set which isn't included",
+			direction:   Egress,
+			memberSets:  []string{testNSSet.GetPrefixName(), testKeyPodSet.GetPrefixName()},
+			expectedIDs: []string{"x/both"},
+		},
+		{
+			name:        "in no sets",
+			direction:   Ingress,
+			memberSets:  nil,
+			expectedIDs: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			isMember := func(setName string) bool {
+				for _, name := range tt.memberSets {
+					if name == setName {
+						return true
+					}
+				}
+				return false
+			}
+			policyKeys := make([]string, 0)
+			for _, policy := range pMgr.GetPoliciesSelecting(tt.direction, isMember) {
+				policyKeys = append(policyKeys, policy.PolicyKey)
+			}
+			require.ElementsMatch(t, tt.expectedIDs, policyKeys)
+		})
+	}
+}
+
 func TestNormalizeAndValidatePolicy(t *testing.T) {
 	tests := []struct {
 		name    string
 		acl     *ACLPolicy
+		tier    PolicyTier
 		wantErr bool
 	}{
 		{
@@ -211,6 +301,45 @@ func TestNormalizeAndValidatePolicy(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "pass in admin policy",
+			acl: &ACLPolicy{
+				PolicyID:  "pass-acl",
+				Target:    Passed,
+				Direction: Ingress,
+			},
+			tier:    AdminTier,
+			wantErr: false,
+		},
+		{
+			name: "pass in baseline admin policy",
+			acl: &ACLPolicy{
+				PolicyID:  "pass-acl",
+				Target:    Passed,
+				Direction: Ingress,
+			},
+			tier:    BaselineAdminTier,
+			wantErr: true,
+		},
+		{
+			name: "pass in network policy",
+			acl: &ACLPolicy{
+				PolicyID:  "pass-acl",
+				Target:    Passed,
+				Direction: Egress,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown tier",
+			acl: &ACLPolicy{
+				PolicyID:  "valid-acl",
+				Target:    Dropped,
+				Direction: Ingress,
+			},
+			tier:    "invalid",
+			wantErr: true,
+		},
 		// TODO add other invalid cases
 	}
 	for _, tt := range tests {
@@ -221,6 +350,7 @@ func TestNormalizeAndValidatePolicy(t *testing.T) {
 				NameSpace: "x",
 				PolicyKey: "x/test-netpol",
 				ACLs:      []*ACLPolicy{tt.acl},
+				Tier:      tt.tier,
 			}
 			NormalizePolicy(netPol)
 			err := ValidatePolicy(netPol)
@@ -11,10 +11,12 @@ import (
 )
 
 var (
-	ErrFailedMarshalACLSettings                      = errors.New("Failed to marshal ACL settings")
-	ErrFailedUnMarshalACLSettings                    = errors.New("Failed to unmarshal ACL settings")
-	resetAllACLs                  shouldResetAllACLs = true
-	removeOnlyGivenPolicy         shouldResetAllACLs = false
+	ErrFailedMarshalACLSettings                         = errors.New("Failed to marshal ACL settings")
+	ErrFailedUnMarshalACLSettings                       = errors.New("Failed to unmarshal ACL settings")
+	ErrUnsupportedAdminNetworkPolicy                    = errors.New("AdminNetworkPolicies are not supported on Windows")
+	ErrUnsupportedFQDNNetworkPolicy                     = errors.New("FQDNNetworkPolicies are not supported on Windows")
+	resetAllACLs                     shouldResetAllACLs = true
+	removeOnlyGivenPolicy            shouldResetAllACLs = false
 )
 
 type staleChains struct{} // unused in Windows
@@ -48,6 +50,12 @@ func (pMgr *PolicyManager) reconcile() {
 
 func (pMgr *PolicyManager) addPolicy(policy *NPMNetworkPolicy, endpointList map[string]string) error {
 	klog.Infof("[DataPlane Windows] adding policy %s on %+v", policy.Name, endpointList)
+	if policy.IsAdminTier() {
+		return fmt.Errorf("[DataPlane Windows] cannot add policy %s: %w", policy.PolicyKey, ErrUnsupportedAdminNetworkPolicy)
+	}
+	if policy.HasFQDNSets() {
+		return fmt.Errorf("[DataPlane Windows] cannot add policy %s: %w", policy.PolicyKey, ErrUnsupportedFQDNNetworkPolicy)
+	}
 	if endpointList == nil {
 		klog.Infof("[DataPlane Windows] No Endpoints to apply policy %s on", policy.Name)
 		return nil
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// RunCRDControllers runs the informers of the controllers for the optional CRDs, AdminNetworkPolicies and
+// FQDNNetworkPolicies, and starts each controller once its informers have synced. It doesn't wait for the sync,
+// since the informers of a CRD which isn't installed never sync, and that mustn't keep the other controllers from starting.
+func RunCRDControllers(informers *Informers, controllers *K8SControllersV2, stopCh <-chan struct{}) {
+	if controllers.AdminNetPolControllerV2 != nil {
+		go informers.AnpInformer.Run(stopCh)
+		go informers.BanpInformer.Run(stopCh)
+		go runWhenSynced("AdminNetworkPolicy", stopCh, controllers.AdminNetPolControllerV2.Run,
+			informers.AnpInformer.HasSynced, informers.BanpInformer.HasSynced)
+	}
+
+	if controllers.FQDNNetPolControllerV2 != nil {
+		go informers.FqdnInformer.Run(stopCh)
+		go runWhenSynced("FQDNNetworkPolicy", stopCh, func(stopCh <-chan struct{}) {
+			go controllers.FQDNResolver.Run(stopCh)
+			controllers.FQDNNetPolControllerV2.Run(stopCh)
+		}, informers.FqdnInformer.HasSynced)
+	}
+}
+
+// runWhenSynced runs a controller once its informers have synced.
+func runWhenSynced(kind string, stopCh <-chan struct{}, run func(stopCh <-chan struct{}), cacheSyncs ...cache.InformerSynced) {
+	klog.Infof("waiting for the %s informers to sync. They only sync once the %s CRD is installed", kind, kind)
+	if !cache.WaitForCacheSync(stopCh, cacheSyncs...) {
+		klog.Errorf("%s informer error: %v", kind, ErrInformerSyncFailure)
+		return
+	}
+
+	klog.Infof("%s informers synced, starting the %s controller", kind, kind)
+	run(stopCh)
+}
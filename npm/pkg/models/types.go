@@ -5,11 +5,13 @@ package models
 import (
 	controllersv1 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v1"
 	controllersv2 "github.com/Azure/azure-container-networking/npm/pkg/controlplane/controllers/v2"
+	"github.com/Azure/azure-container-networking/npm/pkg/controlplane/fqdn"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/informers"
 	coreinformers "k8s.io/client-go/informers/core/v1"
 	networkinginformers "k8s.io/client-go/informers/networking/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 var (
@@ -34,6 +36,11 @@ type K8SControllersV2 struct {
 	NamespaceControllerV2 *controllersv2.NamespaceController     //nolint:structcheck // false lint error
 	NpmNamespaceCacheV2   *controllersv2.NpmNamespaceCache       //nolint:structcheck // false lint error
 	NetPolControllerV2    *controllersv2.NetworkPolicyController //nolint:structcheck // false lint error
+	// AdminNetPolControllerV2 is only created when admin network policies are enabled
+	AdminNetPolControllerV2 *controllersv2.AdminNetworkPolicyController //nolint:structcheck // false lint error
+	// FQDNNetPolControllerV2 and FQDNResolver are only created when FQDN network policies are enabled
+	FQDNNetPolControllerV2 *controllersv2.FQDNNetworkPolicyController //nolint:structcheck // false lint error
+	FQDNResolver           *fqdn.Resolver                             //nolint:structcheck // false lint error
 }
 
 // Informers are the informers for the k8s controllers
@@ -42,6 +49,11 @@ type Informers struct {
 	PodInformer     coreinformers.PodInformer                 //nolint:structcheck // false lint error
 	NsInformer      coreinformers.NamespaceInformer           //nolint:structcheck // false lint error
 	NpInformer      networkinginformers.NetworkPolicyInformer //nolint:structcheck // false lint error
+	// AnpInformer and BanpInformer watch the AdminNetworkPolicy CRDs with the dynamic client, since they aren't in informerFactory
+	AnpInformer  cache.SharedIndexInformer //nolint:structcheck // false lint error
+	BanpInformer cache.SharedIndexInformer //nolint:structcheck // false lint error
+	// FqdnInformer watches the FQDNNetworkPolicy CRD with the dynamic client
+	FqdnInformer cache.SharedIndexInformer //nolint:structcheck // false lint error
 }
 
 // AzureConfig captures the Azure specific configurations and fields
@@ -76,6 +76,10 @@ const (
 	IptablesCommentFlag        string = "--comment"
 	IptablesAddCommentFlag
 
+	IptablesNFLOG           string = "NFLOG"
+	IptablesNFLOGGroupFlag  string = "--nflog-group"
+	IptablesNFLOGPrefixFlag string = "--nflog-prefix"
+
 	IptablesTableFlag       string = "-t"
 	IptablesListFlag        string = "-L"
 	IptablesNumericFlag     string = "-n"
@@ -103,6 +107,18 @@ const (
 	IptablesAzureIngressPolicyChainPrefix string = "AZURE-NPM-INGRESS"
 	IptablesAzureEgressPolicyChainPrefix  string = "AZURE-NPM-EGRESS"
 
+	// NPM v2 Chains for AdminNetworkPolicies, which are evaluated before NetworkPolicies,
+	// and BaselineAdminNetworkPolicies, which are evaluated when no NetworkPolicy selects a Pod
+	IptablesAzureAdminIngressChain    string = "AZURE-NPM-ADMIN-INGRESS"
+	IptablesAzureAdminEgressChain     string = "AZURE-NPM-ADMIN-EGRESS"
+	IptablesAzureBaselineIngressChain string = "AZURE-NPM-BASELINE-INGRESS"
+	IptablesAzureBaselineEgressChain  string = "AZURE-NPM-BASELINE-EGRESS"
+
+	// NFLOG group and prefixes of the rules logging packets right before they are dropped, used when deny logging is enabled
+	IptablesAzureDenyLogGroup         uint16 = 1010
+	IptablesAzureIngressDenyLogPrefix string = "AZURE-NPM-INGRESS-DROP"
+	IptablesAzureEgressDenyLogPrefix  string = "AZURE-NPM-EGRESS-DROP"
+
 	// Below chain exists only in NPM before v1.2.6
 	// TODO delete this below set while cleaning up
 	IptablesAzureTargetSetsChain string = "AZURE-NPM-TARGET-SETS"
@@ -128,6 +144,10 @@ const (
 	IptablesAzureIngressAllowMarkHex string = "0x200/0x200"
 	IptablesAzureIngressDropMarkHex  string = "0x400/0x400"
 	IptablesAzureEgressDropMarkHex   string = "0x800/0x800"
+	// set when an AdminNetworkPolicy passes traffic on to the NetworkPolicies.
+	// These reuse the bits of the NPM v1 marks, which v2 doesn't set.
+	IptablesAzureIngressPassMarkHex string = "0x1000/0x1000"
+	IptablesAzureEgressPassMarkHex  string = "0x2000/0x2000"
 
 	// marks in NPM v1
 	IptablesAzureIngressMarkHex string = "0x2000"
@@ -183,6 +203,7 @@ const (
 	NamespaceLabelPrefix string = "nslabel-"
 	PodLabelPrefix       string = "podlabel-"
 	CIDRPrefix           string = "cidr-"
+	FQDNPrefix           string = "fqdn-"
 	NestedLabelPrefix    string = "nestedlabel-"
 
 	NegationPrefix string = "not-"
@@ -190,6 +211,23 @@ const (
 	SetPolicyDelimiter string = ","
 )
 
+// nftables related constants.
+const (
+	Nftables         string = "nft"
+	NftablesFileFlag string = "-f"
+	// NftablesStdinFile lets nft read the file from stdin and report errors with line numbers in it.
+	NftablesStdinFile string = "/dev/stdin"
+	// NftablesLineErrorPattern matches the line with an error in the file e.g. "/dev/stdin:3:1-40: Error: Could not process rule: No such file or directory".
+	NftablesLineErrorPattern string = "/dev/stdin:(\\d+):"
+
+	// NftablesFamily is the family of the table NPM renders its sets and policies into.
+	// NPM only handles IPv4 traffic, like with iptables.
+	NftablesFamily     string = "ip"
+	NftablesAzureTable string = "azure-npm"
+	// NftablesAzureForwardChain is the base chain of the NPM table, hooked into forward like the jump from FORWARD to AZURE-NPM in iptables.
+	NftablesAzureForwardChain string = "AZURE-NPM-FORWARD"
+)
+
 // NPM telemetry constants.
 const (
 	AddNamespaceEvent    string = "Add Namespace"
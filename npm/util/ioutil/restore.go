@@ -30,6 +30,8 @@ type FileCreator struct {
 	tryCount               int
 	maxTryCount            int
 	ioShim                 *common.IOShim
+	// runFileLineNumbers specifies that error line numbers count the lines of the file that was run, without the omitted lines.
+	runFileLineNumbers bool
 }
 
 // TODO ideas:
@@ -70,6 +72,11 @@ const (
 	Continue LineErrorHandlerMethod = "continue"
 	// ContinueAndAbortSection specifies skipping this line, all previous lines, and all lines tied to this line's section
 	ContinueAndAbortSection LineErrorHandlerMethod = "continue-and-abort"
+	// Skip specifies skipping only this line.
+	// It is meant for commands like nft, which apply none of the lines of a file with an error.
+	Skip LineErrorHandlerMethod = "skip"
+	// SkipSection specifies skipping all lines tied to this line's section, but none of the previous lines.
+	SkipSection LineErrorHandlerMethod = "skip-section"
 
 	anyMatchPattern = ".*"
 )
@@ -105,6 +112,13 @@ func (creator *FileCreator) AddErrorToRetryOn(definition *ErrorDefinition) {
 	creator.errorsToRetryOn = append(creator.errorsToRetryOn, definition)
 }
 
+// UseRunFileLineNumbers makes the line numbers of line errors count the lines of the file that was run, which doesn't
+// include the omitted lines. It is meant for commands like nft, which apply none of the lines of a file with an error,
+// so that lines after the omitted ones are rerun. Otherwise a line number is the number of the line in the creator.
+func (creator *FileCreator) UseRunFileLineNumbers() {
+	creator.runFileLineNumbers = true
+}
+
 func (creator *FileCreator) AddLine(sectionID string, errorHandlers []*LineErrorHandler, items ...string) {
 	section, exists := creator.sections[sectionID]
 	if !exists {
@@ -262,6 +276,9 @@ func (definition *ErrorDefinition) getErrorLineNumber(stdErr, commandString stri
 // return whether the file was altered
 func (creator *FileCreator) handleLineError(stdErr, commandString string, lineNum int) bool {
 	lineNumIndex := lineNum - 1
+	if creator.runFileLineNumbers {
+		lineNumIndex = creator.lineIndex(lineNum)
+	}
 	line := creator.lines[lineNumIndex]
 	for _, errorHandler := range line.errorHandlers {
 		if !errorHandler.Definition.isMatch(stdErr) {
@@ -282,9 +299,34 @@ func (creator *FileCreator) handleLineError(stdErr, commandString string, lineNu
 			for _, lineNum := range section.lineNums {
 				creator.lineNumbersToOmit[lineNum] = struct{}{}
 			}
+		case Skip:
+			klog.Infof("skipping line %d for command [%s]", lineNum, commandString)
+			creator.lineNumbersToOmit[lineNumIndex] = struct{}{}
+		case SkipSection:
+			klog.Infof("skipping line %d and the section associated with the line for command [%s]", lineNum, commandString)
+			section := creator.sections[line.sectionID]
+			for _, lineNum := range section.lineNums {
+				creator.lineNumbersToOmit[lineNum] = struct{}{}
+			}
 		}
 		errorHandler.Callback()
 		return true
 	}
 	return false
 }
+
+// lineIndex returns the index in lines of the line with the given number in the file that was run,
+// which doesn't include the omitted lines.
+func (creator *FileCreator) lineIndex(lineNum int) int {
+	numLinesInFile := 0
+	for k := range creator.lines {
+		if _, isOmitted := creator.lineNumbersToOmit[k]; isOmitted {
+			continue
+		}
+		numLinesInFile++
+		if numLinesInFile == lineNum {
+			return k
+		}
+	}
+	return lineNum - 1
+}
@@ -215,6 +215,110 @@ func TestHandleLineErrorForContinue(t *testing.T) {
 	assert.Equal(t, "line3-item1 line3-item2 line3-item3\nline4-item1 line4-item2 line4-item3\n", fileString)
 }
 
+func TestHandleLineErrorForSkipAndSkipSection(t *testing.T) {
+	fakeErrorCommand := testutils.TestCmd{
+		Cmd:      []string{testCommandString},
+		Stdout:   "failure on line 2: match-pattern do something please",
+		ExitCode: 1,
+	}
+	calls := []testutils.TestCmd{fakeErrorCommand, fakeErrorCommand}
+	creator := NewFileCreator(common.NewMockIOShim(calls), 3, "failure on line (\\d+)")
+	creator.UseRunFileLineNumbers()
+	skipHandlers := []*LineErrorHandler{
+		{
+			Definition: NewErrorDefinition("match-pattern"),
+			Method:     Skip,
+			Callback:   func() { log.Logf("'skip' callback") },
+		},
+	}
+	skipSectionHandlers := []*LineErrorHandler{
+		{
+			Definition: NewErrorDefinition("match-pattern"),
+			Method:     SkipSection,
+			Callback:   func() { log.Logf("'skip section' callback") },
+		},
+	}
+	creator.AddLine(section1ID, nil, "line1-item1", "line1-item2", "line1-item3")
+	creator.AddLine(section1ID, skipHandlers, "line2-item1", "line2-item2", "line2-item3")
+	creator.AddLine(section2ID, skipSectionHandlers, "line3-item1", "line3-item2", "line3-item3")
+	creator.AddLine(section1ID, nil, "line4-item1", "line4-item2", "line4-item3")
+	creator.AddLine(section2ID, nil, "line5-item1", "line5-item2", "line5-item3")
+
+	wasFileAltered, err := creator.RunCommandOnceWithFile(testCommandString)
+	require.Error(t, err)
+	require.True(t, wasFileAltered)
+	assert.Equal(
+		t,
+		"line1-item1 line1-item2 line1-item3\nline3-item1 line3-item2 line3-item3\nline4-item1 line4-item2 line4-item3\nline5-item1 line5-item2 line5-item3\n",
+		creator.ToString(),
+	)
+
+	// line 2 of the updated file is the original line 3
+	wasFileAltered, err = creator.RunCommandOnceWithFile(testCommandString)
+	require.Error(t, err)
+	require.True(t, wasFileAltered)
+	assert.Equal(t, "line1-item1 line1-item2 line1-item3\nline4-item1 line4-item2 line4-item3\n", creator.ToString())
+}
+
+func TestHandleLineErrorLineNumbersAfterOmittedLines(t *testing.T) {
+	tests := []struct {
+		name               string
+		runFileLineNumbers bool
+		expectedFile       string
+	}{
+		{
+			// iptables-restore and ipset restore
+			name:         "line numbers of the creator",
+			expectedFile: "line3-item1\nline4-item1\nline5-item1\n",
+		},
+		{
+			// nft
+			name:               "line numbers of the run file",
+			runFileLineNumbers: true,
+			expectedFile:       "line5-item1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fakeErrorCommand := testutils.TestCmd{
+				Cmd:      []string{testCommandString},
+				Stdout:   "failure on line 2: match-pattern do something please",
+				ExitCode: 1,
+			}
+			calls := []testutils.TestCmd{fakeErrorCommand, fakeErrorCommand}
+			creator := NewFileCreator(common.NewMockIOShim(calls), 3, "failure on line (\\d+)")
+			if tt.runFileLineNumbers {
+				creator.UseRunFileLineNumbers()
+			}
+			errorHandlers := []*LineErrorHandler{
+				{
+					Definition: NewErrorDefinition("match-pattern"),
+					Method:     Continue,
+					Callback:   func() {},
+				},
+			}
+			creator.AddLine(section1ID, errorHandlers, "line1-item1")
+			creator.AddLine(section2ID, errorHandlers, "line2-item1")
+			creator.AddLine(section1ID, errorHandlers, "line3-item1")
+			creator.AddLine(section2ID, errorHandlers, "line4-item1")
+			creator.AddLine(section1ID, errorHandlers, "line5-item1")
+
+			wasFileAltered, err := creator.RunCommandOnceWithFile(testCommandString)
+			require.Error(t, err)
+			require.True(t, wasFileAltered)
+			assert.Equal(t, "line3-item1\nline4-item1\nline5-item1\n", creator.ToString())
+
+			// line 2 of the creator was already omitted, line 2 of the run file is line 4 of the creator
+			wasFileAltered, err = creator.RunCommandOnceWithFile(testCommandString)
+			require.Error(t, err)
+			require.True(t, wasFileAltered)
+			assert.Equal(t, tt.expectedFile, creator.ToString())
+		})
+	}
+}
+
 func TestHandleLineErrorNoMatch(t *testing.T) {
 	fakeErrorCommand := testutils.TestCmd{
 		Cmd:      []string{testCommandString},
@@ -126,6 +126,15 @@ func ClearNetworkConfiguration() (bool, error) {
 		return true, err
 	}
 
+	boltStore := CNIRuntimePath + "azure-vnet.db"
+	if _, err := os.Stat(boltStore); err == nil {
+		log.Printf("Deleting the bolt store %s", boltStore)
+		if err := os.Remove(boltStore); err != nil {
+			log.Printf("Error deleting the bolt store %s", boltStore)
+			return true, err
+		}
+	}
+
 	return true, nil
 }
 
@@ -0,0 +1,259 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-container-networking/log"
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// BoltExtension - Extension of the file backing a bolt store.
+	BoltExtension = ".db"
+
+	// boltOpenTimeout is how long to wait for another process to close the bolt file.
+	boltOpenTimeout = 5 * time.Second
+)
+
+// boltBucket is the bucket holding the (key,value) pairs of the store.
+var boltBucket = []byte("state")
+
+// boltStore is an implementation of KeyValueStore using a bbolt B-tree file, which
+// writes each key in its own crash-safe transaction instead of rewriting the whole file.
+// Values are stored JSON encoded, the same as in the jsonFileStore.
+type boltStore struct {
+	fileName string
+	// jsonFileName is the jsonFileStore imported into the bolt file when it is created.
+	jsonFileName string
+	db           *bolt.DB
+	processLock  processlock.Interface
+	sync.Mutex
+}
+
+// NewBoltStore creates a new boltStore object backed by fileName, accessed as a KeyValueStore.
+// If fileName does not exist yet and jsonFileName is an existing jsonFileStore, its contents are
+// imported when the store is first opened. The jsonFileName is left in place but no longer updated.
+func NewBoltStore(fileName, jsonFileName string, lockclient processlock.Interface) (KeyValueStore, error) {
+	if fileName == "" {
+		fileName = defaultFileName + BoltExtension
+	}
+
+	return &boltStore{
+		fileName:     fileName,
+		jsonFileName: jsonFileName,
+		processLock:  lockclient,
+	}, nil
+}
+
+// open opens the bolt file, creating it and importing the JSON store on first use.
+// The file can only be opened by one process at a time, so it is closed again on Unlock.
+func (kvs *boltStore) open() error {
+	if kvs.db != nil {
+		return nil
+	}
+
+	migrate := !kvs.Exists() && kvs.jsonFileName != ""
+	db, err := bolt.Open(kvs.fileName, 0o644, &bolt.Options{Timeout: boltOpenTimeout}) //nolint:gomnd // file mode
+	if err != nil {
+		return errors.Wrapf(err, "failed to open bolt store %s", kvs.fileName)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return errors.Wrap(err, "failed to create bucket")
+		}
+		if !migrate {
+			return nil
+		}
+		return importJSONFile(kvs.jsonFileName, bucket)
+	})
+	if err != nil {
+		db.Close()
+		// don't leave a partially initialized file behind, so that the import is retried.
+		if migrate {
+			_ = os.Remove(kvs.fileName)
+		}
+		return err
+	}
+
+	kvs.db = db
+	return nil
+}
+
+// importJSONFile writes every (key,value) pair of the jsonFileStore at fileName to the bucket.
+func importJSONFile(fileName string, bucket *bolt.Bucket) error {
+	data, err := readJSONFile(fileName, DefaultSnapshots)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrStoreEmpty) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to read %s", fileName)
+	}
+
+	for key, value := range data {
+		if err := bucket.Put([]byte(key), *value); err != nil {
+			return errors.Wrapf(err, "failed to import key %s", key)
+		}
+	}
+
+	log.Printf("Imported %d keys from %s", len(data), fileName)
+	return nil
+}
+
+func (kvs *boltStore) close() {
+	if kvs.db == nil {
+		return
+	}
+	if err := kvs.db.Close(); err != nil {
+		log.Errorf("could not close bolt store %s. Error: %v", kvs.fileName, err)
+	}
+	kvs.db = nil
+}
+
+func (kvs *boltStore) Exists() bool {
+	if _, err := os.Stat(kvs.fileName); err != nil {
+		return false
+	}
+	return true
+}
+
+// Read restores the value for the given key from persistent store.
+func (kvs *boltStore) Read(key string, value interface{}) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	if !kvs.Exists() && kvs.jsonFileName == "" {
+		return ErrKeyNotFound
+	}
+	if err := kvs.open(); err != nil {
+		return err
+	}
+
+	var raw []byte
+	err := kvs.db.View(func(tx *bolt.Tx) error {
+		// the slice is only valid during the transaction, so it is copied out.
+		if v := tx.Bucket(boltBucket).Get([]byte(key)); v != nil {
+			raw = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to read key %s", key)
+	}
+	if raw == nil {
+		return ErrKeyNotFound
+	}
+
+	return json.Unmarshal(raw, value)
+}
+
+// Write saves the given key value pair to persistent store. The write is committed when it returns.
+func (kvs *boltStore) Write(key string, value interface{}) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if err := kvs.open(); err != nil {
+		return err
+	}
+
+	err = kvs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(key), raw)
+	})
+	return errors.Wrapf(err, "failed to write key %s", key)
+}
+
+// Flush is a no-op, since every Write is committed to persistent store.
+func (kvs *boltStore) Flush() error {
+	return nil
+}
+
+func (kvs *boltStore) lockUtil(status chan error) {
+	err := kvs.processLock.Lock()
+	status <- err
+}
+
+// Lock locks the store for exclusive access.
+func (kvs *boltStore) Lock(timeout time.Duration) error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	// a store without a process lock is only used by this process, which the mutex already excludes.
+	if kvs.processLock == nil {
+		return nil
+	}
+
+	afterTime := time.After(timeout)
+	status := make(chan error)
+
+	log.Printf("Acquiring process lock")
+	go kvs.lockUtil(status)
+
+	var err error
+	select {
+	case <-afterTime:
+		return ErrTimeoutLockingStore
+	case err = <-status:
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "processLock acquire error")
+	}
+
+	log.Printf("Acquired process lock")
+	return nil
+}
+
+// Unlock closes the bolt file, so that other processes can open it, and unlocks the store.
+func (kvs *boltStore) Unlock() error {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	kvs.close()
+	if kvs.processLock == nil {
+		return nil
+	}
+	err := kvs.processLock.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "unlock error")
+	}
+
+	log.Printf("Released process lock")
+	return nil
+}
+
+// GetModificationTime returns the modification time of the persistent store.
+func (kvs *boltStore) GetModificationTime() (time.Time, error) {
+	kvs.Mutex.Lock()
+	defer kvs.Mutex.Unlock()
+
+	info, err := os.Stat(kvs.fileName)
+	if err != nil {
+		log.Printf("os.stat() for file %v failed: %v", kvs.fileName, err)
+		return time.Time{}.UTC(), err
+	}
+
+	return info.ModTime().UTC(), nil
+}
+
+func (kvs *boltStore) Remove() {
+	kvs.Mutex.Lock()
+	kvs.close()
+	if err := os.Remove(kvs.fileName); err != nil {
+		log.Errorf("could not remove file %s. Error: %v", kvs.fileName, err)
+	}
+	kvs.Mutex.Unlock()
+}
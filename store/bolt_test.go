@@ -0,0 +1,96 @@
+// Copyright 2017 Microsoft. All rights reserved.
+// MIT License
+
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-container-networking/processlock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that the key value pairs written to a bolt store are read back by a new store on the same file.
+func TestBoltStoreKeyValuePairsArePersisted(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test"+BoltExtension)
+	kvs, err := NewBoltStore(fileName, "", processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+
+	var value testType1
+	require.ErrorIs(t, kvs.Read(testKey1, &value), ErrKeyNotFound)
+	assert.False(t, kvs.Exists())
+
+	require.NoError(t, kvs.Lock(DefaultLockTimeout))
+	require.NoError(t, kvs.Write(testKey1, &testType1{"test", 42}))
+	require.NoError(t, kvs.Write(testKey2, &testType1{"any", 14}))
+	require.NoError(t, kvs.Flush())
+	require.NoError(t, kvs.Unlock())
+	assert.True(t, kvs.Exists())
+
+	kvs, err = NewBoltStore(fileName, "", processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.NoError(t, kvs.Read(testKey1, &value))
+	assert.Equal(t, testType1{"test", 42}, value)
+	require.NoError(t, kvs.Read(testKey2, &value))
+	assert.Equal(t, testType1{"any", 14}, value)
+	require.ErrorIs(t, kvs.Read("missing", &value), ErrKeyNotFound)
+
+	kvs.Remove()
+	assert.False(t, kvs.Exists())
+}
+
+// Tests that an existing JSON store is imported when the bolt store is created, and only then.
+func TestBoltStoreMigratesJSONFile(t *testing.T) {
+	dir := t.TempDir()
+	jsonFileName := filepath.Join(dir, "state.json")
+	require.NoError(t, os.WriteFile(jsonFileName, []byte(`{"key1":{"Field1":"test","Field2":42}}`), 0o600))
+
+	kvs, err := New(BoltBackend, jsonFileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	var value testType1
+	require.NoError(t, kvs.Read(testKey1, &value))
+	assert.Equal(t, testType1{"test", 42}, value)
+	require.NoError(t, kvs.Write(testKey1, &testType1{"updated", 1}))
+	require.NoError(t, kvs.Unlock())
+	assert.FileExists(t, filepath.Join(dir, "state"+BoltExtension))
+
+	// the JSON file is not imported again, and the migrated store is detected without a backend.
+	kvs, err = New("", jsonFileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.IsType(t, &boltStore{}, kvs)
+	require.NoError(t, kvs.Read(testKey1, &value))
+	assert.Equal(t, testType1{"updated", 1}, value)
+}
+
+func TestNewStoreBackend(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "state.json")
+
+	kvs, err := New("", fileName, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &jsonFileStore{}, kvs)
+
+	kvs, err = New(JSONBackend, fileName, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &jsonFileStore{}, kvs)
+
+	_, err = New("sqlite", fileName, nil)
+	require.ErrorIs(t, err, ErrUnknownBackend)
+}
+
+// Tests that a bolt store without a process lock can be locked and unlocked.
+func TestBoltStoreWithoutProcessLock(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test"+BoltExtension)
+	kvs, err := NewBoltStore(fileName, "", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, kvs.Lock(DefaultLockTimeout))
+	require.NoError(t, kvs.Write(testKey1, &testType1{"test", 42}))
+	require.NoError(t, kvs.Unlock())
+
+	var value testType1
+	require.NoError(t, kvs.Read(testKey1, &value))
+	assert.Equal(t, testType1{"test", 42}, value)
+}
@@ -4,9 +4,11 @@
 package store
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -27,11 +29,46 @@ const (
 
 	// DefaultLockTimeout - lock timeout in milliseconds
 	DefaultLockTimeout = 10000 * time.Millisecond
+
+	// DefaultSnapshots - number of previous versions of the file kept to recover from corruption.
+	DefaultSnapshots = 3
+)
+
+// jsonFileEnvelope wraps the (key,value) pairs written to the file with their checksum, so that a
+// file which was corrupted after it was written is detected. It is only written once enabled by
+// SetChecksumEnabled. Files written without an envelope are always read.
+type jsonFileEnvelope struct {
+	SHA256 string
+	Data   json.RawMessage
+}
+
+// RecoveryHandler is called when a corrupt store file was recovered from the snapshot.
+type RecoveryHandler func(fileName, snapshot string, err error)
+
+var (
+	recoveryHandler RecoveryHandler
+	writeChecksum   bool
 )
 
+// SetRecoveryHandler sets the func called whenever a store recovers from a corrupt file, such as to send
+// telemetry. It must be set before any store is read.
+func SetRecoveryHandler(h RecoveryHandler) {
+	recoveryHandler = h
+}
+
+// SetChecksumEnabled makes the stores write their files in a checksum envelope. Files in either layout are read,
+// but versions which predate the envelope read an enveloped file as an empty state and overwrite it, losing the
+// state. So it must only be enabled once no older version will run on the node. To roll back to an older version,
+// first run with the checksum disabled: the next write of each store rewrites its file without the envelope.
+// It must be set before any store is written.
+func SetChecksumEnabled(enabled bool) {
+	writeChecksum = enabled
+}
+
 // jsonFileStore is an implementation of KeyValueStore using a local JSON file.
 type jsonFileStore struct {
 	fileName    string
+	snapshots   int
 	data        map[string]*json.RawMessage
 	inSync      bool
 	processLock processlock.Interface
@@ -47,6 +84,7 @@ func NewJsonFileStore(fileName string, lockclient processlock.Interface) (KeyVal
 
 	kvs := &jsonFileStore{
 		fileName:    fileName,
+		snapshots:   DefaultSnapshots,
 		processLock: lockclient,
 		data:        make(map[string]*json.RawMessage),
 	}
@@ -68,31 +106,19 @@ func (kvs *jsonFileStore) Read(key string, value interface{}) error {
 
 	// Read contents from file if memory is not in sync.
 	if !kvs.inSync {
-		// Open and parse the file if it exists.
-		file, err := os.Open(kvs.fileName)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return ErrKeyNotFound
-			}
-			return err
+		// the file replaces the memory, so that keys deleted by another process are not written back.
+		data, err := readJSONFile(kvs.fileName, kvs.snapshots)
+		if err == ErrKeyNotFound || err == ErrStoreEmpty {
+			kvs.data = make(map[string]*json.RawMessage)
 		}
-		defer file.Close()
-
-		b, err := io.ReadAll(file)
 		if err != nil {
 			return err
 		}
 
-		if len(b) == 0 {
-			log.Printf("Unable to read file %s, was empty", kvs.fileName)
-			return ErrStoreEmpty
-		}
-
-		// Decode to raw JSON messages.
-		if err := json.Unmarshal(b, &kvs.data); err != nil {
-			return err
+		if data == nil {
+			data = make(map[string]*json.RawMessage)
 		}
-
+		kvs.data = data
 		kvs.inSync = true
 	}
 
@@ -130,7 +156,7 @@ func (kvs *jsonFileStore) Flush() error {
 
 // Lock-free flush for internal callers.
 func (kvs *jsonFileStore) flush() error {
-	buf, err := json.MarshalIndent(&kvs.data, "", "\t")
+	buf, err := encodeJSONFile(kvs.data)
 	if err != nil {
 		return err
 	}
@@ -160,18 +186,181 @@ func (kvs *jsonFileStore) flush() error {
 		return fmt.Errorf("Temp file write failed with: %v", err)
 	}
 
+	// make sure the contents are on disk before the file is renamed over the previous one.
+	if err = f.Sync(); err != nil {
+		return fmt.Errorf("temp file sync failed with: %v", err)
+	}
+
 	if err = f.Close(); err != nil {
 		return fmt.Errorf("temp file close failed with: %v", err)
 	}
 
+	kvs.snapshot()
+
 	// atomic replace
 	if err = platform.ReplaceFile(tmpFileName, kvs.fileName); err != nil {
 		return fmt.Errorf("rename temp file to state file failed:%v", err)
 	}
 
+	syncDir(dir)
 	return nil
 }
 
+func snapshotFileName(fileName string, i int) string {
+	return fmt.Sprintf("%s.%d", fileName, i)
+}
+
+// snapshot rotates the snapshots and keeps the current file as the most recent one.
+// The current file stays in place, so that it is never missing if the process crashes.
+// Failing to take a snapshot does not fail the write.
+func (kvs *jsonFileStore) snapshot() {
+	if kvs.snapshots <= 0 {
+		return
+	}
+
+	// snapshots of a file which was deleted are of a state which was discarded, so they are dropped.
+	if _, err := os.Stat(kvs.fileName); err != nil {
+		kvs.removeSnapshots()
+		return
+	}
+
+	for i := kvs.snapshots - 1; i >= 1; i-- {
+		if err := os.Rename(snapshotFileName(kvs.fileName, i), snapshotFileName(kvs.fileName, i+1)); err != nil && !os.IsNotExist(err) {
+			log.Errorf("could not rotate snapshot %s. Error: %v", snapshotFileName(kvs.fileName, i), err)
+		}
+	}
+
+	latest := snapshotFileName(kvs.fileName, 1)
+	_ = os.Remove(latest)
+	if err := os.Link(kvs.fileName, latest); err == nil {
+		return
+	}
+
+	// hard links are not supported on every filesystem, so fall back to a copy.
+	b, err := os.ReadFile(kvs.fileName)
+	if err == nil {
+		err = os.WriteFile(latest, b, 0o644) //nolint:gomnd // file mode
+	}
+	if err != nil {
+		log.Errorf("could not snapshot %s. Error: %v", kvs.fileName, err)
+	}
+}
+
+func (kvs *jsonFileStore) removeSnapshots() {
+	for i := 1; i <= kvs.snapshots; i++ {
+		if err := os.Remove(snapshotFileName(kvs.fileName, i)); err != nil && !os.IsNotExist(err) {
+			log.Errorf("could not remove snapshot %s. Error: %v", snapshotFileName(kvs.fileName, i), err)
+		}
+	}
+}
+
+// syncDir makes a rename in the directory durable where that is supported.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	d.Close()
+}
+
+// encodeJSONFile encodes the (key,value) pairs in a jsonFileEnvelope if the checksum is enabled,
+// and otherwise as a plain JSON object like earlier versions.
+func encodeJSONFile(data map[string]*json.RawMessage) ([]byte, error) {
+	if !writeChecksum {
+		return json.MarshalIndent(&data, "", "\t")
+	}
+
+	b, err := json.Marshal(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(b)
+	return json.MarshalIndent(&jsonFileEnvelope{SHA256: hex.EncodeToString(sum[:]), Data: b}, "", "\t")
+}
+
+// decodeJSONFile decodes the (key,value) pairs from the contents of a file written by encodeJSONFile,
+// or from a plain JSON object written by an earlier version.
+func decodeJSONFile(b []byte) (map[string]*json.RawMessage, error) {
+	if len(b) == 0 {
+		return nil, ErrStoreEmpty
+	}
+
+	var data map[string]*json.RawMessage
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStoreCorrupt, err)
+	}
+
+	if _, ok := data["SHA256"]; !ok || len(data) != 2 || data["Data"] == nil {
+		return data, nil
+	}
+
+	var envelope jsonFileEnvelope
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStoreCorrupt, err)
+	}
+
+	// the data is indented in the file, so the checksum is of its compact encoding.
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, envelope.Data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStoreCorrupt, err)
+	}
+	sum := sha256.Sum256(compact.Bytes())
+	if hex.EncodeToString(sum[:]) != envelope.SHA256 {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrStoreCorrupt)
+	}
+
+	data = nil
+	if err := json.Unmarshal(compact.Bytes(), &data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStoreCorrupt, err)
+	}
+	return data, nil
+}
+
+// readJSONFile reads the (key,value) pairs from the file. If the file exists but is corrupt, they are
+// read from the most recent of its snapshots which is intact instead. An empty file is not recovered:
+// callers treat ErrStoreEmpty as a request to start with a fresh state.
+func readJSONFile(fileName string, snapshots int) (map[string]*json.RawMessage, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+
+	data, err := decodeJSONFile(b)
+	if err == nil {
+		return data, nil
+	}
+	if err == ErrStoreEmpty {
+		log.Printf("Unable to read file %s, was empty", fileName)
+		return nil, err
+	}
+	log.Errorf("Unable to read file %s: %v", fileName, err)
+
+	for i := 1; i <= snapshots; i++ {
+		snapshot := snapshotFileName(fileName, i)
+		b, serr := os.ReadFile(snapshot)
+		if serr != nil {
+			continue
+		}
+		data, serr := decodeJSONFile(b)
+		if serr != nil {
+			log.Errorf("Unable to read snapshot %s: %v", snapshot, serr)
+			continue
+		}
+		log.Printf("Recovered %s from snapshot %s", fileName, snapshot)
+		if recoveryHandler != nil {
+			recoveryHandler(fileName, snapshot, err)
+		}
+		return data, nil
+	}
+
+	return nil, err
+}
+
 func (kvs *jsonFileStore) lockUtil(status chan error) {
 	err := kvs.processLock.Lock()
 	status <- err
@@ -182,6 +371,11 @@ func (kvs *jsonFileStore) Lock(timeout time.Duration) error {
 	kvs.Mutex.Lock()
 	defer kvs.Mutex.Unlock()
 
+	// a store without a process lock is only used by this process, which the mutex already excludes.
+	if kvs.processLock == nil {
+		return nil
+	}
+
 	afterTime := time.After(timeout)
 	status := make(chan error)
 
@@ -199,6 +393,9 @@ func (kvs *jsonFileStore) Lock(timeout time.Duration) error {
 		return errors.Wrap(err, "processLock acquire error")
 	}
 
+	// Another process may have changed the file while the lock was not held.
+	kvs.inSync = false
+
 	log.Printf("Acquired process lock")
 	return nil
 }
@@ -208,6 +405,9 @@ func (kvs *jsonFileStore) Unlock() error {
 	kvs.Mutex.Lock()
 	defer kvs.Mutex.Unlock()
 
+	if kvs.processLock == nil {
+		return nil
+	}
 	err := kvs.processLock.Unlock()
 	if err != nil {
 		return errors.Wrap(err, "unlock error")
@@ -236,5 +436,6 @@ func (kvs *jsonFileStore) Remove() {
 	if err := os.Remove(kvs.fileName); err != nil {
 		log.Errorf("could not remove file %s. Error: %v", kvs.fileName, err)
 	}
+	kvs.removeSnapshots()
 	kvs.Mutex.Unlock()
 }
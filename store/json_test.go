@@ -4,7 +4,10 @@
 package store
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -71,8 +74,13 @@ func TestKeyValuePairsAreReinstantiatedFromJSONFile(t *testing.T) {
 func TestKeyValuePairsArePersistedToJSONFile(t *testing.T) {
 	writtenValue := testType1{"test", 42}
 	expectedPair := `{"key1":{"Field1":"test","Field2":42}}`
+	sum := sha256.Sum256([]byte(expectedPair))
+	expectedFile := `{"SHA256":"` + hex.EncodeToString(sum[:]) + `","Data":` + expectedPair + `}`
 	var actualPair string
 
+	SetChecksumEnabled(true)
+	defer SetChecksumEnabled(false)
+
 	// Create the store.
 	kvs, err := NewJsonFileStore(testFileName, processlock.NewMockFileLock(false))
 	if err != nil {
@@ -86,30 +94,24 @@ func TestKeyValuePairsArePersistedToJSONFile(t *testing.T) {
 	}
 
 	// Read the persisted file contents.
-	file, err := os.Open(testFileName)
-	if err != nil {
-		t.Fatalf("Failed to open file %v", err)
-	}
-
-	data := make([]byte, 100)
-	n, err := file.Read(data)
+	data, err := os.ReadFile(testFileName)
 	if err != nil {
 		t.Fatalf("Failed to read from file %v", err)
 	}
 
-	file.Close()
-	os.Remove(testFileName)
+	// Remove the file and its snapshots.
+	kvs.Remove()
 
 	// Remove indentation to normalize the JSON encoding.
-	actualPair = string(data[:n])
+	actualPair = string(data)
 	actualPair = strings.Replace(actualPair, " ", "", -1)
 	actualPair = strings.Replace(actualPair, "\t", "", -1)
 	actualPair = strings.Replace(actualPair, "\n", "", -1)
 
-	// Fail if the contents do not match expected JSON encoding.
-	if actualPair != expectedPair {
+	// Fail if the contents do not match expected JSON encoding, wrapped with its checksum.
+	if actualPair != expectedFile {
 		t.Errorf("Read pair (%v, %v) does not match the expected pair (%v, %v)",
-			testKey1, actualPair, testKey1, expectedPair)
+			testKey1, actualPair, testKey1, expectedFile)
 	}
 }
 
@@ -150,7 +152,7 @@ func TestKeyValuePairsAreWrittenAndReadCorrectly(t *testing.T) {
 	}
 
 	// Cleanup.
-	os.Remove(testFileName)
+	kvs.Remove()
 }
 
 // test case for testing newjsonfilestore idempotent
@@ -220,3 +222,184 @@ func TestLock(t *testing.T) {
 		})
 	}
 }
+
+// Tests that files are written without the checksum envelope unless it is enabled, so that earlier versions read them.
+func TestFileIsWrittenWithoutChecksumByDefault(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), testFileName)
+	kvs, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.NoError(t, kvs.Write(testKey1, &testType1{"test", 42}))
+
+	b, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key1":{"Field1":"test","Field2":42}}`, string(b))
+
+	// a file written with the envelope is rewritten without it, which is how the checksum is rolled back.
+	SetChecksumEnabled(true)
+	require.NoError(t, kvs.Write(testKey1, &testType1{"test", 43}))
+	SetChecksumEnabled(false)
+	kvs, err = NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	var value testType1
+	require.NoError(t, kvs.Read(testKey1, &value))
+	require.Equal(t, testType1{"test", 43}, value)
+	require.NoError(t, kvs.Write(testKey2, &testType1{"any", 14}))
+
+	b, err = os.ReadFile(fileName)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key1":{"Field1":"test","Field2":43},"key2":{"Field1":"any","Field2":14}}`, string(b))
+}
+
+// Tests that reading the file replaces the keys in memory, so that keys deleted by another store are not written back.
+func TestLockDropsKeysDeletedByOtherStores(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), testFileName)
+	kvs1, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.NoError(t, kvs1.Write(testKey1, &testType1{"test", 42}))
+	require.NoError(t, kvs1.Write(testKey2, &testType1{"any", 14}))
+
+	// another store rewrites the file without key2.
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"key1":{"Field1":"test","Field2":42}}`), 0o600))
+
+	var value testType1
+	require.NoError(t, kvs1.Lock(DefaultLockTimeout))
+	require.NoError(t, kvs1.Read(testKey1, &value))
+	require.ErrorIs(t, kvs1.Read(testKey2, &value), ErrKeyNotFound)
+	require.NoError(t, kvs1.Write(testKey1, &testType1{"test", 43}))
+	require.NoError(t, kvs1.Unlock())
+
+	b, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"key1":{"Field1":"test","Field2":43}}`, string(b))
+}
+
+// Tests that locking the store reads the changes made by another store of the same file.
+func TestLockReadsChangesOfOtherStores(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), testFileName)
+	kvs1, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	kvs2, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+
+	var value testType1
+	require.NoError(t, kvs1.Lock(DefaultLockTimeout))
+	require.NoError(t, kvs1.Write(testKey1, testType1{Field1: "before", Field2: 1}))
+	require.NoError(t, kvs1.Read(testKey1, &value))
+	require.NoError(t, kvs1.Unlock())
+
+	require.NoError(t, kvs2.Lock(DefaultLockTimeout))
+	require.NoError(t, kvs2.Write(testKey1, testType1{Field1: "after", Field2: 2}))
+	require.NoError(t, kvs2.Unlock())
+
+	require.NoError(t, kvs1.Lock(DefaultLockTimeout))
+	require.NoError(t, kvs1.Read(testKey1, &value))
+	require.NoError(t, kvs1.Unlock())
+	require.Equal(t, testType1{Field1: "after", Field2: 2}, value)
+}
+
+// Tests that a corrupt file is recovered from the most recent intact snapshot.
+func TestCorruptFileIsRecoveredFromSnapshot(t *testing.T) {
+	SetChecksumEnabled(true)
+	defer SetChecksumEnabled(false)
+
+	fileName := filepath.Join(t.TempDir(), testFileName)
+	kvs, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.NoError(t, kvs.Write(testKey1, &testType1{"first", 1}))
+	require.NoError(t, kvs.Write(testKey1, &testType1{"second", 2}))
+	require.NoError(t, kvs.Write(testKey1, &testType1{"third", 3}))
+	require.FileExists(t, fileName+".1")
+	require.FileExists(t, fileName+".2")
+
+	var recovered string
+	SetRecoveryHandler(func(_, snapshot string, err error) {
+		recovered = snapshot
+		require.ErrorIs(t, err, ErrStoreCorrupt)
+	})
+	defer SetRecoveryHandler(nil)
+
+	tests := []struct {
+		name    string
+		corrupt func(b []byte) []byte
+		want    testType1
+	}{
+		{
+			name:    "checksum mismatch",
+			corrupt: func(b []byte) []byte { return []byte(strings.Replace(string(b), "third", "thirt", 1)) },
+			want:    testType1{"second", 2},
+		},
+		{
+			name:    "truncated",
+			corrupt: func(b []byte) []byte { return b[:len(b)/2] },
+			want:    testType1{"second", 2},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := os.ReadFile(fileName)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(fileName+".bak", b, 0o600))
+			defer os.Rename(fileName+".bak", fileName) //nolint:errcheck // restore the intact file
+			require.NoError(t, os.WriteFile(fileName, tt.corrupt(b), 0o600))
+
+			recovered = ""
+			kvs, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+			require.NoError(t, err)
+			var value testType1
+			require.NoError(t, kvs.Read(testKey1, &value))
+			require.Equal(t, tt.want, value)
+			require.Equal(t, fileName+".1", recovered)
+		})
+	}
+
+	// a corrupt snapshot is skipped for an older one.
+	require.NoError(t, os.WriteFile(fileName, []byte("{"), 0o600))
+	require.NoError(t, os.WriteFile(fileName+".1", []byte("{"), 0o600))
+	kvs, err = NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	var value testType1
+	require.NoError(t, kvs.Read(testKey1, &value))
+	require.Equal(t, testType1{"first", 1}, value)
+	require.Equal(t, fileName+".2", recovered)
+}
+
+// Tests that files written before the checksum envelope are still read, and snapshots of a deleted file are not.
+func TestPlainFileIsReadAndDeletedFileIsNotRecovered(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), testFileName)
+	require.NoError(t, os.WriteFile(fileName, []byte(`{"key1":{"Field1":"test","Field2":42}}`), 0o600))
+	kvs, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	var value testType1
+	require.NoError(t, kvs.Read(testKey1, &value))
+	require.Equal(t, testType1{"test", 42}, value)
+	require.NoError(t, kvs.Write(testKey2, &testType1{"any", 14}))
+	require.FileExists(t, fileName+".1")
+
+	require.NoError(t, os.Remove(fileName))
+	kvs, err = NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.ErrorIs(t, kvs.Read(testKey1, &value), ErrKeyNotFound)
+	require.NoError(t, kvs.Write(testKey2, &testType1{"any", 14}))
+	require.NoFileExists(t, fileName+".1")
+}
+
+func TestEmptyFileIsNotRecovered(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), testFileName)
+	kvs, err := NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	require.NoError(t, kvs.Write(testKey1, &testType1{"first", 1}))
+	require.NoError(t, kvs.Write(testKey1, &testType1{"second", 2}))
+	require.FileExists(t, fileName+".1")
+
+	// an empty file is how callers start with a fresh state, so the snapshots must not bring it back.
+	require.NoError(t, os.WriteFile(fileName, nil, 0o600))
+	kvs, err = NewJsonFileStore(fileName, processlock.NewMockFileLock(false))
+	require.NoError(t, err)
+	var value testType1
+	require.ErrorIs(t, kvs.Read(testKey1, &value), ErrStoreEmpty)
+
+	kvs.Remove()
+	require.NoFileExists(t, fileName)
+	require.NoFileExists(t, fileName+".1")
+}
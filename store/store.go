@@ -5,7 +5,11 @@ package store
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/Azure/azure-container-networking/processlock"
 )
 
 // KeyValueStore represents a persistent store of (key,value) pairs.
@@ -20,12 +24,47 @@ type KeyValueStore interface {
 	Remove()
 }
 
+// Backend is the implementation backing a KeyValueStore.
+type Backend string
+
+const (
+	// JSONBackend stores the whole state in a JSON file, which is rewritten on every write.
+	JSONBackend Backend = "json"
+	// BoltBackend stores the state in a bbolt B-tree file, with a transaction per written key.
+	BoltBackend Backend = "bolt"
+)
+
+// New creates a KeyValueStore for the state at the JSON fileName using the passed backend.
+// The bolt backend stores the state next to it with the BoltExtension instead, and imports the
+// JSON file when it is first created. If backend is empty, the bolt backend is used if its file
+// already exists, so that a migrated store keeps being used, and the JSON backend otherwise.
+func New(backend Backend, fileName string, lockclient processlock.Interface) (KeyValueStore, error) {
+	boltFileName := strings.TrimSuffix(fileName, ".json") + BoltExtension
+	if backend == "" {
+		backend = JSONBackend
+		if _, err := os.Stat(boltFileName); err == nil {
+			backend = BoltBackend
+		}
+	}
+
+	switch backend {
+	case JSONBackend:
+		return NewJsonFileStore(fileName, lockclient)
+	case BoltBackend:
+		return NewBoltStore(boltFileName, fileName, lockclient)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, backend)
+	}
+}
+
 var (
 	// Errors returned by KeyValueStore methods.
 	ErrKeyNotFound                    = fmt.Errorf("key not found")
 	ErrStoreLocked                    = fmt.Errorf("store is already locked")
 	ErrStoreNotLocked                 = fmt.Errorf("store is not locked")
 	ErrStoreEmpty                     = fmt.Errorf("store is empty")
+	ErrStoreCorrupt                   = fmt.Errorf("store is corrupt")
 	ErrTimeoutLockingStore            = fmt.Errorf("timed out locking store")
 	ErrNonBlockingLockIsAlreadyLocked = fmt.Errorf("attempted to perform non-blocking lock on an already locked store")
+	ErrUnknownBackend                 = fmt.Errorf("unknown store backend")
 )
@@ -9,6 +9,7 @@ const (
 	CNIDelTimeMetricStr    = "CNIDelTimeMs"
 	CNIUpdateTimeMetricStr = "CNIUpdateTimeMs"
 	CNILockTimeoutStr      = "CNILockTimeoutError"
+	CNIStoreRecoveredStr   = "CNIStoreRecovered"
 
 	// Dimension Names
 	ContextStr        = "Context"
@@ -20,6 +21,7 @@ const (
 	CNIModeStr        = "CNIMode"
 	CNINetworkModeStr = "CNINetworkMode"
 	OSTypeStr         = "OSType"
+	StoreFileStr      = "StoreFile"
 
 	// Values
 	SucceededStr     = "Succeeded"